@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/juicycleff/smartform/v1"
+)
+
+// smartform-doctor probes the dependencies of schema files and prints a readiness matrix.
+// It exits with status 1 when any check fails, so it can gate a deployment.
+//
+//	smartform-doctor -env staging forms/*.json
+func main() {
+	environment := flag.String("env", "", "environment whose schema overlays are applied")
+	timeout := flag.Duration("timeout", smartform.DefaultProbeTimeout, "timeout of a single probe")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: smartform-doctor [-env name] [-timeout 5s] [-json] schema.json...")
+		os.Exit(2)
+	}
+
+	doctor := smartform.NewDoctor().SetTimeout(*timeout)
+	for _, path := range flag.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("error reading %s: %v", path, err)
+		}
+
+		schema, err := smartform.FormSchemaFromJSONForEnvironment(string(content), *environment)
+		if err != nil {
+			log.Fatalf("error loading %s: %v", path, err)
+		}
+		doctor.AddSchema(schema)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	report := doctor.Run(ctx)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		fmt.Print(report.String())
+	}
+
+	if !report.Ready {
+		os.Exit(1)
+	}
+}
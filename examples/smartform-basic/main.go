@@ -55,10 +55,10 @@ func registerContactFormWithBuilder(handler *smartform.APIHandler) {
 		AddValidation(v.Email("Please enter a valid email address"))
 
 	// Add phone field
-	form.TextField("phone", "Phone Number").
+	form.PhoneField("phone", "Phone Number").
+		DefaultCountry("US").
 		Required(false).
-		Placeholder("(123) 456-7890").
-		AddValidation(v.Pattern("^[0-9\\-\\+\\s\\(\\)]+$", "Please enter a valid phone number"))
+		Placeholder("(201) 555-0123")
 
 	// Add subject field with options
 	form.SelectField("subject", "Subject").
@@ -138,7 +138,8 @@ func registerUserProfileFormWithBuilder(handler *smartform.APIHandler) {
 		AddValidation(v.Email("Please enter a valid email address"))
 
 	// Add phone field
-	form.TextField("phone", "Phone Number").
+	form.PhoneField("phone", "Phone Number").
+		DefaultCountry("US").
 		Required(false)
 
 	// Add address group with nested fields
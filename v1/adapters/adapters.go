@@ -0,0 +1,125 @@
+// Package adapters mounts the smartform API on third-party HTTP routers such as chi, gin, echo,
+// and fiber, without the smartform module depending on any of them.
+//
+// Every route is registered on the router with the pattern syntax the router expects, so its
+// middleware chain, logging, and metrics apply per route. Routers are given a register function
+// wrapping the handler the way they need:
+//
+//	adapters.Mount(handler, adapters.Chi, r.Handle)
+//	adapters.Mount(handler, adapters.Gin, func(pattern string, h http.Handler) { r.Any(pattern, gin.WrapH(h)) })
+//	adapters.Mount(handler, adapters.Echo, func(pattern string, h http.Handler) { e.Any(pattern, echo.WrapHandler(h)) })
+//	adapters.Mount(handler, adapters.Fiber, func(pattern string, h http.Handler) { app.All(pattern, adaptor.HTTPHandler(h)) })
+//
+// Handlers read their parameters from the request path, so routes are registered at their own
+// path. To serve the API below a prefix, mount smartform's APIHandler.Handler wrapped in
+// http.StripPrefix instead.
+package adapters
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+// Style describes how a router writes route patterns
+type Style struct {
+	// Wildcard replaces the trailing slash of routes matching every path below their own, e.g.
+	// "*" turns "/api/forms/" into "/api/forms/*". Empty keeps the ServeMux syntax.
+	Wildcard string
+	// Nested reports whether a catch-all pattern may have other routes below it. Routers that
+	// reject them get a single catch-all dispatching to the routes below it.
+	Nested bool
+}
+
+// Styles of the supported routers
+var (
+	ServeMux = Style{Nested: true}
+	Chi      = Style{Wildcard: "*", Nested: true}
+	Echo     = Style{Wildcard: "*", Nested: true}
+	Fiber    = Style{Wildcard: "*", Nested: true}
+	Gin      = Style{Wildcard: "*path"}
+)
+
+// Routes returns the routes of the API with patterns in the given style, the most specific
+// first so that routers matching in registration order pick the right one
+func Routes(handler *smartform.APIHandler, style Style) []smartform.Route {
+	routes := handler.Routes()
+	if !style.Nested {
+		routes = collapse(routes)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].IsPrefix() != routes[j].IsPrefix() {
+			return !routes[i].IsPrefix()
+		}
+		return len(routes[i].Path) > len(routes[j].Path)
+	})
+
+	patterns := make([]smartform.Route, len(routes))
+	for i, route := range routes {
+		patterns[i] = smartform.Route{Path: Pattern(route, style), Handler: route.Handler}
+	}
+	return patterns
+}
+
+// Mount registers every route of the API with register, which adds a pattern and its handler
+// to a router for all methods
+func Mount(handler *smartform.APIHandler, style Style, register func(pattern string, h http.Handler)) {
+	for _, route := range Routes(handler, style) {
+		register(route.Path, route.Handler)
+	}
+}
+
+// Pattern writes the path of a route in the given style
+func Pattern(route smartform.Route, style Style) string {
+	if !route.IsPrefix() || style.Wildcard == "" {
+		return route.Path
+	}
+	return route.Path + style.Wildcard
+}
+
+// collapse merges the routes below a prefix route into it, dispatching between them with a
+// ServeMux
+func collapse(routes []smartform.Route) []smartform.Route {
+	outer := func(route smartform.Route) (smartform.Route, bool) {
+		for _, candidate := range routes {
+			if candidate.IsPrefix() && candidate.Path != route.Path && strings.HasPrefix(route.Path, candidate.Path) {
+				return candidate, true
+			}
+		}
+		return smartform.Route{}, false
+	}
+
+	muxes := map[string]*http.ServeMux{}
+	collapsed := []smartform.Route{}
+	for _, route := range routes {
+		if _, nested := outer(route); nested {
+			continue
+		}
+		if !route.IsPrefix() {
+			collapsed = append(collapsed, route)
+			continue
+		}
+		mux := http.NewServeMux()
+		muxes[route.Path] = mux
+		collapsed = append(collapsed, smartform.Route{Path: route.Path, Handler: mux})
+	}
+
+	// Register the routes of each catch-all on its mux, following each route up to the
+	// outermost prefix route
+	for _, route := range routes {
+		top := route
+		for {
+			parent, nested := outer(top)
+			if !nested {
+				break
+			}
+			top = parent
+		}
+		if mux, ok := muxes[top.Path]; ok {
+			mux.Handle(route.Path, route.Handler)
+		}
+	}
+	return collapsed
+}
@@ -0,0 +1,101 @@
+package adapters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+func newHandler(t *testing.T) *smartform.APIHandler {
+	form := smartform.NewForm("contact", "Contact")
+	form.TextField("name", "Name").Required(true)
+	handler := smartform.NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	return handler
+}
+
+func patterns(handler *smartform.APIHandler, style Style) []string {
+	paths := []string{}
+	for _, route := range Routes(handler, style) {
+		paths = append(paths, route.Path)
+	}
+	return paths
+}
+
+func TestPatterns(t *testing.T) {
+	handler := newHandler(t)
+
+	chi := patterns(handler, Chi)
+	assert.Contains(t, chi, "/api/forms")
+	assert.Contains(t, chi, "/api/forms/*")
+	assert.Contains(t, chi, "/api/options/cache")
+	assert.Contains(t, chi, "/api/options/*")
+	assert.Equal(t, len(handler.Routes()), len(chi))
+
+	// Exact routes come first and longer prefixes before shorter ones, for routers matching in
+	// registration order
+	index := func(paths []string, path string) int {
+		for i, p := range paths {
+			if p == path {
+				return i
+			}
+		}
+		return -1
+	}
+	assert.Less(t, index(chi, "/api/options/cache"), index(chi, "/api/options/*"))
+	assert.Less(t, index(chi, "/api/options/function/*"), index(chi, "/api/options/*"))
+
+	// Gin cannot nest routes below a catch-all, so they are served by the outer one
+	gin := patterns(handler, Gin)
+	assert.Contains(t, gin, "/api/options/*path")
+	assert.NotContains(t, gin, "/api/options/cache")
+	assert.NotContains(t, gin, "/api/options/dynamic/*path")
+	assert.NotContains(t, gin, "/api/auth/tokens/*path")
+	assert.Contains(t, gin, "/api/field/validate/*path")
+}
+
+func TestMount(t *testing.T) {
+	// A router registering handlers in order and matching the first pattern that fits, with
+	// the "*" wildcard syntax of chi, echo, and fiber
+	type entry struct {
+		pattern string
+		handler http.Handler
+	}
+	var table []entry
+	Mount(newHandler(t), Fiber, func(pattern string, h http.Handler) {
+		table = append(table, entry{pattern, h})
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		for _, e := range table {
+			if e.pattern == path || (strings.HasSuffix(e.pattern, "*") && strings.HasPrefix(path, strings.TrimSuffix(e.pattern, "*"))) {
+				e.handler.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+				return rec
+			}
+		}
+		rec.WriteHeader(http.StatusNotFound)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/forms", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"contact"`)
+	rec = serve(http.MethodPost, "/api/validate/contact", `{"name": ""}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"valid":false`)
+
+	// Gin's collapsed catch-alls still dispatch to the routes below them
+	for _, route := range Routes(newHandler(t), Gin) {
+		if route.Path == "/api/options/*path" {
+			rec := httptest.NewRecorder()
+			route.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/cache", nil))
+			assert.NotEqual(t, http.StatusNotFound, rec.Code)
+		}
+	}
+}
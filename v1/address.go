@@ -0,0 +1,304 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// IDs of the sub-fields of address fields, which are also the keys of the address components
+// of suggestions
+const (
+	AddressStreet  = "street"
+	AddressCity    = "city"
+	AddressState   = "state"
+	AddressZip     = "zip"
+	AddressCountry = "country"
+)
+
+// addressComponents are the sub-fields of address fields, in order, with their labels
+var addressComponents = []struct {
+	id    string
+	label string
+}{
+	{AddressStreet, "Street"},
+	{AddressCity, "City"},
+	{AddressState, "State"},
+	{AddressZip, "ZIP code"},
+	{AddressCountry, "Country"},
+}
+
+// Limits of address suggestions
+const (
+	// MinAddressQueryLength is the shortest query sent to a geocoder; shorter queries get no
+	// suggestions, sparing the provider's quota while the user starts typing
+	MinAddressQueryLength = 3
+	// DefaultAddressSuggestions is how many suggestions are returned unless asked otherwise
+	DefaultAddressSuggestions = 5
+	// maxAddressSuggestions is the most suggestions a client may ask for
+	maxAddressSuggestions = 10
+)
+
+// ErrGeocoderNotFound is returned when no geocoder is registered under the name asked for
+var ErrGeocoderNotFound = errors.New("geocoder not found")
+
+// AddressQuery is the text a user typed into an address field, to be completed by a geocoder
+type AddressQuery struct {
+	Text         string `json:"text"`
+	Country      string `json:"country,omitempty"`      // ISO 3166-1 alpha-2 code to restrict suggestions to
+	Language     string `json:"language,omitempty"`     // Language of the suggestions, e.g. "en"
+	Limit        int    `json:"limit,omitempty"`        // Most suggestions to return
+	SessionToken string `json:"sessionToken,omitempty"` // Groups the requests of one address entry for providers billing per session
+}
+
+// AddressSuggestion is an address a geocoder suggests for a query
+type AddressSuggestion struct {
+	ID        string                 `json:"id,omitempty"` // Provider's ID of the place, to resolve it with
+	Label     string                 `json:"label"`        // Full address, as shown in the list of suggestions
+	Address   map[string]interface{} `json:"address,omitempty"`
+	Latitude  float64                `json:"latitude,omitempty"`
+	Longitude float64                `json:"longitude,omitempty"`
+}
+
+// Geocoder suggests addresses completing the text a user typed. The Address of suggestions
+// holds the components keyed by the sub-field IDs of address fields, such as AddressStreet.
+type Geocoder interface {
+	Suggest(ctx context.Context, query *AddressQuery) ([]*AddressSuggestion, error)
+}
+
+// AddressResolver is implemented by geocoders whose suggestions carry only a label and an ID,
+// and must be resolved to their address components once the user picks one
+type AddressResolver interface {
+	Resolve(ctx context.Context, id, sessionToken string) (*AddressSuggestion, error)
+}
+
+// registeredGeocoder is a geocoder with the limiter of the requests sent to its provider
+type registeredGeocoder struct {
+	geocoder Geocoder
+	limiter  *rateLimiter
+}
+
+// AddressFieldBuilder provides a fluent API for creating address fields: groups of street,
+// city, state, ZIP code, and country that clients can fill from address suggestions
+type AddressFieldBuilder struct {
+	GroupFieldBuilder
+}
+
+// NewAddressFieldBuilder creates a new address field builder. The street, city, ZIP code, and
+// country are required by default.
+func NewAddressFieldBuilder(id, label string) *AddressFieldBuilder {
+	builder := &AddressFieldBuilder{GroupFieldBuilder: *NewGroupFieldBuilder(id, label)}
+	builder.Property("address", true)
+	for _, component := range addressComponents {
+		builder.AddField(NewFieldBuilder(component.id, FieldTypeText, component.label).
+			Required(component.id != AddressState).
+			Build())
+	}
+	return builder
+}
+
+// RequiredComponents sets which sub-fields of the address are required, e.g. AddressStreet
+func (ab *AddressFieldBuilder) RequiredComponents(components ...string) *AddressFieldBuilder {
+	for _, field := range ab.field.Nested {
+		field.Required = false
+		for _, component := range components {
+			if field.ID == component {
+				field.Required = true
+			}
+		}
+	}
+	return ab
+}
+
+// ComponentLabel sets the label of a sub-field of the address, e.g. "Postcode" for AddressZip
+func (ab *AddressFieldBuilder) ComponentLabel(component, label string) *AddressFieldBuilder {
+	for _, field := range ab.field.Nested {
+		if field.ID == component {
+			field.Label = label
+		}
+	}
+	return ab
+}
+
+// Autocomplete lets clients fill the address from the suggestions of the named geocoder, or
+// of the default geocoder when the name is empty
+func (ab *AddressFieldBuilder) Autocomplete(geocoder string) *AddressFieldBuilder {
+	autocomplete := map[string]interface{}{"endpoint": "/api/address/suggest"}
+	if geocoder != "" {
+		autocomplete["provider"] = geocoder
+	}
+	ab.Property("autocomplete", autocomplete)
+	return ab
+}
+
+// Countries restricts the suggestions to addresses in the given countries, as ISO 3166-1
+// alpha-2 codes
+func (ab *AddressFieldBuilder) Countries(countries ...string) *AddressFieldBuilder {
+	ab.Property("countries", countries)
+	return ab
+}
+
+// Build finalizes and returns the address field
+func (ab *AddressFieldBuilder) Build() *Field {
+	return ab.field
+}
+
+// AddressField adds an address field to the form
+func (fb *FormBuilder) AddressField(id, label string) *AddressFieldBuilder {
+	field := NewAddressFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// AddressField adds an address field to the group and returns an AddressFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) AddressField(id, label string) *AddressFieldBuilder {
+	field := NewAddressFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
+// RegisterGeocoder registers a geocoder for address suggestions under a name. Requests sent to
+// its provider are limited to the given rate across all clients, as providers such as
+// Nominatim require; a zero limit leaves them unlimited. The first geocoder registered is the
+// default.
+func (ah *APIHandler) RegisterGeocoder(name string, geocoder Geocoder, limit RateLimit) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	if ah.geocoders == nil {
+		ah.geocoders = make(map[string]*registeredGeocoder)
+	}
+	if len(ah.geocoders) == 0 {
+		ah.defaultGeocoder = name
+	}
+	ah.geocoders[name] = &registeredGeocoder{geocoder: geocoder, limiter: newRateLimiter(limit)}
+}
+
+// geocoder returns the geocoder registered under a name, or the default one for no name
+func (ah *APIHandler) geocoder(name string) (string, *registeredGeocoder, error) {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+	if name == "" {
+		name = ah.defaultGeocoder
+	}
+	registered, ok := ah.geocoders[name]
+	if !ok {
+		return name, nil, ErrGeocoderNotFound
+	}
+	return name, registered, nil
+}
+
+// SuggestAddresses asks the named geocoder, or the default one, for suggestions completing a
+// query. Queries shorter than MinAddressQueryLength get no suggestions.
+func (ah *APIHandler) SuggestAddresses(ctx context.Context, name string, query *AddressQuery) ([]*AddressSuggestion, error) {
+	_, registered, err := ah.geocoder(name)
+	if err != nil {
+		return nil, err
+	}
+	if len([]rune(strings.TrimSpace(query.Text))) < MinAddressQueryLength {
+		return []*AddressSuggestion{}, nil
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultAddressSuggestions
+	}
+	query.Limit = min(query.Limit, maxAddressSuggestions)
+
+	suggestions, err := registered.geocoder.Suggest(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(suggestions) > query.Limit {
+		suggestions = suggestions[:query.Limit]
+	}
+	return suggestions, nil
+}
+
+// handleAddress handles requests for address suggestions, and to resolve a suggestion to its
+// address components
+func (ah *APIHandler) handleAddress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	action := getPathParam(r.URL.Path, "/api/address/")
+	if action != "suggest" && action != "resolve" {
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+		return
+	}
+
+	params := r.URL.Query()
+	name, registered, err := ah.geocoder(params.Get("provider"))
+	if err != nil {
+		writeError(w, notConfigured(http.StatusNotFound, fmt.Sprintf("No geocoder %q is registered", name)))
+		return
+	}
+
+	if action == "resolve" {
+		ah.resolveAddress(w, r, name, registered)
+		return
+	}
+
+	query := &AddressQuery{
+		Text:         params.Get("q"),
+		Country:      params.Get("country"),
+		Language:     params.Get("language"),
+		SessionToken: params.Get("sessionToken"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			writeError(w, badRequest("limit must be a number"))
+			return
+		}
+	}
+
+	// Short queries are answered without calling the provider, so they do not count
+	if len([]rune(strings.TrimSpace(query.Text))) >= MinAddressQueryLength {
+		if retryAfter, ok := registered.limiter.allow(name); !ok {
+			writeRateLimited(w, retryAfter, "Too many address suggestions")
+			return
+		}
+	}
+
+	suggestions, err := ah.SuggestAddresses(r.Context(), name, query)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadGateway, fmt.Errorf("error suggesting addresses: %w", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"provider":    name,
+		"suggestions": suggestions,
+	})
+}
+
+// resolveAddress handles requests to resolve a suggestion to its address components
+func (ah *APIHandler) resolveAddress(w http.ResponseWriter, r *http.Request, name string, registered *registeredGeocoder) {
+	resolver, ok := registered.geocoder.(AddressResolver)
+	if !ok {
+		writeError(w, notConfigured(http.StatusNotImplemented, fmt.Sprintf("Geocoder %s has no suggestions to resolve", name)))
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, badRequest("Suggestion ID is required"))
+		return
+	}
+
+	if retryAfter, ok := registered.limiter.allow(name); !ok {
+		writeRateLimited(w, retryAfter, "Too many address lookups")
+		return
+	}
+
+	suggestion, err := resolver.Resolve(r.Context(), id, r.URL.Query().Get("sessionToken"))
+	if err != nil {
+		writeError(w, statusError(http.StatusBadGateway, fmt.Errorf("error resolving address: %w", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(suggestion)
+}
@@ -0,0 +1,96 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGeocoder suggests one address per query and counts the queries it was sent
+type fakeGeocoder struct {
+	queries []*AddressQuery
+}
+
+func (fg *fakeGeocoder) Suggest(ctx context.Context, query *AddressQuery) ([]*AddressSuggestion, error) {
+	fg.queries = append(fg.queries, query)
+	return []*AddressSuggestion{{
+		Label:   "1 Main Street, Springfield",
+		Address: map[string]interface{}{AddressStreet: "1 Main Street", AddressCity: "Springfield"},
+	}}, nil
+}
+
+func TestAddressField(t *testing.T) {
+	builder := NewForm("shipping", "Shipping")
+	builder.AddressField("shipTo", "Ship to").
+		RequiredComponents(AddressStreet, AddressCity, AddressCountry).
+		ComponentLabel(AddressZip, "Postcode").
+		Autocomplete("osm")
+	schema := builder.Build()
+
+	field := schema.FindFieldByID("shipTo")
+	require.NotNil(t, field)
+	assert.Equal(t, FieldTypeGroup, field.Type)
+	assert.Equal(t, true, field.Properties["address"])
+	assert.Equal(t, "osm", field.Properties["autocomplete"].(map[string]interface{})["provider"])
+	require.Len(t, field.Nested, 5)
+	assert.Equal(t, "Postcode", field.Nested[3].Label)
+
+	result := schema.Validate(map[string]interface{}{
+		"shipTo": map[string]interface{}{"street": "1 Main Street", "city": "Springfield"},
+	})
+	require.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "shipTo.country", result.Errors[0].FieldID)
+}
+
+func TestAddressSuggestEndpoint(t *testing.T) {
+	geocoder := &fakeGeocoder{}
+	handler := NewAPIHandler()
+	handler.RegisterGeocoder("fake", geocoder, RateLimit{Requests: 2, Per: time.Minute})
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/address/suggest?q=1+Main&country=US&limit=50")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response struct {
+		Provider    string               `json:"provider"`
+		Suggestions []*AddressSuggestion `json:"suggestions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "fake", response.Provider)
+	require.Len(t, response.Suggestions, 1)
+	assert.Equal(t, "Springfield", response.Suggestions[0].Address[AddressCity])
+	assert.Equal(t, "US", geocoder.queries[0].Country)
+	assert.Equal(t, maxAddressSuggestions, geocoder.queries[0].Limit)
+
+	// Short queries are answered without calling the provider, or counting against its limit
+	rec = get("/api/address/suggest?q=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, geocoder.queries, 1)
+
+	rec = get("/api/address/suggest?q=1+Main+St")
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = get("/api/address/suggest?q=1+Main+Street")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Len(t, geocoder.queries, 2)
+
+	rec = get("/api/address/suggest?q=1+Main&provider=missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// The fake geocoder's suggestions are complete, so there is nothing to resolve
+	rec = get("/api/address/resolve?id=abc")
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
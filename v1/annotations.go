@@ -0,0 +1,188 @@
+package smartform
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// annotationKeyPattern matches annotation keys: a vendor prefix, such as "sql" or "acme.com",
+// a slash, and a name
+var annotationKeyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?/[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+// Annotations carry metadata of downstream tools, such as database column types or CRM field
+// mappings, through smartform without affecting how forms behave. Keys are prefixed with the
+// vendor of the tool, e.g. "sql/column" or "acme.com/crm-field", so that tools do not clash.
+// Annotations are kept by serialization, import, and cloning, but are not sent with rendered
+// forms.
+type Annotations map[string]interface{}
+
+// ValidAnnotationKey reports whether a key has a vendor prefix and a name, such as "sql/column"
+func ValidAnnotationKey(key string) bool {
+	return annotationKeyPattern.MatchString(key)
+}
+
+// Has reports whether an annotation is set
+func (a Annotations) Has(key string) bool {
+	_, ok := a[key]
+	return ok
+}
+
+// String returns an annotation holding text
+func (a Annotations) String(key string) (string, bool) {
+	value, ok := a[key].(string)
+	return value, ok
+}
+
+// Bool returns an annotation holding a boolean
+func (a Annotations) Bool(key string) (bool, bool) {
+	value, ok := a[key].(bool)
+	return value, ok
+}
+
+// Float returns an annotation holding a number
+func (a Annotations) Float(key string) (float64, bool) {
+	switch value := a[key].(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// Int returns an annotation holding a whole number, such as a column length
+func (a Annotations) Int(key string) (int, bool) {
+	value, ok := a.Float(key)
+	if !ok || value != math.Trunc(value) {
+		return 0, false
+	}
+	return int(value), true
+}
+
+// Strings returns an annotation holding a list of text
+func (a Annotations) Strings(key string) ([]string, bool) {
+	switch value := a[key].(type) {
+	case []string:
+		return value, true
+	case []interface{}:
+		strs := make([]string, len(value))
+		for i, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			strs[i] = s
+		}
+		return strs, true
+	}
+	return nil, false
+}
+
+// Decode decodes an annotation holding a structure into target, as JSON would
+func (a Annotations) Decode(key string, target interface{}) error {
+	value, ok := a[key]
+	if !ok {
+		return fmt.Errorf("annotation %s is not set", key)
+	}
+	if err := decodeRaw(value, target); err != nil {
+		return fmt.Errorf("invalid annotation %s: %w", key, err)
+	}
+	return nil
+}
+
+// Vendor returns the annotations with the given prefix, keyed by their name without it
+func (a Annotations) Vendor(prefix string) Annotations {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	vendor := Annotations{}
+	for key, value := range a {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			vendor[name] = value
+		}
+	}
+	return vendor
+}
+
+// Clone returns a deep copy of the annotations
+func (a Annotations) Clone() Annotations {
+	return Annotations(cloneMap(a))
+}
+
+// FieldAnnotations returns the annotations of the fields that have any with the given prefix,
+// keyed by field path and by annotation name without the prefix, for generators that only
+// care about their own annotations
+func (fs *FormSchema) FieldAnnotations(prefix string) map[string]Annotations {
+	annotated := map[string]Annotations{}
+	var collect func(fields []*Field, container string)
+	collect = func(fields []*Field, container string) {
+		for _, field := range fields {
+			path := joinPath(container, field.ID)
+			if vendor := field.Annotations.Vendor(prefix); len(vendor) > 0 {
+				annotated[path] = vendor
+			}
+			collect(field.Nested, path)
+		}
+	}
+	collect(fs.Fields, "")
+	return annotated
+}
+
+// CheckAnnotations checks that every annotation key of the schema and its fields has a vendor
+// prefix
+func (fs *FormSchema) CheckAnnotations() error {
+	check := func(owner string, annotations Annotations) error {
+		keys := make([]string, 0, len(annotations))
+		for key := range annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !ValidAnnotationKey(key) {
+				return fmt.Errorf("annotation %q of %s must be a vendor prefix and a name, such as \"sql/column\"", key, owner)
+			}
+		}
+		return nil
+	}
+
+	if err := check("form "+fs.ID, fs.Annotations); err != nil {
+		return err
+	}
+	var checkFields func(fields []*Field, container string) error
+	checkFields = func(fields []*Field, container string) error {
+		for _, field := range fields {
+			path := joinPath(container, field.ID)
+			if err := check("field "+path, field.Annotations); err != nil {
+				return err
+			}
+			if err := checkFields(field.Nested, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return checkFields(fs.Fields, "")
+}
+
+// Annotate sets an annotation of the field, whose key has a vendor prefix, e.g. "sql/column"
+func (fb *FieldBuilder) Annotate(key string, value interface{}) *FieldBuilder {
+	if fb.field.Annotations == nil {
+		fb.field.Annotations = Annotations{}
+	}
+	fb.field.Annotations[key] = value
+	return fb
+}
+
+// Annotate sets an annotation of the form, whose key has a vendor prefix, e.g. "sql/table"
+func (fb *FormBuilder) Annotate(key string, value interface{}) *FormBuilder {
+	if fb.schema.Annotations == nil {
+		fb.schema.Annotations = Annotations{}
+	}
+	fb.schema.Annotations[key] = value
+	return fb
+}
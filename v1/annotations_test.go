@@ -0,0 +1,70 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	form := NewForm("customer", "Customer")
+	form.Annotate("sql/table", "customers")
+	form.TextField("name", "Name").
+		Annotate("sql/column", "full_name").
+		Annotate("sql/length", 120).
+		Annotate("acme.com/crm-field", map[string]interface{}{"object": "Contact", "field": "Name"})
+	address := form.GroupField("address", "Address")
+	address.TextField("city", "City").Annotate("sql/column", "city")
+	form.TextField("notes", "Notes")
+	schema := form.Build()
+	require.NoError(t, schema.CheckAnnotations())
+
+	// Annotations survive serialization and import
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	imported, err := NewJSONImporter().ImportJSON(string(data))
+	require.NoError(t, err)
+	table, _ := imported.Annotations.String("sql/table")
+	assert.Equal(t, "customers", table)
+
+	name := imported.FindFieldByID("name").Annotations
+	length, ok := name.Int("sql/length")
+	assert.True(t, ok)
+	assert.Equal(t, 120, length)
+	_, ok = name.Bool("sql/length")
+	assert.False(t, ok)
+	var crm struct{ Object, Field string }
+	require.NoError(t, name.Decode("acme.com/crm-field", &crm))
+	assert.Equal(t, "Contact", crm.Object)
+	assert.Error(t, name.Decode("acme.com/missing", &crm))
+
+	// Generators read their own annotations by field path
+	assert.Equal(t, map[string]Annotations{
+		"name":         {"column": "full_name", "length": float64(120)},
+		"address.city": {"column": "city"},
+	}, imported.FieldAnnotations("sql"))
+
+	// Clones do not share annotations
+	clone := schema.Clone()
+	clone.Fields[0].Annotations["sql/column"] = "name"
+	column, _ := schema.Fields[0].Annotations.String("sql/column")
+	assert.Equal(t, "full_name", column)
+
+	// Rendered forms leave them out, and keys need a vendor prefix
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(schema))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/customer", nil))
+	assert.NotContains(t, rec.Body.String(), "full_name")
+
+	form.TextField("email", "Email").Annotate("column", "email")
+	err = handler.RegisterSchema(form.Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `annotation "column" of field email`)
+}
@@ -0,0 +1,271 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Codes of the errors returned by the API, so that clients can tell errors apart without
+// parsing their messages
+const (
+	ErrorCodeBadRequest       = "bad_request"        // The request is missing something or is malformed
+	ErrorCodeInvalidRequest   = "invalid_request"    // The request body could not be decoded
+	ErrorCodeValidationFailed = "validation_failed"  // Submitted values failed validation
+	ErrorCodeMethodNotAllowed = "method_not_allowed" // The endpoint does not accept the method
+	ErrorCodeNotFound         = "not_found"          // The resource does not exist
+	ErrorCodeFormNotFound     = "form_not_found"     // No form is registered with the ID
+	ErrorCodeFieldNotFound    = "field_not_found"    // The form has no field at the path
+	ErrorCodeUnauthorized     = "unauthorized"       // The client could not be authenticated
+	ErrorCodeForbidden        = "forbidden"          // The client may not perform the operation
+	ErrorCodeConflict         = "conflict"           // The request conflicts with earlier ones
+	ErrorCodeGone             = "gone"               // The resource existed but has expired
+	ErrorCodePayloadTooLarge  = "payload_too_large"  // The request body is too large
+	ErrorCodeUnprocessable    = "unprocessable"      // The request body is well-formed but exceeds the decoding limits
+	ErrorCodeRateLimited      = "rate_limited"       // The client sent too many requests
+	ErrorCodeNotConfigured    = "not_configured"     // The feature is not set up on the server
+	ErrorCodeUpstreamFailed   = "upstream_failed"    // A service the server relies on failed
+	ErrorCodeUnavailable      = "unavailable"        // The server cannot handle the request right now
+	ErrorCodeInternal         = "internal_error"     // Anything else that went wrong on the server
+)
+
+var (
+	// ErrMethodNotAllowed is returned for requests with a method the endpoint does not accept
+	ErrMethodNotAllowed = errors.New("method not allowed")
+	// ErrValidationFailed is returned when submitted values fail validation
+	ErrValidationFailed = errors.New("validation failed")
+)
+
+// APIError is the body of every error response of the API:
+//
+//	{"code": "field_not_found", "message": "Field not found", "field": "items[0].name"}
+//
+// Code is one of the ErrorCode constants, Field is the path of the field the error is about,
+// if any, and Details holds further information specific to the error, such as the errors of
+// a failed validation.
+type APIError struct {
+	Status  int         `json:"-"` // HTTP status of the response
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Field   string      `json:"field,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+	Err     error       `json:"-"` // Error the API error was made from, for errors.Is and errors.As
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error the API error was made from
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// NewAPIError creates an API error with a status and code, for handlers and submission
+// pipelines that want to fail requests with errors of their own
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// errorStatuses are the statuses and codes of the errors of the Go API
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrFormNotFound, http.StatusNotFound, ErrorCodeFormNotFound},
+	{ErrFieldNotFound, http.StatusNotFound, ErrorCodeFieldNotFound},
+	{ErrValidationFailed, http.StatusBadRequest, ErrorCodeValidationFailed},
+	{ErrMethodNotAllowed, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed},
+	{ErrFormSessionNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrSubmissionNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrSubmissionJobNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrConfirmationNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrAPIKeyNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrTokenNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrFileNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrTenantNotFound, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrAPIKeyInvalid, http.StatusUnauthorized, ErrorCodeUnauthorized},
+	{ErrConfirmationInvalid, http.StatusForbidden, ErrorCodeForbidden},
+	{ErrAutosaveDisabled, http.StatusForbidden, ErrorCodeForbidden},
+	{ErrCSRFTokenInvalid, http.StatusForbidden, ErrorCodeForbidden},
+	{ErrCrossSiteRequest, http.StatusForbidden, ErrorCodeForbidden},
+	{ErrResumeLinkInvalid, http.StatusNotFound, ErrorCodeNotFound},
+	{ErrResumeLinkExpired, http.StatusGone, ErrorCodeGone},
+	{ErrSubmissionQueueFull, http.StatusServiceUnavailable, ErrorCodeUnavailable},
+	{ErrTooManyConfirmations, http.StatusServiceUnavailable, ErrorCodeUnavailable},
+	{ErrOptionsUnavailable, http.StatusServiceUnavailable, ErrorCodeUnavailable},
+	{ErrUnsupportedAuthType, http.StatusBadRequest, ErrorCodeBadRequest},
+	{ErrIdempotencyTokenRequired, http.StatusBadRequest, ErrorCodeBadRequest},
+	{ErrFunctionForbidden, http.StatusForbidden, ErrorCodeForbidden},
+	{ErrFunctionTimeout, http.StatusGatewayTimeout, ErrorCodeUnavailable},
+	{ErrInvalidCursor, http.StatusBadRequest, ErrorCodeBadRequest},
+	{ErrNoSchemaSource, http.StatusNotImplemented, ErrorCodeNotConfigured},
+	{ErrSchemaSourceFailed, http.StatusBadGateway, ErrorCodeUpstreamFailed},
+	{ErrInvalidSchema, http.StatusUnprocessableEntity, ErrorCodeUnprocessable},
+}
+
+// AsAPIError describes an error as the API reports it. API errors are returned as they are,
+// request bodies that could not be decoded are bad requests, errors of the Go API such as
+// ErrFormNotFound get their status and code, submission rejections keep their status, and any
+// other error is an internal error.
+func AsAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		described := *apiErr
+		if described.Status == 0 {
+			described.Status = http.StatusInternalServerError
+		}
+		if described.Code == "" {
+			described.Code = errorCodeForStatus(described.Status)
+		}
+		return &described
+	}
+
+	apiErr = &APIError{Status: http.StatusInternalServerError, Code: ErrorCodeInternal, Message: err.Error(), Err: err}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		apiErr.Status = requestErr.statusCode()
+		apiErr.Code = requestErr.Code
+		apiErr.Message = requestErr.Message
+		apiErr.Field = requestErr.Field
+		apiErr.Details = requestErr.Diagnostics
+		return apiErr
+	}
+	var rejection *SubmissionRejection
+	if errors.As(err, &rejection) && rejection.Status != 0 {
+		apiErr.Status = rejection.Status
+		apiErr.Code = errorCodeForStatus(rejection.Status)
+		return apiErr
+	}
+	for _, known := range errorStatuses {
+		if errors.Is(err, known.err) {
+			apiErr.Status = known.status
+			apiErr.Code = known.code
+			if err == known.err {
+				apiErr.Message = capitalize(err.Error())
+			}
+			break
+		}
+	}
+	return apiErr
+}
+
+// errorCodeForStatus returns the code of errors with a status that have no code of their own
+func errorCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrorCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrorCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrorCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusGone:
+		return ErrorCodeGone
+	case http.StatusRequestEntityTooLarge:
+		return ErrorCodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return ErrorCodeUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrorCodeRateLimited
+	case http.StatusNotImplemented:
+		return ErrorCodeNotConfigured
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return ErrorCodeUpstreamFailed
+	case http.StatusServiceUnavailable:
+		return ErrorCodeUnavailable
+	}
+	if status >= 400 && status < 500 {
+		return ErrorCodeBadRequest
+	}
+	return ErrorCodeInternal
+}
+
+// capitalize upper-cases the first letter of the message of an error of the Go API, which
+// clients show as it is
+func capitalize(message string) string {
+	r, size := utf8.DecodeRuneInString(message)
+	if r == utf8.RuneError {
+		return message
+	}
+	return string(unicode.ToUpper(r)) + message[size:]
+}
+
+// statusError creates an error with a status for an error of the Go API, keeping its message
+func statusError(status int, err error) *APIError {
+	return &APIError{Status: status, Code: errorCodeForStatus(status), Message: err.Error(), Err: err}
+}
+
+// badRequest creates an error for a request that is missing something or is malformed
+func badRequest(message string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: ErrorCodeBadRequest, Message: message}
+}
+
+// fieldNotFound creates an error for a path that names no field of the form
+func fieldNotFound(path string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: ErrorCodeFieldNotFound, Message: "Field not found", Field: path, Err: ErrFieldNotFound}
+}
+
+// notConfigured creates an error for a feature that is not set up on the server
+func notConfigured(status int, message string) *APIError {
+	return &APIError{Status: status, Code: ErrorCodeNotConfigured, Message: message}
+}
+
+// writeError writes an error response with the JSON body of the error as AsAPIError
+// describes it
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := AsAPIError(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(apiErr)
+}
+
+// Err returns nil when the values are valid, or else an API error wrapping
+// ErrValidationFailed, whose field is the path of the first invalid field and whose details
+// are the errors of every field
+func (vr *ValidationResult) Err() error {
+	if len(vr.Errors) == 0 {
+		return nil
+	}
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    ErrorCodeValidationFailed,
+		Message: "Validation failed",
+		Field:   vr.Errors[0].FieldID,
+		Details: vr.Errors,
+		Err:     ErrValidationFailed,
+	}
+}
+
+// validationFailure is the body of submit responses when values fail validation: an error
+// together with the validation result, whose errors name the full path of each field, such as
+// "items[0].name"
+type validationFailure struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	*ValidationResult
+}
+
+// writeValidationFailure writes the response to a submission whose values failed validation
+func writeValidationFailure(w http.ResponseWriter, result *ValidationResult) {
+	failure := &validationFailure{Code: ErrorCodeValidationFailed, Message: "Validation failed", ValidationResult: result}
+	if len(result.Errors) > 0 {
+		failure.Field = result.Errors[0].FieldID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(failure)
+}
@@ -0,0 +1,103 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponses(t *testing.T) {
+	form := NewForm("order", "Order")
+	form.TextField("customer", "Customer").Required(true)
+	items := form.ArrayField("items", "Items")
+	items.TextField("name", "Name").Required(true).ValidateMinLength(3, "Name is too short")
+	address := form.GroupField("address", "Address")
+	address.TextField("city", "City").Required(true)
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"), path)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		return rec.Code, response
+	}
+
+	code, response := do(http.MethodDelete, "/api/forms/order", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	assert.Equal(t, map[string]interface{}{"code": ErrorCodeMethodNotAllowed, "message": "Method not allowed"}, response)
+
+	code, response = do(http.MethodGet, "/api/forms/missing", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, map[string]interface{}{"code": ErrorCodeFormNotFound, "message": "Form not found"}, response)
+
+	code, response = do(http.MethodGet, "/api/options/order/missing", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, ErrorCodeFieldNotFound, response["code"])
+	assert.Equal(t, "missing", response["field"])
+
+	code, response = do(http.MethodPost, "/api/submit/order", `{"customer": 1`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeInvalidRequest, response["code"])
+	assert.NotEmpty(t, response["details"])
+
+	// Failed validations name the full path of fields inside groups and array items
+	code, response = do(http.MethodPost, "/api/submit/order", `{
+		"customer": "Ada",
+		"items": [{"name": "ab"}, {}, {"name": "Lamp"}],
+		"address": {"city": "Lagos"}
+	}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, ErrorCodeValidationFailed, response["code"])
+	assert.Equal(t, "items[0].name", response["field"])
+	assert.Equal(t, false, response["valid"])
+	paths := []string{}
+	for _, err := range response["errors"].([]interface{}) {
+		paths = append(paths, err.(map[string]interface{})["fieldId"].(string))
+	}
+	assert.Equal(t, []string{"items[0].name", "items[1].name"}, paths)
+
+	code, response = do(http.MethodPost, "/api/submit/order", `{"customer": "Ada", "items": [{"name": "Lamp"}], "address": {"city": ""}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "address.city", response["field"])
+}
+
+func TestAsAPIError(t *testing.T) {
+	apiErr := AsAPIError(fmt.Errorf("loading options: %w", ErrFormNotFound))
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, ErrorCodeFormNotFound, apiErr.Code)
+	assert.Equal(t, "loading options: form not found", apiErr.Message)
+	assert.True(t, errors.Is(apiErr, ErrFormNotFound))
+
+	apiErr = AsAPIError(&SubmissionRejection{Status: http.StatusTooManyRequests, Message: "Try again later"})
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.Status)
+	assert.Equal(t, ErrorCodeRateLimited, apiErr.Code)
+
+	apiErr = AsAPIError(errors.New("disk full"))
+	assert.Equal(t, http.StatusInternalServerError, apiErr.Status)
+	assert.Equal(t, ErrorCodeInternal, apiErr.Code)
+
+	custom := NewAPIError(http.StatusPaymentRequired, "plan_limit", "Upgrade to submit more forms")
+	assert.Equal(t, custom, AsAPIError(fmt.Errorf("submit: %w", custom)))
+
+	result := &ValidationResult{Valid: true}
+	assert.NoError(t, result.Err())
+	result = &ValidationResult{Errors: []*ValidationError{{FieldID: "items[2].price", Message: "Price is required", RuleType: "required"}}}
+	err := result.Err()
+	assert.True(t, errors.Is(err, ErrValidationFailed))
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "items[2].price", apiErr.Field)
+	assert.Equal(t, result.Errors, apiErr.Details)
+}
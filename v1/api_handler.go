@@ -1,11 +1,16 @@
 package smartform
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/juicycleff/smartform/v1/template"
 )
 
 // APIHandler handles HTTP requests for Autoform
@@ -14,6 +19,65 @@ type APIHandler struct {
 	optionService          *OptionService
 	authService            *AuthService
 	dynamicFunctionService *DynamicFunctionService
+	fragmentLoader         *RemoteFragmentLoader
+	baseSchemas            map[string]*FormSchema // Schemas as registered, before fragments are merged
+	versions               map[string]map[string]*FormSchema
+	migrations             map[string][]*schemaMigration
+	fingerprints           map[string]string
+	tamperPolicy           TamperPolicy
+	parentResolver         ParentRecordResolver
+	environment            string
+	probes                 map[string]Probe
+	mediaResolver          MediaResolver
+	submissionStore        SubmissionStore
+	submissionHandlers     map[string]map[SubmissionStage][]SubmissionHandler
+	submissionQueue        *submissionQueue
+	submissionJobListeners []SubmissionJobListener
+	formSessions           FormSessionStore
+	formSessionTTL         time.Duration
+	resumeLinkKey          []byte
+	geocoders              map[string]*registeredGeocoder
+	defaultGeocoder        string
+	signatureKey           []byte
+	uniqueHashKey          []byte
+	staffAuthorizer        StaffAuthorizer
+	apiKeys                APIKeyStore
+	verifiers              *Verifiers
+	remoteValidators       map[string]RemoteValidator
+	fileStorage            FileStorage
+	maxUploadSize          int64
+	failureLogger          *ValidationFailureLogger
+	identityResolver       IdentityResolver
+	accessResolver         AccessResolver
+	fieldEncryptor         FieldEncryptor
+	auditLogger            AuditLogger
+	actorResolver          ActorResolver
+	telemetry              Telemetry
+	cors                   *CORSConfig
+	csrf                   *CSRFConfig
+	requestLimits          *RequestLimits
+	renderContexts         []RenderContextFunc
+	flagProvider           FeatureFlagProvider
+	openAPIInfo            OpenAPIInfo
+	previewLimiter         *rateLimiter
+	liveChangeLimit        RateLimit
+	clock                  Clock
+	sampleGenerator        SampleGenerator
+	templateEvaluator      TemplateEvaluator
+	templateLimits         *template.Limits
+	lintRules              []*LintRule
+	notifier               Notifier
+	confirmations          *ConfirmationStore
+	compressionEnabled     bool
+	compressionMinSize     int
+	tenants                tenantRegistry
+	tenantID               string
+	tenantRoutes           http.Handler
+	tenantRoutesOnce       sync.Once
+	batchWorkers           int
+	schemaSource           SchemaSource
+	sourcedSchemas         map[string]map[string]bool // Versions of the forms loaded from the schema source
+	reloadLock             sync.Mutex                 // Serializes schema reloads
 	schemasLock            sync.RWMutex
 }
 
@@ -25,6 +89,14 @@ func getPathParam(path, prefix string) string {
 	return path[len(prefix):]
 }
 
+// requestSessionID returns the session ID from the request body, or else the session header
+func requestSessionID(r *http.Request, bodySessionID string) string {
+	if bodySessionID != "" {
+		return bodySessionID
+	}
+	return r.Header.Get(SessionHeader)
+}
+
 func getPathSegment(path string, index int) string {
 	segments := splitPath(path)
 	if index < 0 || index >= len(segments) {
@@ -72,113 +144,482 @@ func splitAndClean(s string, sep byte) []string {
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler() *APIHandler {
-	return &APIHandler{
-		schemas:       make(map[string]*FormSchema),
-		optionService: NewOptionService(5 * time.Minute),
-		authService:   NewAuthService(),
-		schemasLock:   sync.RWMutex{},
-	}
+func NewAPIHandler(options ...HandlerOption) *APIHandler {
+	handler := &APIHandler{
+		schemas:         make(map[string]*FormSchema),
+		baseSchemas:     make(map[string]*FormSchema),
+		versions:        make(map[string]map[string]*FormSchema),
+		migrations:      make(map[string][]*schemaMigration),
+		fingerprints:    make(map[string]string),
+		probes:          make(map[string]Probe),
+		confirmations:   NewConfirmationStore(),
+		formSessions:    NewMemoryFormSessionStore(),
+		optionService:   NewOptionService(5 * time.Minute),
+		authService:     NewAuthService(),
+		previewLimiter:  newRateLimiter(DefaultValidationPreviewLimit),
+		liveChangeLimit: DefaultLiveChangeLimit,
+		schemasLock:     sync.RWMutex{},
+	}
+	handler.optionService.SetAuthService(handler.authService)
+	for _, option := range options {
+		option(handler)
+	}
+	return handler
+}
+
+// RegisterSchema registers a form schema. Several versions of a form may be registered;
+// the newest one is served by default. Registration fails if the version is not a semantic
+// version, if option values do not match their declared type, or if any example submission
+// attached to the schema no longer produces its expected outcome.
+func (ah *APIHandler) RegisterSchema(schema *FormSchema) error {
+	start := time.Now()
+	err := ah.registerSchema(schema)
+	ah.audit(nil, start, &AuditEvent{
+		Type:    AuditSchemaRegistered,
+		FormID:  schema.ID,
+		Version: schema.Version,
+		Error:   auditError(err),
+	})
+	return err
 }
 
-// RegisterSchema registers a form schema
-func (ah *APIHandler) RegisterSchema(schema *FormSchema) {
+// registerSchema checks and registers a form schema
+func (ah *APIHandler) registerSchema(schema *FormSchema) error {
+	prepared, err := ah.prepareSchema(schema)
+	if err != nil {
+		return err
+	}
+
 	ah.schemasLock.Lock()
 	defer ah.schemasLock.Unlock()
-	ah.schemas[schema.ID] = schema
+	ah.storeSchema(prepared)
+	return nil
+}
+
+// preparedSchema is a schema checked for registration
+type preparedSchema struct {
+	base        *FormSchema // Schema as registered, before fragments are merged
+	merged      *FormSchema
+	fingerprint string
+}
+
+// prepareSchema checks a form schema and prepares it for registration
+func (ah *APIHandler) prepareSchema(schema *FormSchema) (*preparedSchema, error) {
+	compiled := schema.compiled != nil
+	schema, err := schema.ApplyEnvironment(ah.environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema.Version == "" {
+		schema = schema.Clone()
+		schema.Version = DefaultSchemaVersion
+	}
+	if schema.templateEvaluator == nil && ah.templateEvaluator != nil {
+		schema = schema.Clone()
+		schema.templateEvaluator = ah.templateEvaluator
+	}
+	if schema.templateEvaluator == nil && schema.templateLimits == nil && ah.templateLimits != nil {
+		schema = schema.Clone()
+		schema.templateLimits = ah.templateLimits
+	}
+	if ah.telemetry != nil {
+		schema = schema.Clone()
+		schema.telemetry = ah.telemetry
+	}
+	if _, n, err := parseSchemaVersion(schema.Version); err != nil || n != 3 {
+		return nil, fmt.Errorf("form %s has an invalid semantic version: %q", schema.ID, schema.Version)
+	}
+
+	merged, err := ah.mergeFragments(schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMergedSchema(merged, compiled); err != nil {
+		return nil, err
+	}
+	fingerprint, _ := merged.Fingerprint()
+	return &preparedSchema{base: schema, merged: merged, fingerprint: fingerprint}, nil
+}
+
+// checkMergedSchema makes the checks of registration on a schema with its fragments merged,
+// compiling it again when it was registered compiled
+func checkMergedSchema(merged *FormSchema, compiled bool) error {
+	if err := merged.CheckFragments(); err != nil {
+		return err
+	}
+	if err := merged.CheckOptionTypes(); err != nil {
+		return err
+	}
+	if err := merged.CheckExamples(); err != nil {
+		return err
+	}
+	if err := merged.CheckComputedValues(); err != nil {
+		return err
+	}
+	if err := merged.CheckDependencies(); err != nil {
+		return err
+	}
+	if err := merged.CheckUniqueConstraints(); err != nil {
+		return err
+	}
+	if err := merged.CheckAnnotations(); err != nil {
+		return err
+	}
+	if err := merged.CheckPrintLayout(); err != nil {
+		return err
+	}
+	// Schemas registered compiled stay compiled, whatever copies were made of them
+	if compiled && (merged.compiled == nil || !merged.compiled.current()) {
+		if _, err := merged.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeSchema registers a prepared schema. The caller holds the schemas lock.
+func (ah *APIHandler) storeSchema(prepared *preparedSchema) {
+	schema := prepared.base
+	if ah.versions[schema.ID] == nil {
+		ah.versions[schema.ID] = make(map[string]*FormSchema)
+	}
+	ah.versions[schema.ID][schema.Version] = prepared.merged
+
+	// Older versions stay available without replacing the current one
+	if current, ok := ah.schemas[schema.ID]; ok && compareSchemaVersions(schema.Version, current.Version) < 0 {
+		return
+	}
+	ah.baseSchemas[schema.ID] = schema
+	ah.schemas[schema.ID] = prepared.merged
+	ah.fingerprints[schema.ID] = prepared.fingerprint
 }
 
 // GetSchema gets a schema by ID
 func (ah *APIHandler) GetSchema(id string) (*FormSchema, bool) {
 	ah.schemasLock.RLock()
-	defer ah.schemasLock.RUnlock()
 	schema, ok := ah.schemas[id]
+	base := ah.baseSchemas[id]
+	ah.schemasLock.RUnlock()
+
+	// Refresh remote fragments once their cache entries expire. Merges failing the checks of
+	// registration leave the last good merged schema served.
+	if ok && base != nil && ah.fragmentLoader != nil && len(base.RemoteFragments) > 0 &&
+		ah.fragmentLoader.Expired(base) {
+		merged, err := ah.mergeFragments(base)
+		if err == nil {
+			err = checkMergedSchema(merged, schema.compiled != nil)
+		}
+		if err == nil {
+			schema = merged
+			fingerprint, _ := schema.Fingerprint()
+
+			ah.schemasLock.Lock()
+			if ah.baseSchemas[id] == base {
+				ah.schemas[id] = schema
+				ah.fingerprints[id] = fingerprint
+			}
+			ah.schemasLock.Unlock()
+		}
+	}
+
 	return schema, ok
 }
 
+// GetFingerprint gets the fingerprint of the current version of a schema
+func (ah *APIHandler) GetFingerprint(id string) (string, bool) {
+	// Resolve through GetSchema so expired remote fragments are refreshed first
+	if _, ok := ah.GetSchema(id); !ok {
+		return "", false
+	}
+
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+	fingerprint, ok := ah.fingerprints[id]
+	return fingerprint, ok
+}
+
+// SetFragmentLoader sets the loader used to merge remote fragments into registered schemas
+func (ah *APIHandler) SetFragmentLoader(loader *RemoteFragmentLoader) {
+	ah.fragmentLoader = loader
+}
+
+// mergeFragments merges a schema's remote fragments. Fragments that cannot be fetched or
+// verified fail the merge, rather than serving the form without their fields.
+func (ah *APIHandler) mergeFragments(schema *FormSchema) (*FormSchema, error) {
+	if ah.fragmentLoader == nil || len(schema.RemoteFragments) == 0 {
+		return schema, nil
+	}
+	return ah.fragmentLoader.Apply(schema)
+}
+
 // SetDynamicFunctionService sets the dynamic function service
 func (ah *APIHandler) SetDynamicFunctionService(service *DynamicFunctionService) {
 	ah.dynamicFunctionService = service
+	if ah.telemetry != nil && service != nil {
+		service.SetTelemetry(ah.telemetry)
+	}
 }
 
-// SetupRoutes sets up HTTP routes for the API
-func (ah *APIHandler) SetupRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/api/forms", ah.handleForms)
-	mux.HandleFunc("/api/forms/", ah.handleForm)
-	mux.HandleFunc("/api/options/", ah.handleOptions)
-	mux.HandleFunc("/api/validate/", ah.handleValidate)
-	mux.HandleFunc("/api/submit/", ah.handleSubmit)
-	mux.HandleFunc("/api/auth/", ah.handleAuth)
+// SetTamperPolicy sets how values submitted for hidden or disabled fields are handled
+func (ah *APIHandler) SetTamperPolicy(policy TamperPolicy) {
+	ah.tamperPolicy = policy
+}
 
-	mux.HandleFunc("/api/function/", ah.handleDynamicFunction)
-	mux.HandleFunc("/api/field/dynamic/", ah.handleDynamicField)
-	mux.HandleFunc("/api/options/dynamic/", ah.handleDynamicOptions)
-	mux.HandleFunc("/api/options/function/", ah.handleFunctionOptions)
+// SetEnvironment sets the deployment environment whose schema overlays are applied at registration
+func (ah *APIHandler) SetEnvironment(environment string) {
+	ah.environment = environment
+}
 
+// SetVerifiers sets the services that verify email addresses, phone numbers, and postal addresses
+func (ah *APIHandler) SetVerifiers(verifiers *Verifiers) {
+	ah.verifiers = verifiers
 }
 
-// handleForms handles requests to list all forms
-func (ah *APIHandler) handleForms(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// SetNotifier sets the notifier used to send confirmation codes and links
+func (ah *APIHandler) SetNotifier(notifier Notifier) {
+	ah.notifier = notifier
+}
+
+// SetMediaResolver sets the function that resolves help media file IDs to URLs
+func (ah *APIHandler) SetMediaResolver(resolver MediaResolver) {
+	ah.mediaResolver = resolver
+}
+
+// SetParentRecordResolver sets the function used to load the parent records forms are launched from
+func (ah *APIHandler) SetParentRecordResolver(resolver ParentRecordResolver) {
+	ah.parentResolver = resolver
+}
+
+// EnableCompression compresses JSON responses of at least minSize bytes using brotli or gzip
+func (ah *APIHandler) EnableCompression(minSize int) {
+	ah.compressionEnabled = true
+	ah.compressionMinSize = minSize
+}
+
+// AddProbe registers a readiness probe for a connection, storage backend, or auth provider
+func (ah *APIHandler) AddProbe(kind, name string, probe Probe) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.probes[probeKey(kind, name)] = probe
+}
+
+// Doctor probes the dependencies of every registered schema, and the stores of the handler
+// that implement Pinger, and returns the readiness matrix
+func (ah *APIHandler) Doctor(ctx context.Context) *ReadinessReport {
+	doctor := NewDoctor().SetFunctionService(ah.dynamicFunctionService).SetAuthService(ah.authService)
+
+	stores := map[string]interface{}{
+		"submissions":  ah.submissionStore,
+		"formSessions": ah.formSessions,
+		"files":        ah.fileStorage,
+		"apiKeys":      ah.apiKeys,
+	}
+	for name, store := range stores {
+		if pinger, ok := store.(Pinger); ok {
+			doctor.probes[probeKey(ProbeKindStorage, name)] = pinger.Ping
+		}
 	}
 
 	ah.schemasLock.RLock()
-	defer ah.schemasLock.RUnlock()
-
-	// Build a list of form metadata
-	formsList := []map[string]string{}
 	for _, schema := range ah.schemas {
-		formsList = append(formsList, map[string]string{
-			"id":          schema.ID,
-			"title":       schema.Title,
-			"description": schema.Description,
-		})
+		doctor.AddSchema(schema)
 	}
+	for key, probe := range ah.probes {
+		doctor.probes[key] = probe
+	}
+	ah.schemasLock.RUnlock()
 
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(formsList)
-	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
+	return doctor.Run(ctx)
+}
+
+// SetupRoutes sets up HTTP routes for the API
+func (ah *APIHandler) SetupRoutes(mux *http.ServeMux) {
+	for _, route := range ah.Routes() {
+		mux.Handle(route.Path, route.Handler)
+	}
+}
+
+// Routes returns the endpoints of the API with the configured middleware applied, to be
+// mounted on any router. Handlers read their parameters from the request path, so routes must
+// be mounted at their own path, or below a prefix stripped with http.StripPrefix.
+func (ah *APIHandler) Routes() []Route {
+	return ah.instrumentRoutes(ah.limitRoutes([]Route{
+		{Path: "/api/forms", Handler: ah.wrap(ah.handleForms)},
+		{Path: "/api/forms/", Handler: ah.wrap(ah.handleForm)},
+		{Path: "/api/fingerprints", Handler: ah.wrap(ah.handleFingerprints)},
+		{Path: "/api/doctor", Handler: ah.wrap(ah.handleDoctor)},
+		{Path: "/api/admin/reload", Handler: ah.wrap(ah.handleSchemaReload)},
+		{Path: "/api/openapi.json", Handler: ah.wrap(ah.handleOpenAPISpec)},
+		{Path: "/api/metrics/options", Handler: ah.wrap(ah.handleOptionMetrics)},
+		{Path: "/api/options/", Handler: ah.wrapNegotiated(ah.handleOptions)},
+		{Path: "/api/options/cache", Handler: ah.wrap(ah.handleOptionsCache)},
+		{Path: "/api/options/cache/", Handler: ah.wrap(ah.handleOptionsCache)},
+		{Path: "/api/validate/", Handler: ah.wrapNegotiated(ah.handleValidate)},
+		{Path: "/api/submit/", Handler: ah.wrapNegotiated(ah.handleSubmit)},
+		{Path: "/api/upload/", Handler: ah.wrap(ah.handleUpload)},
+		{Path: "/api/compute/", Handler: ah.wrap(ah.handleCompute)},
+		{Path: "/api/print/", Handler: ah.wrap(ah.handlePrint)},
+		{Path: "/api/auth/", Handler: ah.wrap(ah.handleAuth)},
+		{Path: "/api/auth/tokens/", Handler: ah.wrap(ah.handleAuthTokens)},
+		{Path: "/api/csrf", Handler: ah.wrap(ah.handleCSRFToken)},
+		{Path: "/api/jobs/", Handler: ah.wrapNegotiated(ah.handleSubmissionJob)},
+		{Path: "/api/submissions", Handler: ah.wrap(ah.handleSubmissions)},
+		{Path: "/api/submissions/", Handler: ah.wrap(ah.handleSubmission)},
+		{Path: "/api/sessions/", Handler: ah.wrap(ah.handleFormSessions)},
+		{Path: "/api/resume/", Handler: ah.wrap(ah.handleResume)},
+		{Path: "/api/keys", Handler: ah.wrap(ah.handleAPIKeys)},
+		{Path: "/api/ws/", Handler: ah.apiKeyMiddleware(http.HandlerFunc(ah.handleLiveUpdates))},
+		{Path: "/api/keys/", Handler: ah.wrap(ah.handleAPIKeys)},
+		{Path: "/api/function/", Handler: ah.wrap(ah.handleDynamicFunction)},
+		{Path: "/api/field/dynamic/", Handler: ah.wrap(ah.handleDynamicField)},
+		{Path: "/api/field/validate/", Handler: ah.wrap(ah.handleValidationPreview)},
+		{Path: "/api/address/", Handler: ah.wrap(ah.handleAddress)},
+		{Path: "/api/options/dynamic/", Handler: ah.wrapNegotiated(ah.handleDynamicOptions)},
+		{Path: "/api/options/function/", Handler: ah.wrapNegotiated(ah.handleFunctionOptions)},
+	}))
+}
+
+// wrap applies the configured middleware to a route handler
+func (ah *APIHandler) wrap(handler http.HandlerFunc) http.Handler {
+	var wrapped http.Handler = handler
+	if ah.compressionEnabled {
+		wrapped = CompressionMiddleware(wrapped, ah.compressionMinSize)
 	}
+	return ah.corsMiddleware(ah.timestampMiddleware(ah.apiKeyMiddleware(ah.csrfMiddleware(wrapped))))
+}
+
+// wrapNegotiated applies the configured middleware to a route that also speaks msgpack and CBOR
+func (ah *APIHandler) wrapNegotiated(handler http.HandlerFunc) http.Handler {
+	return ah.wrap(ContentNegotiationMiddleware(handler).ServeHTTP)
 }
 
 // handleForm handles requests for a specific form
 func (ah *APIHandler) handleForm(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Extract form ID and optional sub-resource from path
+	segments := splitPath(getPathParam(r.URL.Path, "/api/forms/"))
+	if len(segments) == 0 {
+		writeError(w, badRequest("Form ID is required"))
 		return
 	}
+	formID := segments[0]
 
-	// Extract form ID from path
-	formID := getPathParam(r.URL.Path, "/api/forms/")
-	if formID == "" {
-		http.Error(w, "Form ID is required", http.StatusBadRequest)
+	if len(segments) > 1 {
+		ah.handleFormResource(w, r, formID, segments[1:])
 		return
 	}
 
-	// Get schema
-	schema, ok := ah.GetSchema(formID)
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	// Get schema, in the requested version if any
+	query := r.URL.Query()
+	schema, ok := ah.GetSchemaVersion(formID, query.Get(VersionQueryParam))
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
+	fingerprint, _ := ah.GetFingerprint(formID)
+	if query.Get(VersionQueryParam) != "" {
+		fingerprint, _ = schema.Fingerprint()
+	}
 
 	// Parse context from query parameters
 	context := map[string]interface{}{}
-	for key, values := range r.URL.Query() {
+	for key, values := range query {
+		switch key {
+		case FieldsQueryParam, ExcludeQueryParam, ParentTypeQueryParam, ParentIDQueryParam, VersionQueryParam, PreviewQueryParam, DiagnosticsQueryParam, LocaleQueryParam:
+			continue
+		}
 		if len(values) > 0 {
 			context[key] = values[0]
 		}
 	}
 
-	// Render schema with context
+	// Prefill fields bound to the parent record the form was launched from
+	if schema.Parent != nil {
+		parent, err := ParentContextFromRequest(query, nil)
+		if err == nil {
+			err = schema.ResolveParent(parent, ah.parentResolver)
+		}
+		if err != nil {
+			writeError(w, statusError(http.StatusBadRequest, err))
+			return
+		}
+		if parent != nil {
+			schema = schema.ApplyParentContext(parent)
+			context["parent"] = parent.Record
+		}
+	}
+
+	// Variables from middleware override the query parameters
+	context = ah.withRenderContext(r, context)
+
+	// Return only the requested part of the schema
+	include := ParseFieldList(query.Get(FieldsQueryParam))
+	exclude := ParseFieldList(query.Get(ExcludeQueryParam))
+	if len(include) > 0 || len(exclude) > 0 {
+		schema = schema.SelectFields(include, exclude)
+	}
+
+	// Point help media at the files in storage
+	if ah.mediaResolver != nil {
+		resolved, err := schema.ResolveHelpMedia(ah.mediaResolver)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		schema = resolved
+	}
+
+	// Designers preview dynamic fields with simulated data instead of calling real backends
+	preview, _ := strconv.ParseBool(query.Get(PreviewQueryParam))
+	if preview {
+		schema = schema.Preview(ah.sampleGenerator)
+		w.Header().Set(PreviewHeader, "true")
+	}
+
+	// Render schema with context, explaining where resolved values came from if asked to
 	renderer := NewFormRenderer(schema)
-	jsonString, err := renderer.RenderJSONWithContext(context)
+	renderer.SetBranchForms(ah.GetSchema)
+	renderer.SetAccess(ah.accessContext(r))
+	if len(schema.Translations) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+		if locale := requestLocale(query, r.Header.Get("Accept-Language"), schema); locale != "" {
+			renderer.SetLocale(locale)
+			w.Header().Set("Content-Language", locale)
+		}
+	}
+	render := renderer.RenderJSONWithContext
+	if diagnostics, _ := strconv.ParseBool(query.Get(DiagnosticsQueryParam)); diagnostics {
+		render = renderer.RenderJSONWithDiagnostics
+	}
+	start := time.Now()
+	_, span := startSpan(ah.telemetry, r.Context(), "smartform.render",
+		attribute("smartform.form", schema.ID),
+		attribute("smartform.version", schema.Version))
+	jsonString, err := render(context)
+	endSpan(span, err)
+	recordDuration(ah.telemetry, r.Context(), MetricRenderDuration, start, attribute("smartform.form", schema.ID))
+	ah.audit(r, start, &AuditEvent{
+		Type:    AuditFormRendered,
+		FormID:  schema.ID,
+		Version: schema.Version,
+		Error:   auditError(err),
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error rendering form: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error rendering form: %v", err)))
+		return
+	}
+
+	// The ETag covers the rendered output, which also depends on the query
+	etag := `"` + fingerprintBytes([]byte(jsonString)) + `"`
+	w.Header().Set(FingerprintHeader, fingerprint)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -186,10 +627,163 @@ func (ah *APIHandler) handleForm(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte(jsonString))
 }
 
+// handleFormResource dispatches requests for the sub-resources of a form
+func (ah *APIHandler) handleFormResource(w http.ResponseWriter, r *http.Request, formID string, resource []string) {
+	switch {
+	case len(resource) == 1 && resource[0] == "fingerprint":
+		ah.handleFormFingerprint(w, r, formID)
+	case len(resource) == 1 && resource[0] == "array-item":
+		ah.handleNewArrayItem(w, r, formID)
+	case len(resource) == 1 && resource[0] == "constraints":
+		ah.handleNumberConstraints(w, r, formID)
+	case len(resource) == 1 && resource[0] == "jsonschema":
+		ah.handleFormJSONSchema(w, r, formID)
+	case len(resource) == 1 && resource[0] == "summary":
+		ah.handleFormSummary(w, r, formID)
+	case len(resource) == 1 && resource[0] == "lint":
+		ah.handleFormLint(w, r, formID)
+	case len(resource) == 2 && resource[0] == "expressions" && resource[1] == "suggest":
+		ah.handleExpressionSuggestions(w, r, formID)
+	case len(resource) == 3 && resource[0] == "array" && resource[2] == "item-template":
+		ah.handleArrayItemTemplate(w, r, formID, resource[1])
+	default:
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+	}
+}
+
+// handleNewArrayItem handles requests for a new, fully defaulted array item
+func (ah *APIHandler) handleNewArrayItem(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var request struct {
+		Path  string                 `json:"path"`
+		State map[string]interface{} `json:"state"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	item, err := NewStateEngine(schema).NewArrayItem(request.Path, ah.withRenderContext(r, request.State))
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(item)
+}
+
+// handleNumberConstraints handles requests for the bounds and steps of number fields, resolved
+// against the form state
+func (ah *APIHandler) handleNumberConstraints(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var request struct {
+		State map[string]interface{} `json:"state"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(NewStateEngine(schema).NumberConstraints(ah.withRenderContext(r, request.State)))
+}
+
+// handleFormFingerprint handles requests for the fingerprint of a specific form
+func (ah *APIHandler) handleFormFingerprint(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	fingerprint, ok := ah.GetFingerprint(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(FingerprintHeader, fingerprint)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"id":          formID,
+		"fingerprint": fingerprint,
+	})
+}
+
+// handleFingerprints handles requests for the fingerprints of all forms
+func (ah *APIHandler) handleFingerprints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	ah.schemasLock.RLock()
+	ids := make([]string, 0, len(ah.schemas))
+	for id := range ah.schemas {
+		ids = append(ids, id)
+	}
+	ah.schemasLock.RUnlock()
+
+	fingerprints := make(map[string]string, len(ids))
+	for _, id := range ids {
+		if fingerprint, ok := ah.GetFingerprint(id); ok {
+			fingerprints[id] = fingerprint
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(fingerprints)
+}
+
+// handleDoctor handles staff requests for the readiness matrix. Running it probes every
+// dependency, and the report names internal endpoints, so it is not open to everyone.
+func (ah *APIHandler) handleDoctor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	report := ah.Doctor(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
 // New handler for function-based options
 func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
@@ -197,7 +791,7 @@ func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Reque
 	path := r.URL.Path
 	parts := splitPath(path)
 	if len(parts) < 4 {
-		http.Error(w, "Form ID and function name are required", http.StatusBadRequest)
+		writeError(w, badRequest("Form ID and function name are required"))
 		return
 	}
 
@@ -207,7 +801,7 @@ func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Reque
 	// Get schema
 	_, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
@@ -215,16 +809,17 @@ func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Reque
 	var request struct {
 		Parameters map[string]interface{} `json:"parameters"`
 		FormState  map[string]interface{} `json:"formState"`
+		SessionID  string                 `json:"sessionId"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
 	// Check if dynamic function service is configured
 	if ah.dynamicFunctionService == nil {
-		http.Error(w, "Dynamic function service not configured", http.StatusInternalServerError)
+		writeError(w, notConfigured(http.StatusInternalServerError, "Dynamic function service not configured"))
 		return
 	}
 
@@ -232,11 +827,11 @@ func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Reque
 	options, err := ah.dynamicFunctionService.ExecuteFunctionForOptions(
 		functionName,
 		request.Parameters,
-		request.FormState,
+		ah.functionState(r, request.SessionID, request.FormState),
 	)
 
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error executing function: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing function: %v", err)))
 		return
 	}
 
@@ -244,45 +839,55 @@ func (ah *APIHandler) handleFunctionOptions(w http.ResponseWriter, r *http.Reque
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(options)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeError(w, badRequest("Bad request"))
 		return
 	}
 }
 
 // handleOptions handles requests for field options
 func (ah *APIHandler) handleOptions(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Extract form ID and field ID from path
 	path := r.URL.Path
-	formID := getPathSegment(path, 3) // /api/options/{formID}/{fieldID}
-	fieldID := getPathSegment(path, 4)
+	formID := getPathSegment(path, 2) // /api/options/{formID}/{fieldID}
+	fieldID := getPathSegment(path, 3)
+	resolve := getPathSegment(path, 4) == "resolve"
+
+	if r.Method != http.MethodGet && !resolve {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
 
 	if formID == "" || fieldID == "" {
-		http.Error(w, "Form ID and Field ID are required", http.StatusBadRequest)
+		writeError(w, badRequest("Form ID and Field ID are required"))
 		return
 	}
 
 	// Get schema
 	schema, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
 	// Find field
 	field := schema.FindFieldByID(fieldID)
 	if field == nil {
-		http.Error(w, "Field not found", http.StatusNotFound)
+		writeError(w, fieldNotFound(fieldID))
 		return
 	}
 
 	// Check if field has options
 	if field.Options == nil {
-		http.Error(w, "Field does not support options", http.StatusBadRequest)
+		writeError(w, badRequest("Field does not support options"))
+		return
+	}
+
+	if resolve {
+		ah.handleResolveOptions(w, r, field)
+		return
+	}
+	if isWindowed(field) {
+		ah.handleOptionWindow(w, r, field)
 		return
 	}
 
@@ -293,42 +898,53 @@ func (ah *APIHandler) handleOptions(w http.ResponseWriter, r *http.Request) {
 			context[key] = values[0]
 		}
 	}
+	context = ah.withRenderContext(r, context)
+
+	options, err := ah.fieldOptions(r, schema, field, context)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		writeOptionsError(w, "Error fetching dynamic options", err)
+		return
+	}
 
-	// Get options based on type
-	var options []*Option
-	var err error
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(options)
+	if err != nil {
+		writeError(w, badRequest("Bad request"))
+		return
+	}
+}
 
+// fieldOptions returns the options of a field for a context. Fields configured wrongly fail
+// with an API error, and dynamic sources with the error of the fetch.
+func (ah *APIHandler) fieldOptions(r *http.Request, schema *FormSchema, field *Field, context map[string]interface{}) ([]*Option, error) {
 	switch field.Options.Type {
 	case OptionsTypeStatic:
-		options = field.Options.Static
+		return field.Options.Static, nil
 
 	case OptionsTypeDynamic:
 		if field.Options.DynamicSource == nil {
-			http.Error(w, "Dynamic source not configured", http.StatusInternalServerError)
-			return
+			return nil, notConfigured(http.StatusInternalServerError, "Dynamic source not configured")
 		}
 
 		// Check if it's a function type
 		if field.Options.DynamicSource.Type == "function" {
-			options, err = ah.getOptionsFromFunction(
+			return ah.getOptionsFromFunction(
 				field.Options.DynamicSource.FunctionName,
 				field.Options.DynamicSource.Parameters,
 				context,
 			)
-		} else {
-			// Default to API type
-			options, err = ah.optionService.GetDynamicOptions(field.Options.DynamicSource, context)
-		}
-
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Error fetching dynamic options: %v", err), http.StatusInternalServerError)
-			return
 		}
+		// Default to API type
+		return ah.optionService.GetDynamicOptionsFor(ah.requestIdentity(r), field.Options.DynamicSource, context)
 
 	case OptionsTypeDependent:
 		if field.Options.Dependency == nil {
-			http.Error(w, "Dependency not configured", http.StatusInternalServerError)
-			return
+			return nil, notConfigured(http.StatusInternalServerError, "Dependency not configured")
 		}
 
 		// Get dependent field value
@@ -340,214 +956,489 @@ func (ah *APIHandler) handleOptions(w http.ResponseWriter, r *http.Request) {
 
 		// Get options for this value
 		if dependentOptions, ok := field.Options.Dependency.ValueMap[dependentValue]; ok {
-			options = dependentOptions
-		} else {
-			// Return empty options if no mapping exists
-			options = []*Option{}
+			return dependentOptions, nil
 		}
-	}
+		// Return empty options if no mapping exists
+		return []*Option{}, nil
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(options)
-	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
+	case OptionsTypeConditional:
+		return schema.ConditionalOptions(field, typedQueryState(schema, context)), nil
 	}
+	return nil, nil
 }
 
 // handleValidate handles form validation requests
 func (ah *APIHandler) handleValidate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
-	// Extract form ID from path
-	formID := getPathParam(r.URL.Path, "/api/validate/")
-	if formID == "" {
-		http.Error(w, "Form ID is required", http.StatusBadRequest)
+	// Extract form ID, and the field to check on its own if any, from path
+	segments := splitPath(getPathParam(r.URL.Path, "/api/validate/"))
+	if len(segments) == 0 {
+		writeError(w, badRequest("Form ID is required"))
 		return
 	}
+	formID := segments[0]
+	fieldID := ""
+	if len(segments) > 1 {
+		if len(segments) != 3 || segments[1] != "field" {
+			writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+			return
+		}
+		fieldID = segments[2]
+	}
 
 	// Get schema
 	schema, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
 	// Parse request body
 	var formData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &formData); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	options, err := ParseValidationOptions(r.URL.Query(), formData)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
 		return
 	}
 
-	// Validate form
+	// Data entered against an older version is upgraded before validation
+	formData, err = ah.migrateSubmission(schema, r.URL.Query(), formData)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+	options.TamperPolicy = ah.tamperPolicy
+	options.Access = ah.accessContext(r)
+	options.OptionResolver = ah.optionResolver(r)
+	options.Verifiers = ah.verifiers
+	options.RemoteValidators = ah.getRemoteValidators()
+	options.FileStorage = ah.fileStorage
+	options.FailureLogger = ah.failureLogger
+	options.Context = r.Context()
+	options.Variables = ah.renderContext(r)
+
+	// Validate form, or only the requested field
+	start := time.Now()
 	validator := NewValidator(schema)
-	result := validator.ValidateForm(formData)
+	var result *ValidationResult
+	if fieldID != "" {
+		result, ok = validator.ValidateFieldWithOptions(fieldID, formData, options)
+		if !ok {
+			writeError(w, fieldNotFound(fieldID))
+			return
+		}
+	} else {
+		result = validator.ValidateFormWithOptions(formData, options)
+	}
+
+	// Values of unique constraints taken by earlier submissions are reported before submitting
+	if err := ah.checkUnique(schema, formData, fieldID, result); err != nil {
+		writeError(w, err)
+		return
+	}
+	ah.recordValidationFailure(r, start, schema, result, "validate")
+
+	// Messages are sent in the caller's language when the form is translated
+	if len(schema.Translations) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+		if locale := requestLocale(r.URL.Query(), r.Header.Get("Accept-Language"), schema); locale != "" {
+			schema.TranslateErrors(result, locale)
+			w.Header().Set("Content-Language", locale)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(result)
+	err = json.NewEncoder(w).Encode(result)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeError(w, badRequest("Bad request"))
 		return
 	}
 }
 
+// optionResolver returns an OptionResolver fetching options on behalf of the caller of a request
+func (ah *APIHandler) optionResolver(r *http.Request) OptionResolver {
+	identity := ah.requestIdentity(r)
+	return func(field *Field, data map[string]interface{}) ([]*Option, error) {
+		return ah.resolveFieldOptions(identity, field, data)
+	}
+}
+
+// resolveFieldOptions re-fetches the dynamic options of a field, from its API or function
+func (ah *APIHandler) resolveFieldOptions(identity CacheIdentity, field *Field, data map[string]interface{}) ([]*Option, error) {
+	if field.Options == nil || field.Options.DynamicSource == nil {
+		return nil, fmt.Errorf("field %s has no dynamic options", field.ID)
+	}
+
+	// Windowed sources are too large to load; only the submitted values are resolved
+	if isWindowed(field) {
+		value, ok := data[field.ID]
+		if !ok {
+			return nil, fmt.Errorf("no value submitted for field %s", field.ID)
+		}
+		values, isList := value.([]interface{})
+		if !isList {
+			values = []interface{}{value}
+		}
+		return ah.resolveOptionValues(context.Background(), identity, field, values, data)
+	}
+
+	if source := field.Options.DynamicSource; source.Type == "function" {
+		return ah.getOptionsFromFunction(source.FunctionName, source.Parameters, data)
+	}
+	return ah.optionService.GetDynamicOptionsFor(identity, field.Options.DynamicSource, data)
+}
+
 // handleSubmit handles form submission
 func (ah *APIHandler) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	if segments := splitPath(getPathParam(r.URL.Path, "/api/submit/")); len(segments) == 2 && segments[1] == "confirm" {
+		ah.handleConfirmSubmission(w, r, segments[0])
+		return
+	}
+
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
 	// Extract form ID from path
 	formID := getPathParam(r.URL.Path, "/api/submit/")
 	if formID == "" {
-		http.Error(w, "Form ID is required", http.StatusBadRequest)
+		writeError(w, badRequest("Form ID is required"))
 		return
 	}
 
 	// Get schema
 	schema, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
 	// Parse request body
 	var formData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &formData); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	options, err := ParseValidationOptions(r.URL.Query(), formData)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	// Data entered against an older version is upgraded before validation
+	formData, err = ah.migrateSubmission(schema, r.URL.Query(), formData)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+	options.TamperPolicy = ah.tamperPolicy
+	options.Access = ah.accessContext(r)
+	options.OptionResolver = ah.optionResolver(r)
+	options.Verifiers = ah.verifiers
+	options.RemoteValidators = ah.getRemoteValidators()
+	options.FileStorage = ah.fileStorage
+	options.FailureLogger = ah.failureLogger
+	options.Context = r.Context()
+	options.Variables = ah.renderContext(r)
+
+	// Locked fields bound to the parent record always carry the record's values
+	parent, err := ParentContextFromRequest(r.URL.Query(), formData)
+	if err == nil {
+		err = schema.ResolveParent(parent, ah.parentResolver)
+	}
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	submission := &SubmissionContext{
+		Context:  r.Context(),
+		Request:  r,
+		FormID:   formID,
+		Schema:   schema,
+		Data:     formData,
+		Parent:   parent,
+		Response: map[string]interface{}{},
+	}
+	if err := ah.runSubmissionStage(submission, SubmissionStagePreValidate, nil); err != nil {
+		ah.writeSubmissionError(w, formID, err, http.StatusBadRequest)
 		return
 	}
+	schema.EnforceParentBindings(submission.Data, parent)
 
 	// Validate form first
+	start := time.Now()
 	validator := NewValidator(schema)
-	result := validator.ValidateForm(formData)
+	result := validator.ValidateFormWithOptions(submission.Data, options)
+
+	// Handlers may add errors of their own, such as checks against a database
+	submission.Result = result
+	if err := ah.runSubmissionStage(submission, SubmissionStagePostValidate, nil); err != nil {
+		ah.writeSubmissionError(w, formID, err, http.StatusBadRequest)
+		return
+	}
+	result.Valid = len(result.Errors) == 0
 
 	if !result.Valid {
 		// Return validation errors
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		err := json.NewEncoder(w).Encode(result)
+		ah.recordValidationFailure(r, start, schema, result, "submit")
+		writeValidationFailure(w, result)
+		return
+	}
+
+	// Sensitive forms wait until the submitter confirms with a one-time code
+	if schema.Confirmation != nil {
+		ah.parkSubmission(w, r, schema, submission.Data, parent)
+		return
+	}
+
+	ah.finalizeSubmission(w, submission)
+}
+
+// finalizeSubmission completes a validated submission and writes the response. Forms that
+// submit asynchronously are queued instead, when a submission queue is running.
+func (ah *APIHandler) finalizeSubmission(w http.ResponseWriter, submission *SubmissionContext) {
+	if ah.submitsAsync(submission) {
+		ah.enqueueSubmission(w, submission)
+		return
+	}
+
+	status, response := ah.completeSubmission(submission)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// completeSubmission completes a validated submission and records it in the audit log. It
+// returns the status and body of the submit response.
+func (ah *APIHandler) completeSubmission(submission *SubmissionContext) (int, map[string]interface{}) {
+	start := time.Now()
+	status, response := ah.storeSubmission(submission)
+
+	event := &AuditEvent{
+		Type:    AuditSubmission,
+		FormID:  submission.Schema.ID,
+		Version: submission.Schema.Version,
+		Details: map[string]interface{}{"status": status},
+	}
+	if status >= http.StatusBadRequest {
+		event.Error, _ = response["message"].(string)
+	}
+	if submission.SubmissionID != "" {
+		event.Details["submissionId"] = submission.SubmissionID
+	}
+	ah.audit(submission.Request, start, event)
+	return status, response
+}
+
+// storeSubmission checks a validated submission for duplicates, records consents, applies
+// the output mapping, claims the values of unique constraints, and runs the persist and notify stages of the pipeline. It returns the
+// status and body of the submit response.
+func (ah *APIHandler) storeSubmission(submission *SubmissionContext) (int, map[string]interface{}) {
+	schema := submission.Schema
+	formID := schema.ID
+	formData := submission.Data
+	parent := submission.Parent
+
+	// Check the submission against earlier ones
+	var duplicates []*DuplicateMatch
+	var duplicateAction DuplicateAction
+	if ah.submissionStore != nil && len(schema.Duplicates) > 0 {
+		previous, err := ah.submissionStore.List(formID)
+		if err == nil {
+			// Stored sensitive values are encrypted, and only match submitted ones decrypted
+			previous, err = ah.decryptSubmissions(submission.Context, schema, previous)
+		}
 		if err != nil {
-			http.Error(w, "Bad request", http.StatusBadRequest)
-			return
+			return submissionErrorResponse(formID, fmt.Errorf("error checking duplicates: %w", err), http.StatusInternalServerError)
 		}
-		return
+		duplicates, duplicateAction = schema.FindDuplicates(formData, previous)
+		if duplicateAction == DuplicateActionBlock {
+			return http.StatusConflict, map[string]interface{}{
+				"success":    false,
+				"code":       ErrorCodeConflict,
+				"message":    "A matching submission already exists",
+				"formId":     formID,
+				"duplicates": duplicates,
+			}
+		}
+	}
+
+	// Store phone numbers in E.164 form, whichever way they were entered
+	schema.NormalizePhoneNumbers(formData)
+
+	// Store amounts in minor units with their currency, whichever way they were entered
+	schema.NormalizeCurrencyAmounts(formData)
+
+	// Store times with their time zone, and date ranges as plain dates
+	schema.NormalizeDateTimes(formData)
+
+	// Record the exact legal text each consent field's acceptance applies to
+	schema.RecordConsents(formData, ah.requestTime(submission.Context))
+
+	// Seal signatures with a hash of the signed data as evidence against later changes
+	if err := schema.SealSignatures(formData, ah.requestTime(submission.Context), ah.signatureSealKey()); err != nil {
+		return submissionErrorResponse(formID, err, http.StatusInternalServerError)
+	}
+
+	// Sensitive values are encrypted before they reach downstream systems, handlers, or storage
+	plain := formData
+	formData, err := ah.encryptSensitive(submission.Context, schema, formData)
+	if err != nil {
+		return submissionErrorResponse(formID, err, http.StatusInternalServerError)
+	}
+	submission.Data = formData
+
+	// Reshape the validated data into the payload downstream systems expect
+	output, err := schema.ApplyOutputMapping(formData)
+	if err != nil {
+		return submissionErrorResponse(formID, err, http.StatusInternalServerError)
 	}
 
-	// Process form submission (in a real implementation, this would save to a database)
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Form submitted successfully",
 		"formId":  formID,
-		"data":    formData,
+		"data":    schema.MaskSensitive(formData),
+	}
+	if schema.Output != nil {
+		response["output"] = output
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(response)
+	// Values of unique constraints are claimed before the submission is saved, so concurrent
+	// submissions of the same values cannot both succeed
+	submissionID := newID()
+	if err := ah.reserveUnique(schema, submissionID, plain); err != nil {
+		return uniqueConflictResponse(schema, err)
+	}
+
+	// Persist handlers wrap saving the submission for back-office triage
+	submission.Output = output
+	err = ah.runSubmissionStage(submission, SubmissionStagePersist, func() error {
+		if ah.submissionStore == nil {
+			return nil
+		}
+		stored := NewSubmission(formID, formData)
+		stored.ID = submissionID
+		if duplicateAction == DuplicateActionLink {
+			stored.DuplicateOf = duplicates[0].SubmissionID
+			response["duplicateOf"] = stored.DuplicateOf
+		}
+		if err := ah.submissionStore.Save(stored); err != nil {
+			return fmt.Errorf("error saving submission: %w", err)
+		}
+		submission.SubmissionID = stored.ID
+		return nil
+	})
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
-		return
+		ah.releaseUnique(schema, submissionID)
+		return submissionErrorResponse(formID, err, http.StatusInternalServerError)
+	}
+	if submission.SubmissionID != "" {
+		response["submissionId"] = submission.SubmissionID
 	}
+
+	// The submission is kept, so failed notifications are reported without failing it
+	if err := ah.runSubmissionStage(submission, SubmissionStageNotify, nil); err != nil {
+		response["notifyError"] = err.Error()
+	}
+
+	if len(duplicates) > 0 {
+		response["duplicates"] = duplicates
+	}
+	if parent != nil {
+		response["parent"] = parent
+	}
+
+	// Handlers may add fields to the response but not replace the standard ones
+	for key, value := range submission.Response {
+		if _, exists := response[key]; !exists {
+			response[key] = value
+		}
+	}
+
+	return http.StatusOK, response
 }
 
 // handleAuth handles authentication requests
 func (ah *APIHandler) handleAuth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
-	// Extract auth type from path
-	authType := getPathParam(r.URL.Path, "/api/auth/")
-	if authType == "" {
-		http.Error(w, "Auth type is required", http.StatusBadRequest)
+	// Extract auth type, and optionally the provider, from path
+	segments := splitPath(getPathParam(r.URL.Path, "/api/auth/"))
+	if len(segments) == 0 {
+		writeError(w, badRequest("Auth type is required"))
 		return
 	}
+	authType := segments[0]
 
 	// Parse request body
 	var authData map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&authData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	var token string
-	var err error
-
-	// Process based on auth type
-	switch authType {
-	case "oauth":
-		token, err = ah.authService.AuthenticateOAuth(authData)
-		break
-	case "basic":
-		token, err = ah.authService.AuthenticateBasic(authData["username"], authData["password"])
-		break
-	case "apikey":
-		token, err = ah.authService.AuthenticateAPIKey(authData["apiKey"])
-		break
-	case "jwt":
-		token, err = ah.authService.AuthenticateJWT(authData)
-		break
-	case "saml":
-		token, err = ah.authService.AuthenticateSAML(authData)
-		break
-	default:
-		http.Error(w, "Unsupported auth type", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &authData); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Authentication failed: %v", err), http.StatusUnauthorized)
+	// Route to the provider named in the path or body, or the default one
+	provider := authData["provider"]
+	if len(segments) > 1 {
+		provider = segments[1]
+	}
+	token, err := ah.authService.AuthenticateToken(r.Context(), authType, provider, authData)
+	switch {
+	case errors.Is(err, ErrUnsupportedAuthType):
+		writeError(w, badRequest("Unsupported auth type"))
+		return
+	case errors.Is(err, ErrAuthProviderNotFound):
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	case err != nil:
+		writeError(w, NewAPIError(http.StatusUnauthorized, ErrorCodeUnauthorized, fmt.Sprintf("Authentication failed: %v", err)))
 		return
 	}
 
 	// Store token for service
-	serviceID := authData["serviceId"]
-	if serviceID != "" {
-		switch authType {
-		case "oauth", "basic", "apikey":
-			ah.authService.SetToken(serviceID, token)
-			break
-		case "jwt":
-			ah.authService.SetJWTToken(serviceID, token)
-			break
-		case "saml":
-			ah.authService.SetSAMLToken(serviceID, token)
-			break
-		}
-	}
-
-	// Return token
-	response := map[string]string{
-		"token": token,
+	if serviceID := authData["serviceId"]; serviceID != "" {
+		ah.authService.StoreToken(serviceID, token)
+		token.ServiceID = serviceID
 	}
 
+	// Return token with its metadata
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	_ = json.NewEncoder(w).Encode(token)
 }
 
 // handleDynamicFunction handles requests to execute a dynamic function
 func (ah *APIHandler) handleDynamicFunction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
 	// Check if dynamic function service is configured
 	if ah.dynamicFunctionService == nil {
-		http.Error(w, "Dynamic function service not configured", http.StatusInternalServerError)
+		writeError(w, notConfigured(http.StatusInternalServerError, "Dynamic function service not configured"))
 		return
 	}
 
 	// Extract function name from path
 	functionName := getPathParam(r.URL.Path, "/api/function/")
 	if functionName == "" {
-		http.Error(w, "Function name is required", http.StatusBadRequest)
+		writeError(w, badRequest("Function name is required"))
 		return
 	}
 
@@ -555,30 +1446,46 @@ func (ah *APIHandler) handleDynamicFunction(w http.ResponseWriter, r *http.Reque
 	var request struct {
 		Arguments map[string]interface{} `json:"arguments"`
 		FormState map[string]interface{} `json:"formState"`
+		SessionID string                 `json:"sessionId"`
+		Field     string                 `json:"field"`
+		Attempt   string                 `json:"attempt"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
-	// Execute the function
-	result, err := ah.dynamicFunctionService.ExecuteFunction(
+	// Execute the function, once per attempt if it has side effects
+	call := functionCall(r, request.SessionID, request.Field, request.Attempt)
+	start := time.Now()
+	result, err := ah.dynamicFunctionService.ExecuteFunctionCall(
 		functionName,
+		call,
 		request.Arguments,
-		request.FormState,
+		ah.functionState(r, request.SessionID, request.FormState),
 	)
-
+	ah.audit(r, start, &AuditEvent{
+		Type:    AuditFunctionExecuted,
+		Error:   auditError(err),
+		Details: map[string]interface{}{"function": functionName},
+	})
+
+	if isFunctionCallError(err) {
+		writeError(w, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error executing function: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing function: %v", err)))
 		return
 	}
 
 	// Return the result
+	writeFunctionResultHeaders(w, result)
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(result)
+	err = json.NewEncoder(w).Encode(result.Value)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeError(w, badRequest("Bad request"))
 		return
 	}
 }
@@ -586,20 +1493,33 @@ func (ah *APIHandler) handleDynamicFunction(w http.ResponseWriter, r *http.Reque
 // handleDynamicField handles requests to get/update a dynamic field
 func (ah *APIHandler) handleDynamicField(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
+	// Batches of fields are served on their own
+	if pathParts := splitPath(r.URL.Path); len(pathParts) == 5 && pathParts[4] == "batch" {
+		batch, err := isBatchRequest(r)
+		if err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if batch {
+			ah.handleDynamicFieldBatch(w, r, pathParts[3])
+			return
+		}
+	}
+
 	// Check if dynamic function service is configured
 	if ah.dynamicFunctionService == nil {
-		http.Error(w, "Dynamic function service not configured", http.StatusInternalServerError)
+		writeError(w, notConfigured(http.StatusInternalServerError, "Dynamic function service not configured"))
 		return
 	}
 
 	// Extract form ID and field ID from path
 	pathParts := splitPath(r.URL.Path)
-	if len(pathParts) < 4 {
-		http.Error(w, "Form ID and Field ID are required", http.StatusBadRequest)
+	if len(pathParts) < 5 {
+		writeError(w, badRequest("Form ID and Field ID are required"))
 		return
 	}
 
@@ -610,60 +1530,81 @@ func (ah *APIHandler) handleDynamicField(w http.ResponseWriter, r *http.Request)
 	var request struct {
 		Config    *DynamicFieldConfig    `json:"config"`
 		FormState map[string]interface{} `json:"formState"`
+		SessionID string                 `json:"sessionId"`
+		Attempt   string                 `json:"attempt"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
 	// Get schema
 	schema, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
 	// Find field
 	field := schema.FindFieldByID(fieldID)
 	if field == nil {
-		http.Error(w, "Field not found", http.StatusNotFound)
+		writeError(w, fieldNotFound(fieldID))
 		return
 	}
 
-	// Execute the dynamic field function
-	result, err := request.Config.ExecuteWithFormState(ah.dynamicFunctionService, request.FormState)
+	// Execute the dynamic field function, once per attempt if it has side effects
+	call := functionCall(r, request.SessionID, fieldID, request.Attempt)
+	result, err := request.Config.ExecuteCall(ah.dynamicFunctionService, call, ah.withRenderContext(r, request.FormState))
+	if isFunctionCallError(err) {
+		writeError(w, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error executing dynamic field function: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing dynamic field function: %v", err)))
 		return
 	}
 
 	// Return the result
+	writeFunctionResultHeaders(w, result)
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(result)
+	err = json.NewEncoder(w).Encode(result.Value)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeError(w, badRequest("Bad request"))
 		return
 	}
 }
 
+// dynamicOptionsRequest is the body of dynamic options requests
+type dynamicOptionsRequest struct {
+	Config        *DynamicFieldConfig    `json:"config"`
+	FormState     map[string]interface{} `json:"formState"`
+	Search        string                 `json:"search,omitempty"`
+	Filters       map[string]interface{} `json:"filters,omitempty"`
+	Sort          string                 `json:"sort,omitempty"`
+	SortDirection string                 `json:"sortDirection,omitempty"`
+	Limit         int                    `json:"limit,omitempty"`
+	Offset        int                    `json:"offset,omitempty"`
+	Cursor        string                 `json:"cursor,omitempty"` // Cursor of the page to return, in cursor mode
+}
+
 // handleDynamicOptions handles requests for dynamic field options with search/filter support
 func (ah *APIHandler) handleDynamicOptions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, ErrMethodNotAllowed)
 		return
 	}
 
 	// Check if dynamic function service is configured
 	if ah.dynamicFunctionService == nil {
-		http.Error(w, "Dynamic function service not configured", http.StatusInternalServerError)
+		writeError(w, notConfigured(http.StatusInternalServerError, "Dynamic function service not configured"))
 		return
 	}
 
 	// Extract form ID and field ID from path
 	pathParts := splitPath(r.URL.Path)
-	if len(pathParts) < 4 {
-		http.Error(w, "Form ID and Field ID are required", http.StatusBadRequest)
+	if len(pathParts) < 5 {
+		writeError(w, badRequest("Form ID and Field ID are required"))
 		return
 	}
 
@@ -671,47 +1612,47 @@ func (ah *APIHandler) handleDynamicOptions(w http.ResponseWriter, r *http.Reques
 	fieldID := pathParts[4]
 
 	// Parse request body
-	var request struct {
-		Config        *DynamicFieldConfig    `json:"config"`
-		FormState     map[string]interface{} `json:"formState"`
-		Search        string                 `json:"search,omitempty"`
-		Filters       map[string]interface{} `json:"filters,omitempty"`
-		Sort          string                 `json:"sort,omitempty"`
-		SortDirection string                 `json:"sortDirection,omitempty"`
-		Limit         int                    `json:"limit,omitempty"`
-		Offset        int                    `json:"offset,omitempty"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var request dynamicOptionsRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
 		return
 	}
 
 	// Get schema
 	schema, ok := ah.GetSchema(formID)
 	if !ok {
-		http.Error(w, "Form not found", http.StatusNotFound)
+		writeError(w, ErrFormNotFound)
 		return
 	}
 
 	// Find field
 	field := schema.FindFieldByID(fieldID)
 	if field == nil {
-		http.Error(w, "Field not found", http.StatusNotFound)
+		writeError(w, fieldNotFound(fieldID))
+		return
+	}
+
+	// Functions declared in cursor mode, or requests paging by cursor, are answered page by page
+	declared := request.Config
+	if field.Options != nil && field.Options.DynamicSource != nil && field.Options.DynamicSource.FunctionConfig != nil {
+		declared = field.Options.DynamicSource.FunctionConfig
+	}
+	if request.Cursor != "" || declared.paginationMode() == PaginationModeCursor {
+		ah.handleOptionCursor(w, r, declared, &request)
 		return
 	}
 
 	// Execute the dynamic field function
-	result, err := request.Config.ExecuteWithFormState(ah.dynamicFunctionService, request.FormState)
+	result, err := request.Config.ExecuteWithFormState(ah.dynamicFunctionService, ah.withRenderContext(r, request.FormState))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error executing dynamic field function: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing dynamic field function: %v", err)))
 		return
 	}
 
 	// Convert result to options
 	options, err := request.Config.CreateOptionsFromResult(result)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error converting result to options: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error converting result to options: %v", err)))
 		return
 	}
 
@@ -727,7 +1668,7 @@ func (ah *APIHandler) handleDynamicOptions(w http.ResponseWriter, r *http.Reques
 
 	filteredOptions, err := ah.dynamicFunctionService.SearchAndSort(options, searchParams)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error filtering options: %v", err), http.StatusInternalServerError)
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error filtering options: %v", err)))
 		return
 	}
 
@@ -745,7 +1686,7 @@ func (ah *APIHandler) handleDynamicOptions(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeError(w, badRequest("Bad request"))
 		return
 	}
 }
@@ -0,0 +1,381 @@
+package smartform
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyOperation is an operation an API key may be allowed to perform on its forms
+type APIKeyOperation string
+
+// Operations API keys can be scoped to
+const (
+	APIKeyOperationRead     APIKeyOperation = "read"     // Get the form schema
+	APIKeyOperationOptions  APIKeyOperation = "options"  // Get field options
+	APIKeyOperationValidate APIKeyOperation = "validate" // Validate form data
+	APIKeyOperationSubmit   APIKeyOperation = "submit"   // Submit and confirm submissions
+)
+
+// APIKeyHeader carries an API key; keys may also be sent as "Authorization: Bearer <key>"
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyPrefix starts every API key, so keys can be told apart from other bearer tokens
+const apiKeyPrefix = "sfk_"
+
+// Errors returned when managing or checking API keys
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyInvalid  = errors.New("invalid or revoked API key")
+)
+
+// APIKey grants machine access to specific operations on specific forms
+type APIKey struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Forms      []string          `json:"forms"`
+	Operations []APIKeyOperation `json:"operations"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	RevokedAt  *time.Time        `json:"revokedAt,omitempty"`
+	SecretHash string            `json:"-"`
+}
+
+// Allows reports whether the key may perform an operation on a form
+func (k *APIKey) Allows(formID string, operation APIKeyOperation) bool {
+	if k.RevokedAt != nil || formID == "" {
+		return false
+	}
+	formAllowed := false
+	for _, id := range k.Forms {
+		if id == formID {
+			formAllowed = true
+			break
+		}
+	}
+	if !formAllowed {
+		return false
+	}
+	for _, allowed := range k.Operations {
+		if allowed == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the key
+func (k *APIKey) Clone() *APIKey {
+	if k == nil {
+		return nil
+	}
+	clone := *k
+	clone.Forms = append([]string{}, k.Forms...)
+	clone.Operations = append([]APIKeyOperation{}, k.Operations...)
+	if k.RevokedAt != nil {
+		revokedAt := *k.RevokedAt
+		clone.RevokedAt = &revokedAt
+	}
+	return &clone
+}
+
+// APIKeyStore persists API keys. Only a hash of each key's secret is stored.
+type APIKeyStore interface {
+	// Save stores a new key
+	Save(key *APIKey) error
+	// Get loads a key by ID
+	Get(id string) (*APIKey, error)
+	// List returns all keys, oldest first
+	List() ([]*APIKey, error)
+	// Update applies a change to a stored key atomically
+	Update(id string, change func(key *APIKey) error) (*APIKey, error)
+}
+
+// MemoryAPIKeyStore keeps API keys in memory
+type MemoryAPIKeyStore struct {
+	keys map[string]*APIKey
+	lock sync.RWMutex
+}
+
+// NewMemoryAPIKeyStore creates a new in-memory API key store
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+// Save stores a new key
+func (ms *MemoryAPIKeyStore) Save(key *APIKey) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.keys[key.ID] = key.Clone()
+	return nil
+}
+
+// Get loads a key by ID
+func (ms *MemoryAPIKeyStore) Get(id string) (*APIKey, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	key, ok := ms.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key.Clone(), nil
+}
+
+// List returns all keys, oldest first
+func (ms *MemoryAPIKeyStore) List() ([]*APIKey, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	keys := make([]*APIKey, 0, len(ms.keys))
+	for _, key := range ms.keys {
+		keys = append(keys, key.Clone())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Update applies a change to a stored key atomically
+func (ms *MemoryAPIKeyStore) Update(id string, change func(key *APIKey) error) (*APIKey, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	stored, ok := ms.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	updated := stored.Clone()
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+	ms.keys[id] = updated
+	return updated.Clone(), nil
+}
+
+// SetAPIKeyStore enables API keys, stored in store. Requests carrying a key are then limited to
+// the forms and operations the key is scoped to.
+func (ah *APIHandler) SetAPIKeyStore(store APIKeyStore) {
+	ah.apiKeys = store
+}
+
+// CreateAPIKey creates a key allowed to perform operations on forms. The returned secret is the
+// key clients send; it is not stored and cannot be retrieved again.
+func (ah *APIHandler) CreateAPIKey(name string, forms []string, operations ...APIKeyOperation) (*APIKey, string, error) {
+	if ah.apiKeys == nil {
+		return nil, "", errors.New("API keys are not configured")
+	}
+	if len(forms) == 0 || len(operations) == 0 {
+		return nil, "", errors.New("an API key needs at least one form and one operation")
+	}
+	for _, operation := range operations {
+		switch operation {
+		case APIKeyOperationRead, APIKeyOperationOptions, APIKeyOperationValidate, APIKeyOperationSubmit:
+		default:
+			return nil, "", fmt.Errorf("unknown API key operation %q", operation)
+		}
+	}
+
+	key := &APIKey{
+		ID:         newID(),
+		Name:       name,
+		Forms:      append([]string{}, forms...),
+		Operations: append([]APIKeyOperation{}, operations...),
+		CreatedAt:  time.Now(),
+	}
+	secret := newID() + newID()
+	key.SecretHash = hashSecret(secret)
+	if err := ah.apiKeys.Save(key); err != nil {
+		return nil, "", fmt.Errorf("error saving API key: %w", err)
+	}
+	return key.Clone(), apiKeyPrefix + key.ID + "_" + secret, nil
+}
+
+// RevokeAPIKey revokes a key; requests using it are rejected from then on
+func (ah *APIHandler) RevokeAPIKey(id string) error {
+	if ah.apiKeys == nil {
+		return ErrAPIKeyNotFound
+	}
+	_, err := ah.apiKeys.Update(id, func(key *APIKey) error {
+		if key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+		}
+		return nil
+	})
+	return err
+}
+
+// authenticateAPIKey checks a key sent by a client and returns the stored key
+func (ah *APIHandler) authenticateAPIKey(value string) (*APIKey, error) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(value, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(value, apiKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+	key, err := ah.apiKeys.Get(id)
+	if err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashSecret(secret))) != 1 || key.RevokedAt != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	return key, nil
+}
+
+// requestAPIKey returns the API key a request carries, if any. Bearer tokens without the key
+// prefix belong to the application's own authentication and are left alone.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && strings.HasPrefix(token, apiKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// apiKeyScope returns the form and operation a request needs. The operation is empty for
+// endpoints API keys never grant access to.
+func (ah *APIHandler) apiKeyScope(r *http.Request) (string, APIKeyOperation) {
+	segments := splitPath(r.URL.Path)
+	if len(segments) < 3 || segments[0] != "api" {
+		return "", ""
+	}
+
+	switch segments[1] {
+	case "forms":
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return segments[2], APIKeyOperationRead
+		}
+		// Item templates and summaries are read with the form state posted
+		if len(segments) == 6 && segments[3] == "array" && segments[5] == "item-template" {
+			return segments[2], APIKeyOperationRead
+		}
+		if len(segments) == 4 && segments[3] == "summary" {
+			return segments[2], APIKeyOperationRead
+		}
+	case "options":
+		if segments[2] != "dynamic" && segments[2] != "function" {
+			return segments[2], APIKeyOperationOptions
+		}
+	case "validate":
+		return segments[2], APIKeyOperationValidate
+	case "print":
+		return segments[2], APIKeyOperationRead
+	case "submit":
+		if len(segments) == 4 && segments[3] == "confirm" {
+			formID, _ := ah.confirmations.FormID(segments[2])
+			return formID, APIKeyOperationSubmit
+		}
+		return segments[2], APIKeyOperationSubmit
+	case "jobs":
+		if job, err := ah.GetSubmissionJob(segments[2]); err == nil {
+			return job.FormID, APIKeyOperationSubmit
+		}
+	}
+	return "", ""
+}
+
+// apiKeyContextKey keys the authenticated API key in a request context
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the API key a request was authenticated with, for instance in a
+// submission handler
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(*APIKey)
+	return key, ok
+}
+
+// apiKeyMiddleware limits requests carrying an API key to the key's forms and operations
+func (ah *APIHandler) apiKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := requestAPIKey(r)
+		if value == "" || ah.apiKeys == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := ah.authenticateAPIKey(value)
+		if err != nil {
+			writeError(w, statusError(http.StatusUnauthorized, err))
+			return
+		}
+		formID, operation := ah.apiKeyScope(r)
+		if operation == "" || !key.Allows(formID, operation) {
+			writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "API key is not allowed to perform this operation"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+// handleAPIKeys handles staff requests to list, create, and revoke API keys
+func (ah *APIHandler) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if ah.apiKeys == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "API keys are not configured"))
+		return
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	id := getPathSegment(r.URL.Path, 2)
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		keys, err := ah.apiKeys.List()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keys)
+
+	case id == "" && r.Method == http.MethodPost:
+		var request struct {
+			Name       string            `json:"name"`
+			Forms      []string          `json:"forms"`
+			Operations []APIKeyOperation `json:"operations"`
+		}
+		if err := decodeRequest(r.Body, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		key, secret, err := ah.CreateAPIKey(request.Name, request.Forms, request.Operations...)
+		if err != nil {
+			writeError(w, statusError(http.StatusBadRequest, err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":    key,
+			"secret": secret,
+		})
+
+	case id != "" && r.Method == http.MethodDelete:
+		if err := ah.RevokeAPIKey(id); err != nil {
+			if errors.Is(err, ErrAPIKeyNotFound) {
+				writeError(w, statusError(http.StatusNotFound, err))
+				return
+			}
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, ErrMethodNotAllowed)
+	}
+}
@@ -0,0 +1,101 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedAPIKeys(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetAPIKeyStore(NewMemoryAPIKeyStore())
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "admin", r.Header.Get("X-Staff") == "yes"
+	})
+	for _, id := range []string{"leads", "payroll"} {
+		require.NoError(t, handler.RegisterSchema(NewForm(id, id).
+			AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+			Build()))
+	}
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	key, secret, err := handler.CreateAPIKey("crm", []string{"leads"}, APIKeyOperationSubmit)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "sfk_"))
+
+	call := func(method, path, apiKey string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"name": "Ada"}`))
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "/api/submit/leads", secret))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodPost, "/api/submit/payroll", secret))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodPost, "/api/validate/leads", secret))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/forms", secret))
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, "/api/keys", secret))
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/api/submit/leads", secret+"x"))
+
+	// Other bearer tokens belong to the application and are not checked
+	assert.Equal(t, http.StatusOK, call(http.MethodPost, "/api/submit/payroll", "eyJhbGciOi"))
+
+	require.NoError(t, handler.RevokeAPIKey(key.ID))
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodPost, "/api/submit/leads", secret))
+}
+
+func TestAPIKeyManagementEndpoints(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetAPIKeyStore(NewMemoryAPIKeyStore())
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "admin", r.Header.Get("X-Staff") == "yes"
+	})
+	require.NoError(t, handler.RegisterSchema(NewForm("leads", "Leads").Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	staff := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Staff", "yes")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := staff(http.MethodPost, "/api/keys", `{"name": "crm", "forms": ["leads"], "operations": ["read", "submit"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Key    APIKey `json:"key"`
+		Secret string `json:"secret"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.NotContains(t, rec.Body.String(), "secretHash")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/forms/leads", nil)
+	req.Header.Set(APIKeyHeader, created.Secret)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, staff(http.MethodPost, "/api/keys", `{"name": "x", "forms": ["leads"], "operations": ["delete"]}`).Code)
+	assert.Equal(t, http.StatusNoContent, staff(http.MethodDelete, "/api/keys/"+created.Key.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, staff(http.MethodDelete, "/api/keys/unknown", "").Code)
+
+	var keys []*APIKey
+	require.NoError(t, json.Unmarshal(staff(http.MethodGet, "/api/keys", "").Body.Bytes(), &keys))
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].RevokedAt)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/keys", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
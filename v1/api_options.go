@@ -2,11 +2,14 @@ package smartform
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,7 +18,20 @@ type OptionService struct {
 	client          *http.Client
 	cache           map[string]*CacheEntry
 	cacheTTL        time.Duration
+	cacheLock       sync.RWMutex
+	swept           time.Time // When expired entries were last dropped from the cache
+	revalidating    map[string]bool
+	keyComponents   []string
 	functionService *DynamicFunctionService
+	authService     *AuthService
+	windowSources   map[string]OptionWindowSource
+	windowLock      sync.RWMutex
+	sourceLimits    map[string]OptionSourceLimit
+	limiters        map[string]*sourceLimiter
+	breakerConfigs  map[string]OptionCircuitBreaker
+	breakers        map[string]*circuitBreaker
+	limitLock       sync.Mutex
+	telemetry       Telemetry
 }
 
 // NewOptionService creates a new option service
@@ -24,41 +40,99 @@ func NewOptionService(cacheTTL time.Duration) *OptionService {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		cache:    make(map[string]*CacheEntry),
-		cacheTTL: cacheTTL,
+		cache:         make(map[string]*CacheEntry),
+		cacheTTL:      cacheTTL,
+		revalidating:  make(map[string]bool),
+		windowSources: make(map[string]OptionWindowSource),
 	}
 }
 
 // GetDynamicOptions fetches options from a dynamic source
 func (os *OptionService) GetDynamicOptions(source *DynamicSource, context map[string]interface{}) ([]*Option, error) {
+	return os.GetDynamicOptionsFor(nil, source, context)
+}
+
+// GetDynamicOptionsFor fetches options from a dynamic source on behalf of an identity, caching
+// them separately from the options fetched for other identities
+func (os *OptionService) GetDynamicOptionsFor(identity CacheIdentity, source *DynamicSource, context map[string]interface{}) ([]*Option, error) {
 	switch source.Type {
 	case "api":
-		return os.fetchAPIOptions(source, context)
+		options, err := os.fetchAPIOptions(source, context, identity)
+		if err != nil && source.Fallback != nil {
+			// Degrade to the fallback options rather than failing the form
+			return cloneOptions(source.Fallback), nil
+		}
+		return options, err
 	case "function":
 		return os.executeFunctionOptions(source, context)
+	case OptionSourceTypeWindow:
+		return nil, fmt.Errorf("options of windowed source %s can only be requested by window", source.WindowSource)
 	default:
 		return nil, fmt.Errorf("unsupported dynamic source type: %s", source.Type)
 	}
 }
 
 // fetchAPIOptions fetches options from an API endpoint
-func (os *OptionService) fetchAPIOptions(source *DynamicSource, context map[string]interface{}) ([]*Option, error) {
-	// Prepare the endpoint URL with context variables
+func (os *OptionService) fetchAPIOptions(source *DynamicSource, context map[string]interface{}, identity CacheIdentity) ([]*Option, error) {
+	// Prepare the endpoint URL with context variables and ${identity.*} placeholders, which
+	// callers cannot spoof
 	endpoint := os.replaceContextVariables(source.Endpoint, context)
+	for component, value := range identity {
+		endpoint = strings.ReplaceAll(endpoint, "${identity."+component+"}", url.PathEscape(value))
+	}
 
-	// Check cache first
-	cacheKey := os.generateCacheKey(endpoint, source.Method, source.Parameters)
-	if entry, ok := os.cache[cacheKey]; ok {
-		if time.Since(entry.Timestamp) < os.cacheTTL {
-			// Cache is still valid
-			return os.parseOptionsFromResponse(entry.Data, source.ValuePath, source.LabelPath)
+	// Options of a service that is not connected are unavailable until it is
+	token := ""
+	if source.ServiceID != "" {
+		var ok bool
+		if os.authService != nil {
+			token, ok = os.authService.GetToken(source.ServiceID)
+		}
+		if !ok {
+			return nil, &OptionsUnavailableError{
+				Code:       ServiceDisconnectedCode,
+				Message:    fmt.Sprintf("service %s is not connected", source.ServiceID),
+				Source:     source.ServiceID,
+				RetryAfter: int(DefaultOptionsRetryAfter / time.Second),
+			}
 		}
 	}
 
-	// Prepare request
-	var req *http.Request
-	var err error
+	// Check cache first, refreshing stale entries in the background if the source allows them
+	identity = os.keyIdentity(identity)
+	cacheKey := os.generateCacheKey(endpoint, source.Method, source.Parameters, source.Headers, identity)
+	policy := source.CachePolicy
+	if !policy.noCache() {
+		data, fresh, ok := os.cacheLookup(cacheKey, policy)
+		os.countCacheLookup(ok, fresh)
+		if ok {
+			if !fresh {
+				os.revalidate(cacheKey, sourceCacheTag(source), identity, policy, func() ([]byte, error) {
+					return os.requestAPIOptions(source, endpoint, token)
+				})
+			}
+			return os.parseOptionsFromResponse(data, source.ValuePath, source.LabelPath)
+		}
+	}
+
+	body, err := os.requestAPIOptions(source, endpoint, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the response
+	if !policy.noCache() {
+		os.cachePut(cacheKey, body, identity, sourceCacheTag(source), policy)
+	}
 
+	// Parse options from response
+	return os.parseOptionsFromResponse(body, source.ValuePath, source.LabelPath)
+}
+
+// requestAPIOptions requests the options of an API source from its upstream
+func (os *OptionService) requestAPIOptions(source *DynamicSource, endpoint, token string) ([]byte, error) {
+	// Prepare request
+	var requestBody []byte
 	if source.Method == "GET" {
 		// Append parameters to URL for GET requests
 		if len(source.Parameters) > 0 {
@@ -72,55 +146,82 @@ func (os *OptionService) fetchAPIOptions(source *DynamicSource, context map[stri
 				endpoint += "?" + strings.Join(params, "&")
 			}
 		}
-		req, err = http.NewRequest("GET", endpoint, nil)
 	} else {
 		// For POST, PUT, etc., add parameters to request body
 		jsonData, err := json.Marshal(source.Parameters)
 		if err != nil {
 			return nil, fmt.Errorf("error marshaling parameters: %w", err)
 		}
-		req, err = http.NewRequest(source.Method, endpoint, bytes.NewBuffer(jsonData))
+		requestBody = jsonData
+	}
+
+	// Execute request, through the circuit breaker and within the concurrency limit of the
+	// upstream. Each attempt sends a new request.
+	var body []byte
+	upstream := endpointSource(endpoint)
+	start := time.Now()
+	ctx, span := startSpan(os.telemetry, context.Background(), "smartform.options.fetch",
+		attribute("smartform.upstream", upstream),
+		attribute("http.request.method", source.Method))
+	err := os.withCircuitBreaker(ctx, upstream, func(ctx context.Context) error {
+		req, err := os.newAPIRequest(ctx, source, endpoint, requestBody, token)
 		if err != nil {
-			return nil, fmt.Errorf("error creating request: %w", err)
+			return err
 		}
-		req.Header.Set("Content-Type", "application/json")
-	}
+		return os.withSourceLimit(ctx, upstream, func() error {
+			resp, err := os.client.Do(req)
+			if err != nil {
+				return fmt.Errorf("error executing request: %w", err)
+			}
+			defer resp.Body.Close()
 
+			// Read response
+			body, err = io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("error reading response: %w", err)
+			}
+
+			// Check status code
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+				return &upstreamStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+			}
+			return nil
+		})
+	})
+	endSpan(span, err)
+	recordDuration(os.telemetry, ctx, MetricOptionFetchDuration, start,
+		attribute("smartform.upstream", upstream),
+		attribute("error", err != nil))
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
+	return body, nil
+}
 
-	// Add headers
-	for k, v := range source.Headers {
-		req.Header.Add(k, v)
+// newAPIRequest creates a request for the options of an API source
+func (os *OptionService) newAPIRequest(ctx context.Context, source *DynamicSource, endpoint string, body []byte, token string) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if body == nil {
+		req, err = http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, source.Method, endpoint, bytes.NewReader(body))
 	}
-
-	// Execute request
-	resp, err := os.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error executing request: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
-	// Check status code
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API returned error status: %d, body: %s", resp.StatusCode, string(body))
+	// Add headers
+	for k, v := range source.Headers {
+		req.Header.Add(k, v)
 	}
-
-	// Cache the response
-	os.cache[cacheKey] = &CacheEntry{
-		Data:      body,
-		Timestamp: time.Now(),
+	if source.ServiceID != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
-
-	// Parse options from response
-	return os.parseOptionsFromResponse(body, source.ValuePath, source.LabelPath)
+	return req, nil
 }
 
 // parseOptionsFromResponse extracts options from an API response
@@ -248,16 +349,6 @@ func (os *OptionService) executeFunctionOptions(source *DynamicSource, context m
 	return nil, fmt.Errorf("function options not implemented")
 }
 
-// generateCacheKey generates a cache key for the request
-func (os *OptionService) generateCacheKey(endpoint, method string, params map[string]interface{}) string {
-	key := method + ":" + endpoint
-	if len(params) > 0 {
-		paramJSON, _ := json.Marshal(params)
-		key += ":" + string(paramJSON)
-	}
-	return key
-}
-
 // replaceContextVariables replaces ${variable} placeholders with values from context
 func (os *OptionService) replaceContextVariables(input string, context map[string]interface{}) string {
 	result := input
@@ -275,6 +366,11 @@ func (os *OptionService) SetDynamicFunctionService(service *DynamicFunctionServi
 	os.functionService = service
 }
 
+// SetAuthService sets the service whose tokens authorize sources of connected services
+func (os *OptionService) SetAuthService(service *AuthService) {
+	os.authService = service
+}
+
 func (os *OptionService) fetchFunctionOptions(source *DynamicSource, context map[string]interface{}) ([]*Option, error) {
 	// Check if we have direct access to the function
 	if source.DirectFunction != nil {
@@ -282,17 +378,15 @@ func (os *OptionService) fetchFunctionOptions(source *DynamicSource, context map
 		params := os.processTemplateVars(source.Parameters, context)
 
 		// Generate cache key
-		cacheKey := os.generateCacheKey("function:"+source.FunctionName, "", params)
+		cacheKey := os.generateCacheKey("function:"+source.FunctionName, "", params, nil, nil)
 
 		// Check cache
-		if entry, ok := os.cache[cacheKey]; ok {
-			if time.Since(entry.Timestamp) < os.cacheTTL {
-				var options []*Option
-				if err := json.Unmarshal(entry.Data, &options); err != nil {
-					return nil, fmt.Errorf("error unmarshaling cached options: %w", err)
-				}
-				return options, nil
+		if data, ok := os.cacheGet(cacheKey, source.CachePolicy); ok {
+			var options []*Option
+			if err := json.Unmarshal(data, &options); err != nil {
+				return nil, fmt.Errorf("error unmarshaling cached options: %w", err)
 			}
+			return options, nil
 		}
 
 		// Execute the direct function
@@ -313,9 +407,8 @@ func (os *OptionService) fetchFunctionOptions(source *DynamicSource, context map
 			return nil, fmt.Errorf("error marshaling options for cache: %w", err)
 		}
 
-		os.cache[cacheKey] = &CacheEntry{
-			Data:      optionsData,
-			Timestamp: time.Now(),
+		if !source.CachePolicy.noCache() {
+			os.cachePut(cacheKey, optionsData, nil, sourceCacheTag(source), source.CachePolicy)
 		}
 
 		return options, nil
@@ -330,17 +423,15 @@ func (os *OptionService) fetchFunctionOptions(source *DynamicSource, context map
 	params := os.processTemplateVars(source.Parameters, context)
 
 	// Generate cache key
-	cacheKey := os.generateCacheKey("function:"+source.FunctionName, "", params)
+	cacheKey := os.generateCacheKey("function:"+source.FunctionName, "", params, nil, nil)
 
 	// Check cache
-	if entry, ok := os.cache[cacheKey]; ok {
-		if time.Since(entry.Timestamp) < os.cacheTTL {
-			var options []*Option
-			if err := json.Unmarshal(entry.Data, &options); err != nil {
-				return nil, fmt.Errorf("error unmarshaling cached options: %w", err)
-			}
-			return options, nil
+	if data, ok := os.cacheGet(cacheKey, source.CachePolicy); ok {
+		var options []*Option
+		if err := json.Unmarshal(data, &options); err != nil {
+			return nil, fmt.Errorf("error unmarshaling cached options: %w", err)
 		}
+		return options, nil
 	}
 
 	// Execute the function
@@ -355,9 +446,8 @@ func (os *OptionService) fetchFunctionOptions(source *DynamicSource, context map
 		return nil, fmt.Errorf("error marshaling options for cache: %w", err)
 	}
 
-	os.cache[cacheKey] = &CacheEntry{
-		Data:      optionsData,
-		Timestamp: time.Now(),
+	if !source.CachePolicy.noCache() {
+		os.cachePut(cacheKey, optionsData, nil, sourceCacheTag(source), source.CachePolicy)
 	}
 
 	return options, nil
@@ -388,81 +478,97 @@ func (os *OptionService) processTemplateVars(args map[string]interface{}, formSt
 	return result
 }
 
-// AuthService handles authentication for API integrations
+// AuthService handles authentication for API integrations. Authentication is delegated to the
+// providers registered for each auth type, and the tokens saved for services are refreshed or
+// expired as their metadata asks.
 type AuthService struct {
-	tokens     map[string]string
-	jwtTokens  map[string]string
-	samlTokens map[string]string
+	tokens        map[string]*TokenInfo             // By token kind and service ID
+	timers        map[string]*time.Timer            // Refresh or expiry of tokens, by token key
+	providers     map[string]map[string]interface{} // By auth type and provider name
+	listeners     []ConnectionListener
+	refreshLeeway time.Duration
+	lock          sync.RWMutex
 }
 
 // NewAuthService creates a new authentication service
 func NewAuthService() *AuthService {
 	return &AuthService{
-		tokens: make(map[string]string),
+		tokens:        make(map[string]*TokenInfo),
+		timers:        make(map[string]*time.Timer),
+		providers:     make(map[string]map[string]interface{}),
+		refreshLeeway: DefaultTokenRefreshLeeway,
+	}
+}
+
+// Clone returns a new service with the same providers, listeners, and refresh leeway, but none
+// of the tokens
+func (as *AuthService) Clone() *AuthService {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	clone := NewAuthService()
+	clone.refreshLeeway = as.refreshLeeway
+	clone.listeners = append([]ConnectionListener{}, as.listeners...)
+	for authType, providers := range as.providers {
+		clone.providers[authType] = make(map[string]interface{}, len(providers))
+		for name, provider := range providers {
+			clone.providers[authType][name] = provider
+		}
 	}
+	return clone
 }
 
-// AuthenticateOAuth performs OAuth authentication
+// AuthenticateOAuth performs OAuth authentication with the provider named by config["provider"]
 func (as *AuthService) AuthenticateOAuth(config map[string]string) (string, error) {
-	// Implementation would handle the OAuth flow
-	return "", fmt.Errorf("OAuth authentication not implemented")
+	return as.Authenticate(context.Background(), AuthTypeOAuth, config["provider"], config)
 }
 
-// AuthenticateBasic performs Basic authentication
+// AuthenticateBasic performs Basic authentication with the default basic provider
 func (as *AuthService) AuthenticateBasic(username, password string) (string, error) {
-	// Implementation would validate credentials and return a token
-	return "", fmt.Errorf("Basic authentication not implemented")
+	return as.Authenticate(context.Background(), AuthTypeBasic, "", map[string]string{"username": username, "password": password})
 }
 
-// AuthenticateAPIKey validates an API key
+// AuthenticateAPIKey validates an API key with the default API key provider
 func (as *AuthService) AuthenticateAPIKey(apiKey string) (string, error) {
-	// Implementation would validate the API key
-	return "", fmt.Errorf("API key authentication not implemented")
+	return as.Authenticate(context.Background(), AuthTypeAPIKey, "", map[string]string{"apiKey": apiKey})
 }
 
-// GetToken retrieves a token for a service
+// GetToken retrieves a token for a service, unless it has expired
 func (as *AuthService) GetToken(serviceID string) (string, bool) {
-	token, ok := as.tokens[serviceID]
-	return token, ok
+	return as.token("", serviceID)
 }
 
-// SetToken stores a token for a service
+// SetToken stores a token for a service that never expires
 func (as *AuthService) SetToken(serviceID, token string) {
-	as.tokens[serviceID] = token
+	as.StoreToken(serviceID, &TokenInfo{Token: token})
 }
 
-// AuthenticateJWT performs JWT authentication
+// AuthenticateJWT performs JWT authentication with the provider named by jwtConfig["provider"]
 func (as *AuthService) AuthenticateJWT(jwtConfig map[string]string) (string, error) {
-	// Implementation would validate JWT parameters and generate a token
-	// This is a simplified placeholder
-	return "", fmt.Errorf("JWT authentication not implemented")
+	return as.Authenticate(context.Background(), AuthTypeJWT, jwtConfig["provider"], jwtConfig)
 }
 
-// AuthenticateSAML performs SAML authentication
+// AuthenticateSAML performs SAML authentication with the provider named by samlConfig["provider"]
 func (as *AuthService) AuthenticateSAML(samlConfig map[string]string) (string, error) {
-	// Implementation would handle SAML authentication flow
-	// This is a simplified placeholder
-	return "", fmt.Errorf("SAML authentication not implemented")
+	return as.Authenticate(context.Background(), AuthTypeSAML, samlConfig["provider"], samlConfig)
 }
 
-// GetJWTToken retrieves a JWT token for a service
+// GetJWTToken retrieves a JWT token for a service, unless it has expired
 func (as *AuthService) GetJWTToken(serviceID string) (string, bool) {
-	token, ok := as.jwtTokens[serviceID]
-	return token, ok
+	return as.token(AuthTypeJWT, serviceID)
 }
 
-// SetJWTToken stores a JWT token for a service
+// SetJWTToken stores a JWT token for a service that never expires
 func (as *AuthService) SetJWTToken(serviceID, token string) {
-	as.jwtTokens[serviceID] = token
+	as.StoreToken(serviceID, &TokenInfo{Token: token, Type: AuthTypeJWT})
 }
 
-// GetSAMLToken retrieves a SAML token for a service
+// GetSAMLToken retrieves a SAML token for a service, unless it has expired
 func (as *AuthService) GetSAMLToken(serviceID string) (string, bool) {
-	token, ok := as.samlTokens[serviceID]
-	return token, ok
+	return as.token(AuthTypeSAML, serviceID)
 }
 
-// SetSAMLToken stores a SAML token for a service
+// SetSAMLToken stores a SAML token for a service that never expires
 func (as *AuthService) SetSAMLToken(serviceID, token string) {
-	as.samlTokens[serviceID] = token
+	as.StoreToken(serviceID, &TokenInfo{Token: token, Type: AuthTypeSAML})
 }
@@ -0,0 +1,188 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// IndexQueryParam is the query parameter naming the array item an item template is for
+const IndexQueryParam = "index"
+
+// ArrayMetadata describes how many items an array field takes and how clients present them
+type ArrayMetadata struct {
+	MinItems  *int   `json:"minItems,omitempty"`
+	MaxItems  *int   `json:"maxItems,omitempty"`
+	ItemLabel string `json:"itemLabel,omitempty"` // Template of the label of each item, e.g. "Room ${add(index, 1)}"
+	Sortable  bool   `json:"sortable,omitempty"`  // Whether users may reorder the items
+}
+
+// ArrayItemTemplate is the item template of an array field resolved for one of its items:
+// the fields of the item with labels, options, visibility, and computed values evaluated in
+// the item's scope, so clients do not have to re-implement the dynamic logic of each row
+type ArrayItemTemplate struct {
+	Path      string                 `json:"path"`            // Path of the item, e.g. "items[3]"
+	Index     int                    `json:"index"`           // Position of the item in the array
+	Label     string                 `json:"label,omitempty"` // Label of the item, resolved from the item label template
+	Fields    []*Field               `json:"fields"`          // Visible fields of the item, resolved for the item
+	Values    map[string]interface{} `json:"values"`          // Values of the item, or the defaults of a new item
+	Array     *ArrayMetadata         `json:"array"`
+	CanAdd    bool                   `json:"canAdd"`    // Whether another item may be added to the array
+	CanRemove bool                   `json:"canRemove"` // Whether the item may be removed from the array
+}
+
+// ItemLabel sets the template of the label of each item, evaluated in the item's scope, e.g.
+// "Room ${add(index, 1)}" or "${item.name}"
+func (ab *ArrayFieldBuilder) ItemLabel(template string) *ArrayFieldBuilder {
+	ab.Property("itemLabel", template)
+	return ab
+}
+
+// Sortable sets whether users may reorder the items of the array
+func (ab *ArrayFieldBuilder) Sortable(sortable bool) *ArrayFieldBuilder {
+	ab.Property("sortable", sortable)
+	return ab
+}
+
+// ArrayMetadata returns the item limits and presentation of an array field, or nil if the
+// field is not an array
+func (f *Field) ArrayMetadata() *ArrayMetadata {
+	if f.Type != FieldTypeArray {
+		return nil
+	}
+	metadata := &ArrayMetadata{
+		MinItems: intProperty(f.Properties, "minItems"),
+		MaxItems: intProperty(f.Properties, "maxItems"),
+	}
+	metadata.ItemLabel, _ = f.Properties["itemLabel"].(string)
+	metadata.Sortable, _ = f.Properties["sortable"].(bool)
+	return metadata
+}
+
+// intProperty returns a property holding a number, which is a float64 in imported schemas
+func intProperty(properties map[string]interface{}, key string) *int {
+	switch value := properties[key].(type) {
+	case int:
+		return &value
+	case float64:
+		n := int(value)
+		return &n
+	}
+	return nil
+}
+
+// RenderArrayItem resolves the item template of the array field at arrayPath (e.g. "items" or
+// "orders[0].lines") for the item at index, given the form state. The fields are evaluated in
+// the scope of array item defaults: the item's fields over the rest of the state, with "item",
+// "parent", and "index". A negative index, or the index of the next item, resolves the
+// template for a new item, whose values are its defaults.
+func (fr *FormRenderer) RenderArrayItem(arrayPath string, index int, state map[string]interface{}) (*ArrayItemTemplate, error) {
+	state = fr.renderState(state)
+	engine := NewStateEngine(fr.schema)
+	field, parent, items, err := engine.findArray(arrayPath, state)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 {
+		index = len(items)
+	}
+	if index > len(items) {
+		return nil, fmt.Errorf("array '%s' has %d items, no item %d", arrayPath, len(items), index)
+	}
+
+	var item map[string]interface{}
+	if index < len(items) {
+		item, _ = items[index].(map[string]interface{})
+	}
+	if item == nil {
+		item = map[string]interface{}{}
+		engine.applyItemDefaults(field.Nested, item, item, parent, index, state)
+	}
+
+	metadata := field.ArrayMetadata()
+	itemPath := fmt.Sprintf("%s[%d]", arrayPath, index)
+	scope := itemScope(state, item, parent, index)
+	template := &ArrayItemTemplate{
+		Path:      itemPath,
+		Index:     index,
+		Label:     fr.evaluateTemplateString(fr.translate(arrayPath+".itemLabel", metadata.ItemLabel), scope),
+		Fields:    []*Field{},
+		Values:    fr.redact(field.Nested, item),
+		Array:     metadata,
+		CanAdd:    metadata.MaxItems == nil || len(items) < *metadata.MaxItems,
+		CanRemove: index < len(items) && (metadata.MinItems == nil || len(items) > *metadata.MinItems),
+	}
+
+	validator := NewValidator(fr.schema)
+	for _, nested := range field.Nested {
+		if !fr.canRead(nested) {
+			continue
+		}
+		if nested.Visible != nil && !validator.evaluateCondition(nested.Visible, scope) {
+			continue
+		}
+		template.Fields = append(template.Fields, fr.copyFieldWithContext(nested, joinPath(itemPath, nested.ID), scope))
+	}
+	return template, nil
+}
+
+// handleArrayItemTemplate handles requests for the item template of an array field resolved
+// for one of its items. The form state is posted as {"state": {...}}; GET requests resolve the
+// template against the render context alone.
+func (ah *APIHandler) handleArrayItemTemplate(w http.ResponseWriter, r *http.Request, formID, arrayPath string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var request struct {
+		State map[string]interface{} `json:"state"`
+	}
+	if r.Method == http.MethodPost {
+		if err := decodeRequest(r.Body, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+
+	query := r.URL.Query()
+	index := -1
+	if value := query.Get(IndexQueryParam); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, badRequest("Index must be a non-negative integer"))
+			return
+		}
+		index = parsed
+	}
+
+	renderer := NewFormRenderer(schema)
+	renderer.SetAccess(ah.accessContext(r))
+	if len(schema.Translations) > 0 {
+		w.Header().Add("Vary", "Accept-Language")
+		if locale := requestLocale(query, r.Header.Get("Accept-Language"), schema); locale != "" {
+			renderer.SetLocale(locale)
+			w.Header().Set("Content-Language", locale)
+		}
+	}
+
+	template, err := renderer.RenderArrayItem(arrayPath, index, ah.withRenderContext(r, request.State))
+	if err != nil {
+		if _, findErr := NewStateEngine(schema).findFieldByPath(arrayPath); findErr != nil {
+			writeError(w, fieldNotFound(arrayPath))
+			return
+		}
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(template)
+}
@@ -0,0 +1,98 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRoomsForm() *FormSchema {
+	form := NewForm("survey", "Survey")
+	form.TextField("building", "Building")
+	rooms := form.ArrayField("rooms", "Rooms")
+	rooms.MinItems(1).MaxItems(3).ItemLabel("Room ${add(index, 1)} of ${building}").Sortable(true)
+	rooms.TextField("name", "Name").Placeholder("Name of ${parent.building} room")
+	rooms.NumberField("width", "Width").DefaultValue(4)
+	rooms.NumberField("length", "Length")
+	rooms.NumberField("area", "Area").ComputedValue("${multiply(width, length)}")
+	rooms.TextField("drain", "Drain location").VisibleWhenEquals("name", "Bathroom")
+	form.TranslationBundle("fr", map[string]string{"rooms.name.label": "Nom"})
+	return form.Build()
+}
+
+func TestRenderArrayItem(t *testing.T) {
+	state := map[string]interface{}{
+		"building": "Annex",
+		"rooms": []interface{}{
+			map[string]interface{}{"name": "Kitchen", "width": 3, "length": 4},
+			map[string]interface{}{"name": "Bathroom", "width": 2, "length": 2},
+		},
+	}
+	renderer := NewFormRenderer(newRoomsForm())
+	renderer.SetLocale("fr")
+
+	template, err := renderer.RenderArrayItem("rooms", 1, state)
+	require.NoError(t, err)
+	assert.Equal(t, "rooms[1]", template.Path)
+	assert.Equal(t, "Room 2 of Annex", template.Label)
+	assert.True(t, template.CanAdd)
+	assert.True(t, template.CanRemove)
+	assert.True(t, template.Array.Sortable)
+	assert.Equal(t, 3, *template.Array.MaxItems)
+	require.Len(t, template.Fields, 5)
+	assert.Equal(t, "Nom", template.Fields[0].Label)
+	assert.Equal(t, "Name of Annex room", template.Fields[0].Placeholder)
+	assert.EqualValues(t, 4, template.Fields[3].DefaultValue)
+	assert.Equal(t, "${multiply(width, length)}", template.Fields[3].ComputedValue)
+	assert.Equal(t, "drain", template.Fields[4].ID)
+
+	// Items past the end are new items, resolved with their defaults
+	template, err = renderer.RenderArrayItem("rooms", -1, state)
+	require.NoError(t, err)
+	assert.Equal(t, 2, template.Index)
+	assert.Equal(t, "Room 3 of Annex", template.Label)
+	assert.Equal(t, map[string]interface{}{"width": 4}, template.Values)
+	assert.False(t, template.CanRemove)
+	assert.Len(t, template.Fields, 4)
+
+	_, err = renderer.RenderArrayItem("rooms", 5, state)
+	assert.Error(t, err)
+	_, err = renderer.RenderArrayItem("building", 0, state)
+	assert.Error(t, err)
+}
+
+func TestArrayItemTemplateEndpoint(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(newRoomsForm()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/forms/survey/array/rooms/item-template?index=0",
+		strings.NewReader(`{"state": {"building": "Annex", "rooms": [{"name": "Hall", "width": 2, "length": 5}]}}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var template ArrayItemTemplate
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &template))
+	assert.Equal(t, "Room 1 of Annex", template.Label)
+	assert.EqualValues(t, 10, template.Values["area"])
+	assert.False(t, template.CanRemove)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/survey/array/rooms/item-template", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &template))
+	assert.Equal(t, "rooms[0]", template.Path)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/survey/array/missing/item-template", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/survey/array/rooms/item-template?index=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,154 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEventType is the kind of a form lifecycle event
+type AuditEventType string
+
+// Form lifecycle events
+const (
+	AuditSchemaRegistered AuditEventType = "schema.registered" // A schema was registered, or failed to be
+	AuditSchemasReloaded  AuditEventType = "schemas.reloaded"  // Schemas were reloaded from the schema source, or failed to be
+	AuditFormRendered     AuditEventType = "form.rendered"     // A form was rendered for a client
+	AuditValidationFailed AuditEventType = "validation.failed" // Data failed validation, when validated or submitted
+	AuditSubmission       AuditEventType = "submission"        // A submission was completed, or failed to be
+	AuditFunctionExecuted AuditEventType = "function.executed" // A dynamic function was executed
+)
+
+// AuditEvent records who did what to a form and when. Events never carry submitted values.
+type AuditEvent struct {
+	Type     AuditEventType         `json:"type"`
+	FormID   string                 `json:"formId,omitempty"`
+	Version  string                 `json:"version,omitempty"`
+	Tenant   string                 `json:"tenant,omitempty"`
+	Actor    string                 `json:"actor,omitempty"` // Who made the request, see SetAuditActorResolver
+	Time     time.Time              `json:"time"`            // When the request was received
+	Duration time.Duration          `json:"duration"`        // How long the operation took
+	Success  bool                   `json:"success"`
+	Error    string                 `json:"error,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"` // Event-specific details, e.g. the submission ID
+}
+
+// AuditLogger records form lifecycle events. It is called synchronously, so implementations
+// that write to slow destinations should buffer.
+type AuditLogger interface {
+	LogAuditEvent(ctx context.Context, event *AuditEvent)
+}
+
+// AuditLoggerFunc adapts a function to the AuditLogger interface
+type AuditLoggerFunc func(ctx context.Context, event *AuditEvent)
+
+// LogAuditEvent calls the function
+func (f AuditLoggerFunc) LogAuditEvent(ctx context.Context, event *AuditEvent) {
+	f(ctx, event)
+}
+
+// JSONAuditLogger writes audit events as JSON, one per line
+type JSONAuditLogger struct {
+	out  io.Writer
+	lock sync.Mutex
+}
+
+// NewJSONAuditLogger creates an audit logger writing to out
+func NewJSONAuditLogger(out io.Writer) *JSONAuditLogger {
+	return &JSONAuditLogger{out: out}
+}
+
+// NewStdoutAuditLogger creates an audit logger writing to standard output, for log collectors
+// reading the output of containers
+func NewStdoutAuditLogger() *JSONAuditLogger {
+	return NewJSONAuditLogger(os.Stdout)
+}
+
+// LogAuditEvent writes an event as a line of JSON
+func (l *JSONAuditLogger) LogAuditEvent(ctx context.Context, event *AuditEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, _ = l.out.Write(append(data, '\n'))
+}
+
+// ActorResolver names who made a request, e.g. the user ID of its session
+type ActorResolver func(r *http.Request) string
+
+// SetAuditLogger sets the logger form lifecycle events are recorded with
+func (ah *APIHandler) SetAuditLogger(logger AuditLogger) {
+	ah.auditLogger = logger
+}
+
+// SetAuditActorResolver sets the function naming who made a request in audit events. Without
+// one, requests authenticated with an API key are attributed to "apikey:<id>", and others to
+// no one.
+func (ah *APIHandler) SetAuditActorResolver(resolver ActorResolver) {
+	ah.actorResolver = resolver
+}
+
+// auditActor names who made a request
+func (ah *APIHandler) auditActor(r *http.Request) string {
+	if ah.actorResolver != nil {
+		return ah.actorResolver(r)
+	}
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return "apikey:" + key.ID
+	}
+	return ""
+}
+
+// audit records an event of a request that started at start, if an audit logger is set. The
+// request is nil for events outside of requests.
+func (ah *APIHandler) audit(r *http.Request, start time.Time, event *AuditEvent) {
+	if ah.auditLogger == nil {
+		return
+	}
+	ctx := context.Background()
+	if r != nil {
+		ctx = r.Context()
+		event.Actor = ah.auditActor(r)
+	}
+	event.Tenant = ah.tenantID
+	event.Time = ah.requestTime(ctx)
+	event.Duration = time.Since(start)
+	if event.Error == "" {
+		event.Success = true
+	}
+	ah.auditLogger.LogAuditEvent(ctx, event)
+}
+
+// auditError returns the message of an error, or "" for none
+func auditError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// recordValidationFailure counts the failure of a validation run and records it in the audit
+// log, with the paths of the fields that failed and the rules they failed
+func (ah *APIHandler) recordValidationFailure(r *http.Request, start time.Time, schema *FormSchema, result *ValidationResult, operation string) {
+	ah.countValidationFailure(r.Context(), schema, result, operation)
+	if ah.auditLogger == nil || result.Valid {
+		return
+	}
+	failures := make([]map[string]string, len(result.Errors))
+	for i, err := range result.Errors {
+		failures[i] = map[string]string{"fieldId": err.FieldID, "rule": err.RuleType}
+	}
+	ah.audit(r, start, &AuditEvent{
+		Type:    AuditValidationFailed,
+		FormID:  schema.ID,
+		Version: schema.Version,
+		Error:   ErrValidationFailed.Error(),
+		Details: map[string]interface{}{"operation": operation, "errors": failures},
+	})
+}
@@ -0,0 +1,117 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAuditLogger keeps the events it is given
+type recordingAuditLogger struct {
+	lock   sync.Mutex
+	events []*AuditEvent
+}
+
+func (l *recordingAuditLogger) LogAuditEvent(ctx context.Context, event *AuditEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *recordingAuditLogger) last() *AuditEvent {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.events[len(l.events)-1]
+}
+
+func TestAuditLogging(t *testing.T) {
+	logger := &recordingAuditLogger{}
+	handler := NewAPIHandler()
+	handler.SetAuditLogger(logger)
+	handler.SetAuditActorResolver(func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("double", func(args, formState map[string]interface{}) (interface{}, error) {
+		return args["n"].(float64) * 2, nil
+	})
+	handler.SetDynamicFunctionService(functions)
+
+	form := NewForm("contact", "Contact")
+	form.TextField("email", "Email").Required(true)
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	event := logger.last()
+	assert.Equal(t, AuditSchemaRegistered, event.Type)
+	assert.Equal(t, "contact", event.FormID)
+	assert.True(t, event.Success)
+	assert.Empty(t, event.Actor)
+
+	mux := handler.Handler()
+	request := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("X-User", "ada")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	request(http.MethodGet, "/api/forms/contact", nil)
+	event = logger.last()
+	assert.Equal(t, AuditFormRendered, event.Type)
+	assert.Equal(t, "ada", event.Actor)
+	assert.Equal(t, DefaultSchemaVersion, event.Version)
+
+	request(http.MethodPost, "/api/validate/contact", map[string]interface{}{"email": "ada@example.com"})
+	assert.Equal(t, AuditFormRendered, logger.last().Type, "passing validations are not logged")
+
+	request(http.MethodPost, "/api/submit/contact", map[string]interface{}{})
+	event = logger.last()
+	assert.Equal(t, AuditValidationFailed, event.Type)
+	assert.False(t, event.Success)
+	assert.Equal(t, "submit", event.Details["operation"])
+	assert.Equal(t, []map[string]string{{"fieldId": "email", "rule": "required"}}, event.Details["errors"])
+
+	rec := request(http.MethodPost, "/api/submit/contact", map[string]interface{}{"email": "ada@example.com"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	event = logger.last()
+	assert.Equal(t, AuditSubmission, event.Type)
+	assert.True(t, event.Success)
+	assert.NotEmpty(t, event.Details["submissionId"])
+	assert.NotContains(t, event.Details, "data")
+
+	request(http.MethodPost, "/api/function/double", map[string]interface{}{"arguments": map[string]interface{}{"n": 2}})
+	event = logger.last()
+	assert.Equal(t, AuditFunctionExecuted, event.Type)
+	assert.Equal(t, "double", event.Details["function"])
+	assert.True(t, event.Success)
+
+	assert.Error(t, handler.RegisterSchema(&FormSchema{ID: "broken", Version: "1"}))
+	event = logger.last()
+	assert.False(t, event.Success)
+	assert.NotEmpty(t, event.Error)
+}
+
+func TestJSONAuditLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewJSONAuditLogger(&out)
+	logger.LogAuditEvent(context.Background(), &AuditEvent{Type: AuditFormRendered, FormID: "contact", Actor: "ada", Success: true})
+	logger.LogAuditEvent(context.Background(), &AuditEvent{Type: AuditSubmission, FormID: "contact", Error: "boom"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	var event AuditEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, AuditFormRendered, event.Type)
+	assert.Equal(t, "ada", event.Actor)
+	assert.Contains(t, lines[1], `"error":"boom"`)
+}
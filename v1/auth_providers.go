@@ -0,0 +1,173 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Auth types handled by the AuthService
+const (
+	AuthTypeOAuth  = "oauth"
+	AuthTypeBasic  = "basic"
+	AuthTypeAPIKey = "apikey"
+	AuthTypeJWT    = "jwt"
+	AuthTypeSAML   = "saml"
+)
+
+// DefaultAuthProvider names the provider used when a request does not name one
+const DefaultAuthProvider = "default"
+
+// Errors returned when a request cannot be routed to a provider
+var (
+	ErrUnsupportedAuthType  = errors.New("unsupported auth type")
+	ErrAuthProviderNotFound = errors.New("auth provider not found")
+)
+
+// OAuthProvider completes OAuth flows, e.g. by exchanging an authorization code for a token
+type OAuthProvider interface {
+	AuthenticateOAuth(ctx context.Context, config map[string]string) (string, error)
+}
+
+// BasicAuthProvider checks a username and password
+type BasicAuthProvider interface {
+	AuthenticateBasic(ctx context.Context, username, password string) (string, error)
+}
+
+// APIKeyProvider checks an API key
+type APIKeyProvider interface {
+	AuthenticateAPIKey(ctx context.Context, apiKey string) (string, error)
+}
+
+// JWTProvider validates JWT parameters and issues a token
+type JWTProvider interface {
+	AuthenticateJWT(ctx context.Context, config map[string]string) (string, error)
+}
+
+// SAMLProvider completes SAML flows
+type SAMLProvider interface {
+	AuthenticateSAML(ctx context.Context, config map[string]string) (string, error)
+}
+
+// RegisterOAuthProvider registers an OAuth provider under a name; an empty name registers the
+// default provider
+func (as *AuthService) RegisterOAuthProvider(name string, provider OAuthProvider) {
+	as.registerProvider(AuthTypeOAuth, name, provider)
+}
+
+// RegisterBasicAuthProvider registers a basic auth provider under a name; an empty name registers
+// the default provider
+func (as *AuthService) RegisterBasicAuthProvider(name string, provider BasicAuthProvider) {
+	as.registerProvider(AuthTypeBasic, name, provider)
+}
+
+// RegisterAPIKeyProvider registers an API key provider under a name; an empty name registers the
+// default provider
+func (as *AuthService) RegisterAPIKeyProvider(name string, provider APIKeyProvider) {
+	as.registerProvider(AuthTypeAPIKey, name, provider)
+}
+
+// RegisterJWTProvider registers a JWT provider under a name; an empty name registers the default
+// provider
+func (as *AuthService) RegisterJWTProvider(name string, provider JWTProvider) {
+	as.registerProvider(AuthTypeJWT, name, provider)
+}
+
+// RegisterSAMLProvider registers a SAML provider under a name; an empty name registers the
+// default provider
+func (as *AuthService) RegisterSAMLProvider(name string, provider SAMLProvider) {
+	as.registerProvider(AuthTypeSAML, name, provider)
+}
+
+// registerProvider registers a provider of an auth type
+func (as *AuthService) registerProvider(authType, name string, provider interface{}) {
+	name = providerName(name)
+
+	as.lock.Lock()
+	defer as.lock.Unlock()
+	if as.providers[authType] == nil {
+		as.providers[authType] = make(map[string]interface{})
+	}
+	as.providers[authType][name] = provider
+}
+
+// provider returns the provider of an auth type registered under a name
+func (as *AuthService) provider(authType, name string) (interface{}, error) {
+	switch authType {
+	case AuthTypeOAuth, AuthTypeBasic, AuthTypeAPIKey, AuthTypeJWT, AuthTypeSAML:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAuthType, authType)
+	}
+	name = providerName(name)
+
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+	provider, ok := as.providers[authType][name]
+	if !ok {
+		return nil, fmt.Errorf("%w: no %s provider named %q", ErrAuthProviderNotFound, authType, name)
+	}
+	return provider, nil
+}
+
+// Authenticate authenticates with the provider of an auth type registered under a name, or the
+// default provider when name is empty. Basic auth reads the "username" and "password" entries of
+// data and API key auth its "apiKey" entry; other types receive the whole of data.
+func (as *AuthService) Authenticate(ctx context.Context, authType, name string, data map[string]string) (string, error) {
+	provider, err := as.provider(authType, name)
+	if err != nil {
+		return "", err
+	}
+
+	switch authType {
+	case AuthTypeOAuth:
+		return provider.(OAuthProvider).AuthenticateOAuth(ctx, data)
+	case AuthTypeBasic:
+		return provider.(BasicAuthProvider).AuthenticateBasic(ctx, data["username"], data["password"])
+	case AuthTypeAPIKey:
+		return provider.(APIKeyProvider).AuthenticateAPIKey(ctx, data["apiKey"])
+	case AuthTypeJWT:
+		return provider.(JWTProvider).AuthenticateJWT(ctx, data)
+	default:
+		return provider.(SAMLProvider).AuthenticateSAML(ctx, data)
+	}
+}
+
+// AuthenticateToken authenticates like Authenticate and returns the token with its metadata.
+// OAuth providers implementing OAuthTokenProvider report the expiry, scopes, and refresh token
+// of their tokens; other tokens never expire.
+func (as *AuthService) AuthenticateToken(ctx context.Context, authType, name string, data map[string]string) (*TokenInfo, error) {
+	var token *TokenInfo
+	provider, err := as.provider(authType, name)
+	if err != nil {
+		return nil, err
+	}
+	if tokenProvider, ok := provider.(OAuthTokenProvider); ok && authType == AuthTypeOAuth {
+		if token, err = tokenProvider.AuthenticateOAuthToken(ctx, data); err != nil {
+			return nil, err
+		}
+		token = token.Clone()
+	} else {
+		value, err := as.Authenticate(ctx, authType, name, data)
+		if err != nil {
+			return nil, err
+		}
+		token = &TokenInfo{Token: value}
+	}
+	token.Type = authType
+	token.Provider = providerName(name)
+	return token, nil
+}
+
+// providerName returns the name a provider is registered under, the default one for ""
+func providerName(name string) string {
+	if name == "" {
+		return DefaultAuthProvider
+	}
+	return name
+}
+
+// SetAuthService sets the service authenticating API integrations
+func (ah *APIHandler) SetAuthService(service *AuthService) {
+	ah.authService = service
+	ah.optionService.SetAuthService(service)
+}
@@ -0,0 +1,76 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthProviders(t *testing.T) {
+	fake := NewFakeAuthProvider().AddUser("ada", "secret").AddAPIKey("key-1")
+	google := NewFakeAuthProvider()
+	service := NewAuthService()
+	service.RegisterBasicAuthProvider("", fake)
+	service.RegisterAPIKeyProvider("", fake)
+	service.RegisterOAuthProvider("google", google)
+	service.RegisterSAMLProvider("", fake)
+
+	handler := NewAPIHandler()
+	handler.SetAuthService(service)
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	authenticate := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := authenticate("/api/auth/basic", `{"username": "ada", "password": "secret", "serviceId": "crm"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "fake-basic-1", response["token"])
+	token, ok := service.GetToken("crm")
+	assert.True(t, ok)
+	assert.Equal(t, "fake-basic-1", token)
+
+	rec = authenticate("/api/auth/basic", `{"username": "ada", "password": "wrong"}`)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = authenticate("/api/auth/apikey", `{"apiKey": "key-1"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Providers are named in the path or the body
+	rec = authenticate("/api/auth/oauth/google", `{"code": "abc"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = authenticate("/api/auth/oauth", `{"provider": "google", "code": "def"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, google.Calls(), 2)
+	assert.Equal(t, "abc", google.Calls()[0].Data["code"])
+
+	rec = authenticate("/api/auth/oauth", `{"code": "abc"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no oauth provider")
+	rec = authenticate("/api/auth/kerberos", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Saved SAML tokens are kept apart from other tokens
+	rec = authenticate("/api/auth/saml", `{"serviceId": "erp"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	_, ok = service.GetToken("erp")
+	assert.False(t, ok)
+	_, ok = service.GetSAMLToken("erp")
+	assert.True(t, ok)
+
+	fake.Err = errors.New("provider unavailable")
+	_, err := service.AuthenticateBasic("ada", "secret")
+	assert.EqualError(t, err, "provider unavailable")
+	_, err = service.AuthenticateJWT(map[string]string{})
+	assert.True(t, errors.Is(err, ErrAuthProviderNotFound))
+}
@@ -0,0 +1,184 @@
+package smartform
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when resuming a draft from a link
+var (
+	ErrResumeLinkInvalid = errors.New("invalid resume link")
+	ErrResumeLinkExpired = errors.New("resume link expired")
+	ErrAutosaveDisabled  = errors.New("drafts are disabled for this form")
+)
+
+// resumeTokenPlaceholder is replaced by the token in resume link templates
+const resumeTokenPlaceholder = "{token}"
+
+// AutosaveConfig configures the drafts anonymous users save of a form
+type AutosaveConfig struct {
+	Disabled         bool   `json:"disabled,omitempty"`         // Sensitive forms are never saved as drafts
+	RetentionSeconds int    `json:"retentionSeconds,omitempty"` // How long drafts and their resume links are kept (default: the session TTL)
+	ResumeURL        string `json:"resumeUrl,omitempty"`        // Resume link template with a {token} placeholder
+}
+
+// ResumeLink is a signed, expiring link to return to a draft
+type ResumeLink struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url,omitempty"` // The resume URL of the form with the token filled in
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Autosave keeps drafts of the form, and resume links to them, for a retention period, and
+// builds resume links from a template such as "https://apply.example.com/resume/{token}"
+func (fb *FormBuilder) Autosave(retention time.Duration, resumeURL string) *FormBuilder {
+	fb.schema.Autosave = &AutosaveConfig{
+		RetentionSeconds: int(retention / time.Second),
+		ResumeURL:        resumeURL,
+	}
+	return fb
+}
+
+// DisableAutosave keeps drafts of a sensitive form from being saved
+func (fb *FormBuilder) DisableAutosave() *FormBuilder {
+	fb.schema.Autosave = &AutosaveConfig{Disabled: true}
+	return fb
+}
+
+// autosaveDisabled reports whether drafts of the form may not be saved
+func (fs *FormSchema) autosaveDisabled() bool {
+	return fs.Autosave != nil && fs.Autosave.Disabled
+}
+
+// SetResumeLinkKey sets the key resume links are signed with. Without one, a random key is
+// used and links stop working when the process restarts.
+func (ah *APIHandler) SetResumeLinkKey(key []byte) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.resumeLinkKey = append([]byte(nil), key...)
+}
+
+// ResumeLink issues a signed link to return to a form session. It expires with the retention
+// period of the form.
+func (ah *APIHandler) ResumeLink(session *FormSession) (*ResumeLink, error) {
+	schema, ok := ah.GetSchema(session.FormID)
+	if !ok {
+		return nil, ErrFormSessionNotFound
+	}
+	if schema.autosaveDisabled() {
+		return nil, ErrAutosaveDisabled
+	}
+
+	expiresAt := ah.sessionExpiry(schema, time.Now()).Truncate(time.Second)
+	payload := session.ID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	link := &ResumeLink{
+		Token:     base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + ah.signResumeLink(payload),
+		ExpiresAt: expiresAt,
+	}
+	if schema.Autosave != nil && schema.Autosave.ResumeURL != "" {
+		link.URL = strings.ReplaceAll(schema.Autosave.ResumeURL, resumeTokenPlaceholder, link.Token)
+	}
+	return link, nil
+}
+
+// ResumeSession loads the form session a resume link was issued for
+func (ah *APIHandler) ResumeSession(token string) (*FormSession, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrResumeLinkInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(ah.signResumeLink(string(payload)))) {
+		return nil, ErrResumeLinkInvalid
+	}
+
+	sessionID, expiry, _ := strings.Cut(string(payload), ".")
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return nil, ErrResumeLinkInvalid
+	}
+	if time.Now().After(time.Unix(expiresAt, 0)) {
+		return nil, ErrResumeLinkExpired
+	}
+
+	session, err := ah.formSessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	schema, ok := ah.GetSchema(session.FormID)
+	if !ok {
+		return nil, ErrFormSessionNotFound
+	}
+	if schema.autosaveDisabled() {
+		return nil, ErrAutosaveDisabled
+	}
+	return session, nil
+}
+
+// signResumeLink signs the payload of a resume link
+func (ah *APIHandler) signResumeLink(payload string) string {
+	mac := hmac.New(sha256.New, ah.resumeKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resumeKey returns the key resume links are signed with, generating one if none is set
+func (ah *APIHandler) resumeKey() []byte {
+	ah.schemasLock.RLock()
+	key := ah.resumeLinkKey
+	ah.schemasLock.RUnlock()
+	if key != nil {
+		return key
+	}
+
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	if ah.resumeLinkKey == nil {
+		ah.resumeLinkKey = make([]byte, 32)
+		_, _ = rand.Read(ah.resumeLinkKey)
+	}
+	return ah.resumeLinkKey
+}
+
+// handleResume handles requests to return to a draft from a resume link
+func (ah *APIHandler) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.formSessions == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "Form sessions are not configured"))
+		return
+	}
+
+	session, err := ah.ResumeSession(getPathParam(r.URL.Path, "/api/resume/"))
+	switch {
+	case errors.Is(err, ErrResumeLinkExpired):
+		writeError(w, NewAPIError(http.StatusGone, ErrorCodeGone, "Resume link expired"))
+	case errors.Is(err, ErrResumeLinkInvalid):
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Form session not found"))
+	default:
+		ah.writeFormSession(w, session, err)
+	}
+}
+
+// writeResumeLink issues and writes a resume link for a form session
+func (ah *APIHandler) writeResumeLink(w http.ResponseWriter, session *FormSession) {
+	link, err := ah.ResumeLink(session)
+	if err != nil {
+		ah.writeFormSession(w, nil, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(link)
+}
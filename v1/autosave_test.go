@@ -0,0 +1,73 @@
+package smartform
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeLinks(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetResumeLinkKey([]byte("secret"))
+	require.NoError(t, handler.RegisterSchema(NewForm("grant", "Grant application").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		Autosave(30*24*time.Hour, "https://apply.example.com/resume/{token}").
+		Build()))
+	require.NoError(t, handler.RegisterSchema(NewForm("claim", "Medical claim").
+		AddField(NewFieldBuilder("diagnosis", FieldTypeText, "Diagnosis").Build()).
+		DisableAutosave().
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	var session FormSession
+	require.Equal(t, http.StatusCreated, sessionRequest(t, mux, http.MethodPost, "/api/sessions/grant", `{"name": "Ada"}`, &session))
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), session.ExpiresAt, time.Minute)
+
+	var link ResumeLink
+	require.Equal(t, http.StatusCreated, sessionRequest(t, mux, http.MethodPost, "/api/sessions/grant/"+session.ID+"/link", "", &link))
+	assert.Equal(t, "https://apply.example.com/resume/"+link.Token, link.URL)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), link.ExpiresAt, time.Minute)
+
+	var resumed FormSession
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodGet, "/api/resume/"+link.Token, "", &resumed))
+	assert.Equal(t, session.ID, resumed.ID)
+	assert.Equal(t, "Ada", resumed.Data["name"])
+
+	// Links are signed with the handler's key
+	forged := strings.Replace(link.Token, ".", "x.", 1)
+	assert.Equal(t, http.StatusNotFound, sessionRequest(t, mux, http.MethodGet, "/api/resume/"+forged, "", nil))
+	handler.SetResumeLinkKey([]byte("rotated"))
+	assert.Equal(t, http.StatusNotFound, sessionRequest(t, mux, http.MethodGet, "/api/resume/"+link.Token, "", nil))
+
+	// Sensitive forms are never saved
+	assert.Equal(t, http.StatusForbidden, sessionRequest(t, mux, http.MethodPost, "/api/sessions/claim", `{"diagnosis": "x"}`, nil))
+	_, err := handler.ResumeLink(&FormSession{ID: "abc", FormID: "claim"})
+	assert.Equal(t, ErrAutosaveDisabled, err)
+}
+
+func TestResumeLinkExpiry(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("grant", "Grant application").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		Autosave(time.Second, "").
+		Build()))
+
+	session := &FormSession{ID: "draft", FormID: "grant", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, handler.formSessions.Create(session))
+	link, err := handler.ResumeLink(session)
+	require.NoError(t, err)
+	assert.Empty(t, link.URL)
+
+	resumed, err := handler.ResumeSession(link.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "draft", resumed.ID)
+
+	time.Sleep(time.Until(link.ExpiresAt) + 10*time.Millisecond)
+	_, err = handler.ResumeSession(link.Token)
+	assert.Equal(t, ErrResumeLinkExpired, err)
+}
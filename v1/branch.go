@@ -0,0 +1,84 @@
+package smartform
+
+// FormLookup finds a registered form by its ID
+type FormLookup func(formID string) (*FormSchema, bool)
+
+// SetBranchForms makes the renderer resolve branch fields: each branch evaluates its condition
+// against the form state and inlines the fields of the form it leads to, looked up with lookup.
+// Branches to forms lookup does not find, or back to a form being rendered, are rendered as
+// references to the form.
+func (fr *FormRenderer) SetBranchForms(lookup FormLookup) {
+	fr.branchForms = lookup
+}
+
+// BranchTarget returns the form a branch field leads to for the form state, and whether its
+// condition holds. A branch without a condition always takes its true branch. The form ID is
+// empty when the branch taken leads nowhere.
+func (fs *FormSchema) BranchTarget(field *Field, state map[string]interface{}) (string, bool) {
+	taken := true
+	if condition := branchCondition(field); condition != nil {
+		taken = NewValidator(fs).evaluateCondition(condition, state)
+	}
+	key := "falseBranch"
+	if taken {
+		key = "trueBranch"
+	}
+	formID, _ := field.Properties[key].(string)
+	return formID, taken
+}
+
+// branchCondition returns the condition of a branch field, whether it was set with the builder
+// or imported from JSON
+func branchCondition(field *Field) *Condition {
+	switch condition := field.Properties["condition"].(type) {
+	case *Condition:
+		return condition
+	case map[string]interface{}:
+		var decoded Condition
+		if err := decodeRaw(condition, &decoded); err == nil {
+			return &decoded
+		}
+	}
+	return nil
+}
+
+// resolveBranch resolves a rendered branch field for the context: "branchTaken" tells whether
+// its condition holds and "branchForm" names the form it leads to, whose fields are inlined
+// into the branch when the form is found. Values of inlined fields are nested under the
+// branch's ID, like those of a group.
+func (fr *FormRenderer) resolveBranch(field, fieldCopy *Field, context map[string]interface{}) {
+	formID, taken := fr.schema.BranchTarget(field, context)
+	fieldCopy.Properties["branchTaken"] = taken
+	fieldCopy.Properties["branchInlined"] = false
+	if formID == "" {
+		return
+	}
+	fieldCopy.Properties["branchForm"] = formID
+
+	if fr.branchForms == nil || formID == fr.schema.ID {
+		return
+	}
+	for _, rendering := range fr.branching {
+		if rendering == formID {
+			return
+		}
+	}
+	target, ok := fr.branchForms(formID)
+	if !ok {
+		return
+	}
+
+	renderer := NewFormRenderer(target)
+	renderer.SetLocale(fr.locale)
+	renderer.SetAccess(fr.access)
+	renderer.branchForms = fr.branchForms
+	renderer.branching = append(append([]string{}, fr.branching...), fr.schema.ID)
+
+	values, _ := context[field.ID].(map[string]interface{})
+	rendered := renderer.copySchemaWithContext(overlayVariables(context, values))
+	fieldCopy.Nested = rendered.Fields
+	fieldCopy.Properties["branchInlined"] = true
+	if fieldCopy.Label == "" {
+		fieldCopy.Label = rendered.Title
+	}
+}
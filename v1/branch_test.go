@@ -0,0 +1,90 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchRendering(t *testing.T) {
+	start := NewForm("signup", "Sign up")
+	start.SelectField("accountType", "Account type")
+	start.BranchField("details", "Details").
+		Condition(When("accountType").Equals("business").Build()).
+		TrueBranch("business").
+		FalseBranch("personal")
+
+	business := NewForm("business", "Business")
+	business.TextField("company", "Company")
+	business.TextField("vatNumber", "VAT number").VisibleWhenExists("company")
+	business.BranchField("back", "Back").TrueBranch("signup")
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(start.Build()))
+	require.NoError(t, handler.RegisterSchema(business.Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	render := func(url string) *Field {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rendered struct {
+			Fields []*Field `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+		return (&FormSchema{Fields: rendered.Fields}).FindFieldByID("details")
+	}
+
+	details := render("/api/forms/signup?accountType=business")
+	require.NotNil(t, details)
+	assert.Equal(t, true, details.Properties["branchTaken"])
+	assert.Equal(t, "business", details.Properties["branchForm"])
+	assert.Equal(t, true, details.Properties["branchInlined"])
+	// The VAT number is hidden until a company is entered
+	require.Len(t, details.Nested, 2)
+	assert.Equal(t, "company", details.Nested[0].ID)
+
+	// Branches back to a form being rendered are references
+	back := details.Nested[1]
+	assert.Equal(t, "signup", back.Properties["branchForm"])
+	assert.Equal(t, false, back.Properties["branchInlined"])
+	assert.Empty(t, back.Nested)
+
+	// Forms that are not registered are references too
+	details = render("/api/forms/signup?accountType=personal")
+	assert.Equal(t, false, details.Properties["branchTaken"])
+	assert.Equal(t, "personal", details.Properties["branchForm"])
+	assert.Equal(t, false, details.Properties["branchInlined"])
+	assert.Empty(t, details.Nested)
+}
+
+func TestBranchTarget(t *testing.T) {
+	form := NewForm("order", "Order")
+	form.BranchField("shipping", "Shipping").
+		Condition(When("total").GreaterThan(float64(100)).Build()).
+		TrueBranch("freeShipping")
+	schema := form.Build()
+	field := schema.FindFieldByID("shipping")
+
+	formID, taken := schema.BranchTarget(field, map[string]interface{}{"total": float64(150)})
+	assert.Equal(t, "freeShipping", formID)
+	assert.True(t, taken)
+
+	formID, taken = schema.BranchTarget(field, map[string]interface{}{"total": float64(50)})
+	assert.Equal(t, "", formID)
+	assert.False(t, taken)
+
+	// Imported branches carry their condition as JSON
+	imported := &Field{ID: "shipping", Type: FieldTypeBranch, Properties: map[string]interface{}{
+		"condition":  map[string]interface{}{"type": "simple", "field": "total", "operator": "gt", "value": float64(100)},
+		"trueBranch": "freeShipping",
+	}}
+	formID, taken = schema.BranchTarget(imported, map[string]interface{}{"total": float64(150)})
+	assert.Equal(t, "freeShipping", formID)
+	assert.True(t, taken)
+}
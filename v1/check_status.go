@@ -0,0 +1,55 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// CheckStatus is the outcome of a readiness check
+type CheckStatus string
+
+// Define check statuses
+const (
+	CheckStatusOK      CheckStatus = "ok"      // Dependency responded as expected
+	CheckStatusFailed  CheckStatus = "failed"  // Dependency is unreachable or misconfigured
+	CheckStatusSkipped CheckStatus = "skipped" // Dependency could not be probed
+)
+
+// Values provides the possible values for CheckStatus, compatible with entgo.
+func (CheckStatus) Values() (types []string) {
+	return []string{
+		string(CheckStatusOK),
+		string(CheckStatusFailed),
+		string(CheckStatusSkipped),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (c CheckStatus) MarshalText() ([]byte, error) {
+	return []byte(c), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (c *CheckStatus) UnmarshalText(text []byte) error {
+	switch CheckStatus(text) {
+	case CheckStatusOK, CheckStatusFailed, CheckStatusSkipped:
+		*c = CheckStatus(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid CheckStatus: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (c *CheckStatus) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("CheckStatus should be a string, got %T", value)
+	}
+	return c.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (c CheckStatus) Value() (driver.Value, error) {
+	return string(c), nil
+}
@@ -0,0 +1,131 @@
+package smartform
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juicycleff/smartform/v1/template"
+)
+
+// NowVariable is the variable holding the instant a request is evaluated at. Templates read it
+// through now(), conditions can compare against it, and CEL expressions see it as now.
+const NowVariable = template.NowVariable
+
+// Clock tells the time to templates, conditions, and validation
+type Clock = template.Clock
+
+// SystemClock tells the wall-clock time
+type SystemClock = template.SystemClock
+
+// FakeClock is a Clock for tests that only moves when told to
+type FakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// NewFakeClock creates a fake clock telling the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the fake clock
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Set moves the fake clock to the given time
+func (c *FakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = now
+}
+
+// Advance moves the fake clock forward
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// requestTimeContextKey keys the time a request was received at in its context
+type requestTimeContextKey struct{}
+
+// RequestTime returns the time a request was received at, which every template, condition,
+// and validation rule evaluated for the request sees as the current time
+func RequestTime(ctx context.Context) (time.Time, bool) {
+	now, ok := ctx.Value(requestTimeContextKey{}).(time.Time)
+	return now, ok
+}
+
+// SetClock sets the clock requests are timestamped with
+func (ah *APIHandler) SetClock(clock Clock) {
+	ah.clock = clock
+}
+
+// now returns the time of the handler's clock
+func (ah *APIHandler) now() time.Time {
+	if ah.clock == nil {
+		return time.Now()
+	}
+	return ah.clock.Now()
+}
+
+// requestTime returns the time a request was received at, or the current time outside of one
+func (ah *APIHandler) requestTime(ctx context.Context) time.Time {
+	if ctx != nil {
+		if now, ok := RequestTime(ctx); ok {
+			return now
+		}
+	}
+	return ah.now()
+}
+
+// timestampMiddleware freezes the time a request is evaluated at when it is received
+func (ah *APIHandler) timestampMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := RequestTime(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestTimeContextKey{}, ah.now())))
+	})
+}
+
+// SetClock sets the clock templates and conditions read the time from when they are not given
+// one, e.g. when the form is rendered outside of a request
+func (fs *FormSchema) SetClock(clock Clock) {
+	fs.clock = clock
+}
+
+// WithClock sets the clock the form's templates and conditions read the time from when they
+// are not given one
+func (fb *FormBuilder) WithClock(clock Clock) *FormBuilder {
+	fb.schema.clock = clock
+	return fb
+}
+
+// now returns the time of the schema's clock
+func (fs *FormSchema) now() time.Time {
+	if fs.clock == nil {
+		return time.Now()
+	}
+	return fs.clock.Now()
+}
+
+// evaluationTime returns the time a validation run is evaluated at: the NowVariable of its
+// variables, or else the time of its clock
+func (o *ValidationOptions) evaluationTime() time.Time {
+	if o != nil {
+		if now, ok := o.Variables[NowVariable].(time.Time); ok {
+			return now
+		}
+		if o.Clock != nil {
+			return o.Clock.Now()
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,99 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC))
+	handler := NewAPIHandler()
+	handler.SetClock(clock)
+
+	form := NewForm("invoice", "Invoice")
+	form.TextField("issued", "Issued").DefaultValue("${formatDate(now())}")
+	form.NumberField("lateFee", "Late fee").
+		RequiredIf(WithExpression(`now > timestamp("2026-02-15T00:00:00Z")`).Build())
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	// Templates see the time of the handler's clock
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/invoice", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var rendered struct {
+		Fields []*Field `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	assert.Equal(t, "2026-03-01", rendered.Fields[0].DefaultValue)
+
+	// So do the conditions of validation rules
+	validate := func() bool {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/invoice", strings.NewReader(`{}`)))
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result.Valid
+	}
+	assert.False(t, validate())
+	clock.Set(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	assert.True(t, validate())
+
+	// Clients cannot choose the time requests are evaluated at
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/invoice?_now=2030-01-01", nil))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	assert.Equal(t, "2026-02-01", rendered.Fields[0].DefaultValue)
+}
+
+// countingClock advances by a second every time it is read
+type countingClock struct {
+	now   time.Time
+	reads int
+}
+
+func (c *countingClock) Now() time.Time {
+	c.reads++
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func TestClockFrozenPerEvaluation(t *testing.T) {
+	clock := &countingClock{now: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	// Every condition of a compound condition sees the same time
+	evaluator := NewConditionEvaluator()
+	evaluator.SetTemplateEngine(NewDefaultTemplateEvaluator(nil).Engine)
+	evaluator.Clock = clock
+	stamp := `${formatDate(now(), "15:04:05")}`
+	condition := And(
+		When("first").Equals(stamp).Build(),
+		When("second").Equals(stamp).Build(),
+	).Build()
+	ctx := NewEvaluationContext()
+	ctx.AddField("first", "00:00:01")
+	ctx.AddField("second", "00:00:01")
+	matched, err := evaluator.Evaluate(condition, ctx)
+	require.NoError(t, err)
+	assert.True(t, matched)
+	assert.Equal(t, 1, clock.reads)
+
+	// Validation runs read their clock once
+	form := NewForm("window", "Window")
+	form.TextField("note", "Note").
+		RequiredIf(Or(
+			WithExpression(`now == timestamp("2026-03-01T00:00:03Z")`).Build(),
+			WithExpression(`now == timestamp("2026-03-01T00:00:02Z")`).Build(),
+		).Build())
+	result := form.Build().ValidateWithOptions(map[string]interface{}{}, &ValidationOptions{Clock: clock})
+	assert.False(t, result.Valid)
+	assert.Equal(t, 2, clock.reads)
+}
@@ -0,0 +1,249 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// compiledOrder stands in for the order of pre-serialized fields, which is only known once the
+// fields rendered with them are
+const compiledOrder = math.MinInt32 + 7
+
+// compiledFieldsID stands in for the fields of the pre-serialized schema
+const compiledFieldsID = "\x00compiled-fields\x00"
+
+// compiledFieldIndent is the indentation of the top-level fields in rendered JSON, which are
+// pre-serialized with it so the JSON joined from them need not be indented again
+const compiledFieldIndent = "    "
+
+// CompiledSchema is a schema pre-serialized for rendering. Fields that cannot depend on the
+// render context, having no conditions, templates, computed values, dependent options,
+// branches, or roles, are serialized once per locale; only the other fields are rendered for
+// each request, and the parts are joined into the JSON the renderer would produce.
+type CompiledSchema struct {
+	schema  *FormSchema
+	fields  []*Field // Top-level fields the schema was compiled with
+	locales sync.Map // Pre-serialized parts by locale, *compiledLocale
+}
+
+// compiledLocale holds the pre-serialized parts of a schema rendered in a locale
+type compiledLocale struct {
+	head, tail []byte           // JSON of the schema around its fields; nil when it cannot be split
+	fields     []*compiledField // By index of the top-level field; nil for fields rendered per request
+}
+
+// compiledField holds the JSON of a field around its order. Nested fields are ordered anew
+// when a top-level field has an order, so the field is kept both ways.
+type compiledField struct {
+	plain   compiledJSON
+	ordered compiledJSON // With the orders of nested fields set
+}
+
+// compiledJSON is JSON split around a placeholder
+type compiledJSON struct {
+	head, tail []byte
+}
+
+// Compile pre-serializes the schema for rendering, see CompiledSchema, and makes renderers of
+// the schema use the result. Compile the schema again after changing its fields; renderers
+// ignore compiled forms whose fields were added or removed since.
+func (fs *FormSchema) Compile() (*CompiledSchema, error) {
+	compiled := &CompiledSchema{schema: fs, fields: append([]*Field(nil), fs.Fields...)}
+	if _, err := compiled.locale(NewFormRenderer(fs)); err != nil {
+		return nil, err
+	}
+	fs.compiled = compiled
+	return compiled, nil
+}
+
+// Compiled returns what the schema was last compiled to, or nil
+func (fs *FormSchema) Compiled() *CompiledSchema {
+	return fs.compiled
+}
+
+// StaticFields returns the IDs of the top-level fields pre-serialized in the default locale
+func (cs *CompiledSchema) StaticFields() []string {
+	parts, err := cs.locale(NewFormRenderer(cs.schema))
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for i, field := range parts.fields {
+		if field != nil {
+			ids = append(ids, cs.fields[i].ID)
+		}
+	}
+	return ids
+}
+
+// current reports whether the schema still has the fields it was compiled with
+func (cs *CompiledSchema) current() bool {
+	if len(cs.schema.Fields) != len(cs.fields) {
+		return false
+	}
+	for i, field := range cs.fields {
+		if cs.schema.Fields[i] != field {
+			return false
+		}
+	}
+	return true
+}
+
+// locale returns the parts pre-serialized for the locale of a renderer, serializing them the
+// first time the locale is rendered
+func (cs *CompiledSchema) locale(fr *FormRenderer) (*compiledLocale, error) {
+	if parts, ok := cs.locales.Load(fr.locale); ok {
+		return parts.(*compiledLocale), nil
+	}
+
+	parts := &compiledLocale{fields: make([]*compiledField, len(cs.fields))}
+	header := fr.copySchemaHeader()
+	header.Fields = []*Field{{ID: compiledFieldsID}}
+	data, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("compiling form %s: %w", cs.schema.ID, err)
+	}
+	placeholder, _ := json.MarshalIndent(header.Fields[0], compiledFieldIndent, "  ")
+	if bytes.Count(data, placeholder) == 1 {
+		index := bytes.Index(data, placeholder)
+		parts.head, parts.tail = data[:index], data[index+len(placeholder):]
+	}
+
+	for i, field := range cs.fields {
+		if !fr.contextFree(field, field.ID) {
+			continue
+		}
+		compiled := &compiledField{}
+		for _, ordered := range []bool{false, true} {
+			fieldCopy := fr.copyFieldWithContext(field, field.ID, map[string]interface{}{})
+			if ordered {
+				ensureNestedFieldsHaveOrder(fieldCopy.Nested)
+			}
+			sortNestedFields(fieldCopy.Nested)
+			fieldCopy.Order = compiledOrder
+
+			data, err := json.MarshalIndent(fieldCopy, compiledFieldIndent, "  ")
+			if err != nil {
+				return nil, fmt.Errorf("compiling field %s of form %s: %w", field.ID, cs.schema.ID, err)
+			}
+			key, value := []byte(`"order": `), []byte(strconv.Itoa(compiledOrder))
+			if bytes.Count(data, append(key, value...)) != 1 {
+				compiled = nil
+				break
+			}
+			index := bytes.Index(data, append(key, value...)) + len(key)
+			split := compiledJSON{head: data[:index], tail: data[index+len(value):]}
+			if ordered {
+				compiled.ordered = split
+			} else {
+				compiled.plain = split
+			}
+		}
+		parts.fields[i] = compiled
+	}
+
+	stored, _ := cs.locales.LoadOrStore(fr.locale, parts)
+	return stored.(*compiledLocale), nil
+}
+
+// contextFree reports whether a field renders the same whatever the context and whoever it is
+// rendered for
+func (fr *FormRenderer) contextFree(field *Field, path string) bool {
+	if field.Visible != nil || field.Enabled != nil || len(field.DefaultWhen) > 0 || field.ComputedValue != "" ||
+		field.Type == FieldTypeBranch || len(field.ReadRoles) > 0 || len(field.WriteRoles) > 0 {
+		return false
+	}
+	if field.Options != nil && field.Options.Dependency != nil {
+		return false
+	}
+	for _, text := range []string{
+		fr.translate(path+".label", field.Label),
+		fr.translate(path+".placeholder", field.Placeholder),
+		fr.translate(path+".helpText", field.HelpText),
+	} {
+		if fr.evaluator.IsTemplate(text) {
+			return false
+		}
+	}
+	if field.Help != nil && fr.evaluator.IsTemplate(field.Help.Markdown) {
+		return false
+	}
+	if value, ok := field.DefaultValue.(string); ok && fr.evaluator.IsTemplate(value) {
+		return false
+	}
+	for _, nested := range field.Nested {
+		if !fr.contextFree(nested, joinPath(path, nested.ID)) {
+			return false
+		}
+	}
+	return true
+}
+
+// render renders the schema for a context from its pre-serialized parts, rendering only the
+// fields depending on the context. It returns false when the compiled form is out of date.
+func (cs *CompiledSchema) render(fr *FormRenderer, context map[string]interface{}) ([]byte, bool, error) {
+	if fr.diagnostics != nil || !cs.current() {
+		return nil, false, nil
+	}
+	parts, err := cs.locale(fr)
+	if err != nil || parts.head == nil {
+		return nil, false, err
+	}
+	context = fr.renderState(context)
+
+	// Pre-serialized fields are stood in for by fields carrying only their order, so that the
+	// fields are ordered exactly as the renderer orders them
+	fields := make([]*Field, 0, len(cs.fields))
+	compiled := make(map[*Field]*compiledField)
+	ordered := false
+	for i, field := range cs.fields {
+		if parts.fields[i] != nil {
+			stand := &Field{Order: field.Order}
+			compiled[stand] = parts.fields[i]
+			fields = append(fields, stand)
+		} else {
+			if !fr.canRead(field) {
+				continue
+			}
+			if field.Visible != nil && !NewValidator(fr.schema).evaluateCondition(field.Visible, context) {
+				continue
+			}
+			fields = append(fields, fr.copyFieldWithContext(field, field.ID, context))
+		}
+		ordered = ordered || field.Order > 0
+	}
+	if len(fields) == 0 {
+		return nil, false, nil // Rendered as an empty array, which the parts cannot be joined into
+	}
+	sorted := &FormSchema{Fields: fields}
+	sorted.SortFields()
+
+	var buffer bytes.Buffer
+	buffer.Write(parts.head)
+	for i, field := range sorted.Fields {
+		if i > 0 {
+			buffer.WriteString(",\n" + compiledFieldIndent)
+		}
+		if pre, ok := compiled[field]; ok {
+			split := pre.plain
+			if ordered {
+				split = pre.ordered
+			}
+			buffer.Write(split.head)
+			buffer.WriteString(strconv.Itoa(field.Order))
+			buffer.Write(split.tail)
+			continue
+		}
+		data, err := json.MarshalIndent(field, compiledFieldIndent, "  ")
+		if err != nil {
+			return nil, false, err
+		}
+		buffer.Write(data)
+	}
+	buffer.Write(parts.tail)
+	return buffer.Bytes(), true, nil
+}
@@ -0,0 +1,144 @@
+package smartform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCompiledForm returns a form mixing fields that depend on the render context with fields
+// that do not, explicitly ordered or not
+func newCompiledForm(ordered bool) *FormSchema {
+	form := NewForm("checkout", "Checkout").Description("Pay for ${plan}")
+	order := func(n int) int {
+		if ordered {
+			return n
+		}
+		return 0
+	}
+	form.TextField("name", "Name").Placeholder("Jane Doe").Required(true).Order(order(3))
+	form.TextField("greeting", "Hello ${name}").Order(order(1))
+	form.NumberField("seats", "Seats").DefaultValue(1)
+	form.NumberField("total", "Total").ComputedValue("${multiply(seats, 10)}")
+	form.TextField("coupon", "Coupon").VisibleWhenEquals("plan", "pro").Order(order(2))
+	form.NumberField("salary", "Salary").ReadRoles("hr")
+	form.SelectField("country", "Country").AddOption("NG", "Nigeria").AddOption("DE", "Germany")
+	form.SelectField("region", "Region").
+		WithDependentOptions("country", map[string][]*Option{"NG": {{Value: "south", Label: "South"}}})
+	address := form.GroupField("address", "Address")
+	address.Order(order(5))
+	address.TextField("street", "Street")
+	address.TextField("city", "City").Order(order(1))
+	form.TranslationBundle("fr", map[string]string{"name.label": "Nom", "seats.label": "Places"})
+	return form.Build()
+}
+
+func TestCompiledSchemaRendersLikeSchema(t *testing.T) {
+	contexts := []map[string]interface{}{
+		nil,
+		{"plan": "pro", "name": "Ada", "seats": 3, "country": "NG"},
+		{"plan": "free", "country": "DE"},
+	}
+	for _, ordered := range []bool{false, true} {
+		schema := newCompiledForm(ordered)
+		compiled := newCompiledForm(ordered)
+		_, err := compiled.Compile()
+		require.NoError(t, err)
+
+		for _, locale := range []string{"", "fr"} {
+			for _, access := range []*AccessContext{nil, {Roles: []string{"hr"}}} {
+				for i, context := range contexts {
+					name := fmt.Sprintf("ordered=%v locale=%q access=%v context=%d", ordered, locale, access != nil, i)
+					want := NewFormRenderer(schema)
+					got := NewFormRenderer(compiled)
+					want.SetLocale(locale)
+					got.SetLocale(locale)
+					if access != nil {
+						want.SetAccess(access)
+						got.SetAccess(access)
+					}
+					expected, err := want.RenderJSONWithContext(context)
+					require.NoError(t, err, name)
+					actual, err := got.RenderJSONWithContext(context)
+					require.NoError(t, err, name)
+					assert.Equal(t, expected, actual, name)
+					_, joined, _ := compiled.Compiled().render(got, context)
+					assert.True(t, joined, "%s is rendered from the compiled form", name)
+				}
+			}
+		}
+	}
+}
+
+func TestCompiledSchemaStaticFields(t *testing.T) {
+	schema := newCompiledForm(false)
+	compiled, err := schema.Compile()
+	require.NoError(t, err)
+	assert.Same(t, compiled, schema.Compiled())
+	assert.Equal(t, []string{"name", "seats", "country", "address"}, compiled.StaticFields())
+	assert.Nil(t, schema.Clone().Compiled())
+
+	// Fields added after compiling are rendered, since the compiled form is out of date
+	schema.Fields = append(schema.Fields, NewFieldBuilder("notes", FieldTypeText, "Notes").Build())
+	rendered, err := NewFormRenderer(schema).RenderJSONWithContext(nil)
+	require.NoError(t, err)
+	assert.Contains(t, rendered, `"notes"`)
+}
+
+func TestRegisterCompiledSchema(t *testing.T) {
+	schema := newCompiledForm(true)
+	_, err := schema.Compile()
+	require.NoError(t, err)
+
+	handler := NewAPIHandler(WithTelemetry(newRecordingTelemetry()))
+	require.NoError(t, handler.RegisterSchema(schema))
+	registered, ok := handler.GetSchema("checkout")
+	require.True(t, ok)
+	require.NotNil(t, registered.Compiled(), "copies of compiled schemas are compiled again")
+	assert.NotSame(t, schema.Compiled(), registered.Compiled())
+
+	require.NoError(t, handler.RegisterSchema(newCompiledForm(false)))
+	registered, _ = handler.GetSchema("checkout")
+	assert.Nil(t, registered.Compiled())
+}
+
+// newLargeForm returns a form of mostly static fields, with a context-dependent field in ten
+func newLargeForm(fields int) *FormSchema {
+	form := NewForm("large", "Large form")
+	for i := 0; i < fields; i++ {
+		id := fmt.Sprintf("field%d", i)
+		switch {
+		case i%10 == 0:
+			form.TextField(id, "Field ${plan}").VisibleWhenExists("plan")
+		case i%5 == 0:
+			form.SelectField(id, "Choice").AddOption("a", "A").AddOption("b", "B").AddOption("c", "C")
+		default:
+			form.TextField(id, fmt.Sprintf("Field %d", i)).Placeholder("Type here").Required(i%2 == 0)
+		}
+	}
+	return form.Build()
+}
+
+func BenchmarkRenderJSONWithContext(b *testing.B) {
+	context := map[string]interface{}{"plan": "pro"}
+	for _, compile := range []bool{false, true} {
+		schema := newLargeForm(200)
+		if compile {
+			if _, err := schema.Compile(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.Run(fmt.Sprintf("compiled=%v", compile), func(b *testing.B) {
+			renderer := NewFormRenderer(schema)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := renderer.RenderJSONWithContext(context); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package smartform
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Supported response encodings
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// DefaultCompressionMinSize is the smallest response body worth compressing
+const DefaultCompressionMinSize = 1024
+
+// CompressionMiddleware compresses JSON responses with brotli or gzip, depending on what the
+// client accepts. Responses smaller than minSize bytes are sent uncompressed.
+func CompressionMiddleware(next http.Handler, minSize int) http.Handler {
+	if minSize < 0 {
+		minSize = 0
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressionWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        minSize,
+			status:         http.StatusOK,
+		}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the preferred supported encoding from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+	best := ""
+	bestQuality := 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, quality := parseEncoding(part)
+		if quality <= 0 {
+			continue
+		}
+
+		switch name {
+		case EncodingBrotli, EncodingGzip, "*":
+		default:
+			continue
+		}
+
+		if name == "*" {
+			name = EncodingBrotli
+		}
+
+		// Prefer brotli when qualities are equal
+		if quality > bestQuality || (quality == bestQuality && name == EncodingBrotli) {
+			best = name
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// parseEncoding parses a single Accept-Encoding entry such as "gzip;q=0.8"
+func parseEncoding(part string) (string, float64) {
+	segments := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(segments[0]))
+	quality := 1.0
+
+	for _, param := range segments[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+				quality = q
+			}
+		}
+	}
+
+	return name, quality
+}
+
+// compressionWriter buffers the start of a response until it knows whether compressing is worthwhile
+type compressionWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buffer   []byte
+	encoder  io.WriteCloser
+	decided  bool
+}
+
+// WriteHeader records the status code until the compression decision is made
+func (cw *compressionWriter) WriteHeader(status int) {
+	if cw.decided {
+		return
+	}
+	cw.status = status
+}
+
+// Write buffers or compresses response data
+func (cw *compressionWriter) Write(data []byte) (int, error) {
+	if !cw.decided {
+		cw.buffer = append(cw.buffer, data...)
+		if len(cw.buffer) < cw.minSize {
+			return len(data), nil
+		}
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(data)
+	}
+	return cw.ResponseWriter.Write(data)
+}
+
+// Flush sends buffered data to the client
+func (cw *compressionWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide()
+	}
+
+	switch encoder := cw.encoder.(type) {
+	case *gzip.Writer:
+		_ = encoder.Flush()
+	case *brotli.Writer:
+		_ = encoder.Flush()
+	}
+
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response
+func (cw *compressionWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// decide writes the headers, choosing compression only for large enough JSON responses
+func (cw *compressionWriter) decide() error {
+	cw.decided = true
+	header := cw.ResponseWriter.Header()
+
+	compress := len(cw.buffer) >= cw.minSize &&
+		len(cw.buffer) > 0 &&
+		header.Get("Content-Encoding") == "" &&
+		strings.Contains(header.Get("Content-Type"), "json") &&
+		cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified
+
+	if compress {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+
+		switch cw.encoding {
+		case EncodingBrotli:
+			cw.encoder = brotli.NewWriterLevel(cw.ResponseWriter, brotli.DefaultCompression)
+		default:
+			cw.encoder = gzip.NewWriter(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buffered := cw.buffer
+	cw.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(buffered)
+	} else {
+		_, err = cw.ResponseWriter.Write(buffered)
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package smartform
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, EncodingBrotli, negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, EncodingGzip, negotiateEncoding("br;q=0.5, gzip"))
+	assert.Equal(t, EncodingGzip, negotiateEncoding("br;q=0, gzip;q=0.1"))
+	assert.Equal(t, "", negotiateEncoding("identity"))
+	assert.Equal(t, "", negotiateEncoding(""))
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	payload := `{"items":"` + strings.Repeat("option,", 500) + `"}`
+	handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("small") != "" {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}), 256)
+
+	request := func(url, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("/", "gzip")
+	require.Equal(t, EncodingGzip, rec.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+
+	rec = request("/", "br")
+	require.Equal(t, EncodingBrotli, rec.Header().Get("Content-Encoding"))
+	body, err = io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(body))
+
+	rec = request("/?small=1", "gzip")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{}`, rec.Body.String())
+}
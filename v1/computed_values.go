@@ -0,0 +1,249 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ComputedValues holds the values of the computed fields of a form, recomputed for a form state
+type ComputedValues struct {
+	Values map[string]interface{} `json:"values"`           // By field path, e.g. "items[0].total"
+	Errors map[string]string      `json:"errors,omitempty"` // Why values could not be computed, by field path
+}
+
+// computedField is a field with a computed value, located in form data
+type computedField struct {
+	field      *Field
+	path       string   // Path with array items marked "[]", e.g. "items[].total"
+	references []string // Paths the expression refers to
+}
+
+// referencePattern matches dotted identifiers that may refer to fields in expressions, with
+// the parenthesis following function names
+var referencePattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*\s*\(?`)
+
+// expressionKeywords are identifiers of expressions that are literals rather than references
+var expressionKeywords = map[string]bool{"true": true, "false": true, "null": true}
+
+// stringLiteralPattern matches quoted strings in expressions
+var stringLiteralPattern = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+
+// templateReferences returns the dotted identifiers inside the ${...} expressions of a template,
+// leaving out function names, string literals, and keywords
+func templateReferences(template string) []string {
+	references := []string{}
+	for _, expression := range templatePattern.FindAllString(template, -1) {
+		expression = stringLiteralPattern.ReplaceAllString(expression, "")
+		for _, reference := range referencePattern.FindAllString(expression, -1) {
+			reference = strings.TrimSpace(reference)
+			if strings.HasSuffix(reference, "(") || expressionKeywords[reference] {
+				continue
+			}
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// collectComputedFields lists the fields with a computed value in definition order
+func collectComputedFields(fields []*Field, prefix string, computed []*computedField) []*computedField {
+	for _, field := range fields {
+		path := joinPath(prefix, field.ID)
+		if field.ComputedValue != "" {
+			computed = append(computed, &computedField{
+				field:      field,
+				path:       path,
+				references: templateReferences(field.ComputedValue),
+			})
+		}
+		if field.Type == FieldTypeArray {
+			computed = collectComputedFields(field.Nested, path+"[]", computed)
+		} else {
+			computed = collectComputedFields(field.Nested, path, computed)
+		}
+	}
+	return computed
+}
+
+// refersTo reports whether a computed value refers to another one, by its full path or, for
+// fields of the same group or array item, by its ID
+func (cf *computedField) refersTo(other *computedField) bool {
+	fullPath := strings.ReplaceAll(other.path, "[]", "")
+	sibling := parentPath(cf.path) == parentPath(other.path)
+	for _, reference := range cf.references {
+		if reference == fullPath || (sibling && reference == other.field.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// parentPath returns the path of the container of a path
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// computeOrder returns the computed fields of the schema, each after the computed fields its
+// expression refers to. It fails when computed values depend on each other in a cycle.
+func (fs *FormSchema) computeOrder() ([]*computedField, error) {
+	computed := collectComputedFields(fs.Fields, "", nil)
+	order := make([]*computedField, 0, len(computed))
+	visiting := map[*computedField]bool{}
+	done := map[*computedField]bool{}
+
+	var visit func(cf *computedField, chain []string) error
+	visit = func(cf *computedField, chain []string) error {
+		chain = append(chain, cf.path)
+		if visiting[cf] {
+			return fmt.Errorf("computed value of %s depends on itself: %s", cf.path, strings.Join(chain, " -> "))
+		}
+		if done[cf] {
+			return nil
+		}
+		visiting[cf] = true
+		for _, other := range computed {
+			if other != cf && cf.refersTo(other) {
+				if err := visit(other, chain); err != nil {
+					return err
+				}
+			}
+		}
+		visiting[cf] = false
+		done[cf] = true
+		order = append(order, cf)
+		return nil
+	}
+
+	for _, cf := range computed {
+		if err := visit(cf, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// CheckComputedValues checks that no computed value depends on itself
+func (fs *FormSchema) CheckComputedValues() error {
+	_, err := fs.computeOrder()
+	return err
+}
+
+// ComputeValues evaluates the computed values of the form against a form state, in dependency
+// order so that computed values may refer to each other. State is not modified. Computed
+// fields of array items are evaluated for each item, in the scope array item defaults are:
+// the item's fields over the rest of the state, with "item", "parent", and "index".
+func (fs *FormSchema) ComputeValues(state map[string]interface{}) (*ComputedValues, error) {
+	state = cloneMap(state)
+	if state == nil {
+		state = map[string]interface{}{}
+	}
+	return fs.computeValuesInto(state)
+}
+
+// computeValuesInto evaluates the computed values of the form, storing them in state
+func (fs *FormSchema) computeValuesInto(state map[string]interface{}) (*ComputedValues, error) {
+	order, err := fs.computeOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ComputedValues{Values: map[string]interface{}{}, Errors: map[string]string{}}
+	evaluator := fs.TemplateEvaluator()
+	for _, cf := range order {
+		segments := strings.Split(cf.path, "[].")
+		fs.computeValueAt(evaluator, cf.field, state, state, segments, "", result)
+	}
+	return result, nil
+}
+
+// computeValueAt evaluates a computed value inside container, walking down the array items
+// the remaining path segments go through
+func (fs *FormSchema) computeValueAt(evaluator TemplateEvaluator, field *Field, container, scope map[string]interface{}, segments []string, prefix string, result *ComputedValues) {
+	if len(segments) == 1 {
+		path := joinPath(prefix, segments[0])
+		value, err := evaluator.Evaluate(field.ComputedValue, scope)
+		if err != nil {
+			result.Errors[path] = err.Error()
+			return
+		}
+		if err := setValueByPath(container, segments[0], value); err != nil {
+			result.Errors[path] = err.Error()
+			return
+		}
+		result.Values[path] = value
+		return
+	}
+
+	items, _ := NewValidator(fs).getValueByPath(container, segments[0]).([]interface{})
+	for i, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// Item values take precedence, so siblings are referred to by their ID
+		fs.computeValueAt(evaluator, field, itemMap, itemScope(scope, itemMap, container, i), segments[1:],
+			fmt.Sprintf("%s[%d]", joinPath(prefix, segments[0]), i), result)
+	}
+}
+
+// applyComputedValue sets the value computed for a field in the context being rendered as its
+// default, keeping the expression so clients can recompute it. Fields of array items are
+// computed by clients for each item.
+func (fr *FormRenderer) applyComputedValue(field *Field, path string, fieldCopy *Field) {
+	if field.ComputedValue == "" {
+		return
+	}
+	fieldCopy.ComputedValue = field.ComputedValue
+	if fr.computed == nil {
+		return
+	}
+
+	provenance := &ValueProvenance{Source: ProvenanceExpression, Template: field.ComputedValue}
+	if message, failed := fr.computed.Errors[path]; failed {
+		provenance.Error = message
+	} else if value, ok := fr.computed.Values[path]; ok {
+		fieldCopy.DefaultValue = value
+		provenance.Value = value
+	} else {
+		return
+	}
+	fr.recordProvenance(path, provenance)
+}
+
+// handleCompute handles requests for the computed values of a form given a form state
+func (ah *APIHandler) handleCompute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	formID := getPathParam(r.URL.Path, "/api/compute/")
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var request struct {
+		State map[string]interface{} `json:"state"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	computed, err := schema.ComputeValues(ah.withRenderContext(r, request.State))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(computed)
+}
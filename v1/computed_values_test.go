@@ -0,0 +1,91 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputedValues(t *testing.T) {
+	form := NewForm("order", "Order")
+	// Defined before the value it depends on, which is computed first regardless
+	form.NumberField("total", "Total").ComputedValue("${add(subtotal, shipping)}")
+	form.NumberField("subtotal", "Subtotal").ComputedValue("${multiply(price, quantity)}")
+	form.NumberField("price", "Price")
+	form.NumberField("quantity", "Quantity")
+	form.NumberField("shipping", "Shipping")
+	items := form.ArrayField("items", "Items")
+	items.NumberField("price", "Price")
+	items.NumberField("quantity", "Quantity")
+	items.NumberField("lineTotal", "Line total").ComputedValue("${multiply(price, quantity)}")
+	schema := form.Build()
+
+	state := map[string]interface{}{
+		"price":    4.0,
+		"quantity": 3.0,
+		"shipping": 5.0,
+		"items": []interface{}{
+			map[string]interface{}{"price": 2.0, "quantity": 5.0},
+			map[string]interface{}{"price": 1.5, "quantity": 2.0},
+		},
+	}
+	computed, err := schema.ComputeValues(state)
+	require.NoError(t, err)
+	assert.Empty(t, computed.Errors)
+	assert.EqualValues(t, 12, computed.Values["subtotal"])
+	assert.EqualValues(t, 17, computed.Values["total"])
+	assert.EqualValues(t, 10, computed.Values["items[0].lineTotal"])
+	assert.EqualValues(t, 3, computed.Values["items[1].lineTotal"])
+	assert.NotContains(t, state, "total")
+
+	// The renderer sends computed values as defaults, with their expressions
+	rendered, err := NewFormRenderer(schema).RenderJSONWithContext(state)
+	require.NoError(t, err)
+	var renderedForm struct {
+		Fields []*Field `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(rendered), &renderedForm))
+	total := renderedForm.Fields[0]
+	assert.EqualValues(t, 17, total.DefaultValue)
+	assert.Equal(t, "${add(subtotal, shipping)}", total.ComputedValue)
+
+	// Computed values depending on each other in a cycle are rejected at registration
+	cyclic := NewForm("cyclic", "Cyclic")
+	cyclic.NumberField("a", "A").ComputedValue("${add(b, 1)}")
+	cyclic.NumberField("b", "B").ComputedValue("${add(a, 1)}")
+	err = NewAPIHandler().RegisterSchema(cyclic.Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "computed value of a depends on itself: a -> b -> a")
+}
+
+func TestComputeEndpoint(t *testing.T) {
+	form := NewForm("quote", "Quote")
+	form.NumberField("hours", "Hours")
+	form.NumberField("rate", "Rate")
+	form.NumberField("cost", "Cost").ComputedValue("${multiply(hours, rate)}")
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/compute/quote", strings.NewReader(`{"state": {"hours": 3, "rate": 40}}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var computed ComputedValues
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &computed))
+	assert.EqualValues(t, 120, computed.Values["cost"])
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/compute/missing", strings.NewReader(`{"state": {}}`)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/compute/quote", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
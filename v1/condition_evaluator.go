@@ -15,12 +15,16 @@ import (
 type ConditionEvaluator struct {
 	// TemplateEngine for resolving template expressions in condition fields
 	TemplateEngine *template.TemplateEngine
+	// Templates evaluates template expressions in place of TemplateEngine when set
+	Templates TemplateEvaluator
 	// CustomFunctions allows registration of custom functions for expressions
 	CustomFunctions map[string]func(args ...interface{}) (interface{}, error)
 	// CaseSensitive determines if string comparisons are case sensitive
 	CaseSensitive bool
 	// EnableTemplateFields determines if fields should be evaluated as templates
 	EnableTemplateFields bool
+	// Clock tells the time templates see as now() when the context carries no NowVariable
+	Clock Clock
 }
 
 // NewConditionEvaluator creates a new condition evaluator with default settings
@@ -37,6 +41,22 @@ func (ce *ConditionEvaluator) SetTemplateEngine(engine *template.TemplateEngine)
 	ce.TemplateEngine = engine
 }
 
+// SetTemplateEvaluator sets the evaluator for template expressions, replacing the template engine
+func (ce *ConditionEvaluator) SetTemplateEvaluator(evaluator TemplateEvaluator) {
+	ce.Templates = evaluator
+}
+
+// templates returns the evaluator for template expressions, or nil if there is none
+func (ce *ConditionEvaluator) templates() TemplateEvaluator {
+	if ce.Templates != nil {
+		return ce.Templates
+	}
+	if ce.TemplateEngine != nil {
+		return NewDefaultTemplateEvaluator(ce.TemplateEngine)
+	}
+	return nil
+}
+
 // EvaluationContext holds the data and metadata for condition evaluation
 // Enhanced to work with template engine
 type EvaluationContext struct {
@@ -108,6 +128,7 @@ func (ce *ConditionEvaluator) Evaluate(condition *Condition, ctx *EvaluationCont
 	if ctx == nil {
 		ctx = NewEvaluationContext()
 	}
+	ce.freezeTime(ctx)
 
 	switch condition.Type {
 	case ConditionTypeSimple:
@@ -130,6 +151,22 @@ func (ce *ConditionEvaluator) Evaluate(condition *Condition, ctx *EvaluationCont
 	}
 }
 
+// freezeTime records the time of the evaluation in the context, unless it already has one, so
+// that every template of a compound condition sees the same time
+func (ce *ConditionEvaluator) freezeTime(ctx *EvaluationContext) {
+	if ctx.TemplateContext == nil {
+		ctx.TemplateContext = make(map[string]interface{})
+	}
+	if _, ok := ctx.TemplateContext[NowVariable]; ok {
+		return
+	}
+	clock := ce.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	ctx.TemplateContext[NowVariable] = clock.Now()
+}
+
 // evaluateSimple handles simple field comparisons with template support
 func (ce *ConditionEvaluator) evaluateSimple(condition *Condition, ctx *EvaluationContext) (bool, error) {
 	if condition.Field == "" {
@@ -184,9 +221,8 @@ func (ce *ConditionEvaluator) evaluateSimple(condition *Condition, ctx *Evaluati
 			// For simple field names, if we only have the field name back, the field doesn't exist
 			if _, fieldExistsInContext := ctx.Fields[condition.Field]; !fieldExistsInContext {
 				// Also check if template engine would have resolved it
-				if ce.TemplateEngine != nil {
-					templateExpr := "${" + condition.Field + "}"
-					if value, err := ce.TemplateEngine.EvaluateExpression(templateExpr, ctx.TemplateContext); err != nil || value == nil {
+				if templates := ce.templates(); templates != nil {
+					if value, err := templates.EvaluateExpression(condition.Field, ctx.TemplateContext); err != nil || value == nil {
 						return false, nil
 					}
 				} else {
@@ -199,9 +235,9 @@ func (ce *ConditionEvaluator) evaluateSimple(condition *Condition, ctx *Evaluati
 
 	// Resolve comparison value if it's a template expression
 	compareValue := condition.Value
-	if ce.EnableTemplateFields && ce.TemplateEngine != nil {
-		if strValue, ok := condition.Value.(string); ok && ce.isTemplateExpression(strValue) {
-			resolvedValue, err := ce.TemplateEngine.EvaluateExpression(strValue, ctx.TemplateContext)
+	if templates := ce.templates(); ce.EnableTemplateFields && templates != nil {
+		if strValue, ok := condition.Value.(string); ok && templates.IsTemplate(strValue) {
+			resolvedValue, err := templates.Evaluate(strValue, ctx.TemplateContext)
 			if err != nil {
 				return false, &EvaluationError{
 					Message:   fmt.Sprintf("error resolving comparison value template '%s': %v", strValue, err),
@@ -220,8 +256,9 @@ func (ce *ConditionEvaluator) evaluateSimple(condition *Condition, ctx *Evaluati
 // resolveFieldValue resolves a field value, supporting both direct lookup and template expressions
 func (ce *ConditionEvaluator) resolveFieldValue(field string, ctx *EvaluationContext) (interface{}, bool, error) {
 	// If template engine is available and field contains template syntax, use template resolution
-	if ce.EnableTemplateFields && ce.TemplateEngine != nil && ce.isTemplateExpression(field) {
-		value, err := ce.TemplateEngine.EvaluateExpression(field, ctx.TemplateContext)
+	templates := ce.templates()
+	if ce.EnableTemplateFields && templates != nil && templates.IsTemplate(field) {
+		value, err := templates.Evaluate(field, ctx.TemplateContext)
 		if err != nil {
 			return nil, false, err
 		}
@@ -234,10 +271,9 @@ func (ce *ConditionEvaluator) resolveFieldValue(field string, ctx *EvaluationCon
 	}
 
 	// Try template engine for variable resolution if field is a simple variable reference
-	if ce.TemplateEngine != nil {
-		// Convert simple field reference to template syntax and try again
-		templateExpr := "${" + field + "}"
-		value, err := ce.TemplateEngine.EvaluateExpression(templateExpr, ctx.TemplateContext)
+	if templates != nil {
+		// Evaluate simple field reference as an expression and try again
+		value, err := templates.EvaluateExpression(field, ctx.TemplateContext)
 		if err == nil && value != nil {
 			return value, true, nil
 		}
@@ -250,6 +286,9 @@ func (ce *ConditionEvaluator) resolveFieldValue(field string, ctx *EvaluationCon
 
 // isTemplateExpression checks if a string contains template syntax
 func (ce *ConditionEvaluator) isTemplateExpression(str string) bool {
+	if templates := ce.templates(); templates != nil {
+		return templates.IsTemplate(str)
+	}
 	return strings.Contains(str, "${") && strings.Contains(str, "}")
 }
 
@@ -334,9 +373,10 @@ func (ce *ConditionEvaluator) evaluateExists(condition *Condition, ctx *Evaluati
 	}
 
 	// Check if field actually exists in context or can be resolved via template
-	if ce.EnableTemplateFields && ce.TemplateEngine != nil && ce.isTemplateExpression(condition.Field) {
+	templates := ce.templates()
+	if ce.EnableTemplateFields && templates != nil && templates.IsTemplate(condition.Field) {
 		// For template expressions, try to resolve and check if successful
-		value, err := ce.TemplateEngine.EvaluateExpression(condition.Field, ctx.TemplateContext)
+		value, err := templates.Evaluate(condition.Field, ctx.TemplateContext)
 		if err != nil {
 			return false, nil // Template resolution failed, field doesn't exist
 		}
@@ -349,9 +389,8 @@ func (ce *ConditionEvaluator) evaluateExists(condition *Condition, ctx *Evaluati
 	}
 
 	// Try template engine for variable resolution
-	if ce.TemplateEngine != nil {
-		templateExpr := "${" + condition.Field + "}"
-		value, err := ce.TemplateEngine.EvaluateExpression(templateExpr, ctx.TemplateContext)
+	if templates != nil {
+		value, err := templates.EvaluateExpression(condition.Field, ctx.TemplateContext)
 		if err == nil && value != nil {
 			return !ce.isEmpty(value), nil
 		}
@@ -371,17 +410,11 @@ func (ce *ConditionEvaluator) evaluateExpression(condition *Condition, ctx *Eval
 	}
 
 	// Use template engine if available
-	if ce.TemplateEngine != nil {
-		// Ensure expression is wrapped in template syntax if not already
-		expression := condition.Expression
-		if !ce.isTemplateExpression(expression) {
-			expression = "${" + expression + "}"
-		}
-
-		result, err := ce.TemplateEngine.EvaluateExpression(expression, ctx.TemplateContext)
+	if templates := ce.templates(); templates != nil {
+		result, err := templates.EvaluateExpression(condition.Expression, ctx.TemplateContext)
 		if err != nil {
 			return false, &EvaluationError{
-				Message:   fmt.Sprintf("error evaluating template expression '%s': %v", expression, err),
+				Message:   fmt.Sprintf("error evaluating template expression '%s': %v", condition.Expression, err),
 				Condition: condition,
 				Cause:     err,
 			}
@@ -496,6 +529,11 @@ func (ce *ConditionEvaluator) isEqual(a, b interface{}) bool {
 		}
 	}
 
+	// Zoned times are equal when they are the same instant, whatever their time zones
+	if equal, ok := temporalEqual(a, b); ok {
+		return equal
+	}
+
 	// Use reflection for deep comparison
 	return reflect.DeepEqual(a, b)
 }
@@ -534,6 +572,11 @@ func (ce *ConditionEvaluator) compareNumeric(a, b interface{}, compareFn func(fl
 }
 
 func (ce *ConditionEvaluator) contains(haystack, needle interface{}) (bool, error) {
+	// A date range contains the dates from its start to its end
+	if within, ok := dateRangeContains(haystack, needle); ok {
+		return within, nil
+	}
+
 	strHaystack, okHaystack := haystack.(string)
 	strNeedle, okNeedle := needle.(string)
 
@@ -670,9 +713,18 @@ func (ce *ConditionEvaluator) toTime(value interface{}) (time.Time, error) {
 				return t, nil
 			}
 		}
+		// Times of day, and dates and times without seconds
+		if t, ok := temporalValue(v); ok {
+			return t, nil
+		}
 		return time.Time{}, fmt.Errorf("cannot parse time: %s", v)
 	case int64:
 		return time.Unix(v, 0), nil
+	case map[string]interface{}, ZonedTime, *ZonedTime:
+		if t, ok := temporalValue(v); ok {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("cannot parse time: %v", v)
 	default:
 		return time.Time{}, fmt.Errorf("cannot convert %T to time", value)
 	}
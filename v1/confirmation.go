@@ -0,0 +1,322 @@
+package smartform
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Confirmation methods
+const (
+	ConfirmationMethodCode = "code" // Send a one-time code the user types in
+	ConfirmationMethodLink = "link" // Send a magic link
+)
+
+// Defaults for submission confirmation
+const (
+	DefaultConfirmationTTL         = 15 * time.Minute
+	DefaultConfirmationMaxAttempts = 5
+	DefaultMaxPendingConfirmations = 10000
+
+	confirmationSweepInterval = time.Minute
+)
+
+// DefaultConfirmationRecipientLimit is how many confirmations may be sent to one address by
+// default
+var DefaultConfirmationRecipientLimit = RateLimit{Requests: 5, Per: time.Hour}
+
+// Errors returned when parking or confirming a submission
+var (
+	ErrConfirmationNotFound = errors.New("confirmation not found or expired")
+	ErrConfirmationInvalid  = errors.New("invalid confirmation code")
+	ErrTooManyConfirmations = errors.New("too many submissions are waiting for confirmation")
+)
+
+// ConfirmationConfig requires submissions to be confirmed with a one-time code or magic
+// link sent to an address taken from the form before they are finalized
+type ConfirmationConfig struct {
+	Field       string `json:"field"`                 // Field holding the email address or phone number
+	Channel     string `json:"channel"`               // email or sms
+	Method      string `json:"method,omitempty"`      // code (default) or link
+	LinkURL     string `json:"linkUrl,omitempty"`     // Magic link template with {id} and {code} placeholders
+	TTLSeconds  int    `json:"ttlSeconds,omitempty"`  // How long the code is valid (default 15 minutes)
+	MaxAttempts int    `json:"maxAttempts,omitempty"` // Wrong codes allowed before the submission is discarded (default 5)
+}
+
+// ttl returns how long a confirmation code is valid
+func (cc *ConfirmationConfig) ttl() time.Duration {
+	if cc.TTLSeconds > 0 {
+		return time.Duration(cc.TTLSeconds) * time.Second
+	}
+	return DefaultConfirmationTTL
+}
+
+// maxAttempts returns how many wrong codes are allowed
+func (cc *ConfirmationConfig) maxAttempts() int {
+	if cc.MaxAttempts > 0 {
+		return cc.MaxAttempts
+	}
+	return DefaultConfirmationMaxAttempts
+}
+
+// PendingSubmission is a validated submission parked until it is confirmed
+type PendingSubmission struct {
+	ID        string
+	FormID    string
+	Data      map[string]interface{}
+	Parent    *ParentContext
+	codeHash  string
+	attempts  int
+	expiresAt time.Time
+}
+
+// ConfirmationStore keeps parked submissions in memory. It holds at most a maximum number of
+// them, dropping expired ones as submissions are parked and confirmed, and limits how many
+// confirmations are sent to each address, so that callers cannot flood memory or inboxes.
+type ConfirmationStore struct {
+	pending    map[string]*PendingSubmission
+	maxPending int
+	recipients *rateLimiter
+	swept      time.Time
+	lock       sync.Mutex
+}
+
+// NewConfirmationStore creates a new confirmation store
+func NewConfirmationStore() *ConfirmationStore {
+	return &ConfirmationStore{
+		pending:    make(map[string]*PendingSubmission),
+		maxPending: DefaultMaxPendingConfirmations,
+		recipients: newRateLimiter(DefaultConfirmationRecipientLimit),
+	}
+}
+
+// emptyCopy returns a store with the settings of the store, holding none of its submissions
+func (cs *ConfirmationStore) emptyCopy() *ConfirmationStore {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	return &ConfirmationStore{
+		pending:    make(map[string]*PendingSubmission),
+		maxPending: cs.maxPending,
+		recipients: newRateLimiter(cs.recipients.limit),
+	}
+}
+
+// SetMaxPending sets how many submissions may wait for confirmation at once. Parking more
+// fails with ErrTooManyConfirmations.
+func (cs *ConfirmationStore) SetMaxPending(max int) *ConfirmationStore {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.maxPending = max
+	return cs
+}
+
+// SetRecipientLimit sets how many confirmations may be sent to one address
+func (cs *ConfirmationStore) SetRecipientLimit(limit RateLimit) *ConfirmationStore {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.recipients = newRateLimiter(limit)
+	return cs
+}
+
+// allowRecipient reports whether another confirmation may be sent to an address, or else how
+// long to wait. Addresses are compared ignoring case.
+func (cs *ConfirmationStore) allowRecipient(to string) (time.Duration, bool) {
+	cs.lock.Lock()
+	recipients := cs.recipients
+	cs.lock.Unlock()
+	return recipients.allow(hashSecret(strings.ToLower(strings.TrimSpace(to))))
+}
+
+// Park stores a submission and returns it together with the code that confirms it
+func (cs *ConfirmationStore) Park(config *ConfirmationConfig, formID string, data map[string]interface{}, parent *ParentContext) (*PendingSubmission, string, error) {
+	code, err := newConfirmationCode(config.Method)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating confirmation code: %w", err)
+	}
+
+	pending := &PendingSubmission{
+		ID:        newID(),
+		FormID:    formID,
+		Data:      data,
+		Parent:    parent,
+		codeHash:  hashSecret(code),
+		expiresAt: time.Now().Add(config.ttl()),
+	}
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	full := cs.maxPending > 0 && len(cs.pending) >= cs.maxPending
+	cs.removeExpired(full)
+	if cs.maxPending > 0 && len(cs.pending) >= cs.maxPending {
+		return nil, "", ErrTooManyConfirmations
+	}
+	cs.pending[pending.ID] = pending
+	return pending, code, nil
+}
+
+// Confirm checks a code and, when it is right, removes and returns the parked submission.
+// The submission is discarded once maxAttempts wrong codes have been given.
+func (cs *ConfirmationStore) Confirm(id, code string, maxAttempts int) (*PendingSubmission, error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.removeExpired(false)
+
+	pending, ok := cs.pending[id]
+	if !ok {
+		return nil, ErrConfirmationNotFound
+	}
+	if time.Now().After(pending.expiresAt) {
+		delete(cs.pending, id)
+		return nil, ErrConfirmationNotFound
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pending.codeHash), []byte(hashSecret(code))) != 1 {
+		pending.attempts++
+		if pending.attempts >= maxAttempts {
+			delete(cs.pending, id)
+		}
+		return nil, ErrConfirmationInvalid
+	}
+
+	delete(cs.pending, id)
+	return pending, nil
+}
+
+// FormID returns the form of a parked submission
+func (cs *ConfirmationStore) FormID(id string) (string, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	pending, ok := cs.pending[id]
+	if !ok || time.Now().After(pending.expiresAt) {
+		return "", false
+	}
+	return pending.FormID, true
+}
+
+// removeExpired drops parked submissions whose code has expired. The store is swept at most
+// once per confirmationSweepInterval unless force is set.
+func (cs *ConfirmationStore) removeExpired(force bool) {
+	now := time.Now()
+	if !force && now.Sub(cs.swept) < confirmationSweepInterval {
+		return
+	}
+	cs.swept = now
+	for id, pending := range cs.pending {
+		if now.After(pending.expiresAt) {
+			delete(cs.pending, id)
+		}
+	}
+}
+
+// newConfirmationCode returns a six-digit code, or a long random token for magic links
+func newConfirmationCode(method string) (string, error) {
+	if method == ConfirmationMethodLink {
+		return newID() + newID(), nil
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashSecret hashes a confirmation code or API key secret so that it is never stored in clear
+func hashSecret(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// confirmationPage is the page magic links open. It posts the code back to the link's own URL.
+var confirmationPage = template.Must(template.New("confirmation").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Confirm your submission</title>
+</head>
+<body>
+<form method="post">
+<input type="hidden" name="code" value="{{.Code}}">
+{{- if .CSRFToken}}
+<input type="hidden" name="{{.CSRFField}}" value="{{.CSRFToken}}">
+{{- end}}
+<button type="submit">Confirm submission</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmationRecipient returns the address a confirmation of submitted data is sent to
+func confirmationRecipient(schema *FormSchema, data map[string]interface{}) (string, error) {
+	config := schema.Confirmation
+	to, _ := NewValidator(schema).getValueByPath(data, config.Field).(string)
+	if to == "" {
+		return "", fmt.Errorf("confirmation field %s is empty", config.Field)
+	}
+	return to, nil
+}
+
+// confirmationNotification builds the message that carries a confirmation code or link
+func confirmationNotification(schema *FormSchema, pending *PendingSubmission, code string) (*Notification, error) {
+	config := schema.Confirmation
+	to, err := confirmationRecipient(schema, pending.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := &Notification{
+		Channel: config.Channel,
+		To:      to,
+		Subject: fmt.Sprintf("Confirm your %s submission", schema.Title),
+		FormID:  schema.ID,
+		Data: map[string]interface{}{
+			"submissionId": pending.ID,
+			"expiresAt":    pending.expiresAt,
+		},
+	}
+
+	if config.Method == ConfirmationMethodLink {
+		link := config.LinkURL
+		if link == "" {
+			link = "/api/submit/{id}/confirm?code={code}"
+		}
+		link = strings.NewReplacer("{id}", pending.ID, "{code}", code).Replace(link)
+		notification.Body = fmt.Sprintf("Open this link to confirm your submission: %s", link)
+		notification.Data["link"] = link
+	} else {
+		notification.Body = fmt.Sprintf("Your confirmation code is %s", code)
+		notification.Data["code"] = code
+	}
+
+	return notification, nil
+}
+
+// RequireConfirmation makes submissions wait for a one-time code sent to the address in field
+func (fb *FormBuilder) RequireConfirmation(field, channel string) *FormBuilder {
+	fb.schema.Confirmation = &ConfirmationConfig{
+		Field:   field,
+		Channel: channel,
+		Method:  ConfirmationMethodCode,
+	}
+	return fb
+}
+
+// RequireLinkConfirmation makes submissions wait for a magic link sent to the address in field.
+// The link template may use the {id} and {code} placeholders.
+func (fb *FormBuilder) RequireLinkConfirmation(field, channel, linkURL string) *FormBuilder {
+	fb.schema.Confirmation = &ConfirmationConfig{
+		Field:   field,
+		Channel: channel,
+		Method:  ConfirmationMethodLink,
+		LinkURL: linkURL,
+	}
+	return fb
+}
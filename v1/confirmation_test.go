@@ -0,0 +1,183 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionConfirmation(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("request", "Data Request").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		RequireConfirmation("email", NotificationChannelEmail).
+		Build()))
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+
+	var sent *Notification
+	handler.SetNotifier(NotifierFunc(func(ctx context.Context, notification *Notification) error {
+		sent = notification
+		return nil
+	}))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/api/submit/request", `{"email": "ada@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var parked map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &parked))
+	id := parked["submissionId"].(string)
+
+	require.NotNil(t, sent)
+	assert.Equal(t, "ada@example.com", sent.To)
+	code := sent.Data["code"].(string)
+	assert.Len(t, code, 6)
+
+	assert.Equal(t, http.StatusForbidden, post("/api/submit/"+id+"/confirm", `{"code": "wrong"}`).Code)
+
+	rec = post("/api/submit/"+id+"/confirm", `{"code": "`+code+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var confirmed map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &confirmed))
+	assert.NotEmpty(t, confirmed["submissionId"])
+
+	// Codes are single use
+	assert.Equal(t, http.StatusNotFound, post("/api/submit/"+id+"/confirm", `{"code": "`+code+`"}`).Code)
+}
+
+func TestConfirmationAttemptLimit(t *testing.T) {
+	store := NewConfirmationStore()
+	pending, code, err := store.Park(&ConfirmationConfig{}, "form", map[string]interface{}{}, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = store.Confirm(pending.ID, "nope", 2)
+		assert.Equal(t, ErrConfirmationInvalid, err)
+	}
+	_, err = store.Confirm(pending.ID, code, 2)
+	assert.Equal(t, ErrConfirmationNotFound, err)
+}
+
+func TestConfirmationLimits(t *testing.T) {
+	store := NewConfirmationStore().SetMaxPending(2)
+	config := &ConfirmationConfig{}
+	first, _, err := store.Park(config, "form", map[string]interface{}{}, nil)
+	require.NoError(t, err)
+	_, _, err = store.Park(config, "form", map[string]interface{}{}, nil)
+	require.NoError(t, err)
+	_, _, err = store.Park(config, "form", map[string]interface{}{}, nil)
+	assert.True(t, errors.Is(err, ErrTooManyConfirmations))
+
+	// A full store sweeps expired submissions before rejecting new ones
+	store.lock.Lock()
+	first.expiresAt = time.Now().Add(-time.Second)
+	store.lock.Unlock()
+	_, _, err = store.Park(config, "form", map[string]interface{}{}, nil)
+	assert.NoError(t, err)
+	_, ok := store.FormID(first.ID)
+	assert.False(t, ok)
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("request", "Data Request").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		RequireConfirmation("email", NotificationChannelEmail).
+		Build()))
+	handler.SetConfirmationStore(NewConfirmationStore().SetRecipientLimit(RateLimit{Requests: 2, Per: time.Hour}))
+	sent := 0
+	handler.SetNotifier(NotifierFunc(func(ctx context.Context, notification *Notification) error {
+		sent++
+		return nil
+	}))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	submit := func(email string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/request", strings.NewReader(`{"email": "`+email+`"}`)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusAccepted, submit("ada@example.com").Code)
+	assert.Equal(t, http.StatusAccepted, submit("Ada@Example.com").Code)
+	rec := submit("ada@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusAccepted, submit("grace@example.com").Code)
+	assert.Equal(t, 3, sent)
+}
+
+func TestMagicLinkConfirmation(t *testing.T) {
+	handler := NewAPIHandler(WithCSRF(CSRFConfig{}))
+	require.NoError(t, handler.RegisterSchema(NewForm("request", "Data Request").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		RequireLinkConfirmation("email", NotificationChannelEmail, "").
+		Build()))
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	var sent *Notification
+	handler.SetNotifier(NotifierFunc(func(ctx context.Context, notification *Notification) error {
+		sent = notification
+		return nil
+	}))
+	server := handler.Handler()
+
+	// The submission is parked by an API client with a CSRF token
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var issued map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+	req := httptest.NewRequest(http.MethodPost, "/api/submit/request", strings.NewReader(`{"email": "ada@example.com"}`))
+	req.Header.Set(CSRFHeader, issued["token"])
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.NotNil(t, sent)
+	link := sent.Data["link"].(string)
+
+	// Opening the link only shows the page that confirms it
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `<form method="post">`)
+	store := handler.submissionStore.(*MemorySubmissionStore)
+	stored, _ := store.List("request")
+	assert.Empty(t, stored)
+
+	// Its button posts the code together with the CSRF token of the page
+	page := rec.Body.String()
+	tokenAt := strings.Index(page, `name="csrfToken" value="`)
+	require.True(t, tokenAt >= 0)
+	token := page[tokenAt+len(`name="csrfToken" value="`):]
+	token = token[:strings.Index(token, `"`)]
+
+	form := url.Values{CSRFFormField: {token}}
+	req = httptest.NewRequest(http.MethodPost, link, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	stored, _ = store.List("request")
+	assert.Len(t, stored, 1)
+}
@@ -0,0 +1,177 @@
+package smartform
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConsentConfig is the versioned legal text of a consent field
+type ConsentConfig struct {
+	Version  string            `json:"version"`
+	Sections []*ConsentSection `json:"sections"`
+}
+
+// ConsentSection is a block of legal text, optionally shown only for certain answers
+type ConsentSection struct {
+	ID      string     `json:"id"`
+	Title   string     `json:"title,omitempty"`
+	Text    string     `json:"text"`
+	Visible *Condition `json:"visible,omitempty"`
+}
+
+// ConsentRecord is stored in the submission in place of a consent field's value. It
+// records exactly which text was accepted and when.
+type ConsentRecord struct {
+	Accepted   bool      `json:"accepted"`
+	Version    string    `json:"version"`
+	Hash       string    `json:"hash"` // Hash of the legal text shown for the submitted answers
+	Sections   []string  `json:"sections"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+}
+
+// Clone returns a deep copy of the consent configuration
+func (cc *ConsentConfig) Clone() *ConsentConfig {
+	if cc == nil {
+		return nil
+	}
+
+	clone := *cc
+	if cc.Sections != nil {
+		clone.Sections = make([]*ConsentSection, len(cc.Sections))
+		for i, section := range cc.Sections {
+			sectionCopy := *section
+			sectionCopy.Visible = section.Visible.Clone()
+			clone.Sections[i] = &sectionCopy
+		}
+	}
+	return &clone
+}
+
+// visibleConsentSections returns the sections of a consent field shown for the given data
+func (v *Validator) visibleConsentSections(config *ConsentConfig, data map[string]interface{}) []*ConsentSection {
+	sections := []*ConsentSection{}
+	for _, section := range config.Sections {
+		if section.Visible == nil || v.evaluateCondition(section.Visible, data) {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// ConsentHash returns the hash of the legal text a consent field shows for the given data
+func (fs *FormSchema) ConsentHash(field *Field, data map[string]interface{}) string {
+	if field.Consent == nil {
+		return ""
+	}
+	return consentHash(field.Consent, NewValidator(fs).visibleConsentSections(field.Consent, data))
+}
+
+// consentHash hashes the version and the text of the shown sections
+func consentHash(config *ConsentConfig, sections []*ConsentSection) string {
+	var b strings.Builder
+	b.WriteString(config.Version)
+	for _, section := range sections {
+		b.WriteString("\x00")
+		b.WriteString(section.ID)
+		b.WriteString("\x00")
+		b.WriteString(section.Title)
+		b.WriteString("\x00")
+		b.WriteString(section.Text)
+	}
+	return fingerprintBytes([]byte(b.String()))
+}
+
+// validateConsent checks the value of a consent field. A value is either true or an
+// object with "accepted" and, optionally, the "version" and "hash" of the text the user
+// saw; a version or hash that no longer matches means the text changed under the user.
+func (v *Validator) validateConsent(field *Field, fieldPath string, value interface{}, data map[string]interface{}, result *ValidationResult) {
+	accepted, version, hash := consentAcceptance(value)
+
+	if !accepted {
+		if field.Required {
+			v.addError(result, &ValidationError{
+				FieldID:  fieldPath,
+				Message:  fmt.Sprintf("%s must be accepted", field.Label),
+				RuleType: string(ValidationTypeRequired),
+			})
+		}
+		return
+	}
+
+	if field.Consent == nil {
+		return
+	}
+
+	if version != "" && version != field.Consent.Version {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s has changed, please review version %s", field.Label, field.Consent.Version),
+			RuleType: string(ValidationTypeConsent),
+		})
+		return
+	}
+
+	if hash != "" && hash != consentHash(field.Consent, v.visibleConsentSections(field.Consent, data)) {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s does not match the text shown for your answers", field.Label),
+			RuleType: string(ValidationTypeConsent),
+		})
+	}
+}
+
+// consentAcceptance reads a submitted consent value
+func consentAcceptance(value interface{}) (accepted bool, version, hash string) {
+	switch v := value.(type) {
+	case bool:
+		return v, "", ""
+	case map[string]interface{}:
+		accepted, _ = v["accepted"].(bool)
+		version, _ = v["version"].(string)
+		hash, _ = v["hash"].(string)
+		return accepted, version, hash
+	default:
+		return false, "", ""
+	}
+}
+
+// RecordConsents replaces the value of every accepted consent field in validated data
+// with a ConsentRecord of the text version, hash, and acceptance time
+func (fs *FormSchema) RecordConsents(data map[string]interface{}, acceptedAt time.Time) {
+	validator := NewValidator(fs)
+
+	var record func(fields []*Field, values map[string]interface{})
+	record = func(fields []*Field, values map[string]interface{}) {
+		for _, field := range fields {
+			if field.Visible != nil && !validator.evaluateCondition(field.Visible, data) {
+				continue
+			}
+
+			if field.Type == FieldTypeConsent && field.Consent != nil {
+				if accepted, _, _ := consentAcceptance(values[field.ID]); accepted {
+					sections := validator.visibleConsentSections(field.Consent, data)
+					ids := make([]string, len(sections))
+					for i, section := range sections {
+						ids[i] = section.ID
+					}
+					values[field.ID] = &ConsentRecord{
+						Accepted:   true,
+						Version:    field.Consent.Version,
+						Hash:       consentHash(field.Consent, sections),
+						Sections:   ids,
+						AcceptedAt: acceptedAt,
+					}
+				}
+				continue
+			}
+
+			if field.Type == FieldTypeGroup || field.Type == FieldTypeObject {
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					record(field.Nested, nested)
+				}
+			}
+		}
+	}
+	record(fs.Fields, data)
+}
@@ -0,0 +1,51 @@
+package smartform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentField(t *testing.T) {
+	builder := NewForm("signup", "Signup")
+	builder.AddField(NewFieldBuilder("country", FieldTypeText, "Country").Build())
+	builder.ConsentField("terms", "Terms of Service").
+		Version("2024-06").
+		Section("general", "General", "You agree to the terms.").
+		SectionWhen("gdpr", "GDPR", "Your data is processed in the EU.", When("country").Equals("DE").Build())
+	schema := builder.Build()
+
+	result := schema.Validate(map[string]interface{}{"country": "DE"})
+	require.False(t, result.Valid)
+	assert.Equal(t, string(ValidationTypeRequired), result.Errors[0].RuleType)
+
+	result = schema.Validate(map[string]interface{}{
+		"country": "DE",
+		"terms":   map[string]interface{}{"accepted": true, "version": "2023-01"},
+	})
+	require.False(t, result.Valid)
+	assert.Equal(t, string(ValidationTypeConsent), result.Errors[0].RuleType)
+
+	// The hash covers the sections shown for the submitted answers
+	field := schema.FindFieldByID("terms")
+	usHash := schema.ConsentHash(field, map[string]interface{}{"country": "US"})
+	result = schema.Validate(map[string]interface{}{
+		"country": "DE",
+		"terms":   map[string]interface{}{"accepted": true, "hash": usHash},
+	})
+	assert.False(t, result.Valid)
+
+	data := map[string]interface{}{"country": "DE", "terms": true}
+	require.True(t, schema.Validate(data).Valid)
+
+	acceptedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	schema.RecordConsents(data, acceptedAt)
+	record, ok := data["terms"].(*ConsentRecord)
+	require.True(t, ok)
+	assert.Equal(t, "2024-06", record.Version)
+	assert.Equal(t, []string{"general", "gdpr"}, record.Sections)
+	assert.Equal(t, schema.ConsentHash(field, data), record.Hash)
+	assert.Equal(t, acceptedAt, record.AcceptedAt)
+}
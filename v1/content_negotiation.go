@@ -0,0 +1,197 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Media types the validate, submit, and options endpoints speak
+const (
+	MediaTypeJSON    = "application/json"
+	MediaTypeMsgpack = "application/msgpack"
+	MediaTypeCBOR    = "application/cbor"
+)
+
+// cborDecMode decodes CBOR maps the way encoding/json decodes objects
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}{}),
+}.DecMode()
+
+// ContentNegotiationMiddleware lets clients send request bodies as msgpack or CBOR, chosen with
+// the Content-Type header, and receive JSON responses in either format, chosen with the Accept
+// header. Bodies are transcoded to and from JSON at the edge so that handlers, and the json
+// struct tags that name every field, are shared by all formats.
+func ContentNegotiationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		if format := mediaFormat(r.Header.Get("Content-Type")); format != MediaTypeJSON {
+			body, err := transcodeToJSON(format, r.Body)
+			if err != nil {
+				writeRequestError(w, newRequestError(&RequestDiagnostic{Message: "invalid " + format + " body: " + err.Error()}))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			r.ContentLength = int64(len(body))
+			r.Header.Set("Content-Type", MediaTypeJSON)
+		}
+
+		format := negotiateMediaType(r.Header.Get("Accept"))
+		if format == MediaTypeJSON || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tw := &transcodingWriter{ResponseWriter: w, format: format, status: http.StatusOK}
+		next.ServeHTTP(tw, r)
+		tw.finish()
+	})
+}
+
+// mediaFormat maps a Content-Type header onto a supported media type; anything that is not
+// msgpack or CBOR is treated as JSON
+func mediaFormat(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case MediaTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+		return MediaTypeMsgpack
+	case MediaTypeCBOR:
+		return MediaTypeCBOR
+	default:
+		return MediaTypeJSON
+	}
+}
+
+// negotiateMediaType picks the preferred response format from an Accept header. JSON is chosen
+// unless msgpack or CBOR is preferred over it.
+func negotiateMediaType(header string) string {
+	best := MediaTypeJSON
+	bestQuality := 0.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, quality := parseEncoding(part)
+		if quality <= 0 {
+			continue
+		}
+
+		var format string
+		switch name {
+		case MediaTypeJSON, "application/*", "*/*":
+			format = MediaTypeJSON
+		case MediaTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+			format = MediaTypeMsgpack
+		case MediaTypeCBOR:
+			format = MediaTypeCBOR
+		default:
+			continue
+		}
+
+		// Earlier entries win ties
+		if quality > bestQuality {
+			best = format
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// transcodeToJSON converts a msgpack or CBOR request body into JSON
+func transcodeToJSON(format string, body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if format == MediaTypeCBOR {
+		err = cborDecMode.Unmarshal(data, &value)
+	} else {
+		err = msgpack.Unmarshal(data, &value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// transcodeFromJSON converts a JSON response body into msgpack or CBOR. Whole numbers are
+// encoded as integers, which JSON does not distinguish.
+func transcodeFromJSON(format string, data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	value = unwrapNumbers(value)
+
+	if format == MediaTypeCBOR {
+		return cbor.Marshal(value)
+	}
+	return msgpack.Marshal(value)
+}
+
+// unwrapNumbers replaces json.Number values with int64 or float64
+func unwrapNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = unwrapNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = unwrapNumbers(item)
+		}
+	}
+	return value
+}
+
+// transcodingWriter buffers a JSON response so it can be re-encoded in the negotiated format
+type transcodingWriter struct {
+	http.ResponseWriter
+	format string
+	status int
+	buffer bytes.Buffer
+}
+
+// WriteHeader records the status code until the response is transcoded
+func (tw *transcodingWriter) WriteHeader(status int) {
+	tw.status = status
+}
+
+// Write buffers response data
+func (tw *transcodingWriter) Write(data []byte) (int, error) {
+	return tw.buffer.Write(data)
+}
+
+// finish transcodes JSON responses and sends the response. Other responses, such as plain
+// text errors, are sent unchanged.
+func (tw *transcodingWriter) finish() {
+	header := tw.ResponseWriter.Header()
+	body := tw.buffer.Bytes()
+
+	if strings.Contains(header.Get("Content-Type"), "json") && len(body) > 0 {
+		if transcoded, err := transcodeFromJSON(tw.format, body); err == nil {
+			header.Set("Content-Type", tw.format)
+			header.Del("Content-Length")
+			body = transcoded
+		}
+	}
+
+	tw.ResponseWriter.WriteHeader(tw.status)
+	_, _ = tw.ResponseWriter.Write(body)
+}
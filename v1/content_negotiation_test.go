@@ -0,0 +1,78 @@
+package smartform
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func newNegotiationHandler(t *testing.T) *http.ServeMux {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("quantity", FieldTypeNumber, "Quantity").Required(true).ValidateMin(1, "At least one").Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	return mux
+}
+
+func TestMsgpackValidation(t *testing.T) {
+	mux := newNegotiationHandler(t)
+
+	body, err := msgpack.Marshal(map[string]interface{}{"quantity": 0})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/validate/order", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-msgpack")
+	req.Header.Set("Accept", MediaTypeMsgpack)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, MediaTypeMsgpack, rec.Header().Get("Content-Type"))
+
+	var result map[string]interface{}
+	require.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, false, result["valid"])
+	errors := result["errors"].([]interface{})
+	assert.Equal(t, "quantity", errors[0].(map[string]interface{})["fieldId"])
+}
+
+func TestCBORValidation(t *testing.T) {
+	mux := newNegotiationHandler(t)
+
+	body, err := cbor.Marshal(map[string]interface{}{"quantity": 3})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/validate/order", bytes.NewReader(body))
+	req.Header.Set("Content-Type", MediaTypeCBOR)
+	req.Header.Set("Accept", "application/json;q=0.5, application/cbor")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, MediaTypeCBOR, rec.Header().Get("Content-Type"))
+	var result map[string]interface{}
+	require.NoError(t, cbor.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, true, result["valid"])
+
+	// Undecodable bodies get the structured request error
+	req = httptest.NewRequest(http.MethodPost, "/api/validate/order", bytes.NewReader([]byte{0xff, 0x00}))
+	req.Header.Set("Content-Type", MediaTypeCBOR)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	assert.Equal(t, MediaTypeJSON, negotiateMediaType(""))
+	assert.Equal(t, MediaTypeJSON, negotiateMediaType("*/*"))
+	assert.Equal(t, MediaTypeMsgpack, negotiateMediaType("application/vnd.msgpack"))
+	assert.Equal(t, MediaTypeJSON, negotiateMediaType("application/json, application/cbor"))
+	assert.Equal(t, MediaTypeCBOR, negotiateMediaType("application/json;q=0.9, application/cbor"))
+	assert.Equal(t, MediaTypeJSON, negotiateMediaType("application/cbor;q=0, text/html"))
+}
@@ -0,0 +1,157 @@
+package smartform
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lets browser applications on other origins call the API
+type CORSConfig struct {
+	AllowedOrigins   []string      // Origins allowed to call the API, e.g. "https://app.example.com", or "*" for any without credentials
+	AllowedMethods   []string      // Methods allowed in cross-origin requests; GET, POST, PUT, PATCH, and DELETE by default
+	AllowedHeaders   []string      // Request headers allowed besides the CORS-safelisted ones; see DefaultCORSHeaders
+	ExposedHeaders   []string      // Response headers scripts may read besides the CORS-safelisted ones
+	AllowCredentials bool          // Whether requests of the listed origins may carry cookies, e.g. the CSRF cookie; never those allowed by "*"
+	MaxAge           time.Duration // How long browsers may cache the answer to a preflight request
+}
+
+// DefaultCORSHeaders are the request headers allowed when a CORS configuration names none
+var DefaultCORSHeaders = []string{
+	"Content-Type", "Authorization", "If-None-Match",
+	APIKeyHeader, CSRFHeader, TenantHeader, SessionHeader, IdempotencyKeyHeader, PreviewHeader,
+}
+
+// defaultCORSMethods are the methods allowed when a CORS configuration names none
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// WithCORS answers cross-origin requests from the allowed origins, including preflight
+// requests, and accepts live update connections from them. Without it, browsers only let pages
+// of the API's own origin call it.
+func WithCORS(config CORSConfig) HandlerOption {
+	return func(ah *APIHandler) {
+		if len(config.AllowedMethods) == 0 {
+			config.AllowedMethods = defaultCORSMethods
+		}
+		if len(config.AllowedHeaders) == 0 {
+			config.AllowedHeaders = DefaultCORSHeaders
+		}
+		ah.cors = &config
+	}
+}
+
+// allowsOrigin reports whether requests from an origin are allowed, and whether the origin is
+// listed rather than allowed by "*"
+func (c *CORSConfig) allowsOrigin(origin string) (allowed, listed bool) {
+	for _, o := range c.AllowedOrigins {
+		if strings.EqualFold(o, origin) {
+			return true, true
+		}
+		if o == "*" {
+			allowed = true
+		}
+	}
+	return allowed, false
+}
+
+// corsMiddleware adds the CORS headers of allowed origins to responses, and answers their
+// preflight requests. Requests from other origins are served without them, so browsers keep
+// the responses from their pages.
+func (ah *APIHandler) corsMiddleware(next http.Handler) http.Handler {
+	if ah.cors == nil {
+		return next
+	}
+	config := ah.cors
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowed, listed := config.allowsOrigin(origin)
+		if !allowed {
+			if preflight {
+				writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Origin is not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Origins allowed by "*" never get credentials, or any site could read the responses
+		// to requests carrying the user's cookies
+		if listed {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if config.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			header.Set("Access-Control-Allow-Origin", "*")
+		}
+		if !preflight {
+			if len(config.ExposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", strings.Join(config.AllowedMethods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(config.AllowedHeaders, ", "))
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// checkLiveOrigin reports whether a live update connection may be opened: from the API's own
+// origin, or from a listed one. Browsers send cookies along with WebSocket connections, so "*"
+// does not let any site open them.
+func (ah *APIHandler) checkLiveOrigin(r *http.Request) bool {
+	if sameOrigin(r) {
+		return true
+	}
+	if ah.cors == nil {
+		return false
+	}
+	_, listed := ah.cors.allowsOrigin(r.Header.Get("Origin"))
+	return listed
+}
+
+// crossSite reports whether a request was sent by a page of another origin than the API's own
+// or one listed by the CORS configuration. Origins only allowed by "*" are cross-site, or any
+// site could post to the API with the user's cookies. Requests from other clients than
+// browsers carry neither the Sec-Fetch-Site nor the Origin header, and are not cross-site.
+func (ah *APIHandler) crossSite(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" && ah.cors != nil {
+		if _, listed := ah.cors.allowsOrigin(origin); listed {
+			return false
+		}
+	}
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return false
+	case "":
+		return !sameOrigin(r)
+	default:
+		return true
+	}
+}
+
+// sameOrigin reports whether a request comes from a page of the host it is sent to, the check
+// the WebSocket upgrader makes by default
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	_, host, found := strings.Cut(origin, "://")
+	return found && strings.EqualFold(host, r.Host)
+}
@@ -0,0 +1,201 @@
+package smartform
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// CSRFHeader is the header requests carry their CSRF token in
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFFormField is the field HTML form posts, which cannot set headers, carry their CSRF token in
+const CSRFFormField = "csrfToken"
+
+// DefaultCSRFCookie is the cookie holding the secret CSRF tokens are derived from
+const DefaultCSRFCookie = "smartform_csrf"
+
+var (
+	// ErrCSRFTokenInvalid is returned when a request that changes state carries no CSRF token, or
+	// one that does not match its cookie
+	ErrCSRFTokenInvalid = errors.New("CSRF token is missing or invalid")
+	// ErrCrossSiteRequest is returned when a page of an origin that is not allowed sends a request
+	// that changes state
+	ErrCrossSiteRequest = errors.New("cross-site request is not allowed")
+)
+
+// inProcessKey marks the context of requests served in process by another transport
+type inProcessKey struct{}
+
+// InProcessContext marks the context of a request that another transport, such as the gRPC
+// server, serves in process through the handler's routes. Its callers are not browsers, which
+// cannot set the context of a request, so the cross-site and CSRF checks are skipped for it.
+func InProcessContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inProcessKey{}, true)
+}
+
+// CSRFConfig configures the CSRF protection of the handler
+type CSRFConfig struct {
+	Key        []byte        // Key tokens are signed with; a random one when empty, which does not survive restarts
+	CookieName string        // Name of the secret cookie; DefaultCSRFCookie when empty
+	CookiePath string        // Path of the secret cookie; "/" when empty
+	MaxAge     time.Duration // Lifetime of the secret cookie; it lasts the browser session when zero
+	SameSite   http.SameSite // SameSite mode of the secret cookie; Lax by default, None for cross-site clients
+}
+
+// WithCSRF protects requests that change state, such as submissions and validations, against
+// cross-site request forgery. Clients get a token from GET /api/csrf, which also sets a secret
+// cookie, and send it in the X-CSRF-Token header of POST, PUT, PATCH, and DELETE requests.
+// Requests authenticated with an API key are not checked, since browsers never send API keys
+// on their own. Without it, requests that change state are still rejected when pages of origins
+// WithCORS does not allow send them.
+func WithCSRF(config CSRFConfig) HandlerOption {
+	return func(ah *APIHandler) {
+		if len(config.Key) == 0 {
+			config.Key = make([]byte, 32)
+			_, _ = rand.Read(config.Key)
+		}
+		if config.CookieName == "" {
+			config.CookieName = DefaultCSRFCookie
+		}
+		if config.CookiePath == "" {
+			config.CookiePath = "/"
+		}
+		if config.SameSite == 0 {
+			config.SameSite = http.SameSiteLaxMode
+		}
+		ah.csrf = &config
+	}
+}
+
+// token derives the CSRF token of a secret
+func (c *CSRFConfig) token(secret []byte) string {
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write(secret)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// secret returns the secret of the request's CSRF cookie
+func (c *CSRFConfig) secret(r *http.Request) ([]byte, bool) {
+	cookie, err := r.Cookie(c.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(secret) != 32 {
+		return nil, false
+	}
+	return secret, true
+}
+
+// issue returns the secret of the request's CSRF cookie, setting a new cookie when the request
+// has none
+func (c *CSRFConfig) issue(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if secret, ok := c.secret(r); ok {
+		return secret, nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	cookie := &http.Cookie{
+		Name:     c.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(secret),
+		Path:     c.CookiePath,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" || c.SameSite == http.SameSiteNoneMode,
+		SameSite: c.SameSite,
+	}
+	if c.MaxAge > 0 {
+		cookie.MaxAge = int(c.MaxAge / time.Second)
+	}
+	http.SetCookie(w, cookie)
+	return secret, nil
+}
+
+// verify checks the CSRF token of a request against its cookie. The token is read from the
+// X-CSRF-Token header or, for HTML form posts, the csrfToken form field.
+func (c *CSRFConfig) verify(r *http.Request) error {
+	secret, ok := c.secret(r)
+	token := r.Header.Get(CSRFHeader)
+	if token == "" && isFormPost(r) {
+		token = r.PostFormValue(CSRFFormField)
+	}
+	if !ok || token == "" || !hmac.Equal([]byte(token), []byte(c.token(secret))) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// csrfMiddleware rejects requests that change state sent by pages of origins that are not
+// allowed, whether or not CSRF protection is configured, and, when it is, those without a valid
+// CSRF token
+func (ah *APIHandler) csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := APIKeyFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if inProcess, _ := r.Context().Value(inProcessKey{}).(bool); inProcess {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ah.crossSite(r) {
+			writeError(w, ErrCrossSiteRequest)
+			return
+		}
+		if ah.csrf == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := ah.csrf.verify(r); err != nil {
+			writeError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleCSRFToken issues the CSRF token of the caller, setting the secret cookie it is derived
+// from when the caller has none
+func (ah *APIHandler) handleCSRFToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.csrf == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "CSRF protection is not configured"))
+		return
+	}
+
+	secret, err := ah.csrf.issue(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"token":  ah.csrf.token(secret),
+		"header": CSRFHeader,
+	})
+}
+
+// isFormPost reports whether a request posts an HTML form
+func isFormPost(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,203 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBrowserHandler(t *testing.T) http.Handler {
+	handler := NewAPIHandler(
+		WithCORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, MaxAge: time.Hour}),
+		WithCSRF(CSRFConfig{}),
+	)
+	handler.SetAPIKeyStore(NewMemoryAPIKeyStore())
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		Build()))
+	return handler.Handler()
+}
+
+func TestCSRFProtection(t *testing.T) {
+	handler := NewAPIHandler(WithCSRF(CSRFConfig{}))
+	handler.SetAPIKeyStore(NewMemoryAPIKeyStore())
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		Build()))
+	mux := handler.Handler()
+
+	submit := func(cookie *http.Cookie, token, apiKey string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/submit/contact", strings.NewReader(`{"name": "Ada"}`))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set(CSRFHeader, token)
+		}
+		if apiKey != "" {
+			req.Header.Set(APIKeyHeader, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/csrf", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+	var issued map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+	token := issued["token"]
+
+	assert.Equal(t, http.StatusForbidden, submit(nil, "", ""))
+	assert.Equal(t, http.StatusForbidden, submit(cookies[0], "", ""))
+	assert.Equal(t, http.StatusForbidden, submit(nil, token, ""), "tokens only hold with their cookie")
+	assert.Equal(t, http.StatusForbidden, submit(cookies[0], token[:len(token)-2]+"xx", ""))
+	assert.Equal(t, http.StatusOK, submit(cookies[0], token, ""))
+
+	// Reading the form needs no token, and API key clients are not browsers
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/contact", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, secret, err := handler.CreateAPIKey("crm", []string{"contact"}, APIKeyOperationSubmit)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, submit(nil, "", secret))
+
+	// A caller with a cookie keeps it and its token
+	req := httptest.NewRequest(http.MethodGet, "/api/csrf", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Result().Cookies())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+	assert.Equal(t, token, issued["token"])
+}
+
+func TestCORS(t *testing.T) {
+	mux := newBrowserHandler(t)
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/submit/contact", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type, x-csrf-token")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), CSRFHeader)
+	assert.Equal(t, "3600", rec.Header().Get("Access-Control-Max-Age"))
+
+	rec = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/forms/contact", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/forms/contact", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWildcard(t *testing.T) {
+	handler := NewAPIHandler(WithCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "*"},
+		AllowCredentials: true,
+	}))
+	mux := handler.Handler()
+	get := func(origin string) http.Header {
+		req := httptest.NewRequest(http.MethodGet, "/api/forms", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	header := get("https://app.example.com")
+	assert.Equal(t, "https://app.example.com", header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", header.Get("Access-Control-Allow-Credentials"))
+
+	header = get("https://evil.example.com")
+	assert.Equal(t, "*", header.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, header.Get("Access-Control-Allow-Credentials"), "any site must not read responses with cookies")
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/ws/contact", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	assert.False(t, handler.checkLiveOrigin(req))
+}
+
+func TestCrossSiteRequests(t *testing.T) {
+	submit := func(handler *APIHandler, header map[string]string) int {
+		require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").Build()))
+		req := httptest.NewRequest(http.MethodPost, "http://api.example.com/api/submit/contact", strings.NewReader(`{}`))
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, submit(NewAPIHandler(), nil), "clients other than browsers are not checked")
+	assert.Equal(t, http.StatusOK, submit(NewAPIHandler(), map[string]string{"Origin": "http://api.example.com"}))
+	assert.Equal(t, http.StatusOK, submit(NewAPIHandler(), map[string]string{"Sec-Fetch-Site": "same-origin"}))
+	assert.Equal(t, http.StatusForbidden, submit(NewAPIHandler(), map[string]string{"Origin": "https://evil.example.com"}))
+	assert.Equal(t, http.StatusForbidden, submit(NewAPIHandler(), map[string]string{"Sec-Fetch-Site": "cross-site"}))
+
+	cors := WithCORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	assert.Equal(t, http.StatusOK, submit(NewAPIHandler(cors), map[string]string{
+		"Origin": "https://app.example.com", "Sec-Fetch-Site": "same-site",
+	}))
+	assert.Equal(t, http.StatusForbidden, submit(NewAPIHandler(cors), map[string]string{
+		"Origin": "https://evil.example.com", "Sec-Fetch-Site": "cross-site",
+	}))
+
+	// Origins only allowed by "*" may read public responses, but not post as the user
+	wildcard := WithCORS(CORSConfig{AllowedOrigins: []string{"*"}})
+	assert.Equal(t, http.StatusForbidden, submit(NewAPIHandler(wildcard), map[string]string{
+		"Origin": "https://evil.example.com", "Sec-Fetch-Site": "cross-site",
+	}))
+	assert.Equal(t, http.StatusForbidden, submit(NewAPIHandler(wildcard), map[string]string{"Origin": "https://evil.example.com"}))
+	assert.Equal(t, http.StatusOK, submit(NewAPIHandler(wildcard), map[string]string{"Origin": "http://api.example.com"}))
+}
+
+func TestLiveUpdateOrigins(t *testing.T) {
+	handler := NewAPIHandler(WithCORS(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}))
+	check := func(origin string) bool {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/ws/contact", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		return handler.checkLiveOrigin(req)
+	}
+	assert.True(t, check(""))
+	assert.True(t, check("http://api.example.com"))
+	assert.True(t, check("https://app.example.com"))
+	assert.False(t, check("https://evil.example.com"))
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/api/ws/contact", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	assert.False(t, NewAPIHandler().checkLiveOrigin(req), "without CORS only the API's own origin may connect")
+}
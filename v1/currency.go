@@ -0,0 +1,495 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxExactFloat is the largest integer every smaller integer of which a float64 holds exactly.
+// Amounts decoded from JSON numbers beyond it may already have been rounded.
+const maxExactFloat = 1 << 53
+
+// ErrUnknownCurrency is returned for currency codes no currency is registered for
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Currency is a currency amounts can be entered in
+type Currency struct {
+	Code   string // ISO 4217 code, e.g. "EUR"
+	Digits int    // Minor units of the currency: digits after the decimal point, e.g. 2 for cents
+	Symbol string // Symbol amounts are formatted with, the code when empty
+}
+
+// currencies are the currencies amounts can be entered in, by code
+var currencies = struct {
+	byCode map[string]*Currency
+	lock   sync.RWMutex
+}{
+	byCode: make(map[string]*Currency),
+}
+
+func init() {
+	for _, currency := range []Currency{
+		{Code: "USD", Digits: 2, Symbol: "$"},
+		{Code: "EUR", Digits: 2, Symbol: "€"},
+		{Code: "GBP", Digits: 2, Symbol: "£"},
+		{Code: "JPY", Digits: 0, Symbol: "¥"},
+		{Code: "CNY", Digits: 2, Symbol: "CN¥"},
+		{Code: "INR", Digits: 2, Symbol: "₹"},
+		{Code: "KRW", Digits: 0, Symbol: "₩"},
+		{Code: "CAD", Digits: 2, Symbol: "CA$"},
+		{Code: "AUD", Digits: 2, Symbol: "A$"},
+		{Code: "NZD", Digits: 2, Symbol: "NZ$"},
+		{Code: "MXN", Digits: 2, Symbol: "MX$"},
+		{Code: "BRL", Digits: 2, Symbol: "R$"},
+		{Code: "HKD", Digits: 2, Symbol: "HK$"},
+		{Code: "ILS", Digits: 2, Symbol: "₪"},
+		{Code: "VND", Digits: 0, Symbol: "₫"},
+		{Code: "CHF", Digits: 2},
+		{Code: "SEK", Digits: 2},
+		{Code: "NOK", Digits: 2},
+		{Code: "DKK", Digits: 2},
+		{Code: "PLN", Digits: 2},
+		{Code: "CZK", Digits: 2},
+		{Code: "HUF", Digits: 2},
+		{Code: "RON", Digits: 2},
+		{Code: "TRY", Digits: 2},
+		{Code: "RUB", Digits: 2},
+		{Code: "ZAR", Digits: 2},
+		{Code: "NGN", Digits: 2},
+		{Code: "KES", Digits: 2},
+		{Code: "EGP", Digits: 2},
+		{Code: "AED", Digits: 2},
+		{Code: "SAR", Digits: 2},
+		{Code: "SGD", Digits: 2},
+		{Code: "TWD", Digits: 2},
+		{Code: "THB", Digits: 2},
+		{Code: "MYR", Digits: 2},
+		{Code: "IDR", Digits: 2},
+		{Code: "PHP", Digits: 2},
+		{Code: "PKR", Digits: 2},
+		{Code: "ARS", Digits: 2},
+		{Code: "COP", Digits: 2},
+		{Code: "PEN", Digits: 2},
+		{Code: "CLP", Digits: 0},
+		{Code: "ISK", Digits: 0},
+		{Code: "UGX", Digits: 0},
+		{Code: "XOF", Digits: 0},
+		{Code: "XAF", Digits: 0},
+		{Code: "BHD", Digits: 3},
+		{Code: "KWD", Digits: 3},
+		{Code: "OMR", Digits: 3},
+		{Code: "JOD", Digits: 3},
+		{Code: "TND", Digits: 3},
+	} {
+		if err := RegisterCurrency(currency); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterCurrency adds a currency amounts can be entered in, or replaces the one registered
+// for its code. Common currencies are built in.
+func RegisterCurrency(currency Currency) error {
+	currency.Code = strings.ToUpper(currency.Code)
+	if len(currency.Code) != 3 {
+		return fmt.Errorf("currency code %q is not an ISO 4217 code", currency.Code)
+	}
+	if currency.Digits < 0 || currency.Digits > 4 {
+		return fmt.Errorf("currency %s cannot have %d minor units", currency.Code, currency.Digits)
+	}
+
+	currencies.lock.Lock()
+	defer currencies.lock.Unlock()
+	currencies.byCode[currency.Code] = &currency
+	return nil
+}
+
+// LookupCurrency returns the currency registered for a code
+func LookupCurrency(code string) (*Currency, error) {
+	currencies.lock.RLock()
+	defer currencies.lock.RUnlock()
+	currency, ok := currencies.byCode[strings.ToUpper(code)]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", code, ErrUnknownCurrency)
+	}
+	return currency, nil
+}
+
+// Money is an amount of a currency, kept in minor units so that it never goes through
+// floating point
+type Money struct {
+	Amount   int64  `json:"amount"`   // Amount in minor units of the currency, e.g. 1999 for $19.99
+	Currency string `json:"currency"` // ISO 4217 code, e.g. "USD"
+}
+
+// ParseMoney parses a decimal amount in major units of a currency, such as "1,234.56", exactly.
+// Commas group digits and a point separates the minor units; amounts with more decimal
+// places than the currency has minor units fail.
+func ParseMoney(amount, currency string) (*Money, error) {
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.ReplaceAll(strings.TrimSpace(amount), ",", "")
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" && fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, fmt.Errorf("%q is not an amount", amount)
+	}
+	// Trailing zeros never make an amount more precise than the currency
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > c.Digits {
+		return nil, fmt.Errorf("%q has more than %d decimal places for %s", amount, c.Digits, c.Code)
+	}
+	fraction += strings.Repeat("0", c.Digits-len(fraction))
+
+	minor, err := strconv.ParseInt("0"+whole+fraction, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%q is too large an amount", amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return &Money{Amount: minor, Currency: c.Code}, nil
+}
+
+// Decimal returns the amount in major units, e.g. "1234.56"
+func (m *Money) Decimal() string {
+	whole, fraction := m.split()
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+// split returns the whole and fractional digits of the amount, with the sign on the whole
+// digits
+func (m *Money) split() (string, string) {
+	digits := 2
+	if currency, err := LookupCurrency(m.Currency); err == nil {
+		digits = currency.Digits
+	}
+
+	abs := strconv.FormatUint(absAmount(m.Amount), 10)
+	if len(abs) <= digits {
+		abs = strings.Repeat("0", digits-len(abs)+1) + abs
+	}
+	whole, fraction := abs[:len(abs)-digits], abs[len(abs)-digits:]
+	if m.Amount < 0 {
+		whole = "-" + whole
+	}
+	return whole, fraction
+}
+
+// absAmount returns the magnitude of an amount, which fits in a uint64 even for the smallest
+// int64
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
+
+// moneyLocale is how a locale writes out amounts
+type moneyLocale struct {
+	group       string // Separator of groups of three digits
+	decimal     string // Separator of the minor units
+	symbolAfter bool   // Whether the symbol follows the amount, separated by a non-breaking space
+	symbolSpace bool   // Whether a symbol preceding the amount is separated by a non-breaking space
+}
+
+// moneyLocales are the conventions of the locales amounts are formatted in, by language or
+// language and region
+var moneyLocales = map[string]moneyLocale{
+	"en":    {group: ",", decimal: "."},
+	"ja":    {group: ",", decimal: "."},
+	"zh":    {group: ",", decimal: "."},
+	"ko":    {group: ",", decimal: "."},
+	"de":    {group: ".", decimal: ",", symbolAfter: true},
+	"de-CH": {group: "’", decimal: ".", symbolSpace: true},
+	"es":    {group: ".", decimal: ",", symbolAfter: true},
+	"it":    {group: ".", decimal: ",", symbolAfter: true},
+	"nl":    {group: ".", decimal: ",", symbolSpace: true},
+	"pt":    {group: ".", decimal: ",", symbolSpace: true},
+	"pt-PT": {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"fr":    {group: "\u202f", decimal: ",", symbolAfter: true},
+	"fr-CH": {group: "\u202f", decimal: ".", symbolAfter: true},
+	"da":    {group: ".", decimal: ",", symbolAfter: true},
+	"sv":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"nb":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"fi":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"pl":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"cs":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+	"ru":    {group: "\u00a0", decimal: ",", symbolAfter: true},
+}
+
+// lookupMoneyLocale returns the conventions of a locale such as "de-AT", falling back to its
+// language and then to English
+func lookupMoneyLocale(locale string) moneyLocale {
+	locale = strings.ReplaceAll(locale, "_", "-")
+	if conventions, ok := moneyLocales[locale]; ok {
+		return conventions
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	if conventions, ok := moneyLocales[strings.ToLower(language)]; ok {
+		return conventions
+	}
+	return moneyLocales["en"]
+}
+
+// Format writes the amount out as a locale does, e.g. "$1,234.56" in "en" and "1.234,56 €"
+// in "de"
+func (m *Money) Format(locale string) string {
+	conventions := lookupMoneyLocale(locale)
+	whole, fraction := m.split()
+	negative := strings.HasPrefix(whole, "-")
+	whole = strings.TrimPrefix(whole, "-")
+
+	var amount strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			amount.WriteString(conventions.group)
+		}
+		amount.WriteRune(digit)
+	}
+	if fraction != "" {
+		amount.WriteString(conventions.decimal + fraction)
+	}
+
+	symbol := m.Currency
+	if currency, err := LookupCurrency(m.Currency); err == nil && currency.Symbol != "" {
+		symbol = currency.Symbol
+	}
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	switch {
+	case conventions.symbolAfter:
+		return sign + amount.String() + "\u00a0" + symbol
+	case conventions.symbolSpace || symbol == m.Currency:
+		return sign + symbol + "\u00a0" + amount.String()
+	default:
+		return sign + symbol + amount.String()
+	}
+}
+
+// String returns the amount with its currency code, e.g. "19.99 USD"
+func (m *Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// CurrencyConfig configures the amounts a currency field accepts
+type CurrencyConfig struct {
+	Currencies      []string `json:"currencies,omitempty"`      // Currencies accepted, all registered ones when empty
+	DefaultCurrency string   `json:"defaultCurrency,omitempty"` // Currency of amounts entered without one
+	Precision       *int     `json:"precision,omitempty"`       // Most decimal places entered, fewer than the minor units of the currency
+	Locale          string   `json:"locale,omitempty"`          // Locale amounts are formatted in (default "en")
+}
+
+// Clone returns a deep copy of the currency configuration
+func (cc *CurrencyConfig) Clone() *CurrencyConfig {
+	if cc == nil {
+		return nil
+	}
+
+	clone := *cc
+	if cc.Currencies != nil {
+		clone.Currencies = append([]string(nil), cc.Currencies...)
+	}
+	if cc.Precision != nil {
+		precision := *cc.Precision
+		clone.Precision = &precision
+	}
+	return &clone
+}
+
+// money reads the value of a currency field: an object with an amount in minor units or a
+// decimal value in major units, and a currency, or a decimal string such as "19.99" or
+// "EUR 19.99". Bare numbers are refused, as they may have been rounded through floating point.
+func (cc *CurrencyConfig) money(value interface{}) (*Money, error) {
+	config := cc
+	if config == nil {
+		config = &CurrencyConfig{}
+	}
+
+	var money *Money
+	var err error
+	switch v := value.(type) {
+	case Money:
+		money = &v
+	case *Money:
+		money = v
+	case string:
+		money, err = config.parse(v)
+	case map[string]interface{}:
+		money, err = config.moneyObject(v)
+	case float64, float32, int, int64, json.Number:
+		return nil, errors.New("amounts must be given in minor units with their currency, or as a decimal string")
+	default:
+		return nil, fmt.Errorf("%v is not an amount", value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return money, config.check(money)
+}
+
+// parse parses a decimal string with an optional currency code before or after the amount
+func (cc *CurrencyConfig) parse(text string) (*Money, error) {
+	currency := cc.DefaultCurrency
+	fields := strings.Fields(text)
+	if len(fields) == 2 {
+		if isCurrencyCode(fields[0]) {
+			currency, text = fields[0], fields[1]
+		} else if isCurrencyCode(fields[1]) {
+			currency, text = fields[1], fields[0]
+		}
+	}
+	if currency == "" {
+		return nil, fmt.Errorf("%q needs a currency", text)
+	}
+	return ParseMoney(text, currency)
+}
+
+// isCurrencyCode reports whether text looks like an ISO 4217 code
+func isCurrencyCode(text string) bool {
+	return len(text) == 3 && strings.Trim(strings.ToUpper(text), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+// moneyObject reads an object with an amount in minor units or a decimal value in major units
+func (cc *CurrencyConfig) moneyObject(object map[string]interface{}) (*Money, error) {
+	currency, _ := object["currency"].(string)
+	if currency == "" {
+		currency = cc.DefaultCurrency
+	}
+	if currency == "" {
+		return nil, errors.New("the amount needs a currency")
+	}
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	if decimal, ok := object["value"].(string); ok {
+		return ParseMoney(decimal, c.Code)
+	}
+
+	var amount int64
+	switch v := object["amount"].(type) {
+	case int:
+		amount = int64(v)
+	case int64:
+		amount = v
+	case json.Number:
+		if amount, err = v.Int64(); err != nil {
+			return nil, fmt.Errorf("amount %s is not a whole number of minor units", v)
+		}
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("amount %v is not a whole number of minor units", v)
+		}
+		if math.Abs(v) > maxExactFloat {
+			return nil, fmt.Errorf("amount %v is too large to be exact", v)
+		}
+		amount = int64(v)
+	default:
+		return nil, errors.New("the amount must be a whole number of minor units")
+	}
+	return &Money{Amount: amount, Currency: c.Code}, nil
+}
+
+// check checks that an amount is of an accepted currency, with no more decimal places than
+// the precision
+func (cc *CurrencyConfig) check(money *Money) error {
+	currency, err := LookupCurrency(money.Currency)
+	if err != nil {
+		return err
+	}
+	if len(cc.Currencies) > 0 {
+		accepted := false
+		for _, code := range cc.Currencies {
+			if strings.EqualFold(code, currency.Code) {
+				accepted = true
+			}
+		}
+		if !accepted {
+			return fmt.Errorf("amounts in %s are not accepted", currency.Code)
+		}
+	}
+	if cc.Precision != nil && *cc.Precision < currency.Digits {
+		step := int64(math.Pow10(currency.Digits - max(*cc.Precision, 0)))
+		if money.Amount%step != 0 {
+			return fmt.Errorf("amounts have at most %d decimal places", max(*cc.Precision, 0))
+		}
+	}
+	return nil
+}
+
+// validateCurrency checks that the value of a currency field is an exact amount of an accepted
+// currency
+func (v *Validator) validateCurrency(field *Field, fieldPath string, value interface{}, result *ValidationResult) {
+	if field.Type != FieldTypeCurrency {
+		return
+	}
+	if _, err := field.Currency.money(value); err != nil {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s is not a valid amount: %v", field.Label, err),
+			RuleType: string(ValidationTypeCurrency),
+		})
+	}
+}
+
+// currencySummary formats the value of a currency field in the locale of the field, or else
+// the given locale, or returns the value as it is when it is not an amount
+func currencySummary(field *Field, value interface{}, locale string) interface{} {
+	money, err := field.Currency.money(value)
+	if err != nil {
+		return value
+	}
+	if field.Currency != nil && field.Currency.Locale != "" {
+		locale = field.Currency.Locale
+	}
+	return money.Format(locale)
+}
+
+// NormalizeCurrencyAmounts replaces the values of currency fields in validated data with
+// objects holding the amount in minor units and the currency code, e.g. "19.99" in a field
+// defaulting to USD with {"amount": 1999, "currency": "USD"}. Values that cannot be read are
+// left as they are.
+func (fs *FormSchema) NormalizeCurrencyAmounts(data map[string]interface{}) {
+	var normalize func(fields []*Field, values map[string]interface{})
+	normalize = func(fields []*Field, values map[string]interface{}) {
+		for _, field := range fields {
+			switch field.Type {
+			case FieldTypeCurrency:
+				if values[field.ID] == nil {
+					continue
+				}
+				if money, err := field.Currency.money(values[field.ID]); err == nil {
+					values[field.ID] = map[string]interface{}{"amount": money.Amount, "currency": money.Currency}
+				}
+			case FieldTypeGroup, FieldTypeObject:
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					normalize(field.Nested, nested)
+				}
+			case FieldTypeArray:
+				items, _ := values[field.ID].([]interface{})
+				for _, item := range items {
+					if itemMap, ok := item.(map[string]interface{}); ok {
+						normalize(field.Nested, itemMap)
+					}
+				}
+			}
+		}
+	}
+	normalize(fs.Fields, data)
+}
@@ -0,0 +1,122 @@
+package smartform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+	}{
+		{"19.99", "USD", 1999},
+		{"1,234.5", "usd", 123450},
+		{"0.1", "EUR", 10},
+		{"-3.10", "EUR", -310},
+		{".5", "GBP", 50},
+		{"1500", "JPY", 1500},
+		{"1.000", "JPY", 1},
+		{"2.125", "KWD", 2125},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			money, err := ParseMoney(tt.amount, tt.currency)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, money.Amount)
+		})
+	}
+
+	_, err := ParseMoney("19.999", "USD")
+	assert.EqualError(t, err, `"19.999" has more than 2 decimal places for USD`)
+	_, err = ParseMoney("1.5", "JPY")
+	assert.Error(t, err)
+	_, err = ParseMoney("1e3", "USD")
+	assert.Error(t, err)
+	_, err = ParseMoney("99999999999999999999", "USD")
+	assert.Error(t, err)
+	_, err = ParseMoney("1", "XYZ")
+	assert.True(t, errors.Is(err, ErrUnknownCurrency))
+}
+
+func TestMoneyFormat(t *testing.T) {
+	usd := &Money{Amount: 123456, Currency: "USD"}
+	assert.Equal(t, "1234.56", usd.Decimal())
+	assert.Equal(t, "$1,234.56", usd.Format("en-US"))
+	assert.Equal(t, "1.234,56\u00a0$", usd.Format("de"))
+
+	eur := &Money{Amount: -5, Currency: "EUR"}
+	assert.Equal(t, "-0.05", eur.Decimal())
+	assert.Equal(t, "-0,05\u00a0€", eur.Format("fr-FR"))
+	assert.Equal(t, "€\u00a01.234,56", (&Money{Amount: 123456, Currency: "EUR"}).Format("nl"))
+
+	assert.Equal(t, "¥1,500", (&Money{Amount: 1500, Currency: "JPY"}).Format("ja"))
+	assert.Equal(t, "CHF\u00a01’000.00", (&Money{Amount: 100000, Currency: "CHF"}).Format("de-CH"))
+	assert.Equal(t, "CHF\u00a012.00", (&Money{Amount: 1200, Currency: "CHF"}).Format(""))
+	assert.Equal(t, "2.125 KWD", (&Money{Amount: 2125, Currency: "KWD"}).String())
+}
+
+func TestCurrencyFieldValidation(t *testing.T) {
+	builder := NewForm("order", "Order")
+	builder.CurrencyField("total", "Total").
+		Currencies("USD", "EUR", "JPY").
+		DefaultCurrency("USD").
+		Precision(1)
+	schema := builder.Build()
+
+	valid := []interface{}{
+		"19.90",
+		"EUR 5",
+		map[string]interface{}{"amount": float64(1990), "currency": "USD"},
+		map[string]interface{}{"value": "1500", "currency": "JPY"},
+		&Money{Amount: 500, Currency: "EUR"},
+	}
+	for _, value := range valid {
+		result := schema.Validate(map[string]interface{}{"total": value})
+		assert.True(t, result.Valid, "%v: %v", value, result.Errors)
+	}
+
+	invalid := map[string]interface{}{
+		"float":         19.9,
+		"fraction":      map[string]interface{}{"amount": 19.9, "currency": "USD"},
+		"precision":     "19.99",
+		"inexact":       map[string]interface{}{"amount": float64(1 << 60), "currency": "USD"},
+		"not accepted":  "GBP 5",
+		"unknown":       map[string]interface{}{"amount": float64(5), "currency": "XYZ"},
+		"not an amount": "five dollars",
+	}
+	for name, value := range invalid {
+		result := schema.Validate(map[string]interface{}{"total": value})
+		require.False(t, result.Valid, name)
+		assert.Equal(t, string(ValidationTypeCurrency), result.Errors[0].RuleType, name)
+	}
+}
+
+func TestNormalizeCurrencyAmounts(t *testing.T) {
+	builder := NewForm("expenses", "Expenses")
+	builder.CurrencyField("total", "Total").DefaultCurrency("EUR").Locale("de")
+	builder.ArrayField("items", "Items").ItemTemplate(NewCurrencyFieldBuilder("cost", "Cost").Build())
+	schema := builder.Build()
+
+	data := map[string]interface{}{
+		"total": "1234.50",
+		"items": []interface{}{
+			map[string]interface{}{"cost": map[string]interface{}{"value": "0.10", "currency": "usd"}},
+			map[string]interface{}{"cost": "not an amount"},
+		},
+	}
+	schema.NormalizeCurrencyAmounts(data)
+
+	assert.Equal(t, map[string]interface{}{"amount": int64(123450), "currency": "EUR"}, data["total"])
+	items := data["items"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"amount": int64(10), "currency": "USD"}, items[0].(map[string]interface{})["cost"])
+	assert.Equal(t, "not an amount", items[1].(map[string]interface{})["cost"])
+
+	document, err := schema.PrintDocument(data)
+	require.NoError(t, err)
+	assert.Equal(t, "1.234,50\u00a0€", document.Pages[0].Blocks[0].Value)
+}
@@ -0,0 +1,437 @@
+package smartform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts of the values of date, time, and datetime fields
+const (
+	dateLayout      = "2006-01-02"
+	timeOfDayLayout = "15:04:05"
+)
+
+// localDateTimeLayouts are the layouts of wall-clock dates and times without an offset, which
+// are read in the time zone of their field
+var localDateTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// localTimeOfDayLayouts are the layouts of times of day
+var localTimeOfDayLayouts = []string{
+	"15:04:05.999999999",
+	"15:04",
+}
+
+// timeOfDayReferenceDate is the date times of day are placed on to compare them across time
+// zones. Its offsets are those of winter in the northern hemisphere.
+var timeOfDayReferenceDate = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ZonedTime is a date and time, or a time of day, with the IANA time zone it was entered in.
+// Time and datetime fields with a time zone store their values in this form.
+type ZonedTime struct {
+	Value    string `json:"value"`              // RFC 3339 date and time with offset, or time of day, e.g. "09:30:00"
+	TimeZone string `json:"timeZone,omitempty"` // IANA time zone, e.g. "Europe/Paris"
+}
+
+// DateRange is a range of dates, the value of date range fields
+type DateRange struct {
+	Start string `json:"start"` // First date, e.g. "2024-07-01"
+	End   string `json:"end"`   // Last date, e.g. "2024-07-14"
+}
+
+// DateRangeConfig configures the ranges a date range field accepts
+type DateRangeConfig struct {
+	MinSpan int    `json:"minSpan,omitempty"` // Fewest days from the start to the end
+	MaxSpan int    `json:"maxSpan,omitempty"` // Most days from the start to the end
+	Min     string `json:"min,omitempty"`     // Earliest start date
+	Max     string `json:"max,omitempty"`     // Latest end date
+}
+
+// Clone returns a copy of the date range configuration
+func (dc *DateRangeConfig) Clone() *DateRangeConfig {
+	if dc == nil {
+		return nil
+	}
+	clone := *dc
+	return &clone
+}
+
+// zonedValue reads a zoned time from an object with a value and a time zone, or returns false
+func zonedValue(value interface{}) (*ZonedTime, bool) {
+	switch v := value.(type) {
+	case ZonedTime:
+		return &v, true
+	case *ZonedTime:
+		return v, v != nil
+	case map[string]interface{}:
+		text, ok := v["value"].(string)
+		if !ok {
+			return nil, false
+		}
+		zone, _ := v["timeZone"].(string)
+		return &ZonedTime{Value: text, TimeZone: zone}, true
+	}
+	return nil, false
+}
+
+// loadTimeZone loads an IANA time zone, UTC for none
+func loadTimeZone(zone string) (*time.Location, error) {
+	if zone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", zone)
+	}
+	return location, nil
+}
+
+// parseZonedTime parses the value of a time or datetime field: a string, or an object with a
+// value and a time zone. Values without an offset are read in their time zone, or else in the
+// default zone. Times of day are placed on the reference date.
+func parseZonedTime(fieldType FieldType, value interface{}, defaultZone string) (time.Time, string, error) {
+	if t, ok := value.(time.Time); ok {
+		return t, t.Location().String(), nil
+	}
+
+	text, isString := value.(string)
+	zone := defaultZone
+	if zoned, ok := zonedValue(value); ok {
+		text, isString = zoned.Value, true
+		if zoned.TimeZone != "" {
+			zone = zoned.TimeZone
+		}
+	}
+	if !isString {
+		return time.Time{}, "", fmt.Errorf("%v is not a date or time", value)
+	}
+	location, err := loadTimeZone(zone)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	text = strings.TrimSpace(text)
+
+	if fieldType == FieldTypeTime {
+		for _, layout := range localTimeOfDayLayouts {
+			if t, err := time.Parse(layout, text); err == nil {
+				return time.Date(timeOfDayReferenceDate.Year(), timeOfDayReferenceDate.Month(), timeOfDayReferenceDate.Day(),
+					t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), location), zone, nil
+			}
+		}
+		return time.Time{}, "", fmt.Errorf("%q is not a time of day", text)
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, text); err == nil {
+		if zone != "" {
+			t = t.In(location)
+		}
+		return t, zone, nil
+	}
+	for _, layout := range localDateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return t, zone, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("%q is not a date and time", text)
+}
+
+// parseDateRange parses the value of a date range field: an object with a start and an end
+// date, or a DateRange
+func parseDateRange(value interface{}) (time.Time, time.Time, error) {
+	var start, end string
+	switch v := value.(type) {
+	case DateRange:
+		start, end = v.Start, v.End
+	case *DateRange:
+		start, end = v.Start, v.End
+	case map[string]interface{}:
+		start, _ = v["start"].(string)
+		end, _ = v["end"].(string)
+	default:
+		return time.Time{}, time.Time{}, errors.New("a date range needs a start and an end")
+	}
+	if start == "" || end == "" {
+		return time.Time{}, time.Time{}, errors.New("a date range needs a start and an end")
+	}
+
+	startDate, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start %q is not a date", start)
+	}
+	endDate, err := time.Parse(dateLayout, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end %q is not a date", end)
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, errors.New("the end is before the start")
+	}
+	return startDate, endDate, nil
+}
+
+// check checks that a range is within the earliest and latest dates and spans an accepted
+// number of days
+func (dc *DateRangeConfig) check(start, end time.Time) error {
+	if dc == nil {
+		return nil
+	}
+	if earliest, err := time.Parse(dateLayout, dc.Min); err == nil && start.Before(earliest) {
+		return fmt.Errorf("the start is before %s", dc.Min)
+	}
+	if latest, err := time.Parse(dateLayout, dc.Max); err == nil && end.After(latest) {
+		return fmt.Errorf("the end is after %s", dc.Max)
+	}
+
+	// Dates are parsed in UTC, so every day is 24 hours long
+	span := int(end.Sub(start).Hours() / 24)
+	if dc.MinSpan > 0 && span < dc.MinSpan {
+		return fmt.Errorf("the range spans %d days, fewer than %d", span, dc.MinSpan)
+	}
+	if dc.MaxSpan > 0 && span > dc.MaxSpan {
+		return fmt.Errorf("the range spans %d days, more than %d", span, dc.MaxSpan)
+	}
+	return nil
+}
+
+// hasTimeZone reports whether values of a field are read with a time zone: time and datetime
+// fields with a time zone, and values entered with one
+func hasTimeZone(field *Field, value interface{}) bool {
+	if field.Type != FieldTypeTime && field.Type != FieldTypeDateTime {
+		return false
+	}
+	_, zoned := zonedValue(value)
+	return zoned || field.TimeZone != ""
+}
+
+// validateDateTime checks that the value of a date range field is a range of accepted dates,
+// and that the value of a zoned time or datetime field is a valid time in a known time zone
+func (v *Validator) validateDateTime(field *Field, fieldPath string, value interface{}, result *ValidationResult) {
+	var err error
+	switch {
+	case field.Type == FieldTypeDateRange:
+		var start, end time.Time
+		if start, end, err = parseDateRange(value); err == nil {
+			err = field.DateRange.check(start, end)
+		}
+	case hasTimeZone(field, value):
+		_, _, err = parseZonedTime(field.Type, value, field.TimeZone)
+	default:
+		return
+	}
+	if err != nil {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s is not valid: %v", field.Label, err),
+			RuleType: string(ValidationTypeDateTime),
+		})
+	}
+}
+
+// zonedTimeOf returns a time in the form time and datetime fields with a time zone store it in
+// Values entered with an offset but no time zone are stored in UTC.
+func zonedTimeOf(fieldType FieldType, t time.Time, zone string) map[string]interface{} {
+	if zone == "" {
+		t, zone = t.UTC(), "UTC"
+	}
+	text := t.Format(time.RFC3339)
+	if fieldType == FieldTypeTime {
+		text = t.Format(timeOfDayLayout)
+	}
+	return map[string]interface{}{"value": text, "timeZone": zone}
+}
+
+// NormalizeDateTimes replaces the values of time and datetime fields with a time zone in
+// validated data with the time and its zone, e.g. "2024-03-10T09:30" in a field in
+// America/New_York with {"value": "2024-03-10T09:30:00-04:00", "timeZone": "America/New_York"}.
+// Date ranges are replaced with their start and end. Values that cannot be read are left as
+// they are.
+func (fs *FormSchema) NormalizeDateTimes(data map[string]interface{}) {
+	var normalize func(fields []*Field, values map[string]interface{})
+	normalize = func(fields []*Field, values map[string]interface{}) {
+		for _, field := range fields {
+			value := values[field.ID]
+			switch field.Type {
+			case FieldTypeTime, FieldTypeDateTime:
+				if value == nil || !hasTimeZone(field, value) {
+					continue
+				}
+				if t, zone, err := parseZonedTime(field.Type, value, field.TimeZone); err == nil {
+					values[field.ID] = zonedTimeOf(field.Type, t, zone)
+				}
+			case FieldTypeDateRange:
+				if start, end, err := parseDateRange(value); err == nil {
+					values[field.ID] = map[string]interface{}{"start": start.Format(dateLayout), "end": end.Format(dateLayout)}
+				}
+			case FieldTypeGroup, FieldTypeObject:
+				if nested, ok := value.(map[string]interface{}); ok {
+					normalize(field.Nested, nested)
+				}
+			case FieldTypeArray:
+				items, _ := value.([]interface{})
+				for _, item := range items {
+					if itemMap, ok := item.(map[string]interface{}); ok {
+						normalize(field.Nested, itemMap)
+					}
+				}
+			}
+		}
+	}
+	normalize(fs.Fields, data)
+}
+
+// temporalValue reads a value conditions compare as a time: a time.Time, a zoned time, or a
+// string holding a date, a date and time, or a time of day. Values without an offset are
+// read in UTC.
+func temporalValue(value interface{}) (time.Time, bool) {
+	fieldType := FieldTypeDateTime
+	text, isString := value.(string)
+	if zoned, ok := zonedValue(value); ok {
+		text, isString = zoned.Value, true
+	}
+	if isString {
+		if t, err := time.Parse(dateLayout, strings.TrimSpace(text)); err == nil {
+			return t, true
+		}
+		for _, layout := range localTimeOfDayLayouts {
+			if _, err := time.Parse(layout, strings.TrimSpace(text)); err == nil {
+				fieldType = FieldTypeTime
+			}
+		}
+	}
+	t, _, err := parseZonedTime(fieldType, value, "")
+	return t, err == nil
+}
+
+// compareTemporal compares two values as times, returning -1, 0, or 1, and false when either
+// is not a time
+func compareTemporal(a, b interface{}) (int, bool) {
+	timeA, okA := temporalValue(a)
+	timeB, okB := temporalValue(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return timeA.Compare(timeB), true
+}
+
+// temporalEqual reports whether two values are the same instant, when either is a zoned time:
+// "2024-03-10T09:30:00-04:00" in America/New_York equals "2024-03-10T13:30:00Z"
+func temporalEqual(a, b interface{}) (bool, bool) {
+	_, zonedA := zonedValue(a)
+	_, zonedB := zonedValue(b)
+	if !zonedA && !zonedB {
+		return false, false
+	}
+	comparison, ok := compareTemporal(a, b)
+	return ok && comparison == 0, ok
+}
+
+// dateRangeContains reports whether a date range holds a date, or false when the range is not
+// a date range
+func dateRangeContains(dateRange, date interface{}) (bool, bool) {
+	switch dateRange.(type) {
+	case DateRange, *DateRange, map[string]interface{}:
+	default:
+		return false, false
+	}
+	start, end, err := parseDateRange(dateRange)
+	if err != nil {
+		return false, false
+	}
+	t, ok := temporalValue(date)
+	if !ok {
+		return false, true
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return !day.Before(start) && !day.After(end), true
+}
+
+// dateTimeSummary formats the value of a zoned time or datetime field, or a date range, for
+// print, or returns the value as it is when it cannot be read
+func dateTimeSummary(field *Field, value interface{}) interface{} {
+	if field.Type == FieldTypeDateRange {
+		if start, end, err := parseDateRange(value); err == nil {
+			return start.Format(dateLayout) + " – " + end.Format(dateLayout)
+		}
+		return value
+	}
+	if !hasTimeZone(field, value) {
+		return value
+	}
+	t, zone, err := parseZonedTime(field.Type, value, field.TimeZone)
+	if err != nil {
+		return value
+	}
+	layout := "2006-01-02 15:04"
+	if field.Type == FieldTypeTime {
+		layout = "15:04"
+	}
+	if zone == "" {
+		zone = "UTC"
+	}
+	return t.Format(layout) + " " + zone
+}
+
+// TimeZone sets the IANA time zone, e.g. "Europe/Paris", values of a time or datetime field
+// entered without an offset are read in. Values are stored with their time zone.
+func (fb *FieldBuilder) TimeZone(zone string) *FieldBuilder {
+	fb.field.TimeZone = zone
+	return fb
+}
+
+// DateRangeFieldBuilder provides a fluent API for creating date range fields
+type DateRangeFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewDateRangeFieldBuilder creates a new date range field builder
+func NewDateRangeFieldBuilder(id, label string) *DateRangeFieldBuilder {
+	builder := &DateRangeFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypeDateRange, label),
+	}
+	builder.field.DateRange = &DateRangeConfig{}
+	return builder
+}
+
+// MinSpan sets the fewest days from the start to the end of the range
+func (db *DateRangeFieldBuilder) MinSpan(days int) *DateRangeFieldBuilder {
+	db.field.DateRange.MinSpan = days
+	return db
+}
+
+// MaxSpan sets the most days from the start to the end of the range
+func (db *DateRangeFieldBuilder) MaxSpan(days int) *DateRangeFieldBuilder {
+	db.field.DateRange.MaxSpan = days
+	return db
+}
+
+// Between sets the earliest start and the latest end of the range, as "2006-01-02" dates.
+// Either may be empty.
+func (db *DateRangeFieldBuilder) Between(earliest, latest string) *DateRangeFieldBuilder {
+	db.field.DateRange.Min = earliest
+	db.field.DateRange.Max = latest
+	return db
+}
+
+// Build finalizes and returns the date range field
+func (db *DateRangeFieldBuilder) Build() *Field {
+	return db.field
+}
+
+// DateRangeField adds a date range field to the form
+func (fb *FormBuilder) DateRangeField(id, label string) *DateRangeFieldBuilder {
+	field := NewDateRangeFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// DateRangeField adds a date range field to the group and returns a DateRangeFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) DateRangeField(id, label string) *DateRangeFieldBuilder {
+	field := NewDateRangeFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
@@ -0,0 +1,108 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDateRangeField(t *testing.T) {
+	builder := NewForm("booking", "Booking")
+	builder.DateRangeField("stay", "Stay").
+		MinSpan(2).
+		MaxSpan(14).
+		Between("2024-01-01", "2024-12-31").
+		Required(true)
+	schema := builder.Build()
+
+	result := schema.Validate(map[string]interface{}{
+		"stay": map[string]interface{}{"start": "2024-07-01", "end": "2024-07-05"},
+	})
+	assert.True(t, result.Valid, result.Errors)
+
+	invalid := map[string]interface{}{
+		"missing end":   map[string]interface{}{"start": "2024-07-01"},
+		"reversed":      map[string]interface{}{"start": "2024-07-05", "end": "2024-07-01"},
+		"too short":     map[string]interface{}{"start": "2024-07-01", "end": "2024-07-02"},
+		"too long":      map[string]interface{}{"start": "2024-07-01", "end": "2024-07-20"},
+		"too early":     map[string]interface{}{"start": "2023-12-30", "end": "2024-01-03"},
+		"not a date":    map[string]interface{}{"start": "July 1st", "end": "2024-07-05"},
+		"not an object": "2024-07-01/2024-07-05",
+	}
+	for name, value := range invalid {
+		result := schema.Validate(map[string]interface{}{"stay": value})
+		require.False(t, result.Valid, name)
+		assert.Equal(t, string(ValidationTypeDateTime), result.Errors[0].RuleType, name)
+	}
+
+	result = schema.Validate(map[string]interface{}{})
+	require.False(t, result.Valid)
+	assert.Equal(t, string(ValidationTypeRequired), result.Errors[0].RuleType)
+}
+
+func TestZonedDateTimeField(t *testing.T) {
+	builder := NewForm("meeting", "Meeting")
+	builder.DateTimeField("startsAt", "Starts at").TimeZone("America/New_York")
+	builder.TimeField("opensAt", "Opens at").TimeZone("Europe/Paris")
+	schema := builder.Build()
+
+	result := schema.Validate(map[string]interface{}{
+		"startsAt": "2024-03-10T09:30",
+		"opensAt":  map[string]interface{}{"value": "08:00", "timeZone": "Asia/Tokyo"},
+	})
+	assert.True(t, result.Valid, result.Errors)
+
+	result = schema.Validate(map[string]interface{}{
+		"startsAt": map[string]interface{}{"value": "2024-03-10T09:30", "timeZone": "Mars/Olympus_Mons"},
+	})
+	require.False(t, result.Valid)
+	assert.Contains(t, result.Errors[0].Message, `unknown time zone "Mars/Olympus_Mons"`)
+
+	data := map[string]interface{}{
+		"startsAt": "2024-03-10T09:30",
+		"opensAt":  "08:00",
+	}
+	schema.NormalizeDateTimes(data)
+	// Daylight saving time began at 2am that day
+	assert.Equal(t, map[string]interface{}{"value": "2024-03-10T09:30:00-04:00", "timeZone": "America/New_York"}, data["startsAt"])
+	assert.Equal(t, map[string]interface{}{"value": "08:00:00", "timeZone": "Europe/Paris"}, data["opensAt"])
+
+	// Fields without a time zone keep their values as entered
+	plain := NewForm("plain", "Plain")
+	plain.DateTimeField("at", "At")
+	plainData := map[string]interface{}{"at": "2024-03-10T09:30:00Z"}
+	plain.Build().NormalizeDateTimes(plainData)
+	assert.Equal(t, "2024-03-10T09:30:00Z", plainData["at"])
+}
+
+func TestTemporalConditions(t *testing.T) {
+	newYork := map[string]interface{}{"value": "2024-03-10T09:30:00-04:00", "timeZone": "America/New_York"}
+	stay := map[string]interface{}{"start": "2024-07-01", "end": "2024-07-05"}
+	evaluator := NewConditionEvaluator()
+	ctx := NewEvaluationContext()
+	ctx.AddField("startsAt", newYork)
+	ctx.AddField("opensAt", map[string]interface{}{"value": "08:00:00", "timeZone": "Europe/Paris"})
+	ctx.AddField("stay", stay)
+
+	tests := []struct {
+		condition *Condition
+		want      bool
+	}{
+		{When("startsAt").Equals("2024-03-10T13:30:00Z").Build(), true},
+		{When("startsAt").GreaterThan("2024-03-10T13:00:00Z").Build(), true},
+		{When("startsAt").LessThan(map[string]interface{}{"value": "2024-03-10T14:00", "timeZone": "Europe/London"}).Build(), true},
+		{When("opensAt").LessThan("07:30").Build(), true},
+		{When("stay").Contains("2024-07-03").Build(), true},
+		{When("stay").Contains("2024-07-06").Build(), false},
+	}
+	for _, tt := range tests {
+		got, err := evaluator.Evaluate(tt.condition, ctx)
+		require.NoError(t, err, tt.condition)
+		assert.Equal(t, tt.want, got, "%s %s %v", tt.condition.Field, tt.condition.Operator, tt.condition.Value)
+
+		// Validation evaluates conditions the same way
+		validator := NewValidator(&FormSchema{})
+		assert.Equal(t, tt.want, validator.evaluateCondition(tt.condition, ctx.Fields), "%s %s %v", tt.condition.Field, tt.condition.Operator, tt.condition.Value)
+	}
+}
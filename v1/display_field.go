@@ -0,0 +1,29 @@
+package smartform
+
+import "fmt"
+
+// markDisplayField marks a display field read-only and computed, telling clients to show its
+// value without submitting it
+func markDisplayField(fieldCopy *Field) {
+	fieldCopy.Properties["readOnly"] = true
+	fieldCopy.Properties["computed"] = true
+}
+
+// rejectDisplayValue fails validation when a value is submitted for a display field. Unlike
+// values of hidden or disabled fields, these are rejected whatever the tamper policy.
+func (v *Validator) rejectDisplayValue(field *Field, fieldPath string, data map[string]interface{}, result *ValidationResult) {
+	if value, present := data[field.ID]; !present || value == nil {
+		return
+	}
+
+	v.addError(result, &ValidationError{
+		FieldID:  fieldPath,
+		Message:  fmt.Sprintf("%s is computed and cannot be submitted", field.Label),
+		RuleType: string(ValidationTypeTamper),
+	})
+	result.Tampered = append(result.Tampered, &TamperFinding{
+		FieldID: fieldPath,
+		Reason:  TamperReasonComputed,
+		Action:  TamperPolicyReject,
+	})
+}
@@ -0,0 +1,70 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplayFields(t *testing.T) {
+	form := NewForm("order", "Order")
+	form.NumberField("price", "Price")
+	form.NumberField("quantity", "Quantity")
+	form.DisplayField("total", "Total").Expression("${multiply(price, quantity)}")
+	shipping := form.GroupField("shipping", "Shipping")
+	shipping.TextField("city", "City")
+	shipping.DisplayField("eta", "Estimated delivery").Function("deliveryEstimate")
+	schema := form.Build()
+
+	handler := NewAPIHandler()
+	handler.SetTamperPolicy(TamperPolicyIgnore)
+	require.NoError(t, handler.RegisterSchema(schema))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	// The renderer computes the value and marks the field so clients never submit it
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/order?diagnostics=true&price=4&quantity=3", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rendered struct {
+		Fields      []*Field           `json:"fields"`
+		Diagnostics *RenderDiagnostics `json:"diagnostics"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	total := rendered.Fields[2]
+	assert.Equal(t, FieldTypeDisplay, total.Type)
+	assert.EqualValues(t, 12, total.DefaultValue)
+	assert.Equal(t, "${multiply(price, quantity)}", total.ComputedValue)
+	assert.Equal(t, true, total.Properties["readOnly"])
+	assert.Equal(t, true, total.Properties["computed"])
+	assert.Equal(t, ProvenanceExpression, rendered.Diagnostics.Provenance["total"].Source)
+	assert.Equal(t, true, rendered.Fields[3].Nested[1].Properties["computed"])
+
+	// Submitted values are rejected whatever the tamper policy, including nested ones
+	validate := func(body string) *ValidationResult {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/order", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return &result
+	}
+	assert.True(t, validate(`{"price": 4, "quantity": 3}`).Valid)
+
+	result := validate(`{"price": 4, "quantity": 3, "total": 0.01, "shipping": {"city": "Oslo", "eta": "tomorrow"}}`)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, &ValidationError{FieldID: "total", Message: "Total is computed and cannot be submitted", RuleType: "tamper"}, result.Errors[0])
+	assert.Equal(t, "shipping.eta", result.Errors[1].FieldID)
+	require.Len(t, result.Tampered, 2)
+	assert.Equal(t, &TamperFinding{FieldID: "total", Reason: TamperReasonComputed, Action: TamperPolicyReject}, result.Tampered[0])
+
+	// Display fields are not part of the submitted data
+	properties := schema.ToJSONSchema()["properties"].(map[string]interface{})
+	assert.NotContains(t, properties, "total")
+	assert.Contains(t, properties, "price")
+}
@@ -0,0 +1,352 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Kinds of dependencies checked by the Doctor
+const (
+	ProbeKindDynamicSource  = "dynamicSource"
+	ProbeKindRemoteFragment = "remoteFragment"
+	ProbeKindConnection     = "connection"
+	ProbeKindStorage        = "storage"
+	ProbeKindAuth           = "auth"
+)
+
+// DefaultProbeTimeout is how long a single probe may take before it fails
+const DefaultProbeTimeout = 5 * time.Second
+
+// Probe checks that a dependency is reachable and correctly configured
+type Probe func(ctx context.Context) error
+
+// Pinger is implemented by storage backends that can check they are reachable, such as the SQL
+// stores and the file storages. The handler's doctor pings the stores implementing it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ReadinessCheck is the outcome of probing one dependency
+type ReadinessCheck struct {
+	Kind       string      `json:"kind"`
+	Name       string      `json:"name"`
+	Forms      []string    `json:"forms,omitempty"` // Forms that depend on it
+	Status     CheckStatus `json:"status"`
+	Message    string      `json:"message,omitempty"`
+	DurationMs int64       `json:"durationMs"`
+}
+
+// ReadinessReport is the readiness matrix produced by the Doctor
+type ReadinessReport struct {
+	Ready     bool              `json:"ready"` // No check failed
+	Checks    []*ReadinessCheck `json:"checks"`
+	CheckedAt time.Time         `json:"checkedAt"`
+}
+
+// String formats the report as a table
+func (rr *ReadinessReport) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tSTATUS\tTIME\tFORMS\tMESSAGE")
+	for _, check := range rr.Checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%dms\t%s\t%s\n",
+			check.Kind, check.Name, check.Status, check.DurationMs, strings.Join(check.Forms, ","), check.Message)
+	}
+	w.Flush()
+
+	if rr.Ready {
+		b.WriteString("ready\n")
+	} else {
+		b.WriteString("not ready\n")
+	}
+	return b.String()
+}
+
+// Doctor probes the dependencies of a set of schemas - dynamic option sources, remote
+// fragments, connections, storage backends, and auth providers - so misconfigurations
+// are caught at deploy rather than when a user opens a form
+type Doctor struct {
+	schemas         []*FormSchema
+	probes          map[string]Probe // Keyed by kind and name
+	functionService *DynamicFunctionService
+	authService     *AuthService
+	client          *http.Client
+	timeout         time.Duration
+}
+
+// NewDoctor creates a new doctor
+func NewDoctor() *Doctor {
+	return &Doctor{
+		probes:  make(map[string]Probe),
+		client:  &http.Client{},
+		timeout: DefaultProbeTimeout,
+	}
+}
+
+// AddSchema adds a schema whose dependencies are checked
+func (d *Doctor) AddSchema(schema *FormSchema) *Doctor {
+	d.schemas = append(d.schemas, schema)
+	return d
+}
+
+// AddProbe registers a probe for a named dependency. Probes for dependencies referenced by
+// schemas replace their default probes; probes for other dependencies, such as storage
+// backends, are always run.
+func (d *Doctor) AddProbe(kind, name string, probe Probe) *Doctor {
+	d.probes[probeKey(kind, name)] = probe
+	return d
+}
+
+// SetFunctionService sets the service used to check that function sources are registered
+func (d *Doctor) SetFunctionService(service *DynamicFunctionService) *Doctor {
+	d.functionService = service
+	return d
+}
+
+// SetAuthService sets the service used to check that connections have tokens and auth forms
+// have providers
+func (d *Doctor) SetAuthService(service *AuthService) *Doctor {
+	d.authService = service
+	return d
+}
+
+// SetTimeout sets how long a single probe may take
+func (d *Doctor) SetTimeout(timeout time.Duration) *Doctor {
+	d.timeout = timeout
+	return d
+}
+
+// probeKey identifies a dependency
+func probeKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// doctorTarget is a dependency found in the schemas
+type doctorTarget struct {
+	check *ReadinessCheck
+	probe Probe
+}
+
+// Run probes every dependency concurrently and returns the readiness matrix
+func (d *Doctor) Run(ctx context.Context) *ReadinessReport {
+	targets := make(map[string]*doctorTarget)
+
+	add := func(kind, name, formID string, probe Probe) {
+		key := probeKey(kind, name)
+		target, ok := targets[key]
+		if !ok {
+			if registered, ok := d.probes[key]; ok {
+				probe = registered
+			}
+			target = &doctorTarget{check: &ReadinessCheck{Kind: kind, Name: name}, probe: probe}
+			targets[key] = target
+		}
+		if formID != "" {
+			target.check.Forms = append(target.check.Forms, formID)
+		}
+	}
+
+	for _, schema := range d.schemas {
+		d.collectTargets(schema, add)
+	}
+	for key, probe := range d.probes {
+		if _, ok := targets[key]; !ok {
+			kind, name, _ := strings.Cut(key, ":")
+			add(kind, name, "", probe)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target *doctorTarget) {
+			defer wg.Done()
+			d.runProbe(ctx, target)
+		}(target)
+	}
+	wg.Wait()
+
+	report := &ReadinessReport{Ready: true, CheckedAt: time.Now()}
+	for _, target := range targets {
+		report.Checks = append(report.Checks, target.check)
+		if target.check.Status == CheckStatusFailed {
+			report.Ready = false
+		}
+	}
+	sort.Slice(report.Checks, func(i, j int) bool {
+		if report.Checks[i].Kind != report.Checks[j].Kind {
+			return report.Checks[i].Kind < report.Checks[j].Kind
+		}
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+
+	return report
+}
+
+// runProbe runs a target's probe with the doctor's timeout and records the outcome
+func (d *Doctor) runProbe(ctx context.Context, target *doctorTarget) {
+	check := target.check
+	if target.probe == nil {
+		check.Status = CheckStatusSkipped
+		check.Message = "no probe registered"
+		return
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := target.probe(probeCtx)
+	check.DurationMs = time.Since(started).Milliseconds()
+
+	var skipped probeSkipped
+	switch {
+	case errors.As(err, &skipped):
+		check.Status = CheckStatusSkipped
+		check.Message = string(skipped)
+	case err != nil:
+		check.Status = CheckStatusFailed
+		check.Message = err.Error()
+	default:
+		check.Status = CheckStatusOK
+	}
+}
+
+// probeSkipped is returned by probes that cannot check their dependency, with the reason
+type probeSkipped string
+
+func (ps probeSkipped) Error() string {
+	return string(ps)
+}
+
+// collectTargets finds the dependencies of a schema
+func (d *Doctor) collectTargets(schema *FormSchema, add func(kind, name, formID string, probe Probe)) {
+	var walk func(fields []*Field)
+	walk = func(fields []*Field) {
+		for _, field := range fields {
+			if field.Options != nil && field.Options.DynamicSource != nil {
+				source := field.Options.DynamicSource
+				switch source.Type {
+				case "api":
+					add(ProbeKindDynamicSource, source.Endpoint, schema.ID, d.httpProbe(source.Endpoint, source.Headers))
+				case "function":
+					add(ProbeKindDynamicSource, "function:"+source.FunctionName, schema.ID, d.functionProbe(schema, source))
+				}
+			}
+			if endpoint, ok := field.Properties["endpoint"].(string); ok && endpoint != "" {
+				add(ProbeKindDynamicSource, endpoint, schema.ID, d.httpProbe(endpoint, nil))
+			}
+			if connection, ok := field.Properties["connection"].(string); ok && connection != "" {
+				add(ProbeKindConnection, connection, schema.ID, d.connectionProbe(connection))
+			}
+			walk(field.Nested)
+		}
+	}
+	walk(schema.Fields)
+
+	if connection, ok := schema.Properties["connection"].(string); ok && connection != "" {
+		add(ProbeKindConnection, connection, schema.ID, d.connectionProbe(connection))
+	}
+	for _, ref := range schema.RemoteFragments {
+		add(ProbeKindRemoteFragment, ref.URL, schema.ID, d.httpProbe(ref.URL, nil))
+	}
+	if schema.Type == FormTypeAuth && schema.AuthType != "" {
+		add(ProbeKindAuth, string(schema.AuthType), schema.ID, d.authProbe(schema.AuthType))
+	}
+}
+
+// httpProbe checks that an endpoint answers. Endpoints with unresolved template variables
+// are probed at their host root. Any response below 500 counts as reachable, since a
+// probe cannot supply the parameters or credentials a real request would carry.
+func (d *Doctor) httpProbe(endpoint string, headers map[string]string) Probe {
+	return func(ctx context.Context) error {
+		target := endpoint
+		if strings.ContainsAny(target, "{}$") {
+			parsed, err := url.Parse(strings.SplitN(target, "{", 2)[0])
+			if err != nil || parsed.Host == "" {
+				return fmt.Errorf("cannot probe templated endpoint %s", endpoint)
+			}
+			target = parsed.Scheme + "://" + parsed.Host + "/"
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint: %w", err)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("endpoint returned %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// functionProbe checks that a function source's function is registered
+func (d *Doctor) functionProbe(schema *FormSchema, source *DynamicSource) Probe {
+	return func(ctx context.Context) error {
+		if source.DirectFunction != nil || schema.functions[source.FunctionName] != nil {
+			return nil
+		}
+		if d.functionService == nil {
+			return probeSkipped("no function service configured")
+		}
+		if !d.functionService.HasFunction(source.FunctionName) {
+			return fmt.Errorf("function %s is not registered", source.FunctionName)
+		}
+		return nil
+	}
+}
+
+// connectionProbe checks that a token is saved for the service a connection names
+func (d *Doctor) connectionProbe(serviceID string) Probe {
+	return func(ctx context.Context) error {
+		if d.authService == nil {
+			return probeSkipped("no auth service configured")
+		}
+		if len(d.authService.ServiceTokens(serviceID)) == 0 {
+			return fmt.Errorf("service %s is not connected", serviceID)
+		}
+		return nil
+	}
+}
+
+// authStrategyTypes maps the strategies of auth forms to the provider types serving them
+var authStrategyTypes = map[AuthStrategy]string{
+	AuthStrategyOAuth2: AuthTypeOAuth,
+	AuthStrategyBasic:  AuthTypeBasic,
+	AuthStrategyAPIKey: AuthTypeAPIKey,
+	AuthStrategyJWT:    AuthTypeJWT,
+	AuthStrategySAML:   AuthTypeSAML,
+}
+
+// authProbe checks that a default provider is registered for the strategy of an auth form
+func (d *Doctor) authProbe(strategy AuthStrategy) Probe {
+	return func(ctx context.Context) error {
+		authType, ok := authStrategyTypes[strategy]
+		if !ok {
+			return probeSkipped(fmt.Sprintf("%s auth has no provider to check", strategy))
+		}
+		if d.authService == nil {
+			return probeSkipped("no auth service configured")
+		}
+		_, err := d.authService.provider(authType, "")
+		return err
+	}
+}
@@ -0,0 +1,98 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctorReadinessMatrix(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer healthy.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	schema := NewForm("order", "Order").
+		AddField(NewFieldBuilder("product", FieldTypeSelect, "Product").
+			WithOptionsFromAPI(healthy.URL+"/products", "GET", "id", "name").Build()).
+		AddField(NewFieldBuilder("region", FieldTypeSelect, "Region").
+			WithOptionsFromAPI(broken.URL+"/regions/${country}", "GET", "id", "name").Build()).
+		AddField(NewFieldBuilder("warehouse", FieldTypeText, "Warehouse").
+			Property("connection", "inventory").Build()).
+		Build()
+
+	functions := NewDynamicFunctionService()
+	report := NewDoctor().
+		AddSchema(schema).
+		SetFunctionService(functions).
+		AddProbe(ProbeKindStorage, "submissions", func(ctx context.Context) error {
+			return errors.New("database unreachable")
+		}).
+		Run(context.Background())
+
+	statuses := map[string]CheckStatus{}
+	for _, check := range report.Checks {
+		statuses[check.Kind+" "+check.Name] = check.Status
+	}
+
+	assert.False(t, report.Ready)
+	assert.Equal(t, CheckStatusOK, statuses[ProbeKindDynamicSource+" "+healthy.URL+"/products"])
+	assert.Equal(t, CheckStatusFailed, statuses[ProbeKindDynamicSource+" "+broken.URL+"/regions/${country}"])
+	assert.Equal(t, CheckStatusSkipped, statuses[ProbeKindConnection+" inventory"])
+	assert.Equal(t, CheckStatusFailed, statuses[ProbeKindStorage+" submissions"])
+	require.Len(t, report.Checks, 4)
+	assert.Contains(t, report.String(), "not ready")
+}
+
+func TestHandlerDoctor(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "admin", r.Header.Get("X-Staff") == "yes"
+	})
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("warehouse", FieldTypeText, "Warehouse").Property("connection", "inventory").Build()).
+		AddField(NewFieldBuilder("supplier", FieldTypeText, "Supplier").Property("connection", "suppliers").Build()).
+		Build()))
+	require.NoError(t, handler.RegisterSchema(NewAuthForm("login", "Login", AuthStrategyBasic).Build()))
+	require.NoError(t, handler.RegisterSchema(NewAuthForm("sso", "SSO", AuthStrategySAML).Build()))
+	handler.authService.RegisterBasicAuthProvider("", NewFakeAuthProvider())
+	handler.authService.SetToken("inventory", "secret")
+	storage, err := NewLocalFileStorage(t.TempDir())
+	require.NoError(t, err)
+	handler.SetFileStorage(storage)
+
+	mux := handler.Handler()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/doctor", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the doctor is for staff only")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/doctor", nil)
+	req.Header.Set("X-Staff", "yes")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var report ReadinessReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	statuses := map[string]CheckStatus{}
+	for _, check := range report.Checks {
+		statuses[check.Kind+" "+check.Name] = check.Status
+	}
+	assert.Equal(t, map[string]CheckStatus{
+		ProbeKindConnection + " inventory": CheckStatusOK,
+		ProbeKindConnection + " suppliers": CheckStatusFailed,
+		ProbeKindAuth + " basic":           CheckStatusOK,
+		ProbeKindAuth + " saml":            CheckStatusFailed,
+		ProbeKindStorage + " files":        CheckStatusOK,
+	}, statuses)
+}
@@ -0,0 +1,55 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// DuplicateAction is what happens when a submission matches an earlier one
+type DuplicateAction string
+
+// Define duplicate actions
+const (
+	DuplicateActionWarn  DuplicateAction = "warn"  // Accept the submission and report the duplicates (default)
+	DuplicateActionLink  DuplicateAction = "link"  // Accept the submission and link it to the earlier one
+	DuplicateActionBlock DuplicateAction = "block" // Reject the submission
+)
+
+// Values provides the possible values for DuplicateAction, compatible with entgo.
+func (DuplicateAction) Values() (types []string) {
+	return []string{
+		string(DuplicateActionWarn),
+		string(DuplicateActionLink),
+		string(DuplicateActionBlock),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (a DuplicateAction) MarshalText() ([]byte, error) {
+	return []byte(a), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (a *DuplicateAction) UnmarshalText(text []byte) error {
+	switch DuplicateAction(text) {
+	case DuplicateActionWarn, DuplicateActionLink, DuplicateActionBlock:
+		*a = DuplicateAction(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid DuplicateAction: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (a *DuplicateAction) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("DuplicateAction should be a string, got %T", value)
+	}
+	return a.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (a DuplicateAction) Value() (driver.Value, error) {
+	return string(a), nil
+}
@@ -0,0 +1,177 @@
+package smartform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DuplicateRule declares that two submissions are duplicates when all of the given fields match
+type DuplicateRule struct {
+	Fields []string        `json:"fields"`
+	Fuzzy  bool            `json:"fuzzy,omitempty"` // Normalize values and tolerate small typos in names
+	Action DuplicateAction `json:"action,omitempty"`
+}
+
+// DuplicateMatch is an earlier submission suspected to be a duplicate
+type DuplicateMatch struct {
+	SubmissionID string          `json:"submissionId"`
+	Fields       []string        `json:"fields"`
+	Action       DuplicateAction `json:"action"`
+}
+
+// FindDuplicates returns the earlier submissions that match the data under any of the
+// schema's duplicate rules, and the strictest action among the matched rules
+func (fs *FormSchema) FindDuplicates(data map[string]interface{}, submissions []*Submission) ([]*DuplicateMatch, DuplicateAction) {
+	matches := []*DuplicateMatch{}
+	action := DuplicateAction("")
+	validator := NewValidator(fs)
+
+	for _, rule := range fs.Duplicates {
+		ruleAction := rule.Action
+		if ruleAction == "" {
+			ruleAction = DuplicateActionWarn
+		}
+
+		for _, submission := range submissions {
+			if !duplicateRuleMatches(validator, rule, data, submission.Data) {
+				continue
+			}
+			matches = append(matches, &DuplicateMatch{
+				SubmissionID: submission.ID,
+				Fields:       rule.Fields,
+				Action:       ruleAction,
+			})
+			if duplicateActionRank(ruleAction) > duplicateActionRank(action) {
+				action = ruleAction
+			}
+		}
+	}
+
+	return matches, action
+}
+
+// duplicateRuleMatches reports whether two submissions match on every field of a rule
+func duplicateRuleMatches(validator *Validator, rule *DuplicateRule, data, other map[string]interface{}) bool {
+	if len(rule.Fields) == 0 {
+		return false
+	}
+
+	for _, fieldID := range rule.Fields {
+		a := validator.getValueByPath(data, fieldID)
+		b := validator.getValueByPath(other, fieldID)
+		if a == nil || b == nil {
+			return false
+		}
+
+		if !rule.Fuzzy {
+			if !optionValuesEqual(a, b) {
+				return false
+			}
+			continue
+		}
+
+		if !fuzzyEqual(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)) {
+			return false
+		}
+	}
+	return true
+}
+
+// duplicateActionRank orders actions from least to most strict
+func duplicateActionRank(action DuplicateAction) int {
+	switch action {
+	case DuplicateActionWarn:
+		return 1
+	case DuplicateActionLink:
+		return 2
+	case DuplicateActionBlock:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// fuzzyEqual compares two values after normalization. Emails must match exactly once
+// normalized; other text may differ by one edit when it is at least five characters long.
+func fuzzyEqual(a, b string) bool {
+	if strings.Contains(a, "@") || strings.Contains(b, "@") {
+		return normalizeEmail(a) == normalizeEmail(b)
+	}
+
+	a, b = normalizeText(a), normalizeText(b)
+	if a == b {
+		return true
+	}
+	if len([]rune(a)) < 5 || len([]rune(b)) < 5 {
+		return false
+	}
+	return editDistance(a, b) <= 1
+}
+
+// normalizeEmail lowercases an email address and drops "+tag" suffixes from the local part
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	if i := strings.Index(local, "+"); i >= 0 {
+		local = local[:i]
+	}
+	return local + "@" + domain
+}
+
+// normalizeText lowercases text, drops punctuation, and collapses whitespace
+func normalizeText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// DetectDuplicates adds a duplicate rule matching on the given field combination
+func (fb *FormBuilder) DetectDuplicates(action DuplicateAction, fields ...string) *FormBuilder {
+	fb.schema.Duplicates = append(fb.schema.Duplicates, &DuplicateRule{Fields: fields, Action: action})
+	return fb
+}
+
+// DetectFuzzyDuplicates adds a duplicate rule that matches normalized values and tolerates small typos
+func (fb *FormBuilder) DetectFuzzyDuplicates(action DuplicateAction, fields ...string) *FormBuilder {
+	fb.schema.Duplicates = append(fb.schema.Duplicates, &DuplicateRule{Fields: fields, Fuzzy: true, Action: action})
+	return fb
+}
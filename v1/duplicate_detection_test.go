@@ -0,0 +1,46 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	schema := NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Build()).
+		AddField(NewFieldBuilder("phone", FieldTypeText, "Phone").Build()).
+		DetectDuplicates(DuplicateActionBlock, "phone").
+		DetectFuzzyDuplicates(DuplicateActionLink, "name", "email").
+		Build()
+
+	earlier := NewSubmission("signup", map[string]interface{}{
+		"name":  "Jonathan Smith",
+		"email": "jon.smith+promo@Example.com",
+		"phone": "555-0100",
+	})
+
+	matches, action := schema.FindDuplicates(map[string]interface{}{
+		"name":  "jonathon  smith.",
+		"email": "jon.smith@example.com",
+		"phone": "555-0199",
+	}, []*Submission{earlier})
+	require.Len(t, matches, 1)
+	assert.Equal(t, DuplicateActionLink, action)
+	assert.Equal(t, earlier.ID, matches[0].SubmissionID)
+
+	_, action = schema.FindDuplicates(map[string]interface{}{
+		"name":  "Someone Else",
+		"email": "other@example.com",
+		"phone": "555-0100",
+	}, []*Submission{earlier})
+	assert.Equal(t, DuplicateActionBlock, action)
+
+	matches, _ = schema.FindDuplicates(map[string]interface{}{
+		"name":  "Jonathan Smith",
+		"email": "jonsmith@example.com",
+	}, []*Submission{earlier})
+	assert.Empty(t, matches)
+}
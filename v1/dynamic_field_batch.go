@@ -0,0 +1,181 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultBatchWorkers is how many fields of a dynamic field batch are computed at once by
+// default
+const DefaultBatchWorkers = 4
+
+// MaxDynamicFieldBatch is the most fields a dynamic field batch may list
+const MaxDynamicFieldBatch = 100
+
+// WithBatchWorkers sets how many fields of a dynamic field batch are computed at once
+func WithBatchWorkers(workers int) HandlerOption {
+	return func(ah *APIHandler) {
+		ah.batchWorkers = workers
+	}
+}
+
+// DynamicFieldBatchRequest asks for the values and options of several dynamic fields at once,
+// e.g. of every field depending on a field the user changed
+type DynamicFieldBatchRequest struct {
+	Fields    []string                       `json:"fields"`
+	Configs   map[string]*DynamicFieldConfig `json:"configs,omitempty"` // Function configs computing the values of fields, by field ID
+	FormState map[string]interface{}         `json:"formState,omitempty"`
+	SessionID string                         `json:"sessionId,omitempty"`
+	Attempt   string                         `json:"attempt,omitempty"`
+}
+
+// DynamicFieldResult is the outcome of one field of a batch: the value computed by its
+// function config, or else its options, or the error computing them
+type DynamicFieldResult struct {
+	Value    interface{} `json:"value,omitempty"`
+	Options  []*Option   `json:"options,omitempty"`
+	Token    string      `json:"token,omitempty"`    // Idempotency token the function ran with, if any
+	Replayed bool        `json:"replayed,omitempty"` // Whether the value is that of an earlier call
+	Error    *APIError   `json:"error,omitempty"`
+}
+
+// DynamicFieldBatch holds the results of a dynamic field batch by field ID
+type DynamicFieldBatch struct {
+	Results map[string]*DynamicFieldResult `json:"results"`
+}
+
+// isBatchRequest reports whether a request to /api/field/dynamic/{formId}/batch asks for a
+// batch, whose body lists "fields", rather than for the value of a field named batch. The body
+// is read and put back with its limits for the handler to decode.
+func isBatchRequest(r *http.Request) (bool, error) {
+	limits := requestLimits(r.Body)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return false, readError(err)
+	}
+	r.Body = &limitedBody{ReadCloser: io.NopCloser(bytes.NewReader(data)), limits: limits}
+
+	// Malformed bodies are reported by the handler decoding them
+	var probe struct {
+		Fields json.RawMessage `json:"fields"`
+	}
+	_ = json.Unmarshal(data, &probe)
+	return probe.Fields != nil, nil
+}
+
+// handleDynamicFieldBatch handles requests to recompute several dynamic fields of a form at
+// once. Fields are computed concurrently by a bounded pool of workers, and fail on their own:
+// the batch succeeds with the error of each failed field.
+func (ah *APIHandler) handleDynamicFieldBatch(w http.ResponseWriter, r *http.Request, formID string) {
+	var request DynamicFieldBatchRequest
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(request.Fields) == 0 {
+		writeError(w, badRequest("Field IDs are required"))
+		return
+	}
+	if len(request.Fields) > MaxDynamicFieldBatch {
+		writeError(w, badRequest(fmt.Sprintf("A batch may list at most %d fields", MaxDynamicFieldBatch)))
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	batch := &DynamicFieldBatch{Results: make(map[string]*DynamicFieldResult, len(request.Fields))}
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	workers := ah.batchWorkers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	for range min(workers, len(request.Fields)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fieldID := range jobs {
+				result := ah.batchField(r, schema, fieldID, &request)
+				lock.Lock()
+				batch.Results[fieldID] = result
+				lock.Unlock()
+			}
+		}()
+	}
+	seen := make(map[string]bool, len(request.Fields))
+	for _, fieldID := range request.Fields {
+		if !seen[fieldID] {
+			seen[fieldID] = true
+			jobs <- fieldID
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(batch)
+}
+
+// batchField computes one field of a batch. Panics fail the field alone, since they would
+// otherwise take down the server from the worker.
+func (ah *APIHandler) batchField(r *http.Request, schema *FormSchema, fieldID string, request *DynamicFieldBatchRequest) (result *DynamicFieldResult) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result = &DynamicFieldResult{Error: AsAPIError(fmt.Errorf("%s: %w: %v", fieldID, ErrFunctionPanicked, recovered))}
+		}
+	}()
+
+	field := schema.FindFieldByID(fieldID)
+	if field == nil {
+		return &DynamicFieldResult{Error: fieldNotFound(fieldID)}
+	}
+	// Each field gets its own copy of the form state, which functions may modify
+	state := ah.withRenderContext(r, cloneMap(request.FormState))
+	if state == nil {
+		state = map[string]interface{}{}
+	}
+
+	if config, ok := request.Configs[fieldID]; ok && config != nil {
+		if ah.dynamicFunctionService == nil {
+			return &DynamicFieldResult{Error: notConfigured(http.StatusInternalServerError, "Dynamic function service not configured")}
+		}
+		call := functionCall(r, request.SessionID, fieldID, request.Attempt)
+		if call.Key != "" {
+			call.Key += "/" + fieldID // Each field runs with its own token
+		}
+		called, err := config.ExecuteCall(ah.dynamicFunctionService, call, state)
+		if err != nil {
+			return &DynamicFieldResult{Error: batchError("Error executing dynamic field function", err)}
+		}
+		return &DynamicFieldResult{Value: called.Value, Token: called.Token, Replayed: called.Replayed}
+	}
+
+	if field.Options == nil {
+		return &DynamicFieldResult{Error: badRequest("Field has neither a function config nor options")}
+	}
+	if isWindowed(field) {
+		return &DynamicFieldResult{Error: badRequest("Field options are windowed; fetch them from the options endpoint")}
+	}
+	options, err := ah.fieldOptions(r, schema, field, state)
+	if err != nil {
+		return &DynamicFieldResult{Error: batchError("Error fetching dynamic options", err)}
+	}
+	return &DynamicFieldResult{Options: options}
+}
+
+// batchError describes the error of a field of a batch as the single-field endpoints would
+func batchError(prefix string, err error) *APIError {
+	if isFunctionCallError(err) {
+		return AsAPIError(err)
+	}
+	return AsAPIError(fmt.Errorf("%s: %w", prefix, err))
+}
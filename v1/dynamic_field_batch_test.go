@@ -0,0 +1,102 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicFieldBatch(t *testing.T) {
+	city := NewFieldBuilder("city", FieldTypeSelect, "City").Build()
+	city.Options = NewOptionsBuilder().Dynamic().FromFunction("cities").Build()
+	handler := NewAPIHandler(WithBatchWorkers(2))
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("country", FieldTypeText, "Country").Build()).
+		AddField(city).
+		AddField(NewFieldBuilder("region", FieldTypeSelect, "Region").
+			WithDependentOptions("country", map[string][]*Option{"NG": {{Value: "south", Label: "South"}}}).Build()).
+		AddField(NewFieldBuilder("shipping", FieldTypeNumber, "Shipping").Build()).
+		AddField(NewFieldBuilder("batch", FieldTypeNumber, "Batch").Build()).
+		AddField(NewFieldBuilder("notes", FieldTypeText, "Notes").Build()).
+		Build()))
+
+	var running, most int32
+	slow := func(next DynamicFunction) DynamicFunction {
+		return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return next(args, formState)
+		}
+	}
+	service := NewDynamicFunctionService()
+	service.Use(slow)
+	service.RegisterFunction("cities", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		formState["mutated"] = true
+		return []*Option{{Value: formState["country"].(string) + "-1", Label: "First"}}, nil
+	})
+	service.RegisterFunction("shippingCost", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		if _, ok := formState["mutated"]; ok {
+			t.Error("fields share the form state")
+		}
+		return 12.5, nil
+	})
+	service.RegisterFunction("broken", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		panic("nil map")
+	})
+	handler.SetDynamicFunctionService(service)
+	mux := handler.Handler()
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post("/api/field/dynamic/order/batch", `{
+		"fields": ["city", "region", "shipping", "notes", "missing", "city"],
+		"configs": {"shipping": {"functionName": "shippingCost"}, "notes": {"functionName": "broken"}},
+		"formState": {"country": "NG"}
+	}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var batch DynamicFieldBatch
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	require.Len(t, batch.Results, 5)
+
+	require.Len(t, batch.Results["city"].Options, 1)
+	assert.Equal(t, "NG-1", batch.Results["city"].Options[0].Value)
+	require.Len(t, batch.Results["region"].Options, 1)
+	assert.Equal(t, "south", batch.Results["region"].Options[0].Value)
+	assert.Equal(t, 12.5, batch.Results["shipping"].Value)
+	assert.Nil(t, batch.Results["shipping"].Error)
+	require.NotNil(t, batch.Results["notes"].Error)
+	assert.Equal(t, ErrorCodeInternal, batch.Results["notes"].Error.Code)
+	require.NotNil(t, batch.Results["missing"].Error)
+	assert.Equal(t, ErrorCodeFieldNotFound, batch.Results["missing"].Error.Code)
+	assert.LessOrEqual(t, atomic.LoadInt32(&most), int32(2), "fields are computed by at most two workers")
+
+	rec = post("/api/field/dynamic/order/batch", `{"fields": []}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = post("/api/field/dynamic/missing/batch", `{"fields": ["city"]}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Bodies without fields go to the field called batch
+	rec = post("/api/field/dynamic/order/batch", `{"config": {"functionName": "shippingCost"}, "formState": {}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `12.5`, rec.Body.String())
+	assert.Equal(t, http.StatusBadRequest, post("/api/field/dynamic/order", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/api/options/dynamic/order", `{}`).Code)
+}
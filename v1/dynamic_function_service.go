@@ -1,11 +1,13 @@
 package smartform
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 )
 
 // DynamicFunctionService manages and executes dynamic functions for form fields
@@ -14,6 +16,15 @@ type DynamicFunctionService struct {
 	functionLock  sync.RWMutex
 	transformers  map[string]DataTransformer
 	transformLock sync.RWMutex
+	sessions      *SessionMemoryStore
+	sideEffects   map[string]SideEffectClass // Side-effect classes functions were declared with
+	idempotency   *idempotencyStore
+	telemetry     Telemetry
+	caches        map[string]*functionCache // Caches of the functions registered with one
+
+	interceptors   []FunctionInterceptor    // Wrap every call, the first one outermost
+	timeouts       map[string]time.Duration // How long calls may run, by function name
+	defaultTimeout time.Duration            // How long calls of functions without a timeout may run
 }
 
 // DynamicFunction represents a function that can be called at runtime
@@ -27,14 +38,84 @@ func NewDynamicFunctionService() *DynamicFunctionService {
 	return &DynamicFunctionService{
 		functions:    make(map[string]DynamicFunction),
 		transformers: make(map[string]DataTransformer),
+		sessions:     NewSessionMemoryStore(),
+		sideEffects:  make(map[string]SideEffectClass),
+		idempotency:  newIdempotencyStore(),
+		timeouts:     make(map[string]time.Duration),
+		caches:       make(map[string]*functionCache),
 	}
 }
 
+// Clone returns a new service with the same functions, transformers, interceptors, timeouts,
+// and function caches, but its own session memory and idempotency records, so that functions
+// registered with either do not affect the other
+func (dfs *DynamicFunctionService) Clone() *DynamicFunctionService {
+	clone := NewDynamicFunctionService()
+
+	dfs.functionLock.RLock()
+	for name, fn := range dfs.functions {
+		clone.functions[name] = fn
+	}
+	for name, class := range dfs.sideEffects {
+		clone.sideEffects[name] = class
+	}
+	for name, cached := range dfs.caches {
+		clone.caches[name] = cached
+	}
+	clone.interceptors = append(clone.interceptors, dfs.interceptors...)
+	for name, timeout := range dfs.timeouts {
+		clone.timeouts[name] = timeout
+	}
+	clone.defaultTimeout = dfs.defaultTimeout
+	dfs.functionLock.RUnlock()
+
+	dfs.transformLock.RLock()
+	for name, transformer := range dfs.transformers {
+		clone.transformers[name] = transformer
+	}
+	dfs.transformLock.RUnlock()
+	return clone
+}
+
+// SessionMemory returns the store holding the per-session scratch memory of functions
+func (dfs *DynamicFunctionService) SessionMemory() *SessionMemoryStore {
+	return dfs.sessions
+}
+
+// SetSessionMemory replaces the store holding the per-session scratch memory of functions
+func (dfs *DynamicFunctionService) SetSessionMemory(store *SessionMemoryStore) {
+	dfs.sessions = store
+}
+
+// WithSession returns a copy of formState carrying the scratch memory of a session under
+// SessionMemoryKey, for functions to read with SessionMemoryFrom. formState is returned
+// unchanged when there is no session.
+func (dfs *DynamicFunctionService) WithSession(sessionID string, formState map[string]interface{}) map[string]interface{} {
+	if sessionID == "" || dfs.sessions == nil {
+		return formState
+	}
+	state := make(map[string]interface{}, len(formState)+1)
+	for key, value := range formState {
+		state[key] = value
+	}
+	state[SessionMemoryKey] = dfs.sessions.Session(sessionID)
+	return state
+}
+
 // RegisterFunction registers a dynamic function
 func (dfs *DynamicFunctionService) RegisterFunction(name string, fn DynamicFunction) {
 	dfs.functionLock.Lock()
 	defer dfs.functionLock.Unlock()
 	dfs.functions[name] = fn
+	delete(dfs.caches, name)
+}
+
+// HasFunction reports whether a dynamic function is registered
+func (dfs *DynamicFunctionService) HasFunction(name string) bool {
+	dfs.functionLock.RLock()
+	defer dfs.functionLock.RUnlock()
+	_, ok := dfs.functions[name]
+	return ok
 }
 
 // RegisterTransformer registers a data transformer
@@ -52,6 +133,9 @@ func (dfs *DynamicFunctionService) ExecuteFunction(
 ) (interface{}, error) {
 	dfs.functionLock.RLock()
 	fn, exists := dfs.functions[functionName]
+	if exists {
+		fn = dfs.intercept(functionName, fn)
+	}
 	dfs.functionLock.RUnlock()
 
 	if !exists {
@@ -62,7 +146,23 @@ func (dfs *DynamicFunctionService) ExecuteFunction(
 	processedArgs := dfs.processTemplateVars(args, formState)
 
 	// Execute the function
-	return fn(processedArgs, formState)
+	start := time.Now()
+	ctx, span := startSpan(dfs.telemetry, context.Background(), "smartform.function",
+		attribute("smartform.function", functionName))
+	result, err := fn(processedArgs, formState)
+	endSpan(span, err)
+	recordDuration(dfs.telemetry, ctx, MetricFunctionDuration, start,
+		attribute("smartform.function", functionName),
+		attribute("error", err != nil))
+	return result, err
+}
+
+// HasTransformer reports whether a data transformer is registered
+func (dfs *DynamicFunctionService) HasTransformer(name string) bool {
+	dfs.transformLock.RLock()
+	defer dfs.transformLock.RUnlock()
+	_, ok := dfs.transformers[name]
+	return ok
 }
 
 // TransformData applies a transformer to the given data
@@ -190,11 +290,21 @@ func (dfs *DynamicFunctionService) matchesFilter(
 	return true
 }
 
-// SearchAndSort searches and sorts options based on criteria
+// SearchAndSort searches and sorts options based on criteria. Options are paged by the
+// "cursor" parameter when it is given, and by "offset" otherwise; see SearchAndSortPage for
+// the cursor of the next page.
 func (dfs *DynamicFunctionService) SearchAndSort(
 	options []*Option,
 	searchParams map[string]interface{},
 ) ([]*Option, error) {
+	if cursor, _ := searchParams["cursor"].(string); cursor != "" {
+		page, err := dfs.SearchAndSortPage(options, searchParams)
+		if err != nil {
+			return nil, err
+		}
+		return page.Options, nil
+	}
+
 	// Extract parameters
 	search, _ := searchParams["search"].(string)
 	sort, _ := searchParams["sort"].(string)
@@ -382,18 +492,33 @@ func (dfc *DynamicFieldConfig) ExecuteWithFormState(
 	service *DynamicFunctionService,
 	formState map[string]interface{},
 ) (interface{}, error) {
+	result, err := dfc.ExecuteCall(service, nil, formState)
+	if err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// ExecuteCall executes the dynamic field function with form state under the idempotency
+// contract, see DynamicFunctionService.ExecuteFunctionCall
+func (dfc *DynamicFieldConfig) ExecuteCall(
+	service *DynamicFunctionService,
+	call *FunctionCall,
+	formState map[string]interface{},
+) (*FunctionResult, error) {
 	// Execute the function
-	result, err := service.ExecuteFunction(dfc.FunctionName, dfc.Arguments, formState)
+	result, err := service.ExecuteFunctionCall(dfc.FunctionName, call, dfc.Arguments, formState)
 	if err != nil {
 		return nil, err
 	}
 
 	// Apply transformer if specified
 	if dfc.TransformerName != "" {
-		result, err = service.TransformData(dfc.TransformerName, result, dfc.TransformerParams)
+		transformed, err := service.TransformData(dfc.TransformerName, result.Value, dfc.TransformerParams)
 		if err != nil {
 			return nil, err
 		}
+		result = &FunctionResult{Value: transformed, Token: result.Token, Replayed: result.Replayed}
 	}
 
 	return result, nil
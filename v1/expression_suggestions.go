@@ -0,0 +1,146 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/juicycleff/smartform/v1/template"
+)
+
+// ExpressionSuggestions returns autocomplete suggestions for a partial template expression,
+// e.g. "customer.", covering the form's fields as well as the variables and functions of its
+// registry. Fields come first, in form order, and shadow variables with the same name.
+func (fs *FormSchema) ExpressionSuggestions(partialExpr string) []*template.VariableSuggestion {
+	suggestions := fieldSuggestions("", fs.Fields)
+	seen := make(map[string]bool, len(suggestions))
+	for _, suggestion := range suggestions {
+		seen[suggestion.Expr] = true
+	}
+
+	registry := fs.variableRegistry
+	if registry == nil {
+		registry = template.NewVariableRegistry()
+	}
+	variables := registry.GenerateVariableSuggestions()
+	sort.SliceStable(variables, func(i, j int) bool {
+		return variables[i].Expr < variables[j].Expr
+	})
+	for _, suggestion := range variables {
+		if !seen[suggestion.Expr] {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	return template.SuggestExpressions(suggestions, partialExpr)
+}
+
+// fieldSuggestions returns suggestions for the values of fields, with their paths under a prefix
+func fieldSuggestions(prefix string, fields []*Field) []*template.VariableSuggestion {
+	suggestions := []*template.VariableSuggestion{}
+	for _, field := range fields {
+		// Sections without fields and display fields hold no values
+		if (field.Type == FieldTypeSection && len(field.Nested) == 0) || field.Type == FieldTypeDisplay {
+			continue
+		}
+
+		expr := field.ID
+		if prefix != "" {
+			expr = prefix + "." + field.ID
+		}
+		suggestion := &template.VariableSuggestion{
+			Expr:        expr,
+			Type:        fieldValueType(field),
+			Description: field.Label,
+			Value:       field.DefaultValue,
+			IsNested:    prefix != "",
+		}
+		suggestions = append(suggestions, suggestion)
+
+		switch field.Type {
+		case FieldTypeGroup, FieldTypeObject, FieldTypeSection:
+			nested := fieldSuggestions(expr, field.Nested)
+			suggestion.Children = childNames(expr, nested)
+			suggestions = append(suggestions, nested...)
+		case FieldTypeArray:
+			item := expr + "[0]"
+			suggestion.ArrayInfo = &template.ArrayInfo{ItemType: "object", SampleAccess: item}
+			nested := fieldSuggestions(item, field.Nested)
+			suggestions = append(suggestions, &template.VariableSuggestion{
+				Expr:        item,
+				Type:        "object",
+				Description: "First item of " + field.Label,
+				Children:    childNames(item, nested),
+				IsNested:    true,
+			})
+			suggestions = append(suggestions, nested...)
+		case FieldTypeDateRange:
+			suggestion.Children = []string{"end", "start"}
+			for _, child := range suggestion.Children {
+				suggestions = append(suggestions, &template.VariableSuggestion{
+					Expr:        expr + "." + child,
+					Type:        "date",
+					Description: "Property of " + expr,
+					IsNested:    true,
+				})
+			}
+		}
+	}
+	return suggestions
+}
+
+// childNames returns the names of the direct children of a path among suggestions
+func childNames(expr string, suggestions []*template.VariableSuggestion) []string {
+	names := []string{}
+	for _, suggestion := range suggestions {
+		name := suggestion.Expr[len(expr)+1:]
+		if !strings.ContainsAny(name, ".[") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// fieldValueType returns the type of a field's value, as suggestions name types
+func fieldValueType(field *Field) string {
+	switch field.Type {
+	case FieldTypeNumber, FieldTypeSlider, FieldTypeRating:
+		return "number"
+	case FieldTypeCheckbox, FieldTypeSwitch:
+		return "boolean"
+	case FieldTypeMultiSelect:
+		return "array<string>"
+	case FieldTypeDate, FieldTypeDateTime:
+		return "date"
+	case FieldTypeArray:
+		return "array<object>"
+	case FieldTypeGroup, FieldTypeObject, FieldTypeSection, FieldTypeDateRange:
+		return "object"
+	case FieldTypeOneOf, FieldTypeAnyOf, FieldTypeCustom:
+		return "any"
+	default:
+		return "string"
+	}
+}
+
+// handleExpressionSuggestions handles requests for autocomplete suggestions of template
+// expressions in a form, for the partial expression given by the q query parameter
+func (ah *APIHandler) handleExpressionSuggestions(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchemaVersion(formID, r.URL.Query().Get(VersionQueryParam))
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       r.URL.Query().Get("q"),
+		"suggestions": schema.ExpressionSuggestions(r.URL.Query().Get("q")),
+	})
+}
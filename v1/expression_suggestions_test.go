@@ -0,0 +1,66 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juicycleff/smartform/v1/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressionSuggestions(t *testing.T) {
+	form := NewForm("order", "Order")
+	customer := form.GroupField("customer", "Customer")
+	customer.TextField("name", "Name")
+	customer.EmailField("email", "Email")
+	lines := form.ArrayField("lines", "Lines")
+	lines.NumberField("quantity", "Quantity")
+	form.NumberField("total", "Total")
+	schema := form.Build()
+	schema.RegisterVariable("currency", "EUR")
+	schema.RegisterVariable("customerTier", "gold")
+
+	exprs := func(suggestions []*template.VariableSuggestion) []string {
+		result := []string{}
+		for _, suggestion := range suggestions {
+			result = append(result, suggestion.Expr)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"customer.name", "customer.email"}, exprs(schema.ExpressionSuggestions("customer.")))
+	assert.Equal(t, []string{"customer", "customer.name", "customer.email", "customerTier"}, exprs(schema.ExpressionSuggestions("customer")))
+	assert.Equal(t, "lines[0].quantity", exprs(schema.ExpressionSuggestions("lines[0]."))[0])
+	assert.Contains(t, exprs(schema.ExpressionSuggestions("concat(tot")), "total")
+
+	suggestions := schema.ExpressionSuggestions("sum")
+	require.Len(t, suggestions, 1)
+	assert.True(t, suggestions[0].IsFunction)
+	assert.Equal(t, "sum(array, [path])", suggestions[0].Signature)
+
+	// Over HTTP
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(schema))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/order/expressions/suggest?q=customer.n", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response struct {
+		Query       string                         `json:"query"`
+		Suggestions []*template.VariableSuggestion `json:"suggestions"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "customer.n", response.Query)
+	require.Len(t, response.Suggestions, 1)
+	assert.Equal(t, "Name", response.Suggestions[0].Description)
+	assert.Equal(t, "string", response.Suggestions[0].Type)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/missing/expressions/suggest?q=a", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,154 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFakeAuthRejected is returned by a FakeAuthProvider for unknown users and API keys
+var ErrFakeAuthRejected = errors.New("invalid credentials")
+
+// FakeAuthCall records a call made to a FakeAuthProvider
+type FakeAuthCall struct {
+	Type  string            // Auth type of the call
+	Data  map[string]string // Credentials or configuration passed to the provider
+	Token string            // Token issued, empty if the call was rejected
+}
+
+// FakeAuthProvider is an in-memory provider for every auth type, for tests and local
+// development. It accepts the users and API keys added to it and every OAuth, JWT, and SAML
+// request, issuing tokens such as "fake-oauth-1", and records each call. OAuth tokens can be
+// refreshed and every token revoked, which is recorded as a "refresh" or "revoke" call.
+type FakeAuthProvider struct {
+	// Err, when set, is returned by every call, e.g. to simulate an unavailable provider
+	Err error
+	// OAuthTTL, when set, makes OAuth tokens expire after it and come with a refresh token
+	OAuthTTL time.Duration
+
+	users   map[string]string
+	apiKeys map[string]bool
+	calls   []*FakeAuthCall
+	lock    sync.Mutex
+}
+
+// NewFakeAuthProvider creates a fake provider without users or API keys
+func NewFakeAuthProvider() *FakeAuthProvider {
+	return &FakeAuthProvider{
+		users:   make(map[string]string),
+		apiKeys: make(map[string]bool),
+	}
+}
+
+// AddUser adds a user accepted by basic auth
+func (fp *FakeAuthProvider) AddUser(username, password string) *FakeAuthProvider {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	fp.users[username] = password
+	return fp
+}
+
+// AddAPIKey adds an API key accepted by API key auth
+func (fp *FakeAuthProvider) AddAPIKey(apiKey string) *FakeAuthProvider {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	fp.apiKeys[apiKey] = true
+	return fp
+}
+
+// Calls returns the calls made to the provider so far
+func (fp *FakeAuthProvider) Calls() []*FakeAuthCall {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	return append([]*FakeAuthCall(nil), fp.calls...)
+}
+
+// AuthenticateOAuth accepts every OAuth request
+func (fp *FakeAuthProvider) AuthenticateOAuth(ctx context.Context, config map[string]string) (string, error) {
+	return fp.authenticate(AuthTypeOAuth, config, true)
+}
+
+// AuthenticateOAuthToken accepts every OAuth request, granting the space-separated scopes of
+// config["scope"]
+func (fp *FakeAuthProvider) AuthenticateOAuthToken(ctx context.Context, config map[string]string) (*TokenInfo, error) {
+	token, err := fp.authenticate(AuthTypeOAuth, config, true)
+	if err != nil {
+		return nil, err
+	}
+	return fp.oauthToken(token, strings.Fields(config["scope"])), nil
+}
+
+// RefreshOAuth refreshes tokens that came with a refresh token
+func (fp *FakeAuthProvider) RefreshOAuth(ctx context.Context, token *TokenInfo) (*TokenInfo, error) {
+	refreshed, err := fp.authenticate("refresh", map[string]string{"refreshToken": token.RefreshToken}, token.RefreshToken != "")
+	if err != nil {
+		return nil, err
+	}
+	return fp.oauthToken(refreshed, token.Scopes), nil
+}
+
+// RevokeToken accepts every revocation
+func (fp *FakeAuthProvider) RevokeToken(ctx context.Context, token *TokenInfo) error {
+	_, err := fp.authenticate("revoke", map[string]string{"token": token.Token}, true)
+	return err
+}
+
+// oauthToken adds the metadata of OAuthTTL to an OAuth token
+func (fp *FakeAuthProvider) oauthToken(token string, scopes []string) *TokenInfo {
+	info := &TokenInfo{Token: token, Scopes: scopes}
+	if fp.OAuthTTL > 0 {
+		info.ExpiresAt = time.Now().Add(fp.OAuthTTL)
+		info.RefreshToken = token + "-refresh"
+	}
+	return info
+}
+
+// AuthenticateBasic accepts the users added with AddUser
+func (fp *FakeAuthProvider) AuthenticateBasic(ctx context.Context, username, password string) (string, error) {
+	fp.lock.Lock()
+	expected, ok := fp.users[username]
+	fp.lock.Unlock()
+	return fp.authenticate(AuthTypeBasic, map[string]string{"username": username}, ok && expected == password)
+}
+
+// AuthenticateAPIKey accepts the API keys added with AddAPIKey
+func (fp *FakeAuthProvider) AuthenticateAPIKey(ctx context.Context, apiKey string) (string, error) {
+	fp.lock.Lock()
+	ok := fp.apiKeys[apiKey]
+	fp.lock.Unlock()
+	return fp.authenticate(AuthTypeAPIKey, map[string]string{"apiKey": apiKey}, ok)
+}
+
+// AuthenticateJWT accepts every JWT request
+func (fp *FakeAuthProvider) AuthenticateJWT(ctx context.Context, config map[string]string) (string, error) {
+	return fp.authenticate(AuthTypeJWT, config, true)
+}
+
+// AuthenticateSAML accepts every SAML request
+func (fp *FakeAuthProvider) AuthenticateSAML(ctx context.Context, config map[string]string) (string, error) {
+	return fp.authenticate(AuthTypeSAML, config, true)
+}
+
+// authenticate records a call and issues a token if it is accepted
+func (fp *FakeAuthProvider) authenticate(authType string, data map[string]string, accepted bool) (string, error) {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+
+	call := &FakeAuthCall{Type: authType, Data: make(map[string]string, len(data))}
+	for key, value := range data {
+		call.Data[key] = value
+	}
+	fp.calls = append(fp.calls, call)
+
+	if fp.Err != nil {
+		return "", fp.Err
+	}
+	if !accepted {
+		return "", ErrFakeAuthRejected
+	}
+	call.Token = fmt.Sprintf("fake-%s-%d", authType, len(fp.calls))
+	return call.Token, nil
+}
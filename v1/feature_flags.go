@@ -0,0 +1,48 @@
+package smartform
+
+import "net/http"
+
+// FeatureFlagsVariable is the context variable feature flags are available under, so templates
+// and conditions refer to them as "${_flags.newPricing}" or "_flags.newPricing"
+const FeatureFlagsVariable = "_flags"
+
+// FeatureFlagProvider evaluates feature flags, such as those of a rollout service, for a request
+type FeatureFlagProvider interface {
+	Flags(r *http.Request) (map[string]interface{}, error)
+}
+
+// FeatureFlagProviderFunc adapts a function to a FeatureFlagProvider
+type FeatureFlagProviderFunc func(r *http.Request) (map[string]interface{}, error)
+
+// Flags calls f(r)
+func (f FeatureFlagProviderFunc) Flags(r *http.Request) (map[string]interface{}, error) {
+	return f(r)
+}
+
+// SetFeatureFlagProvider sets the provider whose flags are evaluated for every request and made
+// available to templates and conditions under FeatureFlagsVariable. When the provider fails,
+// every flag is treated as off, so fields gated by a flag stay hidden.
+func (ah *APIHandler) SetFeatureFlagProvider(provider FeatureFlagProvider) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.flagProvider = provider
+}
+
+// evaluateFeatureFlags evaluates the feature flags of a request, all off when the provider fails
+func evaluateFeatureFlags(provider FeatureFlagProvider, r *http.Request) map[string]interface{} {
+	flags, err := provider.Flags(r)
+	if err != nil || flags == nil {
+		return map[string]interface{}{}
+	}
+	return flags
+}
+
+// VisibleWhenFlag makes the field visible when a feature flag is on
+func (fb *FieldBuilder) VisibleWhenFlag(flag string) *FieldBuilder {
+	return fb.VisibleWhenEquals(FeatureFlagsVariable+"."+flag, true)
+}
+
+// EnabledWhenFlag makes the field enabled when a feature flag is on
+func (fb *FieldBuilder) EnabledWhenFlag(flag string) *FieldBuilder {
+	return fb.EnabledWhenEquals(FeatureFlagsVariable+"."+flag, true)
+}
@@ -0,0 +1,76 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("checkout", "Checkout").
+		AddField(NewFieldBuilder("plan", FieldTypeText, "Plan (${_flags.pricingVersion})").Build()).
+		AddField(NewFieldBuilder("coupon", FieldTypeText, "Coupon").
+			Required(true).
+			VisibleWhenFlag("newPricing").
+			Build()).
+		Build()))
+
+	// Flags are evaluated for each request, here from the user a proxy header names
+	handler.SetFeatureFlagProvider(FeatureFlagProviderFunc(func(r *http.Request) (map[string]interface{}, error) {
+		switch r.Header.Get("X-User") {
+		case "alice":
+			return map[string]interface{}{"newPricing": true, "pricingVersion": "v2"}, nil
+		case "broken":
+			return nil, errors.New("flag service unavailable")
+		default:
+			return map[string]interface{}{"newPricing": false, "pricingVersion": "v1"}, nil
+		}
+	}))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	render := func(user string) []*Field {
+		req := httptest.NewRequest(http.MethodGet, "/api/forms/checkout", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var rendered struct {
+			Fields []*Field `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+		return rendered.Fields
+	}
+
+	fields := render("alice")
+	require.Len(t, fields, 2)
+	assert.Equal(t, "Plan (v2)", fields[0].Label)
+	assert.Len(t, render("bob"), 1)
+	assert.Equal(t, "Plan (v1)", render("bob")[0].Label)
+
+	// A failing provider turns every flag off
+	assert.Len(t, render("broken"), 1)
+
+	validate := func(user, body string) *ValidationResult {
+		req := httptest.NewRequest(http.MethodPost, "/api/validate/checkout", strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return &result
+	}
+	assert.False(t, validate("alice", `{"plan": "pro"}`).Valid)
+	assert.True(t, validate("bob", `{"plan": "pro"}`).Valid)
+
+	// Submitted data cannot switch a flag on
+	assert.True(t, validate("bob", `{"plan": "pro", "_flags": {"newPricing": true}}`).Valid)
+}
@@ -0,0 +1,165 @@
+package smartform
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AccessContext is who a form is rendered or submitted for
+type AccessContext struct {
+	Roles []string `json:"roles"`
+}
+
+// AccessResolver derives the access context of a request, usually from its authentication.
+// It returns nil for anonymous callers.
+type AccessResolver func(r *http.Request) *AccessContext
+
+// HasRole reports whether the caller has a role
+func (ac *AccessContext) HasRole(role string) bool {
+	for _, held := range ac.Roles {
+		if held == role {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyRole reports whether the caller has one of the roles
+func (ac *AccessContext) hasAnyRole(roles []string) bool {
+	for _, role := range roles {
+		if ac.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRead reports whether the caller may see a field: fields without read roles are seen by
+// everyone, and the write roles of a field also grant reading it
+func (ac *AccessContext) CanRead(field *Field) bool {
+	return len(field.ReadRoles) == 0 || ac.hasAnyRole(field.ReadRoles) || ac.hasAnyRole(field.WriteRoles)
+}
+
+// CanWrite reports whether the caller may submit a value for a field: fields without write
+// roles may be submitted by everyone who can see them
+func (ac *AccessContext) CanWrite(field *Field) bool {
+	if len(field.WriteRoles) > 0 {
+		return ac.hasAnyRole(field.WriteRoles)
+	}
+	return ac.CanRead(field)
+}
+
+// Redact returns a copy of data without the values of the fields the caller may not see
+func (fs *FormSchema) Redact(data map[string]interface{}, access *AccessContext) map[string]interface{} {
+	return access.redact(fs.Fields, data)
+}
+
+// redact returns a copy of one level of data without the values of the fields the caller may
+// not see, looking into groups and array items
+func (ac *AccessContext) redact(fields []*Field, data map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		redacted[key] = value
+	}
+
+	for _, field := range fields {
+		value, present := redacted[field.ID]
+		if !present {
+			continue
+		}
+		if !ac.CanRead(field) {
+			delete(redacted, field.ID)
+			continue
+		}
+
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			redacted[field.ID] = ac.redact(field.Nested, nested)
+		case []interface{}:
+			items := make([]interface{}, len(nested))
+			for i, item := range nested {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					items[i] = ac.redact(field.Nested, itemMap)
+				} else {
+					items[i] = item
+				}
+			}
+			redacted[field.ID] = items
+		}
+	}
+	return redacted
+}
+
+// ReadRoles limits who may see the field to callers with one of the roles. The field is left
+// out of forms rendered for anyone else.
+func (fb *FieldBuilder) ReadRoles(roles ...string) *FieldBuilder {
+	fb.field.ReadRoles = roles
+	return fb
+}
+
+// WriteRoles limits who may submit the field to callers with one of the roles. Other callers
+// who may see it get it read-only, and their submissions of it are rejected.
+func (fb *FieldBuilder) WriteRoles(roles ...string) *FieldBuilder {
+	fb.field.WriteRoles = roles
+	return fb
+}
+
+// SetAccess renders the form for a caller, leaving out the fields the caller may not see and
+// marking read-only those the caller may not submit. Without it every field is rendered.
+func (fr *FormRenderer) SetAccess(access *AccessContext) {
+	fr.access = access
+}
+
+// canRead reports whether the form is rendered with a field
+func (fr *FormRenderer) canRead(field *Field) bool {
+	return fr.access == nil || fr.access.CanRead(field)
+}
+
+// redact returns a copy of one level of values without those of the fields the form is not
+// rendered with
+func (fr *FormRenderer) redact(fields []*Field, values map[string]interface{}) map[string]interface{} {
+	if fr.access == nil {
+		return values
+	}
+	return fr.access.redact(fields, values)
+}
+
+// rejectProtectedValue fails validation when a value is submitted for a field the caller may
+// not write. Like values of display fields, these are rejected whatever the tamper policy.
+func (v *Validator) rejectProtectedValue(field *Field, fieldPath string, data map[string]interface{}, result *ValidationResult) {
+	if value, present := data[field.ID]; !present || value == nil {
+		return
+	}
+
+	v.addError(result, &ValidationError{
+		FieldID:  fieldPath,
+		Message:  fmt.Sprintf("%s cannot be submitted by this caller", field.Label),
+		RuleType: string(ValidationTypeTamper),
+	})
+	result.Tampered = append(result.Tampered, &TamperFinding{
+		FieldID: fieldPath,
+		Reason:  TamperReasonProtected,
+		Action:  TamperPolicyReject,
+	})
+}
+
+// SetAccessResolver sets the function deriving who requests are made by, so that forms are
+// rendered and submissions validated for the caller's roles. Without one, callers have no
+// roles: fields with read roles are never rendered, and fields with read or write roles are
+// never accepted in submissions.
+func (ah *APIHandler) SetAccessResolver(resolver AccessResolver) {
+	ah.accessResolver = resolver
+}
+
+// accessContext returns who a request is made by, a caller without roles when unknown
+func (ah *APIHandler) accessContext(r *http.Request) *AccessContext {
+	if ah.accessResolver != nil {
+		if access := ah.accessResolver(r); access != nil {
+			return access
+		}
+	}
+	return &AccessContext{}
+}
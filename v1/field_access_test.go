@@ -0,0 +1,118 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFieldAccessForm() *FormSchema {
+	form := NewForm("employee", "Employee")
+	form.TextField("name", "Name").Required(true)
+	form.NumberField("salary", "Salary").ReadRoles("hr")
+	form.TextField("grade", "Grade").WriteRoles("hr")
+	form.GroupField("bank", "Bank").
+		AddField(NewFieldBuilder("iban", FieldTypeText, "IBAN").ReadRoles("payroll").Build())
+	return form.Build()
+}
+
+func TestFieldAccess(t *testing.T) {
+	schema := newFieldAccessForm()
+	salary := schema.FindFieldByID("salary")
+	grade := schema.FindFieldByID("grade")
+
+	staff := &AccessContext{Roles: []string{"staff"}}
+	hr := &AccessContext{Roles: []string{"hr"}}
+	assert.False(t, staff.CanRead(salary))
+	assert.True(t, hr.CanRead(salary))
+	assert.True(t, hr.CanWrite(salary))
+	assert.True(t, staff.CanRead(grade))
+	assert.False(t, staff.CanWrite(grade))
+
+	data := map[string]interface{}{
+		"name":   "Ada",
+		"salary": 100,
+		"bank":   map[string]interface{}{"iban": "DE00", "branch": "Berlin"},
+	}
+	redacted := schema.Redact(data, hr)
+	assert.Equal(t, 100, redacted["salary"])
+	assert.Equal(t, map[string]interface{}{"branch": "Berlin"}, redacted["bank"])
+	assert.NotContains(t, schema.Redact(data, staff), "salary")
+	assert.Contains(t, data, "salary", "the data itself is left alone")
+
+	// Without an access context every field is validated as before
+	result := schema.Validate(map[string]interface{}{"name": "Ada", "grade": "B"})
+	assert.True(t, result.Valid)
+
+	options := DefaultValidationOptions()
+	options.Access = staff
+	result = schema.ValidateWithOptions(map[string]interface{}{"name": "Ada", "grade": "B", "salary": 1}, options)
+	require.False(t, result.Valid)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, "salary", result.Errors[0].FieldID)
+	assert.Equal(t, "grade", result.Errors[1].FieldID)
+	assert.Equal(t, TamperReasonProtected, result.Tampered[0].Reason)
+
+	options.Access = hr
+	result = schema.ValidateWithOptions(map[string]interface{}{"name": "Ada", "grade": "B", "salary": 1}, options)
+	assert.True(t, result.Valid)
+}
+
+func TestFieldAccessEndpoints(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(newFieldAccessForm()))
+	handler.SetAccessResolver(func(r *http.Request) *AccessContext {
+		if roles := r.Header.Get("X-Roles"); roles != "" {
+			return &AccessContext{Roles: strings.Split(roles, ",")}
+		}
+		return nil
+	})
+	mux := handler.Handler()
+
+	render := func(roles string) map[string]*Field {
+		req := httptest.NewRequest(http.MethodGet, "/api/forms/employee", nil)
+		req.Header.Set("X-Roles", roles)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var rendered struct {
+			Fields []*Field `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+		fields := map[string]*Field{}
+		for _, field := range rendered.Fields {
+			fields[field.ID] = field
+		}
+		return fields
+	}
+
+	fields := render("")
+	assert.NotContains(t, fields, "salary")
+	assert.Equal(t, true, fields["grade"].Properties["readOnly"])
+	assert.Empty(t, fields["bank"].Nested)
+
+	fields = render("hr,payroll")
+	assert.Contains(t, fields, "salary")
+	assert.NotContains(t, fields["grade"].Properties, "readOnly")
+	require.Len(t, fields["bank"].Nested, 1)
+
+	submit := func(roles string, data map[string]interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(data)
+		req := httptest.NewRequest(http.MethodPost, "/api/validate/employee", bytes.NewReader(body))
+		req.Header.Set("X-Roles", roles)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	var result ValidationResult
+	require.NoError(t, json.Unmarshal(submit("", map[string]interface{}{"name": "Ada", "grade": "A"}).Body.Bytes(), &result))
+	assert.False(t, result.Valid)
+	require.NoError(t, json.Unmarshal(submit("hr", map[string]interface{}{"name": "Ada", "grade": "A"}).Body.Bytes(), &result))
+	assert.True(t, result.Valid)
+}
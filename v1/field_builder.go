@@ -53,6 +53,13 @@ func (fb *FieldBuilder) DefaultValue(value interface{}) *FieldBuilder {
 	return fb
 }
 
+// ComputedValue sets the template expression the value of the field is computed from, e.g.
+// "${multiply(price, quantity)}". It is recomputed whenever the fields it refers to change.
+func (fb *FieldBuilder) ComputedValue(expression string) *FieldBuilder {
+	fb.field.ComputedValue = expression
+	return fb
+}
+
 // Order sets the field order
 func (fb *FieldBuilder) Order(order int) *FieldBuilder {
 	fb.field.Order = order
@@ -729,13 +736,17 @@ func (dofb *DynamicOptionsFunctionBuilder) WithSortableFields(fields ...string)
 	return dofb
 }
 
-// WithPagination enables pagination for the options
-func (dofb *DynamicOptionsFunctionBuilder) WithPagination(defaultLimit int) *DynamicOptionsFunctionBuilder {
+// WithPagination enables pagination for the options, by offset unless another mode is given,
+// e.g. PaginationModeCursor for datasets too large to count
+func (dofb *DynamicOptionsFunctionBuilder) WithPagination(defaultLimit int, mode ...PaginationMode) *DynamicOptionsFunctionBuilder {
 	if dofb.config.TransformerParams == nil {
 		dofb.config.TransformerParams = make(map[string]interface{})
 	}
 	dofb.config.TransformerParams["enablePagination"] = true
 	dofb.config.TransformerParams["defaultLimit"] = defaultLimit
+	if len(mode) > 0 {
+		dofb.config.TransformerParams["paginationMode"] = string(mode[0])
+	}
 	return dofb
 }
 
@@ -896,6 +907,15 @@ func (fb *FieldBuilder) WithDependentOptions(dependentField string, valueMap map
 	return fb
 }
 
+// WithConditionalOptions adds options offered while their conditions hold for the form state
+func (fb *FieldBuilder) WithConditionalOptions(options ...*Option) *FieldBuilder {
+	fb.field.Options = &OptionsConfig{
+		Type:   OptionsTypeConditional,
+		Static: options,
+	}
+	return fb
+}
+
 // AddOption adds a single option to the field (creates static options if not already set)
 func (fb *FieldBuilder) AddOption(value interface{}, label string) *FieldBuilder {
 	option := &Option{Value: value, Label: label}
@@ -35,11 +35,17 @@ const (
 	FieldTypeRichText    FieldType = "richtext"
 	FieldTypeColor       FieldType = "color"
 	FieldTypeHidden      FieldType = "hidden"
-	FieldTypeSection     FieldType = "section" // For visual separation
-	FieldTypeCustom      FieldType = "custom"  // For custom components
-	FieldTypeAPI         FieldType = "api"     // For API integration
-	FieldTypeAuth        FieldType = "auth"    // For authentication fields
-	FieldTypeBranch      FieldType = "branch"  // For workflow branches
+	FieldTypeSection     FieldType = "section"   // For visual separation
+	FieldTypeCustom      FieldType = "custom"    // For custom components
+	FieldTypeAPI         FieldType = "api"       // For API integration
+	FieldTypeAuth        FieldType = "auth"      // For authentication fields
+	FieldTypeBranch      FieldType = "branch"    // For workflow branches
+	FieldTypeConsent     FieldType = "consent"   // For versioned legal text that must be accepted
+	FieldTypeDisplay     FieldType = "display"   // For computed values that are shown but never submitted
+	FieldTypeSignature   FieldType = "signature" // For drawn signatures
+	FieldTypePhone       FieldType = "phone"     // For phone numbers, stored in E.164 form
+	FieldTypeCurrency    FieldType = "currency"  // For amounts of money, stored in minor units
+	FieldTypeDateRange   FieldType = "daterange" // For ranges of dates with a start and an end
 )
 
 // Values provides all possible values for FieldType
@@ -75,6 +81,12 @@ func (FieldType) Values() (types []string) {
 		string(FieldTypeAPI),
 		string(FieldTypeAuth),
 		string(FieldTypeBranch),
+		string(FieldTypeConsent),
+		string(FieldTypeDisplay),
+		string(FieldTypeSignature),
+		string(FieldTypePhone),
+		string(FieldTypeCurrency),
+		string(FieldTypeDateRange),
 	}
 }
 
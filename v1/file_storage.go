@@ -0,0 +1,413 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrFileNotFound is returned when a stored file does not exist
+var ErrFileNotFound = errors.New("file not found")
+
+// fileIDPattern matches the IDs files are stored under, which are never user-chosen
+var fileIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// FileReference identifies an uploaded file. Submissions embed it as the value of file and
+// image fields, or a list of them for fields accepting several files.
+type FileReference struct {
+	ID          string    `json:"id"`
+	FormID      string    `json:"formId"`
+	FieldID     string    `json:"fieldId"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width,omitempty"`  // Pixel width of images
+	Height      int       `json:"height,omitempty"` // Pixel height of images
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// FileStorage stores uploaded files together with their references
+type FileStorage interface {
+	// Save stores the content of a file under the ID of its reference, whose size is final
+	// once the content has been read to the end
+	Save(ctx context.Context, ref *FileReference, content io.Reader) error
+	// Open returns the content of a file and its reference
+	Open(ctx context.Context, id string) (io.ReadCloser, *FileReference, error)
+	// Stat returns the reference of a file
+	Stat(ctx context.Context, id string) (*FileReference, error)
+	// Delete removes a file
+	Delete(ctx context.Context, id string) error
+}
+
+// LocalFileStorage stores files in a directory on local disk, each next to a JSON file holding
+// its reference
+type LocalFileStorage struct {
+	dir string
+}
+
+// NewLocalFileStorage creates a storage keeping files in dir, creating it if needed
+func NewLocalFileStorage(dir string) (*LocalFileStorage, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error creating file storage directory: %w", err)
+	}
+	return &LocalFileStorage{dir: dir}, nil
+}
+
+// Ping checks that files can be written to the storage directory
+func (ls *LocalFileStorage) Ping(ctx context.Context) error {
+	probe, err := os.CreateTemp(ls.dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("file storage directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// Save stores the content of a file under the ID of its reference
+func (ls *LocalFileStorage) Save(ctx context.Context, ref *FileReference, content io.Reader) error {
+	path, err := ls.path(ref.ID)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		var meta []byte
+		if meta, err = json.Marshal(ref); err == nil {
+			err = os.WriteFile(path+".json", meta, 0o640)
+		}
+	}
+	if err != nil {
+		_ = ls.Delete(ctx, ref.ID)
+		return err
+	}
+	return nil
+}
+
+// Open returns the content of a file and its reference
+func (ls *LocalFileStorage) Open(ctx context.Context, id string) (io.ReadCloser, *FileReference, error) {
+	ref, err := ls.Stat(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, _ := ls.path(id)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, ref, nil
+}
+
+// Stat returns the reference of a file
+func (ls *LocalFileStorage) Stat(ctx context.Context, id string) (*FileReference, error) {
+	path, err := ls.path(id)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := os.ReadFile(path + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ref FileReference
+	if err := json.Unmarshal(meta, &ref); err != nil {
+		return nil, fmt.Errorf("error reading file reference: %w", err)
+	}
+	return &ref, nil
+}
+
+// Delete removes a file
+func (ls *LocalFileStorage) Delete(ctx context.Context, id string) error {
+	path, err := ls.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if metaErr := os.Remove(path + ".json"); err == nil {
+		err = metaErr
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrFileNotFound
+	}
+	return err
+}
+
+// path returns where a file is stored, refusing IDs that could escape the directory
+func (ls *LocalFileStorage) path(id string) (string, error) {
+	if !fileIDPattern.MatchString(id) {
+		return "", ErrFileNotFound
+	}
+	return filepath.Join(ls.dir, id), nil
+}
+
+// S3Config configures an S3-compatible file storage
+type S3Config struct {
+	Endpoint        string       // Base URL of the service, e.g. "https://s3.eu-west-1.amazonaws.com" or a MinIO URL
+	Bucket          string       // Bucket files are stored in
+	Region          string       // Region requests are signed for (default "us-east-1")
+	AccessKeyID     string       // Access key requests are signed with
+	SecretAccessKey string       // Secret of the access key
+	Prefix          string       // Prefix of the keys of stored objects, e.g. "uploads/"
+	Client          *http.Client // HTTP client (default: one with a 30s timeout)
+}
+
+// S3FileStorage stores files as objects in an S3-compatible bucket, with their references as
+// object metadata. Objects are addressed path-style, which every S3-compatible service supports.
+type S3FileStorage struct {
+	config S3Config
+	now    func() time.Time
+}
+
+// NewS3FileStorage creates a storage keeping files in an S3-compatible bucket
+func NewS3FileStorage(config S3Config) *S3FileStorage {
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	return &S3FileStorage{config: config, now: time.Now}
+}
+
+// Headers of the object metadata holding file references
+const (
+	s3MetaForm       = "X-Amz-Meta-Form"
+	s3MetaField      = "X-Amz-Meta-Field"
+	s3MetaName       = "X-Amz-Meta-Name"
+	s3MetaUploadedAt = "X-Amz-Meta-Uploaded-At"
+	s3MetaWidth      = "X-Amz-Meta-Width"
+	s3MetaHeight     = "X-Amz-Meta-Height"
+)
+
+// Ping checks that the bucket exists and the access key may use it
+func (ss *S3FileStorage) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, ss.config.Endpoint+"/"+url.PathEscape(ss.config.Bucket), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ss.do(req, nil)
+	if errors.Is(err, ErrFileNotFound) {
+		return fmt.Errorf("bucket %s not found", ss.config.Bucket)
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Save stores the content of a file under the ID of its reference
+func (ss *S3FileStorage) Save(ctx context.Context, ref *FileReference, content io.Reader) error {
+	if !fileIDPattern.MatchString(ref.ID) {
+		return ErrFileNotFound
+	}
+	// Objects need a length and a payload hash up front; uploads are bounded, so buffer them
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, ss.objectURL(ref.ID), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ref.ContentType)
+	req.Header.Set(s3MetaForm, ref.FormID)
+	req.Header.Set(s3MetaField, ref.FieldID)
+	req.Header.Set(s3MetaName, url.QueryEscape(ref.Name))
+	req.Header.Set(s3MetaUploadedAt, ref.UploadedAt.UTC().Format(time.RFC3339))
+	if ref.Width > 0 && ref.Height > 0 {
+		req.Header.Set(s3MetaWidth, strconv.Itoa(ref.Width))
+		req.Header.Set(s3MetaHeight, strconv.Itoa(ref.Height))
+	}
+
+	resp, err := ss.do(req, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Open returns the content of a file and its reference
+func (ss *S3FileStorage) Open(ctx context.Context, id string) (io.ReadCloser, *FileReference, error) {
+	resp, err := ss.request(ctx, http.MethodGet, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, s3FileReference(id, resp), nil
+}
+
+// Stat returns the reference of a file
+func (ss *S3FileStorage) Stat(ctx context.Context, id string) (*FileReference, error) {
+	resp, err := ss.request(ctx, http.MethodHead, id)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return s3FileReference(id, resp), nil
+}
+
+// Delete removes a file
+func (ss *S3FileStorage) Delete(ctx context.Context, id string) error {
+	resp, err := ss.request(ctx, http.MethodDelete, id)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// request sends a bodiless request for an object
+func (ss *S3FileStorage) request(ctx context.Context, method, id string) (*http.Response, error) {
+	if !fileIDPattern.MatchString(id) {
+		return nil, ErrFileNotFound
+	}
+	req, err := http.NewRequestWithContext(ctx, method, ss.objectURL(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return ss.do(req, nil)
+}
+
+// do signs and sends a request, turning error statuses into errors
+func (ss *S3FileStorage) do(req *http.Request, payload []byte) (*http.Response, error) {
+	ss.sign(req, payload)
+	resp, err := ss.config.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling file storage: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrFileNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("file storage returned error status: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+// objectURL returns the path-style URL of the object of a file
+func (ss *S3FileStorage) objectURL(id string) string {
+	return ss.config.Endpoint + "/" + url.PathEscape(ss.config.Bucket) + "/" + s3EscapePath(ss.config.Prefix+id)
+}
+
+// sign signs a request with AWS Signature Version 4
+func (ss *S3FileStorage) sign(req *http.Request, payload []byte) {
+	now := ss.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256.Sum256(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	// Sign the host and every x-amz-* header
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := day + "/" + ss.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+ss.config.SecretAccessKey), day)
+	key = hmacSHA256(key, ss.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		ss.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath escapes each segment of an object key
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3FileReference reads the reference of a file from the metadata of its object
+func s3FileReference(id string, resp *http.Response) *FileReference {
+	name, err := url.QueryUnescape(resp.Header.Get(s3MetaName))
+	if err != nil {
+		name = resp.Header.Get(s3MetaName)
+	}
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	uploadedAt, _ := time.Parse(time.RFC3339, resp.Header.Get(s3MetaUploadedAt))
+	width, _ := strconv.Atoi(resp.Header.Get(s3MetaWidth))
+	height, _ := strconv.Atoi(resp.Header.Get(s3MetaHeight))
+	return &FileReference{
+		ID:          id,
+		FormID:      resp.Header.Get(s3MetaForm),
+		FieldID:     resp.Header.Get(s3MetaField),
+		Name:        name,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        size,
+		Width:       width,
+		Height:      height,
+		UploadedAt:  uploadedAt,
+	}
+}
@@ -0,0 +1,460 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultMaxUploadSize is the largest upload request accepted unless set otherwise
+const DefaultMaxUploadSize = 32 << 20
+
+// UploadFormField is the name of the multipart parts holding uploaded files
+const UploadFormField = "file"
+
+// uploadPeekSize is how much of an upload is read before it is stored, to sniff its type and
+// read image headers
+const uploadPeekSize = 1 << 20
+
+// errFileTooLarge is returned while reading an upload once it exceeds its size limit
+var errFileTooLarge = errors.New("file is too large")
+
+// SetFileStorage sets the storage of uploaded files, which enables the upload endpoint and the
+// checking of submitted file references
+func (ah *APIHandler) SetFileStorage(storage FileStorage) {
+	ah.fileStorage = storage
+}
+
+// SetMaxUploadSize sets the largest upload request accepted, in bytes
+func (ah *APIHandler) SetMaxUploadSize(size int64) {
+	ah.maxUploadSize = size
+}
+
+// uploadError is an upload rejected with a status
+type uploadError struct {
+	status  int
+	message string
+}
+
+// Error implements the error interface
+func (ue *uploadError) Error() string {
+	return ue.message
+}
+
+// handleUpload handles multipart uploads of files for a file or image field. Every part named
+// "file" is stored, and the references of the stored files are returned for the client to
+// embed in its submission.
+func (ah *APIHandler) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.fileStorage == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "File uploads are not configured"))
+		return
+	}
+
+	formID, fieldPath, _ := strings.Cut(getPathParam(r.URL.Path, "/api/upload/"), "/")
+	if formID == "" || fieldPath == "" {
+		writeError(w, badRequest("Form ID and field ID are required"))
+		return
+	}
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+	field, err := NewStateEngine(schema).findFieldByPath(fieldPath)
+	if err != nil {
+		writeError(w, fieldNotFound(fieldPath))
+		return
+	}
+	if field.Type != FieldTypeFile && field.Type != FieldTypeImage {
+		writeError(w, badRequest("Field does not accept files"))
+		return
+	}
+
+	maxSize := ah.maxUploadSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, badRequest("Expected a multipart/form-data request"))
+		return
+	}
+
+	files := []*FileReference{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && part.FormName() != UploadFormField {
+			continue
+		}
+		var ref *FileReference
+		if err == nil {
+			ref, err = ah.storeUpload(r.Context(), schema.ID, pathIndexPattern.ReplaceAllString(fieldPath, ""), field, part)
+		}
+		if err != nil {
+			// Files stored before the failure are not returned, so nothing could refer to them
+			for _, stored := range files {
+				_ = ah.fileStorage.Delete(r.Context(), stored.ID)
+			}
+			writeUploadError(w, err)
+			return
+		}
+		files = append(files, ref)
+	}
+	if len(files) == 0 {
+		writeError(w, NewAPIError(http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("No %q part in the request", UploadFormField)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+}
+
+// storeUpload checks an uploaded file against the file rules of its field and stores it
+func (ah *APIHandler) storeUpload(ctx context.Context, formID, fieldPath string, field *Field, content io.Reader) (*FileReference, error) {
+	part, _ := content.(interface{ FileName() string })
+	name := ""
+	if part != nil {
+		name = filepath.Base(part.FileName())
+	}
+
+	// The type is sniffed from the content rather than taken from the client, and the
+	// dimensions of images are read from their header, which the peeked bytes hold
+	head := make([]byte, uploadPeekSize)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+	ref := &FileReference{
+		ID:          newID(),
+		FormID:      formID,
+		FieldID:     fieldPath,
+		Name:        name,
+		ContentType: detectContentType(head, name),
+		UploadedAt:  time.Now().UTC(),
+	}
+
+	allowed, message := allowedFileTypes(field)
+	if allowed != nil && !fileTypeAllowed(allowed, ref) {
+		if message == "" {
+			message = fmt.Sprintf("Files of type %s are not allowed", ref.ContentType)
+		}
+		return nil, &uploadError{status: http.StatusUnsupportedMediaType, message: message}
+	}
+	if contentMismatch(name, ref.ContentType) {
+		return nil, &uploadError{
+			status:  http.StatusUnsupportedMediaType,
+			message: fmt.Sprintf("File content (%s) does not match its extension", ref.ContentType),
+		}
+	}
+	if err := checkUploadedImage(field, ref, head); err != nil {
+		return nil, err
+	}
+
+	reader := &sizeLimitedReader{reader: io.MultiReader(bytes.NewReader(head), content), ref: ref, limit: -1}
+	for _, rule := range field.ValidationRules {
+		if rule.Type == ValidationTypeFileSize {
+			if limit, ok := decodeNumber(rule.Parameters); ok {
+				reader.limit = int64(limit)
+				reader.message = rule.Message
+			}
+		}
+	}
+
+	if err := ah.fileStorage.Save(ctx, ref, reader); err != nil {
+		if errors.Is(err, errFileTooLarge) {
+			message := reader.message
+			if message == "" {
+				message = fmt.Sprintf("File must be at most %d bytes", reader.limit)
+			}
+			return nil, &uploadError{status: http.StatusRequestEntityTooLarge, message: message}
+		}
+		return nil, err
+	}
+	return ref, nil
+}
+
+// writeUploadError writes the response for a rejected or failed upload
+func writeUploadError(w http.ResponseWriter, err error) {
+	var rejected *uploadError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &rejected):
+		writeError(w, NewAPIError(rejected.status, errorCodeForStatus(rejected.status), rejected.message))
+	case errors.As(err, &tooLarge):
+		writeError(w, NewAPIError(http.StatusRequestEntityTooLarge, ErrorCodePayloadTooLarge, fmt.Sprintf("Upload must be at most %d bytes", tooLarge.Limit)))
+	default:
+		writeError(w, fmt.Errorf("error storing file: %w", err))
+	}
+}
+
+// sizeLimitedReader counts the size of an upload into its reference, failing once it exceeds
+// the limit, if there is one
+type sizeLimitedReader struct {
+	reader  io.Reader
+	ref     *FileReference
+	limit   int64
+	message string
+}
+
+// Read implements io.Reader
+func (sr *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := sr.reader.Read(p)
+	sr.ref.Size += int64(n)
+	if sr.limit >= 0 && sr.ref.Size > sr.limit {
+		return n, errFileTooLarge
+	}
+	return n, err
+}
+
+// detectContentType returns the media type of a file from its first bytes. Formats that
+// cannot be recognized from their content, such as office documents, are typed by extension,
+// except as images, audio, or video, which are always recognizable.
+func detectContentType(head []byte, name string) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	switch sniffed {
+	case "application/octet-stream", "text/plain", "application/zip":
+		byExtension, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name)))
+		if byExtension != "" && !strings.HasPrefix(byExtension, "image/") &&
+			!strings.HasPrefix(byExtension, "audio/") && !strings.HasPrefix(byExtension, "video/") {
+			return byExtension
+		}
+	}
+	return sniffed
+}
+
+// allowedFileTypes returns the file types a field accepts and the message for other types.
+// Image fields accept images unless they have a file type rule; other fields without one
+// accept any type, for which nil is returned.
+func allowedFileTypes(field *Field) ([]string, string) {
+	for _, rule := range field.ValidationRules {
+		if rule.Type == ValidationTypeFileType {
+			return fileTypeList(rule.Parameters), rule.Message
+		}
+	}
+	if field.Type == FieldTypeImage {
+		return []string{"image/*"}, "File must be an image"
+	}
+	return nil, ""
+}
+
+// fileTypeList returns the file types of a file type rule, which are a list of strings once
+// imported from JSON
+func fileTypeList(parameters interface{}) []string {
+	switch types := parameters.(type) {
+	case []string:
+		return types
+	case []interface{}:
+		list := make([]string, 0, len(types))
+		for _, item := range types {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case string:
+		return strings.Split(types, ",")
+	default:
+		return []string{}
+	}
+}
+
+// fileTypeAllowed reports whether a file is of one of the allowed types, each a media type
+// such as "application/pdf", a family such as "image/*", or an extension such as ".pdf"
+func fileTypeAllowed(allowed []string, ref *FileReference) bool {
+	contentType, _, _ := mime.ParseMediaType(ref.ContentType)
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case strings.HasPrefix(entry, "."):
+			if strings.EqualFold(filepath.Ext(ref.Name), entry) {
+				return true
+			}
+		case strings.HasSuffix(entry, "/*"):
+			if strings.HasPrefix(contentType, strings.TrimSuffix(entry, "*")) {
+				return true
+			}
+		case entry == contentType:
+			return true
+		}
+	}
+	return false
+}
+
+// fileReferences returns the file references of a file field value: a reference, its ID, or a
+// list of them. The second result is false for values that are none of these.
+func fileReferences(value interface{}) ([]*FileReference, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		refs := make([]*FileReference, 0, len(v))
+		for _, item := range v {
+			ref, ok := fileReference(item)
+			if !ok {
+				return nil, false
+			}
+			refs = append(refs, ref)
+		}
+		return refs, true
+	case []*FileReference:
+		return v, true
+	default:
+		ref, ok := fileReference(value)
+		if !ok {
+			return nil, false
+		}
+		return []*FileReference{ref}, true
+	}
+}
+
+// fileReference returns a single file reference from a submitted value
+func fileReference(value interface{}) (*FileReference, bool) {
+	switch v := value.(type) {
+	case *FileReference:
+		return v, v != nil
+	case FileReference:
+		return &v, true
+	case string:
+		return &FileReference{ID: v}, v != ""
+	case map[string]interface{}:
+		id, _ := v["id"].(string)
+		if id == "" {
+			return nil, false
+		}
+		ref := &FileReference{ID: id}
+		ref.FormID, _ = v["formId"].(string)
+		ref.FieldID, _ = v["fieldId"].(string)
+		ref.Name, _ = v["name"].(string)
+		ref.ContentType, _ = v["contentType"].(string)
+		if size, ok := decodeNumber(v["size"]); ok {
+			ref.Size = int64(size)
+		}
+		if width, ok := decodeNumber(v["width"]); ok {
+			ref.Width = int(width)
+		}
+		if height, ok := decodeNumber(v["height"]); ok {
+			ref.Height = int(height)
+		}
+		return ref, true
+	default:
+		return nil, false
+	}
+}
+
+// storedFile returns the stored reference of a submitted file, which unlike the submitted one
+// can be trusted. Without a file storage the submitted reference is returned.
+func (v *Validator) storedFile(ref *FileReference) (*FileReference, error) {
+	if v.options == nil || v.options.FileStorage == nil {
+		return ref, nil
+	}
+	if stored, ok := v.files[ref.ID]; ok {
+		return stored.ref, stored.err
+	}
+
+	ctx := v.options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	stored, err := v.options.FileStorage.Stat(ctx, ref.ID)
+	if v.files == nil {
+		v.files = make(map[string]storedFileResult)
+	}
+	v.files[ref.ID] = storedFileResult{ref: stored, err: err}
+	return stored, err
+}
+
+// storedFileResult is a looked up file reference, kept for the rules of the same field
+type storedFileResult struct {
+	ref *FileReference
+	err error
+}
+
+// validateFileReferences checks that the files submitted for a file or image field were
+// uploaded for that field of this form. It only runs with a file storage.
+func (v *Validator) validateFileReferences(field *Field, fieldPath string, value interface{}, result *ValidationResult) {
+	if field.Type != FieldTypeFile && field.Type != FieldTypeImage {
+		return
+	}
+	if v.options == nil || v.options.FileStorage == nil {
+		return
+	}
+
+	refs, ok := fileReferences(value)
+	if !ok {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  "Value must be a file reference",
+			RuleType: string(ValidationTypeFile),
+		})
+		return
+	}
+
+	fieldID := pathIndexPattern.ReplaceAllString(fieldPath, "")
+	for _, ref := range refs {
+		stored, err := v.storedFile(ref)
+		message := ""
+		switch {
+		case errors.Is(err, ErrFileNotFound):
+			message = "File was not uploaded"
+		case err != nil:
+			message = "File could not be checked"
+		case stored.FormID != v.schema.ID || stored.FieldID != fieldID:
+			message = "File was uploaded for another field"
+		}
+		if message != "" {
+			v.addError(result, &ValidationError{
+				FieldID:  fieldPath,
+				Message:  message,
+				RuleType: string(ValidationTypeFile),
+			})
+		}
+	}
+}
+
+// validateFileRule applies a file type, file size, or image dimensions rule to the submitted files. Files that
+// cannot be looked up are reported by the reference check instead, and metadata that is
+// unknown is not checked.
+func (v *Validator) validateFileRule(rule *ValidationRule, value interface{}) (bool, string) {
+	refs, ok := fileReferences(value)
+	if !ok {
+		return true, ""
+	}
+
+	for _, ref := range refs {
+		stored, err := v.storedFile(ref)
+		if err != nil || stored == nil {
+			continue
+		}
+		switch rule.Type {
+		case ValidationTypeFileType:
+			if (stored.ContentType != "" || stored.Name != "") && !fileTypeAllowed(fileTypeList(rule.Parameters), stored) {
+				return false, rule.Message
+			}
+		case ValidationTypeFileSize:
+			if maxSize, ok := decodeNumber(rule.Parameters); ok && float64(stored.Size) > maxSize {
+				return false, rule.Message
+			}
+		case ValidationTypeImageDimensions:
+			if stored.Width > 0 && stored.Height > 0 && !imageDimensionsAllowed(rule.Parameters, stored.Width, stored.Height) {
+				return false, rule.Message
+			}
+		}
+	}
+	return true, ""
+}
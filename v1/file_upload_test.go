@@ -0,0 +1,316 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadRequest builds a multipart upload of files, given by name and content
+func uploadRequest(t *testing.T, path string, files ...string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for i := 0; i < len(files); i += 2 {
+		part, err := writer.CreateFormFile(UploadFormField, files[i])
+		require.NoError(t, err)
+		_, _ = part.Write([]byte(files[i+1]))
+	}
+	require.NoError(t, writer.Close())
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestFileUploads(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewLocalFileStorage(dir)
+	require.NoError(t, err)
+
+	handler := NewAPIHandler()
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	form := NewForm("application", "Application")
+	form.FileField("resume", "Resume").
+		ValidateFileType([]string{"application/pdf"}, "Resume must be a PDF").
+		ValidateFileSize(64, "Resume is too large")
+	form.ImageField("photo", "Photo")
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+
+	upload := func(req *http.Request) (int, []*FileReference) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var result struct {
+			Files []*FileReference `json:"files"`
+		}
+		if rec.Code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec.Code, result.Files
+	}
+
+	// Uploads are refused until a storage is configured
+	code, _ := upload(uploadRequest(t, "/api/upload/application/resume", "cv.pdf", "%PDF-1.4"))
+	assert.Equal(t, http.StatusNotImplemented, code)
+	handler.SetFileStorage(storage)
+
+	code, files := upload(uploadRequest(t, "/api/upload/application/resume", "cv.pdf", "%PDF-1.4 resume"))
+	require.Equal(t, http.StatusCreated, code)
+	require.Len(t, files, 1)
+	resume := files[0]
+	assert.Equal(t, "application", resume.FormID)
+	assert.Equal(t, "resume", resume.FieldID)
+	assert.Equal(t, "cv.pdf", resume.Name)
+	assert.Equal(t, "application/pdf", resume.ContentType)
+	assert.Equal(t, int64(15), resume.Size)
+
+	// The type is sniffed, so renaming a file does not get it past the type rule
+	code, _ = upload(uploadRequest(t, "/api/upload/application/resume", "cv.pdf", "<html><body>cv</body></html>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	code, _ = upload(uploadRequest(t, "/api/upload/application/photo", "photo.png", "not an image"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+
+	// Files over the size rule are rejected without leaving anything behind
+	code, _ = upload(uploadRequest(t, "/api/upload/application/resume",
+		"a.pdf", "%PDF-1.4", "b.pdf", "%PDF-1.4"+strings.Repeat(" ", 64)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	handler.SetMaxUploadSize(100)
+	code, _ = upload(uploadRequest(t, "/api/upload/application/photo", "photo.png", strings.Repeat("x", 200)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	handler.SetMaxUploadSize(0)
+
+	code, _ = upload(uploadRequest(t, "/api/upload/application/name", "cv.pdf", "%PDF-1.4"))
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = upload(uploadRequest(t, "/api/upload/application/resume"))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Returned references are embedded in submissions and checked against the storage
+	validate := func(data map[string]interface{}) *ValidationResult {
+		body, _ := json.Marshal(data)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/application", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return &result
+	}
+	assert.True(t, validate(map[string]interface{}{"resume": resume}).Valid)
+
+	// Claimed metadata is not trusted
+	forged := *resume
+	forged.ID = "0123456789abcdef0123456789abcdef"
+	result := validate(map[string]interface{}{"resume": forged})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, &ValidationError{FieldID: "resume", Message: "File was not uploaded", RuleType: "file"}, result.Errors[0])
+
+	result = validate(map[string]interface{}{"photo": resume})
+	require.NotEmpty(t, result.Errors)
+	assert.Equal(t, "File was uploaded for another field", result.Errors[0].Message)
+}
+
+func TestFileRulesWithoutStorage(t *testing.T) {
+	schema := NewForm("application", "Application").Build()
+	schema.AddField(NewFieldBuilder("resume", FieldTypeFile, "Resume").
+		ValidateFileType([]string{"application/pdf"}, "Resume must be a PDF").
+		ValidateFileSize(1024, "Resume is too large").
+		Build())
+	validator := NewValidator(schema)
+
+	result := validator.ValidateForm(map[string]interface{}{
+		"resume": map[string]interface{}{"id": "a", "name": "cv.pdf", "contentType": "application/pdf", "size": float64(100)},
+	})
+	assert.True(t, result.Valid)
+
+	result = validator.ValidateForm(map[string]interface{}{
+		"resume": []interface{}{
+			map[string]interface{}{"id": "a", "name": "cv.docx", "contentType": "application/msword", "size": float64(100)},
+			map[string]interface{}{"id": "b", "name": "cv.pdf", "contentType": "application/pdf", "size": float64(4096)},
+		},
+	})
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, "Resume must be a PDF", result.Errors[0].Message)
+	assert.Equal(t, "Resume is too large", result.Errors[1].Message)
+}
+
+// fakeS3 is an in-memory S3-compatible service checking that requests are signed
+type fakeS3 struct {
+	lock    sync.Mutex
+	objects map[string]http.Header
+	bodies  map[string][]byte
+}
+
+func (fs *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/20240102/eu-west-1/s3/aws4_request") ||
+		r.Header.Get("X-Amz-Date") != "20240102T030405Z" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		fs.objects[r.URL.Path] = r.Header.Clone()
+		fs.bodies[r.URL.Path] = body
+	case http.MethodGet, http.MethodHead:
+		header, ok := fs.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range header {
+			if strings.HasPrefix(name, "X-Amz-Meta-") || name == "Content-Type" {
+				w.Header()[name] = values
+			}
+		}
+		_, _ = w.Write(fs.bodies[r.URL.Path])
+	case http.MethodDelete:
+		delete(fs.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3FileStorage(t *testing.T) {
+	service := &fakeS3{objects: map[string]http.Header{}, bodies: map[string][]byte{}}
+	server := httptest.NewServer(service)
+	defer server.Close()
+
+	storage := NewS3FileStorage(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "forms",
+		Region:          "eu-west-1",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Prefix:          "uploads/",
+	})
+	storage.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	ctx := context.Background()
+	ref := &FileReference{
+		ID:          newID(),
+		FormID:      "application",
+		FieldID:     "resume",
+		Name:        "my cv.pdf",
+		ContentType: "application/pdf",
+		UploadedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.NoError(t, storage.Save(ctx, ref, &sizeLimitedReader{reader: strings.NewReader("%PDF-1.4"), ref: ref, limit: -1}))
+	assert.Contains(t, service.bodies, "/forms/uploads/"+ref.ID)
+
+	stored, err := storage.Stat(ctx, ref.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ref, stored)
+
+	content, stored, err := storage.Open(ctx, ref.ID)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "%PDF-1.4", string(data))
+	assert.Equal(t, "my cv.pdf", stored.Name)
+
+	require.NoError(t, storage.Delete(ctx, ref.ID))
+	_, err = storage.Stat(ctx, ref.ID)
+	assert.Equal(t, ErrFileNotFound, err)
+	_, err = storage.Stat(ctx, "../secret")
+	assert.Equal(t, ErrFileNotFound, err)
+}
+
+// pngImage encodes a blank PNG image of the given size
+func pngImage(t *testing.T, width, height int) string {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.String()
+}
+
+func TestImageUploads(t *testing.T) {
+	storage, err := NewLocalFileStorage(t.TempDir())
+	require.NoError(t, err)
+	handler := NewAPIHandler()
+	handler.SetFileStorage(storage)
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	form := NewForm("profile", "Profile")
+	form.ImageField("banner", "Banner").
+		ValidateImageDimensions(map[string]interface{}{"minWidth": 100, "aspectRatio": "2:1"}, "Banner must be 2:1 and at least 100px wide")
+	form.FileField("attachment", "Attachment")
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+
+	upload := func(field, name, content string) (*httptest.ResponseRecorder, []*FileReference) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, uploadRequest(t, "/api/upload/profile/"+field, name, content))
+		var result struct {
+			Files []*FileReference `json:"files"`
+		}
+		if rec.Code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec, result.Files
+	}
+
+	rec, files := upload("banner", "banner.png", pngImage(t, 300, 150))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Equal(t, 300, files[0].Width)
+	assert.Equal(t, 150, files[0].Height)
+	stored, err := storage.Stat(context.Background(), files[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 300, stored.Width)
+
+	rec, _ = upload("banner", "banner.png", pngImage(t, 150, 150))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Banner must be 2:1")
+	rec, _ = upload("banner", "banner.png", pngImage(t, 80, 40))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Content must match the extension, whatever the field accepts
+	rec, _ = upload("banner", "banner.jpg", pngImage(t, 300, 150))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec, _ = upload("attachment", "notes.png", "just some notes")
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec, _ = upload("attachment", "report.pdf", pngImage(t, 10, 10))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	rec, _ = upload("attachment", "notes.txt", "just some notes")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// Images whose header is cut short are not valid
+	rec, _ = upload("attachment", "broken.png", pngImage(t, 10, 10)[:20])
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	assert.Contains(t, rec.Body.String(), "not a valid image")
+}
+
+func TestImageDimensionsRule(t *testing.T) {
+	schema := NewForm("profile", "Profile").Build()
+	schema.AddField(NewFieldBuilder("avatar", FieldTypeImage, "Avatar").
+		ValidateImageDimensions(map[string]interface{}{"width": 64, "height": 64}, "Avatar must be 64x64").
+		Build())
+	validator := NewValidator(schema)
+
+	avatar := func(width, height int) map[string]interface{} {
+		return map[string]interface{}{"avatar": map[string]interface{}{"id": "a", "width": float64(width), "height": float64(height)}}
+	}
+	assert.True(t, validator.ValidateForm(avatar(64, 64)).Valid)
+	result := validator.ValidateForm(avatar(64, 32))
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "Avatar must be 64x64", result.Errors[0].Message)
+
+	assert.True(t, imageDimensionsAllowed(map[string]interface{}{"aspectRatio": 1.7778}, 1920, 1080))
+	assert.False(t, imageDimensionsAllowed(map[string]interface{}{"aspectRatio": "16:9"}, 1920, 1200))
+	assert.True(t, imageDimensionsAllowed(map[string]interface{}{"aspectRatio": "16/10", "maxHeight": 1200}, 1920, 1200))
+}
@@ -31,6 +31,12 @@ func (fb *FormBuilder) Description(description string) *FormBuilder {
 	return fb
 }
 
+// Version sets the semantic version of the form
+func (fb *FormBuilder) Version(version string) *FormBuilder {
+	fb.schema.Version = version
+	return fb
+}
+
 // FormType sets the form type
 func (fb *FormBuilder) FormType(formType FormType) *FormBuilder {
 	fb.schema.Type = formType
@@ -45,6 +51,33 @@ func (fb *FormBuilder) AuthType(authType AuthStrategy) *FormBuilder {
 	return fb
 }
 
+// Tag adds tags to the form, for filtering the form list
+func (fb *FormBuilder) Tag(tags ...string) *FormBuilder {
+	fb.schema.Tags = append(fb.schema.Tags, tags...)
+	return fb
+}
+
+// Category sets the catalog category the form is grouped under
+func (fb *FormBuilder) Category(category string) *FormBuilder {
+	fb.schema.Category = category
+	return fb
+}
+
+// Owner sets the team or person responsible for the form
+func (fb *FormBuilder) Owner(owner string) *FormBuilder {
+	fb.schema.Owner = owner
+	return fb
+}
+
+// Meta sets a catalog metadata entry of the form, such as its icon
+func (fb *FormBuilder) Meta(key string, value interface{}) *FormBuilder {
+	if fb.schema.Metadata == nil {
+		fb.schema.Metadata = make(map[string]interface{})
+	}
+	fb.schema.Metadata[key] = value
+	return fb
+}
+
 // Property sets a custom property on the form
 func (fb *FormBuilder) Property(key string, value interface{}) *FormBuilder {
 	fb.schema.Properties[key] = value
@@ -312,6 +345,41 @@ func (fb *FormBuilder) BranchField(id, label string) *BranchFieldBuilder {
 	return field
 }
 
+// ConsentField adds a consent field to the form
+func (fb *FormBuilder) ConsentField(id, label string) *ConsentFieldBuilder {
+	field := NewConsentFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// SignatureField adds a signature field to the form
+func (fb *FormBuilder) SignatureField(id, label string) *SignatureFieldBuilder {
+	field := NewSignatureFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// PhoneField adds a phone field to the form
+func (fb *FormBuilder) PhoneField(id, label string) *PhoneFieldBuilder {
+	field := NewPhoneFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// CurrencyField adds a currency field to the form
+func (fb *FormBuilder) CurrencyField(id, label string) *CurrencyFieldBuilder {
+	field := NewCurrencyFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
+// DisplayField adds a display field, whose value is computed and never submitted, to the form
+func (fb *FormBuilder) DisplayField(id, label string) *DisplayFieldBuilder {
+	field := NewDisplayFieldBuilder(id, label)
+	fb.AddField(field.Build())
+	return field
+}
+
 // CustomField Custom adds a custom field to the form
 func (fb *FormBuilder) CustomField(id, label string) *CustomFieldBuilder {
 	if id == "" {
@@ -322,6 +390,18 @@ func (fb *FormBuilder) CustomField(id, label string) *CustomFieldBuilder {
 	return field
 }
 
+// IncludeRemoteFragment references a fragment published by another service.
+// The fragment is fetched and merged when the schema is registered with an APIHandler
+// that has a RemoteFragmentLoader configured.
+func (fb *FormBuilder) IncludeRemoteFragment(url, keyID, prefix string) *FormBuilder {
+	fb.schema.RemoteFragments = append(fb.schema.RemoteFragments, &RemoteFragmentRef{
+		URL:    url,
+		KeyID:  keyID,
+		Prefix: prefix,
+	})
+	return fb
+}
+
 // RegisterVariable registers a variable in the form
 func (fb *FormBuilder) RegisterVariable(name string, value interface{}) *FormBuilder {
 	fb.schema.RegisterVariable(name, value)
@@ -336,6 +416,8 @@ func (fb *FormBuilder) RegisterVariableFunction(name string, fn template.Templat
 
 // Build finalizes and returns the form schema
 func (fb *FormBuilder) Build() *FormSchema {
+	// Fragments that are not registered are reported when the form is registered
+	_ = fb.schema.applyFragments()
 	fb.registerDynamicFunctions()
 
 	return fb.schema
@@ -0,0 +1,150 @@
+package smartform
+
+import (
+	"fmt"
+	"sync"
+)
+
+// FormFragment is a named group of fields, such as an address or a payment card, that forms
+// include with FormBuilder.IncludeFragment
+type FormFragment struct {
+	ID      string   `json:"id"`
+	Version string   `json:"version,omitempty"`
+	Fields  []*Field `json:"fields"`
+}
+
+// FragmentRef is the inclusion of a registered fragment in a form
+type FragmentRef struct {
+	ID     string `json:"id"`
+	Prefix string `json:"prefix,omitempty"` // Prefix applied to the fragment's field IDs
+	At     int    `json:"at"`               // Number of the form's own fields before the fragment's
+}
+
+// fragments holds the registered fragments by ID
+var fragments = struct {
+	byID map[string]*FormFragment
+	lock sync.RWMutex
+}{
+	byID: make(map[string]*FormFragment),
+}
+
+// RegisterFragment registers a fragment forms can include, or replaces the one registered with
+// its ID. Forms built afterwards include the fields it has now.
+func RegisterFragment(fragment *FormFragment) error {
+	if fragment.ID == "" {
+		return fmt.Errorf("fragment ID is required")
+	}
+	if err := validateFragmentFields(fragment.Fields); err != nil {
+		return fmt.Errorf("invalid fragment %s: %w", fragment.ID, err)
+	}
+
+	registered := &FormFragment{ID: fragment.ID, Version: fragment.Version, Fields: cloneFields(fragment.Fields)}
+	fragments.lock.Lock()
+	defer fragments.lock.Unlock()
+	fragments.byID[fragment.ID] = registered
+	return nil
+}
+
+// LookupFragment returns the fragment registered with an ID
+func LookupFragment(id string) (*FormFragment, bool) {
+	fragments.lock.RLock()
+	defer fragments.lock.RUnlock()
+	fragment, ok := fragments.byID[id]
+	return fragment, ok
+}
+
+// FragmentBuilder provides the fluent API of forms for building fragments
+type FragmentBuilder struct {
+	*FormBuilder
+}
+
+// NewFragment creates a new fragment builder
+func NewFragment(id string) *FragmentBuilder {
+	return &FragmentBuilder{FormBuilder: NewForm(id, "")}
+}
+
+// Build finalizes and returns the fragment
+func (fb *FragmentBuilder) Build() *FormFragment {
+	schema := fb.FormBuilder.Build()
+	return &FormFragment{ID: schema.ID, Version: schema.Version, Fields: schema.Fields}
+}
+
+// IncludeFragment includes the fields of a registered fragment in the form, after the fields
+// added so far. Top-level field IDs get the prefix, e.g. "billing_street" for the field
+// "street" with the prefix "billing", and conditions, templates, and option dependencies
+// between the fragment's fields are rewritten to match. The fragment is looked up when the
+// form is built, so forms pick up changes to fragments registered in the meantime; forms
+// including a fragment that is not registered are rejected at registration.
+func (fb *FormBuilder) IncludeFragment(id, prefix string) *FormBuilder {
+	own := 0
+	for _, field := range fb.schema.Fields {
+		if _, included := field.Properties["fragment"]; !included {
+			own++
+		}
+	}
+	fb.schema.Fragments = append(fb.schema.Fragments, &FragmentRef{ID: id, Prefix: prefix, At: own})
+	return fb
+}
+
+// CheckFragments reports fragments the form includes that are not registered, or whose fields
+// conflict with other fields of the form
+func (fs *FormSchema) CheckFragments() error {
+	return fs.Clone().applyFragments()
+}
+
+// applyFragments replaces the fields of included fragments with those of the fragments as
+// registered now. Fragments that are not registered are left out.
+func (fs *FormSchema) applyFragments() error {
+	if len(fs.Fragments) == 0 {
+		return nil
+	}
+
+	own := []*Field{}
+	for _, field := range fs.Fields {
+		if _, included := field.Properties["fragment"]; !included {
+			own = append(own, field)
+		}
+	}
+	existing := make(map[string]bool, len(own))
+	for _, field := range own {
+		existing[field.ID] = true
+	}
+
+	var firstErr error
+	included := make([][]*Field, len(own)+1)
+	for _, ref := range fs.Fragments {
+		fragment, ok := LookupFragment(ref.ID)
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("form %s includes fragment %s, which is not registered", fs.ID, ref.ID)
+			}
+			continue
+		}
+
+		fields := prefixFragmentFields(fragment.Fields, ref.Prefix)
+		for _, field := range fields {
+			if existing[field.ID] && firstErr == nil {
+				firstErr = fmt.Errorf("fragment %s field '%s' conflicts with an existing field of form %s", ref.ID, field.ID, fs.ID)
+			}
+			existing[field.ID] = true
+			field.Properties["fragment"] = ref.ID
+			if fragment.Version != "" {
+				field.Properties["fragmentVersion"] = fragment.Version
+			}
+		}
+
+		at := ref.At
+		if at < 0 || at > len(own) {
+			at = len(own)
+		}
+		included[at] = append(included[at], fields...)
+	}
+
+	merged := make([]*Field, 0, len(fs.Fields))
+	for i, field := range own {
+		merged = append(merged, included[i]...)
+		merged = append(merged, field)
+	}
+	fs.Fields = append(merged, included[len(own)]...)
+	return firstErr
+}
@@ -0,0 +1,63 @@
+package smartform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncludeFragment(t *testing.T) {
+	address := NewFragment("testAddress")
+	address.TextField("street", "Street").Required(true)
+	address.TextField("zip", "ZIP code").VisibleWhenExists("street")
+	require.NoError(t, RegisterFragment(address.Build()))
+
+	form := NewForm("checkout", "Checkout")
+	form.TextField("name", "Name")
+	form.IncludeFragment("testAddress", "billing")
+	form.CheckboxField("sameAddress", "Ship to the billing address")
+	form.IncludeFragment("testAddress", "shipping")
+	schema := form.Build()
+
+	ids := []string{}
+	for _, field := range schema.Fields {
+		ids = append(ids, field.ID)
+	}
+	assert.Equal(t, []string{"name", "billing_street", "billing_zip", "sameAddress", "shipping_street", "shipping_zip"}, ids)
+	assert.Equal(t, "billing_street", schema.FindFieldByID("billing_zip").Visible.Field)
+	assert.Equal(t, "testAddress", schema.FindFieldByID("shipping_zip").Properties["fragment"])
+
+	result := schema.Validate(map[string]interface{}{"billing_street": "1 Main St"})
+	require.False(t, result.Valid)
+	assert.Equal(t, "shipping_street", result.Errors[0].FieldID)
+
+	// Forms built after a fragment changes include the change
+	address.TextField("country", "Country")
+	require.NoError(t, RegisterFragment(address.Build()))
+	assert.Len(t, form.Build().Fields, 8)
+
+	// Imported forms include fragments as registered, once
+	data, err := json.Marshal(form.Build())
+	require.NoError(t, err)
+	imported, err := NewJSONImporter().ImportJSON(string(data))
+	require.NoError(t, err)
+	require.Len(t, imported.Fields, 8)
+	assert.Equal(t, "billing_country", imported.Fields[3].ID)
+	assert.Equal(t, "sameAddress", imported.Fields[4].ID)
+
+	// Forms including fragments that are not registered are rejected
+	missing := NewForm("missing", "Missing")
+	missing.IncludeFragment("testNoSuchFragment", "")
+	err = NewAPIHandler().RegisterSchema(missing.Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "fragment testNoSuchFragment, which is not registered")
+
+	conflicting := NewForm("conflicting", "Conflicting")
+	conflicting.TextField("street", "Street")
+	conflicting.IncludeFragment("testAddress", "")
+	err = NewAPIHandler().RegisterSchema(conflicting.Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicts with an existing field")
+}
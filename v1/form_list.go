@@ -0,0 +1,249 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Page sizes of the form list
+const (
+	DefaultFormListLimit = 50
+	MaxFormListLimit     = 500
+)
+
+// Orders the form list can be sorted in
+const (
+	FormListSortID       = "id"
+	FormListSortTitle    = "title"
+	FormListSortVersion  = "version"
+	FormListSortCategory = "category"
+)
+
+// FormListQuery selects a page of the registered forms
+type FormListQuery struct {
+	Search     string            // Text the ID, title, or description must contain, ignoring case
+	Tags       []string          // Tags a form must all carry
+	Category   string            // Category a form must be in, ignoring case
+	Owner      string            // Owner a form must have, ignoring case
+	Metadata   map[string]string // Metadata entries a form must have, compared as text
+	Sort       string            // One of the FormListSort orders; by ID when empty
+	Descending bool
+	Page       int // 1-based page number
+	Limit      int // Forms per page
+}
+
+// FormListItem describes a registered form in the form list
+type FormListItem struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Version     string                 `json:"version"`
+	Tags        []string               `json:"tags,omitempty"`
+	Category    string                 `json:"category,omitempty"`
+	Owner       string                 `json:"owner,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// FormListPagination tells where a page is in the form list
+type FormListPagination struct {
+	Total int `json:"total"` // Number of forms matching the query, across all pages
+	Page  int `json:"page"`
+	Limit int `json:"limit"`
+	Pages int `json:"pages"`
+}
+
+// FormList is a page of the registered forms
+type FormList struct {
+	Forms      []*FormListItem    `json:"forms"`
+	Pagination FormListPagination `json:"pagination"`
+}
+
+// HasTags reports whether the form carries every one of the tags
+func (fs *FormSchema) HasTags(tags ...string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, own := range fs.Tags {
+			if strings.EqualFold(own, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether a form passes the filters of the query, besides its search text
+func (q *FormListQuery) matches(schema *FormSchema) bool {
+	if !schema.HasTags(q.Tags...) {
+		return false
+	}
+	if q.Category != "" && !strings.EqualFold(schema.Category, q.Category) {
+		return false
+	}
+	if q.Owner != "" && !strings.EqualFold(schema.Owner, q.Owner) {
+		return false
+	}
+	for key, expected := range q.Metadata {
+		value, ok := schema.Metadata[key]
+		if !ok || fmt.Sprint(value) != expected {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseFormListQuery reads the search, tag, category, owner, meta.*, sort, sortDir, page, and
+// limit query parameters of the form list. Tags may be repeated or separated by commas, and
+// metadata entries are matched by parameters such as meta.icon=clipboard; the page size is
+// bounded by MaxFormListLimit.
+func ParseFormListQuery(query url.Values) (*FormListQuery, error) {
+	listQuery := &FormListQuery{
+		Search:   strings.TrimSpace(query.Get("search")),
+		Category: query.Get("category"),
+		Owner:    query.Get("owner"),
+		Sort:     query.Get("sort"),
+		Page:     1,
+		Limit:    DefaultFormListLimit,
+	}
+	for name, values := range query {
+		if key, ok := strings.CutPrefix(name, "meta."); ok && key != "" {
+			if listQuery.Metadata == nil {
+				listQuery.Metadata = make(map[string]string)
+			}
+			listQuery.Metadata[key] = values[0]
+		}
+	}
+	for _, value := range query["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				listQuery.Tags = append(listQuery.Tags, tag)
+			}
+		}
+	}
+
+	switch listQuery.Sort {
+	case "":
+		listQuery.Sort = FormListSortID
+	case FormListSortID, FormListSortTitle, FormListSortVersion, FormListSortCategory:
+	default:
+		return nil, fmt.Errorf("invalid sort: %s", listQuery.Sort)
+	}
+	switch direction := query.Get("sortDir"); direction {
+	case "", "asc":
+	case "desc":
+		listQuery.Descending = true
+	default:
+		return nil, fmt.Errorf("invalid sortDir: %s", direction)
+	}
+
+	if page := query.Get("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid page: %s", page)
+		}
+		listQuery.Page = n
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", limit)
+		}
+		listQuery.Limit = min(n, MaxFormListLimit)
+	}
+	return listQuery, nil
+}
+
+// ListForms returns a page of the current versions of the registered forms matching a query.
+// Forms are sorted stably, by ID among equals, so pages never overlap or skip forms while the
+// registered forms stay the same.
+func (ah *APIHandler) ListForms(query *FormListQuery) *FormList {
+	search := strings.ToLower(query.Search)
+	ah.schemasLock.RLock()
+	matches := make([]*FormSchema, 0, len(ah.schemas))
+	for _, schema := range ah.schemas {
+		if !query.matches(schema) {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(schema.ID), search) &&
+			!strings.Contains(strings.ToLower(schema.Title), search) &&
+			!strings.Contains(strings.ToLower(schema.Description), search) {
+			continue
+		}
+		matches = append(matches, schema)
+	}
+	ah.schemasLock.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if query.Descending {
+			a, b = b, a
+		}
+		var order int
+		switch query.Sort {
+		case FormListSortTitle:
+			order = strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+		case FormListSortVersion:
+			order = compareSchemaVersions(a.Version, b.Version)
+		case FormListSortCategory:
+			order = strings.Compare(strings.ToLower(a.Category), strings.ToLower(b.Category))
+		}
+		if order == 0 {
+			return a.ID < b.ID
+		}
+		return order < 0
+	})
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultFormListLimit
+	}
+	page := max(query.Page, 1)
+	list := &FormList{
+		Forms: []*FormListItem{},
+		Pagination: FormListPagination{
+			Total: len(matches),
+			Page:  page,
+			Limit: limit,
+			Pages: (len(matches) + limit - 1) / limit,
+		},
+	}
+	start := min((page-1)*limit, len(matches))
+	for _, schema := range matches[start:min(start+limit, len(matches))] {
+		list.Forms = append(list.Forms, &FormListItem{
+			ID:          schema.ID,
+			Title:       schema.Title,
+			Description: schema.Description,
+			Version:     schema.Version,
+			Tags:        schema.Tags,
+			Category:    schema.Category,
+			Owner:       schema.Owner,
+			Metadata:    schema.Metadata,
+		})
+	}
+	return list
+}
+
+// handleForms handles requests to list the registered forms, a page at a time
+func (ah *APIHandler) handleForms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	query, err := ParseFormListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ah.ListForms(query))
+}
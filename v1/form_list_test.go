@@ -0,0 +1,144 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListForms(t *testing.T) {
+	handler := NewAPIHandler()
+	for _, form := range []*FormBuilder{
+		NewForm("onboarding", "Employee Onboarding").Description("New hires").Tag("hr", "people").Version("2.0.0"),
+		NewForm("leave", "Leave Request").Description("Time off for employees").Tag("hr"),
+		NewForm("expense", "Expense Claim").Tag("finance").Version("1.5.0"),
+		NewForm("contact", "Contact Us"),
+	} {
+		require.NoError(t, handler.RegisterSchema(form.Build()))
+	}
+	mux := handler.Handler()
+
+	list := func(query string) (int, *FormList) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms?"+query, nil))
+		var forms FormList
+		_ = json.Unmarshal(rec.Body.Bytes(), &forms)
+		return rec.Code, &forms
+	}
+	ids := func(forms *FormList) []string {
+		var ids []string
+		for _, form := range forms.Forms {
+			ids = append(ids, form.ID)
+		}
+		return ids
+	}
+
+	code, forms := list("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"contact", "expense", "leave", "onboarding"}, ids(forms))
+	assert.Equal(t, FormListPagination{Total: 4, Page: 1, Limit: DefaultFormListLimit, Pages: 1}, forms.Pagination)
+	assert.Equal(t, []string{"hr", "people"}, forms.Forms[3].Tags)
+
+	_, forms = list("limit=3&page=2")
+	assert.Equal(t, []string{"onboarding"}, ids(forms))
+	assert.Equal(t, FormListPagination{Total: 4, Page: 2, Limit: 3, Pages: 2}, forms.Pagination)
+	_, forms = list("limit=3&page=5")
+	assert.Empty(t, forms.Forms)
+	assert.Equal(t, 4, forms.Pagination.Total)
+
+	_, forms = list("search=EMPLOYEE")
+	assert.Equal(t, []string{"leave", "onboarding"}, ids(forms), "titles and descriptions are searched")
+	_, forms = list("tag=HR")
+	assert.Equal(t, []string{"leave", "onboarding"}, ids(forms))
+	_, forms = list("tag=hr&tag=people")
+	assert.Equal(t, []string{"onboarding"}, ids(forms))
+	_, forms = list("tag=hr,finance")
+	assert.Empty(t, forms.Forms)
+	assert.Equal(t, 0, forms.Pagination.Total)
+
+	_, forms = list("sort=title&sortDir=desc")
+	assert.Equal(t, []string{"leave", "expense", "onboarding", "contact"}, ids(forms))
+	_, forms = list("sort=version")
+	assert.Equal(t, []string{"contact", "leave", "expense", "onboarding"}, ids(forms), "equal versions are ordered by ID")
+
+	for _, query := range []string{"page=0", "limit=x", "sort=created", "sortDir=up"} {
+		code, _ = list(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestParseFormListQuery(t *testing.T) {
+	query, err := ParseFormListQuery(url.Values{"limit": {"10000"}, "tag": {" hr , people", "finance"}})
+	require.NoError(t, err)
+	assert.Equal(t, MaxFormListLimit, query.Limit)
+	assert.Equal(t, []string{"hr", "people", "finance"}, query.Tags)
+	assert.Equal(t, FormListSortID, query.Sort)
+}
+
+func TestFormCatalogMetadata(t *testing.T) {
+	handler := NewAPIHandler()
+	onboarding := NewForm("onboarding", "Employee Onboarding").
+		Tag("hr").Category("People").Owner("people-ops").Meta("icon", "clipboard").Meta("priority", 2).
+		Build()
+	require.NoError(t, handler.RegisterSchema(onboarding))
+	require.NoError(t, handler.RegisterSchema(NewForm("expense", "Expense Claim").Category("Finance").Meta("priority", 1).Build()))
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact Us").Build()))
+	mux := handler.Handler()
+
+	list := func(query string) []string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, query)
+		var forms FormList
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &forms))
+		var ids []string
+		for _, form := range forms.Forms {
+			ids = append(ids, form.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"onboarding"}, list("category=people"))
+	assert.Equal(t, []string{"onboarding"}, list("owner=People-Ops"))
+	assert.Equal(t, []string{"onboarding"}, list("meta.icon=clipboard"))
+	assert.Equal(t, []string{"expense"}, list("meta.priority=1"))
+	assert.Empty(t, list("meta.icon=clipboard&category=finance"))
+	assert.Equal(t, []string{"contact", "expense", "onboarding"}, list("sort=category"))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms?category=people", nil))
+	var forms FormList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &forms))
+	require.Len(t, forms.Forms, 1)
+	assert.Equal(t, "people-ops", forms.Forms[0].Owner)
+	assert.Equal(t, "clipboard", forms.Forms[0].Metadata["icon"])
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/onboarding", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var detail map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, []interface{}{"hr"}, detail["tags"])
+	assert.Equal(t, "People", detail["category"])
+	assert.Equal(t, "people-ops", detail["owner"])
+	assert.Equal(t, map[string]interface{}{"icon": "clipboard", "priority": float64(2)}, detail["metadata"])
+
+	clone := onboarding.Clone()
+	clone.Metadata["icon"] = "briefcase"
+	clone.Tags[0] = "finance"
+	assert.Equal(t, "clipboard", onboarding.Metadata["icon"])
+	assert.Equal(t, []string{"hr"}, onboarding.Tags)
+
+	data, err := json.Marshal(onboarding)
+	require.NoError(t, err)
+	imported, err := FormSchemaFromJSON(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, onboarding.Tags, imported.Tags)
+	assert.Equal(t, "People", imported.Category)
+	assert.Equal(t, "people-ops", imported.Owner)
+	assert.Equal(t, "clipboard", imported.Metadata["icon"])
+}
@@ -0,0 +1,153 @@
+package smartform
+
+import "fmt"
+
+// Preview query parameter, response header, and the property marking simulated data
+const (
+	PreviewQueryParam = "preview"
+	PreviewHeader     = "X-Smartform-Preview"
+	PreviewProperty   = "preview"
+)
+
+// Sources of simulated preview data
+const (
+	PreviewSourceMock      = "mock"      // Representative data configured with the field
+	PreviewSourceGenerated = "generated" // Data made up by the sample generator
+)
+
+// DefaultSampleOptionCount is how many options the default generator makes up
+const DefaultSampleOptionCount = 3
+
+// SampleGenerator makes up representative data for dynamic fields that have no mock data,
+// so forms can be previewed without their backends
+type SampleGenerator interface {
+	// SampleOptions returns options standing in for a field's dynamic options
+	SampleOptions(field *Field) []*Option
+	// SampleValue returns a value standing in for a field's computed value
+	SampleValue(field *Field) interface{}
+}
+
+// DefaultSampleGenerator generates placeholder options and values from the field's type
+type DefaultSampleGenerator struct{}
+
+// SampleOptions returns numbered placeholder options of the field's declared value type
+func (DefaultSampleGenerator) SampleOptions(field *Field) []*Option {
+	valueType := OptionValueTypeAny
+	if field.Options != nil && field.Options.ValueType != "" {
+		valueType = field.Options.ValueType
+	}
+
+	if valueType == OptionValueTypeBoolean {
+		return []*Option{
+			{Value: true, Label: "Sample yes"},
+			{Value: false, Label: "Sample no"},
+		}
+	}
+
+	options := make([]*Option, DefaultSampleOptionCount)
+	for i := range options {
+		var value interface{} = fmt.Sprintf("sample-%d", i+1)
+		if valueType == OptionValueTypeNumber {
+			value = float64(i + 1)
+		}
+		options[i] = &Option{Value: value, Label: fmt.Sprintf("Sample option %d", i+1)}
+	}
+	return options
+}
+
+// SampleValue returns a placeholder value suited to the field's type
+func (DefaultSampleGenerator) SampleValue(field *Field) interface{} {
+	switch field.Type {
+	case FieldTypeNumber, FieldTypeSlider, FieldTypeRating:
+		return float64(42)
+	case FieldTypeCheckbox, FieldTypeSwitch:
+		return true
+	case FieldTypeEmail:
+		return "preview@example.com"
+	case FieldTypeDate:
+		return "2024-01-01"
+	case FieldTypeTime:
+		return "12:00"
+	case FieldTypeDateTime:
+		return "2024-01-01T12:00:00Z"
+	case FieldTypeDateRange:
+		return map[string]interface{}{"start": "2024-01-01", "end": "2024-01-07"}
+	default:
+		return "Sample " + field.Label
+	}
+}
+
+// Preview returns a copy of the schema whose dynamic options and computed values are replaced
+// by representative data, so designers can preview a form's logic without configuring real
+// backends. Mock data configured on a field is preferred; generator makes up the rest. Every
+// simulated field is marked with a "preview" property naming what was replaced and where the
+// data came from, and the schema itself is marked too.
+func (fs *FormSchema) Preview(generator SampleGenerator) *FormSchema {
+	if generator == nil {
+		generator = DefaultSampleGenerator{}
+	}
+
+	preview := fs.Clone()
+	if preview.Properties == nil {
+		preview.Properties = make(map[string]interface{})
+	}
+	preview.Properties[PreviewProperty] = true
+	previewFields(preview.Fields, generator)
+	return preview
+}
+
+// previewFields replaces the dynamic data of fields and their nested fields
+func previewFields(fields []*Field, generator SampleGenerator) {
+	for _, field := range fields {
+		simulated := map[string]interface{}{}
+
+		if field.Options != nil && field.Options.DynamicSource != nil {
+			options, source := field.Options.DynamicSource.Mock, PreviewSourceMock
+			if len(options) == 0 {
+				options, source = generator.SampleOptions(field), PreviewSourceGenerated
+			}
+			field.Options = &OptionsConfig{
+				Type:      OptionsTypeStatic,
+				Static:    cloneOptions(options),
+				ValueType: field.Options.ValueType,
+			}
+			simulated["options"] = source
+		}
+
+		if computed, _ := field.Properties["dynamicValue"].(bool); computed {
+			value, source := field.Properties["previewValue"], PreviewSourceMock
+			if value == nil {
+				value, source = generator.SampleValue(field), PreviewSourceGenerated
+			}
+			field.DefaultValue = value
+			simulated["value"] = source
+		}
+
+		if len(simulated) > 0 {
+			if field.Properties == nil {
+				field.Properties = make(map[string]interface{})
+			}
+			field.Properties[PreviewProperty] = simulated
+		}
+
+		previewFields(field.Nested, generator)
+	}
+}
+
+// SetSampleGenerator sets the generator that makes up data for previews of dynamic fields
+// without mock data
+func (ah *APIHandler) SetSampleGenerator(generator SampleGenerator) {
+	ah.sampleGenerator = generator
+}
+
+// WithMock sets representative options shown instead of the dynamic ones when the form is previewed
+func (dob *DynamicOptionsBuilder) WithMock(options ...*Option) *DynamicOptionsBuilder {
+	dob.config.DynamicSource.Mock = options
+	return dob
+}
+
+// PreviewValue sets the value shown for a computed field when the form is previewed
+func (fb *FieldBuilder) PreviewValue(value interface{}) *FieldBuilder {
+	fb.field.Properties["previewValue"] = value
+	return fb
+}
@@ -0,0 +1,76 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormPreview(t *testing.T) {
+	country := NewFieldBuilder("country", FieldTypeSelect, "Country").Build()
+	country.Options = NewOptionsBuilder().Dynamic().
+		FromAPI("https://countries.internal/api", "GET").
+		WithMock(&Option{Value: "NZ", Label: "New Zealand"}).
+		Build()
+
+	plan := NewFieldBuilder("plan", FieldTypeSelect, "Plan").Build()
+	plan.Options = NewOptionsBuilder().Dynamic().FromFunction("plans").ValueType(OptionValueTypeNumber).Build()
+
+	quote := NewFieldBuilder("quote", FieldTypeNumber, "Quote")
+	quote.DynamicValue("computeQuote")
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Signup").
+		AddField(country).
+		AddField(plan).
+		AddField(quote.PreviewValue(99.5).Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/signup?preview=true", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(PreviewHeader))
+
+	var rendered struct {
+		Properties map[string]interface{} `json:"properties"`
+		Fields     []struct {
+			ID           string                 `json:"id"`
+			DefaultValue interface{}            `json:"defaultValue"`
+			Properties   map[string]interface{} `json:"properties"`
+			Options      *struct {
+				Type          string    `json:"type"`
+				Static        []*Option `json:"static"`
+				DynamicSource *struct{} `json:"dynamicSource"`
+			} `json:"options"`
+		} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	assert.Equal(t, true, rendered.Properties[PreviewProperty])
+	require.Len(t, rendered.Fields, 3)
+
+	// Mock options are used as configured, without the real endpoint
+	assert.Equal(t, "static", rendered.Fields[0].Options.Type)
+	assert.Nil(t, rendered.Fields[0].Options.DynamicSource)
+	assert.Equal(t, "New Zealand", rendered.Fields[0].Options.Static[0].Label)
+	assert.Equal(t, map[string]interface{}{"options": PreviewSourceMock}, rendered.Fields[0].Properties[PreviewProperty])
+
+	// Options without mock data are generated with the declared value type
+	require.Len(t, rendered.Fields[1].Options.Static, DefaultSampleOptionCount)
+	assert.Equal(t, float64(1), rendered.Fields[1].Options.Static[0].Value)
+	assert.Equal(t, map[string]interface{}{"options": PreviewSourceGenerated}, rendered.Fields[1].Properties[PreviewProperty])
+
+	assert.Equal(t, 99.5, rendered.Fields[2].DefaultValue)
+	assert.Equal(t, map[string]interface{}{"value": PreviewSourceMock}, rendered.Fields[2].Properties[PreviewProperty])
+
+	// Without the flag the form is rendered as registered
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/signup", nil))
+	assert.Empty(t, rec.Header().Get(PreviewHeader))
+	assert.NotContains(t, rec.Body.String(), "Sample option")
+}
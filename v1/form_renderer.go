@@ -3,22 +3,26 @@ package smartform
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
-
-	"github.com/juicycleff/smartform/v1/template"
 )
 
 // FormRenderer converts form schemas to JSON representations for the frontend
 type FormRenderer struct {
-	schema         *FormSchema
-	templateEngine *template.TemplateEngine
+	schema       *FormSchema
+	evaluator    TemplateEvaluator
+	diagnostics  *RenderDiagnostics // Set while rendering with diagnostics
+	translations map[string]string  // Translations of the locale set with SetLocale
+	computed     *ComputedValues    // Computed values of the context being rendered
+	locale       string             // Locale set with SetLocale
+	branchForms  FormLookup         // Finds the forms branches lead to, set with SetBranchForms
+	branching    []string           // IDs of the forms whose branches led to the one rendered
+	access       *AccessContext     // Who the form is rendered for, set with SetAccess
 }
 
 // NewFormRenderer creates a new form renderer
 func NewFormRenderer(schema *FormSchema) *FormRenderer {
 	return &FormRenderer{
-		schema:         schema,
-		templateEngine: template.NewTemplateEngine(),
+		schema:    schema,
+		evaluator: schema.TemplateEvaluator(),
 	}
 }
 
@@ -33,6 +37,13 @@ func (fr *FormRenderer) RenderJSON() (string, error) {
 
 // RenderJSONWithContext renders the form with context-specific modifications
 func (fr *FormRenderer) RenderJSONWithContext(context map[string]interface{}) (string, error) {
+	// Compiled schemas only render the fields depending on the context
+	if compiled := fr.schema.compiled; compiled != nil {
+		if data, ok, err := compiled.render(fr, context); err != nil || ok {
+			return string(data), err
+		}
+	}
+
 	// Create a copy of the schema to modify
 	schemaCopy := fr.copySchemaWithContext(context)
 
@@ -45,7 +56,7 @@ func (fr *FormRenderer) RenderJSONWithContext(context map[string]interface{}) (s
 }
 
 // copyFieldWithContext creates a context-aware copy of a field
-func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]interface{}) *Field {
+func (fr *FormRenderer) copyFieldWithContext(field *Field, path string, context map[string]interface{}) *Field {
 	// Create a new field with the same basic properties
 	fieldCopy := &Field{
 		ID:              field.ID,
@@ -56,42 +67,72 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 		Placeholder:     field.Placeholder,
 		HelpText:        field.HelpText,
 		Order:           field.Order,
+		Verify:          field.Verify,
+		Sensitive:       field.Sensitive,
 		ValidationRules: make([]*ValidationRule, len(field.ValidationRules)),
 		Properties:      make(map[string]interface{}),
 		Nested:          []*Field{},
 	}
 
-	fieldCopy.Label = fr.evaluateTemplateString(field.Label, context)
-	fieldCopy.Placeholder = fr.evaluateTemplateString(field.Placeholder, context)
-	fieldCopy.HelpText = fr.evaluateTemplateString(field.HelpText, context)
+	fieldCopy.Label = fr.evaluateTemplateString(fr.translate(path+".label", field.Label), context)
+	fieldCopy.Placeholder = fr.evaluateTemplateString(fr.translate(path+".placeholder", field.Placeholder), context)
+	fieldCopy.HelpText = fr.evaluateTemplateString(fr.translate(path+".helpText", field.HelpText), context)
+	if field.Help != nil {
+		fieldCopy.Help = field.Help.Clone()
+		fieldCopy.Help.Markdown = fr.evaluateTemplateString(field.Help.Markdown, context)
+	}
 
 	// Handle DefaultWhen conditions
 	if field.DefaultWhen != nil && len(field.DefaultWhen) > 0 {
 		validator := NewValidator(fr.schema)
-		for _, defaultWhen := range field.DefaultWhen {
+		for i, defaultWhen := range field.DefaultWhen {
 			if validator.evaluateCondition(defaultWhen.Condition, context) {
+				rule := i
+				provenance := &ValueProvenance{Source: ProvenanceDefaultWhen, Rule: &rule}
 				// Evaluate the default value if it's a template expression
-				if strValue, ok := defaultWhen.Value.(string); ok && fr.containsTemplateExpression(strValue) {
-					evaluatedValue, err := fr.templateEngine.EvaluateExpression(strValue, context)
+				if strValue, ok := defaultWhen.Value.(string); ok && fr.evaluator.IsTemplate(strValue) {
+					provenance.Template = strValue
+					evaluatedValue, err := fr.evaluator.Evaluate(strValue, context)
 					if err == nil {
 						fieldCopy.DefaultValue = evaluatedValue
 					} else {
 						fieldCopy.DefaultValue = defaultWhen.Value
+						provenance.Error = err.Error()
 					}
 				} else {
 					fieldCopy.DefaultValue = defaultWhen.Value
 				}
+				provenance.Value = fieldCopy.DefaultValue
+				fr.recordProvenance(path, provenance)
 				break
 			}
 		}
 	} else if field.DefaultValue != nil {
+		provenance := &ValueProvenance{Source: ProvenanceStatic}
+		if source, ok := fr.schema.prefills[path]; ok {
+			provenance = &ValueProvenance{Source: ProvenancePrefill, Prefill: source}
+		}
 		// Evaluate the default value if it's a template expression
-		if strValue, ok := field.DefaultValue.(string); ok && fr.containsTemplateExpression(strValue) {
-			evaluatedValue, err := fr.templateEngine.EvaluateExpression(strValue, context)
+		if strValue, ok := field.DefaultValue.(string); ok && fr.evaluator.IsTemplate(strValue) {
+			provenance.Template = strValue
+			evaluatedValue, err := fr.evaluator.Evaluate(strValue, context)
 			if err == nil {
 				fieldCopy.DefaultValue = evaluatedValue
+			} else {
+				provenance.Error = err.Error()
 			}
 		}
+		provenance.Value = fieldCopy.DefaultValue
+		fr.recordProvenance(path, provenance)
+	}
+
+	// Computed values replace defaults; those of dynamic functions are left to the client
+	fr.applyComputedValue(field, path, fieldCopy)
+	fr.computedProvenance(field, path, fieldCopy.DefaultValue)
+
+	// Values of sensitive fields are never sent back
+	if field.Sensitive && fieldCopy.DefaultValue != nil && fieldCopy.DefaultValue != "" {
+		fieldCopy.DefaultValue = SensitiveMask
 	}
 
 	// Handle requiredIf condition
@@ -103,7 +144,7 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 	for i, rule := range field.ValidationRules {
 		fieldCopy.ValidationRules[i] = &ValidationRule{
 			Type:       rule.Type,
-			Message:    rule.Message,
+			Message:    fr.translate(path+".validation."+string(rule.Type), rule.Message),
 			Parameters: rule.Parameters,
 		}
 	}
@@ -113,6 +154,16 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 		fieldCopy.Properties[k] = v
 	}
 
+	// Display fields are marked so clients never submit them
+	if field.Type == FieldTypeDisplay {
+		markDisplayField(fieldCopy)
+	}
+
+	// Fields the caller may see but not submit are shown read-only
+	if fr.access != nil && !fr.access.CanWrite(field) {
+		fieldCopy.Properties["readOnly"] = true
+	}
+
 	// Handle visibility condition
 	if field.Visible != nil {
 		fieldCopy.Visible = fr.copyCondition(field.Visible)
@@ -129,14 +180,32 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 		}
 	}
 
+	// Legal text is sent whole; the client shows sections as answers change
+	fieldCopy.Consent = field.Consent.Clone()
+
+	// The signature pad is drawn from the field's configuration
+	fieldCopy.Signature = field.Signature.Clone()
+	fieldCopy.Phone = field.Phone.Clone()
+	fieldCopy.Currency = field.Currency.Clone()
+	fieldCopy.DateRange = field.DateRange.Clone()
+
 	// Handle options for select-type fields
 	if field.Options != nil {
 		fieldCopy.Options = fr.copyOptionsWithContext(field.Options, context)
 	}
 
+	// Branches are rendered with the fields of the form they lead to
+	if field.Type == FieldTypeBranch {
+		fr.resolveBranch(field, fieldCopy, context)
+	}
+
 	// Handle nested fields
 	if field.Nested != nil {
 		for _, nestedField := range field.Nested {
+			if !fr.canRead(nestedField) {
+				continue
+			}
+
 			// Skip nested fields that aren't visible in this context
 			if nestedField.Visible != nil {
 				validator := NewValidator(fr.schema)
@@ -145,7 +214,7 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 				}
 			}
 
-			nestedCopy := fr.copyFieldWithContext(nestedField, context)
+			nestedCopy := fr.copyFieldWithContext(nestedField, joinPath(path, nestedField.ID), context)
 			fieldCopy.Nested = append(fieldCopy.Nested, nestedCopy)
 		}
 	}
@@ -155,22 +224,16 @@ func (fr *FormRenderer) copyFieldWithContext(field *Field, context map[string]in
 
 // copySchemaWithContext creates a context-aware copy of the schema
 func (fr *FormRenderer) copySchemaWithContext(context map[string]interface{}) *FormSchema {
-	// Create a new schema with the same basic properties
-	schemaCopy := &FormSchema{
-		ID:          fr.schema.ID,
-		Title:       fr.schema.Title,
-		Description: fr.schema.Description,
-		Fields:      []*Field{},
-		Properties:  make(map[string]interface{}),
-	}
-
-	// Copy over properties
-	for k, v := range fr.schema.Properties {
-		schemaCopy.Properties[k] = v
-	}
+	schemaCopy := fr.copySchemaHeader()
+	context = fr.renderState(context)
 
 	// Process fields based on context
 	for _, field := range fr.schema.Fields {
+		// Fields the caller may not see are left out, along with their values
+		if !fr.canRead(field) {
+			continue
+		}
+
 		// Skip fields that should not be visible in this context
 		if field.Visible != nil {
 			// Create a validator to evaluate the condition
@@ -181,7 +244,7 @@ func (fr *FormRenderer) copySchemaWithContext(context map[string]interface{}) *F
 		}
 
 		// Include the field with possible context-specific modifications
-		fieldCopy := fr.copyFieldWithContext(field, context)
+		fieldCopy := fr.copyFieldWithContext(field, field.ID, context)
 		schemaCopy.Fields = append(schemaCopy.Fields, fieldCopy)
 	}
 
@@ -191,6 +254,43 @@ func (fr *FormRenderer) copySchemaWithContext(context map[string]interface{}) *F
 	return schemaCopy
 }
 
+// copySchemaHeader copies the schema without its fields, which depend on the context
+func (fr *FormRenderer) copySchemaHeader() *FormSchema {
+	// Create a new schema with the same basic properties
+	schemaCopy := &FormSchema{
+		ID:          fr.schema.ID,
+		Title:       fr.translate("title", fr.schema.Title),
+		Version:     fr.schema.Version,
+		Description: fr.translate("description", fr.schema.Description),
+		Tags:        fr.schema.Tags,
+		Category:    fr.schema.Category,
+		Owner:       fr.schema.Owner,
+		Metadata:    fr.schema.Metadata,
+		Fields:      []*Field{},
+		Properties:  make(map[string]interface{}),
+	}
+
+	// Copy over properties
+	for k, v := range fr.schema.Properties {
+		schemaCopy.Properties[k] = v
+	}
+	return schemaCopy
+}
+
+// renderState returns a copy of the context with the evaluation time and the computed values
+// set, so conditions and templates see them
+func (fr *FormRenderer) renderState(context map[string]interface{}) map[string]interface{} {
+	context = cloneMap(context)
+	if context == nil {
+		context = map[string]interface{}{}
+	}
+	if _, ok := context[NowVariable]; !ok {
+		context[NowVariable] = fr.schema.now()
+	}
+	fr.computed, _ = fr.schema.computeValuesInto(context)
+	return context
+}
+
 // copyCondition creates a copy of a condition
 func (fr *FormRenderer) copyCondition(condition *Condition) *Condition {
 	if condition == nil {
@@ -231,9 +331,10 @@ func (fr *FormRenderer) copyOptionsWithContext(options *OptionsConfig, context m
 		optionsCopy.Static = make([]*Option, len(options.Static))
 		for i, option := range options.Static {
 			optionsCopy.Static[i] = &Option{
-				Value: option.Value,
-				Label: option.Label,
-				Icon:  option.Icon,
+				Value:     option.Value,
+				Label:     option.Label,
+				Icon:      option.Icon,
+				Condition: fr.copyCondition(option.Condition),
 			}
 		}
 	}
@@ -322,18 +423,13 @@ func (fr *FormRenderer) getValueFromContext(context map[string]interface{}, path
 
 // evaluateTemplateString evaluates a string that may contain template expressions
 func (fr *FormRenderer) evaluateTemplateString(input string, context map[string]interface{}) string {
-	if input == "" || !fr.containsTemplateExpression(input) {
+	if input == "" || !fr.evaluator.IsTemplate(input) {
 		return input
 	}
 
-	result, err := fr.templateEngine.EvaluateExpressionAsString(input, context)
+	result, err := fr.evaluator.Evaluate(input, context)
 	if err != nil {
 		return input
 	}
-	return result
-}
-
-// containsTemplateExpression checks if a string contains template expressions
-func (fr *FormRenderer) containsTemplateExpression(input string) bool {
-	return strings.Contains(input, "${")
+	return fmt.Sprintf("%v", result)
 }
@@ -0,0 +1,64 @@
+package smartform
+
+// ScriptKind identifies what a schema script implements
+type ScriptKind string
+
+// Define script kinds
+const (
+	ScriptKindFunction    ScriptKind = "function"    // Behaves like a DynamicFunction(args, formState)
+	ScriptKindTransformer ScriptKind = "transformer" // Behaves like a DataTransformer(data, params)
+)
+
+// ScriptDefinition is a function or transformer authored as a script and stored with the schema.
+// Scripts are executed by an optional scripting adapter (see the script package) and are
+// never sent to clients by the renderer.
+type ScriptDefinition struct {
+	Name     string     `json:"name"`
+	Kind     ScriptKind `json:"kind"`
+	Language string     `json:"language"` // e.g. "javascript"
+	Source   string     `json:"source"`
+}
+
+// AddScript attaches a script definition to the schema, replacing any script with the same name
+func (fs *FormSchema) AddScript(script *ScriptDefinition) *FormSchema {
+	for i, existing := range fs.Scripts {
+		if existing.Name == script.Name {
+			fs.Scripts[i] = script
+			return fs
+		}
+	}
+	fs.Scripts = append(fs.Scripts, script)
+	return fs
+}
+
+// FindScript returns a script definition by name
+func (fs *FormSchema) FindScript(name string) *ScriptDefinition {
+	for _, script := range fs.Scripts {
+		if script.Name == name {
+			return script
+		}
+	}
+	return nil
+}
+
+// FunctionScript adds a JavaScript dynamic function to the form
+func (fb *FormBuilder) FunctionScript(name, source string) *FormBuilder {
+	fb.schema.AddScript(&ScriptDefinition{
+		Name:     name,
+		Kind:     ScriptKindFunction,
+		Language: "javascript",
+		Source:   source,
+	})
+	return fb
+}
+
+// TransformerScript adds a JavaScript data transformer to the form
+func (fb *FormBuilder) TransformerScript(name, source string) *FormBuilder {
+	fb.schema.AddScript(&ScriptDefinition{
+		Name:     name,
+		Kind:     ScriptKindTransformer,
+		Language: "javascript",
+		Source:   source,
+	})
+	return fb
+}
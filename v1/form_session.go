@@ -0,0 +1,435 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultFormSessionTTL is how long a form session is kept after its last change
+const DefaultFormSessionTTL = 7 * 24 * time.Hour
+
+// ErrFormSessionNotFound is returned when a form session does not exist or has expired
+var ErrFormSessionNotFound = errors.New("form session not found")
+
+// FormSession is the saved state of a partially completed form
+type FormSession struct {
+	ID        string                 `json:"id"`
+	FormID    string                 `json:"formId"`
+	Version   string                 `json:"version,omitempty"` // Version of the form the session was started with
+	Data      map[string]interface{} `json:"data"`
+	CreatedAt time.Time              `json:"createdAt"`
+	UpdatedAt time.Time              `json:"updatedAt"`
+	ExpiresAt time.Time              `json:"expiresAt"`
+}
+
+// FormSessionStore persists form sessions
+type FormSessionStore interface {
+	// Create stores a new session
+	Create(session *FormSession) error
+	// Get loads a session by ID
+	Get(id string) (*FormSession, error)
+	// Update applies a change to a stored session atomically
+	Update(id string, change func(session *FormSession) error) (*FormSession, error)
+	// Delete removes a session
+	Delete(id string) error
+}
+
+// MemoryFormSessionStore keeps form sessions in memory
+type MemoryFormSessionStore struct {
+	sessions map[string]*FormSession
+	lock     sync.Mutex
+}
+
+// NewMemoryFormSessionStore creates a new in-memory form session store
+func NewMemoryFormSessionStore() *MemoryFormSessionStore {
+	return &MemoryFormSessionStore{
+		sessions: make(map[string]*FormSession),
+	}
+}
+
+// Create stores a new session
+func (ms *MemoryFormSessionStore) Create(session *FormSession) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.removeExpired()
+	ms.sessions[session.ID] = session.Clone()
+	return nil
+}
+
+// Get loads a session by ID
+func (ms *MemoryFormSessionStore) Get(id string) (*FormSession, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	session, ok := ms.live(id)
+	if !ok {
+		return nil, ErrFormSessionNotFound
+	}
+	return session.Clone(), nil
+}
+
+// Update applies a change to a stored session atomically
+func (ms *MemoryFormSessionStore) Update(id string, change func(session *FormSession) error) (*FormSession, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	stored, ok := ms.live(id)
+	if !ok {
+		return nil, ErrFormSessionNotFound
+	}
+
+	updated := stored.Clone()
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+	ms.sessions[id] = updated
+	return updated.Clone(), nil
+}
+
+// Delete removes a session
+func (ms *MemoryFormSessionStore) Delete(id string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	if _, ok := ms.live(id); !ok {
+		return ErrFormSessionNotFound
+	}
+	delete(ms.sessions, id)
+	return nil
+}
+
+// live returns a session that has not expired, dropping it if it has
+func (ms *MemoryFormSessionStore) live(id string) (*FormSession, bool) {
+	session, ok := ms.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if session.expired(time.Now()) {
+		delete(ms.sessions, id)
+		return nil, false
+	}
+	return session, true
+}
+
+// removeExpired drops every expired session
+func (ms *MemoryFormSessionStore) removeExpired() {
+	now := time.Now()
+	for id, session := range ms.sessions {
+		if session.expired(now) {
+			delete(ms.sessions, id)
+		}
+	}
+}
+
+// Clone returns a deep copy of the session
+func (fs *FormSession) Clone() *FormSession {
+	if fs == nil {
+		return nil
+	}
+	clone := *fs
+	clone.Data = cloneMap(fs.Data)
+	return &clone
+}
+
+// expired reports whether the session has passed its expiry time
+func (fs *FormSession) expired(now time.Time) bool {
+	return !fs.ExpiresAt.IsZero() && now.After(fs.ExpiresAt)
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396) to data: objects are merged recursively
+// and null removes a key
+func mergePatch(data, patch map[string]interface{}) map[string]interface{} {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(data, key)
+			continue
+		}
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			target, _ := data[key].(map[string]interface{})
+			data[key] = mergePatch(target, patchObject)
+			continue
+		}
+		data[key] = value
+	}
+	return data
+}
+
+// SetFormSessionStore sets the store partially completed forms are saved to
+func (ah *APIHandler) SetFormSessionStore(store FormSessionStore) {
+	ah.formSessions = store
+}
+
+// SetFormSessionTTL sets how long a form session is kept after its last change
+func (ah *APIHandler) SetFormSessionTTL(ttl time.Duration) {
+	ah.formSessionTTL = ttl
+}
+
+// sessionExpiry returns the expiry time of a session of a form changed now, after the retention
+// period of the form or the session TTL
+func (ah *APIHandler) sessionExpiry(schema *FormSchema, now time.Time) time.Time {
+	if schema.Autosave != nil && schema.Autosave.RetentionSeconds > 0 {
+		return now.Add(time.Duration(schema.Autosave.RetentionSeconds) * time.Second)
+	}
+	if ah.formSessionTTL > 0 {
+		return now.Add(ah.formSessionTTL)
+	}
+	return now.Add(DefaultFormSessionTTL)
+}
+
+// sessionFormState returns formState laid over the data saved in a form session, so that
+// functions see the stored answers together with unsaved changes. formState is returned
+// unchanged when sessionID does not name a form session.
+func (ah *APIHandler) sessionFormState(sessionID string, formState map[string]interface{}) map[string]interface{} {
+	if sessionID == "" || ah.formSessions == nil {
+		return formState
+	}
+	session, err := ah.formSessions.Get(sessionID)
+	if err != nil {
+		return formState
+	}
+	data := session.Data
+	if schema, ok := ah.GetSchema(session.FormID); ok {
+		if decrypted, err := ah.decryptSensitive(context.Background(), schema, data); err == nil {
+			data = decrypted
+		}
+	}
+	return overlayVariables(data, formState)
+}
+
+// functionState builds the state a dynamic function runs with: the answers saved in the form
+// session, overlaid with the submitted state and the middleware variables, and carrying the
+// session's scratch memory
+func (ah *APIHandler) functionState(r *http.Request, bodySessionID string, formState map[string]interface{}) map[string]interface{} {
+	sessionID := requestSessionID(r, bodySessionID)
+	state := ah.withRenderContext(r, ah.sessionFormState(sessionID, formState))
+	return ah.dynamicFunctionService.WithSession(sessionID, state)
+}
+
+// handleFormSessions handles requests to create, resume, patch, validate, and delete form
+// sessions, and to issue resume links to them
+func (ah *APIHandler) handleFormSessions(w http.ResponseWriter, r *http.Request) {
+	if ah.formSessions == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "Form sessions are not configured"))
+		return
+	}
+
+	segments := splitPath(getPathParam(r.URL.Path, "/api/sessions/"))
+	if len(segments) == 0 {
+		writeError(w, badRequest("Form ID is required"))
+		return
+	}
+	schema, ok := ah.GetSchema(segments[0])
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+	if schema.autosaveDisabled() {
+		ah.writeFormSession(w, nil, ErrAutosaveDisabled)
+		return
+	}
+
+	if len(segments) == 1 {
+		if r.Method != http.MethodPost {
+			writeError(w, ErrMethodNotAllowed)
+			return
+		}
+		ah.createFormSession(w, r, schema)
+		return
+	}
+
+	session, err := ah.formSessions.Get(segments[1])
+	if err == nil && session.FormID != schema.ID {
+		err = ErrFormSessionNotFound
+	}
+	if err != nil {
+		ah.writeFormSession(w, nil, err)
+		return
+	}
+
+	switch {
+	case len(segments) == 2 && r.Method == http.MethodGet:
+		ah.writeFormSession(w, session, nil)
+
+	case len(segments) == 2 && r.Method == http.MethodPatch:
+		var patch map[string]interface{}
+		if err := decodeRequest(r.Body, &patch); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		// Masked values sent back unchanged keep the values they stand for
+		patch, err := ah.encryptSensitive(r.Context(), schema, dropMasked(schema.Fields, patch))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		updated, err := ah.formSessions.Update(session.ID, func(session *FormSession) error {
+			session.Data = mergePatch(session.Data, patch)
+			session.UpdatedAt = time.Now()
+			session.ExpiresAt = ah.sessionExpiry(schema, session.UpdatedAt)
+			return nil
+		})
+		ah.writeFormSession(w, updated, err)
+
+	case len(segments) == 2 && r.Method == http.MethodDelete:
+		if err := ah.formSessions.Delete(session.ID); err != nil {
+			ah.writeFormSession(w, nil, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(segments) == 3 && segments[2] == "validate" && r.Method == http.MethodPost:
+		ah.validateFormSession(w, r, schema, session)
+
+	case len(segments) == 3 && segments[2] == "link" && r.Method == http.MethodPost:
+		ah.writeResumeLink(w, session)
+
+	case len(segments) == 2 || (len(segments) == 3 && (segments[2] == "validate" || segments[2] == "link")):
+		writeError(w, ErrMethodNotAllowed)
+
+	default:
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+	}
+}
+
+// createFormSession starts a session, optionally with initial data in the request body
+func (ah *APIHandler) createFormSession(w http.ResponseWriter, r *http.Request, schema *FormSchema) {
+	data := map[string]interface{}{}
+	if r.ContentLength != 0 {
+		if err := decodeRequest(r.Body, &data); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+	data, err := ah.encryptSensitive(r.Context(), schema, data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	now := time.Now()
+	session := &FormSession{
+		ID:        newID(),
+		FormID:    schema.ID,
+		Version:   schema.Version,
+		Data:      data,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: ah.sessionExpiry(schema, now),
+	}
+	if err := ah.formSessions.Create(session); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ah.maskFormSession(session))
+}
+
+// validateFormSession validates the data saved in a session. The fields query parameter
+// limits validation to the fields of one step.
+func (ah *APIHandler) validateFormSession(w http.ResponseWriter, r *http.Request, schema *FormSchema, session *FormSession) {
+	query := r.URL.Query()
+	data, err := ah.decryptSensitive(r.Context(), schema, session.Data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	options, err := ParseValidationOptions(query, data)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	// Sessions started with an older version of the form are upgraded first
+	if session.Version != "" && schema.Version != "" {
+		data, err = ah.MigrateData(schema.ID, session.Version, schema.Version, data)
+		if err != nil {
+			writeError(w, statusError(http.StatusConflict, err))
+			return
+		}
+	}
+
+	if include := ParseFieldList(query.Get(FieldsQueryParam)); len(include) > 0 {
+		schema = schema.SelectFields(include, nil)
+	}
+
+	options.TamperPolicy = ah.tamperPolicy
+	options.Access = ah.accessContext(r)
+	options.OptionResolver = ah.optionResolver(r)
+	options.Verifiers = ah.verifiers
+	options.RemoteValidators = ah.getRemoteValidators()
+	options.FileStorage = ah.fileStorage
+	options.FailureLogger = ah.failureLogger
+	options.Context = r.Context()
+	options.Variables = ah.renderContext(r)
+
+	start := time.Now()
+	result := NewValidator(schema).ValidateFormWithOptions(data, options)
+	ah.recordValidationFailure(r, start, schema, result, "session")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// writeFormSession writes a form session or the error that occurred loading it
+func (ah *APIHandler) writeFormSession(w http.ResponseWriter, session *FormSession, err error) {
+	if errors.Is(err, ErrFormSessionNotFound) {
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Form session not found"))
+		return
+	}
+	if errors.Is(err, ErrAutosaveDisabled) {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Drafts are disabled for this form"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ah.maskFormSession(session))
+}
+
+// maskFormSession returns a copy of a session with the values of sensitive fields masked
+func (ah *APIHandler) maskFormSession(session *FormSession) *FormSession {
+	schema, ok := ah.GetSchema(session.FormID)
+	if !ok || !schema.hasSensitiveFields() {
+		return session
+	}
+	masked := *session
+	masked.Data = schema.MaskSensitive(session.Data)
+	return &masked
+}
+
+// dropMasked returns a copy of one level of a patch without the masked values of sensitive
+// fields
+func dropMasked(fields []*Field, patch map[string]interface{}) map[string]interface{} {
+	if patch == nil {
+		return nil
+	}
+	kept := make(map[string]interface{}, len(patch))
+	for key, value := range patch {
+		kept[key] = value
+	}
+	for _, field := range fields {
+		switch value := kept[field.ID].(type) {
+		case string:
+			if field.Sensitive && value == SensitiveMask {
+				delete(kept, field.ID)
+			}
+		case map[string]interface{}:
+			kept[field.ID] = dropMasked(field.Nested, value)
+		}
+	}
+	return kept
+}
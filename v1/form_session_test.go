@@ -0,0 +1,90 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFormSessionHandler(t *testing.T) (*APIHandler, *http.ServeMux) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("application", "Application").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Required(true).Build()).
+		AddField(NewFieldBuilder("income", FieldTypeNumber, "Income").Required(true).Build()).
+		Build()))
+
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("greeting", func(args, formState map[string]interface{}) (interface{}, error) {
+		return "Hello " + formState["name"].(string), nil
+	})
+	handler.SetDynamicFunctionService(functions)
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	return handler, mux
+}
+
+func sessionRequest(t *testing.T, mux *http.ServeMux, method, path, body string, target interface{}) int {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if target != nil && rec.Code < 300 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), target), rec.Body.String())
+	}
+	return rec.Code
+}
+
+func TestFormSessionLifecycle(t *testing.T) {
+	_, mux := newFormSessionHandler(t)
+
+	var session FormSession
+	require.Equal(t, http.StatusCreated, sessionRequest(t, mux, http.MethodPost, "/api/sessions/application", `{"name": "Ada"}`, &session))
+	assert.Equal(t, "application", session.FormID)
+	assert.Equal(t, DefaultSchemaVersion, session.Version)
+	path := "/api/sessions/application/" + session.ID
+
+	// Step one is complete, the whole form is not
+	var result ValidationResult
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPost, path+"/validate?fields=name", "", &result))
+	assert.True(t, result.Valid)
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPost, path+"/validate", "", &result))
+	assert.False(t, result.Valid)
+
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPatch, path, `{"income": 52000, "draft": {"note": "x"}}`, &session))
+	var patched FormSession
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPatch, path, `{"draft": null}`, &patched))
+	assert.Equal(t, map[string]interface{}{"name": "Ada", "income": float64(52000)}, patched.Data)
+
+	// Resuming after a reload returns the saved answers
+	var resumed FormSession
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodGet, path, "", &resumed))
+	assert.Equal(t, patched.Data, resumed.Data)
+
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPost, path+"/validate", "", &result))
+	assert.True(t, result.Valid)
+
+	// Dynamic functions see the stored state
+	var greeting string
+	require.Equal(t, http.StatusOK, sessionRequest(t, mux, http.MethodPost, "/api/function/greeting",
+		`{"arguments": {}, "formState": {}, "sessionId": "`+session.ID+`"}`, &greeting))
+	assert.Equal(t, "Hello Ada", greeting)
+
+	assert.Equal(t, http.StatusNotFound, sessionRequest(t, mux, http.MethodGet, "/api/sessions/other/"+session.ID, "", nil))
+	assert.Equal(t, http.StatusNoContent, sessionRequest(t, mux, http.MethodDelete, path, "", nil))
+	assert.Equal(t, http.StatusNotFound, sessionRequest(t, mux, http.MethodGet, path, "", nil))
+}
+
+func TestFormSessionExpiry(t *testing.T) {
+	handler, mux := newFormSessionHandler(t)
+	handler.SetFormSessionTTL(time.Millisecond)
+
+	var session FormSession
+	require.Equal(t, http.StatusCreated, sessionRequest(t, mux, http.MethodPost, "/api/sessions/application", "", &session))
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, http.StatusNotFound, sessionRequest(t, mux, http.MethodGet, "/api/sessions/application/"+session.ID, "", nil))
+}
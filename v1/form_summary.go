@@ -0,0 +1,246 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// summaryLabelSeparator joins the labels of a field and the groups and array items it is in
+const summaryLabelSeparator = " › "
+
+// FormSummary is the review of a filled-in form shown on confirmation pages: the answers of
+// its visible fields, formatted for reading and grouped by section
+type FormSummary struct {
+	FormID   string            `json:"formId"`
+	Title    string            `json:"title"`
+	Sections []*SummarySection `json:"sections"`
+	Errors   map[string]string `json:"errors,omitempty"` // Errors of formatters and computed values, by field path
+}
+
+// SummarySection is a section of a form summary. Fields before the first section field are in
+// a section without an ID, titled after the form.
+type SummarySection struct {
+	ID      string          `json:"id,omitempty"`
+	Title   string          `json:"title"`
+	Entries []*SummaryEntry `json:"entries"`
+}
+
+// SummaryEntry is the answer of one field in a form summary
+type SummaryEntry struct {
+	FieldID string      `json:"fieldId"` // Path of the field, e.g. "items[0].name"
+	Label   string      `json:"label"`   // Label of the field, after those of the groups and array items it is in
+	Type    FieldType   `json:"type"`
+	Value   interface{} `json:"value"`   // Value as entered or computed
+	Display string      `json:"display"` // Value formatted for reading, empty for no answer
+}
+
+// SummaryOptions configures how a form summary is built
+type SummaryOptions struct {
+	Functions *DynamicFunctionService // Runs the formatters of fields; without it, values are formatted by type only
+	Locale    string                  // Locale of the labels, and of amounts of currency fields without one
+}
+
+// Summary builds the review of a form filled in with state: computed values are evaluated,
+// hidden fields left out, and values formatted with the formatters of their fields, or else by
+// type, as option labels, amounts, and times. Group and array item fields are flattened into
+// the section they are in.
+func (fs *FormSchema) Summary(state map[string]interface{}, options *SummaryOptions) (*FormSummary, error) {
+	if options == nil {
+		options = &SummaryOptions{}
+	}
+	data := cloneMap(state)
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	computed, err := fs.computeValuesInto(data)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := fs.Translations[options.Locale]
+	translate := func(key, text string) string {
+		if translation, ok := translations[pathIndexPattern.ReplaceAllString(key, "")]; ok {
+			return translation
+		}
+		return text
+	}
+
+	summary := &FormSummary{
+		FormID: fs.ID,
+		Title:  translate("title", fs.Title),
+		Errors: map[string]string{},
+	}
+	for path, message := range computed.Errors {
+		summary.Errors[path] = message
+	}
+	section := &SummarySection{Title: summary.Title, Entries: []*SummaryEntry{}}
+
+	validator := NewValidator(fs)
+	var summarize func(fields []*Field, values, scope map[string]interface{}, path, label string)
+	summarize = func(fields []*Field, values, scope map[string]interface{}, path, label string) {
+		for _, field := range fields {
+			if !summarized(field) || (field.Visible != nil && !validator.evaluateCondition(field.Visible, scope)) {
+				continue
+			}
+			fieldPath := joinPath(path, field.ID)
+			fieldLabel := translate(fieldPath+".label", field.Label)
+			if label != "" {
+				fieldLabel = label + summaryLabelSeparator + fieldLabel
+			}
+			value := values[field.ID]
+
+			// Values of sensitive fields are shown masked, whatever their type
+			if field.Sensitive && value != nil && value != "" {
+				section.Entries = append(section.Entries, &SummaryEntry{
+					FieldID: fieldPath,
+					Label:   fieldLabel,
+					Type:    field.Type,
+					Value:   SensitiveMask,
+					Display: SensitiveMask,
+				})
+				continue
+			}
+
+			switch field.Type {
+			case FieldTypeSection:
+				// Sections holding their fields are summarized like sections followed by them
+				if len(section.Entries) > 0 || section.ID != "" {
+					summary.Sections = append(summary.Sections, section)
+				}
+				section = &SummarySection{ID: field.ID, Title: translate(fieldPath+".label", field.Label), Entries: []*SummaryEntry{}}
+				summarize(field.Nested, values, scope, path, label)
+			case FieldTypeGroup, FieldTypeObject:
+				nested, _ := value.(map[string]interface{})
+				if nested == nil {
+					nested = map[string]interface{}{}
+				}
+				summarize(field.Nested, nested, overlayVariables(scope, nested), fieldPath, fieldLabel)
+			case FieldTypeArray:
+				items, _ := value.([]interface{})
+				for i, item := range items {
+					itemMap, ok := item.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					itemPath := fmt.Sprintf("%s[%d]", fieldPath, i)
+					summarize(field.Nested, itemMap, itemScope(scope, itemMap, values, i), itemPath, fmt.Sprintf("%s %d", fieldLabel, i+1))
+				}
+			default:
+				section.Entries = append(section.Entries, &SummaryEntry{
+					FieldID: fieldPath,
+					Label:   fieldLabel,
+					Type:    field.Type,
+					Value:   value,
+					Display: fs.summaryDisplay(field, fieldPath, value, data, options, summary.Errors),
+				})
+			}
+		}
+	}
+	summarize(fs.Fields, data, data, "", "")
+	if len(section.Entries) > 0 || section.ID != "" || len(summary.Sections) == 0 {
+		summary.Sections = append(summary.Sections, section)
+	}
+	if len(summary.Errors) == 0 {
+		summary.Errors = nil
+	}
+	return summary, nil
+}
+
+// summarized reports whether a field appears in form summaries
+func summarized(field *Field) bool {
+	switch field.Type {
+	case FieldTypeHidden, FieldTypePassword, FieldTypeAuth, FieldTypeAPI, FieldTypeBranch:
+		return false
+	}
+	return true
+}
+
+// summaryDisplay formats the value of a field with its formatter, or by type when it has none
+// or it fails. Failures are recorded in errors by field path.
+func (fs *FormSchema) summaryDisplay(field *Field, path string, value interface{}, state map[string]interface{}, options *SummaryOptions, errors map[string]string) string {
+	if value == nil {
+		return ""
+	}
+	if formatter := fieldFormatter(field); formatter != nil && options.Functions != nil {
+		formatted, err := formatter.format(options.Functions, value, state)
+		if err == nil {
+			return printValue(formatted)
+		}
+		errors[path] = err.Error()
+	}
+	return printValue(displayValue(field, value, options.Locale))
+}
+
+// fieldFormatter returns the formatter configured on a field, whether it was set with the
+// builder or imported from JSON
+func fieldFormatter(field *Field) *DynamicFieldConfig {
+	switch config := field.Properties["formatterFunction"].(type) {
+	case *DynamicFieldConfig:
+		return config
+	case map[string]interface{}:
+		var decoded DynamicFieldConfig
+		if err := decodeRaw(config, &decoded); err == nil && decoded.FunctionName != "" {
+			return &decoded
+		}
+	}
+	return nil
+}
+
+// format formats a value with the transformer the formatter names, given the formatter's
+// arguments as parameters, or else with the dynamic function it names, given the value as the
+// "value" argument and followed by the formatter's transformer
+func (dfc *DynamicFieldConfig) format(service *DynamicFunctionService, value interface{}, state map[string]interface{}) (interface{}, error) {
+	if service.HasTransformer(dfc.FunctionName) {
+		return service.TransformData(dfc.FunctionName, value, dfc.Arguments)
+	}
+
+	args := make(map[string]interface{}, len(dfc.Arguments)+1)
+	for key, arg := range dfc.Arguments {
+		args[key] = arg
+	}
+	args["value"] = value
+	formatted, err := service.ExecuteFunction(dfc.FunctionName, args, state)
+	if err != nil || dfc.TransformerName == "" {
+		return formatted, err
+	}
+	return service.TransformData(dfc.TransformerName, formatted, dfc.TransformerParams)
+}
+
+// handleFormSummary handles requests for the review of a form filled in with the posted state
+func (ah *APIHandler) handleFormSummary(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var request struct {
+		State map[string]interface{} `json:"state"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	options := &SummaryOptions{
+		Functions: ah.dynamicFunctionService,
+		Locale:    requestLocale(r.URL.Query(), r.Header.Get("Accept-Language"), schema),
+	}
+	summary, err := schema.Summary(ah.withRenderContext(r, request.State), options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if options.Locale != "" {
+		w.Header().Set("Content-Language", options.Locale)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(summary)
+}
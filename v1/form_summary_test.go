@@ -0,0 +1,101 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormSummary(t *testing.T) {
+	form := NewForm("order", "Order")
+	form.TextField("name", "Name")
+	form.PasswordField("password", "Password")
+	form.SelectField("plan", "Plan").WithStaticOptions([]*Option{NewOption("pro", "Professional")})
+	form.SectionField("shipping", "Shipping")
+	form.CheckboxField("gift", "Gift")
+	form.TextField("giftNote", "Gift note").VisibleWhenEquals("gift", true)
+	form.ArrayField("items", "Item").ItemTemplate(NewCurrencyFieldBuilder("price", "Price").DefaultCurrency("USD").Build())
+	form.NumberField("quantity", "Quantity")
+	form.NumberField("total", "Total").ComputedValue("${multiply(quantity, 2)}")
+	form.TextField("reference", "Reference").Formatter("upper")
+	form.TextField("code", "Code").Formatter("broken")
+	form.TranslationBundle("fr", map[string]string{"title": "Commande", "items.price.label": "Prix"})
+	schema := form.Build()
+
+	service := NewDynamicFunctionService()
+	service.RegisterTransformer("upper", func(data interface{}, params map[string]interface{}) (interface{}, error) {
+		return strings.ToUpper(fmt.Sprint(data)), nil
+	})
+	service.RegisterFunction("broken", func(args map[string]interface{}, state map[string]interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("formatter failed")
+	})
+
+	state := map[string]interface{}{
+		"name":      "Ada",
+		"password":  "secret",
+		"plan":      "pro",
+		"gift":      false,
+		"giftNote":  "Happy birthday",
+		"items":     []interface{}{map[string]interface{}{"price": map[string]interface{}{"amount": float64(1990), "currency": "USD"}}},
+		"quantity":  float64(3),
+		"reference": "ab-12",
+		"code":      "x1",
+	}
+	summary, err := schema.Summary(state, &SummaryOptions{Functions: service, Locale: "fr"})
+	require.NoError(t, err)
+	assert.NotContains(t, state, "total", "the state is left as is")
+
+	assert.Equal(t, "Commande", summary.Title)
+	require.Len(t, summary.Sections, 2)
+	assert.Equal(t, "", summary.Sections[0].ID)
+	assert.Equal(t, "Commande", summary.Sections[0].Title)
+	assert.Equal(t, "shipping", summary.Sections[1].ID)
+
+	displays := func(section *SummarySection) map[string]string {
+		result := map[string]string{}
+		for _, entry := range section.Entries {
+			result[entry.Label] = entry.Display
+		}
+		return result
+	}
+	assert.Equal(t, map[string]string{"Name": "Ada", "Plan": "Professional"}, displays(summary.Sections[0]))
+	assert.Equal(t, map[string]string{
+		"Gift":          "No",
+		"Item 1 › Prix": "19,90\u00a0$",
+		"Quantity":      "3",
+		"Total":         "6",
+		"Reference":     "AB-12",
+		"Code":          "x1",
+	}, displays(summary.Sections[1]))
+	assert.Equal(t, "items[0].price", summary.Sections[1].Entries[1].FieldID)
+	assert.Equal(t, map[string]string{"code": "formatter failed"}, summary.Errors)
+
+	// Over HTTP
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(service)
+	require.NoError(t, handler.RegisterSchema(schema))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	body, err := json.Marshal(map[string]interface{}{"state": state})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/forms/order/summary?locale=fr", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "fr", rec.Header().Get("Content-Language"))
+
+	var response FormSummary
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "Commande", response.Title)
+	assert.Equal(t, "AB-12", response.Sections[1].Entries[4].Display)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/order/summary", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,163 @@
+package smartform
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultFunctionCacheEntries is how many results a cached function keeps by default
+const DefaultFunctionCacheEntries = 1000
+
+// FunctionCache sets how the results of a pure dynamic function are memoized. Results are keyed
+// by the arguments of the call and the form state fields the function reads, so functions
+// depending on anything else, such as the time or the session, must not be cached.
+type FunctionCache struct {
+	TTL         time.Duration // How long results are kept; forever when zero
+	MaxEntries  int           // Most results kept, the least recently used evicted; DefaultFunctionCacheEntries when zero
+	StateFields []string      // Form state fields the result depends on, besides the arguments
+}
+
+// cachedResult is a result of a cached function, or the call computing it
+type cachedResult struct {
+	key      string
+	done     chan struct{}
+	value    interface{}
+	err      error
+	storedAt time.Time // When the call returned; zero while it runs
+}
+
+// functionCache memoizes the results of a function by call key. Results are kept in a list
+// ordered by use, the most recently used first, so that evicting one takes constant time.
+type functionCache struct {
+	config  FunctionCache
+	results map[string]*list.Element
+	order   *list.List
+	lock    sync.Mutex
+}
+
+// newFunctionCache creates the cache of a function
+func newFunctionCache(config FunctionCache) *functionCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultFunctionCacheEntries
+	}
+	return &functionCache{config: config, results: make(map[string]*list.Element), order: list.New()}
+}
+
+// key returns the key of a call, or false when its arguments or state cannot be encoded
+func (fc *functionCache) key(args, formState map[string]interface{}) (string, bool) {
+	state := make(map[string]interface{}, len(fc.config.StateFields))
+	for _, field := range fc.config.StateFields {
+		if value, ok := formState[field]; ok {
+			state[field] = value
+		}
+	}
+	data, err := json.Marshal([]interface{}{args, state})
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// wrap returns a function answering calls from the cache. Concurrent calls with the same key
+// wait for the one running; failures are not kept, so the next call runs again.
+func (fc *functionCache) wrap(fn DynamicFunction) DynamicFunction {
+	return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		key, ok := fc.key(args, formState)
+		if !ok {
+			return fn(args, formState)
+		}
+
+		fc.lock.Lock()
+		if element, ok := fc.results[key]; ok {
+			result := element.Value.(*cachedResult)
+			if !fc.expired(result, time.Now()) {
+				fc.order.MoveToFront(element)
+				fc.lock.Unlock()
+				<-result.done
+				if result.err != nil {
+					return fn(args, formState)
+				}
+				return result.value, nil
+			}
+			fc.remove(element)
+		}
+		fc.evict()
+		result := &cachedResult{key: key, done: make(chan struct{})}
+		element := fc.order.PushFront(result)
+		fc.results[key] = element
+		fc.lock.Unlock()
+
+		// Waiting calls are released even if the function panics, and run it themselves
+		defer func() {
+			fc.lock.Lock()
+			result.storedAt = time.Now()
+			if result.err != nil && fc.results[key] == element {
+				fc.remove(element)
+			}
+			fc.lock.Unlock()
+			close(result.done)
+		}()
+		result.err = ErrFunctionPanicked
+		result.value, result.err = fn(args, formState)
+		return result.value, result.err
+	}
+}
+
+// expired reports whether a result is past the TTL of the cache. The caller holds the lock, so
+// results of calls still running have not been stored and never expire.
+func (fc *functionCache) expired(result *cachedResult, now time.Time) bool {
+	return fc.config.TTL > 0 && !result.storedAt.IsZero() && now.Sub(result.storedAt) > fc.config.TTL
+}
+
+// evict drops the least recently used results while the cache is full. Calls still running are
+// not evicted; expired results are dropped when they are looked up. The caller holds the lock.
+func (fc *functionCache) evict() {
+	element := fc.order.Back()
+	for element != nil && len(fc.results) >= fc.config.MaxEntries {
+		previous := element.Prev()
+		if !element.Value.(*cachedResult).storedAt.IsZero() {
+			fc.remove(element)
+		}
+		element = previous
+	}
+}
+
+// remove drops a result from the cache. The caller holds the lock.
+func (fc *functionCache) remove(element *list.Element) {
+	fc.order.Remove(element)
+	delete(fc.results, element.Value.(*cachedResult).key)
+}
+
+// clear drops every result of the cache
+func (fc *functionCache) clear() {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	fc.results = make(map[string]*list.Element)
+	fc.order.Init()
+}
+
+// RegisterCachedFunction registers a dynamic function whose results are memoized, for
+// functions that are pure given their arguments and some form state fields, such as lookups of
+// the cities of a state. Concurrent calls with the same key run the function once. Cached
+// results are shared between callers, which must not modify them, and between clones of the
+// service.
+func (dfs *DynamicFunctionService) RegisterCachedFunction(name string, cache FunctionCache, fn DynamicFunction) {
+	cached := newFunctionCache(cache)
+	dfs.functionLock.Lock()
+	defer dfs.functionLock.Unlock()
+	dfs.functions[name] = cached.wrap(fn)
+	dfs.caches[name] = cached
+}
+
+// ClearFunctionCache drops the cached results of a function, e.g. after the data it reads
+// changed. It does nothing for functions registered without a cache.
+func (dfs *DynamicFunctionService) ClearFunctionCache(name string) {
+	dfs.functionLock.RLock()
+	cached, ok := dfs.caches[name]
+	dfs.functionLock.RUnlock()
+	if ok {
+		cached.clear()
+	}
+}
@@ -0,0 +1,137 @@
+package smartform
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCachedFunction(t *testing.T) {
+	var calls int32
+	service := NewDynamicFunctionService()
+	service.RegisterCachedFunction("getCitiesByState", FunctionCache{StateFields: []string{"country"}},
+		func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return []string{args["state"].(string) + " City"}, nil
+		})
+
+	call := func(state, country, other string) interface{} {
+		value, err := service.ExecuteFunction("getCitiesByState",
+			map[string]interface{}{"state": state}, map[string]interface{}{"country": country, "other": other})
+		require.NoError(t, err)
+		return value
+	}
+
+	assert.Equal(t, []string{"Lagos City"}, call("Lagos", "NG", "a"))
+	assert.Equal(t, []string{"Lagos City"}, call("Lagos", "NG", "b"), "fields outside the key are ignored")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	call("Lagos", "GH", "a")
+	call("Oyo", "NG", "a")
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+	service.ClearFunctionCache("getCitiesByState")
+	call("Lagos", "NG", "a")
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+
+	// Registering the function again without a cache drops it
+	service.RegisterFunction("getCitiesByState", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+	call("Lagos", "NG", "a")
+	call("Lagos", "NG", "a")
+	assert.EqualValues(t, 6, atomic.LoadInt32(&calls))
+	assert.NotContains(t, service.caches, "getCitiesByState")
+}
+
+func TestFunctionCacheExpiryAndEviction(t *testing.T) {
+	var calls int32
+	service := NewDynamicFunctionService()
+	service.RegisterCachedFunction("getBrands", FunctionCache{TTL: 30 * time.Millisecond, MaxEntries: 2},
+		func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return args["category"], nil
+		})
+	call := func(category string) {
+		_, err := service.ExecuteFunction("getBrands", map[string]interface{}{"category": category}, nil)
+		require.NoError(t, err)
+	}
+
+	call("shoes")
+	call("bags")
+	call("hats") // Evicts shoes, the least recently used
+	call("bags")
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+	call("shoes") // Evicts hats, since bags was used since
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+	call("bags")
+	assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+	call("hats")
+	assert.EqualValues(t, 5, atomic.LoadInt32(&calls))
+
+	time.Sleep(40 * time.Millisecond)
+	call("bags")
+	assert.EqualValues(t, 6, atomic.LoadInt32(&calls))
+}
+
+func TestFunctionCacheDeduplicatesConcurrentCalls(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	service := NewDynamicFunctionService()
+	service.RegisterCachedFunction("getBrands", FunctionCache{},
+		func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "brands", nil
+		})
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = service.ExecuteFunction("getBrands", nil, nil)
+		}(i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	for _, result := range results {
+		assert.Equal(t, "brands", result)
+	}
+}
+
+func TestFunctionCacheSkipsFailures(t *testing.T) {
+	var calls int32
+	service := NewDynamicFunctionService()
+	service.Use(RecoverFunctionPanics(nil))
+	service.RegisterCachedFunction("flaky", FunctionCache{},
+		func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			switch atomic.AddInt32(&calls, 1) {
+			case 1:
+				return nil, errors.New("upstream down")
+			case 2:
+				panic("upstream broken")
+			}
+			return "ok", nil
+		})
+
+	_, err := service.ExecuteFunction("flaky", nil, nil)
+	assert.Error(t, err)
+	_, err = service.ExecuteFunction("flaky", nil, nil)
+	assert.True(t, errors.Is(err, ErrFunctionPanicked), "%v", err)
+	value, err := service.ExecuteFunction("flaky", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", value)
+	_, _ = service.ExecuteFunction("flaky", nil, nil)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,237 @@
+package smartform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Headers of the idempotency contract of dynamic functions
+const (
+	// IdempotencyKeyHeader carries a client-chosen idempotency token, which replaces the one the
+	// service derives. Responses of functions with side effects echo the token used.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayHeader is set on responses replaying the result of an earlier call
+	IdempotentReplayHeader = "Idempotent-Replayed"
+)
+
+// IdempotencyTokenKey is the reserved form state key under which dynamic functions with side
+// effects receive the idempotency token of the call
+const IdempotencyTokenKey = "_idempotencyToken"
+
+// DefaultIdempotencyTTL is how long the results of calls with side effects are replayed
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ErrIdempotencyTokenRequired is returned when a function with side effects is called without
+// a session or idempotency key to derive its token from
+var ErrIdempotencyTokenRequired = errors.New("function with side effects requires a session or idempotency key")
+
+// SideEffectClass declares what calling a dynamic function does besides returning a result
+type SideEffectClass string
+
+const (
+	// SideEffectNone functions only read, so calls may be repeated freely. Functions are of
+	// this class unless declared otherwise.
+	SideEffectNone SideEffectClass = "none"
+	// SideEffectIdempotent functions change something, but repeating a call changes nothing
+	// more, e.g. setting a flag. They receive an idempotency token but are not deduplicated.
+	SideEffectIdempotent SideEffectClass = "idempotent"
+	// SideEffectMutating functions change something each time they run, e.g. reserving
+	// inventory. They receive an idempotency token to pass to the systems they change, and
+	// retries with the same token replay the result of the first successful call.
+	SideEffectMutating SideEffectClass = "mutating"
+)
+
+// FunctionCall identifies a call of a dynamic function for the idempotency contract. The token
+// of a call is derived from its session, field, and attempt: clients keep the attempt when
+// retrying a call, e.g. after a timeout, and change it when the user changes the input, so
+// retries reuse the token while new attempts get a new one.
+type FunctionCall struct {
+	SessionID string `json:"sessionId,omitempty"`
+	Field     string `json:"field,omitempty"`   // Path of the field the call is made for
+	Attempt   string `json:"attempt,omitempty"` // Chosen by the client, e.g. a counter or a hash of the input
+	Key       string `json:"key,omitempty"`     // Idempotency key chosen by the client, replacing the derived token
+}
+
+// Token returns the idempotency token of the call: its key, or else a token derived from its
+// session, field, and attempt. It is empty when the call has neither a key nor a session.
+func (fc *FunctionCall) Token() string {
+	if fc.Key != "" {
+		return fc.Key
+	}
+	if fc.SessionID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(fc.SessionID + "\x00" + fc.Field + "\x00" + fc.Attempt))
+	return hex.EncodeToString(sum[:16])
+}
+
+// IdempotencyTokenFrom returns the idempotency token injected into the form state of a
+// function with side effects, or "" when the function was called without one
+func IdempotencyTokenFrom(formState map[string]interface{}) string {
+	token, _ := formState[IdempotencyTokenKey].(string)
+	return token
+}
+
+// FunctionResult is the result of a call of a dynamic function under the idempotency contract
+type FunctionResult struct {
+	Value    interface{}
+	Token    string // Idempotency token the call ran with, if any
+	Replayed bool   // Whether the value is that of an earlier call with the same token
+}
+
+// idempotentCall is a call of a mutating function, kept to replay its result
+type idempotentCall struct {
+	done      chan struct{}
+	value     interface{}
+	err       error
+	expiresAt time.Time
+}
+
+// idempotencyStore keeps the results of calls of mutating functions by function and token
+type idempotencyStore struct {
+	calls map[string]*idempotentCall
+	ttl   time.Duration
+	lock  sync.Mutex
+}
+
+// newIdempotencyStore creates an idempotency store replaying results for the default period
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{calls: make(map[string]*idempotentCall), ttl: DefaultIdempotencyTTL}
+}
+
+// do runs fn once per key while its result is kept. Concurrent calls with the same key wait
+// for the running one; failed calls are forgotten so that retries run again, with the same
+// token.
+func (is *idempotencyStore) do(key string, fn func() (interface{}, error)) (interface{}, bool, error) {
+	is.lock.Lock()
+	now := time.Now()
+	for k, call := range is.calls {
+		if !call.expiresAt.IsZero() && now.After(call.expiresAt) {
+			delete(is.calls, k)
+		}
+	}
+	if call, ok := is.calls[key]; ok {
+		is.lock.Unlock()
+		<-call.done
+		if call.err == nil {
+			return call.value, true, nil
+		}
+		return is.do(key, fn)
+	}
+	call := &idempotentCall{done: make(chan struct{})}
+	is.calls[key] = call
+	is.lock.Unlock()
+
+	call.value, call.err = fn()
+
+	is.lock.Lock()
+	if call.err != nil {
+		delete(is.calls, key)
+	} else {
+		call.expiresAt = time.Now().Add(is.ttl)
+	}
+	is.lock.Unlock()
+	close(call.done)
+	return call.value, false, call.err
+}
+
+// RegisterFunctionWithSideEffects registers a dynamic function declaring its side-effect class
+func (dfs *DynamicFunctionService) RegisterFunctionWithSideEffects(name string, class SideEffectClass, fn DynamicFunction) {
+	dfs.RegisterFunction(name, fn)
+	dfs.functionLock.Lock()
+	defer dfs.functionLock.Unlock()
+	dfs.sideEffects[name] = class
+}
+
+// SideEffects returns the side-effect class a function was registered with
+func (dfs *DynamicFunctionService) SideEffects(name string) SideEffectClass {
+	dfs.functionLock.RLock()
+	defer dfs.functionLock.RUnlock()
+	if class, ok := dfs.sideEffects[name]; ok {
+		return class
+	}
+	return SideEffectNone
+}
+
+// SetIdempotencyTTL sets how long the results of calls of mutating functions are replayed
+func (dfs *DynamicFunctionService) SetIdempotencyTTL(ttl time.Duration) {
+	dfs.idempotency.lock.Lock()
+	defer dfs.idempotency.lock.Unlock()
+	dfs.idempotency.ttl = ttl
+}
+
+// ExecuteFunctionCall executes a dynamic function under the idempotency contract. Functions
+// with side effects receive the token of the call under IdempotencyTokenKey, and fail with
+// ErrIdempotencyTokenRequired when the call has none. Calls of mutating functions run once
+// per token: retries replay the result of the first successful call instead of running the
+// function again.
+func (dfs *DynamicFunctionService) ExecuteFunctionCall(
+	functionName string,
+	call *FunctionCall,
+	args map[string]interface{},
+	formState map[string]interface{},
+) (*FunctionResult, error) {
+	class := dfs.SideEffects(functionName)
+	if class == SideEffectNone {
+		value, err := dfs.ExecuteFunction(functionName, args, formState)
+		if err != nil {
+			return nil, err
+		}
+		return &FunctionResult{Value: value}, nil
+	}
+
+	token := ""
+	if call != nil {
+		token = call.Token()
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%s: %w", functionName, ErrIdempotencyTokenRequired)
+	}
+	state := make(map[string]interface{}, len(formState)+1)
+	for key, value := range formState {
+		state[key] = value
+	}
+	state[IdempotencyTokenKey] = token
+
+	if class != SideEffectMutating {
+		value, err := dfs.ExecuteFunction(functionName, args, state)
+		if err != nil {
+			return nil, err
+		}
+		return &FunctionResult{Value: value, Token: token}, nil
+	}
+
+	value, replayed, err := dfs.idempotency.do(functionName+"\x00"+token, func() (interface{}, error) {
+		return dfs.ExecuteFunction(functionName, args, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FunctionResult{Value: value, Token: token, Replayed: replayed}, nil
+}
+
+// functionCall identifies the call of a dynamic function a request makes
+func functionCall(r *http.Request, bodySessionID, field, attempt string) *FunctionCall {
+	return &FunctionCall{
+		SessionID: requestSessionID(r, bodySessionID),
+		Field:     field,
+		Attempt:   attempt,
+		Key:       r.Header.Get(IdempotencyKeyHeader),
+	}
+}
+
+// writeFunctionResultHeaders echoes the idempotency token a function ran with, and marks
+// replayed results
+func writeFunctionResultHeaders(w http.ResponseWriter, result *FunctionResult) {
+	if result.Token != "" {
+		w.Header().Set(IdempotencyKeyHeader, result.Token)
+	}
+	if result.Replayed {
+		w.Header().Set(IdempotentReplayHeader, "true")
+	}
+}
@@ -0,0 +1,110 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReservationService registers a function reserving stock, which fails the first time it
+// sees a token when flaky is set, as if the response had been lost
+func newReservationService(reservations map[string]int, flaky bool) *DynamicFunctionService {
+	seen := map[string]bool{}
+	service := NewDynamicFunctionService()
+	service.RegisterFunctionWithSideEffects("reserve", SideEffectMutating, func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		token := IdempotencyTokenFrom(formState)
+		reservations[token]++
+		if flaky && !seen[token] {
+			seen[token] = true
+			return nil, errors.New("timeout")
+		}
+		return fmt.Sprintf("reservation-%d", len(reservations)), nil
+	})
+	return service
+}
+
+func TestExecuteFunctionCall(t *testing.T) {
+	reservations := map[string]int{}
+	service := newReservationService(reservations, false)
+	assert.Equal(t, SideEffectMutating, service.SideEffects("reserve"))
+	assert.Equal(t, SideEffectNone, service.SideEffects("missing"))
+
+	call := &FunctionCall{SessionID: "s1", Field: "items[0].sku", Attempt: "1"}
+	first, err := service.ExecuteFunctionCall("reserve", call, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, first.Replayed)
+	assert.Equal(t, call.Token(), first.Token)
+
+	// Retries of the attempt replay the result without reserving again
+	retry, err := service.ExecuteFunctionCall("reserve", &FunctionCall{SessionID: "s1", Field: "items[0].sku", Attempt: "1"}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, retry.Replayed)
+	assert.Equal(t, first.Value, retry.Value)
+	assert.Equal(t, map[string]int{call.Token(): 1}, reservations)
+
+	// A new attempt, or another field, reserves again
+	next, err := service.ExecuteFunctionCall("reserve", &FunctionCall{SessionID: "s1", Field: "items[0].sku", Attempt: "2"}, nil, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Token, next.Token)
+	assert.Len(t, reservations, 2)
+
+	_, err = service.ExecuteFunctionCall("reserve", nil, nil, nil)
+	assert.True(t, errors.Is(err, ErrIdempotencyTokenRequired))
+}
+
+func TestExecuteFunctionCallRetriesFailuresWithSameToken(t *testing.T) {
+	reservations := map[string]int{}
+	service := newReservationService(reservations, true)
+	call := &FunctionCall{Key: "checkout-42"}
+
+	_, err := service.ExecuteFunctionCall("reserve", call, nil, nil)
+	assert.Error(t, err)
+	result, err := service.ExecuteFunctionCall("reserve", call, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, result.Replayed)
+	assert.Equal(t, map[string]int{"checkout-42": 2}, reservations)
+}
+
+func TestFunctionEndpointIdempotency(t *testing.T) {
+	reservations := map[string]int{}
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(newReservationService(reservations, false))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	call := func(session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/function/reserve",
+			strings.NewReader(`{"arguments": {"sku": "A1"}, "field": "sku", "attempt": "7"}`))
+		if session != "" {
+			req.Header.Set(SessionHeader, session)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("s1")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	token := rec.Header().Get(IdempotencyKeyHeader)
+	assert.Equal(t, (&FunctionCall{SessionID: "s1", Field: "sku", Attempt: "7"}).Token(), token)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayHeader))
+
+	rec = call("s1")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayHeader))
+	var value string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &value))
+	assert.Equal(t, "reservation-1", value)
+	assert.Equal(t, 1, reservations[token])
+
+	rec = call("")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Len(t, reservations, 1)
+}
@@ -0,0 +1,197 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// FunctionNameKey is the form state key under which interceptors find the name of the function
+// being called. It is removed before the function itself runs.
+const FunctionNameKey = "_function"
+
+// FunctionCallMessage is the message of logged function calls
+const FunctionCallMessage = "function executed"
+
+// Errors of intercepted function calls
+var (
+	ErrFunctionTimeout   = errors.New("function timed out")
+	ErrFunctionForbidden = errors.New("function not permitted")
+	ErrFunctionPanicked  = errors.New("function panicked")
+)
+
+// FunctionInterceptor wraps the execution of dynamic functions with cross-cutting behavior, such
+// as logging, caching, or permission checks. It returns a function calling next, or answering
+// in its place.
+type FunctionInterceptor func(next DynamicFunction) DynamicFunction
+
+// Use adds interceptors to every function call. Interceptors run in the order they were added,
+// the first one outermost; the name of the function being called is in the form state, see
+// FunctionName.
+func (dfs *DynamicFunctionService) Use(interceptors ...FunctionInterceptor) {
+	dfs.functionLock.Lock()
+	defer dfs.functionLock.Unlock()
+	dfs.interceptors = append(dfs.interceptors, interceptors...)
+}
+
+// SetFunctionTimeout bounds how long calls of a function may run; zero removes the bound. Calls
+// running longer fail with ErrFunctionTimeout. Functions cannot be stopped, so the function
+// keeps running in the background and its result is discarded.
+func (dfs *DynamicFunctionService) SetFunctionTimeout(name string, timeout time.Duration) {
+	dfs.functionLock.Lock()
+	defer dfs.functionLock.Unlock()
+	if timeout <= 0 {
+		delete(dfs.timeouts, name)
+		return
+	}
+	dfs.timeouts[name] = timeout
+}
+
+// SetDefaultFunctionTimeout bounds how long calls of functions without a timeout of their own
+// may run; zero removes the bound
+func (dfs *DynamicFunctionService) SetDefaultFunctionTimeout(timeout time.Duration) {
+	dfs.functionLock.Lock()
+	defer dfs.functionLock.Unlock()
+	dfs.defaultTimeout = max(timeout, 0)
+}
+
+// isFunctionCallError reports whether an error is about the call of a function rather than
+// from the function itself, so that it is answered with its own status
+func isFunctionCallError(err error) bool {
+	return errors.Is(err, ErrIdempotencyTokenRequired) || errors.Is(err, ErrFunctionForbidden) ||
+		errors.Is(err, ErrFunctionTimeout)
+}
+
+// FunctionName returns the name of the function being called, for interceptors
+func FunctionName(formState map[string]interface{}) string {
+	name, _ := formState[FunctionNameKey].(string)
+	return name
+}
+
+// intercept wraps a function in the interceptors and timeout of the service. The caller holds
+// the function lock.
+func (dfs *DynamicFunctionService) intercept(name string, fn DynamicFunction) DynamicFunction {
+	timeout, ok := dfs.timeouts[name]
+	if !ok {
+		timeout = dfs.defaultTimeout
+	}
+	if len(dfs.interceptors) == 0 && timeout <= 0 {
+		return fn
+	}
+
+	// The function runs without the name interceptors were given
+	wrapped := func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		state := make(map[string]interface{}, len(formState))
+		for key, value := range formState {
+			if key != FunctionNameKey {
+				state[key] = value
+			}
+		}
+		if timeout > 0 {
+			return callWithTimeout(name, timeout, fn, args, state)
+		}
+		return fn(args, state)
+	}
+	for i := len(dfs.interceptors) - 1; i >= 0; i-- {
+		wrapped = dfs.interceptors[i](wrapped)
+	}
+
+	return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		state := make(map[string]interface{}, len(formState)+1)
+		for key, value := range formState {
+			state[key] = value
+		}
+		state[FunctionNameKey] = name
+		return wrapped(args, state)
+	}
+}
+
+// callWithTimeout calls a function, giving up on it after timeout. A panic of the function is
+// raised again in the caller, where interceptors can recover it.
+func callWithTimeout(name string, timeout time.Duration, fn DynamicFunction, args, formState map[string]interface{}) (interface{}, error) {
+	type outcome struct {
+		value    interface{}
+		err      error
+		panicked bool
+		panicVal interface{}
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- outcome{panicked: true, panicVal: recovered}
+			}
+		}()
+		value, err := fn(args, formState)
+		done <- outcome{value: value, err: err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		if result.panicked {
+			panic(result.panicVal)
+		}
+		return result.value, result.err
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: %w after %s", name, ErrFunctionTimeout, timeout)
+	}
+}
+
+// RecoverFunctionPanics turns panics of functions into ErrFunctionPanicked errors, logged with
+// their stack to logger if it is not nil
+func RecoverFunctionPanics(logger Logger) FunctionInterceptor {
+	return func(next DynamicFunction) DynamicFunction {
+		return func(args map[string]interface{}, formState map[string]interface{}) (value interface{}, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					name := FunctionName(formState)
+					if logger != nil {
+						logger.Log(context.Background(), slog.LevelError, "function panicked",
+							"function", name, "panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+					}
+					value, err = nil, fmt.Errorf("%s: %w: %v", name, ErrFunctionPanicked, recovered)
+				}
+			}()
+			return next(args, formState)
+		}
+	}
+}
+
+// LogFunctionCalls logs every function call with its duration and error, if any. Arguments and
+// results are never logged, since they may hold what users entered.
+func LogFunctionCalls(logger Logger) FunctionInterceptor {
+	return func(next DynamicFunction) DynamicFunction {
+		return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			start := time.Now()
+			value, err := next(args, formState)
+			level := slog.LevelInfo
+			attrs := []any{"function", FunctionName(formState), "duration", time.Since(start)}
+			if err != nil {
+				level = slog.LevelWarn
+				attrs = append(attrs, "error", err.Error())
+			}
+			logger.Log(context.Background(), level, FunctionCallMessage, attrs...)
+			return value, err
+		}
+	}
+}
+
+// RequireFunctionPermission fails the calls allowed does not permit with ErrFunctionForbidden.
+// allowed is given the name of the function and the form state, which carries the variables
+// set for the request, such as the caller's identity.
+func RequireFunctionPermission(allowed func(name string, formState map[string]interface{}) bool) FunctionInterceptor {
+	return func(next DynamicFunction) DynamicFunction {
+		return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+			name := FunctionName(formState)
+			if !allowed(name, formState) {
+				return nil, fmt.Errorf("%s: %w", name, ErrFunctionForbidden)
+			}
+			return next(args, formState)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package smartform
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionInterceptors(t *testing.T) {
+	service := NewDynamicFunctionService()
+	var seen map[string]interface{}
+	service.RegisterFunction("echo", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		seen = formState
+		return args["value"], nil
+	})
+
+	var order []string
+	trace := func(label string) FunctionInterceptor {
+		return func(next DynamicFunction) DynamicFunction {
+			return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+				order = append(order, label+":"+FunctionName(formState))
+				return next(args, formState)
+			}
+		}
+	}
+	service.Use(trace("outer"), trace("inner"))
+
+	state := map[string]interface{}{"country": "NG"}
+	value, err := service.ExecuteFunction("echo", map[string]interface{}{"value": 42}, state)
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+	assert.Equal(t, []string{"outer:echo", "inner:echo"}, order)
+
+	// The function sees the form state without the name, and the caller's state is left alone
+	assert.Equal(t, map[string]interface{}{"country": "NG"}, seen)
+	assert.Equal(t, map[string]interface{}{"country": "NG"}, state)
+
+	// Clones keep the interceptors
+	order = nil
+	_, err = service.Clone().ExecuteFunction("echo", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer:echo", "inner:echo"}, order)
+}
+
+func TestFunctionTimeouts(t *testing.T) {
+	service := NewDynamicFunctionService()
+	release := make(chan struct{})
+	defer close(release)
+	service.RegisterFunction("slow", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		<-release
+		return "late", nil
+	})
+	service.RegisterFunction("quick", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return "done", nil
+	})
+	service.SetDefaultFunctionTimeout(time.Hour)
+	service.SetFunctionTimeout("slow", 20*time.Millisecond)
+
+	_, err := service.ExecuteFunction("slow", nil, nil)
+	assert.True(t, errors.Is(err, ErrFunctionTimeout), "%v", err)
+	value, err := service.ExecuteFunction("quick", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "done", value)
+
+	// Removing the timeout falls back to the default
+	service.SetFunctionTimeout("slow", 0)
+	service.SetDefaultFunctionTimeout(10 * time.Millisecond)
+	_, err = service.ExecuteFunction("slow", nil, nil)
+	assert.True(t, errors.Is(err, ErrFunctionTimeout), "%v", err)
+}
+
+func TestRecoverFunctionPanics(t *testing.T) {
+	var logs bytes.Buffer
+	service := NewDynamicFunctionService()
+	service.Use(RecoverFunctionPanics(slog.New(slog.NewTextHandler(&logs, nil))))
+	service.RegisterFunction("broken", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		panic("nil map")
+	})
+
+	_, err := service.ExecuteFunction("broken", nil, nil)
+	assert.True(t, errors.Is(err, ErrFunctionPanicked), "%v", err)
+	assert.Contains(t, logs.String(), "function=broken")
+
+	// Panics under a timeout are raised again in the caller, where they are recovered
+	service.SetFunctionTimeout("broken", time.Second)
+	_, err = service.ExecuteFunction("broken", nil, nil)
+	assert.True(t, errors.Is(err, ErrFunctionPanicked), "%v", err)
+}
+
+func TestLogFunctionCalls(t *testing.T) {
+	var logs bytes.Buffer
+	service := NewDynamicFunctionService()
+	service.Use(LogFunctionCalls(slog.New(slog.NewTextHandler(&logs, nil))))
+	service.RegisterFunction("lookup", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("not found")
+	})
+
+	_, err := service.ExecuteFunction("lookup", map[string]interface{}{"secret": "hunter2"}, nil)
+	assert.Error(t, err)
+	assert.Contains(t, logs.String(), "level=WARN")
+	assert.Contains(t, logs.String(), "function=lookup")
+	assert.Contains(t, logs.String(), `error="not found"`)
+	assert.NotContains(t, logs.String(), "hunter2")
+}
+
+func TestFunctionEndpointInterceptorErrors(t *testing.T) {
+	service := NewDynamicFunctionService()
+	service.RegisterFunction("payroll", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	service.RegisterFunction("slow", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return "late", nil
+	})
+	service.Use(RequireFunctionPermission(func(name string, formState map[string]interface{}) bool {
+		return name != "payroll" || formState["role"] == "admin"
+	}))
+	service.SetFunctionTimeout("slow", 10*time.Millisecond)
+
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(service)
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	call := func(name, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/function/"+name, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := call("payroll", `{"formState": {"role": "clerk"}}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = call("payroll", `{"formState": {"role": "admin"}}`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = call("slow", `{}`)
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code, rec.Body.String())
+}
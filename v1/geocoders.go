@@ -0,0 +1,385 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Base URLs of the geocoding providers
+const (
+	GooglePlacesBaseURL = "https://maps.googleapis.com/maps/api/place"
+	MapboxBaseURL       = "https://api.mapbox.com/geocoding/v5/mapbox.places"
+	NominatimBaseURL    = "https://nominatim.openstreetmap.org"
+)
+
+// NominatimRateLimit is the rate the usage policy of the public Nominatim service allows
+var NominatimRateLimit = RateLimit{Requests: 1, Per: time.Second}
+
+// geocoderClient returns the HTTP client of a geocoder, defaulting to one with a 10s timeout
+func geocoderClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return client
+}
+
+// getGeocoderJSON sends a GET request to a geocoding provider and decodes its JSON response
+func getGeocoderJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// addressComponentsOf returns the non-empty address components of a suggestion
+func addressComponentsOf(components map[string]string) map[string]interface{} {
+	address := map[string]interface{}{}
+	for key, value := range components {
+		if value != "" {
+			address[key] = value
+		}
+	}
+	return address
+}
+
+// joinStreet joins a house number and a street name, e.g. "10 Downing Street"
+func joinStreet(number, street string) string {
+	return strings.TrimSpace(number + " " + street)
+}
+
+// GooglePlacesConfig configures a geocoder backed by the Google Places API
+type GooglePlacesConfig struct {
+	APIKey  string       // Key of the Places API
+	BaseURL string       // Base URL of the API (default GooglePlacesBaseURL)
+	Client  *http.Client // HTTP client (default: one with a 10s timeout)
+}
+
+// GooglePlacesGeocoder suggests addresses with Place Autocomplete. Its suggestions carry only
+// a label and a place ID; Resolve fetches the address components of the picked place, and
+// passing the session token of the suggestions bills them as one session.
+type GooglePlacesGeocoder struct {
+	config GooglePlacesConfig
+}
+
+// NewGooglePlacesGeocoder creates a geocoder backed by the Google Places API
+func NewGooglePlacesGeocoder(config GooglePlacesConfig) *GooglePlacesGeocoder {
+	if config.BaseURL == "" {
+		config.BaseURL = GooglePlacesBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.Client = geocoderClient(config.Client)
+	return &GooglePlacesGeocoder{config: config}
+}
+
+// googleStatus is the status of a response of the Places API
+type googleStatus struct {
+	Status       string `json:"status"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// err returns the error a status reports, if any
+func (gs googleStatus) err() error {
+	if gs.Status == "OK" || gs.Status == "ZERO_RESULTS" {
+		return nil
+	}
+	if gs.ErrorMessage != "" {
+		return fmt.Errorf("places API returned %s: %s", gs.Status, gs.ErrorMessage)
+	}
+	return fmt.Errorf("places API returned %s", gs.Status)
+}
+
+// Suggest implements Geocoder
+func (gg *GooglePlacesGeocoder) Suggest(ctx context.Context, query *AddressQuery) ([]*AddressSuggestion, error) {
+	params := url.Values{}
+	params.Set("input", query.Text)
+	params.Set("types", "address")
+	params.Set("key", gg.config.APIKey)
+	if query.Country != "" {
+		params.Set("components", "country:"+strings.ToLower(query.Country))
+	}
+	if query.Language != "" {
+		params.Set("language", query.Language)
+	}
+	if query.SessionToken != "" {
+		params.Set("sessiontoken", query.SessionToken)
+	}
+
+	var response struct {
+		googleStatus
+		Predictions []struct {
+			PlaceID     string `json:"place_id"`
+			Description string `json:"description"`
+		} `json:"predictions"`
+	}
+	if err := getGeocoderJSON(ctx, gg.config.Client, gg.config.BaseURL+"/autocomplete/json?"+params.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	if err := response.err(); err != nil {
+		return nil, err
+	}
+
+	suggestions := []*AddressSuggestion{}
+	for _, prediction := range response.Predictions {
+		suggestions = append(suggestions, &AddressSuggestion{ID: prediction.PlaceID, Label: prediction.Description})
+	}
+	return suggestions, nil
+}
+
+// Resolve implements AddressResolver
+func (gg *GooglePlacesGeocoder) Resolve(ctx context.Context, id, sessionToken string) (*AddressSuggestion, error) {
+	params := url.Values{}
+	params.Set("place_id", id)
+	params.Set("fields", "address_component,formatted_address,geometry")
+	params.Set("key", gg.config.APIKey)
+	if sessionToken != "" {
+		params.Set("sessiontoken", sessionToken)
+	}
+
+	var response struct {
+		googleStatus
+		Result struct {
+			FormattedAddress  string `json:"formatted_address"`
+			AddressComponents []struct {
+				LongName  string   `json:"long_name"`
+				ShortName string   `json:"short_name"`
+				Types     []string `json:"types"`
+			} `json:"address_components"`
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"result"`
+	}
+	if err := getGeocoderJSON(ctx, gg.config.Client, gg.config.BaseURL+"/details/json?"+params.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	if err := response.err(); err != nil {
+		return nil, err
+	}
+
+	parts := map[string]string{}
+	for _, component := range response.Result.AddressComponents {
+		for _, kind := range component.Types {
+			if _, ok := parts[kind]; ok {
+				continue
+			}
+			parts[kind] = component.LongName
+			// States and countries are kept as their codes, e.g. "CA" and "US"
+			if kind == "administrative_area_level_1" || kind == "country" {
+				parts[kind] = component.ShortName
+			}
+		}
+	}
+	city := parts["locality"]
+	if city == "" {
+		city = parts["postal_town"]
+	}
+	return &AddressSuggestion{
+		ID:    id,
+		Label: response.Result.FormattedAddress,
+		Address: addressComponentsOf(map[string]string{
+			AddressStreet:  joinStreet(parts["street_number"], parts["route"]),
+			AddressCity:    city,
+			AddressState:   parts["administrative_area_level_1"],
+			AddressZip:     parts["postal_code"],
+			AddressCountry: parts["country"],
+		}),
+		Latitude:  response.Result.Geometry.Location.Lat,
+		Longitude: response.Result.Geometry.Location.Lng,
+	}, nil
+}
+
+// MapboxConfig configures a geocoder backed by the Mapbox Geocoding API
+type MapboxConfig struct {
+	AccessToken string       // Access token of the Mapbox account
+	BaseURL     string       // Base URL of the API (default MapboxBaseURL)
+	Client      *http.Client // HTTP client (default: one with a 10s timeout)
+}
+
+// MapboxGeocoder suggests addresses with the Mapbox Geocoding API in autocomplete mode
+type MapboxGeocoder struct {
+	config MapboxConfig
+}
+
+// NewMapboxGeocoder creates a geocoder backed by the Mapbox Geocoding API
+func NewMapboxGeocoder(config MapboxConfig) *MapboxGeocoder {
+	if config.BaseURL == "" {
+		config.BaseURL = MapboxBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.Client = geocoderClient(config.Client)
+	return &MapboxGeocoder{config: config}
+}
+
+// Suggest implements Geocoder
+func (mg *MapboxGeocoder) Suggest(ctx context.Context, query *AddressQuery) ([]*AddressSuggestion, error) {
+	params := url.Values{}
+	params.Set("access_token", mg.config.AccessToken)
+	params.Set("autocomplete", "true")
+	params.Set("types", "address")
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Country != "" {
+		params.Set("country", strings.ToLower(query.Country))
+	}
+	if query.Language != "" {
+		params.Set("language", query.Language)
+	}
+
+	var response struct {
+		Features []struct {
+			ID        string    `json:"id"`
+			PlaceName string    `json:"place_name"`
+			Text      string    `json:"text"`    // Street name
+			Address   string    `json:"address"` // House number
+			Center    []float64 `json:"center"`  // Longitude and latitude
+			Context   []struct {
+				ID        string `json:"id"` // Kind of the context and its ID, e.g. "postcode.123"
+				Text      string `json:"text"`
+				ShortCode string `json:"short_code"`
+			} `json:"context"`
+		} `json:"features"`
+	}
+	endpoint := mg.config.BaseURL + "/" + url.PathEscape(query.Text) + ".json?" + params.Encode()
+	if err := getGeocoderJSON(ctx, mg.config.Client, endpoint, nil, &response); err != nil {
+		return nil, err
+	}
+
+	suggestions := []*AddressSuggestion{}
+	for _, feature := range response.Features {
+		parts := map[string]string{AddressStreet: joinStreet(feature.Address, feature.Text)}
+		for _, entry := range feature.Context {
+			kind, _, _ := strings.Cut(entry.ID, ".")
+			switch kind {
+			case "place":
+				parts[AddressCity] = entry.Text
+			case "postcode":
+				parts[AddressZip] = entry.Text
+			case "region":
+				// Region codes are prefixed with the country, e.g. "US-CA"
+				parts[AddressState] = entry.Text
+				if _, code, ok := strings.Cut(entry.ShortCode, "-"); ok {
+					parts[AddressState] = code
+				}
+			case "country":
+				parts[AddressCountry] = strings.ToUpper(entry.ShortCode)
+			}
+		}
+		suggestion := &AddressSuggestion{ID: feature.ID, Label: feature.PlaceName, Address: addressComponentsOf(parts)}
+		if len(feature.Center) == 2 {
+			suggestion.Longitude, suggestion.Latitude = feature.Center[0], feature.Center[1]
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
+
+// NominatimConfig configures a geocoder backed by Nominatim, the OpenStreetMap geocoder
+type NominatimConfig struct {
+	BaseURL   string       // Base URL of the service (default NominatimBaseURL)
+	UserAgent string       // Identifies the application, as the usage policy of the public service requires
+	Email     string       // Contact address sent with requests, for services that ask for one
+	Client    *http.Client // HTTP client (default: one with a 10s timeout)
+}
+
+// NominatimGeocoder suggests addresses with the search API of Nominatim. The public service
+// allows one request per second; register it with NominatimRateLimit.
+type NominatimGeocoder struct {
+	config NominatimConfig
+}
+
+// NewNominatimGeocoder creates a geocoder backed by Nominatim
+func NewNominatimGeocoder(config NominatimConfig) *NominatimGeocoder {
+	if config.BaseURL == "" {
+		config.BaseURL = NominatimBaseURL
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.Client = geocoderClient(config.Client)
+	return &NominatimGeocoder{config: config}
+}
+
+// Suggest implements Geocoder
+func (ng *NominatimGeocoder) Suggest(ctx context.Context, query *AddressQuery) ([]*AddressSuggestion, error) {
+	params := url.Values{}
+	params.Set("q", query.Text)
+	params.Set("format", "jsonv2")
+	params.Set("addressdetails", "1")
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Country != "" {
+		params.Set("countrycodes", strings.ToLower(query.Country))
+	}
+	if query.Language != "" {
+		params.Set("accept-language", query.Language)
+	}
+	if ng.config.Email != "" {
+		params.Set("email", ng.config.Email)
+	}
+	header := http.Header{}
+	if ng.config.UserAgent != "" {
+		header.Set("User-Agent", ng.config.UserAgent)
+	}
+
+	var response []struct {
+		PlaceID     json.Number       `json:"place_id"`
+		DisplayName string            `json:"display_name"`
+		Lat         string            `json:"lat"`
+		Lon         string            `json:"lon"`
+		Address     map[string]string `json:"address"`
+	}
+	if err := getGeocoderJSON(ctx, ng.config.Client, ng.config.BaseURL+"/search?"+params.Encode(), header, &response); err != nil {
+		return nil, err
+	}
+
+	suggestions := []*AddressSuggestion{}
+	for _, place := range response {
+		address := place.Address
+		city := address["city"]
+		for _, alternative := range []string{"town", "village", "hamlet"} {
+			if city == "" {
+				city = address[alternative]
+			}
+		}
+		suggestion := &AddressSuggestion{
+			ID:    place.PlaceID.String(),
+			Label: place.DisplayName,
+			Address: addressComponentsOf(map[string]string{
+				AddressStreet:  joinStreet(address["house_number"], address["road"]),
+				AddressCity:    city,
+				AddressState:   address["state"],
+				AddressZip:     address["postcode"],
+				AddressCountry: strings.ToUpper(address["country_code"]),
+			}),
+		}
+		suggestion.Latitude, _ = strconv.ParseFloat(place.Lat, 64)
+		suggestion.Longitude, _ = strconv.ParseFloat(place.Lon, 64)
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,110 @@
+package smartform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// geocoderServer serves a canned response, recording the request it was sent
+func geocoderServer(t *testing.T, body string, request **http.Request) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*request = r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGooglePlacesGeocoder(t *testing.T) {
+	var request *http.Request
+	server := geocoderServer(t, `{"status": "OK", "predictions": [
+		{"place_id": "p1", "description": "1600 Amphitheatre Pkwy, Mountain View, CA, USA"}]}`, &request)
+	geocoder := NewGooglePlacesGeocoder(GooglePlacesConfig{APIKey: "key", BaseURL: server.URL})
+
+	suggestions, err := geocoder.Suggest(context.Background(), &AddressQuery{Text: "1600 Amph", Country: "US", SessionToken: "s1"})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, &AddressSuggestion{ID: "p1", Label: "1600 Amphitheatre Pkwy, Mountain View, CA, USA"}, suggestions[0])
+	assert.Equal(t, "/autocomplete/json", request.URL.Path)
+	assert.Equal(t, "country:us", request.URL.Query().Get("components"))
+	assert.Equal(t, "s1", request.URL.Query().Get("sessiontoken"))
+
+	server = geocoderServer(t, `{"status": "OK", "result": {
+		"formatted_address": "1600 Amphitheatre Pkwy, Mountain View, CA 94043, USA",
+		"address_components": [
+			{"long_name": "1600", "short_name": "1600", "types": ["street_number"]},
+			{"long_name": "Amphitheatre Parkway", "short_name": "Amphitheatre Pkwy", "types": ["route"]},
+			{"long_name": "Mountain View", "short_name": "Mountain View", "types": ["locality", "political"]},
+			{"long_name": "California", "short_name": "CA", "types": ["administrative_area_level_1", "political"]},
+			{"long_name": "United States", "short_name": "US", "types": ["country", "political"]},
+			{"long_name": "94043", "short_name": "94043", "types": ["postal_code"]}],
+		"geometry": {"location": {"lat": 37.42, "lng": -122.08}}}}`, &request)
+	geocoder = NewGooglePlacesGeocoder(GooglePlacesConfig{APIKey: "key", BaseURL: server.URL})
+
+	resolved, err := geocoder.Resolve(context.Background(), "p1", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		AddressStreet:  "1600 Amphitheatre Parkway",
+		AddressCity:    "Mountain View",
+		AddressState:   "CA",
+		AddressZip:     "94043",
+		AddressCountry: "US",
+	}, resolved.Address)
+	assert.Equal(t, 37.42, resolved.Latitude)
+
+	server = geocoderServer(t, `{"status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`, &request)
+	geocoder = NewGooglePlacesGeocoder(GooglePlacesConfig{BaseURL: server.URL})
+	_, err = geocoder.Suggest(context.Background(), &AddressQuery{Text: "1600 Amph"})
+	assert.EqualError(t, err, "places API returned REQUEST_DENIED: The provided API key is invalid.")
+}
+
+func TestMapboxGeocoder(t *testing.T) {
+	var request *http.Request
+	server := geocoderServer(t, `{"features": [{
+		"id": "address.1", "place_name": "10 Downing Street, London, SW1A 2AA, United Kingdom",
+		"text": "Downing Street", "address": "10", "center": [-0.1276, 51.5034],
+		"context": [
+			{"id": "postcode.1", "text": "SW1A 2AA"},
+			{"id": "place.2", "text": "London"},
+			{"id": "region.3", "text": "England", "short_code": "GB-ENG"},
+			{"id": "country.4", "text": "United Kingdom", "short_code": "gb"}]}]}`, &request)
+	geocoder := NewMapboxGeocoder(MapboxConfig{AccessToken: "token", BaseURL: server.URL})
+
+	suggestions, err := geocoder.Suggest(context.Background(), &AddressQuery{Text: "10 Downing", Limit: 5})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, map[string]interface{}{
+		AddressStreet:  "10 Downing Street",
+		AddressCity:    "London",
+		AddressState:   "ENG",
+		AddressZip:     "SW1A 2AA",
+		AddressCountry: "GB",
+	}, suggestions[0].Address)
+	assert.Equal(t, 51.5034, suggestions[0].Latitude)
+	assert.Equal(t, "/10 Downing.json", request.URL.Path)
+	assert.Equal(t, "true", request.URL.Query().Get("autocomplete"))
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	var request *http.Request
+	server := geocoderServer(t, `[{"place_id": 42, "display_name": "Pariser Platz 1, Berlin, Germany",
+		"lat": "52.5163", "lon": "13.3777", "address": {"house_number": "1", "road": "Pariser Platz",
+		"city": "Berlin", "state": "Berlin", "postcode": "10117", "country_code": "de"}}]`, &request)
+	geocoder := NewNominatimGeocoder(NominatimConfig{BaseURL: server.URL, UserAgent: "forms-test/1.0"})
+
+	suggestions, err := geocoder.Suggest(context.Background(), &AddressQuery{Text: "Pariser Platz", Country: "DE"})
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "42", suggestions[0].ID)
+	assert.Equal(t, "1 Pariser Platz", suggestions[0].Address[AddressStreet])
+	assert.Equal(t, "DE", suggestions[0].Address[AddressCountry])
+	assert.Equal(t, 13.3777, suggestions[0].Longitude)
+	assert.Equal(t, "forms-test/1.0", request.Header.Get("User-Agent"))
+	assert.Equal(t, "de", request.URL.Query().Get("countrycodes"))
+}
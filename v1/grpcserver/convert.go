@@ -0,0 +1,207 @@
+package grpcserver
+
+import (
+	"fmt"
+
+	smartform "github.com/juicycleff/smartform/v1"
+	"github.com/juicycleff/smartform/v1/grpcserver/smartformpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// formSchema converts a rendered form to its message, keeping the complete form as its
+// definition
+func formSchema(rendered map[string]interface{}) (*smartformpb.FormSchema, error) {
+	definition, err := protoStruct(rendered)
+	if err != nil {
+		return nil, err
+	}
+	form := &smartformpb.FormSchema{
+		Id:          stringOf(rendered, "id"),
+		Title:       stringOf(rendered, "title"),
+		Description: stringOf(rendered, "description"),
+		Version:     stringOf(rendered, "version"),
+		Type:        stringOf(rendered, "type"),
+		Tags:        stringsOf(rendered, "tags"),
+		Category:    stringOf(rendered, "category"),
+		Owner:       stringOf(rendered, "owner"),
+		Definition:  definition,
+	}
+	if form.Properties, err = protoStruct(mapOf(rendered, "properties")); err != nil {
+		return nil, err
+	}
+	if form.Metadata, err = protoStruct(mapOf(rendered, "metadata")); err != nil {
+		return nil, err
+	}
+	if form.Fields, err = fields(rendered["fields"]); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+// fields converts the rendered fields of a form or group to messages
+func fields(raw interface{}) ([]*smartformpb.Field, error) {
+	list, _ := raw.([]interface{})
+	converted := make([]*smartformpb.Field, 0, len(list))
+	for _, item := range list {
+		rendered, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		order, _ := rendered["order"].(float64)
+		required, _ := rendered["required"].(bool)
+		field := &smartformpb.Field{
+			Id:          stringOf(rendered, "id"),
+			Type:        stringOf(rendered, "type"),
+			Label:       stringOf(rendered, "label"),
+			Required:    required,
+			Placeholder: stringOf(rendered, "placeholder"),
+			HelpText:    stringOf(rendered, "helpText"),
+			Order:       int32(order),
+		}
+		var err error
+		if value, ok := rendered["defaultValue"]; ok {
+			if field.DefaultValue, err = protoValue(value); err != nil {
+				return nil, err
+			}
+		}
+		if field.Properties, err = protoStruct(mapOf(rendered, "properties")); err != nil {
+			return nil, err
+		}
+		if field.Options, err = options(mapOf(rendered, "options")); err != nil {
+			return nil, err
+		}
+		if field.Nested, err = fields(rendered["nested"]); err != nil {
+			return nil, err
+		}
+		converted = append(converted, field)
+	}
+	return converted, nil
+}
+
+// options converts the static options of a rendered field to messages
+func options(config map[string]interface{}) ([]*smartformpb.Option, error) {
+	list, _ := config["static"].([]interface{})
+	converted := make([]*smartformpb.Option, 0, len(list))
+	for _, item := range list {
+		rendered, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, err := protoValue(rendered["value"])
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, &smartformpb.Option{
+			Value: value,
+			Label: stringOf(rendered, "label"),
+			Icon:  stringOf(rendered, "icon"),
+		})
+	}
+	return converted, nil
+}
+
+// formSummary converts a form of the form list to its message
+func formSummary(form *smartform.FormListItem) (*smartformpb.FormSummary, error) {
+	metadata, err := protoStruct(form.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &smartformpb.FormSummary{
+		Id:          form.ID,
+		Title:       form.Title,
+		Description: form.Description,
+		Version:     form.Version,
+		Tags:        form.Tags,
+		Category:    form.Category,
+		Owner:       form.Owner,
+		Metadata:    metadata,
+	}, nil
+}
+
+// validateResponse converts a validation result to its message
+func validateResponse(result map[string]interface{}) (*smartformpb.ValidateResponse, error) {
+	complete, err := protoStruct(result)
+	if err != nil {
+		return nil, err
+	}
+	valid, _ := result["valid"].(bool)
+	response := &smartformpb.ValidateResponse{Valid: valid, Result: complete}
+	list, _ := result["errors"].([]interface{})
+	for _, item := range list {
+		if validationErr, ok := item.(map[string]interface{}); ok {
+			response.Errors = append(response.Errors, &smartformpb.ValidationError{
+				FieldId:  stringOf(validationErr, "fieldId"),
+				Message:  stringOf(validationErr, "message"),
+				RuleType: stringOf(validationErr, "ruleType"),
+			})
+		}
+	}
+	return response, nil
+}
+
+// submitResponse converts the response to a submission to its message
+func submitResponse(response map[string]interface{}) (*smartformpb.SubmitResponse, error) {
+	complete, err := protoStruct(response)
+	if err != nil {
+		return nil, err
+	}
+	success, _ := response["success"].(bool)
+	return &smartformpb.SubmitResponse{
+		Success:      success,
+		Message:      stringOf(response, "message"),
+		FormId:       stringOf(response, "formId"),
+		SubmissionId: stringOf(response, "submissionId"),
+		JobId:        stringOf(response, "jobId"),
+		Response:     complete,
+	}, nil
+}
+
+// protoStruct converts a JSON object to a Struct, or nil when it is empty
+func protoStruct(object map[string]interface{}) (*structpb.Struct, error) {
+	if len(object) == 0 {
+		return nil, nil
+	}
+	converted, err := structpb.NewStruct(object)
+	if err != nil {
+		return nil, internalError(fmt.Errorf("converting response: %w", err))
+	}
+	return converted, nil
+}
+
+// protoValue converts a JSON value to a Value
+func protoValue(value interface{}) (*structpb.Value, error) {
+	converted, err := structpb.NewValue(value)
+	if err != nil {
+		return nil, internalError(fmt.Errorf("converting response: %w", err))
+	}
+	return converted, nil
+}
+
+// structMap returns the JSON object of a Struct, or an empty object for nil
+func structMap(object *structpb.Struct) map[string]interface{} {
+	if object == nil {
+		return map[string]interface{}{}
+	}
+	return object.AsMap()
+}
+
+func stringOf(object map[string]interface{}, key string) string {
+	value, _ := object[key].(string)
+	return value
+}
+
+func mapOf(object map[string]interface{}, key string) map[string]interface{} {
+	value, _ := object[key].(map[string]interface{})
+	return value
+}
+
+func stringsOf(object map[string]interface{}, key string) []string {
+	list, _ := object[key].([]interface{})
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,98 @@
+package grpcserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the ErrorInfo details of failed calls
+const ErrorDomain = "smartform"
+
+// statusCodes maps the HTTP statuses of the API to gRPC codes
+var statusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusMethodNotAllowed:      codes.Unimplemented,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusGone:                  codes.NotFound,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusBadGateway:            codes.Unavailable,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcCode returns the gRPC code of an HTTP status
+func grpcCode(httpStatus int) codes.Code {
+	if code, ok := statusCodes[httpStatus]; ok {
+		return code
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// apiFailure is the body of a failed API request: an APIError, or the validation failure of a
+// submission
+type apiFailure struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Errors  []struct {
+		FieldID string `json:"fieldId"`
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+// statusError translates the response of a failed API request into a gRPC status, with the API
+// error code in an ErrorInfo detail and invalid fields in a BadRequest detail
+func statusError(httpStatus int, body []byte) error {
+	var failure apiFailure
+	_ = json.Unmarshal(body, &failure)
+	message := failure.Message
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+	st := status.New(grpcCode(httpStatus), message)
+
+	info := &errdetails.ErrorInfo{
+		Reason:   failure.Code,
+		Domain:   ErrorDomain,
+		Metadata: map[string]string{"httpStatus": strconv.Itoa(httpStatus)},
+	}
+	if failure.Field != "" {
+		info.Metadata["field"] = failure.Field
+	}
+	badRequest := &errdetails.BadRequest{}
+	for _, fieldErr := range failure.Errors {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       fieldErr.FieldID,
+			Description: fieldErr.Message,
+		})
+	}
+
+	detailed, err := st.WithDetails(info)
+	if err == nil && len(badRequest.FieldViolations) > 0 {
+		detailed, err = detailed.WithDetails(badRequest)
+	}
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// internalError reports a failure of the server itself
+func internalError(err error) error {
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,250 @@
+// Package grpcserver serves the smartform API over gRPC, for services that would rather call it
+// with clients generated from smartformpb/smartform.proto than over HTTP and JSON:
+//
+//	server := grpc.NewServer()
+//	grpcserver.Register(server, handler)
+//	server.Serve(listener)
+//
+// Calls are served in process by the routes of the APIHandler, through its middleware, so API
+// keys, tenants, request limits, audit logging, and telemetry apply to them as they do to HTTP
+// requests. The metadata of a call becomes the headers of the request, and the HTTP status of a
+// failed request the gRPC code of the call. Calls are not made by browsers, so they skip the
+// handler's CSRF protection, see smartform.InProcessContext.
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	smartform "github.com/juicycleff/smartform/v1"
+	"github.com/juicycleff/smartform/v1/grpcserver/smartformpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Server implements smartformpb.FormServiceServer on top of an APIHandler
+type Server struct {
+	smartformpb.UnimplementedFormServiceServer
+	handler http.Handler
+}
+
+// NewServer creates a gRPC form service serving the forms of an API handler
+func NewServer(handler *smartform.APIHandler) *Server {
+	return &Server{handler: handler.Handler()}
+}
+
+// Register registers the form service of an API handler on a gRPC server
+func Register(registrar grpc.ServiceRegistrar, handler *smartform.APIHandler) *Server {
+	server := NewServer(handler)
+	smartformpb.RegisterFormServiceServer(registrar, server)
+	return server
+}
+
+// ListForms returns a page of the registered forms
+func (s *Server) ListForms(ctx context.Context, req *smartformpb.ListFormsRequest) (*smartformpb.ListFormsResponse, error) {
+	query := url.Values{}
+	setQuery(query, "search", req.GetSearch())
+	setQuery(query, "category", req.GetCategory())
+	setQuery(query, "owner", req.GetOwner())
+	setQuery(query, "sort", req.GetSort())
+	if req.GetDescending() {
+		query.Set("sortDir", "desc")
+	}
+	if req.GetPage() > 0 {
+		query.Set("page", strconv.Itoa(int(req.GetPage())))
+	}
+	if req.GetLimit() > 0 {
+		query.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	}
+	for _, tag := range req.GetTags() {
+		query.Add("tag", tag)
+	}
+
+	var list smartform.FormList
+	if _, err := s.call(ctx, http.MethodGet, "/api/forms", query, nil, &list); err != nil {
+		return nil, err
+	}
+	response := &smartformpb.ListFormsResponse{
+		Total: int32(list.Pagination.Total),
+		Page:  int32(list.Pagination.Page),
+		Limit: int32(list.Pagination.Limit),
+		Pages: int32(list.Pagination.Pages),
+	}
+	for _, form := range list.Forms {
+		summary, err := formSummary(form)
+		if err != nil {
+			return nil, err
+		}
+		response.Forms = append(response.Forms, summary)
+	}
+	return response, nil
+}
+
+// Render returns a form rendered for a context
+func (s *Server) Render(ctx context.Context, req *smartformpb.RenderRequest) (*smartformpb.RenderResponse, error) {
+	query := url.Values{}
+	for key, value := range req.GetContext() {
+		query.Set(key, value)
+	}
+	setQuery(query, smartform.VersionQueryParam, req.GetVersion())
+	setQuery(query, smartform.LocaleQueryParam, req.GetLocale())
+	setQuery(query, smartform.FieldsQueryParam, strings.Join(req.GetFields(), ","))
+	setQuery(query, smartform.ExcludeQueryParam, strings.Join(req.GetExclude(), ","))
+
+	var rendered map[string]interface{}
+	header, err := s.call(ctx, http.MethodGet, "/api/forms/"+url.PathEscape(req.GetFormId()), query, nil, &rendered)
+	if err != nil {
+		return nil, err
+	}
+	form, err := formSchema(rendered)
+	if err != nil {
+		return nil, err
+	}
+	return &smartformpb.RenderResponse{
+		Form:        form,
+		Fingerprint: header.Get(smartform.FingerprintHeader),
+		Locale:      header.Get("Content-Language"),
+	}, nil
+}
+
+// Validate validates data against a form, or a single field of it
+func (s *Server) Validate(ctx context.Context, req *smartformpb.ValidateRequest) (*smartformpb.ValidateResponse, error) {
+	path := "/api/validate/" + url.PathEscape(req.GetFormId())
+	if req.GetField() != "" {
+		path += "/field/" + url.PathEscape(req.GetField())
+	}
+
+	var result map[string]interface{}
+	if _, err := s.call(ctx, http.MethodPost, path, nil, structMap(req.GetData()), &result); err != nil {
+		return nil, err
+	}
+	return validateResponse(result)
+}
+
+// Submit validates and submits data to a form. Data failing validation is rejected with an
+// InvalidArgument error listing the invalid fields.
+func (s *Server) Submit(ctx context.Context, req *smartformpb.SubmitRequest) (*smartformpb.SubmitResponse, error) {
+	var response map[string]interface{}
+	path := "/api/submit/" + url.PathEscape(req.GetFormId())
+	if _, err := s.call(ctx, http.MethodPost, path, nil, structMap(req.GetData()), &response); err != nil {
+		return nil, err
+	}
+	return submitResponse(response)
+}
+
+// ExecuteFunction executes a registered dynamic function
+func (s *Server) ExecuteFunction(ctx context.Context, req *smartformpb.ExecuteFunctionRequest) (*smartformpb.ExecuteFunctionResponse, error) {
+	body := map[string]interface{}{
+		"arguments": structMap(req.GetArguments()),
+		"formState": structMap(req.GetFormState()),
+		"sessionId": req.GetSessionId(),
+		"field":     req.GetField(),
+		"attempt":   req.GetAttempt(),
+	}
+
+	var result interface{}
+	if _, err := s.call(ctx, http.MethodPost, "/api/function/"+url.PathEscape(req.GetName()), nil, body, &result); err != nil {
+		return nil, err
+	}
+	value, err := protoValue(result)
+	if err != nil {
+		return nil, err
+	}
+	return &smartformpb.ExecuteFunctionResponse{Value: value}, nil
+}
+
+// call serves a request to an API route in process and decodes its JSON response into out. It
+// returns the headers of the response, or the error of a failed request as a gRPC status.
+func (s *Server) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) (http.Header, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, internalError(fmt.Errorf("encoding request: %w", err))
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	r, err := http.NewRequestWithContext(smartform.InProcessContext(ctx), method, target, reader)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	requestHeaders(ctx, r)
+
+	w := newResponseRecorder()
+	s.handler.ServeHTTP(w, r)
+	if w.status >= http.StatusBadRequest {
+		return nil, statusError(w.status, w.body.Bytes())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.body.Bytes(), out); err != nil {
+			return nil, internalError(fmt.Errorf("decoding response: %w", err))
+		}
+	}
+	return w.header, nil
+}
+
+// requestHeaders sets the headers of a request from the metadata and peer of a call. Binary
+// metadata, pseudo-headers, and the gRPC protocol's own headers are left out.
+func requestHeaders(ctx context.Context, r *http.Request) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || strings.HasSuffix(key, "-bin") ||
+				key == "content-type" || key == "te" {
+				continue
+			}
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", "application/json")
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		r.RemoteAddr = p.Addr.String()
+	}
+}
+
+// setQuery sets a query parameter unless its value is empty
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// responseRecorder keeps the response of a route served in process
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: http.Header{}}
+}
+
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.WriteHeader(http.StatusOK)
+	return rr.body.Write(data)
+}
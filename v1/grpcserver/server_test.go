@@ -0,0 +1,153 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	smartform "github.com/juicycleff/smartform/v1"
+	"github.com/juicycleff/smartform/v1/grpcserver/smartformpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newClient(t *testing.T, handler *smartform.APIHandler) smartformpb.FormServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	Register(server, handler)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return smartformpb.NewFormServiceClient(conn)
+}
+
+func newContactHandler(t *testing.T) *smartform.APIHandler {
+	handler := smartform.NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(smartform.NewForm("contact", "Contact").
+		Tag("support").
+		Meta("icon", "mail").
+		AddField(smartform.NewFieldBuilder("name", smartform.FieldTypeText, "Name").Required(true).Build()).
+		AddField(smartform.NewFieldBuilder("topic", smartform.FieldTypeSelect, "Topic").
+			WithStaticOptions([]*smartform.Option{smartform.NewOption("sales", "Sales"), smartform.NewOption("help", "Help")}).
+			Build()).
+		Build()))
+	functions := smartform.NewDynamicFunctionService()
+	functions.RegisterFunction("greet", func(args, state map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{"greeting": "Hello " + state["name"].(string)}, nil
+	})
+	handler.SetDynamicFunctionService(functions)
+	return handler
+}
+
+func TestFormService(t *testing.T) {
+	client := newClient(t, newContactHandler(t))
+	ctx := context.Background()
+
+	list, err := client.ListForms(ctx, &smartformpb.ListFormsRequest{Tags: []string{"support"}})
+	require.NoError(t, err)
+	require.Len(t, list.Forms, 1)
+	assert.Equal(t, int32(1), list.Total)
+	assert.Equal(t, "mail", list.Forms[0].Metadata.AsMap()["icon"])
+
+	rendered, err := client.Render(ctx, &smartformpb.RenderRequest{FormId: "contact"})
+	require.NoError(t, err)
+	assert.Equal(t, "Contact", rendered.Form.Title)
+	require.Len(t, rendered.Form.Fields, 2)
+	assert.True(t, rendered.Form.Fields[0].Required)
+	require.Len(t, rendered.Form.Fields[1].Options, 2)
+	assert.Equal(t, "sales", rendered.Form.Fields[1].Options[0].Value.GetStringValue())
+	assert.NotEmpty(t, rendered.Fingerprint)
+	assert.Equal(t, "contact", rendered.Form.Definition.AsMap()["id"])
+
+	_, err = client.Render(ctx, &smartformpb.RenderRequest{FormId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	data, err := structpb.NewStruct(map[string]interface{}{"topic": "sales"})
+	require.NoError(t, err)
+	validation, err := client.Validate(ctx, &smartformpb.ValidateRequest{FormId: "contact", Data: data})
+	require.NoError(t, err)
+	assert.False(t, validation.Valid)
+	require.NotEmpty(t, validation.Errors)
+	assert.Equal(t, "name", validation.Errors[0].FieldId)
+
+	// Rejected submissions list their invalid fields
+	_, err = client.Submit(ctx, &smartformpb.SubmitRequest{FormId: "contact", Data: data})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	var reason string
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			reason = detail.Reason
+		case *errdetails.BadRequest:
+			violations = detail.FieldViolations
+		}
+	}
+	assert.Equal(t, smartform.ErrorCodeValidationFailed, reason)
+	require.NotEmpty(t, violations)
+	assert.Equal(t, "name", violations[0].Field)
+
+	data.Fields["name"] = structpb.NewStringValue("Ada")
+	submitted, err := client.Submit(ctx, &smartformpb.SubmitRequest{FormId: "contact", Data: data})
+	require.NoError(t, err)
+	assert.True(t, submitted.Success)
+	assert.Equal(t, "contact", submitted.FormId)
+
+	state, err := structpb.NewStruct(map[string]interface{}{"name": "Ada"})
+	require.NoError(t, err)
+	result, err := client.ExecuteFunction(ctx, &smartformpb.ExecuteFunctionRequest{Name: "greet", FormState: state})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Ada", result.Value.GetStructValue().AsMap()["greeting"])
+}
+
+func TestFormServiceWithCSRF(t *testing.T) {
+	handler := smartform.NewAPIHandler(smartform.WithCSRF(smartform.CSRFConfig{}))
+	require.NoError(t, handler.RegisterSchema(smartform.NewForm("contact", "Contact").
+		AddField(smartform.NewFieldBuilder("name", smartform.FieldTypeText, "Name").Build()).
+		Build()))
+	client := newClient(t, handler)
+
+	// Calls are not made by browsers, so they need no CSRF token, even with browser metadata
+	data, err := structpb.NewStruct(map[string]interface{}{"name": "Ada"})
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "origin", "https://evil.example.com")
+	_, err = client.Validate(ctx, &smartformpb.ValidateRequest{FormId: "contact", Data: data})
+	assert.NoError(t, err)
+	submitted, err := client.Submit(ctx, &smartformpb.SubmitRequest{FormId: "contact", Data: data})
+	require.NoError(t, err)
+	assert.True(t, submitted.Success)
+}
+
+func TestFormServiceMetadata(t *testing.T) {
+	handler := newContactHandler(t)
+	handler.SetAPIKeyStore(smartform.NewMemoryAPIKeyStore())
+	_, secret, err := handler.CreateAPIKey("crm", []string{"contact"}, smartform.APIKeyOperationSubmit)
+	require.NoError(t, err)
+	client := newClient(t, handler)
+
+	data, err := structpb.NewStruct(map[string]interface{}{"name": "Ada"})
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), smartform.APIKeyHeader, secret)
+	_, err = client.Submit(ctx, &smartformpb.SubmitRequest{FormId: "contact", Data: data})
+	assert.NoError(t, err)
+	_, err = client.ListForms(ctx, &smartformpb.ListFormsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "the key may only submit")
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), smartform.APIKeyHeader, secret+"x")
+	_, err = client.Submit(ctx, &smartformpb.SubmitRequest{FormId: "contact", Data: data})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,5 @@
+// Package smartformpb holds the messages and service of smartform.proto, generated with
+// protoc-gen-go and protoc-gen-go-grpc
+package smartformpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative smartform.proto
@@ -0,0 +1,1446 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: smartform.proto
+
+package smartformpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// FormSchema is a form as rendered by the API
+type FormSchema struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id          string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title       string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Version     string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	Type        string                 `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	Fields      []*Field               `protobuf:"bytes,6,rep,name=fields,proto3" json:"fields,omitempty"`
+	Properties  *structpb.Struct       `protobuf:"bytes,7,opt,name=properties,proto3" json:"properties,omitempty"`
+	Tags        []string               `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category    string                 `protobuf:"bytes,9,opt,name=category,proto3" json:"category,omitempty"`
+	Owner       string                 `protobuf:"bytes,10,opt,name=owner,proto3" json:"owner,omitempty"`
+	Metadata    *structpb.Struct       `protobuf:"bytes,11,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// The complete form as the JSON API serves it, including the parts without a field of their
+	// own here, such as conditions, validation rules, and dynamic sources
+	Definition    *structpb.Struct `protobuf:"bytes,12,opt,name=definition,proto3" json:"definition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FormSchema) Reset() {
+	*x = FormSchema{}
+	mi := &file_smartform_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FormSchema) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FormSchema) ProtoMessage() {}
+
+func (x *FormSchema) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FormSchema.ProtoReflect.Descriptor instead.
+func (*FormSchema) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FormSchema) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FormSchema) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *FormSchema) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *FormSchema) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *FormSchema) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *FormSchema) GetFields() []*Field {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *FormSchema) GetProperties() *structpb.Struct {
+	if x != nil {
+		return x.Properties
+	}
+	return nil
+}
+
+func (x *FormSchema) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *FormSchema) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *FormSchema) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *FormSchema) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *FormSchema) GetDefinition() *structpb.Struct {
+	if x != nil {
+		return x.Definition
+	}
+	return nil
+}
+
+// Field is a field of a form
+type Field struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Label         string                 `protobuf:"bytes,3,opt,name=label,proto3" json:"label,omitempty"`
+	Required      bool                   `protobuf:"varint,4,opt,name=required,proto3" json:"required,omitempty"`
+	Placeholder   string                 `protobuf:"bytes,5,opt,name=placeholder,proto3" json:"placeholder,omitempty"`
+	HelpText      string                 `protobuf:"bytes,6,opt,name=help_text,json=helpText,proto3" json:"help_text,omitempty"`
+	DefaultValue  *structpb.Value        `protobuf:"bytes,7,opt,name=default_value,json=defaultValue,proto3" json:"default_value,omitempty"`
+	Order         int32                  `protobuf:"varint,8,opt,name=order,proto3" json:"order,omitempty"`
+	Options       []*Option              `protobuf:"bytes,9,rep,name=options,proto3" json:"options,omitempty"`
+	Nested        []*Field               `protobuf:"bytes,10,rep,name=nested,proto3" json:"nested,omitempty"`
+	Properties    *structpb.Struct       `protobuf:"bytes,11,opt,name=properties,proto3" json:"properties,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Field) Reset() {
+	*x = Field{}
+	mi := &file_smartform_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Field) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Field) ProtoMessage() {}
+
+func (x *Field) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Field.ProtoReflect.Descriptor instead.
+func (*Field) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Field) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Field) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Field) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Field) GetRequired() bool {
+	if x != nil {
+		return x.Required
+	}
+	return false
+}
+
+func (x *Field) GetPlaceholder() string {
+	if x != nil {
+		return x.Placeholder
+	}
+	return ""
+}
+
+func (x *Field) GetHelpText() string {
+	if x != nil {
+		return x.HelpText
+	}
+	return ""
+}
+
+func (x *Field) GetDefaultValue() *structpb.Value {
+	if x != nil {
+		return x.DefaultValue
+	}
+	return nil
+}
+
+func (x *Field) GetOrder() int32 {
+	if x != nil {
+		return x.Order
+	}
+	return 0
+}
+
+func (x *Field) GetOptions() []*Option {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *Field) GetNested() []*Field {
+	if x != nil {
+		return x.Nested
+	}
+	return nil
+}
+
+func (x *Field) GetProperties() *structpb.Struct {
+	if x != nil {
+		return x.Properties
+	}
+	return nil
+}
+
+// Option is a choice of a select-type field
+type Option struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *structpb.Value        `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Label         string                 `protobuf:"bytes,2,opt,name=label,proto3" json:"label,omitempty"`
+	Icon          string                 `protobuf:"bytes,3,opt,name=icon,proto3" json:"icon,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Option) Reset() {
+	*x = Option{}
+	mi := &file_smartform_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Option) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Option) ProtoMessage() {}
+
+func (x *Option) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Option.ProtoReflect.Descriptor instead.
+func (*Option) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Option) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Option) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Option) GetIcon() string {
+	if x != nil {
+		return x.Icon
+	}
+	return ""
+}
+
+// FormSummary describes a registered form in the form list
+type FormSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Description   string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	Version       string                 `protobuf:"bytes,4,opt,name=version,proto3" json:"version,omitempty"`
+	Tags          []string               `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category      string                 `protobuf:"bytes,6,opt,name=category,proto3" json:"category,omitempty"`
+	Owner         string                 `protobuf:"bytes,7,opt,name=owner,proto3" json:"owner,omitempty"`
+	Metadata      *structpb.Struct       `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FormSummary) Reset() {
+	*x = FormSummary{}
+	mi := &file_smartform_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FormSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FormSummary) ProtoMessage() {}
+
+func (x *FormSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FormSummary.ProtoReflect.Descriptor instead.
+func (*FormSummary) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FormSummary) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FormSummary) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *FormSummary) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *FormSummary) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *FormSummary) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *FormSummary) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *FormSummary) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *FormSummary) GetMetadata() *structpb.Struct {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type ListFormsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Text the ID, title, or description must contain, ignoring case
+	Search string `protobuf:"bytes,1,opt,name=search,proto3" json:"search,omitempty"`
+	// Tags the forms must all carry
+	Tags     []string `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty"`
+	Category string   `protobuf:"bytes,3,opt,name=category,proto3" json:"category,omitempty"`
+	Owner    string   `protobuf:"bytes,4,opt,name=owner,proto3" json:"owner,omitempty"`
+	// id, title, version, or category; id by default
+	Sort       string `protobuf:"bytes,5,opt,name=sort,proto3" json:"sort,omitempty"`
+	Descending bool   `protobuf:"varint,6,opt,name=descending,proto3" json:"descending,omitempty"`
+	// 1-based page number
+	Page          int32 `protobuf:"varint,7,opt,name=page,proto3" json:"page,omitempty"`
+	Limit         int32 `protobuf:"varint,8,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFormsRequest) Reset() {
+	*x = ListFormsRequest{}
+	mi := &file_smartform_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFormsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFormsRequest) ProtoMessage() {}
+
+func (x *ListFormsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFormsRequest.ProtoReflect.Descriptor instead.
+func (*ListFormsRequest) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListFormsRequest) GetSearch() string {
+	if x != nil {
+		return x.Search
+	}
+	return ""
+}
+
+func (x *ListFormsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ListFormsRequest) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *ListFormsRequest) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *ListFormsRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListFormsRequest) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+func (x *ListFormsRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListFormsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListFormsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Forms []*FormSummary         `protobuf:"bytes,1,rep,name=forms,proto3" json:"forms,omitempty"`
+	// Number of forms matching the request, across all pages
+	Total         int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	Page          int32 `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	Limit         int32 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Pages         int32 `protobuf:"varint,5,opt,name=pages,proto3" json:"pages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFormsResponse) Reset() {
+	*x = ListFormsResponse{}
+	mi := &file_smartform_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFormsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFormsResponse) ProtoMessage() {}
+
+func (x *ListFormsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFormsResponse.ProtoReflect.Descriptor instead.
+func (*ListFormsResponse) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListFormsResponse) GetForms() []*FormSummary {
+	if x != nil {
+		return x.Forms
+	}
+	return nil
+}
+
+func (x *ListFormsResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListFormsResponse) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListFormsResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListFormsResponse) GetPages() int32 {
+	if x != nil {
+		return x.Pages
+	}
+	return 0
+}
+
+type RenderRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	FormId string                 `protobuf:"bytes,1,opt,name=form_id,json=formId,proto3" json:"form_id,omitempty"`
+	// Version to render; the newest when empty
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// Locale of a translation bundle; the accept-language metadata is used when empty
+	Locale string `protobuf:"bytes,3,opt,name=locale,proto3" json:"locale,omitempty"`
+	// Variables templates and conditions are evaluated with
+	Context map[string]string `protobuf:"bytes,4,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Field paths to keep, or to leave out, of the rendered form
+	Fields        []string `protobuf:"bytes,5,rep,name=fields,proto3" json:"fields,omitempty"`
+	Exclude       []string `protobuf:"bytes,6,rep,name=exclude,proto3" json:"exclude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderRequest) Reset() {
+	*x = RenderRequest{}
+	mi := &file_smartform_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderRequest) ProtoMessage() {}
+
+func (x *RenderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderRequest.ProtoReflect.Descriptor instead.
+func (*RenderRequest) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RenderRequest) GetFormId() string {
+	if x != nil {
+		return x.FormId
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *RenderRequest) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *RenderRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *RenderRequest) GetExclude() []string {
+	if x != nil {
+		return x.Exclude
+	}
+	return nil
+}
+
+type RenderResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Form  *FormSchema            `protobuf:"bytes,1,opt,name=form,proto3" json:"form,omitempty"`
+	// Fingerprint of the registered form
+	Fingerprint string `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Locale the form was rendered in, if translated
+	Locale        string `protobuf:"bytes,3,opt,name=locale,proto3" json:"locale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderResponse) Reset() {
+	*x = RenderResponse{}
+	mi := &file_smartform_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderResponse) ProtoMessage() {}
+
+func (x *RenderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderResponse.ProtoReflect.Descriptor instead.
+func (*RenderResponse) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RenderResponse) GetForm() *FormSchema {
+	if x != nil {
+		return x.Form
+	}
+	return nil
+}
+
+func (x *RenderResponse) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *RenderResponse) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+type ValidateRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	FormId string                 `protobuf:"bytes,1,opt,name=form_id,json=formId,proto3" json:"form_id,omitempty"`
+	Data   *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	// Path of a single field to validate; the whole form when empty
+	Field         string `protobuf:"bytes,3,opt,name=field,proto3" json:"field,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateRequest) Reset() {
+	*x = ValidateRequest{}
+	mi := &file_smartform_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateRequest) ProtoMessage() {}
+
+func (x *ValidateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateRequest.ProtoReflect.Descriptor instead.
+func (*ValidateRequest) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ValidateRequest) GetFormId() string {
+	if x != nil {
+		return x.FormId
+	}
+	return ""
+}
+
+func (x *ValidateRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ValidateRequest) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+// ValidationError is a failed check of a field
+type ValidationError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FieldId       string                 `protobuf:"bytes,1,opt,name=field_id,json=fieldId,proto3" json:"field_id,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	RuleType      string                 `protobuf:"bytes,3,opt,name=rule_type,json=ruleType,proto3" json:"rule_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidationError) Reset() {
+	*x = ValidationError{}
+	mi := &file_smartform_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidationError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidationError) ProtoMessage() {}
+
+func (x *ValidationError) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidationError.ProtoReflect.Descriptor instead.
+func (*ValidationError) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ValidationError) GetFieldId() string {
+	if x != nil {
+		return x.FieldId
+	}
+	return ""
+}
+
+func (x *ValidationError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ValidationError) GetRuleType() string {
+	if x != nil {
+		return x.RuleType
+	}
+	return ""
+}
+
+type ValidateResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Valid  bool                   `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	Errors []*ValidationError     `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+	// The complete validation result as the JSON API returns it
+	Result        *structpb.Struct `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateResponse) Reset() {
+	*x = ValidateResponse{}
+	mi := &file_smartform_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateResponse) ProtoMessage() {}
+
+func (x *ValidateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateResponse.ProtoReflect.Descriptor instead.
+func (*ValidateResponse) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ValidateResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *ValidateResponse) GetErrors() []*ValidationError {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *ValidateResponse) GetResult() *structpb.Struct {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type SubmitRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	FormId        string                 `protobuf:"bytes,1,opt,name=form_id,json=formId,proto3" json:"form_id,omitempty"`
+	Data          *structpb.Struct       `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitRequest) Reset() {
+	*x = SubmitRequest{}
+	mi := &file_smartform_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitRequest) ProtoMessage() {}
+
+func (x *SubmitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitRequest.ProtoReflect.Descriptor instead.
+func (*SubmitRequest) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SubmitRequest) GetFormId() string {
+	if x != nil {
+		return x.FormId
+	}
+	return ""
+}
+
+func (x *SubmitRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type SubmitResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	FormId  string                 `protobuf:"bytes,3,opt,name=form_id,json=formId,proto3" json:"form_id,omitempty"`
+	// ID of the stored submission, when a submission store is configured
+	SubmissionId string `protobuf:"bytes,4,opt,name=submission_id,json=submissionId,proto3" json:"submission_id,omitempty"`
+	// ID of the background job, for forms submitted asynchronously
+	JobId string `protobuf:"bytes,5,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// The complete response as the JSON API returns it
+	Response      *structpb.Struct `protobuf:"bytes,6,opt,name=response,proto3" json:"response,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitResponse) Reset() {
+	*x = SubmitResponse{}
+	mi := &file_smartform_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitResponse) ProtoMessage() {}
+
+func (x *SubmitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitResponse.ProtoReflect.Descriptor instead.
+func (*SubmitResponse) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *SubmitResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *SubmitResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetFormId() string {
+	if x != nil {
+		return x.FormId
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetSubmissionId() string {
+	if x != nil {
+		return x.SubmissionId
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *SubmitResponse) GetResponse() *structpb.Struct {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+type ExecuteFunctionRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Name      string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Arguments *structpb.Struct       `protobuf:"bytes,2,opt,name=arguments,proto3" json:"arguments,omitempty"`
+	FormState *structpb.Struct       `protobuf:"bytes,3,opt,name=form_state,json=formState,proto3" json:"form_state,omitempty"`
+	SessionId string                 `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	Field     string                 `protobuf:"bytes,5,opt,name=field,proto3" json:"field,omitempty"`
+	// Attempt token of functions with side effects, executed once per attempt
+	Attempt       string `protobuf:"bytes,6,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteFunctionRequest) Reset() {
+	*x = ExecuteFunctionRequest{}
+	mi := &file_smartform_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteFunctionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteFunctionRequest) ProtoMessage() {}
+
+func (x *ExecuteFunctionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteFunctionRequest.ProtoReflect.Descriptor instead.
+func (*ExecuteFunctionRequest) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ExecuteFunctionRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ExecuteFunctionRequest) GetArguments() *structpb.Struct {
+	if x != nil {
+		return x.Arguments
+	}
+	return nil
+}
+
+func (x *ExecuteFunctionRequest) GetFormState() *structpb.Struct {
+	if x != nil {
+		return x.FormState
+	}
+	return nil
+}
+
+func (x *ExecuteFunctionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ExecuteFunctionRequest) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *ExecuteFunctionRequest) GetAttempt() string {
+	if x != nil {
+		return x.Attempt
+	}
+	return ""
+}
+
+type ExecuteFunctionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *structpb.Value        `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecuteFunctionResponse) Reset() {
+	*x = ExecuteFunctionResponse{}
+	mi := &file_smartform_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecuteFunctionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecuteFunctionResponse) ProtoMessage() {}
+
+func (x *ExecuteFunctionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_smartform_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecuteFunctionResponse.ProtoReflect.Descriptor instead.
+func (*ExecuteFunctionResponse) Descriptor() ([]byte, []int) {
+	return file_smartform_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ExecuteFunctionResponse) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+var File_smartform_proto protoreflect.FileDescriptor
+
+const file_smartform_proto_rawDesc = "" +
+	"\n" +
+	"\x0fsmartform.proto\x12\fsmartform.v1\x1a\x1cgoogle/protobuf/struct.proto\"\x9c\x03\n" +
+	"\n" +
+	"FormSchema\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\x12\x12\n" +
+	"\x04type\x18\x05 \x01(\tR\x04type\x12+\n" +
+	"\x06fields\x18\x06 \x03(\v2\x13.smartform.v1.FieldR\x06fields\x127\n" +
+	"\n" +
+	"properties\x18\a \x01(\v2\x17.google.protobuf.StructR\n" +
+	"properties\x12\x12\n" +
+	"\x04tags\x18\b \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\t \x01(\tR\bcategory\x12\x14\n" +
+	"\x05owner\x18\n" +
+	" \x01(\tR\x05owner\x123\n" +
+	"\bmetadata\x18\v \x01(\v2\x17.google.protobuf.StructR\bmetadata\x127\n" +
+	"\n" +
+	"definition\x18\f \x01(\v2\x17.google.protobuf.StructR\n" +
+	"definition\"\x85\x03\n" +
+	"\x05Field\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05label\x18\x03 \x01(\tR\x05label\x12\x1a\n" +
+	"\brequired\x18\x04 \x01(\bR\brequired\x12 \n" +
+	"\vplaceholder\x18\x05 \x01(\tR\vplaceholder\x12\x1b\n" +
+	"\thelp_text\x18\x06 \x01(\tR\bhelpText\x12;\n" +
+	"\rdefault_value\x18\a \x01(\v2\x16.google.protobuf.ValueR\fdefaultValue\x12\x14\n" +
+	"\x05order\x18\b \x01(\x05R\x05order\x12.\n" +
+	"\aoptions\x18\t \x03(\v2\x14.smartform.v1.OptionR\aoptions\x12+\n" +
+	"\x06nested\x18\n" +
+	" \x03(\v2\x13.smartform.v1.FieldR\x06nested\x127\n" +
+	"\n" +
+	"properties\x18\v \x01(\v2\x17.google.protobuf.StructR\n" +
+	"properties\"`\n" +
+	"\x06Option\x12,\n" +
+	"\x05value\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x05value\x12\x14\n" +
+	"\x05label\x18\x02 \x01(\tR\x05label\x12\x12\n" +
+	"\x04icon\x18\x03 \x01(\tR\x04icon\"\xea\x01\n" +
+	"\vFormSummary\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x18\n" +
+	"\aversion\x18\x04 \x01(\tR\aversion\x12\x12\n" +
+	"\x04tags\x18\x05 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\x06 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05owner\x18\a \x01(\tR\x05owner\x123\n" +
+	"\bmetadata\x18\b \x01(\v2\x17.google.protobuf.StructR\bmetadata\"\xce\x01\n" +
+	"\x10ListFormsRequest\x12\x16\n" +
+	"\x06search\x18\x01 \x01(\tR\x06search\x12\x12\n" +
+	"\x04tags\x18\x02 \x03(\tR\x04tags\x12\x1a\n" +
+	"\bcategory\x18\x03 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05owner\x18\x04 \x01(\tR\x05owner\x12\x12\n" +
+	"\x04sort\x18\x05 \x01(\tR\x04sort\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x06 \x01(\bR\n" +
+	"descending\x12\x12\n" +
+	"\x04page\x18\a \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\b \x01(\x05R\x05limit\"\x9a\x01\n" +
+	"\x11ListFormsResponse\x12/\n" +
+	"\x05forms\x18\x01 \x03(\v2\x19.smartform.v1.FormSummaryR\x05forms\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x14\n" +
+	"\x05pages\x18\x05 \x01(\x05R\x05pages\"\x8c\x02\n" +
+	"\rRenderRequest\x12\x17\n" +
+	"\aform_id\x18\x01 \x01(\tR\x06formId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x16\n" +
+	"\x06locale\x18\x03 \x01(\tR\x06locale\x12B\n" +
+	"\acontext\x18\x04 \x03(\v2(.smartform.v1.RenderRequest.ContextEntryR\acontext\x12\x16\n" +
+	"\x06fields\x18\x05 \x03(\tR\x06fields\x12\x18\n" +
+	"\aexclude\x18\x06 \x03(\tR\aexclude\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"x\n" +
+	"\x0eRenderResponse\x12,\n" +
+	"\x04form\x18\x01 \x01(\v2\x18.smartform.v1.FormSchemaR\x04form\x12 \n" +
+	"\vfingerprint\x18\x02 \x01(\tR\vfingerprint\x12\x16\n" +
+	"\x06locale\x18\x03 \x01(\tR\x06locale\"m\n" +
+	"\x0fValidateRequest\x12\x17\n" +
+	"\aform_id\x18\x01 \x01(\tR\x06formId\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\x12\x14\n" +
+	"\x05field\x18\x03 \x01(\tR\x05field\"c\n" +
+	"\x0fValidationError\x12\x19\n" +
+	"\bfield_id\x18\x01 \x01(\tR\afieldId\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1b\n" +
+	"\trule_type\x18\x03 \x01(\tR\bruleType\"\x90\x01\n" +
+	"\x10ValidateResponse\x12\x14\n" +
+	"\x05valid\x18\x01 \x01(\bR\x05valid\x125\n" +
+	"\x06errors\x18\x02 \x03(\v2\x1d.smartform.v1.ValidationErrorR\x06errors\x12/\n" +
+	"\x06result\x18\x03 \x01(\v2\x17.google.protobuf.StructR\x06result\"U\n" +
+	"\rSubmitRequest\x12\x17\n" +
+	"\aform_id\x18\x01 \x01(\tR\x06formId\x12+\n" +
+	"\x04data\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x04data\"\xce\x01\n" +
+	"\x0eSubmitResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x17\n" +
+	"\aform_id\x18\x03 \x01(\tR\x06formId\x12#\n" +
+	"\rsubmission_id\x18\x04 \x01(\tR\fsubmissionId\x12\x15\n" +
+	"\x06job_id\x18\x05 \x01(\tR\x05jobId\x123\n" +
+	"\bresponse\x18\x06 \x01(\v2\x17.google.protobuf.StructR\bresponse\"\xea\x01\n" +
+	"\x16ExecuteFunctionRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x125\n" +
+	"\targuments\x18\x02 \x01(\v2\x17.google.protobuf.StructR\targuments\x126\n" +
+	"\n" +
+	"form_state\x18\x03 \x01(\v2\x17.google.protobuf.StructR\tformState\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05field\x18\x05 \x01(\tR\x05field\x12\x18\n" +
+	"\aattempt\x18\x06 \x01(\tR\aattempt\"G\n" +
+	"\x17ExecuteFunctionResponse\x12,\n" +
+	"\x05value\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x05value2\x90\x03\n" +
+	"\vFormService\x12L\n" +
+	"\tListForms\x12\x1e.smartform.v1.ListFormsRequest\x1a\x1f.smartform.v1.ListFormsResponse\x12C\n" +
+	"\x06Render\x12\x1b.smartform.v1.RenderRequest\x1a\x1c.smartform.v1.RenderResponse\x12I\n" +
+	"\bValidate\x12\x1d.smartform.v1.ValidateRequest\x1a\x1e.smartform.v1.ValidateResponse\x12C\n" +
+	"\x06Submit\x12\x1b.smartform.v1.SubmitRequest\x1a\x1c.smartform.v1.SubmitResponse\x12^\n" +
+	"\x0fExecuteFunction\x12$.smartform.v1.ExecuteFunctionRequest\x1a%.smartform.v1.ExecuteFunctionResponseB;Z9github.com/juicycleff/smartform/v1/grpcserver/smartformpbb\x06proto3"
+
+var (
+	file_smartform_proto_rawDescOnce sync.Once
+	file_smartform_proto_rawDescData []byte
+)
+
+func file_smartform_proto_rawDescGZIP() []byte {
+	file_smartform_proto_rawDescOnce.Do(func() {
+		file_smartform_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_smartform_proto_rawDesc), len(file_smartform_proto_rawDesc)))
+	})
+	return file_smartform_proto_rawDescData
+}
+
+var file_smartform_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_smartform_proto_goTypes = []any{
+	(*FormSchema)(nil),              // 0: smartform.v1.FormSchema
+	(*Field)(nil),                   // 1: smartform.v1.Field
+	(*Option)(nil),                  // 2: smartform.v1.Option
+	(*FormSummary)(nil),             // 3: smartform.v1.FormSummary
+	(*ListFormsRequest)(nil),        // 4: smartform.v1.ListFormsRequest
+	(*ListFormsResponse)(nil),       // 5: smartform.v1.ListFormsResponse
+	(*RenderRequest)(nil),           // 6: smartform.v1.RenderRequest
+	(*RenderResponse)(nil),          // 7: smartform.v1.RenderResponse
+	(*ValidateRequest)(nil),         // 8: smartform.v1.ValidateRequest
+	(*ValidationError)(nil),         // 9: smartform.v1.ValidationError
+	(*ValidateResponse)(nil),        // 10: smartform.v1.ValidateResponse
+	(*SubmitRequest)(nil),           // 11: smartform.v1.SubmitRequest
+	(*SubmitResponse)(nil),          // 12: smartform.v1.SubmitResponse
+	(*ExecuteFunctionRequest)(nil),  // 13: smartform.v1.ExecuteFunctionRequest
+	(*ExecuteFunctionResponse)(nil), // 14: smartform.v1.ExecuteFunctionResponse
+	nil,                             // 15: smartform.v1.RenderRequest.ContextEntry
+	(*structpb.Struct)(nil),         // 16: google.protobuf.Struct
+	(*structpb.Value)(nil),          // 17: google.protobuf.Value
+}
+var file_smartform_proto_depIdxs = []int32{
+	1,  // 0: smartform.v1.FormSchema.fields:type_name -> smartform.v1.Field
+	16, // 1: smartform.v1.FormSchema.properties:type_name -> google.protobuf.Struct
+	16, // 2: smartform.v1.FormSchema.metadata:type_name -> google.protobuf.Struct
+	16, // 3: smartform.v1.FormSchema.definition:type_name -> google.protobuf.Struct
+	17, // 4: smartform.v1.Field.default_value:type_name -> google.protobuf.Value
+	2,  // 5: smartform.v1.Field.options:type_name -> smartform.v1.Option
+	1,  // 6: smartform.v1.Field.nested:type_name -> smartform.v1.Field
+	16, // 7: smartform.v1.Field.properties:type_name -> google.protobuf.Struct
+	17, // 8: smartform.v1.Option.value:type_name -> google.protobuf.Value
+	16, // 9: smartform.v1.FormSummary.metadata:type_name -> google.protobuf.Struct
+	3,  // 10: smartform.v1.ListFormsResponse.forms:type_name -> smartform.v1.FormSummary
+	15, // 11: smartform.v1.RenderRequest.context:type_name -> smartform.v1.RenderRequest.ContextEntry
+	0,  // 12: smartform.v1.RenderResponse.form:type_name -> smartform.v1.FormSchema
+	16, // 13: smartform.v1.ValidateRequest.data:type_name -> google.protobuf.Struct
+	9,  // 14: smartform.v1.ValidateResponse.errors:type_name -> smartform.v1.ValidationError
+	16, // 15: smartform.v1.ValidateResponse.result:type_name -> google.protobuf.Struct
+	16, // 16: smartform.v1.SubmitRequest.data:type_name -> google.protobuf.Struct
+	16, // 17: smartform.v1.SubmitResponse.response:type_name -> google.protobuf.Struct
+	16, // 18: smartform.v1.ExecuteFunctionRequest.arguments:type_name -> google.protobuf.Struct
+	16, // 19: smartform.v1.ExecuteFunctionRequest.form_state:type_name -> google.protobuf.Struct
+	17, // 20: smartform.v1.ExecuteFunctionResponse.value:type_name -> google.protobuf.Value
+	4,  // 21: smartform.v1.FormService.ListForms:input_type -> smartform.v1.ListFormsRequest
+	6,  // 22: smartform.v1.FormService.Render:input_type -> smartform.v1.RenderRequest
+	8,  // 23: smartform.v1.FormService.Validate:input_type -> smartform.v1.ValidateRequest
+	11, // 24: smartform.v1.FormService.Submit:input_type -> smartform.v1.SubmitRequest
+	13, // 25: smartform.v1.FormService.ExecuteFunction:input_type -> smartform.v1.ExecuteFunctionRequest
+	5,  // 26: smartform.v1.FormService.ListForms:output_type -> smartform.v1.ListFormsResponse
+	7,  // 27: smartform.v1.FormService.Render:output_type -> smartform.v1.RenderResponse
+	10, // 28: smartform.v1.FormService.Validate:output_type -> smartform.v1.ValidateResponse
+	12, // 29: smartform.v1.FormService.Submit:output_type -> smartform.v1.SubmitResponse
+	14, // 30: smartform.v1.FormService.ExecuteFunction:output_type -> smartform.v1.ExecuteFunctionResponse
+	26, // [26:31] is the sub-list for method output_type
+	21, // [21:26] is the sub-list for method input_type
+	21, // [21:21] is the sub-list for extension type_name
+	21, // [21:21] is the sub-list for extension extendee
+	0,  // [0:21] is the sub-list for field type_name
+}
+
+func init() { file_smartform_proto_init() }
+func file_smartform_proto_init() {
+	if File_smartform_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_smartform_proto_rawDesc), len(file_smartform_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   16,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_smartform_proto_goTypes,
+		DependencyIndexes: file_smartform_proto_depIdxs,
+		MessageInfos:      file_smartform_proto_msgTypes,
+	}.Build()
+	File_smartform_proto = out.File
+	file_smartform_proto_goTypes = nil
+	file_smartform_proto_depIdxs = nil
+}
@@ -0,0 +1,301 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: smartform.proto
+
+package smartformpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FormService_ListForms_FullMethodName       = "/smartform.v1.FormService/ListForms"
+	FormService_Render_FullMethodName          = "/smartform.v1.FormService/Render"
+	FormService_Validate_FullMethodName        = "/smartform.v1.FormService/Validate"
+	FormService_Submit_FullMethodName          = "/smartform.v1.FormService/Submit"
+	FormService_ExecuteFunction_FullMethodName = "/smartform.v1.FormService/ExecuteFunction"
+)
+
+// FormServiceClient is the client API for FormService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FormService serves registered forms to other services: listing and rendering them,
+// validating data against them, submitting them, and executing their dynamic functions.
+//
+// Calls carry the HTTP API's headers as metadata, such as x-api-key, x-tenant-id,
+// accept-language, and idempotency-key. Failures have the gRPC code matching the HTTP status
+// of the API, with a google.rpc.ErrorInfo detail in the "smartform" domain whose reason is the
+// API error code, and a google.rpc.BadRequest detail listing the invalid fields of rejected
+// submissions.
+type FormServiceClient interface {
+	// ListForms returns a page of the registered forms
+	ListForms(ctx context.Context, in *ListFormsRequest, opts ...grpc.CallOption) (*ListFormsResponse, error)
+	// Render returns a form rendered for a context
+	Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderResponse, error)
+	// Validate validates data against a form, or a single field of it
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+	// Submit validates and submits data to a form
+	Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error)
+	// ExecuteFunction executes a registered dynamic function
+	ExecuteFunction(ctx context.Context, in *ExecuteFunctionRequest, opts ...grpc.CallOption) (*ExecuteFunctionResponse, error)
+}
+
+type formServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFormServiceClient(cc grpc.ClientConnInterface) FormServiceClient {
+	return &formServiceClient{cc}
+}
+
+func (c *formServiceClient) ListForms(ctx context.Context, in *ListFormsRequest, opts ...grpc.CallOption) (*ListFormsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFormsResponse)
+	err := c.cc.Invoke(ctx, FormService_ListForms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *formServiceClient) Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (*RenderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenderResponse)
+	err := c.cc.Invoke(ctx, FormService_Render_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *formServiceClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateResponse)
+	err := c.cc.Invoke(ctx, FormService_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *formServiceClient) Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*SubmitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitResponse)
+	err := c.cc.Invoke(ctx, FormService_Submit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *formServiceClient) ExecuteFunction(ctx context.Context, in *ExecuteFunctionRequest, opts ...grpc.CallOption) (*ExecuteFunctionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExecuteFunctionResponse)
+	err := c.cc.Invoke(ctx, FormService_ExecuteFunction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FormServiceServer is the server API for FormService service.
+// All implementations must embed UnimplementedFormServiceServer
+// for forward compatibility.
+//
+// FormService serves registered forms to other services: listing and rendering them,
+// validating data against them, submitting them, and executing their dynamic functions.
+//
+// Calls carry the HTTP API's headers as metadata, such as x-api-key, x-tenant-id,
+// accept-language, and idempotency-key. Failures have the gRPC code matching the HTTP status
+// of the API, with a google.rpc.ErrorInfo detail in the "smartform" domain whose reason is the
+// API error code, and a google.rpc.BadRequest detail listing the invalid fields of rejected
+// submissions.
+type FormServiceServer interface {
+	// ListForms returns a page of the registered forms
+	ListForms(context.Context, *ListFormsRequest) (*ListFormsResponse, error)
+	// Render returns a form rendered for a context
+	Render(context.Context, *RenderRequest) (*RenderResponse, error)
+	// Validate validates data against a form, or a single field of it
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+	// Submit validates and submits data to a form
+	Submit(context.Context, *SubmitRequest) (*SubmitResponse, error)
+	// ExecuteFunction executes a registered dynamic function
+	ExecuteFunction(context.Context, *ExecuteFunctionRequest) (*ExecuteFunctionResponse, error)
+	mustEmbedUnimplementedFormServiceServer()
+}
+
+// UnimplementedFormServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFormServiceServer struct{}
+
+func (UnimplementedFormServiceServer) ListForms(context.Context, *ListFormsRequest) (*ListFormsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListForms not implemented")
+}
+func (UnimplementedFormServiceServer) Render(context.Context, *RenderRequest) (*RenderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Render not implemented")
+}
+func (UnimplementedFormServiceServer) Validate(context.Context, *ValidateRequest) (*ValidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedFormServiceServer) Submit(context.Context, *SubmitRequest) (*SubmitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Submit not implemented")
+}
+func (UnimplementedFormServiceServer) ExecuteFunction(context.Context, *ExecuteFunctionRequest) (*ExecuteFunctionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExecuteFunction not implemented")
+}
+func (UnimplementedFormServiceServer) mustEmbedUnimplementedFormServiceServer() {}
+func (UnimplementedFormServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeFormServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FormServiceServer will
+// result in compilation errors.
+type UnsafeFormServiceServer interface {
+	mustEmbedUnimplementedFormServiceServer()
+}
+
+func RegisterFormServiceServer(s grpc.ServiceRegistrar, srv FormServiceServer) {
+	// If the following call pancis, it indicates UnimplementedFormServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FormService_ServiceDesc, srv)
+}
+
+func _FormService_ListForms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFormsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FormServiceServer).ListForms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FormService_ListForms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FormServiceServer).ListForms(ctx, req.(*ListFormsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FormService_Render_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FormServiceServer).Render(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FormService_Render_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FormServiceServer).Render(ctx, req.(*RenderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FormService_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FormServiceServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FormService_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FormServiceServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FormService_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FormServiceServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FormService_Submit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FormServiceServer).Submit(ctx, req.(*SubmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FormService_ExecuteFunction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecuteFunctionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FormServiceServer).ExecuteFunction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FormService_ExecuteFunction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FormServiceServer).ExecuteFunction(ctx, req.(*ExecuteFunctionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FormService_ServiceDesc is the grpc.ServiceDesc for FormService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FormService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "smartform.v1.FormService",
+	HandlerType: (*FormServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListForms",
+			Handler:    _FormService_ListForms_Handler,
+		},
+		{
+			MethodName: "Render",
+			Handler:    _FormService_Render_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _FormService_Validate_Handler,
+		},
+		{
+			MethodName: "Submit",
+			Handler:    _FormService_Submit_Handler,
+		},
+		{
+			MethodName: "ExecuteFunction",
+			Handler:    _FormService_ExecuteFunction_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "smartform.proto",
+}
@@ -0,0 +1,166 @@
+package smartform
+
+import "fmt"
+
+// HelpContent is structured help for a field. It is serialized separately from the
+// plain HelpText so clients that only understand HelpText keep working.
+type HelpContent struct {
+	Markdown  string       `json:"markdown,omitempty"`
+	Links     []*HelpLink  `json:"links,omitempty"`
+	Media     []*HelpMedia `json:"media,omitempty"`
+	LearnMore *HelpPanel   `json:"learnMore,omitempty"` // Optional expandable panel
+}
+
+// HelpLink is a link shown with a field's help
+type HelpLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// HelpMedia is an image or video shown with a field's help. Media is stored in file
+// storage and referenced by FileID; URL is filled in when the form is served.
+type HelpMedia struct {
+	Type    HelpMediaType `json:"type"`
+	FileID  string        `json:"fileId,omitempty"`
+	URL     string        `json:"url,omitempty"`
+	Alt     string        `json:"alt,omitempty"`
+	Caption string        `json:"caption,omitempty"`
+}
+
+// HelpPanel is the "learn more" content of a field's help
+type HelpPanel struct {
+	Title    string       `json:"title"`
+	Markdown string       `json:"markdown,omitempty"`
+	Media    []*HelpMedia `json:"media,omitempty"`
+}
+
+// MediaResolver returns the URL at which a stored file is served
+type MediaResolver func(fileID string) (string, error)
+
+// Clone returns a deep copy of the help content
+func (hc *HelpContent) Clone() *HelpContent {
+	if hc == nil {
+		return nil
+	}
+
+	clone := *hc
+	if hc.Links != nil {
+		clone.Links = make([]*HelpLink, len(hc.Links))
+		for i, link := range hc.Links {
+			linkCopy := *link
+			clone.Links[i] = &linkCopy
+		}
+	}
+	clone.Media = cloneHelpMedia(hc.Media)
+	if hc.LearnMore != nil {
+		panel := *hc.LearnMore
+		panel.Media = cloneHelpMedia(hc.LearnMore.Media)
+		clone.LearnMore = &panel
+	}
+	return &clone
+}
+
+// cloneHelpMedia returns a deep copy of a list of help media
+func cloneHelpMedia(media []*HelpMedia) []*HelpMedia {
+	if media == nil {
+		return nil
+	}
+	clone := make([]*HelpMedia, len(media))
+	for i, item := range media {
+		itemCopy := *item
+		clone[i] = &itemCopy
+	}
+	return clone
+}
+
+// ResolveHelpMedia returns a copy of the schema with the URL of every stored help media
+// item resolved from its file ID
+func (fs *FormSchema) ResolveHelpMedia(resolver MediaResolver) (*FormSchema, error) {
+	resolved := fs.Clone()
+
+	resolve := func(media []*HelpMedia) error {
+		for _, item := range media {
+			if item.FileID == "" || item.URL != "" {
+				continue
+			}
+			url, err := resolver(item.FileID)
+			if err != nil {
+				return fmt.Errorf("error resolving help media %s: %w", item.FileID, err)
+			}
+			item.URL = url
+		}
+		return nil
+	}
+
+	var walk func(fields []*Field) error
+	walk = func(fields []*Field) error {
+		for _, field := range fields {
+			if field.Help != nil {
+				if err := resolve(field.Help.Media); err != nil {
+					return err
+				}
+				if field.Help.LearnMore != nil {
+					if err := resolve(field.Help.LearnMore.Media); err != nil {
+						return err
+					}
+				}
+			}
+			if err := walk(field.Nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(resolved.Fields); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// help returns the field's structured help, creating it if necessary
+func (fb *FieldBuilder) help() *HelpContent {
+	if fb.field.Help == nil {
+		fb.field.Help = &HelpContent{}
+	}
+	return fb.field.Help
+}
+
+// Help sets the field's structured help content
+func (fb *FieldBuilder) Help(help *HelpContent) *FieldBuilder {
+	fb.field.Help = help
+	return fb
+}
+
+// HelpMarkdown sets the markdown body of the field's help
+func (fb *FieldBuilder) HelpMarkdown(markdown string) *FieldBuilder {
+	fb.help().Markdown = markdown
+	return fb
+}
+
+// HelpLink adds a link to the field's help
+func (fb *FieldBuilder) HelpLink(label, url string) *FieldBuilder {
+	help := fb.help()
+	help.Links = append(help.Links, &HelpLink{Label: label, URL: url})
+	return fb
+}
+
+// HelpImage adds an image from file storage to the field's help
+func (fb *FieldBuilder) HelpImage(fileID, alt string) *FieldBuilder {
+	help := fb.help()
+	help.Media = append(help.Media, &HelpMedia{Type: HelpMediaTypeImage, FileID: fileID, Alt: alt})
+	return fb
+}
+
+// HelpVideo adds a video from file storage to the field's help
+func (fb *FieldBuilder) HelpVideo(fileID, caption string) *FieldBuilder {
+	help := fb.help()
+	help.Media = append(help.Media, &HelpMedia{Type: HelpMediaTypeVideo, FileID: fileID, Caption: caption})
+	return fb
+}
+
+// LearnMore sets the "learn more" panel of the field's help
+func (fb *FieldBuilder) LearnMore(title, markdown string) *FieldBuilder {
+	fb.help().LearnMore = &HelpPanel{Title: title, Markdown: markdown}
+	return fb
+}
@@ -0,0 +1,44 @@
+package smartform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredHelp(t *testing.T) {
+	schema := NewForm("tax", "Tax").
+		AddField(NewFieldBuilder("vat", FieldTypeText, "VAT Number").
+			HelpText("Your VAT number").
+			HelpMarkdown("Found on your **registration certificate**").
+			HelpLink("VAT guide", "https://example.com/vat").
+			HelpImage("file-123", "Sample certificate").
+			LearnMore("Why we ask", "We need it for invoicing").
+			Build()).
+		Build()
+
+	resolved, err := schema.ResolveHelpMedia(func(fileID string) (string, error) {
+		return "https://cdn.example.com/" + fileID, nil
+	})
+	require.NoError(t, err)
+
+	help := resolved.FindFieldByID("vat").Help
+	assert.Equal(t, "https://cdn.example.com/file-123", help.Media[0].URL)
+	assert.Empty(t, schema.FindFieldByID("vat").Help.Media[0].URL)
+
+	// Plain help text is kept as a separate key for older clients
+	data, err := json.Marshal(resolved.FindFieldByID("vat"))
+	require.NoError(t, err)
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, "Your VAT number", raw["helpText"])
+	assert.Equal(t, "Why we ask", raw["help"].(map[string]interface{})["learnMore"].(map[string]interface{})["title"])
+
+	imported, err := FormSchemaFromJSON(`{"id": "f", "title": "F", "fields": [
+		{"id": "a", "type": "text", "label": "A", "help": {"markdown": "md", "media": [{"type": "video", "fileId": "v1"}]}}
+	]}`)
+	require.NoError(t, err)
+	assert.Equal(t, HelpMediaTypeVideo, imported.FindFieldByID("a").Help.Media[0].Type)
+}
@@ -0,0 +1,53 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// HelpMediaType represents the kind of media embedded in field help
+type HelpMediaType string
+
+// Define help media types
+const (
+	HelpMediaTypeImage HelpMediaType = "image"
+	HelpMediaTypeVideo HelpMediaType = "video"
+)
+
+// Values provides the possible values for HelpMediaType, compatible with entgo.
+func (HelpMediaType) Values() (types []string) {
+	return []string{
+		string(HelpMediaTypeImage),
+		string(HelpMediaTypeVideo),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (m HelpMediaType) MarshalText() ([]byte, error) {
+	return []byte(m), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (m *HelpMediaType) UnmarshalText(text []byte) error {
+	switch HelpMediaType(text) {
+	case HelpMediaTypeImage, HelpMediaTypeVideo:
+		*m = HelpMediaType(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid HelpMediaType: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (m *HelpMediaType) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("HelpMediaType should be a string, got %T", value)
+	}
+	return m.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (m HelpMediaType) Value() (driver.Value, error) {
+	return string(m), nil
+}
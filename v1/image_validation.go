@@ -0,0 +1,179 @@
+package smartform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Decoders for reading the dimensions of uploaded images
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultAspectRatioTolerance is how far, relative to the expected ratio, the aspect ratio of
+// an image may be off unless the rule sets its own tolerance
+const DefaultAspectRatioTolerance = 0.01
+
+// recognizedMediaTypes are the types content sniffing recognizes, so a file whose extension
+// declares one of them must also sniff as it
+var recognizedMediaTypes = map[string]bool{
+	"application/pdf": true,
+	"application/ogg": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/bmp":       true,
+	"image/x-icon":    true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"audio/aiff":      true,
+	"audio/midi":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+	"video/avi":       true,
+}
+
+// genericMediaTypes are sniffed for content that is not recognized more precisely
+var genericMediaTypes = map[string]bool{
+	"application/octet-stream": true,
+	"text/plain":               true,
+	"text/xml":                 true,
+	"application/zip":          true,
+}
+
+// mediaTypeAliases maps the names extensions give some types to the names sniffing gives them
+var mediaTypeAliases = map[string]string{
+	"image/svg+xml":            "text/xml",
+	"application/xml":          "text/xml",
+	"image/vnd.microsoft.icon": "image/x-icon",
+	"audio/wav":                "audio/wave",
+	"audio/x-wav":              "audio/wave",
+	"audio/x-aiff":             "audio/aiff",
+	"audio/mid":                "audio/midi",
+	"audio/ogg":                "application/ogg",
+	"video/ogg":                "application/ogg",
+	"video/x-msvideo":          "video/avi",
+}
+
+// canonicalMediaType returns the name sniffing gives a media type
+func canonicalMediaType(mediaType string) string {
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		return alias
+	}
+	return mediaType
+}
+
+// contentMismatch reports whether the sniffed type of a file contradicts the type its
+// extension declares: the content was recognized as something else, or the extension
+// declares a type that would have been recognized but was not
+func contentMismatch(name, contentType string) bool {
+	declared, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name)))
+	if declared == "" {
+		return false
+	}
+	declared = canonicalMediaType(declared)
+	sniffed := canonicalMediaType(contentType)
+	if declared == sniffed {
+		return false
+	}
+	return !genericMediaTypes[sniffed] || recognizedMediaTypes[declared]
+}
+
+// checkUploadedImage reads the dimensions of an uploaded image from its first bytes into its
+// reference and checks them against the image dimensions rule of the field. Images in formats
+// that cannot be decoded are only rejected when the field has a rule to enforce.
+func checkUploadedImage(field *Field, ref *FileReference, head []byte) error {
+	if !strings.HasPrefix(ref.ContentType, "image/") {
+		return nil
+	}
+	var rule *ValidationRule
+	for _, candidate := range field.ValidationRules {
+		if candidate.Type == ValidationTypeImageDimensions {
+			rule = candidate
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		// A known format whose header ends before the peeked bytes do is corrupt
+		if !errors.Is(err, image.ErrFormat) && len(head) < uploadPeekSize {
+			return &uploadError{status: http.StatusUnsupportedMediaType, message: "File is not a valid image"}
+		}
+		if rule != nil {
+			return &uploadError{status: http.StatusUnprocessableEntity, message: "Image dimensions could not be read"}
+		}
+		return nil
+	}
+
+	ref.Width, ref.Height = config.Width, config.Height
+	if rule != nil && !imageDimensionsAllowed(rule.Parameters, ref.Width, ref.Height) {
+		message := rule.Message
+		if message == "" {
+			message = fmt.Sprintf("Image dimensions %dx%d are not allowed", ref.Width, ref.Height)
+		}
+		return &uploadError{status: http.StatusUnprocessableEntity, message: message}
+	}
+	return nil
+}
+
+// imageDimensionsAllowed checks the dimensions of an image against the parameters of an image
+// dimensions rule: "width" and "height" for exact sizes, "minWidth", "maxWidth", "minHeight",
+// and "maxHeight" for bounds, and "aspectRatio" as a number or a string such as "16:9", within
+// "aspectRatioTolerance"
+func imageDimensionsAllowed(parameters interface{}, width, height int) bool {
+	limits, _ := parameters.(map[string]interface{})
+	w, h := float64(width), float64(height)
+
+	checks := []struct {
+		key   string
+		valid func(limit float64) bool
+	}{
+		{"width", func(limit float64) bool { return w == limit }},
+		{"height", func(limit float64) bool { return h == limit }},
+		{"minWidth", func(limit float64) bool { return w >= limit }},
+		{"maxWidth", func(limit float64) bool { return w <= limit }},
+		{"minHeight", func(limit float64) bool { return h >= limit }},
+		{"maxHeight", func(limit float64) bool { return h <= limit }},
+	}
+	for _, check := range checks {
+		if limit, ok := decodeNumber(limits[check.key]); ok && !check.valid(limit) {
+			return false
+		}
+	}
+
+	ratio, ok := aspectRatio(limits["aspectRatio"])
+	if !ok || h == 0 {
+		return true
+	}
+	tolerance, ok := decodeNumber(limits["aspectRatioTolerance"])
+	if !ok {
+		tolerance = DefaultAspectRatioTolerance
+	}
+	return math.Abs(w/h-ratio) <= ratio*tolerance
+}
+
+// aspectRatio returns the ratio of width to height given as a number or as "16:9" or "16/9"
+func aspectRatio(value interface{}) (float64, bool) {
+	if s, ok := value.(string); ok {
+		for _, separator := range []string{":", "/"} {
+			if width, height, found := strings.Cut(s, separator); found {
+				var w, h float64
+				if _, err := fmt.Sscan(strings.TrimSpace(width), &w); err != nil {
+					return 0, false
+				}
+				if _, err := fmt.Sscan(strings.TrimSpace(height), &h); err != nil || h == 0 {
+					return 0, false
+				}
+				return w / h, w > 0
+			}
+		}
+	}
+	ratio, ok := decodeNumber(value)
+	return ratio, ok && ratio > 0
+}
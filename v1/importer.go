@@ -13,14 +13,27 @@ func FormSchemaFromMap(rawSchema map[string]interface{}) (*FormSchema, error) {
 	return NewJSONImporter().convertToFormSchema(rawSchema)
 }
 
+// FormSchemaFromJSONForEnvironment imports a JSON schema and applies its overlay for the given environment
+func FormSchemaFromJSONForEnvironment(jsonStr, environment string) (*FormSchema, error) {
+	return NewJSONImporter().WithEnvironment(environment).ImportJSON(jsonStr)
+}
+
 // JSONImporter provides functionality to import JSON into form schemas
-type JSONImporter struct{}
+type JSONImporter struct {
+	environment string
+}
 
 // NewJSONImporter creates a new JSON importer
 func NewJSONImporter() *JSONImporter {
 	return &JSONImporter{}
 }
 
+// WithEnvironment makes the importer apply the schema's overlay for the given environment
+func (ji *JSONImporter) WithEnvironment(environment string) *JSONImporter {
+	ji.environment = environment
+	return ji
+}
+
 // ImportJSON imports a JSON string into a FormSchema
 func (ji *JSONImporter) ImportJSON(jsonStr string) (*FormSchema, error) {
 	var rawSchema map[string]interface{}
@@ -36,7 +49,7 @@ func (ji *JSONImporter) ImportJSON(jsonStr string) (*FormSchema, error) {
 		return nil, err
 	}
 
-	return schema, nil
+	return schema.ApplyEnvironment(ji.environment)
 }
 
 // convertToFormSchema converts a raw JSON map to a FormSchema
@@ -60,6 +73,26 @@ func (ji *JSONImporter) convertToFormSchema(rawSchema map[string]interface{}) (*
 		schema.Description = description
 	}
 
+	if version, ok := rawSchema["version"].(string); ok {
+		schema.Version = version
+	}
+
+	// Extract catalog metadata
+	if tagsRaw, ok := rawSchema["tags"].([]interface{}); ok {
+		if err := decodeRaw(tagsRaw, &schema.Tags); err != nil {
+			return nil, fmt.Errorf("invalid tags: %w", err)
+		}
+	}
+	if category, ok := rawSchema["category"].(string); ok {
+		schema.Category = category
+	}
+	if owner, ok := rawSchema["owner"].(string); ok {
+		schema.Owner = owner
+	}
+	if metadata, ok := rawSchema["metadata"].(map[string]interface{}); ok {
+		schema.Metadata = metadata
+	}
+
 	// Extract form type
 	if formTypeStr, ok := rawSchema["type"].(string); ok {
 		schema.Type = FormType(formTypeStr)
@@ -75,6 +108,112 @@ func (ji *JSONImporter) convertToFormSchema(rawSchema map[string]interface{}) (*
 		schema.Properties = props
 	}
 
+	// Extract scripts
+	if scriptsRaw, ok := rawSchema["scripts"].([]interface{}); ok {
+		if err := decodeRaw(scriptsRaw, &schema.Scripts); err != nil {
+			return nil, fmt.Errorf("invalid scripts: %w", err)
+		}
+	}
+
+	// Extract remote fragment references
+	if refsRaw, ok := rawSchema["remoteFragments"].([]interface{}); ok {
+		if err := decodeRaw(refsRaw, &schema.RemoteFragments); err != nil {
+			return nil, fmt.Errorf("invalid remoteFragments: %w", err)
+		}
+	}
+
+	// Extract included fragments
+	if refsRaw, ok := rawSchema["fragments"].([]interface{}); ok {
+		if err := decodeRaw(refsRaw, &schema.Fragments); err != nil {
+			return nil, fmt.Errorf("invalid fragments: %w", err)
+		}
+	}
+
+	// Extract example submissions
+	if examplesRaw, ok := rawSchema["examples"].([]interface{}); ok {
+		if err := decodeRaw(examplesRaw, &schema.Examples); err != nil {
+			return nil, fmt.Errorf("invalid examples: %w", err)
+		}
+	}
+
+	// Extract parent record binding
+	if parentRaw, ok := rawSchema["parent"].(map[string]interface{}); ok {
+		if err := decodeRaw(parentRaw, &schema.Parent); err != nil {
+			return nil, fmt.Errorf("invalid parent: %w", err)
+		}
+	}
+
+	// Extract output mapping
+	if outputRaw, ok := rawSchema["output"].(map[string]interface{}); ok {
+		if err := decodeRaw(outputRaw, &schema.Output); err != nil {
+			return nil, fmt.Errorf("invalid output: %w", err)
+		}
+	}
+
+	// Extract duplicate detection rules
+	if duplicatesRaw, ok := rawSchema["duplicates"].([]interface{}); ok {
+		if err := decodeRaw(duplicatesRaw, &schema.Duplicates); err != nil {
+			return nil, fmt.Errorf("invalid duplicates: %w", err)
+		}
+	}
+
+	// Extract annotations
+	if annotations, ok := rawSchema["annotations"].(map[string]interface{}); ok {
+		schema.Annotations = Annotations(annotations)
+	}
+
+	// Extract the print layout
+	if printRaw, ok := rawSchema["print"].(map[string]interface{}); ok {
+		if err := decodeRaw(printRaw, &schema.Print); err != nil {
+			return nil, fmt.Errorf("invalid print layout: %w", err)
+		}
+	}
+
+	// Extract unique constraints
+	if uniqueRaw, ok := rawSchema["unique"].([]interface{}); ok {
+		if err := decodeRaw(uniqueRaw, &schema.Unique); err != nil {
+			return nil, fmt.Errorf("invalid unique constraints: %w", err)
+		}
+	}
+
+	// Extract submission confirmation
+	if confirmationRaw, ok := rawSchema["confirmation"].(map[string]interface{}); ok {
+		if err := decodeRaw(confirmationRaw, &schema.Confirmation); err != nil {
+			return nil, fmt.Errorf("invalid confirmation: %w", err)
+		}
+	}
+
+	// Extract asynchronous submission
+	if asyncSubmit, ok := rawSchema["asyncSubmit"].(bool); ok {
+		schema.AsyncSubmit = asyncSubmit
+	}
+
+	// Extract draft autosave
+	if autosaveRaw, ok := rawSchema["autosave"].(map[string]interface{}); ok {
+		if err := decodeRaw(autosaveRaw, &schema.Autosave); err != nil {
+			return nil, fmt.Errorf("invalid autosave: %w", err)
+		}
+	}
+
+	// Extract translations
+	if translationsRaw, ok := rawSchema["translations"].(map[string]interface{}); ok {
+		if err := decodeRaw(translationsRaw, &schema.Translations); err != nil {
+			return nil, fmt.Errorf("invalid translations: %w", err)
+		}
+	}
+
+	// Extract environment overlays
+	if environmentsRaw, ok := rawSchema["environments"].(map[string]interface{}); ok {
+		if err := decodeRaw(environmentsRaw, &schema.Environments); err != nil {
+			return nil, fmt.Errorf("invalid environments: %w", err)
+		}
+		for name, overlay := range schema.Environments {
+			if overlay.Environment == "" {
+				overlay.Environment = name
+			}
+		}
+	}
+
 	// Extract fields
 	if fieldsRaw, ok := rawSchema["fields"].([]interface{}); ok {
 		for _, fieldRaw := range fieldsRaw {
@@ -91,9 +230,28 @@ func (ji *JSONImporter) convertToFormSchema(rawSchema map[string]interface{}) (*
 	// Ensure fields have proper order
 	schema.SortFields()
 
+	// Include the fragments as registered now, in the order of the fields
+	if len(schema.Fragments) > 0 {
+		if err := schema.applyFragments(); err != nil {
+			return nil, err
+		}
+		for i, field := range schema.Fields {
+			field.Order = i + 1
+		}
+	}
+
 	return schema, nil
 }
 
+// decodeRaw converts a raw JSON value into a typed target by round-tripping it through JSON
+func decodeRaw(raw interface{}, target interface{}) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
 // convertToField converts a raw JSON map to a Field
 func (ji *JSONImporter) convertToField(rawField map[string]interface{}) (*Field, error) {
 	// Extract required properties
@@ -134,6 +292,50 @@ func (ji *JSONImporter) convertToField(rawField map[string]interface{}) (*Field,
 		field.HelpText = helpText
 	}
 
+	if consentRaw, ok := rawField["consent"].(map[string]interface{}); ok {
+		if err := decodeRaw(consentRaw, &field.Consent); err != nil {
+			return nil, fmt.Errorf("invalid consent for field %s: %w", id, err)
+		}
+	}
+
+	if signatureRaw, ok := rawField["signature"].(map[string]interface{}); ok {
+		if err := decodeRaw(signatureRaw, &field.Signature); err != nil {
+			return nil, fmt.Errorf("invalid signature for field %s: %w", id, err)
+		}
+	}
+
+	if phoneRaw, ok := rawField["phone"].(map[string]interface{}); ok {
+		if err := decodeRaw(phoneRaw, &field.Phone); err != nil {
+			return nil, fmt.Errorf("invalid phone for field %s: %w", id, err)
+		}
+	}
+
+	if currencyRaw, ok := rawField["currency"].(map[string]interface{}); ok {
+		if err := decodeRaw(currencyRaw, &field.Currency); err != nil {
+			return nil, fmt.Errorf("invalid currency for field %s: %w", id, err)
+		}
+	}
+
+	if timeZone, ok := rawField["timeZone"].(string); ok {
+		field.TimeZone = timeZone
+	}
+
+	if dateRangeRaw, ok := rawField["dateRange"].(map[string]interface{}); ok {
+		if err := decodeRaw(dateRangeRaw, &field.DateRange); err != nil {
+			return nil, fmt.Errorf("invalid date range for field %s: %w", id, err)
+		}
+	}
+
+	if verify, ok := rawField["verify"].(bool); ok {
+		field.Verify = verify
+	}
+
+	if helpRaw, ok := rawField["help"].(map[string]interface{}); ok {
+		if err := decodeRaw(helpRaw, &field.Help); err != nil {
+			return nil, fmt.Errorf("invalid help for field %s: %w", id, err)
+		}
+	}
+
 	if order, ok := rawField["order"].(float64); ok {
 		field.Order = int(order)
 	}
@@ -142,6 +344,18 @@ func (ji *JSONImporter) convertToField(rawField map[string]interface{}) (*Field,
 	if defaultValue, exists := rawField["defaultValue"]; exists {
 		field.DefaultValue = defaultValue
 	}
+	if computedValue, ok := rawField["computedValue"].(string); ok {
+		field.ComputedValue = computedValue
+	}
+
+	if annotations, ok := rawField["annotations"].(map[string]interface{}); ok {
+		field.Annotations = Annotations(annotations)
+	}
+	if printRaw, ok := rawField["print"].(map[string]interface{}); ok {
+		if err := decodeRaw(printRaw, &field.Print); err != nil {
+			return nil, fmt.Errorf("invalid print hints for field %s: %w", id, err)
+		}
+	}
 
 	// Extract properties
 	if props, ok := rawField["properties"].(map[string]interface{}); ok {
@@ -349,6 +563,15 @@ func (ji *JSONImporter) convertToOption(rawOpt map[string]interface{}) (*Option,
 		option.Icon = icon
 	}
 
+	// Extract the condition the option is offered under
+	if conditionRaw, ok := rawOpt["condition"].(map[string]interface{}); ok {
+		condition, err := ji.convertToCondition(conditionRaw)
+		if err != nil {
+			return nil, err
+		}
+		option.Condition = condition
+	}
+
 	return option, nil
 }
 
@@ -384,6 +607,40 @@ func (ji *JSONImporter) convertToDynamicSource(rawSource map[string]interface{})
 		source.LabelPath = labelPath
 	}
 
+	// Extract windowed source
+	if windowSource, ok := rawSource["windowSource"].(string); ok {
+		source.WindowSource = windowSource
+	}
+	if pageSize, ok := rawSource["pageSize"].(float64); ok {
+		source.PageSize = int(pageSize)
+	}
+
+	// Extract preview options
+	if mockRaw, ok := rawSource["mock"].([]interface{}); ok {
+		for _, optRaw := range mockRaw {
+			if optMap, ok := optRaw.(map[string]interface{}); ok {
+				option, err := ji.convertToOption(optMap)
+				if err != nil {
+					return nil, err
+				}
+				source.Mock = append(source.Mock, option)
+			}
+		}
+	}
+
+	// Extract fallback options
+	if fallbackRaw, ok := rawSource["fallback"].([]interface{}); ok {
+		for _, optRaw := range fallbackRaw {
+			if optMap, ok := optRaw.(map[string]interface{}); ok {
+				option, err := ji.convertToOption(optMap)
+				if err != nil {
+					return nil, err
+				}
+				source.Fallback = append(source.Fallback, option)
+			}
+		}
+	}
+
 	// Extract headers
 	if headersRaw, ok := rawSource["headers"].(map[string]interface{}); ok {
 		source.Headers = make(map[string]string)
@@ -408,6 +665,20 @@ func (ji *JSONImporter) convertToDynamicSource(rawSource map[string]interface{})
 		}
 	}
 
+	// Extract connected service
+	if serviceID, ok := rawSource["serviceId"].(string); ok {
+		source.ServiceID = serviceID
+	}
+
+	// Extract cache policy
+	if cacheRaw, ok := rawSource["cache"].(map[string]interface{}); ok {
+		var policy OptionCachePolicy
+		if err := decodeRaw(cacheRaw, &policy); err != nil {
+			return nil, fmt.Errorf("invalid cache: %w", err)
+		}
+		source.CachePolicy = &policy
+	}
+
 	return source, nil
 }
 
@@ -0,0 +1,425 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// JSONSchemaDialect is the JSON Schema draft documents are exported in
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// ToJSONSchema converts the schema into a JSON Schema (draft 2020-12) document describing valid
+// submissions, so payloads can be checked with standard tooling and client types generated.
+// Field types, static options, validation rules, and required, requiredIf, and visibility
+// conditions are translated. Rules that need the server, such as custom, unique, and dynamic
+// option checks, and conditions written as expressions are left out.
+func (fs *FormSchema) ToJSONSchema() map[string]interface{} {
+	document := fieldsToJSONSchema(fs.Fields)
+	document["$schema"] = JSONSchemaDialect
+	if fs.ID != "" {
+		document["$id"] = fs.ID
+	}
+	if fs.Title != "" {
+		document["title"] = fs.Title
+	}
+	if fs.Description != "" {
+		document["description"] = fs.Description
+	}
+	if fs.Version != "" {
+		document["$comment"] = "Form version " + fs.Version
+	}
+	return document
+}
+
+// fieldsToJSONSchema describes an object holding the given fields
+func fieldsToJSONSchema(fields []*Field) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	conditionals := []interface{}{}
+
+	for _, field := range fields {
+		// Sections and display fields hold no submitted data
+		if (field.Type == FieldTypeSection && len(field.Nested) == 0) || field.Type == FieldTypeDisplay {
+			continue
+		}
+		properties[field.ID] = fieldToJSONSchema(field)
+
+		// Hidden fields are not validated, so their requirements only apply while visible
+		var visible map[string]interface{}
+		if field.Visible != nil {
+			visible = conditionToJSONSchema(field.Visible)
+		}
+
+		if field.Required {
+			switch {
+			case field.Visible == nil:
+				required = append(required, field.ID)
+			case visible != nil:
+				conditionals = append(conditionals, map[string]interface{}{
+					"if":   visible,
+					"then": map[string]interface{}{"required": []interface{}{field.ID}},
+				})
+			}
+		}
+
+		if field.RequiredIf != nil {
+			condition := conditionToJSONSchema(field.RequiredIf)
+			if condition != nil && field.Visible != nil {
+				if visible == nil {
+					condition = nil
+				} else {
+					condition = map[string]interface{}{"allOf": []interface{}{visible, condition}}
+				}
+			}
+			if condition != nil {
+				conditionals = append(conditionals, map[string]interface{}{
+					"if":   condition,
+					"then": map[string]interface{}{"required": []interface{}{field.ID}},
+				})
+			}
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if len(conditionals) > 0 {
+		schema["allOf"] = conditionals
+	}
+	return schema
+}
+
+// fieldToJSONSchema describes the value of a field
+func fieldToJSONSchema(field *Field) map[string]interface{} {
+	schema := map[string]interface{}{}
+
+	switch field.Type {
+	case FieldTypeText, FieldTypeTextarea, FieldTypePassword, FieldTypeRichText, FieldTypeColor:
+		schema["type"] = "string"
+	case FieldTypeEmail:
+		schema["type"] = "string"
+		schema["format"] = "email"
+	case FieldTypePhone:
+		schema["type"] = "string"
+	case FieldTypeDate:
+		schema["type"] = "string"
+		schema["format"] = "date"
+	case FieldTypeTime, FieldTypeDateTime:
+		format := "date-time"
+		if field.Type == FieldTypeTime {
+			format = "time"
+		}
+		if field.TimeZone == "" {
+			schema["type"] = "string"
+			schema["format"] = format
+			break
+		}
+		// Either a time in the field's time zone, or the time with the zone it was entered in
+		schema["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"value":    map[string]interface{}{"type": "string"},
+					"timeZone": map[string]interface{}{"type": "string"},
+				},
+				"required": []interface{}{"value"},
+			},
+		}
+	case FieldTypeDateRange:
+		schema["type"] = "object"
+		schema["properties"] = map[string]interface{}{
+			"start": map[string]interface{}{"type": "string", "format": "date"},
+			"end":   map[string]interface{}{"type": "string", "format": "date"},
+		}
+		schema["required"] = []interface{}{"start", "end"}
+	case FieldTypeNumber, FieldTypeSlider, FieldTypeRating:
+		schema["type"] = "number"
+	case FieldTypeCheckbox, FieldTypeSwitch:
+		if field.Options == nil {
+			schema["type"] = "boolean"
+		} else {
+			schema["type"] = "array"
+			schema["uniqueItems"] = true
+			schema["items"] = optionsToJSONSchema(field)
+		}
+	case FieldTypeSelect, FieldTypeRadio:
+		for key, value := range optionsToJSONSchema(field) {
+			schema[key] = value
+		}
+	case FieldTypeMultiSelect:
+		schema["type"] = "array"
+		schema["uniqueItems"] = true
+		schema["items"] = optionsToJSONSchema(field)
+	case FieldTypeGroup, FieldTypeObject, FieldTypeSection:
+		schema = fieldsToJSONSchema(field.Nested)
+	case FieldTypeArray:
+		schema["type"] = "array"
+		schema["items"] = fieldsToJSONSchema(field.Nested)
+		metadata := field.ArrayMetadata()
+		if metadata.MinItems != nil {
+			schema["minItems"] = *metadata.MinItems
+		}
+		if metadata.MaxItems != nil {
+			schema["maxItems"] = *metadata.MaxItems
+		}
+	case FieldTypeOneOf, FieldTypeAnyOf:
+		alternatives := []interface{}{}
+		for _, nested := range field.Nested {
+			alternatives = append(alternatives, fieldToJSONSchema(nested))
+		}
+		if len(alternatives) > 0 {
+			schema[string(field.Type)] = alternatives
+		}
+	case FieldTypeConsent:
+		// Either a plain acceptance or the accepted text version and hash
+		schema["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "boolean"},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"accepted": map[string]interface{}{"type": "boolean"},
+					"version":  map[string]interface{}{"type": "string"},
+					"hash":     map[string]interface{}{"type": "string"},
+				},
+				"required": []interface{}{"accepted"},
+			},
+		}
+	case FieldTypeSignature:
+		// Either a PNG image, as a data URL or base64, or the image or strokes with their format
+		point := map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"x": map[string]interface{}{"type": "number"},
+				"y": map[string]interface{}{"type": "number"},
+				"t": map[string]interface{}{"type": "integer"},
+			},
+			"required": []interface{}{"x", "y"},
+		}
+		schema["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"format": map[string]interface{}{"type": "string", "enum": []interface{}{"png", "points"}},
+					"data":   map[string]interface{}{"type": "string"},
+					"strokes": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"type": "array", "items": point},
+					},
+				},
+			},
+		}
+	case FieldTypeCurrency:
+		// Either a decimal string, or the amount in minor units or as a decimal with its currency
+		currency := map[string]interface{}{"type": "string"}
+		if field.Currency != nil && len(field.Currency.Currencies) > 0 {
+			codes := make([]interface{}, len(field.Currency.Currencies))
+			for i, code := range field.Currency.Currencies {
+				codes[i] = code
+			}
+			currency["enum"] = codes
+		}
+		schema["oneOf"] = []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"amount":   map[string]interface{}{"type": "integer"},
+					"value":    map[string]interface{}{"type": "string"},
+					"currency": currency,
+				},
+			},
+		}
+	}
+
+	if field.Label != "" {
+		schema["title"] = field.Label
+	}
+	if field.HelpText != "" {
+		schema["description"] = field.HelpText
+	}
+	if field.DefaultValue != nil {
+		schema["default"] = field.DefaultValue
+	}
+
+	for _, rule := range field.ValidationRules {
+		applyRuleToJSONSchema(schema, rule)
+	}
+
+	// Empty strings and lists do not satisfy required fields
+	if field.Required {
+		switch schema["type"] {
+		case "string":
+			if _, ok := schema["minLength"]; !ok {
+				schema["minLength"] = 1
+			}
+		case "array":
+			if _, ok := schema["minItems"]; !ok {
+				schema["minItems"] = 1
+			}
+		}
+	}
+
+	return schema
+}
+
+// optionsToJSONSchema describes a single option value of a field
+func optionsToJSONSchema(field *Field) map[string]interface{} {
+	schema := map[string]interface{}{}
+	if dataType := declaredOptionValueType(field); dataType != "" {
+		schema["type"] = dataType
+	}
+
+	// Conditional options are listed whatever their conditions, which the schema cannot express
+	if field.Options != nil && (field.Options.Type == OptionsTypeStatic || field.Options.Type == OptionsTypeConditional) &&
+		len(field.Options.Static) > 0 {
+		values := make([]interface{}, len(field.Options.Static))
+		for i, option := range field.Options.Static {
+			values[i] = option.Value
+		}
+		schema["enum"] = values
+	}
+	return schema
+}
+
+// applyRuleToJSONSchema adds the keyword matching a validation rule
+func applyRuleToJSONSchema(schema map[string]interface{}, rule *ValidationRule) {
+	switch rule.Type {
+	case ValidationTypeMinLength:
+		if n, ok := rule.Parameters.(float64); ok {
+			schema["minLength"] = int(n)
+		}
+	case ValidationTypeMaxLength:
+		if n, ok := rule.Parameters.(float64); ok {
+			schema["maxLength"] = int(n)
+		}
+	case ValidationTypePattern:
+		if pattern, ok := rule.Parameters.(string); ok {
+			schema["pattern"] = pattern
+		}
+	case ValidationTypeMin:
+		if n, ok := rule.Parameters.(float64); ok {
+			schema["minimum"] = n
+		}
+	case ValidationTypeMax:
+		if n, ok := rule.Parameters.(float64); ok {
+			schema["maximum"] = n
+		}
+	case ValidationTypeEmail:
+		schema["format"] = "email"
+	case ValidationTypeURL:
+		schema["format"] = "uri"
+	}
+}
+
+// conditionToJSONSchema translates a condition into a schema the data matches when the
+// condition holds, or nil when it cannot be expressed
+func conditionToJSONSchema(condition *Condition) map[string]interface{} {
+	switch condition.Type {
+	case ConditionTypeSimple:
+		var constraint map[string]interface{}
+		switch condition.Operator {
+		case "eq", "neq":
+			constraint = map[string]interface{}{"const": condition.Value}
+		case "contains", "startsWith", "endsWith":
+			value, ok := condition.Value.(string)
+			if !ok {
+				return nil
+			}
+			pattern := regexp.QuoteMeta(value)
+			switch condition.Operator {
+			case "startsWith":
+				pattern = "^" + pattern
+			case "endsWith":
+				pattern = pattern + "$"
+			}
+			constraint = map[string]interface{}{"type": "string", "pattern": pattern}
+		case "gt", "gte", "lt", "lte":
+			value, ok := condition.Value.(float64)
+			if !ok {
+				return nil
+			}
+			keyword := map[string]string{
+				"gt": "exclusiveMinimum", "gte": "minimum", "lt": "exclusiveMaximum", "lte": "maximum",
+			}[condition.Operator]
+			constraint = map[string]interface{}{"type": "number", keyword: value}
+		default:
+			return nil
+		}
+
+		schema := pathConstraint(condition.Field, constraint)
+		if condition.Operator == "neq" {
+			return map[string]interface{}{"not": schema}
+		}
+		return schema
+
+	case ConditionTypeExists:
+		return pathConstraint(condition.Field, map[string]interface{}{
+			"not": map[string]interface{}{"enum": []interface{}{nil, "", false, 0, []interface{}{}, map[string]interface{}{}}},
+		})
+
+	case ConditionTypeAnd, ConditionTypeOr:
+		subschemas := []interface{}{}
+		for _, sub := range condition.Conditions {
+			subschema := conditionToJSONSchema(sub)
+			if subschema == nil {
+				return nil
+			}
+			subschemas = append(subschemas, subschema)
+		}
+		if condition.Type == ConditionTypeAnd {
+			return map[string]interface{}{"allOf": subschemas}
+		}
+		return map[string]interface{}{"anyOf": subschemas}
+
+	case ConditionTypeNot:
+		if len(condition.Conditions) == 0 {
+			return nil
+		}
+		subschema := conditionToJSONSchema(condition.Conditions[0])
+		if subschema == nil {
+			return nil
+		}
+		return map[string]interface{}{"not": subschema}
+
+	default:
+		return nil
+	}
+}
+
+// pathConstraint requires the value at a dotted path to be present and match a constraint
+func pathConstraint(path string, constraint map[string]interface{}) map[string]interface{} {
+	parts := strings.Split(path, ".")
+	schema := constraint
+	for i := len(parts) - 1; i >= 0; i-- {
+		schema = map[string]interface{}{
+			"properties": map[string]interface{}{parts[i]: schema},
+			"required":   []interface{}{parts[i]},
+		}
+	}
+	return schema
+}
+
+// handleFormJSONSchema handles requests for the JSON Schema of a form's submissions
+func (ah *APIHandler) handleFormJSONSchema(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchemaVersion(formID, r.URL.Query().Get(VersionQueryParam))
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	_ = json.NewEncoder(w).Encode(schema.ToJSONSchema())
+}
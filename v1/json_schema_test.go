@@ -0,0 +1,74 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToJSONSchema(t *testing.T) {
+	schema := NewForm("signup", "Sign up").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		AddField(NewFieldBuilder("age", FieldTypeNumber, "Age").
+			ValidateMin(18, "Too young").Build()).
+		AddField(NewFieldBuilder("plan", FieldTypeSelect, "Plan").
+			WithStaticOptions([]*Option{NewOption("free", "Free"), NewOption("pro", "Pro")}).Build()).
+		AddField(NewFieldBuilder("company", FieldTypeText, "Company").
+			RequiredWhenEquals("plan", "pro").Build()).
+		Build()
+
+	document := schema.ToJSONSchema()
+	assert.Equal(t, JSONSchemaDialect, document["$schema"])
+	assert.Equal(t, "object", document["type"])
+	assert.Equal(t, []interface{}{"email"}, document["required"])
+
+	properties := document["properties"].(map[string]interface{})
+	email := properties["email"].(map[string]interface{})
+	assert.Equal(t, "email", email["format"])
+	assert.Equal(t, 1, email["minLength"])
+	assert.Equal(t, 18.0, properties["age"].(map[string]interface{})["minimum"])
+	assert.Equal(t, []interface{}{"free", "pro"}, properties["plan"].(map[string]interface{})["enum"])
+
+	conditionals := document["allOf"].([]interface{})
+	require.Len(t, conditionals, 1)
+	conditional := conditionals[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"required": []interface{}{"company"}}, conditional["then"])
+	assert.Equal(t, map[string]interface{}{
+		"properties": map[string]interface{}{"plan": map[string]interface{}{"const": "pro"}},
+		"required":   []interface{}{"plan"},
+	}, conditional["if"])
+
+	_, err := json.Marshal(document)
+	require.NoError(t, err)
+}
+
+func TestNestedFieldsToJSONSchema(t *testing.T) {
+	line := NewFieldBuilder("sku", FieldTypeText, "SKU").Required(true).Build()
+	lines := NewFieldBuilder("lines", FieldTypeArray, "Lines").Build()
+	lines.Nested = []*Field{line}
+
+	document := NewForm("order", "Order").AddField(lines).Build().ToJSONSchema()
+	items := document["properties"].(map[string]interface{})["lines"].(map[string]interface{})["items"].(map[string]interface{})
+	assert.Equal(t, "object", items["type"])
+	assert.Equal(t, []interface{}{"sku"}, items["required"])
+}
+
+func TestJSONSchemaEndpoint(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/contact/jsonschema", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/schema+json", rec.Header().Get("Content-Type"))
+	var document map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &document))
+	assert.Equal(t, "contact", document["$id"])
+}
@@ -0,0 +1,349 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// LintSeverity is how serious a lint issue is
+type LintSeverity string
+
+// Severities of lint issues
+const (
+	LintSeverityError   LintSeverity = "error"   // The form does not work as its author meant
+	LintSeverityWarning LintSeverity = "warning" // The form works, but is likely not what its author meant
+)
+
+// Names of the built-in lint rules
+const (
+	LintRuleUnreachableField         = "unreachableField"         // A visibility condition can never be true
+	LintRuleRequiredHidden           = "requiredHidden"           // A required field users cannot fill in
+	LintRuleOptionsOnNonSelect       = "optionsOnNonSelect"       // Options on a field that does not offer them
+	LintRuleMissingValidationMessage = "missingValidationMessage" // A validation rule without a message
+	LintRuleConflictingRequired      = "conflictingRequired"      // RequiredIf that has no effect or only holds while hidden
+)
+
+// LintRule checks each field of a schema, returning a message for each issue found. Rules are
+// configured by choosing the ones passed to Lint, adjusting their severity, or adding custom ones.
+type LintRule struct {
+	Name     string
+	Severity LintSeverity
+	Check    func(target *LintTarget) []string
+}
+
+// LintTarget is a field being linted
+type LintTarget struct {
+	Schema *FormSchema
+	Field  *Field
+	Path   string // Path of the field, e.g. "address.street"
+
+	paths map[string]*Field
+	ids   map[string][]*Field
+}
+
+// Resolve returns the field a condition or reference names: a field by its path, or by an ID
+// no other field has
+func (lt *LintTarget) Resolve(name string) *Field {
+	if field, ok := lt.paths[name]; ok {
+		return field
+	}
+	if candidates := lt.ids[name]; len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// LintIssue is an issue a lint rule found on a field
+type LintIssue struct {
+	Rule     string       `json:"rule"`
+	Severity LintSeverity `json:"severity"`
+	FieldID  string       `json:"fieldId"` // Path of the field
+	Message  string       `json:"message"`
+}
+
+// LintReport lists the issues found in a schema
+type LintReport struct {
+	FormID string       `json:"formId"`
+	Issues []*LintIssue `json:"issues"`
+}
+
+// HasErrors reports whether an issue of error severity was found
+func (lr *LintReport) HasErrors() bool {
+	for _, issue := range lr.Issues {
+		if issue.Severity == LintSeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultLintRules returns new copies of the built-in lint rules
+func DefaultLintRules() []*LintRule {
+	return []*LintRule{
+		{Name: LintRuleUnreachableField, Severity: LintSeverityError, Check: lintUnreachableField},
+		{Name: LintRuleRequiredHidden, Severity: LintSeverityError, Check: lintRequiredHidden},
+		{Name: LintRuleOptionsOnNonSelect, Severity: LintSeverityWarning, Check: lintOptionsOnNonSelect},
+		{Name: LintRuleMissingValidationMessage, Severity: LintSeverityWarning, Check: lintMissingValidationMessage},
+		{Name: LintRuleConflictingRequired, Severity: LintSeverityWarning, Check: lintConflictingRequired},
+	}
+}
+
+// Lint checks every field of a schema with the rules, or with the default rules when none are
+// given, and reports the issues found in field order
+func Lint(schema *FormSchema, rules ...*LintRule) *LintReport {
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+
+	type located struct {
+		field *Field
+		path  string
+	}
+	fields := []located{}
+	paths := map[string]*Field{}
+	ids := map[string][]*Field{}
+	var collect func(fields []*Field, container string)
+	collect = func(nested []*Field, container string) {
+		for _, field := range nested {
+			path := joinPath(container, field.ID)
+			fields = append(fields, located{field: field, path: path})
+			paths[path] = field
+			ids[field.ID] = append(ids[field.ID], field)
+			collect(field.Nested, path)
+		}
+	}
+	collect(schema.Fields, "")
+
+	report := &LintReport{FormID: schema.ID, Issues: []*LintIssue{}}
+	for _, f := range fields {
+		target := &LintTarget{Schema: schema, Field: f.field, Path: f.path, paths: paths, ids: ids}
+		for _, rule := range rules {
+			for _, message := range rule.Check(target) {
+				report.Issues = append(report.Issues, &LintIssue{
+					Rule:     rule.Name,
+					Severity: rule.Severity,
+					FieldID:  f.path,
+					Message:  message,
+				})
+			}
+		}
+	}
+	return report
+}
+
+// Lint checks the schema with the rules, or with the default rules when none are given
+func (fs *FormSchema) Lint(rules ...*LintRule) *LintReport {
+	return Lint(fs, rules...)
+}
+
+func lintUnreachableField(target *LintTarget) []string {
+	if target.neverTrue(target.Field.Visible) {
+		return []string{fmt.Sprintf("field %s is never visible: its visibility condition can never be true", target.Path)}
+	}
+	return nil
+}
+
+func lintRequiredHidden(target *LintTarget) []string {
+	field := target.Field
+	if !field.Required || field.DefaultValue != nil || field.ComputedValue != "" || len(field.DefaultWhen) > 0 {
+		return nil
+	}
+	if field.Type == FieldTypeHidden {
+		return []string{fmt.Sprintf("field %s is required, but hidden and without a value", target.Path)}
+	}
+	return nil
+}
+
+// optionFieldTypes are the field types that offer options
+var optionFieldTypes = map[FieldType]bool{
+	FieldTypeSelect:      true,
+	FieldTypeMultiSelect: true,
+	FieldTypeRadio:       true,
+	FieldTypeCheckbox:    true,
+	FieldTypeCustom:      true,
+}
+
+func lintOptionsOnNonSelect(target *LintTarget) []string {
+	if target.Field.Options != nil && !optionFieldTypes[target.Field.Type] {
+		return []string{fmt.Sprintf("field %s has options, but %s fields do not offer them", target.Path, target.Field.Type)}
+	}
+	return nil
+}
+
+func lintMissingValidationMessage(target *LintTarget) []string {
+	messages := []string{}
+	for _, rule := range target.Field.ValidationRules {
+		if strings.TrimSpace(rule.Message) == "" {
+			messages = append(messages, fmt.Sprintf("%s validation of field %s has no message", rule.Type, target.Path))
+		}
+	}
+	return messages
+}
+
+func lintConflictingRequired(target *LintTarget) []string {
+	field := target.Field
+	if field.RequiredIf == nil {
+		return nil
+	}
+	if field.Required {
+		return []string{fmt.Sprintf("field %s is always required, so its requiredIf condition has no effect", target.Path)}
+	}
+	if field.Visible != nil && target.neverTrue(And(field.Visible, field.RequiredIf).Build()) {
+		return []string{fmt.Sprintf("field %s is only required while hidden, so it is never required", target.Path)}
+	}
+	return nil
+}
+
+// neverTrue reports whether a condition can never hold, because it compares a field with
+// static options to a value none of them has, requires a field to equal two different values,
+// or is the expression false
+func (lt *LintTarget) neverTrue(condition *Condition) bool {
+	if condition == nil {
+		return false
+	}
+
+	switch condition.Type {
+	case ConditionTypeSimple:
+		values, ok := lt.optionValues(condition.Field)
+		if !ok {
+			return false
+		}
+		switch condition.Operator {
+		case "eq", "equals", "==":
+			return !containsValue(values, condition.Value)
+		case "in":
+			candidates, ok := condition.Value.([]interface{})
+			if !ok {
+				return false
+			}
+			for _, candidate := range candidates {
+				if containsValue(values, candidate) {
+					return false
+				}
+			}
+			return true
+		}
+		return false
+
+	case ConditionTypeAnd:
+		required := map[string]interface{}{}
+		for _, sub := range conjuncts(condition) {
+			if lt.neverTrue(sub) {
+				return true
+			}
+			if sub.Type != ConditionTypeSimple || (sub.Operator != "eq" && sub.Operator != "equals" && sub.Operator != "==") {
+				continue
+			}
+			if value, ok := required[sub.Field]; ok && fmt.Sprintf("%v", value) != fmt.Sprintf("%v", sub.Value) {
+				return true
+			}
+			required[sub.Field] = sub.Value
+		}
+		return false
+
+	case ConditionTypeOr:
+		if len(condition.Conditions) == 0 {
+			return false
+		}
+		for _, sub := range condition.Conditions {
+			if !lt.neverTrue(sub) {
+				return false
+			}
+		}
+		return true
+
+	case ConditionTypeExpression:
+		expression := strings.TrimSpace(condition.Expression)
+		if strings.HasPrefix(expression, "${") && strings.HasSuffix(expression, "}") {
+			expression = strings.TrimSpace(expression[2 : len(expression)-1])
+		}
+		return expression == "false"
+	}
+	return false
+}
+
+// conjuncts returns the conditions an AND condition requires, looking into nested ANDs
+func conjuncts(condition *Condition) []*Condition {
+	terms := []*Condition{}
+	for _, sub := range condition.Conditions {
+		if sub != nil && sub.Type == ConditionTypeAnd {
+			terms = append(terms, conjuncts(sub)...)
+		} else {
+			terms = append(terms, sub)
+		}
+	}
+	return terms
+}
+
+// optionValues returns the values of the static options of the single-choice field a
+// condition names, if it has any
+func (lt *LintTarget) optionValues(name string) ([]interface{}, bool) {
+	field := lt.Resolve(name)
+	if field == nil || (field.Type != FieldTypeSelect && field.Type != FieldTypeRadio) ||
+		field.Options == nil || field.Options.Type != OptionsTypeStatic || len(field.Options.Static) == 0 {
+		return nil, false
+	}
+	values := make([]interface{}, len(field.Options.Static))
+	for i, option := range field.Options.Static {
+		values[i] = option.Value
+	}
+	return values, true
+}
+
+// containsValue reports whether a value is among values, comparing them as text so that
+// numbers decoded from JSON match
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if fmt.Sprintf("%v", candidate) == fmt.Sprintf("%v", value) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLintRules sets the rules the lint endpoint checks forms with, instead of the default rules
+func (ah *APIHandler) SetLintRules(rules ...*LintRule) {
+	ah.lintRules = rules
+}
+
+// handleFormLint handles requests for the lint issues of a form. The rules query parameter
+// limits the rules checked to a comma-separated list of names.
+func (ah *APIHandler) handleFormLint(w http.ResponseWriter, r *http.Request, formID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	schema, ok := ah.GetSchemaVersion(formID, r.URL.Query().Get(VersionQueryParam))
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	rules := ah.lintRules
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+	if names := r.URL.Query().Get("rules"); names != "" {
+		selected := []*LintRule{}
+		for _, name := range strings.Split(names, ",") {
+			found := false
+			for _, rule := range rules {
+				if rule.Name == strings.TrimSpace(name) {
+					selected = append(selected, rule)
+					found = true
+				}
+			}
+			if !found {
+				writeError(w, NewAPIError(http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("Unknown lint rule %s", name)))
+				return
+			}
+		}
+		rules = selected
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Lint(schema, rules...))
+}
@@ -0,0 +1,78 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	form := NewForm("account", "Account")
+	form.SelectField("plan", "Plan").WithStaticOptions([]*Option{{Value: "free", Label: "Free"}, {Value: "pro", Label: "Pro"}})
+	form.TextField("company", "Company").VisibleWhenEquals("plan", "business")
+	form.TextField("vat", "VAT number").
+		VisibleWhen(When("plan").Equals("pro").Build()).
+		RequiredIf(When("plan").Equals("free").Build())
+	form.TextField("seats", "Seats").Required(true).RequiredWhenEquals("plan", "pro")
+	form.HiddenField("tenant", nil).Required(true)
+	form.HiddenField("source", "web").Required(true)
+	form.TextField("color", "Color").WithStaticOptions([]*Option{{Value: "red", Label: "Red"}})
+	form.TextField("nickname", "Nickname").ValidateMinLength(2, "")
+	form.TextField("debug", "Debug").VisibleWhen(&Condition{Type: ConditionTypeExpression, Expression: "${false}"})
+	schema := form.Build()
+
+	report := Lint(schema)
+	issues := map[string]string{}
+	for _, issue := range report.Issues {
+		issues[issue.FieldID] = issue.Rule
+	}
+	assert.Equal(t, map[string]string{
+		"company":  LintRuleUnreachableField,
+		"vat":      LintRuleConflictingRequired,
+		"seats":    LintRuleConflictingRequired,
+		"tenant":   LintRuleRequiredHidden,
+		"color":    LintRuleOptionsOnNonSelect,
+		"nickname": LintRuleMissingValidationMessage,
+		"debug":    LintRuleUnreachableField,
+	}, issues)
+	assert.Len(t, report.Issues, 7)
+	assert.True(t, report.HasErrors())
+
+	// Rules are configured by choosing them and their severity
+	rules := DefaultLintRules()
+	for _, rule := range rules {
+		rule.Severity = LintSeverityWarning
+	}
+	custom := &LintRule{Name: "labelled", Severity: LintSeverityWarning, Check: func(target *LintTarget) []string {
+		if target.Field.Label == "" {
+			return []string{"field " + target.Path + " has no label"}
+		}
+		return nil
+	}}
+	report = schema.Lint(append(rules, custom)...)
+	assert.False(t, report.HasErrors())
+	assert.Len(t, report.Issues, 9)
+
+	// Over HTTP
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(schema))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/account/lint?rules=unreachableField", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response LintReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "account", response.FormID)
+	require.Len(t, response.Issues, 2)
+	assert.Equal(t, LintSeverityError, response.Issues[0].Severity)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/account/lint?rules=noSuchRule", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
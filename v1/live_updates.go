@@ -0,0 +1,432 @@
+package smartform
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Types of messages exchanged over the live update channel
+const (
+	LiveMessageChange = "change" // Sent by the client with field changes
+	LiveMessageUpdate = "update" // Pushed by the server with what the changes affected
+	LiveMessageError  = "error"  // Pushed by the server when a message cannot be handled
+)
+
+// DefaultLiveChangeLimit is how many changes a live update connection may send by default
+var DefaultLiveChangeLimit = RateLimit{Requests: 20, Per: time.Second}
+
+// Timeouts of live update connections. Clients must answer the pings sent every
+// livePingInterval, or send changes, within liveReadTimeout.
+const (
+	liveWriteTimeout = 10 * time.Second
+	liveReadTimeout  = 60 * time.Second
+	livePingInterval = liveReadTimeout * 9 / 10
+)
+
+// LiveChange is a message from the client carrying field changes as a JSON merge patch:
+// nested objects are merged and null clears a value
+type LiveChange struct {
+	Type    string                 `json:"type"`
+	Changes map[string]interface{} `json:"changes"`
+}
+
+// LiveUpdate is pushed to the client after each change. It only lists what differs from the
+// previous update; the first update describes the whole form.
+type LiveUpdate struct {
+	Type        string                        `json:"type"`
+	Seq         int                           `json:"seq"`                   // Number of changes applied so far
+	Values      map[string]interface{}        `json:"values,omitempty"`      // Recomputed dynamic values
+	Options     map[string][]*Option          `json:"options,omitempty"`     // Refreshed options of RefreshOn fields
+	Visibility  map[string]bool               `json:"visibility,omitempty"`  // Fields shown or hidden
+	Enabled     map[string]bool               `json:"enabled,omitempty"`     // Fields enabled or disabled
+	Constraints map[string]*NumberConstraints `json:"constraints,omitempty"` // Resolved bounds and steps of number fields
+	Errors      map[string]string             `json:"errors,omitempty"`      // Fields that could not be updated
+	Message     string                        `json:"message,omitempty"`     // Set on error messages
+}
+
+// liveSession tracks the form state of one live update connection
+type liveSession struct {
+	handler     *APIHandler
+	request     *http.Request
+	access      *AccessContext
+	schema      *FormSchema
+	sessionID   string
+	state       map[string]interface{}
+	values      map[string]interface{}
+	visibility  map[string]bool
+	enabled     map[string]bool
+	constraints map[string]*NumberConstraints
+	seq         int
+}
+
+// SetLiveChangeLimit caps how many changes each live update connection may send. Changes over
+// the limit are held back until the connection may send again, so clients typing fast are
+// slowed down rather than losing changes.
+func (ah *APIHandler) SetLiveChangeLimit(limit RateLimit) {
+	ah.liveChangeLimit = limit
+}
+
+// liveUpgrader upgrades live update requests; cross-origin requests are refused unless their
+// origin is allowed by the CORS configuration
+func (ah *APIHandler) liveUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{CheckOrigin: ah.checkLiveOrigin}
+}
+
+// handleLiveUpdates handles WebSocket connections over which clients stream field changes and
+// the server pushes recomputed dynamic values, refreshed options, and visibility changes
+func (ah *APIHandler) handleLiveUpdates(w http.ResponseWriter, r *http.Request) {
+	formID := getPathParam(r.URL.Path, "/api/ws/")
+	if formID == "" {
+		writeError(w, badRequest("Form ID is required"))
+		return
+	}
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	conn, err := ah.liveUpgrader().Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	// Messages are bounded like request bodies, and connections whose client went away are
+	// closed once they stop answering pings
+	limits := ah.limits()
+	conn.SetReadLimit(limits.bodySize("/api/ws/"))
+	_ = conn.SetReadDeadline(time.Now().Add(liveReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(liveReadTimeout))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(livePingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout)) != nil {
+					return
+				}
+			}
+		}
+	}()
+	write := func(update *LiveUpdate) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		return conn.WriteJSON(update)
+	}
+	limiter := newRateLimiter(ah.liveChangeLimit)
+
+	// Connections naming a form session start from its saved answers
+	sessionID := requestSessionID(r, r.URL.Query().Get("sessionId"))
+	live := &liveSession{
+		handler:     ah,
+		request:     r,
+		access:      ah.accessContext(r),
+		schema:      schema,
+		sessionID:   sessionID,
+		state:       cloneMap(ah.sessionFormState(sessionID, map[string]interface{}{})),
+		values:      map[string]interface{}{},
+		visibility:  map[string]bool{},
+		enabled:     map[string]bool{},
+		constraints: map[string]*NumberConstraints{},
+	}
+	if err := write(live.update(nil)); err != nil {
+		return
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(liveReadTimeout))
+
+		var message LiveChange
+		body := &limitedBody{ReadCloser: io.NopCloser(bytes.NewReader(data)), limits: limits}
+		if err := decodeRequest(body, &message); err != nil {
+			if write(&LiveUpdate{Type: LiveMessageError, Seq: live.seq, Message: err.Error()}) != nil {
+				return
+			}
+			continue
+		}
+		if message.Type != LiveMessageChange {
+			if write(&LiveUpdate{Type: LiveMessageError, Seq: live.seq, Message: fmt.Sprintf("unsupported message type: %q", message.Type)}) != nil {
+				return
+			}
+			continue
+		}
+
+		for {
+			wait, ok := limiter.allow("")
+			if ok {
+				break
+			}
+			time.Sleep(wait)
+		}
+		live.seq++
+		live.state = mergePatch(live.state, message.Changes)
+		if err := write(live.update(message.Changes)); err != nil {
+			return
+		}
+	}
+}
+
+// update recomputes the form after changes and returns what differs from the previous update
+func (ls *liveSession) update(changes map[string]interface{}) *LiveUpdate {
+	update := &LiveUpdate{
+		Type:        LiveMessageUpdate,
+		Seq:         ls.seq,
+		Values:      map[string]interface{}{},
+		Options:     map[string][]*Option{},
+		Visibility:  map[string]bool{},
+		Enabled:     map[string]bool{},
+		Constraints: map[string]*NumberConstraints{},
+		Errors:      map[string]string{},
+	}
+
+	changed := map[string]bool{}
+	markChanged(changes, "", changed)
+
+	// Dynamic values come first, so conditions and options see them
+	state := cloneMap(ls.handler.withRenderContext(ls.request, ls.state))
+	ls.computeExpressions(state, changed, update)
+	ls.computeValues(ls.schema.Fields, "", state, changed, update)
+
+	validator := NewValidator(ls.schema)
+	ls.evaluateConditions(validator, ls.schema.Fields, "", state, update)
+	ls.resolveConstraints(state, update)
+	if changes != nil {
+		ls.refreshOptions(ls.schema.Fields, "", state, changed, update)
+	}
+
+	ls.redact(update)
+	return update
+}
+
+// redact leaves out of an update the fields the caller may not see, and masks the values of
+// sensitive fields, as forms and submissions are shown over HTTP
+func (ls *liveSession) redact(update *LiveUpdate) {
+	for path, value := range update.Values {
+		readable, sensitive := ls.fieldAccess(path)
+		if !readable {
+			delete(update.Values, path)
+		} else if sensitive && value != nil && value != "" {
+			update.Values[path] = SensitiveMask
+		}
+	}
+	for path := range update.Options {
+		if readable, _ := ls.fieldAccess(path); !readable {
+			delete(update.Options, path)
+		}
+	}
+	for path := range update.Visibility {
+		if readable, _ := ls.fieldAccess(path); !readable {
+			delete(update.Visibility, path)
+		}
+	}
+	for path := range update.Enabled {
+		if readable, _ := ls.fieldAccess(path); !readable {
+			delete(update.Enabled, path)
+		}
+	}
+	for path := range update.Constraints {
+		if readable, _ := ls.fieldAccess(path); !readable {
+			delete(update.Constraints, path)
+		}
+	}
+	for path := range update.Errors {
+		if readable, _ := ls.fieldAccess(path); !readable {
+			delete(update.Errors, path)
+		}
+	}
+}
+
+// fieldAccess reports whether the caller may see the field at a data path, and whether its
+// value is sensitive, looking at every field the path goes through
+func (ls *liveSession) fieldAccess(path string) (readable, sensitive bool) {
+	fields := ls.schema.Fields
+	for _, part := range strings.Split(pathIndexPattern.ReplaceAllString(path, ""), ".") {
+		var field *Field
+		for _, candidate := range fields {
+			if candidate.ID == part {
+				field = candidate
+				break
+			}
+		}
+		if field == nil {
+			break
+		}
+		if !ls.access.CanRead(field) {
+			return false, sensitive
+		}
+		sensitive = sensitive || field.Sensitive
+		fields = field.Nested
+	}
+	return true, sensitive
+}
+
+// markChanged records the paths of every value in changes, including nested ones
+func markChanged(changes map[string]interface{}, prefix string, changed map[string]bool) {
+	for key, value := range changes {
+		path := joinPath(prefix, key)
+		changed[path] = true
+		if nested, ok := value.(map[string]interface{}); ok {
+			markChanged(nested, path, changed)
+		}
+	}
+}
+
+// computeValues recomputes the values of fields computed by dynamic functions, recording the
+// ones that changed
+func (ls *liveSession) computeValues(fields []*Field, prefix string, state map[string]interface{}, changed map[string]bool, update *LiveUpdate) {
+	service := ls.handler.dynamicFunctionService
+	for _, field := range fields {
+		path := joinPath(prefix, field.ID)
+		if len(field.Nested) > 0 && field.Type != FieldTypeArray {
+			ls.computeValues(field.Nested, path, state, changed, update)
+			continue
+		}
+
+		computed, _ := field.Properties["dynamicValue"].(bool)
+		config := fieldDynamicFunction(field)
+		if !computed || config == nil || service == nil {
+			continue
+		}
+		value, err := config.ExecuteWithFormState(service, ls.handler.functionState(ls.request, ls.sessionID, state))
+		if err != nil {
+			update.Errors[path] = err.Error()
+			continue
+		}
+		_ = setValueByPath(state, path, value)
+		ls.recordValue(path, value, changed, update)
+	}
+}
+
+// computeExpressions recomputes the computed values of fields in dependency order, recording
+// the ones that changed
+func (ls *liveSession) computeExpressions(state map[string]interface{}, changed map[string]bool, update *LiveUpdate) {
+	// Schemas whose computed values depend on each other in a cycle are never registered
+	computed, err := ls.schema.computeValuesInto(state)
+	if err != nil {
+		return
+	}
+	for path, message := range computed.Errors {
+		update.Errors[path] = message
+	}
+	for path, value := range computed.Values {
+		ls.recordValue(path, value, changed, update)
+	}
+}
+
+// recordValue records a recomputed value when it differs from the last one sent
+func (ls *liveSession) recordValue(path string, value interface{}, changed map[string]bool, update *LiveUpdate) {
+	if previous, seen := ls.values[path]; seen && reflect.DeepEqual(previous, value) {
+		return
+	}
+	ls.values[path] = value
+	update.Values[path] = value
+	changed[path] = true
+}
+
+// evaluateConditions evaluates the visibility and enablement of fields, recording the ones
+// that changed
+func (ls *liveSession) evaluateConditions(validator *Validator, fields []*Field, prefix string, state map[string]interface{}, update *LiveUpdate) {
+	for _, field := range fields {
+		path := joinPath(prefix, field.ID)
+
+		visible := field.Visible == nil || validator.evaluateCondition(field.Visible, state)
+		if previous, seen := ls.visibility[path]; !seen || previous != visible {
+			ls.visibility[path] = visible
+			update.Visibility[path] = visible
+		}
+
+		enabled := field.Enabled == nil || validator.evaluateCondition(field.Enabled, state)
+		if previous, seen := ls.enabled[path]; !seen || previous != enabled {
+			ls.enabled[path] = enabled
+			update.Enabled[path] = enabled
+		}
+
+		if field.Type != FieldTypeArray {
+			ls.evaluateConditions(validator, field.Nested, path, state, update)
+		}
+	}
+}
+
+// resolveConstraints resolves the bounds and steps of number fields, recording the ones that
+// changed
+func (ls *liveSession) resolveConstraints(state map[string]interface{}, update *LiveUpdate) {
+	for path, constraints := range NewStateEngine(ls.schema).NumberConstraints(state) {
+		if previous, seen := ls.constraints[path]; seen && reflect.DeepEqual(previous, constraints) {
+			continue
+		}
+		ls.constraints[path] = constraints
+		update.Constraints[path] = constraints
+	}
+}
+
+// refreshOptions re-fetches the options of fields that refresh on one of the changed fields
+func (ls *liveSession) refreshOptions(fields []*Field, prefix string, state map[string]interface{}, changed map[string]bool, update *LiveUpdate) {
+	for _, field := range fields {
+		path := joinPath(prefix, field.ID)
+		if field.Type != FieldTypeArray {
+			ls.refreshOptions(field.Nested, path, state, changed, update)
+		}
+
+		if field.Options == nil || field.Options.DynamicSource == nil || isWindowed(field) {
+			continue
+		}
+		source := field.Options.DynamicSource
+		refresh := false
+		for _, fieldID := range source.RefreshOn {
+			refresh = refresh || changed[fieldID]
+		}
+		if !refresh {
+			continue
+		}
+
+		var options []*Option
+		var err error
+		if source.Type == "function" {
+			options, err = ls.handler.getOptionsFromFunction(source.FunctionName, source.Parameters, state)
+		} else {
+			options, err = ls.handler.optionService.GetDynamicOptionsFor(ls.handler.requestIdentity(ls.request), source, state)
+		}
+		if err != nil {
+			update.Errors[path] = err.Error()
+			continue
+		}
+		if options == nil {
+			options = []*Option{}
+		}
+		update.Options[path] = options
+	}
+}
+
+// fieldDynamicFunction returns the dynamic function configured on a field, whether it was
+// set with the builder or imported from JSON
+func fieldDynamicFunction(field *Field) *DynamicFieldConfig {
+	switch config := field.Properties["dynamicFunction"].(type) {
+	case *DynamicFieldConfig:
+		return config
+	case map[string]interface{}:
+		var decoded DynamicFieldConfig
+		if err := decodeRaw(config, &decoded); err == nil && decoded.FunctionName != "" {
+			return &decoded
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package smartform
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveUpdates(t *testing.T) {
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("orderTotal", func(args, state map[string]interface{}) (interface{}, error) {
+		quantity, _ := state["quantity"].(float64)
+		price, _ := state["price"].(float64)
+		return quantity * price, nil
+	})
+	functions.RegisterFunction("cities", func(args, state map[string]interface{}) (interface{}, error) {
+		if state["country"] == "FR" {
+			return []*Option{NewOption("paris", "Paris")}, nil
+		}
+		return []*Option{}, nil
+	})
+
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(functions)
+	total := NewFieldBuilder("total", FieldTypeNumber, "Total")
+	total.DynamicValue("orderTotal")
+	city := NewFieldBuilder("city", FieldTypeSelect, "City").Build()
+	city.Options = NewOptionsBuilder().Dynamic().FromFunction("cities").RefreshOn("country").Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("quantity", FieldTypeNumber, "Quantity").ValidateMaxExpression("${stock}", "Not enough stock").Build()).
+		AddField(NewFieldBuilder("price", FieldTypeNumber, "Price").Build()).
+		AddField(total.Build()).
+		AddField(NewDisplayFieldBuilder("subtotal", "Subtotal").Expression("${multiply(price, quantity)}").Build()).
+		AddField(NewFieldBuilder("freight", FieldTypeText, "Freight").VisibleWhenGreaterThan("total", 100.0).Build()).
+		AddField(NewFieldBuilder("country", FieldTypeText, "Country").Build()).
+		AddField(city).
+		Build()))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws/order", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var update LiveUpdate
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, LiveMessageUpdate, update.Type)
+	assert.Equal(t, 0.0, update.Values["total"])
+	assert.Equal(t, false, update.Visibility["freight"])
+	assert.Equal(t, true, update.Visibility["quantity"])
+
+	// A change pushes the recomputed total and the fields it shows
+	require.NoError(t, conn.WriteJSON(&LiveChange{Type: LiveMessageChange, Changes: map[string]interface{}{"quantity": 3, "price": 50, "stock": 5}}))
+	update = LiveUpdate{}
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, 1, update.Seq)
+	assert.Equal(t, 150.0, update.Values["total"])
+	assert.EqualValues(t, 150, update.Values["subtotal"])
+	assert.Equal(t, map[string]bool{"freight": true}, update.Visibility)
+	assert.Empty(t, update.Options)
+	require.NotNil(t, update.Constraints["quantity"])
+	assert.Equal(t, 5.0, *update.Constraints["quantity"].Max)
+
+	// Options refresh when a field they depend on changes; unchanged values are not repeated
+	require.NoError(t, conn.WriteJSON(&LiveChange{Type: LiveMessageChange, Changes: map[string]interface{}{"country": "FR"}}))
+	update = LiveUpdate{}
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, 2, update.Seq)
+	assert.Empty(t, update.Values)
+	assert.Empty(t, update.Visibility)
+	assert.Empty(t, update.Constraints)
+	require.Len(t, update.Options["city"], 1)
+	assert.Equal(t, "Paris", update.Options["city"][0].Label)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type": "reset"}`)))
+	update = LiveUpdate{}
+	require.NoError(t, conn.ReadJSON(&update))
+	assert.Equal(t, LiveMessageError, update.Type)
+	assert.Contains(t, update.Message, "reset")
+}
+
+func TestLiveUpdatesRespectFieldAccess(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetAccessResolver(func(r *http.Request) *AccessContext {
+		return &AccessContext{Roles: r.URL.Query()["role"]}
+	})
+	form := NewForm("payroll", "Payroll")
+	form.NumberField("hours", "Hours")
+	form.NumberField("salary", "Salary").ComputedValue("${multiply(hours, 50)}").ReadRoles("hr")
+	form.TextField("bonus", "Bonus").VisibleWhenGreaterThan("hours", 40.0).ReadRoles("hr")
+	form.TextField("reference", "Reference").ComputedValue("REF-${hours}").Sensitive(true)
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+
+	server := httptest.NewServer(handler.Handler())
+	defer server.Close()
+	connect := func(query string) *LiveUpdate {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws/payroll"+query, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		var update LiveUpdate
+		require.NoError(t, conn.ReadJSON(&update))
+		require.NoError(t, conn.WriteJSON(&LiveChange{Type: LiveMessageChange, Changes: map[string]interface{}{"hours": 45}}))
+		update = LiveUpdate{}
+		require.NoError(t, conn.ReadJSON(&update))
+		return &update
+	}
+
+	update := connect("")
+	assert.NotContains(t, update.Values, "salary")
+	assert.NotContains(t, update.Visibility, "bonus")
+	assert.Equal(t, SensitiveMask, update.Values["reference"])
+
+	update = connect("?role=hr")
+	assert.EqualValues(t, 2250, update.Values["salary"])
+	assert.Equal(t, true, update.Visibility["bonus"])
+	assert.Equal(t, SensitiveMask, update.Values["reference"])
+}
+
+func TestLiveUpdateLimits(t *testing.T) {
+	handler := NewAPIHandler(WithRequestLimits(RequestLimits{EndpointBodySizes: map[string]int64{"/api/ws/": 256}}))
+	handler.SetLiveChangeLimit(RateLimit{Requests: 1, Per: 50 * time.Millisecond})
+	require.NoError(t, handler.RegisterSchema(NewForm("notes", "Notes").
+		AddField(NewFieldBuilder("text", FieldTypeText, "Text").Build()).
+		Build()))
+	server := httptest.NewServer(handler.Handler())
+	defer server.Close()
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws/notes", nil)
+		require.NoError(t, err)
+		var update LiveUpdate
+		require.NoError(t, conn.ReadJSON(&update))
+		return conn
+	}
+
+	// Changes over the limit are held back, not dropped
+	conn := dial()
+	defer conn.Close()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, conn.WriteJSON(&LiveChange{Type: LiveMessageChange, Changes: map[string]interface{}{"text": i}}))
+	}
+	for i := 1; i <= 3; i++ {
+		var update LiveUpdate
+		require.NoError(t, conn.ReadJSON(&update))
+		assert.Equal(t, i, update.Seq)
+	}
+	assert.True(t, time.Since(start) >= 100*time.Millisecond, "changes over the limit are held back")
+
+	// Messages over the size limit close the connection
+	large := dial()
+	defer large.Close()
+	require.NoError(t, large.WriteJSON(&LiveChange{Type: LiveMessageChange, Changes: map[string]interface{}{"text": strings.Repeat("x", 512)}}))
+	var update LiveUpdate
+	err := large.ReadJSON(&update)
+	require.Error(t, err)
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), err.Error())
+}
@@ -0,0 +1,32 @@
+package smartform
+
+import "context"
+
+// Notification channels
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+)
+
+// Notification is a message sent to a person filling in a form
+type Notification struct {
+	Channel string                 `json:"channel"` // email or sms
+	To      string                 `json:"to"`
+	Subject string                 `json:"subject,omitempty"`
+	Body    string                 `json:"body"`
+	FormID  string                 `json:"formId"`
+	Data    map[string]interface{} `json:"data,omitempty"` // Values for templated messages
+}
+
+// Notifier delivers notifications, e.g. through an email or SMS provider
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, notification *Notification) error
+
+// Notify calls the function
+func (f NotifierFunc) Notify(ctx context.Context, notification *Notification) error {
+	return f(ctx, notification)
+}
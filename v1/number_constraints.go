@@ -0,0 +1,175 @@
+package smartform
+
+import (
+	"math"
+)
+
+// stepTolerance absorbs floating point error when checking that a number is on a step
+const stepTolerance = 1e-9
+
+// NumberConstraints are the bounds and step of a number field, with the template expressions
+// of dynamic ones resolved against the form state. Unset constraints are nil.
+type NumberConstraints struct {
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+	Step *float64 `json:"step,omitempty"`
+}
+
+// ValidateMinExpression adds a minimum value validation rule whose bound is a template
+// expression over other fields, such as "${deposit}"
+func (fb *FieldBuilder) ValidateMinExpression(expression string, message string) *FieldBuilder {
+	return fb.AddValidation(&ValidationRule{
+		Type:       ValidationTypeMin,
+		Message:    message,
+		Parameters: expression,
+	})
+}
+
+// ValidateMaxExpression adds a maximum value validation rule whose bound is a template
+// expression over other fields, such as "${stockLevel}"
+func (fb *FieldBuilder) ValidateMaxExpression(expression string, message string) *FieldBuilder {
+	return fb.AddValidation(&ValidationRule{
+		Type:       ValidationTypeMax,
+		Message:    message,
+		Parameters: expression,
+	})
+}
+
+// ValidateStep adds a step validation rule: the value must be the minimum, or 0 without one,
+// plus a multiple of step
+func (fb *FieldBuilder) ValidateStep(step float64, message string) *FieldBuilder {
+	return fb.AddValidation(&ValidationRule{
+		Type:       ValidationTypeStep,
+		Message:    message,
+		Parameters: step,
+	})
+}
+
+// ValidateStepExpression adds a step validation rule whose step is a template expression over
+// other fields, such as "${packSize}"
+func (fb *FieldBuilder) ValidateStepExpression(expression string, message string) *FieldBuilder {
+	return fb.AddValidation(&ValidationRule{
+		Type:       ValidationTypeStep,
+		Message:    message,
+		Parameters: expression,
+	})
+}
+
+// Step creates a step validation rule
+func (vb *ValidationBuilder) Step(step float64, message string) *ValidationRule {
+	return &ValidationRule{
+		Type:       ValidationTypeStep,
+		Message:    message,
+		Parameters: step,
+	}
+}
+
+// NumberConstraints resolves the min, max, and step rules of every number field against the
+// form state, keyed by field path, so clients can apply bounds that depend on other fields
+func (se *StateEngine) NumberConstraints(state map[string]interface{}) map[string]*NumberConstraints {
+	if state == nil {
+		state = map[string]interface{}{}
+	}
+	constraints := map[string]*NumberConstraints{}
+	se.collectNumberConstraints(se.schema.Fields, "", state, constraints)
+	return constraints
+}
+
+// collectNumberConstraints resolves the constraints of fields and the fields nested in them
+func (se *StateEngine) collectNumberConstraints(fields []*Field, prefix string, state map[string]interface{}, constraints map[string]*NumberConstraints) {
+	for _, field := range fields {
+		path := joinPath(prefix, field.ID)
+		if field.Type != FieldTypeArray {
+			se.collectNumberConstraints(field.Nested, path, state, constraints)
+		}
+		if c := se.validator.numberConstraints(field, state); c != nil {
+			constraints[path] = c
+		}
+	}
+}
+
+// numberConstraints resolves the min, max, and step rules of a field. It returns nil when the
+// field has none that resolve.
+func (v *Validator) numberConstraints(field *Field, data map[string]interface{}) *NumberConstraints {
+	constraints := &NumberConstraints{}
+	found := false
+	for _, rule := range field.ValidationRules {
+		if rule.Type != ValidationTypeMin && rule.Type != ValidationTypeMax && rule.Type != ValidationTypeStep {
+			continue
+		}
+		bound, ok := v.ruleNumber(rule, field, data)
+		if !ok {
+			continue
+		}
+		switch rule.Type {
+		case ValidationTypeMin:
+			constraints.Min = &bound
+		case ValidationTypeMax:
+			constraints.Max = &bound
+		case ValidationTypeStep:
+			constraints.Step = &bound
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return constraints
+}
+
+// validateNumberRule checks a number against a min, max, or step rule. Rules whose template
+// expression does not resolve to a number, e.g. because the field it refers to is empty, are
+// not enforced.
+func (v *Validator) validateNumberRule(rule *ValidationRule, value interface{}, field *Field, data map[string]interface{}) (bool, string) {
+	var num float64
+	switch n := value.(type) {
+	case float64:
+		num = n
+	case int:
+		num = float64(n)
+	default:
+		return false, rule.Message
+	}
+
+	bound, ok := v.ruleNumber(rule, field, data)
+	if !ok {
+		return true, ""
+	}
+
+	switch rule.Type {
+	case ValidationTypeMin:
+		return num >= bound, rule.Message
+	case ValidationTypeMax:
+		return num <= bound, rule.Message
+	default:
+		if bound <= 0 {
+			return true, ""
+		}
+		base := 0.0
+		if constraints := v.numberConstraints(field, data); constraints != nil && constraints.Min != nil {
+			base = *constraints.Min
+		}
+		steps := (num - base) / bound
+		return math.Abs(steps-math.Round(steps)) < stepTolerance, rule.Message
+	}
+}
+
+// ruleNumber returns the number a rule is parameterized with, evaluating template expressions
+// against the form data. Rules without parameters compare against 0.
+func (v *Validator) ruleNumber(rule *ValidationRule, field *Field, data map[string]interface{}) (float64, bool) {
+	switch param := rule.Parameters.(type) {
+	case nil:
+		return 0, true
+	case string:
+		if v.schema == nil {
+			return 0, false
+		}
+		resolved := v.schema.GetTemplateResolver().ResolveFieldValue(field.ID, param, data)
+		if !resolved.Resolved {
+			return 0, false
+		}
+		return decodeNumber(resolved.Value)
+	default:
+		return decodeNumber(param)
+	}
+}
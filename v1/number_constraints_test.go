@@ -0,0 +1,71 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orderForm limits the quantity of an order by the stock left and the pack size
+func orderForm() *FormSchema {
+	return NewForm("order", "Order").
+		AddField(NewFieldBuilder("stockLevel", FieldTypeNumber, "Stock").Build()).
+		AddField(NewFieldBuilder("packSize", FieldTypeNumber, "Pack size").Build()).
+		AddField(NewFieldBuilder("quantity", FieldTypeNumber, "Quantity").
+			ValidateMin(2, "Order at least 2").
+			ValidateMaxExpression("${stockLevel}", "Not enough stock").
+			ValidateStepExpression("${packSize}", "Order whole packs").
+			Build()).
+		Build()
+}
+
+func TestDynamicNumberConstraints(t *testing.T) {
+	schema := orderForm()
+	validate := func(data map[string]interface{}) []string {
+		var rules []string
+		for _, err := range schema.Validate(data).Errors {
+			rules = append(rules, err.RuleType)
+		}
+		return rules
+	}
+
+	assert.Empty(t, validate(map[string]interface{}{"stockLevel": 20.0, "packSize": 6.0, "quantity": 14.0}))
+	assert.Equal(t, []string{"max"}, validate(map[string]interface{}{"stockLevel": 10.0, "packSize": 6.0, "quantity": 14.0}))
+	assert.Equal(t, []string{"step"}, validate(map[string]interface{}{"stockLevel": 20.0, "packSize": 6.0, "quantity": 12.0}))
+	assert.Equal(t, []string{"min"}, validate(map[string]interface{}{"stockLevel": 20.0, "quantity": 1.0}))
+
+	// Bounds referring to empty fields are not enforced
+	assert.Empty(t, validate(map[string]interface{}{"quantity": 13.0}))
+
+	constraints := NewStateEngine(schema).NumberConstraints(map[string]interface{}{"stockLevel": 20.0, "packSize": 6.0})
+	require.Contains(t, constraints, "quantity")
+	assert.Equal(t, 2.0, *constraints["quantity"].Min)
+	assert.Equal(t, 20.0, *constraints["quantity"].Max)
+	assert.Equal(t, 6.0, *constraints["quantity"].Step)
+
+	constraints = NewStateEngine(schema).NumberConstraints(nil)
+	assert.Nil(t, constraints["quantity"].Max)
+	assert.Nil(t, constraints["quantity"].Step)
+}
+
+func TestNumberConstraintsEndpoint(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(orderForm()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/forms/order/constraints",
+		strings.NewReader(`{"state": {"stockLevel": 8}}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var constraints map[string]*NumberConstraints
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &constraints))
+	require.NotNil(t, constraints["quantity"].Max)
+	assert.Equal(t, 8.0, *constraints["quantity"].Max)
+	assert.Nil(t, constraints["quantity"].Step)
+}
@@ -0,0 +1,391 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification documents are generated in
+const OpenAPIVersion = "3.1.0"
+
+// OpenAPIInfo describes the deployment in generated OpenAPI documents
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// SetOpenAPIInfo sets how generated OpenAPI documents describe the deployment
+func (ah *APIHandler) SetOpenAPIInfo(info OpenAPIInfo) {
+	ah.openAPIInfo = info
+}
+
+// GenerateOpenAPISpec generates an OpenAPI 3.1 document describing the endpoints of every
+// registered form, so clients can generate SDKs against the deployment. Each form gets its own
+// render, JSON Schema, validate, submit, and compute operations, whose request bodies are the
+// JSON Schema of the form's submissions, together with the shapes of validation results and
+// errors shared by all forms.
+func (ah *APIHandler) GenerateOpenAPISpec() map[string]interface{} {
+	ah.schemasLock.RLock()
+	schemas := make([]*FormSchema, 0, len(ah.schemas))
+	for _, schema := range ah.schemas {
+		schemas = append(schemas, schema)
+	}
+	ah.schemasLock.RUnlock()
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].ID < schemas[j].ID })
+
+	info := ah.openAPIInfo
+	if info.Title == "" {
+		info.Title = "Smartform API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+
+	components := openAPISharedSchemas()
+	paths := map[string]interface{}{
+		"/api/forms": map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": "listForms",
+				"summary":     "List registered forms",
+				"parameters":  formListParameters(),
+				"responses": map[string]interface{}{
+					"200": jsonResponse("A page of the registered forms", schemaRef("FormList")),
+				},
+			},
+		},
+	}
+
+	for _, schema := range schemas {
+		name := openAPIName(schema.ID)
+		submission := schema.ToJSONSchema()
+		delete(submission, "$schema")
+		delete(submission, "$id")
+		components[name+"Submission"] = submission
+
+		tags := []interface{}{schema.ID}
+		submissionBody := map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaRef(name + "Submission")},
+			},
+		}
+
+		paths["/api/forms/"+schema.ID] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": "get" + name + "Form",
+				"summary":     "Render " + formTitle(schema),
+				"tags":        tags,
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The rendered form", schemaRef("Form")),
+					"404": errorResponse("Form not found"),
+				},
+			},
+		}
+		paths["/api/forms/"+schema.ID+"/jsonschema"] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": "get" + name + "JSONSchema",
+				"summary":     "Get the JSON Schema of submissions of " + formTitle(schema),
+				"tags":        tags,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "JSON Schema of submissions",
+						"content": map[string]interface{}{
+							"application/schema+json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+						},
+					},
+					"404": errorResponse("Form not found"),
+				},
+			},
+		}
+		paths["/api/validate/"+schema.ID] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "validate" + name,
+				"summary":     "Validate a submission of " + formTitle(schema),
+				"tags":        tags,
+				"requestBody": submissionBody,
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Validation result", schemaRef("ValidationResult")),
+					"400": jsonResponse("Malformed request body", schemaRef("RequestError")),
+					"404": errorResponse("Form not found"),
+				},
+			},
+		}
+		paths["/api/submit/"+schema.ID] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "submit" + name,
+				"summary":     "Submit " + formTitle(schema),
+				"tags":        tags,
+				"requestBody": submissionBody,
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Submission accepted", schemaRef("SubmitResponse")),
+					"400": jsonResponse("Invalid submission", map[string]interface{}{
+						"oneOf": []interface{}{schemaRef("ValidationFailure"), schemaRef("RequestError")},
+					}),
+					"404": errorResponse("Form not found"),
+					"409": jsonResponse("Submission conflicts with an earlier one", schemaRef("SubmitError")),
+					"500": jsonResponse("Submission could not be processed", schemaRef("SubmitError")),
+				},
+			},
+		}
+		paths["/api/compute/"+schema.ID] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": "compute" + name,
+				"summary":     "Compute the computed values of " + formTitle(schema),
+				"tags":        tags,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{
+							"type":       "object",
+							"properties": map[string]interface{}{"state": schemaRef(name + "Submission")},
+						}},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("Computed values", schemaRef("ComputedValues")),
+					"400": jsonResponse("Malformed request body", schemaRef("RequestError")),
+					"404": errorResponse("Form not found"),
+				},
+			},
+		}
+	}
+
+	document := map[string]interface{}{
+		"openapi":           OpenAPIVersion,
+		"jsonSchemaDialect": JSONSchemaDialect,
+		"info":              info,
+		"paths":             paths,
+		"components":        map[string]interface{}{"schemas": components},
+	}
+	return document
+}
+
+// formListParameters describes the query parameters of the form list
+func formListParameters() []interface{} {
+	query := func(name, description string, schema map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"name": name, "in": "query", "description": description, "schema": schema}
+	}
+	return []interface{}{
+		query("search", "Text the ID, title, or description must contain, ignoring case", map[string]interface{}{"type": "string"}),
+		query("tag", "Tags the forms must all carry; repeated or separated by commas", map[string]interface{}{
+			"type": "array", "items": map[string]interface{}{"type": "string"},
+		}),
+		query("category", "Category the forms must be in, ignoring case", map[string]interface{}{"type": "string"}),
+		query("owner", "Owner the forms must have, ignoring case", map[string]interface{}{"type": "string"}),
+		query("sort", "Order of the forms", map[string]interface{}{
+			"type": "string", "default": FormListSortID,
+			"enum": []interface{}{FormListSortID, FormListSortTitle, FormListSortVersion, FormListSortCategory},
+		}),
+		query("sortDir", "Direction of the order", map[string]interface{}{
+			"type": "string", "enum": []interface{}{"asc", "desc"}, "default": "asc",
+		}),
+		query("page", "1-based page number", map[string]interface{}{"type": "integer", "minimum": 1, "default": 1}),
+		query("limit", "Forms per page", map[string]interface{}{
+			"type": "integer", "minimum": 1, "maximum": MaxFormListLimit, "default": DefaultFormListLimit,
+		}),
+	}
+}
+
+// openAPISharedSchemas describes the bodies shared by the operations of every form
+func openAPISharedSchemas() map[string]interface{} {
+	str := map[string]interface{}{"type": "string"}
+	boolean := map[string]interface{}{"type": "boolean"}
+	object := map[string]interface{}{"type": "object"}
+	integer := map[string]interface{}{"type": "integer"}
+	arrayOf := func(items interface{}) map[string]interface{} {
+		return map[string]interface{}{"type": "array", "items": items}
+	}
+	objectOf := func(properties map[string]interface{}, required ...interface{}) map[string]interface{} {
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	errorCodes := []interface{}{
+		ErrorCodeBadRequest, ErrorCodeInvalidRequest, ErrorCodeValidationFailed, ErrorCodeMethodNotAllowed,
+		ErrorCodeNotFound, ErrorCodeFormNotFound, ErrorCodeFieldNotFound, ErrorCodeUnauthorized,
+		ErrorCodeForbidden, ErrorCodeConflict, ErrorCodeGone, ErrorCodePayloadTooLarge, ErrorCodeUnprocessable, ErrorCodeRateLimited,
+		ErrorCodeNotConfigured, ErrorCodeUpstreamFailed, ErrorCodeUnavailable, ErrorCodeInternal,
+	}
+	fieldTypes := []interface{}{}
+	for _, fieldType := range FieldType("").Values() {
+		fieldTypes = append(fieldTypes, fieldType)
+	}
+
+	return map[string]interface{}{
+		"FormSummary": objectOf(map[string]interface{}{
+			"id":          str,
+			"title":       str,
+			"description": str,
+			"version":     str,
+			"tags":        arrayOf(str),
+			"category":    str,
+			"owner":       str,
+			"metadata":    object,
+		}, "id"),
+		"FormList": objectOf(map[string]interface{}{
+			"forms": arrayOf(schemaRef("FormSummary")),
+			"pagination": objectOf(map[string]interface{}{
+				"total": integer,
+				"page":  integer,
+				"limit": integer,
+				"pages": integer,
+			}, "total", "page", "limit", "pages"),
+		}, "forms", "pagination"),
+		"Form": objectOf(map[string]interface{}{
+			"id":          str,
+			"title":       str,
+			"description": str,
+			"version":     str,
+			"tags":        arrayOf(str),
+			"category":    str,
+			"owner":       str,
+			"metadata":    object,
+			"fields":      arrayOf(schemaRef("Field")),
+			"properties":  object,
+		}, "id", "fields"),
+		"Field": objectOf(map[string]interface{}{
+			"id":              str,
+			"type":            map[string]interface{}{"type": "string", "enum": fieldTypes},
+			"label":           str,
+			"required":        boolean,
+			"defaultValue":    map[string]interface{}{},
+			"computedValue":   str,
+			"placeholder":     str,
+			"helpText":        str,
+			"validationRules": arrayOf(object),
+			"properties":      object,
+			"options":         object,
+			"nested":          arrayOf(schemaRef("Field")),
+		}, "id", "type", "label"),
+		"Error": objectOf(map[string]interface{}{
+			"code":    map[string]interface{}{"type": "string", "enum": errorCodes},
+			"message": str,
+			"field":   str,
+			"details": map[string]interface{}{},
+		}, "code", "message"),
+		"ValidationError": objectOf(map[string]interface{}{
+			"fieldId":  str,
+			"message":  str,
+			"ruleType": str,
+		}, "fieldId", "message", "ruleType"),
+		"ValidationResult": objectOf(map[string]interface{}{
+			"valid":         boolean,
+			"errors":        arrayOf(schemaRef("ValidationError")),
+			"sections":      map[string]interface{}{"type": "object", "additionalProperties": arrayOf(schemaRef("ValidationError"))},
+			"truncated":     boolean,
+			"coercions":     arrayOf(object),
+			"tampered":      arrayOf(object),
+			"verifications": arrayOf(object),
+		}, "valid"),
+		"ValidationFailure": map[string]interface{}{
+			"allOf": []interface{}{schemaRef("ValidationResult"), objectOf(map[string]interface{}{
+				"code":    map[string]interface{}{"const": ErrorCodeValidationFailed},
+				"message": str,
+				"field":   str,
+			}, "code", "message")},
+		},
+		"RequestError": objectOf(map[string]interface{}{
+			"code":    map[string]interface{}{"const": ErrorCodeInvalidRequest},
+			"message": str,
+			"field":   str,
+			"details": arrayOf(objectOf(map[string]interface{}{
+				"path":    str,
+				"message": str,
+				"line":    map[string]interface{}{"type": "integer"},
+				"column":  map[string]interface{}{"type": "integer"},
+			}, "message")),
+		}, "code", "message", "details"),
+		"SubmitResponse": objectOf(map[string]interface{}{
+			"success":      map[string]interface{}{"const": true},
+			"message":      str,
+			"formId":       str,
+			"data":         object,
+			"output":       map[string]interface{}{},
+			"submissionId": str,
+			"duplicates":   arrayOf(object),
+			"duplicateOf":  str,
+		}, "success", "message", "formId"),
+		"SubmitError": objectOf(map[string]interface{}{
+			"success":    map[string]interface{}{"const": false},
+			"code":       map[string]interface{}{"type": "string", "enum": errorCodes},
+			"message":    str,
+			"field":      str,
+			"formId":     str,
+			"duplicates": arrayOf(object),
+			"conflict": objectOf(map[string]interface{}{
+				"constraint": str,
+				"fields":     arrayOf(str),
+			}),
+			"errors": arrayOf(schemaRef("ValidationError")),
+		}, "success", "code", "message", "formId"),
+		"ComputedValues": objectOf(map[string]interface{}{
+			"values": object,
+			"errors": map[string]interface{}{"type": "object", "additionalProperties": str},
+		}, "values"),
+	}
+}
+
+// schemaRef refers to a schema of the document's components
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonResponse describes a response with a JSON body
+func jsonResponse(description string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// errorResponse describes an error response with an Error body
+func errorResponse(description string) map[string]interface{} {
+	return jsonResponse(description, schemaRef("Error"))
+}
+
+// openAPIName turns a form ID into a name usable in component names and operation IDs,
+// e.g. "job-application" into "JobApplication"
+func openAPIName(id string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range id {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// formTitle returns the title of a form, or its ID when it has none
+func formTitle(schema *FormSchema) string {
+	if schema.Title != "" {
+		return schema.Title
+	}
+	return schema.ID
+}
+
+// handleOpenAPISpec handles requests for the OpenAPI document of the registered forms
+func (ah *APIHandler) handleOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ah.GenerateOpenAPISpec())
+}
@@ -0,0 +1,65 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOpenAPISpec(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetOpenAPIInfo(OpenAPIInfo{Title: "Forms", Version: "2.3.0"})
+	require.NoError(t, handler.RegisterSchema(newRegistrationForm()))
+	signup := NewForm("job-application", "Job Application")
+	signup.TextField("name", "Name").Required(true)
+	signup.NumberField("years", "Years of experience")
+	require.NoError(t, handler.RegisterSchema(signup.Build()))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var spec map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec))
+	assert.Equal(t, "3.1.0", spec["openapi"])
+	assert.Equal(t, map[string]interface{}{"title": "Forms", "version": "2.3.0"}, spec["info"])
+
+	// Every form gets its own operations, whose bodies are the JSON Schema of its submissions
+	paths := spec["paths"].(map[string]interface{})
+	submit := paths["/api/submit/job-application"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "submitJobApplication", submit["operationId"])
+	body := submit["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"]
+	assert.Equal(t, map[string]interface{}{"$ref": "#/components/schemas/JobApplicationSubmission"}, body.(map[string]interface{})["schema"])
+	responses := submit["responses"].(map[string]interface{})
+	for _, status := range []string{"200", "400", "404", "409", "500"} {
+		assert.Contains(t, responses, status)
+	}
+	assert.Contains(t, paths, "/api/validate/registration")
+	assert.Contains(t, paths, "/api/compute/registration")
+	assert.Contains(t, paths, "/api/forms/registration/jsonschema")
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	submission := schemas["JobApplicationSubmission"].(map[string]interface{})
+	assert.NotContains(t, submission, "$schema")
+	assert.Equal(t, []interface{}{"name"}, submission["required"])
+	assert.Equal(t, "number", submission["properties"].(map[string]interface{})["years"].(map[string]interface{})["type"])
+	for _, name := range []string{"Error", "ValidationResult", "ValidationFailure", "ValidationError", "RequestError", "SubmitError"} {
+		assert.Contains(t, schemas, name)
+	}
+
+	// Operation IDs are unique across forms
+	operationIDs := map[string]bool{}
+	for _, item := range paths {
+		for _, operation := range item.(map[string]interface{}) {
+			id := operation.(map[string]interface{})["operationId"].(string)
+			assert.False(t, operationIDs[id], id)
+			operationIDs[id] = true
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CircuitOpenCode identifies responses for options of a source whose circuit is open
+const CircuitOpenCode = "circuit_open"
+
+// Defaults of circuit breakers
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitOpenFor          = 30 * time.Second
+	DefaultRetryBackoff            = 100 * time.Millisecond
+)
+
+// CircuitState is the state of the circuit breaker of an option source
+type CircuitState string
+
+// Circuit breaker states
+const (
+	CircuitClosed   CircuitState = "closed"    // Requests go through
+	CircuitOpen     CircuitState = "open"      // Requests fail fast until the circuit is tried again
+	CircuitHalfOpen CircuitState = "half-open" // One trial request decides whether the circuit closes
+)
+
+// OptionCircuitBreaker stops calling an upstream option source that keeps failing, so that
+// forms using it fail fast, or fall back to their fallback options, instead of waiting for
+// every request to time out. Failed requests are retried first.
+type OptionCircuitBreaker struct {
+	FailureThreshold int           // Consecutive failed requests that open the circuit (default 5)
+	OpenFor          time.Duration // How long an open circuit fails fast before a trial request (default 30s)
+	MaxRetries       int           // Retries of a request that failed with a network error, a 5xx, or a 429
+	Backoff          time.Duration // Wait before the first retry, doubled for each further one (default 100ms)
+	Timeout          time.Duration // Longest a single attempt may take (default: the 10s client timeout)
+}
+
+// upstreamStatusError is returned when an upstream answers with an error status
+type upstreamStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (ue *upstreamStatusError) Error() string {
+	return fmt.Sprintf("API returned error status: %d, body: %s", ue.StatusCode, ue.Body)
+}
+
+// circuitBreaker tracks the failures of one option source
+type circuitBreaker struct {
+	config   OptionCircuitBreaker
+	lock     sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker creates a closed circuit breaker with config
+func newCircuitBreaker(config OptionCircuitBreaker) *circuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if config.OpenFor <= 0 {
+		config.OpenFor = DefaultCircuitOpenFor
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DefaultRetryBackoff
+	}
+	return &circuitBreaker{config: config, state: CircuitClosed}
+}
+
+// allow reports whether a request may be made. An open circuit lets one trial request through
+// once it has been open long enough; otherwise it returns how long until then.
+func (cb *circuitBreaker) allow(now time.Time) (bool, time.Duration) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		wait := cb.config.OpenFor - now.Sub(cb.openedAt)
+		if wait > 0 {
+			return false, wait
+		}
+		cb.state = CircuitHalfOpen
+		return true, 0
+	case CircuitHalfOpen:
+		// A trial request is in flight
+		return false, DefaultOptionsRetryAfter
+	default:
+		return true, 0
+	}
+}
+
+// record records the outcome of a request let through. Requests that never reached the
+// upstream, such as shed ones, count neither way.
+func (cb *circuitBreaker) record(err error, now time.Time) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch {
+	case errors.Is(err, ErrOptionsUnavailable):
+		if cb.state == CircuitHalfOpen {
+			cb.state = CircuitOpen
+		}
+	case err == nil || !retryableOptionsError(err):
+		cb.state = CircuitClosed
+		cb.failures = 0
+	default:
+		cb.failures++
+		if cb.state == CircuitHalfOpen || cb.failures >= cb.config.FailureThreshold {
+			cb.state = CircuitOpen
+			cb.openedAt = now
+		}
+	}
+}
+
+// retryableOptionsError reports whether a request failed in a way worth retrying: the upstream
+// could not be reached, failed, or asked to slow down. Shed requests are not retried.
+func retryableOptionsError(err error) bool {
+	if errors.Is(err, ErrOptionsUnavailable) {
+		return false
+	}
+	var status *upstreamStatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// SetCircuitBreaker sets the circuit breaker of an option source, named like source limits:
+// by the host of their endpoint, or AllSources for every source without one of its own
+func (os *OptionService) SetCircuitBreaker(source string, config OptionCircuitBreaker) {
+	os.limitLock.Lock()
+	defer os.limitLock.Unlock()
+
+	if os.breakerConfigs == nil {
+		os.breakerConfigs = make(map[string]OptionCircuitBreaker)
+		os.breakers = make(map[string]*circuitBreaker)
+	}
+	os.breakerConfigs[source] = config
+	if source == AllSources {
+		// Sources using the previous default pick up the new one
+		for name := range os.breakers {
+			if _, own := os.breakerConfigs[name]; !own {
+				delete(os.breakers, name)
+			}
+		}
+		return
+	}
+	delete(os.breakers, source)
+}
+
+// CircuitState returns the state of the circuit breaker of a source. Sources without a
+// circuit breaker are always closed.
+func (os *OptionService) CircuitState(source string) CircuitState {
+	breaker := os.breaker(source)
+	if breaker == nil {
+		return CircuitClosed
+	}
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	return breaker.state
+}
+
+// breaker returns the circuit breaker of a source, or nil if it has none
+func (os *OptionService) breaker(source string) *circuitBreaker {
+	os.limitLock.Lock()
+	defer os.limitLock.Unlock()
+
+	if breaker, ok := os.breakers[source]; ok {
+		return breaker
+	}
+	config, ok := os.breakerConfigs[source]
+	if !ok {
+		config, ok = os.breakerConfigs[AllSources]
+	}
+	if !ok {
+		return nil
+	}
+	breaker := newCircuitBreaker(config)
+	os.breakers[source] = breaker
+	return breaker
+}
+
+// withCircuitBreaker calls attempt unless the circuit of the source is open, retrying it with
+// backoff when it fails in a retryable way
+func (os *OptionService) withCircuitBreaker(ctx context.Context, source string, attempt func(ctx context.Context) error) error {
+	breaker := os.breaker(source)
+	if breaker == nil {
+		return attempt(ctx)
+	}
+
+	if ok, wait := breaker.allow(time.Now()); !ok {
+		return &OptionsUnavailableError{
+			Code:       CircuitOpenCode,
+			Message:    fmt.Sprintf("options from %s are unavailable while the source is failing", source),
+			Source:     source,
+			RetryAfter: int(math.Ceil(wait.Seconds())),
+		}
+	}
+
+	backoff := breaker.config.Backoff
+	var err error
+	for try := 0; ; try++ {
+		err = breaker.attempt(ctx, attempt)
+		if err == nil || !retryableOptionsError(err) || try >= breaker.config.MaxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	breaker.record(err, time.Now())
+	return err
+}
+
+// attempt makes one attempt, within the timeout of the breaker
+func (cb *circuitBreaker) attempt(ctx context.Context, attempt func(ctx context.Context) error) error {
+	if cb.config.Timeout <= 0 {
+		return attempt(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, cb.config.Timeout)
+	defer cancel()
+	return attempt(ctx)
+}
+
+// SetOptionCircuitBreaker sets the circuit breaker of an option source
+func (ah *APIHandler) SetOptionCircuitBreaker(source string, config OptionCircuitBreaker) {
+	ah.optionService.SetCircuitBreaker(source, config)
+}
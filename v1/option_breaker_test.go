@@ -0,0 +1,124 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyUpstream answers with the next status of statuses, then with 200
+func flakyUpstream(calls *atomic.Int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) && statuses[n-1] != http.StatusOK {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id": "p1", "name": "Product 1"}]`))
+	}))
+}
+
+func TestOptionRetries(t *testing.T) {
+	var calls atomic.Int32
+	upstream := flakyUpstream(&calls, http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK, http.StatusNotFound)
+	defer upstream.Close()
+
+	service := NewOptionService(0)
+	service.SetCircuitBreaker(AllSources, OptionCircuitBreaker{MaxRetries: 2, Backoff: time.Millisecond})
+	source := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL, "GET", "id", "name").Build().DynamicSource
+
+	options, err := service.GetDynamicOptions(source, nil)
+	require.NoError(t, err)
+	require.Len(t, options, 1)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Client errors are not retried
+	_, err = service.GetDynamicOptions(source, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), calls.Load())
+	assert.Equal(t, CircuitClosed, service.CircuitState(endpointSource(upstream.URL)))
+}
+
+func TestOptionCircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	upstream := flakyUpstream(&calls, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer upstream.Close()
+
+	service := NewOptionService(0)
+	host := endpointSource(upstream.URL)
+	service.SetCircuitBreaker(host, OptionCircuitBreaker{FailureThreshold: 2, OpenFor: 50 * time.Millisecond})
+	source := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL, "GET", "id", "name").Build().DynamicSource
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetDynamicOptions(source, nil)
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrOptionsUnavailable))
+	}
+	assert.Equal(t, CircuitOpen, service.CircuitState(host))
+
+	// An open circuit fails fast without calling the upstream
+	_, err := service.GetDynamicOptions(source, nil)
+	var unavailable *OptionsUnavailableError
+	require.True(t, errors.As(err, &unavailable))
+	assert.Equal(t, CircuitOpenCode, unavailable.Code)
+	assert.Equal(t, 1, unavailable.RetryAfter)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// A failed trial request opens it again; a successful one closes it
+	time.Sleep(60 * time.Millisecond)
+	_, err = service.GetDynamicOptions(source, nil)
+	require.Error(t, err)
+	assert.Equal(t, CircuitOpen, service.CircuitState(host))
+	time.Sleep(60 * time.Millisecond)
+	_, err = service.GetDynamicOptions(source, nil)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, service.CircuitState(host))
+
+	stats := service.SourceStats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, CircuitClosed, stats[0].Circuit)
+}
+
+func TestOptionFallback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer upstream.Close()
+
+	handler := NewAPIHandler()
+	handler.SetOptionCircuitBreaker(AllSources, OptionCircuitBreaker{Timeout: 20 * time.Millisecond})
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("warehouse", FieldTypeSelect, "Warehouse").
+			WithDynamicOptionsConfig(NewOptionsBuilder().Dynamic().
+				FromAPIWithPath(upstream.URL, "GET", "id", "name").
+				WithFallback(NewOption("main", "Main warehouse")).
+				Build()).
+			Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	started := time.Now()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/order/warehouse", nil))
+	assert.Less(t, int64(time.Since(started)), int64(150*time.Millisecond))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var options []*Option
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &options))
+	require.Len(t, options, 1)
+	assert.Equal(t, "main", options[0].Value)
+
+	// Fallback options are part of the schema
+	schema, err := NewJSONImporter().ImportJSON(`{"id": "f", "title": "F", "fields": [{"id": "w", "type": "select", "label": "W",
+		"options": {"type": "dynamic", "dynamicSource": {"type": "api", "endpoint": "x", "fallback": [{"value": "main", "label": "Main"}]}}}]}`)
+	require.NoError(t, err)
+	require.Len(t, schema.Fields[0].Options.DynamicSource.Fallback, 1)
+	assert.Equal(t, "Main", schema.Fields[0].Options.DynamicSource.Fallback[0].Label)
+}
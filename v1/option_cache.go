@@ -0,0 +1,380 @@
+package smartform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Errors returned when invalidating the cached options of a form or field
+var (
+	ErrFormNotFound  = errors.New("form not found")
+	ErrFieldNotFound = errors.New("field not found")
+)
+
+// CacheIdentity names who options are fetched for, e.g. {"tenant": "acme", "user": "42"}.
+// Options fetched for one identity are never served from the cache to another.
+type CacheIdentity map[string]string
+
+// IdentityResolver derives the cache identity of a request, usually from its authentication
+type IdentityResolver func(r *http.Request) CacheIdentity
+
+// SetCacheKeyComponents limits the identity components that partition the option cache,
+// e.g. "tenant" when upstream results depend on the tenant but not on the user. By
+// default every component of the identity is used.
+func (os *OptionService) SetCacheKeyComponents(components ...string) {
+	os.cacheLock.Lock()
+	defer os.cacheLock.Unlock()
+	os.keyComponents = components
+}
+
+// keyIdentity returns the part of an identity that partitions the cache
+func (os *OptionService) keyIdentity(identity CacheIdentity) CacheIdentity {
+	if len(identity) == 0 {
+		return nil
+	}
+
+	os.cacheLock.RLock()
+	components := os.keyComponents
+	os.cacheLock.RUnlock()
+	if components == nil {
+		return identity
+	}
+
+	keyed := CacheIdentity{}
+	for _, component := range components {
+		if value, ok := identity[component]; ok {
+			keyed[component] = value
+		}
+	}
+	return keyed
+}
+
+// generateCacheKey generates a cache key for the request. Sources calling the same endpoint
+// with different headers, e.g. per-tenant API keys, and different identities get separate keys.
+func (os *OptionService) generateCacheKey(endpoint, method string, params map[string]interface{}, headers map[string]string, identity CacheIdentity) string {
+	key := method + ":" + endpoint
+	if len(params) > 0 {
+		paramJSON, _ := json.Marshal(params)
+		key += ":" + string(paramJSON)
+	}
+	if len(headers) > 0 {
+		// Hash the headers so that credentials never appear in keys
+		headerJSON, _ := json.Marshal(headers)
+		sum := sha256.Sum256(headerJSON)
+		key += "|headers:" + hex.EncodeToString(sum[:8])
+	}
+	if len(identity) > 0 {
+		values := url.Values{}
+		for component, value := range identity {
+			values.Set(component, value)
+		}
+		key += "|identity:" + values.Encode()
+	}
+	return key
+}
+
+// OptionCachePolicy sets how the options of a dynamic source are cached
+type OptionCachePolicy struct {
+	TTLSeconds                  int  `json:"ttlSeconds,omitempty"`                  // How long options are fresh (default: the service TTL)
+	NoCache                     bool `json:"noCache,omitempty"`                     // Fetch the options on every request
+	StaleWhileRevalidateSeconds int  `json:"staleWhileRevalidateSeconds,omitempty"` // How long expired options are served while they are refetched
+}
+
+// noCache reports whether options may not be cached. A nil policy caches with the defaults.
+func (p *OptionCachePolicy) noCache() bool {
+	return p != nil && p.NoCache
+}
+
+// CacheFor caches the options of the source for ttl instead of the service TTL
+func (dob *DynamicOptionsBuilder) CacheFor(ttl time.Duration) *DynamicOptionsBuilder {
+	dob.cachePolicy().TTLSeconds = int(ttl / time.Second)
+	return dob
+}
+
+// NoCache fetches the options of the source on every request
+func (dob *DynamicOptionsBuilder) NoCache() *DynamicOptionsBuilder {
+	dob.cachePolicy().NoCache = true
+	return dob
+}
+
+// StaleWhileRevalidate keeps serving expired options for up to window while they are refetched
+// in the background, so slow upstreams only delay the request that finds them expired too long
+func (dob *DynamicOptionsBuilder) StaleWhileRevalidate(window time.Duration) *DynamicOptionsBuilder {
+	dob.cachePolicy().StaleWhileRevalidateSeconds = int(window / time.Second)
+	return dob
+}
+
+// cachePolicy returns the cache policy of the source being built, creating it if needed
+func (dob *DynamicOptionsBuilder) cachePolicy() *OptionCachePolicy {
+	source := dob.config.DynamicSource
+	if source.CachePolicy == nil {
+		source.CachePolicy = &OptionCachePolicy{}
+	}
+	return source.CachePolicy
+}
+
+// sourceCacheTag identifies the source cache entries were fetched from, so they can be dropped
+// together whatever context, parameters, or identity they were fetched with
+func sourceCacheTag(source *DynamicSource) string {
+	if source.Type == "function" {
+		return "function:" + source.FunctionName
+	}
+	return source.Method + ":" + source.Endpoint
+}
+
+// cacheGet returns a cached response that has not expired
+func (os *OptionService) cacheGet(key string, policy *OptionCachePolicy) ([]byte, bool) {
+	data, fresh, ok := os.cacheLookup(key, policy)
+	return data, ok && fresh
+}
+
+// cacheLookup returns a cached response that has not expired, or that expired within the
+// stale-while-revalidate window of the policy, in which case fresh is false
+func (os *OptionService) cacheLookup(key string, policy *OptionCachePolicy) (data []byte, fresh, ok bool) {
+	if policy.noCache() {
+		return nil, false, false
+	}
+
+	ttl, stale := os.cacheLifetime(policy)
+
+	os.cacheLock.RLock()
+	defer os.cacheLock.RUnlock()
+	entry, ok := os.cache[key]
+	if !ok {
+		return nil, false, false
+	}
+	age := time.Since(entry.Timestamp)
+	switch {
+	case age < ttl:
+		return entry.Data, true, true
+	case age < ttl+stale:
+		return entry.Data, false, true
+	default:
+		return nil, false, false
+	}
+}
+
+// cacheLifetime returns how long entries cached under a policy are fresh, and how long they
+// are served stale after that
+func (os *OptionService) cacheLifetime(policy *OptionCachePolicy) (ttl, stale time.Duration) {
+	ttl = os.cacheTTL
+	if policy != nil {
+		if policy.TTLSeconds > 0 {
+			ttl = time.Duration(policy.TTLSeconds) * time.Second
+		}
+		stale = time.Duration(policy.StaleWhileRevalidateSeconds) * time.Second
+	}
+	return ttl, stale
+}
+
+// cachePut caches a response fetched from a source for an identity. Entries that can no
+// longer be served are dropped as responses are cached, at most once per cache TTL, so that
+// the cache holds only the options of the latest requests however many keys they use.
+func (os *OptionService) cachePut(key string, data []byte, identity CacheIdentity, source string, policy *OptionCachePolicy) {
+	ttl, stale := os.cacheLifetime(policy)
+
+	os.cacheLock.Lock()
+	defer os.cacheLock.Unlock()
+	now := time.Now()
+	if now.Sub(os.swept) >= os.cacheTTL {
+		for key, entry := range os.cache {
+			if now.Sub(entry.Timestamp) >= entry.lifetime {
+				delete(os.cache, key)
+			}
+		}
+		os.swept = now
+	}
+	os.cache[key] = &CacheEntry{
+		Data:      data,
+		Timestamp: now,
+		Identity:  identity,
+		Source:    source,
+		lifetime:  ttl + stale,
+	}
+}
+
+// revalidate refetches a stale cache entry in the background, once at a time. Failures keep
+// the stale entry until its window ends.
+func (os *OptionService) revalidate(key, source string, identity CacheIdentity, policy *OptionCachePolicy, fetch func() ([]byte, error)) {
+	os.cacheLock.Lock()
+	if os.revalidating[key] {
+		os.cacheLock.Unlock()
+		return
+	}
+	os.revalidating[key] = true
+	os.cacheLock.Unlock()
+
+	go func() {
+		data, err := fetch()
+		if err == nil {
+			os.cachePut(key, data, identity, source, policy)
+		}
+		os.cacheLock.Lock()
+		delete(os.revalidating, key)
+		os.cacheLock.Unlock()
+	}()
+}
+
+// InvalidateSource drops the cached options of a source, for every context and identity
+func (os *OptionService) InvalidateSource(source *DynamicSource) int {
+	tag := sourceCacheTag(source)
+	os.cacheLock.Lock()
+	defer os.cacheLock.Unlock()
+
+	removed := 0
+	for key, entry := range os.cache {
+		if entry.Source == tag {
+			delete(os.cache, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// FlushCache drops every cached option
+func (os *OptionService) FlushCache() int {
+	os.cacheLock.Lock()
+	defer os.cacheLock.Unlock()
+	removed := len(os.cache)
+	os.cache = make(map[string]*CacheEntry)
+	return removed
+}
+
+// InvalidateIdentity drops the cached options of every identity matching all the given
+// components, e.g. {"tenant": "acme"} drops the entries of all users of that tenant
+func (os *OptionService) InvalidateIdentity(identity CacheIdentity) int {
+	os.cacheLock.Lock()
+	defer os.cacheLock.Unlock()
+
+	removed := 0
+	for key, entry := range os.cache {
+		if len(entry.Identity) == 0 {
+			continue
+		}
+		matches := true
+		for component, value := range identity {
+			if entry.Identity[component] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			delete(os.cache, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// SetIdentityResolver sets the function that derives the cache identity of requests, so that
+// options whose upstream results depend on the caller are cached per tenant or user
+func (ah *APIHandler) SetIdentityResolver(resolver IdentityResolver) {
+	ah.identityResolver = resolver
+}
+
+// SetOptionCacheKeyComponents limits the identity components that partition the option cache
+func (ah *APIHandler) SetOptionCacheKeyComponents(components ...string) {
+	ah.optionService.SetCacheKeyComponents(components...)
+}
+
+// InvalidateOptionCache drops the cached options of every identity matching all the given components
+func (ah *APIHandler) InvalidateOptionCache(identity CacheIdentity) int {
+	return ah.optionService.InvalidateIdentity(identity)
+}
+
+// InvalidateOptionsCache drops the cached options of a field, or of every field of the form when
+// fieldID is empty, so the next request refetches them. Fields sharing a source are refetched too.
+func (ah *APIHandler) InvalidateOptionsCache(formID, fieldID string) (int, error) {
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		return 0, ErrFormNotFound
+	}
+
+	fields := schema.Fields
+	if fieldID != "" {
+		field := schema.FindFieldByID(fieldID)
+		if field == nil {
+			return 0, ErrFieldNotFound
+		}
+		fields = []*Field{field}
+	}
+
+	removed := 0
+	for _, source := range dynamicSources(fields) {
+		removed += ah.optionService.InvalidateSource(source)
+	}
+	return removed, nil
+}
+
+// FlushOptionsCache drops every cached option
+func (ah *APIHandler) FlushOptionsCache() int {
+	return ah.optionService.FlushCache()
+}
+
+// dynamicSources returns the dynamic option sources of fields and their nested fields
+func dynamicSources(fields []*Field) []*DynamicSource {
+	var sources []*DynamicSource
+	for _, field := range fields {
+		if field.Options != nil && field.Options.DynamicSource != nil {
+			sources = append(sources, field.Options.DynamicSource)
+		}
+		sources = append(sources, dynamicSources(field.Nested)...)
+	}
+	return sources
+}
+
+// handleOptionsCache handles staff requests to flush cached options: of every source, of a
+// form, or of one field
+func (ah *APIHandler) handleOptionsCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	segments := splitPath(getPathParam(r.URL.Path, "/api/options/cache"))
+	var removed int
+	var err error
+	switch len(segments) {
+	case 0:
+		removed = ah.FlushOptionsCache()
+	case 1:
+		removed, err = ah.InvalidateOptionsCache(segments[0], "")
+	case 2:
+		removed, err = ah.InvalidateOptionsCache(segments[0], segments[1])
+	default:
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+		return
+	}
+	switch {
+	case errors.Is(err, ErrFormNotFound):
+		writeError(w, ErrFormNotFound)
+		return
+	case errors.Is(err, ErrFieldNotFound):
+		writeError(w, fieldNotFound(segments[1]))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"removed": removed})
+}
+
+// requestIdentity returns the cache identity of a request
+func (ah *APIHandler) requestIdentity(r *http.Request) CacheIdentity {
+	if ah.identityResolver == nil || r == nil {
+		return nil
+	}
+	return ah.identityResolver(r)
+}
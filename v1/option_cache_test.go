@@ -0,0 +1,219 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionCachePartitionedByIdentity(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"value": r.URL.Path, "label": r.URL.Path},
+		})
+	}))
+	defer upstream.Close()
+
+	handler := NewAPIHandler()
+	handler.SetIdentityResolver(func(r *http.Request) CacheIdentity {
+		return CacheIdentity{"tenant": r.Header.Get("X-Tenant"), "user": r.Header.Get("X-User")}
+	})
+	handler.SetOptionCacheKeyComponents("tenant")
+
+	project := NewFieldBuilder("project", FieldTypeSelect, "Project").Build()
+	project.Options = NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL+"/${identity.tenant}/projects", "GET", "value", "label").Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("task", "Task").AddField(project).Build()))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	fetch := func(tenant, user string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/options/task/project", nil)
+		req.Header.Set("X-Tenant", tenant)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var options []*Option
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &options))
+		require.Len(t, options, 1)
+		return options[0].Label
+	}
+
+	assert.Equal(t, "/acme/projects", fetch("acme", "1"))
+	assert.Equal(t, "/acme/projects", fetch("acme", "2")) // Users of a tenant share entries
+	assert.Equal(t, "/globex/projects", fetch("globex", "1"))
+	assert.Equal(t, 2, calls)
+
+	assert.Equal(t, 1, handler.InvalidateOptionCache(CacheIdentity{"tenant": "acme"}))
+	fetch("acme", "1")
+	fetch("globex", "1")
+	assert.Equal(t, 3, calls)
+}
+
+func TestCacheKeySeparatesHeadersAndIdentities(t *testing.T) {
+	service := NewOptionService(0)
+	base := service.generateCacheKey("https://api", "GET", nil, nil, nil)
+	withKey := service.generateCacheKey("https://api", "GET", nil, map[string]string{"Authorization": "Bearer secret"}, nil)
+	withIdentity := service.generateCacheKey("https://api", "GET", nil, nil, CacheIdentity{"tenant": "acme"})
+
+	assert.NotEqual(t, base, withKey)
+	assert.NotContains(t, withKey, "secret")
+	assert.NotEqual(t, base, withIdentity)
+}
+
+// backdateOptionCache makes every cached option look fetched age ago
+func backdateOptionCache(service *OptionService, age time.Duration) {
+	service.cacheLock.Lock()
+	defer service.cacheLock.Unlock()
+	for _, entry := range service.cache {
+		entry.Timestamp = time.Now().Add(-age)
+	}
+}
+
+func TestOptionCachePolicies(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{{"value": n, "label": r.URL.Path}})
+	}))
+	defer upstream.Close()
+
+	service := NewOptionService(time.Minute)
+	fetch := func(source *DynamicSource) interface{} {
+		options, err := service.GetDynamicOptions(source, nil)
+		require.NoError(t, err)
+		require.Len(t, options, 1)
+		return options[0].Value
+	}
+
+	live := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL+"/live", "GET", "value", "label").NoCache().Build().DynamicSource
+	fetch(live)
+	fetch(live)
+	assert.Equal(t, int32(2), calls.Load())
+
+	short := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL+"/short", "GET", "value", "label").CacheFor(10 * time.Second).Build().DynamicSource
+	fetch(short)
+	backdateOptionCache(service, 5*time.Second)
+	fetch(short)
+	assert.Equal(t, int32(3), calls.Load())
+	backdateOptionCache(service, 15*time.Second)
+	fetch(short)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// Expired options within the window are served while they are refetched
+	service.FlushCache()
+	slow := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL+"/slow", "GET", "value", "label").
+		CacheFor(10 * time.Second).StaleWhileRevalidate(time.Minute).Build().DynamicSource
+	first := fetch(slow)
+	backdateOptionCache(service, 30*time.Second)
+	assert.Equal(t, first, fetch(slow))
+	assert.Eventually(t, func() bool { return fetch(slow) != first }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(6), calls.Load())
+
+	backdateOptionCache(service, 2*time.Minute)
+	assert.Equal(t, float64(7), fetch(slow))
+}
+
+func TestOptionCacheDropsExpiredEntries(t *testing.T) {
+	service := NewOptionService(time.Minute)
+	service.cachePut("plain", []byte(`[]`), nil, "GET:/plain", nil)
+	service.cachePut("stale", []byte(`[]`), nil, "GET:/stale", &OptionCachePolicy{TTLSeconds: 10, StaleWhileRevalidateSeconds: 120})
+	backdateOptionCache(service, 90*time.Second)
+
+	// Expired entries are dropped at most once per cache TTL
+	service.cachePut("fresh", []byte(`[]`), nil, "GET:/fresh", nil)
+	assert.Len(t, service.cache, 3)
+
+	service.cacheLock.Lock()
+	service.swept = service.swept.Add(-time.Minute)
+	service.cacheLock.Unlock()
+	service.cachePut("latest", []byte(`[]`), nil, "GET:/latest", nil)
+	assert.NotContains(t, service.cache, "plain")
+	assert.Contains(t, service.cache, "stale")
+	assert.Contains(t, service.cache, "fresh")
+	assert.Contains(t, service.cache, "latest")
+}
+
+func TestInvalidateOptionsCache(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{{"value": r.URL.Path, "label": r.URL.Path}})
+	}))
+	defer upstream.Close()
+
+	handler := NewAPIHandler()
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "ops", r.Header.Get("X-Staff") == "yes"
+	})
+	country := NewFieldBuilder("country", FieldTypeSelect, "Country").
+		WithDynamicOptionsConfig(NewOptionsBuilder().Dynamic().FromAPI(upstream.URL+"/countries", "GET").Build()).
+		Build()
+	currency := NewFieldBuilder("currency", FieldTypeSelect, "Currency").
+		WithDynamicOptionsConfig(NewOptionsBuilder().Dynamic().FromAPI(upstream.URL+"/currencies", "GET").Build()).
+		Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("billing", "Billing").
+		AddField(country).
+		AddField(NewGroupFieldBuilder("payment", "Payment").AddField(currency).Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	warm := func() {
+		for _, field := range []string{"country", "currency"} {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/billing/"+field, nil))
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		}
+	}
+	flush := func(path string, staff bool) (int, int) {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		if staff {
+			req.Header.Set("X-Staff", "yes")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var result struct {
+			Removed int `json:"removed"`
+		}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec.Code, result.Removed
+	}
+
+	warm()
+	warm()
+	assert.Equal(t, int32(2), calls.Load())
+
+	removed, err := handler.InvalidateOptionsCache("billing", "country")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	warm()
+	assert.Equal(t, int32(3), calls.Load())
+
+	_, err = handler.InvalidateOptionsCache("billing", "missing")
+	assert.Equal(t, ErrFieldNotFound, err)
+
+	code, _ := flush("/api/options/cache/billing", false)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, removed = flush("/api/options/cache/billing", true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, removed)
+	code, _ = flush("/api/options/cache/unknown", true)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	warm()
+	code, removed = flush("/api/options/cache", true)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, int32(5), calls.Load())
+}
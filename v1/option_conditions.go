@@ -0,0 +1,38 @@
+package smartform
+
+// availableOptions returns the options whose conditions hold for the form state, keeping
+// options without a condition
+func (v *Validator) availableOptions(options []*Option, data map[string]interface{}) []*Option {
+	available := []*Option{}
+	for _, option := range options {
+		if option.Condition == nil || v.evaluateCondition(option.Condition, data) {
+			available = append(available, option)
+		}
+	}
+	return available
+}
+
+// ConditionalOptions returns the options of a field with conditional options that are offered
+// for the form state
+func (fs *FormSchema) ConditionalOptions(field *Field, data map[string]interface{}) []*Option {
+	if field.Options == nil || field.Options.Type != OptionsTypeConditional {
+		return nil
+	}
+	return NewValidator(fs).availableOptions(field.Options.Static, data)
+}
+
+// typedQueryState interprets form state passed as query parameters, where every value is text,
+// as the data types of the fields they belong to, so that conditions compare numbers and
+// booleans as they would in submitted data
+func typedQueryState(schema *FormSchema, data map[string]interface{}) map[string]interface{} {
+	typed := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if field := schema.FindFieldByID(key); field != nil {
+			if dataType := expectedDataType(field); dataType != "" && dataType != string(OptionValueTypeString) {
+				value, _ = coerceValue(dataType, value)
+			}
+		}
+		typed[key] = value
+	}
+	return typed
+}
@@ -0,0 +1,82 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCheckoutSchema() *FormSchema {
+	return NewForm("checkout", "Checkout").
+		AddField(NewFieldBuilder("subtotal", FieldTypeNumber, "Subtotal").Build()).
+		AddField(NewFieldBuilder("country", FieldTypeText, "Country").Build()).
+		AddField(NewFieldBuilder("shipping", FieldTypeSelect, "Shipping").
+			WithConditionalOptions(
+				NewOption("standard", "Standard shipping"),
+				NewConditionalOption("express", "Express shipping", When("subtotal").GreaterThan(50.0).Build()),
+				NewConditionalOption("courier", "Same-day courier", And(
+					When("subtotal").GreaterThan(100.0).Build(),
+					When("country").Equals("NL").Build(),
+				).Build()),
+			).
+			Build()).
+		Build()
+}
+
+func TestConditionalOptions(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(newCheckoutSchema()))
+	mux := handler.Handler()
+
+	options := func(query string) []interface{} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/checkout/shipping?"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var offered []*Option
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offered))
+		values := []interface{}{}
+		for _, option := range offered {
+			values = append(values, option.Value)
+		}
+		return values
+	}
+	assert.Equal(t, []interface{}{"standard"}, options(""))
+	assert.Equal(t, []interface{}{"standard"}, options("subtotal=20"))
+	assert.Equal(t, []interface{}{"standard", "express"}, options("subtotal=80"), "query values are read as numbers")
+	assert.Equal(t, []interface{}{"standard", "express"}, options("subtotal=120&country=DE"))
+	assert.Equal(t, []interface{}{"standard", "express", "courier"}, options("subtotal=120&country=NL"))
+
+	// Submitted values must be among the options offered for the submitted data
+	result := NewValidator(newCheckoutSchema()).ValidateForm(map[string]interface{}{"subtotal": 20.0, "shipping": "express"})
+	assert.False(t, result.Valid)
+	result = NewValidator(newCheckoutSchema()).ValidateForm(map[string]interface{}{"subtotal": 80.0, "shipping": "express"})
+	assert.True(t, result.Valid)
+}
+
+func TestConditionalOptionsRoundTrip(t *testing.T) {
+	schema := newCheckoutSchema()
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	imported, err := FormSchemaFromJSON(string(data))
+	require.NoError(t, err)
+
+	field := imported.FindFieldByID("shipping")
+	require.NotNil(t, field)
+	assert.Equal(t, OptionsTypeConditional, field.Options.Type)
+	offered := imported.ConditionalOptions(field, map[string]interface{}{"subtotal": 80.0})
+	require.Len(t, offered, 2)
+	assert.Equal(t, "express", offered[1].Value)
+
+	// Rendered forms keep the conditions for clients to evaluate
+	rendered, err := NewFormRenderer(schema).RenderJSONWithContext(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Contains(t, rendered, `"condition"`)
+
+	clone := schema.Clone()
+	clone.FindFieldByID("shipping").Options.Static[1].Condition.Value = 10.0
+	assert.Equal(t, 50.0, schema.FindFieldByID("shipping").Options.Static[1].Condition.Value)
+}
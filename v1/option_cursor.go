@@ -0,0 +1,232 @@
+package smartform
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// PaginationMode says how clients page through the options of a dynamic function
+type PaginationMode string
+
+const (
+	// PaginationModeOffset pages by offset and limit, counting the options matching the search
+	PaginationModeOffset PaginationMode = "offset"
+	// PaginationModeCursor pages by opaque cursors, for datasets too large to count or to
+	// reload whole for every page. Pages stay consistent while options are added or removed.
+	PaginationModeCursor PaginationMode = "cursor"
+)
+
+// ErrInvalidCursor is returned for option cursors that were not issued by the service
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OptionPage is a page of options in cursor mode. Functions paging through large datasets
+// themselves receive the "cursor", "limit", and "search" of the request as arguments and
+// return an OptionPage, whose NextCursor they choose; other functions return every option
+// and are paged by SearchAndSortPage.
+type OptionPage struct {
+	Options    []*Option `json:"options"`
+	NextCursor string    `json:"nextCursor,omitempty"` // Cursor of the next page; empty after the last page
+	Total      int       `json:"total,omitempty"`      // Number of options matching the search, when known
+}
+
+// optionCursor is the position after the last option of a page
+type optionCursor struct {
+	Offset int    `json:"o"`
+	Value  string `json:"v"` // Value of the last option of the page, to find it again when options moved
+}
+
+// encode returns the opaque form of the cursor
+func (oc *optionCursor) encode() string {
+	data, _ := json.Marshal(oc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeOptionCursor reads a cursor issued by SearchAndSortPage
+func decodeOptionCursor(cursor string) (*optionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var decoded optionCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Offset < 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &decoded, nil
+}
+
+// start returns the index of the first option after the cursor: after the option it ended
+// on, wherever that moved, or at its offset when that option is gone
+func (oc *optionCursor) start(options []*Option) int {
+	if oc.Offset > 0 && oc.Offset <= len(options) && fmt.Sprint(options[oc.Offset-1].Value) == oc.Value {
+		return oc.Offset
+	}
+	for i, option := range options {
+		if fmt.Sprint(option.Value) == oc.Value {
+			return i + 1
+		}
+	}
+	return min(oc.Offset, len(options))
+}
+
+// SearchAndSortPage searches and sorts options like SearchAndSort, and returns the page after
+// the "cursor" parameter, or the first page without one, of up to "limit" options. Cursors
+// are only valid for the search, filters, and sort they were issued for.
+func (dfs *DynamicFunctionService) SearchAndSortPage(
+	options []*Option,
+	searchParams map[string]interface{},
+) (*OptionPage, error) {
+	params := make(map[string]interface{}, len(searchParams))
+	for key, value := range searchParams {
+		if key != "cursor" && key != "limit" && key != "offset" {
+			params[key] = value
+		}
+	}
+	matching, err := dfs.SearchAndSort(options, params)
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	if cursor, _ := searchParams["cursor"].(string); cursor != "" {
+		decoded, err := decodeOptionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		start = decoded.start(matching)
+	}
+	end := len(matching)
+	if limit, _ := searchParams["limit"].(float64); limit > 0 {
+		end = min(start+int(limit), end)
+	}
+
+	page := &OptionPage{Options: matching[start:end], Total: len(matching)}
+	if end < len(matching) {
+		page.NextCursor = (&optionCursor{Offset: end, Value: fmt.Sprint(matching[end-1].Value)}).encode()
+	}
+	return page, nil
+}
+
+// paginationMode returns the pagination mode a function config was declared with
+func (dfc *DynamicFieldConfig) paginationMode() PaginationMode {
+	if dfc == nil {
+		return PaginationModeOffset
+	}
+	switch mode := dfc.TransformerParams["paginationMode"].(type) {
+	case PaginationMode:
+		return mode
+	case string:
+		return PaginationMode(mode)
+	}
+	return PaginationModeOffset
+}
+
+// defaultLimit returns the page size a function config was declared with, or 0
+func (dfc *DynamicFieldConfig) defaultLimit() int {
+	if dfc == nil {
+		return 0
+	}
+	switch limit := dfc.TransformerParams["defaultLimit"].(type) {
+	case int:
+		return limit
+	case float64:
+		return int(limit)
+	}
+	return 0
+}
+
+// optionPageFromResult returns the page a function in cursor mode returned, or false when it
+// returned every option
+func optionPageFromResult(config *DynamicFieldConfig, result interface{}) (*OptionPage, bool, error) {
+	switch v := result.(type) {
+	case *OptionPage:
+		return v, true, nil
+	case OptionPage:
+		return &v, true, nil
+	case map[string]interface{}:
+		raw, ok := v["options"]
+		if !ok {
+			return nil, false, nil
+		}
+		options, err := config.CreateOptionsFromResult(raw)
+		if err != nil {
+			return nil, false, err
+		}
+		page := &OptionPage{Options: options}
+		page.NextCursor, _ = v["nextCursor"].(string)
+		return page, true, nil
+	}
+	return nil, false, nil
+}
+
+// handleOptionCursor answers a dynamic options request in cursor mode. Functions get the
+// cursor, limit, and search of the request as arguments, to page through their dataset
+// themselves; every option a function returns at once is paged by SearchAndSortPage.
+func (ah *APIHandler) handleOptionCursor(w http.ResponseWriter, r *http.Request, declared *DynamicFieldConfig, request *dynamicOptionsRequest) {
+	if request.Config == nil {
+		writeError(w, badRequest("Function config is required"))
+		return
+	}
+	limit := request.Limit
+	if limit <= 0 {
+		limit = declared.defaultLimit()
+	}
+	if limit <= 0 {
+		limit = DefaultOptionWindowSize
+	}
+
+	config := *request.Config
+	config.Arguments = make(map[string]interface{}, len(request.Config.Arguments)+3)
+	for key, value := range request.Config.Arguments {
+		config.Arguments[key] = value
+	}
+	config.Arguments["cursor"] = request.Cursor
+	config.Arguments["limit"] = limit
+	config.Arguments["search"] = request.Search
+
+	result, err := config.ExecuteWithFormState(ah.dynamicFunctionService, ah.withRenderContext(r, request.FormState))
+	if err != nil {
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing dynamic field function: %v", err)))
+		return
+	}
+
+	page, paged, err := optionPageFromResult(&config, result)
+	if err == nil && !paged {
+		var options []*Option
+		options, err = config.CreateOptionsFromResult(result)
+		if err == nil {
+			page, err = ah.dynamicFunctionService.SearchAndSortPage(options, map[string]interface{}{
+				"search":  request.Search,
+				"sort":    request.Sort,
+				"sortDir": request.SortDirection,
+				"filters": request.Filters,
+				"cursor":  request.Cursor,
+				"limit":   float64(limit),
+			})
+		}
+	}
+	if errors.Is(err, ErrInvalidCursor) {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error converting result to options: %v", err)))
+		return
+	}
+
+	pagination := map[string]interface{}{"limit": limit, "nextCursor": page.NextCursor}
+	if page.Total > 0 {
+		pagination["total"] = page.Total
+	}
+	options := page.Options
+	if options == nil {
+		options = []*Option{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"options":    options,
+		"pagination": pagination,
+	})
+}
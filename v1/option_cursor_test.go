@@ -0,0 +1,128 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// numberedOptions returns options with the values 1 to n
+func numberedOptions(n int) []*Option {
+	options := make([]*Option, n)
+	for i := range options {
+		options[i] = &Option{Value: fmt.Sprint(i + 1), Label: fmt.Sprintf("Option %d", i+1)}
+	}
+	return options
+}
+
+func TestSearchAndSortPage(t *testing.T) {
+	service := NewDynamicFunctionService()
+	options := numberedOptions(5)
+
+	first, err := service.SearchAndSortPage(options, map[string]interface{}{"limit": float64(2)})
+	require.NoError(t, err)
+	assert.Equal(t, []*Option{options[0], options[1]}, first.Options)
+	assert.Equal(t, 5, first.Total)
+	require.NotEmpty(t, first.NextCursor)
+
+	// Removing an option before the cursor does not skip or repeat options
+	second, err := service.SearchAndSortPage(options[1:], map[string]interface{}{"limit": float64(2), "cursor": first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []*Option{options[2], options[3]}, second.Options)
+
+	last, err := service.SearchAndSortPage(options, map[string]interface{}{"limit": float64(2), "cursor": second.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []*Option{options[4]}, last.Options)
+	assert.Empty(t, last.NextCursor)
+
+	paged, err := service.SearchAndSort(options, map[string]interface{}{"limit": float64(2), "cursor": first.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []*Option{options[2], options[3]}, paged)
+
+	_, err = service.SearchAndSortPage(options, map[string]interface{}{"cursor": "not a cursor"})
+	assert.True(t, errors.Is(err, ErrInvalidCursor))
+}
+
+func TestDynamicOptionsCursorMode(t *testing.T) {
+	country := NewFieldBuilder("country", FieldTypeSelect, "Country").Build()
+	country.Options = NewOptionsBuilder().Dynamic().WithFunctionOptions("countries").WithPagination(40, PaginationModeCursor).Build()
+	customer := NewFieldBuilder("customer", FieldTypeSelect, "Customer").Build()
+	customer.Options = NewOptionsBuilder().Dynamic().WithFunctionOptions("customers").Build()
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").AddField(country).AddField(customer).Build()))
+
+	service := NewDynamicFunctionService()
+	service.RegisterFunction("countries", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return numberedOptions(100), nil
+	})
+	// Customers are paged by the function itself, as a database would
+	service.RegisterFunction("customers", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		start := 0
+		if cursor, _ := args["cursor"].(string); cursor != "" {
+			_, _ = fmt.Sscan(cursor, &start)
+		}
+		page := &OptionPage{Options: numberedOptions(start + args["limit"].(int))[start:]}
+		if start == 0 {
+			page.NextCursor = fmt.Sprint(args["limit"])
+		}
+		return page, nil
+	})
+	handler.SetDynamicFunctionService(service)
+	mux := handler.Handler()
+
+	type response struct {
+		Options    []*Option `json:"options"`
+		Pagination struct {
+			Limit      int    `json:"limit"`
+			NextCursor string `json:"nextCursor"`
+			Total      int    `json:"total"`
+		} `json:"pagination"`
+	}
+	fetch := func(field, function, cursor string) *response {
+		body := fmt.Sprintf(`{"config": {"functionName": %q}, "cursor": %q}`, function, cursor)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/options/dynamic/order/"+field, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var decoded response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+		return &decoded
+	}
+
+	// Countries are declared in cursor mode, so even the first request is paged by cursor
+	var values []interface{}
+	cursor, pages := "", 0
+	for {
+		page := fetch("country", "countries", cursor)
+		assert.Equal(t, 40, page.Pagination.Limit)
+		assert.Equal(t, 100, page.Pagination.Total)
+		for _, option := range page.Options {
+			values = append(values, option.Value)
+		}
+		pages++
+		if cursor = page.Pagination.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, 3, pages)
+	require.Len(t, values, 100)
+	assert.Equal(t, "100", values[99])
+
+	first := fetch("customer", "customers", "0")
+	require.Len(t, first.Options, DefaultOptionWindowSize)
+	assert.Equal(t, "50", first.Pagination.NextCursor)
+	second := fetch("customer", "customers", first.Pagination.NextCursor)
+	assert.Equal(t, "51", second.Options[0].Value)
+	assert.Empty(t, second.Pagination.NextCursor)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/options/dynamic/order/country",
+		strings.NewReader(`{"config": {"functionName": "countries"}, "cursor": "bogus"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
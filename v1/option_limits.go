@@ -0,0 +1,292 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// AllSources sets the limit every option source gets unless it has one of its own
+const AllSources = "*"
+
+// OptionsUnavailableCode identifies responses shedding load from a saturated option source
+const OptionsUnavailableCode = "options_unavailable"
+
+// ServiceDisconnectedCode identifies responses for options of a service that is not connected
+const ServiceDisconnectedCode = "service_disconnected"
+
+// DefaultOptionsRetryAfter is how long clients are asked to wait when load is shed
+const DefaultOptionsRetryAfter = time.Second
+
+// ErrOptionsUnavailable is matched by the errors returned when load is shed from an option source
+// or its service is not connected
+var ErrOptionsUnavailable = errors.New("options temporarily unavailable")
+
+// OptionSourceLimit caps the load put on an upstream option source
+type OptionSourceLimit struct {
+	MaxConcurrent int           // Requests in flight at once
+	MaxQueued     int           // Requests waiting for a free slot; more are shed at once
+	QueueTimeout  time.Duration // How long a request waits for a free slot before it is shed
+	RetryAfter    time.Duration // How long clients are asked to wait when load is shed (default 1s)
+}
+
+// OptionSourceStats reports how saturated an option source is
+type OptionSourceStats struct {
+	Source        string       `json:"source"`
+	MaxConcurrent int          `json:"maxConcurrent"`
+	MaxQueued     int          `json:"maxQueued"`
+	Active        int64        `json:"active"`
+	Queued        int64        `json:"queued"`
+	Served        uint64       `json:"served"`
+	Shed          uint64       `json:"shed"`
+	Saturation    float64      `json:"saturation"`        // Share of the slots in use
+	Circuit       CircuitState `json:"circuit,omitempty"` // State of the circuit breaker, if the source has one
+}
+
+// OptionsUnavailableError is returned, and sent to clients, when load is shed from a
+// saturated option source or the service of a source is not connected. Clients may retry after
+// RetryAfter seconds.
+type OptionsUnavailableError struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Source     string `json:"source"`
+	RetryAfter int    `json:"retryAfter"`
+}
+
+// Error implements the error interface
+func (oe *OptionsUnavailableError) Error() string {
+	return oe.Message
+}
+
+// Is makes the error match ErrOptionsUnavailable
+func (oe *OptionsUnavailableError) Is(target error) bool {
+	return target == ErrOptionsUnavailable
+}
+
+// sourceLimiter enforces the limit of one option source
+type sourceLimiter struct {
+	limit  OptionSourceLimit
+	slots  chan struct{}
+	active atomic.Int64
+	queued atomic.Int64
+	served atomic.Uint64
+	shed   atomic.Uint64
+}
+
+// newSourceLimiter creates a limiter enforcing limit
+func newSourceLimiter(limit OptionSourceLimit) *sourceLimiter {
+	if limit.MaxConcurrent <= 0 {
+		limit.MaxConcurrent = 1
+	}
+	if limit.RetryAfter <= 0 {
+		limit.RetryAfter = DefaultOptionsRetryAfter
+	}
+	return &sourceLimiter{
+		limit: limit,
+		slots: make(chan struct{}, limit.MaxConcurrent),
+	}
+}
+
+// acquire takes a slot, waiting in the queue while there is room in it
+func (sl *sourceLimiter) acquire(ctx context.Context) bool {
+	select {
+	case sl.slots <- struct{}{}:
+		sl.active.Add(1)
+		return true
+	default:
+	}
+
+	if sl.queued.Add(1) > int64(sl.limit.MaxQueued) {
+		sl.queued.Add(-1)
+		return false
+	}
+	defer sl.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if sl.limit.QueueTimeout > 0 {
+		timer := time.NewTimer(sl.limit.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case sl.slots <- struct{}{}:
+		sl.active.Add(1)
+		return true
+	case <-timeout:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot
+func (sl *sourceLimiter) release() {
+	sl.active.Add(-1)
+	<-sl.slots
+}
+
+// stats reports the limiter's current load
+func (sl *sourceLimiter) stats(source string) *OptionSourceStats {
+	active := sl.active.Load()
+	return &OptionSourceStats{
+		Source:        source,
+		MaxConcurrent: sl.limit.MaxConcurrent,
+		MaxQueued:     sl.limit.MaxQueued,
+		Active:        active,
+		Queued:        sl.queued.Load(),
+		Served:        sl.served.Load(),
+		Shed:          sl.shed.Load(),
+		Saturation:    float64(active) / float64(sl.limit.MaxConcurrent),
+	}
+}
+
+// SetSourceLimit caps the requests made to an option source at once. API sources are named by
+// the host of their endpoint and windowed sources by their registered name; AllSources sets the
+// limit of every source without one of its own. Requests beyond the cap wait in a queue, and
+// are shed with an OptionsUnavailableError when the queue is full or they time out.
+func (os *OptionService) SetSourceLimit(source string, limit OptionSourceLimit) {
+	os.limitLock.Lock()
+	defer os.limitLock.Unlock()
+
+	if os.sourceLimits == nil {
+		os.sourceLimits = make(map[string]OptionSourceLimit)
+		os.limiters = make(map[string]*sourceLimiter)
+	}
+	os.sourceLimits[source] = limit
+	if source == AllSources {
+		// Sources using the previous default pick up the new one
+		for name := range os.limiters {
+			if _, own := os.sourceLimits[name]; !own {
+				delete(os.limiters, name)
+			}
+		}
+		return
+	}
+	delete(os.limiters, source)
+}
+
+// SourceStats reports the load of every limited option source, and the circuit state of every
+// source with a circuit breaker, ordered by name
+func (os *OptionService) SourceStats() []*OptionSourceStats {
+	os.limitLock.Lock()
+	defer os.limitLock.Unlock()
+
+	stats := make([]*OptionSourceStats, 0, len(os.limiters))
+	bySource := make(map[string]*OptionSourceStats, len(os.limiters))
+	for source, limiter := range os.limiters {
+		bySource[source] = limiter.stats(source)
+		stats = append(stats, bySource[source])
+	}
+	for source, breaker := range os.breakers {
+		sourceStats, ok := bySource[source]
+		if !ok {
+			sourceStats = &OptionSourceStats{Source: source}
+			stats = append(stats, sourceStats)
+		}
+		breaker.lock.Lock()
+		sourceStats.Circuit = breaker.state
+		breaker.lock.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+// limiter returns the limiter of a source, or nil if the source is not limited
+func (os *OptionService) limiter(source string) *sourceLimiter {
+	os.limitLock.Lock()
+	defer os.limitLock.Unlock()
+
+	if limiter, ok := os.limiters[source]; ok {
+		return limiter
+	}
+	limit, ok := os.sourceLimits[source]
+	if !ok {
+		limit, ok = os.sourceLimits[AllSources]
+	}
+	if !ok {
+		return nil
+	}
+	limiter := newSourceLimiter(limit)
+	os.limiters[source] = limiter
+	return limiter
+}
+
+// withSourceLimit calls fetch once the source has a free slot, or sheds the request
+func (os *OptionService) withSourceLimit(ctx context.Context, source string, fetch func() error) error {
+	limiter := os.limiter(source)
+	if limiter == nil {
+		return fetch()
+	}
+
+	if !limiter.acquire(ctx) {
+		limiter.shed.Add(1)
+		return &OptionsUnavailableError{
+			Code:       OptionsUnavailableCode,
+			Message:    fmt.Sprintf("options from %s are temporarily unavailable", source),
+			Source:     source,
+			RetryAfter: int(math.Ceil(limiter.limit.RetryAfter.Seconds())),
+		}
+	}
+	defer limiter.release()
+
+	limiter.served.Add(1)
+	return fetch()
+}
+
+// endpointSource names the option source of an API endpoint by its host
+func endpointSource(endpoint string) string {
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return endpoint
+}
+
+// writeOptionsError writes an error fetching options. Shed load is answered with 503 and a
+// typed body the client can retry on; other errors are described after prefix.
+func writeOptionsError(w http.ResponseWriter, prefix string, err error) {
+	var unavailable *OptionsUnavailableError
+	if !errors.As(err, &unavailable) {
+		writeError(w, fmt.Errorf("%s: %w", prefix, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(unavailable.RetryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(unavailable)
+}
+
+// SetOptionSourceLimit caps the requests made to an option source at once
+func (ah *APIHandler) SetOptionSourceLimit(source string, limit OptionSourceLimit) {
+	ah.optionService.SetSourceLimit(source, limit)
+}
+
+// handleOptionMetrics handles staff requests for the saturation of limited option sources
+func (ah *APIHandler) handleOptionMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ah.optionService.SourceStats())
+}
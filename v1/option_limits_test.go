@@ -0,0 +1,95 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionSourceLimit(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		_, _ = w.Write([]byte(`[{"id": "p1", "name": "Product 1"}]`))
+	}))
+	defer upstream.Close()
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("product", FieldTypeSelect, "Product").
+			WithOptionsFromAPI(upstream.URL+"/products?page=${page}", "GET", "id", "name").Build()).
+		Build()))
+	handler.SetOptionSourceLimit(AllSources, OptionSourceLimit{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+		QueueTimeout:  20 * time.Millisecond,
+		RetryAfter:    2 * time.Second,
+	})
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) { return "admin", r.Header.Get("X-Staff") == "yes" })
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/order/product?page=1", nil))
+		first <- rec
+	}()
+	<-started
+
+	// The only slot is taken, so the next render waits in the queue and is then shed
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/order/product?page=2", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code, rec.Body.String())
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	var unavailable OptionsUnavailableError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &unavailable))
+	assert.Equal(t, OptionsUnavailableCode, unavailable.Code)
+	assert.Equal(t, 2, unavailable.RetryAfter)
+
+	// Source metrics are for staff only
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/metrics/options", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/metrics/options", nil)
+	req.Header.Set("X-Staff", "yes")
+	mux.ServeHTTP(rec, req)
+	var stats []*OptionSourceStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.Len(t, stats, 1)
+	assert.Equal(t, upstream.Listener.Addr().String(), stats[0].Source)
+	assert.Equal(t, int64(1), stats[0].Active)
+	assert.Equal(t, uint64(1), stats[0].Served)
+	assert.Equal(t, uint64(1), stats[0].Shed)
+	assert.Equal(t, 1.0, stats[0].Saturation)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-first).Code)
+	assert.Equal(t, int64(0), handler.optionService.SourceStats()[0].Active)
+}
+
+func TestOptionSourceLimitQueue(t *testing.T) {
+	limiter := newSourceLimiter(OptionSourceLimit{MaxConcurrent: 1, MaxQueued: 1, QueueTimeout: time.Second})
+	require.True(t, limiter.acquire(t.Context()))
+
+	// A queued request gets the slot once it is released
+	acquired := make(chan bool)
+	go func() { acquired <- limiter.acquire(t.Context()) }()
+	require.Eventually(t, func() bool { return limiter.queued.Load() == 1 }, time.Second, time.Millisecond)
+
+	// The queue is full, so further requests are shed at once
+	assert.False(t, limiter.acquire(t.Context()))
+
+	limiter.release()
+	assert.True(t, <-acquired)
+	limiter.release()
+}
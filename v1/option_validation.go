@@ -0,0 +1,166 @@
+package smartform
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OptionResolver loads the effective options of a field whose options come from a dynamic source
+type OptionResolver func(field *Field, data map[string]interface{}) ([]*Option, error)
+
+// effectiveOptions returns the options a submitted value must be one of. The second
+// result is false when the option set cannot be determined, in which case the value
+// is not checked.
+func (v *Validator) effectiveOptions(field *Field, data map[string]interface{}) ([]*Option, bool) {
+	config := field.Options
+
+	switch config.Type {
+	case OptionsTypeStatic:
+		return config.Static, true
+
+	case OptionsTypeDependent:
+		if config.Dependency == nil || config.Dependency.Expression != "" {
+			return nil, false
+		}
+		dependencyValue := v.getValueByPath(data, config.Dependency.Field)
+		return config.Dependency.ValueMap[fmt.Sprintf("%v", dependencyValue)], true
+
+	case OptionsTypeConditional:
+		return v.availableOptions(config.Static, data), true
+
+	case OptionsTypeDynamic:
+		// Re-fetch the options so that the check sees the same set the client was offered
+		if v.options != nil && v.options.OptionResolver != nil {
+			if options, err := v.options.OptionResolver(field, data); err == nil {
+				return options, true
+			}
+		}
+		if config.DynamicSource != nil && config.DynamicSource.Type == "function" {
+			options, err := v.schema.GetOptionsFromFunction(config.DynamicSource, data)
+			return options, err == nil
+		}
+		return nil, false
+
+	default:
+		return nil, false
+	}
+}
+
+// validateOptionValue checks that a submitted value, or each value of a multi-select,
+// is one of the field's effective options and has the declared option value type
+func (v *Validator) validateOptionValue(field *Field, fieldPath string, value interface{}, data map[string]interface{}, result *ValidationResult) {
+	if field.Options == nil {
+		return
+	}
+
+	options, ok := v.effectiveOptions(field, data)
+	if !ok {
+		return
+	}
+
+	values := []interface{}{value}
+	multiple := false
+	if list, isList := value.([]interface{}); isList {
+		values = list
+		multiple = true
+	}
+
+	dataType := declaredOptionValueType(field)
+	for i, item := range values {
+		itemPath := fieldPath
+		if multiple {
+			itemPath = fmt.Sprintf("%s[%d]", fieldPath, i)
+
+			// Single values were already interpreted with the rest of the field
+			var valid bool
+			if item, valid = v.interpretAs(dataType, field, itemPath, item, result); !valid {
+				continue
+			}
+		}
+
+		if !containsOptionValue(options, item) {
+			v.addError(result, &ValidationError{
+				FieldID:  itemPath,
+				Message:  fmt.Sprintf("%v is not a valid option for %s", item, field.Label),
+				RuleType: string(ValidationTypeOption),
+			})
+		}
+	}
+}
+
+// containsOptionValue reports whether value is one of the options. Numbers match
+// regardless of their Go type; all other values must match exactly, including type.
+func containsOptionValue(options []*Option, value interface{}) bool {
+	for _, option := range options {
+		if optionValuesEqual(option.Value, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// optionValuesEqual compares two option values
+func optionValuesEqual(a, b interface{}) bool {
+	if hasDataType(string(OptionValueTypeNumber), a) && hasDataType(string(OptionValueTypeNumber), b) {
+		return toFloat64(a) == toFloat64(b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat64 converts any Go number to float64
+func toFloat64(value interface{}) float64 {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	default:
+		return 0
+	}
+}
+
+// CheckOptionTypes verifies that the options of every field with a declared option
+// value type have values of that type
+func (fs *FormSchema) CheckOptionTypes() error {
+	var check func(fields []*Field) error
+	check = func(fields []*Field) error {
+		for _, field := range fields {
+			if dataType := declaredOptionValueType(field); dataType != "" {
+				options := append([]*Option{}, field.Options.Static...)
+				if field.Options.Dependency != nil {
+					for _, dependent := range field.Options.Dependency.ValueMap {
+						options = append(options, dependent...)
+					}
+				}
+				for _, option := range options {
+					if !hasDataType(dataType, option.Value) {
+						return fmt.Errorf("field %s: option %v is not a %s", field.ID, option.Value, dataType)
+					}
+				}
+			}
+			if err := check(field.Nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(fs.Fields)
+}
+
+// ValueType declares the data type of the option values
+func (ob *OptionsBuilder) ValueType(valueType OptionValueType) *OptionsBuilder {
+	ob.config.ValueType = valueType
+	return ob
+}
+
+// OptionValueType declares the data type of the field's option values
+func (fb *FieldBuilder) OptionValueType(valueType OptionValueType) *FieldBuilder {
+	if fb.field.Options == nil {
+		fb.field.Options = &OptionsConfig{}
+	}
+	fb.field.Options.ValueType = valueType
+	return fb
+}
@@ -0,0 +1,57 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// OptionValueType represents the declared data type of a field's option values
+type OptionValueType string
+
+// Define option value types
+const (
+	OptionValueTypeAny     OptionValueType = "any"     // Values are not type checked (default)
+	OptionValueTypeString  OptionValueType = "string"  // Values are strings
+	OptionValueTypeNumber  OptionValueType = "number"  // Values are numbers
+	OptionValueTypeBoolean OptionValueType = "boolean" // Values are booleans
+)
+
+// Values provides the possible values for OptionValueType, compatible with entgo.
+func (OptionValueType) Values() (types []string) {
+	return []string{
+		string(OptionValueTypeAny),
+		string(OptionValueTypeString),
+		string(OptionValueTypeNumber),
+		string(OptionValueTypeBoolean),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (p OptionValueType) MarshalText() ([]byte, error) {
+	return []byte(p), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (p *OptionValueType) UnmarshalText(text []byte) error {
+	switch OptionValueType(text) {
+	case OptionValueTypeAny, OptionValueTypeString, OptionValueTypeNumber, OptionValueTypeBoolean:
+		*p = OptionValueType(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid OptionValueType: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (p *OptionValueType) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("OptionValueType should be a string, got %T", value)
+	}
+	return p.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (p OptionValueType) Value() (driver.Value, error) {
+	return string(p), nil
+}
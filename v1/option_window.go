@@ -0,0 +1,261 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// OptionSourceTypeWindow is the dynamic source type of options served window by window
+const OptionSourceTypeWindow = "window"
+
+// Window sizes for windowed option sources
+const (
+	DefaultOptionWindowSize = 50
+	MaxOptionWindowSize     = 500
+)
+
+// OptionWindowQuery asks a windowed source for part of its options
+type OptionWindowQuery struct {
+	Search  string                 `json:"search,omitempty"`
+	Offset  int                    `json:"offset"`
+	Limit   int                    `json:"limit"`
+	Context map[string]interface{} `json:"context,omitempty"` // Other request parameters, e.g. values of dependent fields
+}
+
+// OptionWindow is one window of a large option list
+type OptionWindow struct {
+	Options []*Option `json:"options"`
+	Total   int       `json:"total"` // Number of options matching the search, across all windows
+	Offset  int       `json:"offset"`
+	Limit   int       `json:"limit"`
+}
+
+// OptionWindowSource serves a large option list, such as all customers, without ever
+// loading it whole. Implementations usually translate queries to paged database or API calls.
+type OptionWindowSource interface {
+	// Window returns the options in the requested window and the total matching the search
+	Window(ctx context.Context, query *OptionWindowQuery) (*OptionWindow, error)
+	// Resolve returns the options for the given values, leaving out values that do not exist
+	Resolve(ctx context.Context, values []interface{}) ([]*Option, error)
+}
+
+// RegisterWindowSource registers a windowed option source under a name
+func (os *OptionService) RegisterWindowSource(name string, source OptionWindowSource) {
+	os.windowLock.Lock()
+	defer os.windowLock.Unlock()
+	os.windowSources[name] = source
+}
+
+// windowSource returns the registered windowed source of a dynamic source
+func (os *OptionService) windowSource(source *DynamicSource) (OptionWindowSource, error) {
+	os.windowLock.RLock()
+	defer os.windowLock.RUnlock()
+	windowSource, ok := os.windowSources[source.WindowSource]
+	if !ok {
+		return nil, fmt.Errorf("option window source %s not registered", source.WindowSource)
+	}
+	return windowSource, nil
+}
+
+// RegisterOptionWindowSource registers a windowed option source for fields configured with
+// FromWindowSource
+func (ah *APIHandler) RegisterOptionWindowSource(name string, source OptionWindowSource) {
+	ah.optionService.RegisterWindowSource(name, source)
+}
+
+// isWindowed reports whether a field's options are served by a windowed source
+func isWindowed(field *Field) bool {
+	return field.Options != nil && field.Options.DynamicSource != nil &&
+		field.Options.DynamicSource.Type == OptionSourceTypeWindow
+}
+
+// parseOptionWindowQuery reads the offset, limit, and search query parameters, bounding
+// the window size. The remaining parameters become the query context.
+func parseOptionWindowQuery(query url.Values, pageSize int) (*OptionWindowQuery, error) {
+	windowQuery := &OptionWindowQuery{
+		Search:  query.Get("search"),
+		Limit:   pageSize,
+		Context: map[string]interface{}{},
+	}
+	if windowQuery.Limit <= 0 {
+		windowQuery.Limit = DefaultOptionWindowSize
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid offset: %s", offset)
+		}
+		windowQuery.Offset = n
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit: %s", limit)
+		}
+		windowQuery.Limit = n
+	}
+	if windowQuery.Limit > MaxOptionWindowSize {
+		windowQuery.Limit = MaxOptionWindowSize
+	}
+
+	for key, values := range query {
+		switch key {
+		case "search", "offset", "limit":
+			continue
+		}
+		if len(values) > 0 {
+			windowQuery.Context[key] = values[0]
+		}
+	}
+	return windowQuery, nil
+}
+
+// handleOptionWindow serves one window of a field's options
+func (ah *APIHandler) handleOptionWindow(w http.ResponseWriter, r *http.Request, field *Field) {
+	source, err := ah.optionService.windowSource(field.Options.DynamicSource)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	query, err := parseOptionWindowQuery(r.URL.Query(), field.Options.DynamicSource.PageSize)
+	if err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	var window *OptionWindow
+	err = ah.optionService.withSourceLimit(r.Context(), field.Options.DynamicSource.WindowSource, func() (err error) {
+		window, err = source.Window(r.Context(), query)
+		return err
+	})
+	if err != nil {
+		writeOptionsError(w, "Error fetching options", err)
+		return
+	}
+	if window.Options == nil {
+		window.Options = []*Option{}
+	}
+	window.Offset = query.Offset
+	window.Limit = query.Limit
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(window)
+}
+
+// handleResolveOptions resolves selected values of a field to their options, so that clients
+// can show labels for values outside the windows they have loaded
+func (ah *APIHandler) handleResolveOptions(w http.ResponseWriter, r *http.Request, field *Field) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Values  []interface{}          `json:"values"`
+		Context map[string]interface{} `json:"context,omitempty"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if len(request.Values) > MaxOptionWindowSize {
+		writeError(w, NewAPIError(http.StatusBadRequest, ErrorCodeBadRequest, fmt.Sprintf("At most %d values can be resolved at once", MaxOptionWindowSize)))
+		return
+	}
+
+	data := ah.withRenderContext(r, request.Context)
+	options, err := ah.resolveOptionValues(r.Context(), ah.requestIdentity(r), field, request.Values, data)
+	if err != nil {
+		writeOptionsError(w, "Error resolving options", err)
+		return
+	}
+
+	// Values no longer offered by the source are reported so views can show them as they are
+	missing := []interface{}{}
+	for _, value := range request.Values {
+		if !containsOptionValue(options, value) {
+			missing = append(missing, value)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"options": options, "missing": missing})
+}
+
+// resolveOptionValues returns the options of a field matching the given values. Windowed
+// sources resolve them directly; other fields are looked up in their full option list, from
+// their static options, dependency, API, or function.
+func (ah *APIHandler) resolveOptionValues(ctx context.Context, identity CacheIdentity, field *Field, values []interface{}, data map[string]interface{}) ([]*Option, error) {
+	if isWindowed(field) {
+		source, err := ah.optionService.windowSource(field.Options.DynamicSource)
+		if err != nil {
+			return nil, err
+		}
+		var options []*Option
+		err = ah.optionService.withSourceLimit(ctx, field.Options.DynamicSource.WindowSource, func() (err error) {
+			options, err = source.Resolve(ctx, values)
+			return err
+		})
+		if options == nil {
+			options = []*Option{}
+		}
+		return options, err
+	}
+
+	var all []*Option
+	switch {
+	case field.Options.Type == OptionsTypeStatic:
+		all = field.Options.Static
+	case field.Options.Type == OptionsTypeDependent:
+		all = dependentOptions(field.Options.Dependency, data)
+	case field.Options.Type == OptionsTypeConditional:
+		// Selected values keep their labels while the state no longer offers them
+		all = field.Options.Static
+	case field.Options.DynamicSource != nil:
+		var err error
+		if all, err = ah.resolveFieldOptions(identity, field, data); err != nil {
+			return nil, err
+		}
+	}
+
+	options := []*Option{}
+	for _, value := range values {
+		for _, option := range all {
+			if optionValuesEqual(option.Value, value) {
+				options = append(options, option)
+				break
+			}
+		}
+	}
+	return options, nil
+}
+
+// dependentOptions returns the options of a dependency for the value of the field it depends
+// on. Without that value, the options for every value are returned, in the order of the values
+// they are mapped to.
+func dependentOptions(dependency *OptionsDependency, data map[string]interface{}) []*Option {
+	if dependency == nil {
+		return nil
+	}
+	if value, ok := data[dependency.Field]; ok {
+		return dependency.ValueMap[fmt.Sprintf("%v", value)]
+	}
+
+	keys := make([]string, 0, len(dependency.ValueMap))
+	for key := range dependency.ValueMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var options []*Option
+	for _, key := range keys {
+		options = append(options, dependency.ValueMap[key]...)
+	}
+	return options
+}
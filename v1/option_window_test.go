@@ -0,0 +1,150 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// customerSource pretends to page through a million customers
+type customerSource struct {
+	lastQuery *OptionWindowQuery
+}
+
+func (cs *customerSource) Window(ctx context.Context, query *OptionWindowQuery) (*OptionWindow, error) {
+	cs.lastQuery = query
+	options := []*Option{}
+	for i := query.Offset; i < query.Offset+query.Limit && i < 1000000; i++ {
+		options = append(options, NewOption(float64(i), fmt.Sprintf("Customer %d", i)))
+	}
+	return &OptionWindow{Options: options, Total: 1000000}, nil
+}
+
+func (cs *customerSource) Resolve(ctx context.Context, values []interface{}) ([]*Option, error) {
+	options := []*Option{}
+	for _, value := range values {
+		if id, ok := value.(float64); ok && id >= 0 && id < 1000000 {
+			options = append(options, NewOption(id, fmt.Sprintf("Customer %d", int(id))))
+		}
+	}
+	return options, nil
+}
+
+func TestOptionWindowSource(t *testing.T) {
+	source := &customerSource{}
+	handler := NewAPIHandler()
+	handler.RegisterOptionWindowSource("customers", source)
+
+	customer := NewFieldBuilder("customer", FieldTypeSelect, "Customer").Build()
+	customer.Options = NewOptionsBuilder().Dynamic().FromWindowSource("customers", 20).Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").AddField(customer).Build()))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/order/customer?offset=100&region=eu", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var window OptionWindow
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &window))
+	assert.Equal(t, 1000000, window.Total)
+	assert.Equal(t, 20, window.Limit)
+	require.Len(t, window.Options, 20)
+	assert.Equal(t, "Customer 100", window.Options[0].Label)
+	assert.Equal(t, "eu", source.lastQuery.Context["region"])
+
+	// Window sizes are bounded
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/options/order/customer?limit=100000", nil))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &window))
+	assert.Len(t, window.Options, MaxOptionWindowSize)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/options/order/customer/resolve",
+		strings.NewReader(`{"values": [7, 999999, 5000000]}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resolved struct {
+		Options []*Option `json:"options"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resolved))
+	require.Len(t, resolved.Options, 2)
+	assert.Equal(t, "Customer 999999", resolved.Options[1].Label)
+
+	// Submitted values are checked by resolving them, not by loading the list
+	validate := func(body string) bool {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/order", strings.NewReader(body)))
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result.Valid
+	}
+	assert.True(t, validate(`{"customer": 42}`))
+	assert.False(t, validate(`{"customer": 5000000}`))
+}
+
+func TestResolveStaticOptions(t *testing.T) {
+	handler := NewAPIHandler()
+	color := NewFieldBuilder("color", FieldTypeSelect, "Color").Build()
+	color.Options = NewOptionsBuilder().Static().AddOption("red", "Red").AddOption("blue", "Blue").Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("paint", "Paint").AddField(color).Build()))
+
+	options, err := handler.resolveOptionValues(context.Background(), nil, color, []interface{}{"blue", "green"}, nil)
+	require.NoError(t, err)
+	require.Len(t, options, 1)
+	assert.Equal(t, "Blue", options[0].Label)
+}
+
+func TestResolveOptionsFromEverySource(t *testing.T) {
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("plans", func(args, state map[string]interface{}) (interface{}, error) {
+		return []*Option{NewOption("pro", "Pro"), NewOption("team", "Team")}, nil
+	})
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(functions)
+
+	plan := NewFieldBuilder("plan", FieldTypeSelect, "Plan").Build()
+	plan.Options = NewOptionsBuilder().Dynamic().FromFunction("plans").Build()
+	city := NewFieldBuilder("city", FieldTypeSelect, "City").Build()
+	city.Options = NewOptionsBuilder().Dependent("country").
+		WhenEquals("FR").AddOption("paris", "Paris").End().
+		WhenEquals("UK").AddOption("london", "London").End().
+		Build()
+	require.NoError(t, handler.RegisterSchema(NewForm("account", "Account").AddField(plan).AddField(city).Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	resolve := func(path, body string) (labels []string, missing []interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resolved struct {
+			Options []*Option     `json:"options"`
+			Missing []interface{} `json:"missing"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resolved))
+		for _, option := range resolved.Options {
+			labels = append(labels, option.Label)
+		}
+		return labels, resolved.Missing
+	}
+
+	labels, missing := resolve("/api/options/account/plan/resolve", `{"values": ["team", "legacy"]}`)
+	assert.Equal(t, []string{"Team"}, labels)
+	assert.Equal(t, []interface{}{"legacy"}, missing)
+
+	// Without the value of the field they depend on, dependent options are looked up in every list
+	labels, missing = resolve("/api/options/account/city/resolve", `{"values": ["london", "paris"]}`)
+	assert.Equal(t, []string{"London", "Paris"}, labels)
+	assert.Empty(t, missing)
+
+	labels, missing = resolve("/api/options/account/city/resolve", `{"values": ["london"], "context": {"country": "FR"}}`)
+	assert.Empty(t, labels)
+	assert.Equal(t, []interface{}{"london"}, missing)
+}
@@ -36,6 +36,14 @@ func (ob *OptionsBuilder) Dependent(field string) *DependentOptionsBuilder {
 	return &DependentOptionsBuilder{ob}
 }
 
+// Conditional creates a conditional options configuration, whose options are offered while
+// their conditions hold for the form state
+func (ob *OptionsBuilder) Conditional() *ConditionalOptionsBuilder {
+	ob.config.Type = OptionsTypeConditional
+	ob.config.Static = []*Option{}
+	return &ConditionalOptionsBuilder{ob}
+}
+
 // GetDynamicSource extracts the dynamic source from the options config
 func (ob *OptionsBuilder) GetDynamicSource() *DynamicSource {
 	if ob.config.Type == OptionsTypeDynamic {
@@ -81,6 +89,23 @@ func (sob *StaticOptionsBuilder) AddOptions(options ...*Option) *StaticOptionsBu
 	return sob
 }
 
+// ConditionalOptionsBuilder provides a fluent API for creating conditional options
+type ConditionalOptionsBuilder struct {
+	*OptionsBuilder
+}
+
+// AddOption adds an option offered whatever the form state
+func (cob *ConditionalOptionsBuilder) AddOption(value interface{}, label string) *ConditionalOptionsBuilder {
+	cob.config.Static = append(cob.config.Static, NewOption(value, label))
+	return cob
+}
+
+// AddOptionWhen adds an option offered while a condition holds
+func (cob *ConditionalOptionsBuilder) AddOptionWhen(condition *Condition, value interface{}, label string) *ConditionalOptionsBuilder {
+	cob.config.Static = append(cob.config.Static, NewConditionalOption(value, label, condition))
+	return cob
+}
+
 // DynamicOptionsBuilder provides a fluent API for creating dynamic options
 type DynamicOptionsBuilder struct {
 	*OptionsBuilder
@@ -113,6 +138,13 @@ func (dob *DynamicOptionsBuilder) WithHeader(key string, value string) *DynamicO
 	return dob
 }
 
+// UsingService authorizes API requests with the token saved for a connected service. While the
+// service is not connected the options are unavailable.
+func (dob *DynamicOptionsBuilder) UsingService(serviceID string) *DynamicOptionsBuilder {
+	dob.config.DynamicSource.ServiceID = serviceID
+	return dob
+}
+
 // WithParameter adds a parameter to the API request
 func (dob *DynamicOptionsBuilder) WithParameter(key string, value interface{}) *DynamicOptionsBuilder {
 	if dob.config.DynamicSource.Parameters == nil {
@@ -140,10 +172,26 @@ func (dob *DynamicOptionsBuilder) RefreshOn(fieldIDs ...string) *DynamicOptionsB
 	return dob
 }
 
+// WithFallback sets the options served while the API cannot be fetched, so forms degrade
+// gracefully when it is down
+func (dob *DynamicOptionsBuilder) WithFallback(options ...*Option) *DynamicOptionsBuilder {
+	dob.config.DynamicSource.Fallback = append(dob.config.DynamicSource.Fallback, options...)
+	return dob
+}
+
 // FromFunction configures options to be generated by a custom function
 func (dob *DynamicOptionsBuilder) FromFunction(functionName string) *DynamicOptionsBuilder {
 	dob.config.DynamicSource.Type = "function"
-	// Function-specific configurations would go here
+	dob.config.DynamicSource.FunctionName = functionName
+	return dob
+}
+
+// FromWindowSource configures options to be served window by window from a registered
+// OptionWindowSource, for lists too large to send to the client
+func (dob *DynamicOptionsBuilder) FromWindowSource(name string, pageSize int) *DynamicOptionsBuilder {
+	dob.config.DynamicSource.Type = OptionSourceTypeWindow
+	dob.config.DynamicSource.WindowSource = name
+	dob.config.DynamicSource.PageSize = pageSize
 	return dob
 }
 
@@ -240,6 +288,15 @@ func NewOption(value interface{}, label string) *Option {
 	}
 }
 
+// NewConditionalOption creates a new option offered while a condition holds
+func NewConditionalOption(value interface{}, label string, condition *Condition) *Option {
+	return &Option{
+		Value:     value,
+		Label:     label,
+		Condition: condition,
+	}
+}
+
 // NewOptionWithIcon creates a new option with an icon
 func NewOptionWithIcon(value interface{}, label string, icon string) *Option {
 	return &Option{
@@ -0,0 +1,182 @@
+package smartform
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutputMapping reshapes validated form data into the payload downstream systems expect
+type OutputMapping struct {
+	Rules           []*OutputRule `json:"rules"`
+	IncludeUnmapped bool          `json:"includeUnmapped,omitempty"` // Copy top-level fields no rule reads from
+}
+
+// OutputRule writes one value of the output payload. Exactly one of Source, Value,
+// or Template provides the value.
+type OutputRule struct {
+	Target   string      `json:"target"`             // Dot path in the output, e.g. "customer.name"; "" is the root when flattening
+	Source   string      `json:"source,omitempty"`   // Dot path in the form data
+	Value    interface{} `json:"value,omitempty"`    // Constant value
+	Template string      `json:"template,omitempty"` // Template expression evaluated against the form data
+	Flatten  bool        `json:"flatten,omitempty"`  // Copy the leaves of the source object into the target, joining nested keys with "_"
+}
+
+// ApplyOutputMapping returns the output payload for validated form data. Without an
+// output mapping the data is returned unchanged.
+func (fs *FormSchema) ApplyOutputMapping(data map[string]interface{}) (map[string]interface{}, error) {
+	if fs.Output == nil {
+		return data, nil
+	}
+
+	output := map[string]interface{}{}
+	validator := NewValidator(fs)
+	resolver := fs.GetTemplateResolver()
+	consumed := map[string]bool{}
+
+	for _, rule := range fs.Output.Rules {
+		var value interface{}
+
+		switch {
+		case rule.Source != "":
+			value = validator.getValueByPath(data, rule.Source)
+			consumed[strings.SplitN(rule.Source, ".", 2)[0]] = true
+		case rule.Template != "":
+			resolved := resolver.ResolveFieldValue(rule.Target, rule.Template, data, &ResolutionOptions{
+				StrictMode: true,
+				MaxDepth:   10,
+			})
+			if resolved.Error != nil {
+				return nil, fmt.Errorf("error computing output %s: %w", rule.Target, resolved.Error)
+			}
+			value = resolved.Value
+		default:
+			value = cloneValue(rule.Value)
+		}
+
+		if rule.Flatten {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				if value == nil {
+					continue
+				}
+				return nil, fmt.Errorf("output %s: cannot flatten %T", rule.Target, value)
+			}
+			for key, leaf := range flattenMap(object, "") {
+				if err := setValueByPath(output, joinPath(rule.Target, key), leaf); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		if rule.Target == "" {
+			return nil, fmt.Errorf("output rule has no target")
+		}
+		if err := setValueByPath(output, rule.Target, cloneValue(value)); err != nil {
+			return nil, err
+		}
+	}
+
+	if fs.Output.IncludeUnmapped {
+		for key, value := range data {
+			if _, exists := output[key]; !exists && !consumed[key] {
+				output[key] = cloneValue(value)
+			}
+		}
+	}
+
+	return output, nil
+}
+
+// flattenMap flattens nested maps into a single level, joining keys with "_"
+func flattenMap(object map[string]interface{}, prefix string) map[string]interface{} {
+	flat := map[string]interface{}{}
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			for nestedKey, nestedValue := range flattenMap(nested, key) {
+				flat[nestedKey] = nestedValue
+			}
+			continue
+		}
+		flat[key] = cloneValue(value)
+	}
+	return flat
+}
+
+// joinPath joins two dot paths, either of which may be empty
+func joinPath(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+// setValueByPath sets a value in nested maps using a dot notation path, creating intermediate maps
+func setValueByPath(data map[string]interface{}, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+	current := data
+
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part]
+		if !ok || next == nil {
+			nextMap := map[string]interface{}{}
+			current[part] = nextMap
+			current = nextMap
+			continue
+		}
+
+		nextMap, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set %s: %s is not an object", path, part)
+		}
+		current = nextMap
+	}
+
+	current[parts[len(parts)-1]] = value
+	return nil
+}
+
+// outputMapping returns the schema's output mapping, creating it if necessary
+func (fb *FormBuilder) outputMapping() *OutputMapping {
+	if fb.schema.Output == nil {
+		fb.schema.Output = &OutputMapping{}
+	}
+	return fb.schema.Output
+}
+
+// MapOutput copies a form value to a path of the output payload
+func (fb *FormBuilder) MapOutput(target, source string) *FormBuilder {
+	mapping := fb.outputMapping()
+	mapping.Rules = append(mapping.Rules, &OutputRule{Target: target, Source: source})
+	return fb
+}
+
+// OutputConstant writes a constant value to a path of the output payload
+func (fb *FormBuilder) OutputConstant(target string, value interface{}) *FormBuilder {
+	mapping := fb.outputMapping()
+	mapping.Rules = append(mapping.Rules, &OutputRule{Target: target, Value: value})
+	return fb
+}
+
+// OutputTemplate writes the result of a template expression to a path of the output payload
+func (fb *FormBuilder) OutputTemplate(target, template string) *FormBuilder {
+	mapping := fb.outputMapping()
+	mapping.Rules = append(mapping.Rules, &OutputRule{Target: target, Template: template})
+	return fb
+}
+
+// FlattenOutput copies the leaves of a form object into the output payload under target
+func (fb *FormBuilder) FlattenOutput(target, source string) *FormBuilder {
+	mapping := fb.outputMapping()
+	mapping.Rules = append(mapping.Rules, &OutputRule{Target: target, Source: source, Flatten: true})
+	return fb
+}
+
+// IncludeUnmappedOutput copies form fields that no output rule reads from into the output payload
+func (fb *FormBuilder) IncludeUnmappedOutput(include bool) *FormBuilder {
+	fb.outputMapping().IncludeUnmapped = include
+	return fb
+}
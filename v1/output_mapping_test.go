@@ -0,0 +1,54 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOutputMapping(t *testing.T) {
+	schema := NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("firstName", FieldTypeText, "First Name").Build()).
+		AddField(NewFieldBuilder("lastName", FieldTypeText, "Last Name").Build()).
+		AddField(NewFieldBuilder("address", FieldTypeObject, "Address").Build()).
+		AddField(NewFieldBuilder("notes", FieldTypeText, "Notes").Build()).
+		MapOutput("customer.first", "firstName").
+		OutputTemplate("customer.fullName", "${firstName} ${lastName}").
+		OutputConstant("source", "web").
+		FlattenOutput("", "address").
+		IncludeUnmappedOutput(true).
+		Build()
+
+	output, err := schema.ApplyOutputMapping(map[string]interface{}{
+		"firstName": "Ada",
+		"lastName":  "Lovelace",
+		"address": map[string]interface{}{
+			"city": "London",
+			"geo":  map[string]interface{}{"lat": 51.5},
+		},
+		"notes": "hello",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"first":    "Ada",
+		"fullName": "Ada Lovelace",
+	}, output["customer"])
+	assert.Equal(t, "web", output["source"])
+	assert.Equal(t, "London", output["city"])
+	assert.Equal(t, 51.5, output["geo_lat"])
+	assert.Equal(t, "hello", output["notes"])
+	assert.Equal(t, "Lovelace", output["lastName"])
+	assert.NotContains(t, output, "firstName")
+	assert.NotContains(t, output, "address")
+}
+
+func TestApplyOutputMappingWithoutMapping(t *testing.T) {
+	schema := NewForm("plain", "Plain").Build()
+	data := map[string]interface{}{"a": 1}
+
+	output, err := schema.ApplyOutputMapping(data)
+	require.NoError(t, err)
+	assert.Equal(t, data, output)
+}
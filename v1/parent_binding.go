@@ -0,0 +1,170 @@
+package smartform
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// Reserved keys used to pass the parent record of a form
+const (
+	ParentTypeQueryParam = "parentType"
+	ParentIDQueryParam   = "parentId"
+	ParentDataKey        = "_parent"
+)
+
+// ParentBinding declares that a form is launched from a parent record (e.g. an account page)
+type ParentBinding struct {
+	Type     string                `json:"type"`               // Type of the parent record, e.g. "account"
+	Required bool                  `json:"required,omitempty"` // Form can only be used with a parent record
+	Fields   []*ParentFieldBinding `json:"fields,omitempty"`
+}
+
+// ParentFieldBinding prefills a form field from a value of the parent record
+type ParentFieldBinding struct {
+	Field  string `json:"field"`            // Form field to prefill
+	Source string `json:"source"`           // Dot path of the value in the parent record
+	Locked bool   `json:"locked,omitempty"` // The user cannot change the prefilled value
+}
+
+// ParentContext identifies the record a form was launched from
+type ParentContext struct {
+	Type   string                 `json:"type"`
+	ID     string                 `json:"id"`
+	Record map[string]interface{} `json:"-"` // Record data, loaded by a ParentRecordResolver
+}
+
+// ParentRecordResolver loads the data of a parent record
+type ParentRecordResolver func(recordType, id string) (map[string]interface{}, error)
+
+// ParentContextFromRequest reads the parent record reference from the parentType and parentId
+// query parameters or from the reserved ParentDataKey entry of the form data, which is
+// removed from the data. It returns nil when no parent is given.
+func ParentContextFromRequest(query url.Values, data map[string]interface{}) (*ParentContext, error) {
+	parent := &ParentContext{
+		Type: query.Get(ParentTypeQueryParam),
+		ID:   query.Get(ParentIDQueryParam),
+	}
+
+	if raw, ok := data[ParentDataKey]; ok {
+		delete(data, ParentDataKey)
+		if err := decodeRaw(raw, parent); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ParentDataKey, err)
+		}
+	}
+
+	if parent.Type == "" && parent.ID == "" {
+		return nil, nil
+	}
+	if parent.Type == "" || parent.ID == "" {
+		return nil, fmt.Errorf("parent type and ID are both required")
+	}
+	return parent, nil
+}
+
+// ResolveParent checks a parent reference against the schema's binding and loads the record
+func (fs *FormSchema) ResolveParent(parent *ParentContext, resolver ParentRecordResolver) error {
+	if fs.Parent == nil {
+		return nil
+	}
+
+	if parent == nil {
+		if fs.Parent.Required {
+			return fmt.Errorf("form %s must be opened from a %s record", fs.ID, fs.Parent.Type)
+		}
+		return nil
+	}
+
+	if parent.Type != fs.Parent.Type {
+		return fmt.Errorf("form %s cannot be opened from a %s record", fs.ID, parent.Type)
+	}
+
+	if parent.Record == nil && resolver != nil {
+		record, err := resolver(parent.Type, parent.ID)
+		if err != nil {
+			return fmt.Errorf("error loading %s %s: %w", parent.Type, parent.ID, err)
+		}
+		parent.Record = record
+	}
+
+	return nil
+}
+
+// ApplyParentContext returns a copy of the schema with bound fields prefilled from the
+// parent record and locked fields disabled
+func (fs *FormSchema) ApplyParentContext(parent *ParentContext) *FormSchema {
+	applied := fs.Clone()
+	if fs.Parent == nil || parent == nil || parent.Record == nil {
+		return applied
+	}
+
+	validator := NewValidator(fs)
+	applied.prefills = make(map[string]string)
+	for _, binding := range fs.Parent.Fields {
+		field := applied.FindFieldByID(binding.Field)
+		if field == nil {
+			continue
+		}
+
+		field.DefaultValue = validator.getValueByPath(parent.Record, binding.Source)
+		field.DefaultWhen = nil
+		applied.prefills[binding.Field] = binding.Source
+
+		if binding.Locked {
+			if field.Properties == nil {
+				field.Properties = make(map[string]interface{})
+			}
+			field.Properties["disabled"] = true
+			field.Properties["locked"] = true
+		}
+	}
+
+	return applied
+}
+
+// EnforceParentBindings overwrites the values of locked fields in submitted data with the
+// values from the parent record and returns the IDs of fields whose values were changed
+func (fs *FormSchema) EnforceParentBindings(data map[string]interface{}, parent *ParentContext) []string {
+	changed := []string{}
+	if fs.Parent == nil || parent == nil || parent.Record == nil {
+		return changed
+	}
+
+	validator := NewValidator(fs)
+	for _, binding := range fs.Parent.Fields {
+		if !binding.Locked {
+			continue
+		}
+
+		expected := validator.getValueByPath(parent.Record, binding.Source)
+		if current, ok := data[binding.Field]; !ok || !reflect.DeepEqual(current, expected) {
+			data[binding.Field] = expected
+			changed = append(changed, binding.Field)
+		}
+	}
+
+	return changed
+}
+
+// ParentRecord declares the type of record the form is launched from
+func (fb *FormBuilder) ParentRecord(recordType string, required bool) *FormBuilder {
+	if fb.schema.Parent == nil {
+		fb.schema.Parent = &ParentBinding{}
+	}
+	fb.schema.Parent.Type = recordType
+	fb.schema.Parent.Required = required
+	return fb
+}
+
+// BindParentField prefills a field from a value of the parent record
+func (fb *FormBuilder) BindParentField(fieldID, source string, locked bool) *FormBuilder {
+	if fb.schema.Parent == nil {
+		fb.schema.Parent = &ParentBinding{}
+	}
+	fb.schema.Parent.Fields = append(fb.schema.Parent.Fields, &ParentFieldBinding{
+		Field:  fieldID,
+		Source: source,
+		Locked: locked,
+	})
+	return fb
+}
@@ -0,0 +1,44 @@
+package smartform
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParentBinding(t *testing.T) {
+	schema := NewForm("contact", "New contact").
+		AddField(NewFieldBuilder("accountName", FieldTypeText, "Account").Build()).
+		AddField(NewFieldBuilder("region", FieldTypeText, "Region").Build()).
+		ParentRecord("account", true).
+		BindParentField("accountName", "name", true).
+		BindParentField("region", "address.region", false).
+		Build()
+
+	resolver := func(recordType, id string) (map[string]interface{}, error) {
+		return map[string]interface{}{
+			"name":    "Acme",
+			"address": map[string]interface{}{"region": "EMEA"},
+		}, nil
+	}
+
+	require.Error(t, schema.ResolveParent(nil, resolver))
+
+	parent, err := ParentContextFromRequest(url.Values{"parentType": {"account"}, "parentId": {"42"}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, schema.ResolveParent(parent, resolver))
+
+	applied := schema.ApplyParentContext(parent)
+	assert.Equal(t, "Acme", applied.FindFieldByID("accountName").DefaultValue)
+	assert.Equal(t, true, applied.FindFieldByID("accountName").Properties["locked"])
+	assert.Equal(t, "EMEA", applied.FindFieldByID("region").DefaultValue)
+	assert.Nil(t, schema.FindFieldByID("accountName").DefaultValue)
+
+	data := map[string]interface{}{"accountName": "Other", "region": "APAC"}
+	changed := schema.EnforceParentBindings(data, parent)
+	assert.Equal(t, []string{"accountName"}, changed)
+	assert.Equal(t, "Acme", data["accountName"])
+	assert.Equal(t, "APAC", data["region"])
+}
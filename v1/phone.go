@@ -0,0 +1,421 @@
+package smartform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// PhoneFormat is how a phone number is written out
+type PhoneFormat string
+
+const (
+	// PhoneFormatE164 writes numbers as "+12015550123", the form they are stored in
+	PhoneFormatE164 PhoneFormat = "e164"
+	// PhoneFormatInternational writes numbers as "+1 201 555 0123"
+	PhoneFormatInternational PhoneFormat = "international"
+	// PhoneFormatNational writes numbers as dialed within their region, e.g. "(201) 555-0123"
+	PhoneFormatNational PhoneFormat = "national"
+)
+
+// maxE164Digits is the most digits of a phone number, calling code included
+const maxE164Digits = 15
+
+// ErrUnknownPhoneRegion is returned for numbers of a region no metadata is registered for
+var ErrUnknownPhoneRegion = errors.New("unknown phone region")
+
+// PhoneRegion is the numbering plan of a region: enough metadata to tell possible numbers from
+// impossible ones, like the possible lengths of libphonenumber
+type PhoneRegion struct {
+	Country     string // ISO 3166-1 alpha-2 code, e.g. "GB"
+	CallingCode string // Country calling code, e.g. "44"
+	TrunkPrefix string // Prefix dialed before national numbers within the region, e.g. "0"
+	Lengths     []int  // Possible lengths of national significant numbers
+	Pattern     string // Regular expression national significant numbers must match, if any
+	Groups      []int  // Digits per group when formatting numbers of the most common length
+	pattern     *regexp.Regexp
+}
+
+// phoneRegions are the numbering plans of the regions phone numbers are parsed for
+var phoneRegions = struct {
+	byCountry     map[string]*PhoneRegion
+	byCallingCode map[string][]*PhoneRegion // The first region of a calling code is its main region
+	lock          sync.RWMutex
+}{
+	byCountry:     make(map[string]*PhoneRegion),
+	byCallingCode: make(map[string][]*PhoneRegion),
+}
+
+func init() {
+	for _, region := range []PhoneRegion{
+		{Country: "US", CallingCode: "1", TrunkPrefix: "1", Lengths: []int{10}, Pattern: `[2-9]\d{2}[2-9]\d{6}`, Groups: []int{3, 3, 4}},
+		{Country: "CA", CallingCode: "1", TrunkPrefix: "1", Lengths: []int{10}, Pattern: `[2-9]\d{2}[2-9]\d{6}`, Groups: []int{3, 3, 4}},
+		{Country: "GB", CallingCode: "44", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d{8,9}`},
+		{Country: "DE", CallingCode: "49", TrunkPrefix: "0", Lengths: []int{6, 7, 8, 9, 10, 11, 12, 13}, Pattern: `[1-9]\d+`},
+		{Country: "FR", CallingCode: "33", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{1, 2, 2, 2, 2}},
+		{Country: "ES", CallingCode: "34", Lengths: []int{9}, Pattern: `[5-9]\d{8}`, Groups: []int{3, 3, 3}},
+		{Country: "IT", CallingCode: "39", Lengths: []int{6, 7, 8, 9, 10, 11}, Pattern: `[03]\d+`},
+		{Country: "NL", CallingCode: "31", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`},
+		{Country: "BE", CallingCode: "32", TrunkPrefix: "0", Lengths: []int{8, 9}, Pattern: `[1-9]\d{7,8}`},
+		{Country: "CH", CallingCode: "41", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{2, 3, 2, 2}},
+		{Country: "AT", CallingCode: "43", TrunkPrefix: "0", Lengths: []int{7, 8, 9, 10, 11, 12, 13}, Pattern: `[1-9]\d+`},
+		{Country: "SE", CallingCode: "46", TrunkPrefix: "0", Lengths: []int{7, 8, 9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "NO", CallingCode: "47", Lengths: []int{8}, Groups: []int{3, 2, 3}},
+		{Country: "DK", CallingCode: "45", Lengths: []int{8}, Groups: []int{2, 2, 2, 2}},
+		{Country: "FI", CallingCode: "358", TrunkPrefix: "0", Lengths: []int{5, 6, 7, 8, 9, 10, 11, 12}, Pattern: `[1-9]\d+`},
+		{Country: "IE", CallingCode: "353", TrunkPrefix: "0", Lengths: []int{7, 8, 9}, Pattern: `[1-9]\d+`},
+		{Country: "PT", CallingCode: "351", Lengths: []int{9}, Pattern: `[2-9]\d{8}`, Groups: []int{3, 3, 3}},
+		{Country: "PL", CallingCode: "48", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{3, 3, 3}},
+		{Country: "RU", CallingCode: "7", TrunkPrefix: "8", Lengths: []int{10}, Pattern: `[3-9]\d{9}`, Groups: []int{3, 3, 2, 2}},
+		{Country: "TR", CallingCode: "90", TrunkPrefix: "0", Lengths: []int{10}, Pattern: `[2-9]\d{9}`, Groups: []int{3, 3, 2, 2}},
+		{Country: "IN", CallingCode: "91", TrunkPrefix: "0", Lengths: []int{10}, Pattern: `[1-9]\d{9}`, Groups: []int{5, 5}},
+		{Country: "PK", CallingCode: "92", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "CN", CallingCode: "86", TrunkPrefix: "0", Lengths: []int{10, 11}, Pattern: `[1-9]\d+`},
+		{Country: "JP", CallingCode: "81", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "KR", CallingCode: "82", TrunkPrefix: "0", Lengths: []int{8, 9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "HK", CallingCode: "852", Lengths: []int{8}, Groups: []int{4, 4}},
+		{Country: "SG", CallingCode: "65", Lengths: []int{8}, Pattern: `[3689]\d{7}`, Groups: []int{4, 4}},
+		{Country: "MY", CallingCode: "60", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "TH", CallingCode: "66", TrunkPrefix: "0", Lengths: []int{8, 9}, Pattern: `[1-9]\d+`},
+		{Country: "VN", CallingCode: "84", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "PH", CallingCode: "63", TrunkPrefix: "0", Lengths: []int{8, 9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "ID", CallingCode: "62", TrunkPrefix: "0", Lengths: []int{9, 10, 11, 12}, Pattern: `[1-9]\d+`},
+		{Country: "AU", CallingCode: "61", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{1, 4, 4}},
+		{Country: "NZ", CallingCode: "64", TrunkPrefix: "0", Lengths: []int{8, 9, 10}, Pattern: `[2-9]\d+`},
+		{Country: "AE", CallingCode: "971", TrunkPrefix: "0", Lengths: []int{8, 9}, Pattern: `[1-9]\d+`},
+		{Country: "SA", CallingCode: "966", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`},
+		{Country: "IL", CallingCode: "972", TrunkPrefix: "0", Lengths: []int{8, 9}, Pattern: `[2-9]\d+`},
+		{Country: "EG", CallingCode: "20", TrunkPrefix: "0", Lengths: []int{9, 10}, Pattern: `[1-9]\d+`},
+		{Country: "ZA", CallingCode: "27", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{2, 3, 4}},
+		{Country: "NG", CallingCode: "234", TrunkPrefix: "0", Lengths: []int{8, 10}, Pattern: `[1-9]\d+`},
+		{Country: "KE", CallingCode: "254", TrunkPrefix: "0", Lengths: []int{9}, Pattern: `[1-9]\d{8}`, Groups: []int{3, 6}},
+		{Country: "BR", CallingCode: "55", TrunkPrefix: "0", Lengths: []int{10, 11}, Pattern: `[1-9]{2}\d{8,9}`},
+		{Country: "MX", CallingCode: "52", Lengths: []int{10}, Pattern: `[1-9]\d{9}`, Groups: []int{2, 4, 4}},
+		{Country: "AR", CallingCode: "54", TrunkPrefix: "0", Lengths: []int{10, 11}, Pattern: `[1-9]\d+`},
+	} {
+		if err := RegisterPhoneRegion(region); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterPhoneRegion adds the numbering plan of a region phone numbers are parsed for, or
+// replaces the one registered for its country. The built-in plans cover common regions.
+func RegisterPhoneRegion(region PhoneRegion) error {
+	region.Country = strings.ToUpper(region.Country)
+	if region.Country == "" || region.CallingCode == "" || len(region.Lengths) == 0 {
+		return errors.New("phone region needs a country, a calling code, and possible lengths")
+	}
+	if region.Pattern != "" {
+		pattern, err := regexp.Compile(`^(?:` + region.Pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("invalid pattern of phone region %s: %w", region.Country, err)
+		}
+		region.pattern = pattern
+	}
+
+	phoneRegions.lock.Lock()
+	defer phoneRegions.lock.Unlock()
+	if previous, ok := phoneRegions.byCountry[region.Country]; ok && previous.CallingCode != region.CallingCode {
+		kept := []*PhoneRegion{}
+		for _, existing := range phoneRegions.byCallingCode[previous.CallingCode] {
+			if existing.Country != region.Country {
+				kept = append(kept, existing)
+			}
+		}
+		phoneRegions.byCallingCode[previous.CallingCode] = kept
+	}
+	regions := phoneRegions.byCallingCode[region.CallingCode]
+	replaced := false
+	for i, existing := range regions {
+		if existing.Country == region.Country {
+			regions[i] = &region
+			replaced = true
+		}
+	}
+	if !replaced {
+		regions = append(regions, &region)
+	}
+	phoneRegions.byCallingCode[region.CallingCode] = regions
+	phoneRegions.byCountry[region.Country] = &region
+	return nil
+}
+
+// phoneRegion returns the numbering plan registered for a country
+func phoneRegion(country string) (*PhoneRegion, bool) {
+	phoneRegions.lock.RLock()
+	defer phoneRegions.lock.RUnlock()
+	region, ok := phoneRegions.byCountry[strings.ToUpper(country)]
+	return region, ok
+}
+
+// callingCodeRegions returns the numbering plans of the calling code a number begins with,
+// and the rest of the number. Calling codes are prefix-free, so at most one matches.
+func callingCodeRegions(digits string) ([]*PhoneRegion, string) {
+	phoneRegions.lock.RLock()
+	defer phoneRegions.lock.RUnlock()
+	for length := 1; length <= 3 && length < len(digits); length++ {
+		if regions, ok := phoneRegions.byCallingCode[digits[:length]]; ok {
+			return regions, digits[length:]
+		}
+	}
+	return nil, ""
+}
+
+// possible reports whether a national significant number is a possible number of the region
+func (pr *PhoneRegion) possible(national string) bool {
+	if len(pr.CallingCode)+len(national) > maxE164Digits {
+		return false
+	}
+	lengthOK := false
+	for _, length := range pr.Lengths {
+		if len(national) == length {
+			lengthOK = true
+		}
+	}
+	return lengthOK && (pr.pattern == nil || pr.pattern.MatchString(national))
+}
+
+// nationalNumber strips the trunk prefix from a number dialed within the region, when the
+// number is only possible without it
+func (pr *PhoneRegion) nationalNumber(digits string) string {
+	if pr.TrunkPrefix != "" && strings.HasPrefix(digits, pr.TrunkPrefix) && !pr.possible(digits) {
+		return strings.TrimPrefix(digits, pr.TrunkPrefix)
+	}
+	return digits
+}
+
+// PhoneNumber is a parsed phone number
+type PhoneNumber struct {
+	Country     string `json:"country"`     // ISO 3166-1 alpha-2 code of the region
+	CallingCode string `json:"callingCode"` // Country calling code, e.g. "44"
+	National    string `json:"national"`    // National significant number, without trunk prefix
+}
+
+// phoneSeparators are the characters people write between the digits of phone numbers
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "", "\u00a0", "")
+
+// ParsePhoneNumber parses a phone number written in international form, with a leading "+"
+// or international prefix, or as dialed within the default country. It fails for numbers
+// that are impossible in their region, such as numbers of the wrong length.
+func ParsePhoneNumber(input, defaultCountry string) (*PhoneNumber, error) {
+	digits := phoneSeparators.Replace(strings.TrimSpace(input))
+	international := strings.HasPrefix(digits, "+")
+	digits = strings.TrimPrefix(digits, "+")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil, fmt.Errorf("%q is not a phone number", input)
+	}
+
+	defaultRegion, hasDefault := phoneRegion(defaultCountry)
+	if !international {
+		// International prefixes, "011" from North America and "00" from most other regions
+		if hasDefault && defaultRegion.CallingCode == "1" && strings.HasPrefix(digits, "011") {
+			digits, international = digits[3:], true
+		} else if strings.HasPrefix(digits, "00") {
+			digits, international = digits[2:], true
+		}
+	}
+
+	if !international {
+		if !hasDefault {
+			if defaultCountry == "" {
+				return nil, fmt.Errorf("%q needs a country code", input)
+			}
+			return nil, fmt.Errorf("%s: %w", defaultCountry, ErrUnknownPhoneRegion)
+		}
+		national := defaultRegion.nationalNumber(digits)
+		if !defaultRegion.possible(national) {
+			return nil, fmt.Errorf("%q is not a possible phone number in %s", input, defaultRegion.Country)
+		}
+		return &PhoneNumber{Country: defaultRegion.Country, CallingCode: defaultRegion.CallingCode, National: national}, nil
+	}
+
+	regions, rest := callingCodeRegions(digits)
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("%q has an unknown country code", input)
+	}
+	// Regions sharing a calling code are told apart by the default country only
+	region := regions[0]
+	for _, candidate := range regions {
+		if hasDefault && candidate.Country == defaultRegion.Country {
+			region = candidate
+		}
+	}
+	// People often keep the trunk prefix after the country code, as in "+44 (0)20 ..."
+	national := region.nationalNumber(rest)
+	if !region.possible(national) {
+		return nil, fmt.Errorf("%q is not a possible phone number in %s", input, region.Country)
+	}
+	return &PhoneNumber{Country: region.Country, CallingCode: region.CallingCode, National: national}, nil
+}
+
+// E164 returns the number in E.164 form, e.g. "+442079460958"
+func (pn *PhoneNumber) E164() string {
+	return "+" + pn.CallingCode + pn.National
+}
+
+// String returns the number in E.164 form
+func (pn *PhoneNumber) String() string {
+	return pn.E164()
+}
+
+// Format writes the number out in a format
+func (pn *PhoneNumber) Format(format PhoneFormat) string {
+	var groups []int
+	trunkPrefix := ""
+	if region, ok := phoneRegion(pn.Country); ok {
+		groups = region.Groups
+		trunkPrefix = region.TrunkPrefix
+	}
+	parts := groupDigits(pn.National, groups)
+
+	switch format {
+	case PhoneFormatInternational:
+		return "+" + pn.CallingCode + " " + strings.Join(parts, " ")
+	case PhoneFormatNational:
+		if pn.CallingCode == "1" && len(parts) == 3 {
+			return fmt.Sprintf("(%s) %s-%s", parts[0], parts[1], parts[2])
+		}
+		// The trunk prefix of North America is not dialed within the region
+		if trunkPrefix != "" && pn.CallingCode != "1" {
+			parts[0] = trunkPrefix + parts[0]
+		}
+		return strings.Join(parts, " ")
+	default:
+		return pn.E164()
+	}
+}
+
+// groupDigits splits a national number into groups for formatting: the groups of its region
+// when they fit, otherwise groups of three with up to four digits last
+func groupDigits(digits string, groups []int) []string {
+	total := 0
+	for _, size := range groups {
+		total += size
+	}
+	if total != len(digits) {
+		groups = nil
+		for remaining := len(digits); remaining > 0; {
+			size := min(remaining, 3)
+			if remaining <= 4 {
+				size = remaining
+			}
+			groups = append(groups, size)
+			remaining -= size
+		}
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, size := range groups {
+		parts = append(parts, digits[:size])
+		digits = digits[size:]
+	}
+	return parts
+}
+
+// PhoneConfig configures how a phone field parses numbers
+type PhoneConfig struct {
+	DefaultCountry string      `json:"defaultCountry,omitempty"` // Region of numbers entered without a country code
+	CountryField   string      `json:"countryField,omitempty"`   // Path of a field holding the region, taking precedence over the default
+	Countries      []string    `json:"countries,omitempty"`      // Regions accepted, all when empty
+	Format         PhoneFormat `json:"format,omitempty"`         // How clients display numbers (default international)
+}
+
+// Clone returns a deep copy of the phone configuration
+func (pc *PhoneConfig) Clone() *PhoneConfig {
+	if pc == nil {
+		return nil
+	}
+
+	clone := *pc
+	if pc.Countries != nil {
+		clone.Countries = append([]string(nil), pc.Countries...)
+	}
+	return &clone
+}
+
+// parse parses the value of a phone field in the scope of its form data, checking that the
+// region of the number is accepted
+func (pc *PhoneConfig) parse(v *Validator, value interface{}, scope map[string]interface{}) (*PhoneNumber, error) {
+	input, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a phone number", value)
+	}
+	if pc == nil {
+		return ParsePhoneNumber(input, "")
+	}
+
+	country := pc.DefaultCountry
+	if pc.CountryField != "" {
+		if fieldCountry, ok := v.getValueByPath(scope, pc.CountryField).(string); ok && fieldCountry != "" {
+			country = fieldCountry
+		}
+	}
+	number, err := ParsePhoneNumber(input, country)
+	if err != nil {
+		return nil, err
+	}
+	if len(pc.Countries) > 0 {
+		for _, accepted := range pc.Countries {
+			if strings.EqualFold(accepted, number.Country) {
+				return number, nil
+			}
+		}
+		return nil, fmt.Errorf("numbers in %s are not accepted", number.Country)
+	}
+	return number, nil
+}
+
+// validatePhoneNumber checks that the value of a phone field is a possible number of an
+// accepted region
+func (v *Validator) validatePhoneNumber(field *Field, fieldPath string, value interface{}, scope map[string]interface{}, result *ValidationResult) {
+	if field.Type != FieldTypePhone {
+		return
+	}
+	if _, err := field.Phone.parse(v, value, scope); err != nil {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s is not a valid phone number: %v", field.Label, err),
+			RuleType: string(ValidationTypePhone),
+		})
+	}
+}
+
+// phoneRuleCountry returns the default country of a phone validation rule
+func phoneRuleCountry(rule *ValidationRule) string {
+	country, _ := rule.Parameters.(string)
+	return country
+}
+
+// NormalizePhoneNumbers replaces the values of phone fields in validated data with their E.164
+// form, e.g. "(201) 555-0123" with "+12015550123". Values that cannot be parsed are left as
+// they are.
+func (fs *FormSchema) NormalizePhoneNumbers(data map[string]interface{}) {
+	validator := NewValidator(fs)
+
+	var normalize func(fields []*Field, values, scope map[string]interface{})
+	normalize = func(fields []*Field, values, scope map[string]interface{}) {
+		for _, field := range fields {
+			switch field.Type {
+			case FieldTypePhone:
+				if number, err := field.Phone.parse(validator, values[field.ID], scope); err == nil {
+					values[field.ID] = number.E164()
+				}
+			case FieldTypeGroup, FieldTypeObject:
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					normalize(field.Nested, nested, overlayVariables(scope, nested))
+				}
+			case FieldTypeArray:
+				items, _ := values[field.ID].([]interface{})
+				for i, item := range items {
+					if itemMap, ok := item.(map[string]interface{}); ok {
+						normalize(field.Nested, itemMap, itemScope(scope, itemMap, values, i))
+					}
+				}
+			}
+		}
+	}
+	normalize(fs.Fields, data, data)
+}
@@ -0,0 +1,121 @@
+package smartform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		defaultCountry string
+		want           string
+		country        string
+	}{
+		{"national US", "(201) 555-0123", "US", "+12015550123", "US"},
+		{"US with trunk prefix", "1-201-555-0123", "US", "+12015550123", "US"},
+		{"national GB", "020 7946 0958", "GB", "+442079460958", "GB"},
+		{"international", "+44 20 7946 0958", "US", "+442079460958", "GB"},
+		{"trunk prefix after country code", "+44 (0)20 7946 0958", "", "+442079460958", "GB"},
+		{"international prefix", "0049 30 1234567", "FR", "+49301234567", "DE"},
+		{"North American international prefix", "011 33 1 23 45 67 89", "US", "+33123456789", "FR"},
+		{"shared calling code", "+1 416 555 0199", "CA", "+14165550199", "CA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := ParsePhoneNumber(tt.input, tt.defaultCountry)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, number.E164())
+			assert.Equal(t, tt.country, number.Country)
+		})
+	}
+
+	invalid := []struct {
+		name           string
+		input          string
+		defaultCountry string
+	}{
+		{"too short", "555-0123", "US"},
+		{"too long", "+1 201 555 01234", ""},
+		{"area code starting with 1", "(101) 555-0123", "US"},
+		{"no country code", "020 7946 0958", ""},
+		{"letters", "call me", "US"},
+		{"unknown country code", "+999 123456789", ""},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePhoneNumber(tt.input, tt.defaultCountry)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := ParsePhoneNumber("020 7946 0958", "XX")
+	assert.True(t, errors.Is(err, ErrUnknownPhoneRegion))
+}
+
+func TestPhoneNumberFormat(t *testing.T) {
+	us, err := ParsePhoneNumber("+12015550123", "")
+	require.NoError(t, err)
+	assert.Equal(t, "(201) 555-0123", us.Format(PhoneFormatNational))
+	assert.Equal(t, "+1 201 555 0123", us.Format(PhoneFormatInternational))
+
+	fr, err := ParsePhoneNumber("01 23 45 67 89", "FR")
+	require.NoError(t, err)
+	assert.Equal(t, "01 23 45 67 89", fr.Format(PhoneFormatNational))
+	assert.Equal(t, "+33 1 23 45 67 89", fr.Format(PhoneFormatInternational))
+	assert.Equal(t, "+33123456789", fr.Format(PhoneFormatE164))
+}
+
+func TestPhoneFieldValidation(t *testing.T) {
+	builder := NewForm("contact", "Contact")
+	builder.SelectField("country", "Country")
+	builder.PhoneField("phone", "Phone").
+		DefaultCountry("US").
+		CountryFrom("country").
+		Countries("US", "GB")
+	schema := builder.Build()
+
+	result := schema.Validate(map[string]interface{}{"phone": "(201) 555-0123"})
+	assert.True(t, result.Valid, result.Errors)
+
+	result = schema.Validate(map[string]interface{}{"country": "GB", "phone": "020 7946 0958"})
+	assert.True(t, result.Valid, result.Errors)
+
+	result = schema.Validate(map[string]interface{}{"phone": "555-0123"})
+	require.False(t, result.Valid)
+	assert.Equal(t, string(ValidationTypePhone), result.Errors[0].RuleType)
+
+	result = schema.Validate(map[string]interface{}{"phone": "+33 1 23 45 67 89"})
+	require.False(t, result.Valid)
+	assert.Contains(t, result.Errors[0].Message, "numbers in FR are not accepted")
+
+	rule := NewValidationBuilder().Phone("GB", "Enter a valid phone number")
+	text := NewForm("text", "Text")
+	text.TextField("mobile", "Mobile").AddValidation(rule)
+	assert.True(t, text.Build().Validate(map[string]interface{}{"mobile": "07700 900123"}).Valid)
+	assert.False(t, text.Build().Validate(map[string]interface{}{"mobile": "07700 9001"}).Valid)
+}
+
+func TestNormalizePhoneNumbers(t *testing.T) {
+	builder := NewForm("contacts", "Contacts")
+	builder.ArrayField("contacts", "Contacts").ItemTemplate(NewPhoneFieldBuilder("phone", "Phone").DefaultCountry("GB").Build())
+	schema := builder.Build()
+
+	data := map[string]interface{}{
+		"contacts": []interface{}{
+			map[string]interface{}{"phone": "020 7946 0958"},
+			map[string]interface{}{"phone": "+1 (201) 555-0123"},
+			map[string]interface{}{"phone": "not a number"},
+		},
+	}
+	schema.NormalizePhoneNumbers(data)
+
+	items := data["contacts"].([]interface{})
+	assert.Equal(t, "+442079460958", items[0].(map[string]interface{})["phone"])
+	assert.Equal(t, "+12015550123", items[1].(map[string]interface{})["phone"])
+	assert.Equal(t, "not a number", items[2].(map[string]interface{})["phone"])
+}
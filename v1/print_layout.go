@@ -0,0 +1,471 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Paper sizes of printed forms
+const (
+	PaperSizeA4     = "A4"
+	PaperSizeLetter = "letter"
+	PaperSizeLegal  = "legal"
+)
+
+// Orientations of printed pages
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+)
+
+// PrintFormatQueryParam asks the print endpoint for the print document as JSON instead of HTML
+const PrintFormatQueryParam = "format"
+
+// PrintLayout describes how a form is laid out on paper, for government-style forms that are
+// printed or rendered to PDF from the same schema that drives the interactive form
+type PrintLayout struct {
+	PaperSize   string `json:"paperSize,omitempty"`   // A4 (default), letter, or legal
+	Orientation string `json:"orientation,omitempty"` // portrait (default) or landscape
+	Columns     int    `json:"columns,omitempty"`     // Columns fields flow into, 1 (default) or 2
+	Header      string `json:"header,omitempty"`      // Template printed at the top of every page
+	Footer      string `json:"footer,omitempty"`      // Template printed at the bottom of every page
+}
+
+// PrintHints describe how a field is laid out on paper
+type PrintHints struct {
+	PageBreakBefore bool `json:"pageBreakBefore,omitempty"` // The field starts a new page
+	PageBreakAfter  bool `json:"pageBreakAfter,omitempty"`  // The fields after it start a new page
+	FullWidth       bool `json:"fullWidth,omitempty"`       // The field spans every column
+	KeepTogether    bool `json:"keepTogether,omitempty"`    // A group or array is not split across pages
+	Hidden          bool `json:"hidden,omitempty"`          // The field is left out of printed copies
+}
+
+// PrintDocument is a form filled in with values and split into pages, which PDF and HTML
+// renderers lay out on paper
+type PrintDocument struct {
+	FormID      string       `json:"formId"`
+	Title       string       `json:"title"`
+	PaperSize   string       `json:"paperSize"`
+	Orientation string       `json:"orientation"`
+	Columns     int          `json:"columns"`
+	Pages       []*PrintPage `json:"pages"`
+}
+
+// PrintPage is one page of a print document
+type PrintPage struct {
+	Number int           `json:"number"`
+	Header string        `json:"header,omitempty"`
+	Footer string        `json:"footer,omitempty"`
+	Blocks []*PrintBlock `json:"blocks"`
+}
+
+// PrintBlock is a field of a print document with its value. Groups hold a block for each of
+// their fields, and arrays a block for each item.
+type PrintBlock struct {
+	FieldID      string        `json:"fieldId"` // Full path of the field, e.g. "items[0].name"
+	Type         FieldType     `json:"type"`
+	Label        string        `json:"label"`
+	Value        interface{}   `json:"value,omitempty"`
+	FullWidth    bool          `json:"fullWidth,omitempty"`
+	KeepTogether bool          `json:"keepTogether,omitempty"`
+	Blocks       []*PrintBlock `json:"blocks,omitempty"`
+}
+
+// printLayoutOrDefault returns the print layout of the schema with its defaults filled in
+func (fs *FormSchema) printLayoutOrDefault() PrintLayout {
+	layout := PrintLayout{}
+	if fs.Print != nil {
+		layout = *fs.Print
+	}
+	if layout.PaperSize == "" {
+		layout.PaperSize = PaperSizeA4
+	}
+	if layout.Orientation == "" {
+		layout.Orientation = OrientationPortrait
+	}
+	if layout.Columns < 1 {
+		layout.Columns = 1
+	}
+	return layout
+}
+
+// CheckPrintLayout checks that the print layout of the schema uses known paper sizes and
+// orientations and at most two columns
+func (fs *FormSchema) CheckPrintLayout() error {
+	if fs.Print == nil {
+		return nil
+	}
+	switch fs.Print.PaperSize {
+	case "", PaperSizeA4, PaperSizeLetter, PaperSizeLegal:
+	default:
+		return fmt.Errorf("print layout of form %s has an unknown paper size %q", fs.ID, fs.Print.PaperSize)
+	}
+	switch fs.Print.Orientation {
+	case "", OrientationPortrait, OrientationLandscape:
+	default:
+		return fmt.Errorf("print layout of form %s has an unknown orientation %q", fs.ID, fs.Print.Orientation)
+	}
+	if fs.Print.Columns < 0 || fs.Print.Columns > 2 {
+		return fmt.Errorf("print layout of form %s must have one or two columns, not %d", fs.ID, fs.Print.Columns)
+	}
+	return nil
+}
+
+// PrintDocument fills the form in with data and its computed values, and splits it into pages
+// at the page breaks of its top-level fields. Fields that are hidden from print, not visible
+// for the data, or hold secrets are left out, and values of fields with static options are
+// printed as their labels. Headers and footers are evaluated for every page with the data and
+// the variables "page" and "pages".
+func (fs *FormSchema) PrintDocument(data map[string]interface{}) (*PrintDocument, error) {
+	data = cloneMap(data)
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if _, err := fs.computeValuesInto(data); err != nil {
+		return nil, err
+	}
+	layout := fs.printLayoutOrDefault()
+	document := &PrintDocument{
+		FormID:      fs.ID,
+		Title:       fs.Title,
+		PaperSize:   layout.PaperSize,
+		Orientation: layout.Orientation,
+		Columns:     layout.Columns,
+		Pages:       []*PrintPage{},
+	}
+
+	validator := NewValidator(fs)
+	page := &PrintPage{Blocks: []*PrintBlock{}}
+	breakPage := func() {
+		if len(page.Blocks) > 0 {
+			document.Pages = append(document.Pages, page)
+			page = &PrintPage{Blocks: []*PrintBlock{}}
+		}
+	}
+	for _, field := range fs.Fields {
+		if !printable(field) || (field.Visible != nil && !validator.evaluateCondition(field.Visible, data)) {
+			continue
+		}
+		if field.Print != nil && field.Print.PageBreakBefore {
+			breakPage()
+		}
+		page.Blocks = append(page.Blocks, fs.printBlock(validator, field, field.ID, data[field.ID]))
+		if field.Print != nil && field.Print.PageBreakAfter {
+			breakPage()
+		}
+	}
+	if len(page.Blocks) > 0 || len(document.Pages) == 0 {
+		document.Pages = append(document.Pages, page)
+	}
+
+	evaluator := fs.TemplateEvaluator()
+	for i, page := range document.Pages {
+		page.Number = i + 1
+		context := cloneMap(data)
+		context["page"] = page.Number
+		context["pages"] = len(document.Pages)
+		var err error
+		if page.Header, err = evaluatePrintTemplate(evaluator, layout.Header, context); err != nil {
+			return nil, fmt.Errorf("error evaluating print header: %w", err)
+		}
+		if page.Footer, err = evaluatePrintTemplate(evaluator, layout.Footer, context); err != nil {
+			return nil, fmt.Errorf("error evaluating print footer: %w", err)
+		}
+	}
+	return document, nil
+}
+
+// printBlock creates the block of a field holding value, with blocks for the fields of groups
+// and the items of arrays
+func (fs *FormSchema) printBlock(validator *Validator, field *Field, path string, value interface{}) *PrintBlock {
+	block := &PrintBlock{FieldID: path, Type: field.Type, Label: field.Label}
+	if field.Print != nil {
+		block.FullWidth = field.Print.FullWidth
+		block.KeepTogether = field.Print.KeepTogether
+	}
+
+	switch field.Type {
+	case FieldTypeGroup, FieldTypeObject:
+		nested, _ := value.(map[string]interface{})
+		block.Blocks = fs.printBlocks(validator, field.Nested, path, nested)
+	case FieldTypeArray:
+		items, _ := value.([]interface{})
+		for i, item := range items {
+			itemMap, _ := item.(map[string]interface{})
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			block.Blocks = append(block.Blocks, &PrintBlock{
+				FieldID: itemPath,
+				Type:    FieldTypeGroup,
+				Label:   fmt.Sprintf("%s %d", field.Label, i+1),
+				Blocks:  fs.printBlocks(validator, field.Nested, itemPath, itemMap),
+			})
+		}
+	case FieldTypeSection:
+	default:
+		block.Value = displayValue(field, value, "")
+	}
+	return block
+}
+
+// displayValue returns the value of a field as people read it: option labels instead of option
+// values, amounts and times formatted, and signatures summarized. Amounts of currency fields
+// without a locale are formatted in the given one.
+func displayValue(field *Field, value interface{}, locale string) interface{} {
+	switch field.Type {
+	case FieldTypeSignature:
+		return signatureSummary(value)
+	case FieldTypeCurrency:
+		return currencySummary(field, value, locale)
+	case FieldTypeTime, FieldTypeDateTime, FieldTypeDateRange:
+		return dateTimeSummary(field, value)
+	default:
+		return optionLabels(field, value)
+	}
+}
+
+// optionLabels replaces the values of a field with static options by the labels of the
+// options
+func optionLabels(field *Field, value interface{}) interface{} {
+	if field.Options == nil || len(field.Options.Static) == 0 {
+		return value
+	}
+	label := func(value interface{}) interface{} {
+		for _, option := range field.Options.Static {
+			if fmt.Sprint(option.Value) == fmt.Sprint(value) {
+				return option.Label
+			}
+		}
+		return value
+	}
+	if values, ok := value.([]interface{}); ok {
+		labels := make([]interface{}, len(values))
+		for i, item := range values {
+			labels[i] = label(item)
+		}
+		return labels
+	}
+	return label(value)
+}
+
+// printBlocks creates the blocks of the printable fields of a group or array item
+func (fs *FormSchema) printBlocks(validator *Validator, fields []*Field, path string, data map[string]interface{}) []*PrintBlock {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	blocks := []*PrintBlock{}
+	for _, field := range fields {
+		if !printable(field) || (field.Visible != nil && !validator.evaluateCondition(field.Visible, data)) {
+			continue
+		}
+		blocks = append(blocks, fs.printBlock(validator, field, joinPath(path, field.ID), data[field.ID]))
+	}
+	return blocks
+}
+
+// printable reports whether a field appears on printed copies
+func printable(field *Field) bool {
+	if field.Print != nil && field.Print.Hidden {
+		return false
+	}
+	switch field.Type {
+	case FieldTypeHidden, FieldTypePassword, FieldTypeAuth, FieldTypeAPI:
+		return false
+	}
+	return true
+}
+
+// evaluatePrintTemplate evaluates a header or footer template
+func evaluatePrintTemplate(evaluator TemplateEvaluator, source string, context map[string]interface{}) (string, error) {
+	if source == "" || !evaluator.IsTemplate(source) {
+		return source, nil
+	}
+	value, err := evaluator.Evaluate(source, context)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(value), nil
+}
+
+// printPageSizes are the CSS page sizes of the paper sizes
+var printPageSizes = map[string]string{
+	PaperSizeA4:     "A4",
+	PaperSizeLetter: "letter",
+	PaperSizeLegal:  "legal",
+}
+
+// printHTML renders print documents as HTML pages that browsers and HTML-to-PDF converters
+// lay out on paper
+var printHTML = template.Must(template.New("print").Funcs(template.FuncMap{
+	"pageSize": func(document *PrintDocument) string {
+		return printPageSizes[document.PaperSize] + " " + document.Orientation
+	},
+	"printValue": printValue,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+@page { size: {{pageSize .}}; margin: 15mm; }
+body { font-family: sans-serif; font-size: 10pt; margin: 0; }
+.page { break-after: page; display: flex; flex-direction: column; min-height: 100%; }
+.page:last-child { break-after: auto; }
+.page-header, .page-footer { font-size: 8pt; color: #555; }
+.page-footer { margin-top: auto; }
+.fields { display: grid; grid-template-columns: repeat({{.Columns}}, 1fr); gap: 4mm 8mm; }
+.field { break-inside: avoid; }
+.field.full-width, .group { grid-column: 1 / -1; }
+.group.keep-together { break-inside: avoid; }
+.label { font-weight: bold; }
+.value { border-bottom: 1px solid #999; min-height: 1.4em; }
+</style>
+</head>
+<body>
+{{- range .Pages}}
+<section class="page" data-page="{{.Number}}">
+{{- if .Header}}
+<header class="page-header">{{.Header}}</header>
+{{- end}}
+<div class="fields">
+{{- range .Blocks}}{{template "block" .}}{{end}}
+</div>
+{{- if .Footer}}
+<footer class="page-footer">{{.Footer}}</footer>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+{{define "block"}}
+{{- if .Blocks}}
+<fieldset class="group{{if .KeepTogether}} keep-together{{end}}" data-field="{{.FieldID}}">
+<legend class="label">{{.Label}}</legend>
+<div class="fields">
+{{- range .Blocks}}{{template "block" .}}{{end}}
+</div>
+</fieldset>
+{{- else if eq (print .Type) "section"}}
+<h2 class="group" data-field="{{.FieldID}}">{{.Label}}</h2>
+{{- else}}
+<div class="field{{if .FullWidth}} full-width{{end}}" data-field="{{.FieldID}}">
+<div class="label">{{.Label}}</div>
+<div class="value">{{printValue .Value}}</div>
+</div>
+{{- end}}
+{{- end}}
+`))
+
+// printValue formats a value for print
+func printValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = printValue(item)
+		}
+		return strings.Join(values, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteHTML writes the document as an HTML page with print styles: a page size, page breaks,
+// and fields flowing into the columns of the layout
+func (pd *PrintDocument) WriteHTML(w io.Writer) error {
+	return printHTML.Execute(w, pd)
+}
+
+// handlePrint handles requests to fill a form in with values for print. The print document is
+// rendered as HTML, or sent as JSON with ?format=json for PDF renderers.
+func (ah *APIHandler) handlePrint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	formID := getPathParam(r.URL.Path, "/api/print/")
+	if formID == "" {
+		writeError(w, badRequest("Form ID is required"))
+		return
+	}
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	var data map[string]interface{}
+	if err := decodeRequest(r.Body, &data); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	document, err := schema.PrintDocument(ah.withRenderContext(r, data))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get(PrintFormatQueryParam) == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(document)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = document.WriteHTML(w)
+}
+
+// PrintLayout sets how the form is laid out on paper
+func (fb *FormBuilder) PrintLayout(layout *PrintLayout) *FormBuilder {
+	fb.schema.Print = layout
+	return fb
+}
+
+// printHints returns the print hints of the field, creating them if needed
+func (fb *FieldBuilder) printHints() *PrintHints {
+	if fb.field.Print == nil {
+		fb.field.Print = &PrintHints{}
+	}
+	return fb.field.Print
+}
+
+// PageBreakBefore starts a new printed page with the field
+func (fb *FieldBuilder) PageBreakBefore() *FieldBuilder {
+	fb.printHints().PageBreakBefore = true
+	return fb
+}
+
+// PageBreakAfter starts a new printed page after the field
+func (fb *FieldBuilder) PageBreakAfter() *FieldBuilder {
+	fb.printHints().PageBreakAfter = true
+	return fb
+}
+
+// PrintFullWidth makes the field span every column of a multi-column print layout
+func (fb *FieldBuilder) PrintFullWidth() *FieldBuilder {
+	fb.printHints().FullWidth = true
+	return fb
+}
+
+// KeepTogetherInPrint keeps the fields of a group or array on one printed page when possible
+func (fb *FieldBuilder) KeepTogetherInPrint() *FieldBuilder {
+	fb.printHints().KeepTogether = true
+	return fb
+}
+
+// HideInPrint leaves the field out of printed copies
+func (fb *FieldBuilder) HideInPrint() *FieldBuilder {
+	fb.printHints().Hidden = true
+	return fb
+}
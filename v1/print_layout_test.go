@@ -0,0 +1,105 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPermitForm() *FormBuilder {
+	form := NewForm("permit", "Building Permit").PrintLayout(&PrintLayout{
+		PaperSize: PaperSizeLetter,
+		Columns:   2,
+		Header:    "Permit for ${applicant}",
+		Footer:    "Page ${page} of ${pages}",
+	})
+	form.TextField("applicant", "Applicant")
+	form.SelectField("kind", "Kind").AddOption("res", "Residential").AddOption("com", "Commercial")
+	form.TextareaField("notes", "Notes").PrintFullWidth()
+	form.TextField("internalRef", "Internal reference").HideInPrint()
+	form.CheckboxField("demolition", "Demolition")
+	form.TextField("demolitionPlan", "Demolition plan").VisibleWhenEquals("demolition", true)
+	rooms := form.ArrayField("rooms", "Room")
+	rooms.PageBreakBefore().KeepTogetherInPrint()
+	rooms.TextField("name", "Name")
+	rooms.NumberField("area", "Area")
+	form.TextField("signature", "Signature").PageBreakBefore()
+	return form
+}
+
+func TestPrintDocument(t *testing.T) {
+	document, err := newPermitForm().Build().PrintDocument(map[string]interface{}{
+		"applicant":   "Ada",
+		"kind":        "com",
+		"internalRef": "X-1",
+		"demolition":  false,
+		"rooms": []interface{}{
+			map[string]interface{}{"name": "Kitchen", "area": 12},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, PaperSizeLetter, document.PaperSize)
+	assert.Equal(t, OrientationPortrait, document.Orientation)
+	assert.Equal(t, 2, document.Columns)
+	require.Len(t, document.Pages, 3)
+
+	first := document.Pages[0]
+	assert.Equal(t, "Permit for Ada", first.Header)
+	assert.Equal(t, "Page 1 of 3", first.Footer)
+	ids := []string{}
+	for _, block := range first.Blocks {
+		ids = append(ids, block.FieldID)
+	}
+	assert.Equal(t, []string{"applicant", "kind", "notes", "demolition"}, ids)
+	assert.Equal(t, "Commercial", first.Blocks[1].Value)
+	assert.True(t, first.Blocks[2].FullWidth)
+
+	rooms := document.Pages[1].Blocks[0]
+	assert.True(t, rooms.KeepTogether)
+	require.Len(t, rooms.Blocks, 1)
+	assert.Equal(t, "rooms[0]", rooms.Blocks[0].FieldID)
+	assert.Equal(t, "rooms[0].area", rooms.Blocks[0].Blocks[1].FieldID)
+	assert.Equal(t, 12, rooms.Blocks[0].Blocks[1].Value)
+
+	assert.Equal(t, "signature", document.Pages[2].Blocks[0].FieldID)
+	assert.Equal(t, "Page 3 of 3", document.Pages[2].Footer)
+
+	var html strings.Builder
+	require.NoError(t, document.WriteHTML(&html))
+	assert.Contains(t, html.String(), "size: letter portrait")
+	assert.Contains(t, html.String(), "grid-template-columns: repeat(2, 1fr)")
+	assert.Equal(t, 3, strings.Count(html.String(), `<section class="page"`))
+	assert.NotContains(t, html.String(), "X-1")
+}
+
+func TestPrintEndpoint(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(newPermitForm().Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/print/permit", strings.NewReader(`{"applicant": "<Ada>"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "&lt;Ada&gt;")
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/print/permit?format=json", strings.NewReader(`{"applicant": "Ada"}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var document PrintDocument
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &document))
+	assert.Equal(t, "permit", document.FormID)
+	assert.Equal(t, "Permit for Ada", document.Pages[0].Header)
+
+	// Layouts the renderers cannot honor are rejected at registration
+	invalid := NewForm("wide", "Wide").PrintLayout(&PrintLayout{Columns: 3})
+	invalid.TextField("name", "Name")
+	assert.Error(t, handler.RegisterSchema(invalid.Build()))
+}
@@ -0,0 +1,79 @@
+package smartform
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit caps how many requests one client may send per period
+type RateLimit struct {
+	Requests int           // Requests a client may send per period (0 means unlimited)
+	Per      time.Duration // Length of the period (default 1s)
+}
+
+// rateLimiter counts the requests of each client in fixed windows
+type rateLimiter struct {
+	limit   RateLimit
+	lock    sync.Mutex
+	windows map[string]*rateWindow
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// rateWindow counts the requests of one client within a period
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// newRateLimiter creates a limiter of requests
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Per <= 0 {
+		limit.Per = time.Second
+	}
+	return &rateLimiter{
+		limit:   limit,
+		windows: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// allow reports whether a client may send another request, or else how long it must wait
+func (pl *rateLimiter) allow(client string) (time.Duration, bool) {
+	if pl.limit.Requests <= 0 {
+		return 0, true
+	}
+
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	now := pl.now()
+	if now.Sub(pl.pruned) >= pl.limit.Per {
+		for key, window := range pl.windows {
+			if now.Sub(window.start) >= pl.limit.Per {
+				delete(pl.windows, key)
+			}
+		}
+		pl.pruned = now
+	}
+
+	window, ok := pl.windows[client]
+	if !ok || now.Sub(window.start) >= pl.limit.Per {
+		window = &rateWindow{start: now}
+		pl.windows[client] = window
+	}
+	if window.count >= pl.limit.Requests {
+		return window.start.Add(pl.limit.Per).Sub(now), false
+	}
+	window.count++
+	return 0, true
+}
+
+// writeRateLimited writes a 429 response telling the client how many seconds to wait
+func writeRateLimited(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	writeError(w, NewAPIError(http.StatusTooManyRequests, ErrorCodeRateLimited, message))
+}
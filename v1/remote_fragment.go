@@ -0,0 +1,475 @@
+package smartform
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers used to transport fragment signatures
+const (
+	FragmentKeyIDHeader     = "X-Smartform-Key-Id"
+	FragmentSignatureHeader = "X-Smartform-Signature"
+)
+
+// DefaultMaxFragmentSize is the size of the largest fragment document a loader reads by default
+const DefaultMaxFragmentSize = 1 << 20
+
+// RemoteFragmentRef references a fragment of fields published by another service
+type RemoteFragmentRef struct {
+	URL       string `json:"url"`
+	KeyID     string `json:"keyId,omitempty"`     // Trusted key expected to have signed the fragment
+	Integrity string `json:"integrity,omitempty"` // Optional pinned content hash ("sha256-<base64>")
+	Prefix    string `json:"prefix,omitempty"`    // Prefix applied to the fragment's field IDs
+	Order     int    `json:"order,omitempty"`     // Order of the first merged field; later fields follow it
+}
+
+// RemoteFragment is the document served by a fragment publisher
+type RemoteFragment struct {
+	ID      string   `json:"id"`
+	Version string   `json:"version,omitempty"`
+	Fields  []*Field `json:"fields"`
+}
+
+// cachedFragment is a fetched and verified fragment
+type cachedFragment struct {
+	fragment  *RemoteFragment
+	fetchedAt time.Time
+}
+
+// RemoteFragmentLoader fetches, verifies, caches, and merges remote fragments
+type RemoteFragmentLoader struct {
+	client        *http.Client
+	trustedKeys   map[string]ed25519.PublicKey
+	cache         map[string]*cachedFragment
+	cacheTTL      time.Duration
+	maxSize       int64
+	allowUnsigned bool
+	mutex         sync.RWMutex
+}
+
+// NewRemoteFragmentLoader creates a loader that caches fragments for cacheTTL
+func NewRemoteFragmentLoader(cacheTTL time.Duration) *RemoteFragmentLoader {
+	return &RemoteFragmentLoader{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		trustedKeys: make(map[string]ed25519.PublicKey),
+		cache:       make(map[string]*cachedFragment),
+		cacheTTL:    cacheTTL,
+		maxSize:     DefaultMaxFragmentSize,
+	}
+}
+
+// TrustKey registers a public key allowed to sign fragments
+func (l *RemoteFragmentLoader) TrustKey(keyID string, key ed25519.PublicKey) *RemoteFragmentLoader {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.trustedKeys[keyID] = key
+	return l
+}
+
+// AllowUnsigned permits fragments without a signature (intended for local development)
+func (l *RemoteFragmentLoader) AllowUnsigned(allow bool) *RemoteFragmentLoader {
+	l.allowUnsigned = allow
+	return l
+}
+
+// SetHTTPClient replaces the client used to fetch fragments
+func (l *RemoteFragmentLoader) SetHTTPClient(client *http.Client) *RemoteFragmentLoader {
+	l.client = client
+	return l
+}
+
+// SetMaxSize sets the size of the largest fragment document read, in bytes
+func (l *RemoteFragmentLoader) SetMaxSize(size int64) *RemoteFragmentLoader {
+	l.maxSize = size
+	return l
+}
+
+// Expired reports whether any fragment referenced by the schema needs to be refetched
+func (l *RemoteFragmentLoader) Expired(schema *FormSchema) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, ref := range schema.RemoteFragments {
+		entry, ok := l.cache[ref.URL]
+		if !ok || time.Since(entry.fetchedAt) >= l.cacheTTL {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch returns the verified fragment for a reference, using the cache when fresh
+func (l *RemoteFragmentLoader) Fetch(ref *RemoteFragmentRef) (*RemoteFragment, error) {
+	l.mutex.RLock()
+	entry, ok := l.cache[ref.URL]
+	l.mutex.RUnlock()
+
+	if ok && time.Since(entry.fetchedAt) < l.cacheTTL {
+		return entry.fragment, nil
+	}
+
+	fragment, err := l.fetch(ref)
+	if err != nil {
+		if !ok {
+			return nil, err
+		}
+		// Serve the last verified copy rather than breaking the form, and wait another cache
+		// lifetime before fetching again rather than fetching on every request
+		fragment = entry.fragment
+	}
+
+	l.mutex.Lock()
+	l.cache[ref.URL] = &cachedFragment{fragment: fragment, fetchedAt: time.Now()}
+	l.mutex.Unlock()
+
+	return fragment, nil
+}
+
+// fetch downloads and verifies a fragment
+func (l *RemoteFragmentLoader) fetch(ref *RemoteFragmentRef) (*RemoteFragment, error) {
+	resp, err := l.client.Get(ref.URL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching fragment %s: %w", ref.URL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, l.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading fragment %s: %w", ref.URL, err)
+	}
+	if int64(len(body)) > l.maxSize {
+		return nil, fmt.Errorf("fragment %s is larger than %d bytes", ref.URL, l.maxSize)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fragment %s returned status %d", ref.URL, resp.StatusCode)
+	}
+
+	if ref.Integrity != "" {
+		sum := sha256.Sum256(body)
+		if expected := "sha256-" + base64.StdEncoding.EncodeToString(sum[:]); expected != ref.Integrity {
+			return nil, fmt.Errorf("fragment %s failed integrity check", ref.URL)
+		}
+	}
+
+	if err := l.verifySignature(ref, body, resp.Header); err != nil {
+		return nil, err
+	}
+
+	var fragment RemoteFragment
+	if err := json.Unmarshal(body, &fragment); err != nil {
+		return nil, fmt.Errorf("error parsing fragment %s: %w", ref.URL, err)
+	}
+
+	if err := validateFragment(&fragment); err != nil {
+		return nil, fmt.Errorf("invalid fragment %s: %w", ref.URL, err)
+	}
+
+	return &fragment, nil
+}
+
+// verifySignature checks the ed25519 signature attached to a fragment response
+func (l *RemoteFragmentLoader) verifySignature(ref *RemoteFragmentRef, body []byte, header http.Header) error {
+	signature := header.Get(FragmentSignatureHeader)
+	if signature == "" {
+		if l.allowUnsigned && ref.KeyID == "" {
+			return nil
+		}
+		return fmt.Errorf("fragment %s is not signed", ref.URL)
+	}
+
+	keyID := header.Get(FragmentKeyIDHeader)
+	if ref.KeyID != "" && keyID != ref.KeyID {
+		return fmt.Errorf("fragment %s signed with key '%s', expected '%s'", ref.URL, keyID, ref.KeyID)
+	}
+
+	l.mutex.RLock()
+	key, ok := l.trustedKeys[keyID]
+	l.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("fragment %s signed with untrusted key '%s'", ref.URL, keyID)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, signedFragment(ref.URL, body), decoded) {
+		return fmt.Errorf("fragment %s has an invalid signature", ref.URL)
+	}
+
+	return nil
+}
+
+// Apply returns a copy of the schema with all remote fragments merged in
+func (l *RemoteFragmentLoader) Apply(schema *FormSchema) (*FormSchema, error) {
+	merged := schema.Clone()
+
+	existing := make(map[string]bool)
+	for _, id := range collectFieldIDs(merged.Fields) {
+		existing[id] = true
+	}
+
+	for _, ref := range schema.RemoteFragments {
+		fragment, err := l.Fetch(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := prefixFragmentFields(fragment.Fields, ref.Prefix)
+		for i, field := range fields {
+			if existing[field.ID] {
+				return nil, fmt.Errorf("fragment %s field '%s' conflicts with an existing field", ref.URL, field.ID)
+			}
+			existing[field.ID] = true
+
+			field.Properties["fragmentSource"] = ref.URL
+			if fragment.Version != "" {
+				field.Properties["fragmentVersion"] = fragment.Version
+			}
+			if ref.Order > 0 {
+				field.Order = ref.Order + i
+			}
+		}
+
+		merged.Fields = append(merged.Fields, fields...)
+	}
+
+	merged.SortFields()
+	return merged, nil
+}
+
+// signedFragment returns the payload a fragment's signature covers: the URL it is published at
+// and its serialized document, so that a fragment signed for one URL cannot be served at another
+func signedFragment(url string, body []byte) []byte {
+	return append([]byte(url+"\n"), body...)
+}
+
+// SignFragment signs a serialized fragment for publication at url, the URL forms reference it by
+func SignFragment(url string, body []byte, key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedFragment(url, body)))
+}
+
+// ServeFragment returns a handler that publishes a fragment signed for url, the URL forms
+// reference it by
+func ServeFragment(url string, fragment *RemoteFragment, keyID string, key ed25519.PrivateKey) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, ErrMethodNotAllowed)
+			return
+		}
+
+		body, err := json.Marshal(fragment)
+		if err != nil {
+			writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, "Error encoding fragment"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(FragmentKeyIDHeader, keyID)
+		w.Header().Set(FragmentSignatureHeader, SignFragment(url, body, key))
+		_, _ = w.Write(body)
+	}
+}
+
+// validateFragment checks that a fragment is well-formed
+func validateFragment(fragment *RemoteFragment) error {
+	return validateFragmentFields(fragment.Fields)
+}
+
+// validateFragmentFields checks that the fields of a fragment are well-formed
+func validateFragmentFields(fields []*Field) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("fragment has no fields")
+	}
+
+	seen := make(map[string]bool)
+	for _, id := range collectFieldIDs(fields) {
+		if id == "" {
+			return fmt.Errorf("fragment contains a field without an ID")
+		}
+		if seen[id] {
+			return fmt.Errorf("fragment contains duplicate field ID '%s'", id)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// collectFieldIDs returns the IDs of all fields, including nested ones
+func collectFieldIDs(fields []*Field) []string {
+	ids := []string{}
+	for _, field := range fields {
+		ids = append(ids, field.ID)
+		if field.Nested != nil {
+			ids = append(ids, collectFieldIDs(field.Nested)...)
+		}
+	}
+	return ids
+}
+
+// prefixFieldID applies a fragment prefix to a field ID
+func prefixFieldID(prefix, id string) string {
+	if prefix == "" {
+		return id
+	}
+	return prefix + "_" + id
+}
+
+// prefixFragmentFields copies fields, prefixing top-level IDs and rewriting references
+// between fragment fields so that conditions keep pointing at the right fields
+func prefixFragmentFields(fields []*Field, prefix string) []*Field {
+	copies := cloneFields(fields)
+	if prefix == "" {
+		for _, field := range copies {
+			if field.Properties == nil {
+				field.Properties = make(map[string]interface{})
+			}
+		}
+		return copies
+	}
+
+	mapping := make(map[string]string)
+	for _, field := range copies {
+		mapping[field.ID] = prefixFieldID(prefix, field.ID)
+	}
+
+	for _, field := range copies {
+		field.ID = mapping[field.ID]
+		rewriteFieldReferences(field, mapping)
+		if field.Properties == nil {
+			field.Properties = make(map[string]interface{})
+		}
+	}
+
+	return copies
+}
+
+// rewriteFieldReferences renames references to other fields inside a field definition
+func rewriteFieldReferences(field *Field, mapping map[string]string) {
+	rewriteConditionReferences(field.RequiredIf, mapping)
+	rewriteConditionReferences(field.Visible, mapping)
+	rewriteConditionReferences(field.Enabled, mapping)
+
+	for _, defaultWhen := range field.DefaultWhen {
+		rewriteConditionReferences(defaultWhen.Condition, mapping)
+	}
+
+	for _, rule := range field.ValidationRules {
+		switch params := rule.Parameters.(type) {
+		case *Condition:
+			rewriteConditionReferences(params, mapping)
+		case map[string]interface{}:
+			if ref, ok := params["field"].(string); ok {
+				if renamed, ok := mapping[ref]; ok {
+					params["field"] = renamed
+				}
+			}
+		}
+	}
+
+	if field.Options != nil {
+		if field.Options.DynamicSource != nil {
+			for i, ref := range field.Options.DynamicSource.RefreshOn {
+				if renamed, ok := mapping[ref]; ok {
+					field.Options.DynamicSource.RefreshOn[i] = renamed
+				}
+			}
+			for k, v := range field.Options.DynamicSource.Parameters {
+				if s, ok := v.(string); ok {
+					field.Options.DynamicSource.Parameters[k] = rewriteTemplateReferences(s, mapping)
+				}
+			}
+		}
+		if field.Options.Dependency != nil {
+			if renamed, ok := mapping[field.Options.Dependency.Field]; ok {
+				field.Options.Dependency.Field = renamed
+			}
+		}
+	}
+
+	if s, ok := field.DefaultValue.(string); ok {
+		field.DefaultValue = rewriteTemplateReferences(s, mapping)
+	}
+	field.ComputedValue = rewriteTemplateReferences(field.ComputedValue, mapping)
+}
+
+// rewriteConditionReferences renames field references inside a condition tree
+func rewriteConditionReferences(condition *Condition, mapping map[string]string) {
+	if condition == nil {
+		return
+	}
+
+	if renamed, ok := mapping[condition.Field]; ok {
+		condition.Field = renamed
+	}
+	if condition.Expression != "" {
+		condition.Expression = rewriteExpressionReferences(condition.Expression, mapping)
+	}
+	for _, sub := range condition.Conditions {
+		rewriteConditionReferences(sub, mapping)
+	}
+}
+
+// templatePattern matches ${...} template expressions
+var templatePattern = regexp.MustCompile(`\$\{[^}]*\}`)
+
+// rewriteTemplateReferences renames field identifiers inside the ${...} expressions of a string
+func rewriteTemplateReferences(value string, mapping map[string]string) string {
+	if len(mapping) == 0 {
+		return value
+	}
+
+	return templatePattern.ReplaceAllStringFunc(value, func(expression string) string {
+		return rewriteExpressionReferences(expression, mapping)
+	})
+}
+
+// identifierPattern matches identifiers that may refer to field IDs in expressions
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// expressionTokenPattern matches the string literals of expressions and their dotted
+// identifiers, see referencePattern
+var expressionTokenPattern = regexp.MustCompile(stringLiteralPattern.String() + "|" + referencePattern.String())
+
+// rewriteExpressionReferences renames the fields an expression refers to, by the first
+// identifier of each reference. String literals, function names, and properties of other
+// values, such as the name in items[0].name, are left as they are.
+func rewriteExpressionReferences(expression string, mapping map[string]string) string {
+	if len(mapping) == 0 {
+		return expression
+	}
+
+	var rewritten strings.Builder
+	last := 0
+	for _, match := range expressionTokenPattern.FindAllStringIndex(expression, -1) {
+		token := expression[match[0]:match[1]]
+		rewritten.WriteString(expression[last:match[0]])
+		last = match[1]
+
+		property := match[0] > 0 && expression[match[0]-1] == '.'
+		if property || strings.ContainsAny(token[:1], `"'`) || strings.HasSuffix(token, "(") {
+			rewritten.WriteString(token)
+			continue
+		}
+		end := strings.IndexAny(token, ". \t\r\n")
+		if end < 0 {
+			end = len(token)
+		}
+		if renamed, ok := mapping[token[:end]]; ok {
+			token = renamed + token[end:]
+		}
+		rewritten.WriteString(token)
+	}
+	rewritten.WriteString(expression[last:])
+	return rewritten.String()
+}
@@ -0,0 +1,206 @@
+package smartform
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAddressFragment() *RemoteFragment {
+	return &RemoteFragment{
+		ID:      "address",
+		Version: "1.2.0",
+		Fields: []*Field{
+			{ID: "country", Type: FieldTypeText, Label: "Country"},
+			{
+				ID:    "state",
+				Type:  FieldTypeText,
+				Label: "State",
+				Visible: &Condition{
+					Type:     ConditionTypeSimple,
+					Field:    "country",
+					Operator: "eq",
+					Value:    "US",
+				},
+			},
+		},
+	}
+}
+
+// serveFragment publishes a fragment signed with the "billing" key for the URL of the server
+func serveFragment(fragment *RemoteFragment, key ed25519.PrivateKey) *httptest.Server {
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.Handler = ServeFragment("http://"+server.Listener.Addr().String(), fragment, "billing", key)
+	server.Start()
+	return server
+}
+
+func TestRemoteFragmentLoader_Apply(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	server := serveFragment(newAddressFragment(), private)
+	defer server.Close()
+
+	schema := NewForm("checkout", "Checkout").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Order(1).Build()).
+		IncludeRemoteFragment(server.URL, "billing", "shipping").
+		Build()
+
+	loader := NewRemoteFragmentLoader(time.Minute).TrustKey("billing", public)
+	merged, err := loader.Apply(schema)
+	require.NoError(t, err)
+
+	require.Len(t, merged.Fields, 3)
+	assert.Len(t, schema.Fields, 1, "original schema must not be modified")
+
+	state := merged.FindFieldByID("shipping_state")
+	require.NotNil(t, state)
+	assert.Equal(t, "shipping_country", state.Visible.Field)
+	assert.Equal(t, server.URL, state.Properties["fragmentSource"])
+	assert.Equal(t, "1.2.0", state.Properties["fragmentVersion"])
+}
+
+func TestRemoteFragmentLoader_RejectsUntrustedSignatures(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	server := serveFragment(newAddressFragment(), private)
+	defer server.Close()
+
+	schema := NewForm("checkout", "Checkout").
+		IncludeRemoteFragment(server.URL, "billing", "").
+		Build()
+
+	loader := NewRemoteFragmentLoader(time.Minute).TrustKey("billing", otherPublic)
+	_, err = loader.Apply(schema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid signature")
+
+	// Fragments signed for another URL are rejected
+	replayed := httptest.NewServer(ServeFragment("https://billing.example.com/address", newAddressFragment(), "billing", private))
+	defer replayed.Close()
+	_, err = NewRemoteFragmentLoader(time.Minute).TrustKey("billing", public).
+		Apply(NewForm("checkout", "Checkout").IncludeRemoteFragment(replayed.URL, "billing", "").Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid signature")
+
+	// Without a signature the fragment is rejected unless explicitly allowed
+	_, err = NewRemoteFragmentLoader(time.Minute).Apply(schema)
+	assert.Error(t, err)
+}
+
+func TestRemoteFragmentLoader_RejectsConflicts(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	server := serveFragment(newAddressFragment(), private)
+	defer server.Close()
+
+	schema := NewForm("checkout", "Checkout").
+		AddField(NewFieldBuilder("country", FieldTypeText, "Country").Build()).
+		IncludeRemoteFragment(server.URL, "billing", "").
+		Build()
+
+	_, err = NewRemoteFragmentLoader(time.Minute).TrustKey("billing", public).Apply(schema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicts")
+}
+
+func TestRewriteExpressionReferences(t *testing.T) {
+	mapping := map[string]string{"country": "shipping_country", "upper": "shipping_upper", "name": "shipping_name"}
+	for expression, expected := range map[string]string{
+		`country == "US"`:                     `shipping_country == "US"`,
+		`${country == 'country'}`:             `${shipping_country == 'country'}`,
+		`upper(country) == "NG"`:              `upper(shipping_country) == "NG"`,
+		`country.code != user.country`:        `shipping_country.code != user.country`,
+		`items[0].name == name`:               `items[0].name == shipping_name`,
+		`country == "the country's name"`:     `shipping_country == "the country's name"`,
+		`user.name + " from " + country.name`: `user.name + " from " + shipping_country.name`,
+	} {
+		assert.Equal(t, expected, rewriteExpressionReferences(expression, mapping), expression)
+	}
+}
+
+func TestRemoteFragmentRefresh(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	fragment := newAddressFragment()
+	var failing atomic.Bool
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		ServeFragment("http://"+r.Host, fragment, "billing", private)(w, r)
+	}))
+	defer server.Close()
+
+	schema := NewForm("checkout", "Checkout").IncludeRemoteFragment(server.URL, "billing", "").Build()
+	handler := NewAPIHandler()
+	failing.Store(true)
+	handler.SetFragmentLoader(NewRemoteFragmentLoader(time.Millisecond).TrustKey("billing", public))
+	assert.Error(t, handler.RegisterSchema(schema), "forms are not registered without their fragments")
+	failing.Store(false)
+	require.NoError(t, handler.RegisterSchema(schema))
+
+	// A fragment failing the checks of registration leaves the last good merge served
+	lock.Lock()
+	fragment = &RemoteFragment{ID: "address", Fields: []*Field{
+		{ID: "a", Type: FieldTypeNumber, Label: "A", ComputedValue: "${b}"},
+		{ID: "b", Type: FieldTypeNumber, Label: "B", ComputedValue: "${a}"},
+	}}
+	lock.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	current, ok := handler.GetSchema("checkout")
+	require.True(t, ok)
+	assert.NotNil(t, current.FindFieldByID("state"))
+	assert.Nil(t, current.FindFieldByID("a"))
+
+	// Failed fetches serve the cached fragment, and are not retried until it expires again
+	loader := NewRemoteFragmentLoader(time.Hour).TrustKey("billing", public)
+	ref := schema.RemoteFragments[0]
+	_, err = loader.Fetch(ref)
+	require.NoError(t, err)
+	loader.cache[ref.URL].fetchedAt = time.Now().Add(-2 * time.Hour)
+	failing.Store(true)
+	before := fetches.Load()
+	for range 3 {
+		cached, err := loader.Fetch(ref)
+		require.NoError(t, err)
+		assert.Len(t, cached.Fields, 2)
+	}
+	assert.Equal(t, before+1, fetches.Load())
+	assert.False(t, loader.Expired(schema))
+}
+
+func TestRemoteFragmentLoader_MaxSize(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	fragment := newAddressFragment()
+	fragment.Fields[0].Label = strings.Repeat("x", 2048)
+	server := serveFragment(fragment, private)
+	defer server.Close()
+
+	schema := NewForm("checkout", "Checkout").IncludeRemoteFragment(server.URL, "billing", "").Build()
+	_, err = NewRemoteFragmentLoader(time.Minute).TrustKey("billing", public).SetMaxSize(1024).Apply(schema)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "larger than")
+}
@@ -0,0 +1,80 @@
+package smartform
+
+import (
+	"context"
+	"fmt"
+)
+
+// RemoteValidator checks a field value against an external system, such as a user directory
+// to verify that a username is not taken. It returns an error describing why the value is
+// rejected, or nil if the value is acceptable.
+type RemoteValidator func(ctx context.Context, value interface{}, formState map[string]interface{}) error
+
+// validateRemote calls the remote validator a rule refers to. The rule's message is reported
+// when set, otherwise the validator's error.
+func (v *Validator) validateRemote(rule *ValidationRule, value interface{}, data map[string]interface{}) (bool, string) {
+	name, _ := rule.Parameters.(string)
+
+	var validator RemoteValidator
+	if v.options != nil {
+		validator = v.options.RemoteValidators[name]
+	}
+	if validator == nil {
+		return false, fmt.Sprintf("remote validator %q is not registered", name)
+	}
+
+	ctx := v.options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if err := validator(ctx, value, data); err != nil {
+		if rule.Message != "" {
+			return false, rule.Message
+		}
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// Remote creates a rule checking the value with the remote validator registered under name
+func (vb *ValidationBuilder) Remote(name string, message string) *ValidationRule {
+	return &ValidationRule{
+		Type:       ValidationTypeRemote,
+		Message:    message,
+		Parameters: name,
+	}
+}
+
+// ValidateRemote adds a rule checking the value with the remote validator registered under name.
+// An empty message reports the validator's error instead.
+func (fb *FieldBuilder) ValidateRemote(name string, message string) *FieldBuilder {
+	return fb.AddValidation(&ValidationRule{
+		Type:       ValidationTypeRemote,
+		Message:    message,
+		Parameters: name,
+	})
+}
+
+// RegisterRemoteValidator registers a validator that remote validation rules refer to by name
+func (ah *APIHandler) RegisterRemoteValidator(name string, validator RemoteValidator) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+
+	if ah.remoteValidators == nil {
+		ah.remoteValidators = make(map[string]RemoteValidator)
+	}
+	ah.remoteValidators[name] = validator
+}
+
+// getRemoteValidators returns a copy of the registered remote validators
+func (ah *APIHandler) getRemoteValidators() map[string]RemoteValidator {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+
+	validators := make(map[string]RemoteValidator, len(ah.remoteValidators))
+	for name, validator := range ah.remoteValidators {
+		validators[name] = validator
+	}
+	return validators
+}
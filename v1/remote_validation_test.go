@@ -0,0 +1,68 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteValidation(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.RegisterRemoteValidator("usernameAvailable", func(ctx context.Context, value interface{}, formState map[string]interface{}) error {
+		if value == "ada" {
+			return errors.New("ada is already taken")
+		}
+		return nil
+	})
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Sign up").
+		AddField(NewFieldBuilder("username", FieldTypeText, "Username").Required(true).ValidateRemote("usernameAvailable", "").Build()).
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		AddField(NewFieldBuilder("city", FieldTypeText, "City").ValidateRemote("cityExists", "Unknown city").Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	validate := func(path, body string) (int, *ValidationResult) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var result ValidationResult
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec.Code, &result
+	}
+
+	// A single field is checked without reporting the rest of the form
+	code, result := validate("/api/validate/signup/field/username", `{"username": "ada"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, &ValidationError{FieldID: "username", Message: "ada is already taken", RuleType: "remote"}, result.Errors[0])
+
+	code, result = validate("/api/validate/signup/field/username", `{"username": "grace"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.True(t, result.Valid)
+
+	// Rules naming a validator that is not registered fail
+	code, result = validate("/api/validate/signup/field/city", `{"city": "Atlantis"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "city", result.Errors[0].FieldID)
+	assert.Contains(t, result.Errors[0].Message, "cityExists")
+
+	code, _ = validate("/api/validate/signup/field/phone", `{}`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// Remote validators also run when the whole form is validated
+	code, result = validate("/api/validate/signup", `{"username": "ada", "email": "ada@example.com"}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "username", result.Errors[0].FieldID)
+}
@@ -0,0 +1,58 @@
+package smartform
+
+import "net/http"
+
+// RenderContextFunc contributes variables, such as feature flags, experiment arms, or the
+// user's profile, to the context forms are rendered, validated, and computed with
+type RenderContextFunc func(r *http.Request) map[string]interface{}
+
+// UseRenderContext adds a function whose variables are made available to templates and
+// conditions on every render, validation, submission, array item, and dynamic function
+// call. Later functions override the variables of earlier ones, and all of them override
+// query parameters and submitted data, which clients control.
+func (ah *APIHandler) UseRenderContext(fn RenderContextFunc) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.renderContexts = append(ah.renderContexts, fn)
+}
+
+// renderContext collects the variables contributed for a request, including its feature flags
+// and the time it is evaluated at
+func (ah *APIHandler) renderContext(r *http.Request) map[string]interface{} {
+	ah.schemasLock.RLock()
+	contexts := ah.renderContexts
+	provider := ah.flagProvider
+	ah.schemasLock.RUnlock()
+
+	variables := map[string]interface{}{NowVariable: ah.requestTime(r.Context())}
+	for _, fn := range contexts {
+		for key, value := range fn(r) {
+			variables[key] = value
+		}
+	}
+	if provider != nil {
+		variables[FeatureFlagsVariable] = evaluateFeatureFlags(provider, r)
+	}
+	return variables
+}
+
+// withRenderContext returns a copy of state with the request's variables laid over it
+func (ah *APIHandler) withRenderContext(r *http.Request, state map[string]interface{}) map[string]interface{} {
+	return overlayVariables(state, ah.renderContext(r))
+}
+
+// overlayVariables returns a copy of data with variables taking precedence, or data itself
+// when there are no variables
+func overlayVariables(data, variables map[string]interface{}) map[string]interface{} {
+	if len(variables) == 0 {
+		return data
+	}
+	scope := make(map[string]interface{}, len(data)+len(variables))
+	for key, value := range data {
+		scope[key] = value
+	}
+	for key, value := range variables {
+		scope[key] = value
+	}
+	return scope
+}
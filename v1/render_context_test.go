@@ -0,0 +1,87 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRenderContextHandler(t *testing.T) *http.ServeMux {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email for ${plan}").Required(true).Build()).
+		AddField(NewFieldBuilder("betaCode", FieldTypeText, "Beta code").
+			Required(true).
+			VisibleWhenEquals("beta", true).
+			Build()).
+		Build()))
+
+	// Middleware decides the flags from a header the proxy sets
+	handler.UseRenderContext(func(r *http.Request) map[string]interface{} {
+		return map[string]interface{}{"plan": "free", "beta": r.Header.Get("X-Beta") == "on"}
+	})
+	handler.UseRenderContext(func(r *http.Request) map[string]interface{} {
+		if r.Header.Get("X-Plan") != "" {
+			return map[string]interface{}{"plan": r.Header.Get("X-Plan")}
+		}
+		return nil
+	})
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	return mux
+}
+
+func TestRenderContextTemplates(t *testing.T) {
+	mux := newRenderContextHandler(t)
+
+	render := func(path, plan string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if plan != "" {
+			req.Header.Set("X-Plan", plan)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var rendered struct {
+			Fields []struct {
+				Label string `json:"label"`
+			} `json:"fields"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+		return rendered.Fields[0].Label
+	}
+
+	assert.Equal(t, "Email for free", render("/api/forms/signup", ""))
+	assert.Equal(t, "Email for pro", render("/api/forms/signup", "pro"))
+
+	// Query parameters cannot override middleware variables
+	assert.Equal(t, "Email for free", render("/api/forms/signup?plan=enterprise", ""))
+}
+
+func TestRenderContextConditions(t *testing.T) {
+	mux := newRenderContextHandler(t)
+
+	validate := func(beta, body string) *ValidationResult {
+		req := httptest.NewRequest(http.MethodPost, "/api/validate/signup", strings.NewReader(body))
+		req.Header.Set("X-Beta", beta)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return &result
+	}
+
+	assert.True(t, validate("off", `{"email": "a@example.com"}`).Valid)
+	assert.False(t, validate("on", `{"email": "a@example.com"}`).Valid)
+	assert.True(t, validate("on", `{"email": "a@example.com", "betaCode": "xyz"}`).Valid)
+
+	// Submitted data cannot switch a flag on
+	assert.True(t, validate("off", `{"email": "a@example.com", "beta": true}`).Valid)
+}
@@ -0,0 +1,371 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRequestDiagnostics limits how many problems are reported for a single request body
+const maxRequestDiagnostics = 20
+
+// RequestDiagnostic describes one problem with a request body
+type RequestDiagnostic struct {
+	Path    string `json:"path,omitempty"`   // Where the problem is, e.g. items[1].quantity
+	Message string `json:"message"`          // What is wrong
+	Line    int    `json:"line,omitempty"`   // Line of a syntax error
+	Column  int    `json:"column,omitempty"` // Column of a syntax error
+}
+
+// RequestError is the structured payload returned when a request body cannot be decoded. It
+// has the shape of an APIError, with the diagnostics as its details.
+type RequestError struct {
+	Code        string               `json:"code"`
+	Message     string               `json:"message"`
+	Field       string               `json:"field,omitempty"` // Path of the first problem, if it has one
+	Diagnostics []*RequestDiagnostic `json:"details"`
+	status      int                  // 413 or 422 for bodies over the request limits, 400 otherwise
+}
+
+// statusCode returns the status the error is reported with
+func (re *RequestError) statusCode() int {
+	if re.status != 0 {
+		return re.status
+	}
+	return http.StatusBadRequest
+}
+
+// Error implements the error interface
+func (re *RequestError) Error() string {
+	if len(re.Diagnostics) == 0 {
+		return re.Message
+	}
+	return re.Message + ": " + re.Diagnostics[0].Message
+}
+
+// newRequestError creates a request error from diagnostics
+func newRequestError(diagnostics ...*RequestDiagnostic) *RequestError {
+	requestErr := &RequestError{Code: ErrorCodeInvalidRequest, Message: "Invalid request body", Diagnostics: diagnostics}
+	if len(diagnostics) > 0 {
+		requestErr.Field = diagnostics[0].Path
+	}
+	return requestErr
+}
+
+// missingFieldError reports a required request field that was not given
+func missingFieldError(path string) *RequestError {
+	return newRequestError(&RequestDiagnostic{Path: path, Message: path + " is required"})
+}
+
+// decodeRequest strictly decodes a JSON request body into target. Unknown fields of structs,
+// values of the wrong type, and trailing data are rejected with a *RequestError that names the
+// path of every problem found. Bodies over the limits of their route are rejected before they
+// are decoded.
+func decodeRequest(body io.Reader, target interface{}) error {
+	limits := requestLimits(body)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return readError(err)
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return syntaxError(data, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		line, column := textPosition(data, decoder.InputOffset())
+		return newRequestError(&RequestDiagnostic{
+			Message: "unexpected data after the JSON value",
+			Line:    line,
+			Column:  column,
+		})
+	}
+	if err := checkJSONLimits(data, limits); err != nil {
+		return err
+	}
+
+	var diagnostics []*RequestDiagnostic
+	checkJSONShape(value, reflect.TypeOf(target).Elem(), "", &diagnostics)
+	if len(diagnostics) > 0 {
+		return newRequestError(diagnostics...)
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return newRequestError(&RequestDiagnostic{Message: err.Error()})
+	}
+	return nil
+}
+
+// writeRequestError writes a decoding error as a structured response: a 400 error, or a 413 or
+// 422 error for bodies over the request limits
+func writeRequestError(w http.ResponseWriter, err error) {
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) {
+		requestErr = newRequestError(&RequestDiagnostic{Message: err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(requestErr.statusCode())
+	_ = json.NewEncoder(w).Encode(requestErr)
+}
+
+// syntaxError translates an error from decoding malformed JSON
+func syntaxError(data []byte, err error) *RequestError {
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		return newRequestError(&RequestDiagnostic{Message: "request body is empty"})
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		line, column := textPosition(data, int64(len(data)))
+		return newRequestError(&RequestDiagnostic{Message: "unexpected end of JSON", Line: line, Column: column})
+	case errors.As(err, &syntaxErr):
+		// The offset counts the offending byte
+		line, column := textPosition(data, max(syntaxErr.Offset-1, 0))
+		return newRequestError(&RequestDiagnostic{Message: syntaxErr.Error(), Line: line, Column: column})
+	default:
+		return newRequestError(&RequestDiagnostic{Message: err.Error()})
+	}
+}
+
+// textPosition converts a byte offset into a 1-based line and column
+func textPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// checkJSONShape compares a generically decoded value with the Go type it will be decoded
+// into and records a diagnostic for every mismatch
+func checkJSONShape(value interface{}, t reflect.Type, path string, diagnostics *[]*RequestDiagnostic) {
+	if value == nil || len(*diagnostics) >= maxRequestDiagnostics {
+		// null is accepted for every type, as it is by encoding/json
+		return
+	}
+
+	// Types that decode themselves are trusted
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return
+	}
+	if _, isString := value.(string); isString && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+
+	mismatch := func(expected string) {
+		*diagnostics = append(*diagnostics, &RequestDiagnostic{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s%s, got %s", expected, atPath(path), jsonKind(value)),
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		checkJSONShape(value, t.Elem(), path, diagnostics)
+
+	case reflect.Interface:
+		// Any value fits an empty interface
+
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			mismatch("string")
+		}
+
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			mismatch("boolean")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := value.(json.Number)
+		if !ok {
+			mismatch("integer")
+			return
+		}
+		if _, err := strconv.ParseInt(number.String(), 10, t.Bits()); err != nil {
+			mismatch("integer")
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, ok := value.(json.Number)
+		if !ok {
+			mismatch("non-negative integer")
+			return
+		}
+		if _, err := strconv.ParseUint(number.String(), 10, t.Bits()); err != nil {
+			mismatch("non-negative integer")
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			mismatch("number")
+		}
+
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are base64 strings
+			if _, ok := value.(string); !ok {
+				mismatch("string")
+			}
+			return
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			mismatch("array")
+			return
+		}
+		for i, item := range items {
+			checkJSONShape(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), diagnostics)
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("object")
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			checkJSONShape(object[key], t.Elem(), joinJSONPath(path, key), diagnostics)
+		}
+
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			mismatch("object")
+			return
+		}
+		fields := jsonStructFields(t)
+		for _, key := range sortedKeys(object) {
+			fieldType, known := lookupJSONField(fields, key)
+			if !known {
+				if len(*diagnostics) < maxRequestDiagnostics {
+					*diagnostics = append(*diagnostics, &RequestDiagnostic{
+						Path:    joinJSONPath(path, key),
+						Message: fmt.Sprintf("unknown field %q%s", key, inPath(path)),
+					})
+				}
+				continue
+			}
+			checkJSONShape(object[key], fieldType, joinJSONPath(path, key), diagnostics)
+		}
+	}
+}
+
+// jsonStructFields maps the JSON names of a struct's fields to their types, following
+// embedded structs the way encoding/json does
+func jsonStructFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for embeddedName, embeddedType := range jsonStructFields(embedded) {
+					if _, exists := fields[embeddedName]; !exists {
+						fields[embeddedName] = embeddedType
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// lookupJSONField finds a field by its JSON name, falling back to the case-insensitive
+// match encoding/json accepts
+func lookupJSONField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if fieldType, ok := fields[key]; ok {
+		return fieldType, true
+	}
+	for name, fieldType := range fields {
+		if strings.EqualFold(name, key) {
+			return fieldType, true
+		}
+	}
+	return nil, false
+}
+
+// jsonKind names the JSON type of a generically decoded value
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// joinJSONPath appends an object key to a diagnostic path
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// atPath and inPath phrase a diagnostic path for messages; the root has no suffix
+func atPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
+
+func inPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " in " + path
+}
+
+// sortedKeys returns the keys of an object in a stable order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,79 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRequestDiagnostics(t *testing.T) {
+	type item struct {
+		SKU      string `json:"sku"`
+		Quantity int    `json:"quantity"`
+	}
+	var order struct {
+		Items []item `json:"items"`
+		Note  string `json:"note,omitempty"`
+	}
+
+	err := decodeRequest(strings.NewReader(`{
+		"items": [{"sku": "a", "quantity": 1}, {"sku": "b", "quantity": "two"}, {"sku": 3, "quantity": 1.5}],
+		"coupon": "SAVE"
+	}`), &order)
+	requestErr, ok := err.(*RequestError)
+	require.True(t, ok, "%v", err)
+
+	messages := map[string]string{}
+	for _, diagnostic := range requestErr.Diagnostics {
+		messages[diagnostic.Path] = diagnostic.Message
+	}
+	assert.Equal(t, map[string]string{
+		"coupon":            `unknown field "coupon"`,
+		"items[1].quantity": "expected integer at items[1].quantity, got string",
+		"items[2].quantity": "expected integer at items[2].quantity, got number",
+		"items[2].sku":      "expected string at items[2].sku, got number",
+	}, messages)
+
+	require.NoError(t, decodeRequest(strings.NewReader(`{"items": [{"sku": "a", "quantity": 2}], "note": null}`), &order))
+	assert.Equal(t, 2, order.Items[0].Quantity)
+}
+
+func TestDecodeRequestSyntax(t *testing.T) {
+	var data map[string]interface{}
+
+	err := decodeRequest(strings.NewReader("{\n  \"name\": \"Ada\",\n  \"age\": }"), &data)
+	requestErr, ok := err.(*RequestError)
+	require.True(t, ok)
+	assert.Equal(t, 3, requestErr.Diagnostics[0].Line)
+	assert.Equal(t, 10, requestErr.Diagnostics[0].Column)
+
+	assert.Error(t, decodeRequest(strings.NewReader(`{"a": 1} {"b": 2}`), &data))
+	assert.Error(t, decodeRequest(strings.NewReader(``), &data))
+	assert.Error(t, decodeRequest(strings.NewReader(`[1, 2]`), &data))
+}
+
+func TestHandlerReturnsRequestDiagnostics(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(NewDynamicFunctionService())
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/function/echo",
+		strings.NewReader(`{"arguments": [], "formstate": {}, "extra": true}`)))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var payload RequestError
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &payload))
+	assert.Equal(t, "Invalid request body", payload.Message)
+	require.Len(t, payload.Diagnostics, 2)
+	assert.Equal(t, "arguments", payload.Diagnostics[0].Path)
+	assert.Equal(t, "expected object at arguments, got array", payload.Diagnostics[0].Message)
+	assert.Equal(t, `unknown field "extra"`, payload.Diagnostics[1].Message)
+}
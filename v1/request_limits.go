@@ -0,0 +1,241 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxBodySize is the largest request body accepted by default, except by the upload
+// endpoint, which has its own limit
+const DefaultMaxBodySize int64 = 4 << 20
+
+// DefaultMaxJSONDepth is how deeply JSON request bodies may nest arrays and objects by default
+const DefaultMaxJSONDepth = 64
+
+// RequestLimits bounds the request bodies the handler reads, against payloads crafted to
+// exhaust memory or to be read differently by other parsers
+type RequestLimits struct {
+	MaxBodySize         int64            // Largest body of any endpoint; DefaultMaxBodySize when zero
+	EndpointBodySizes   map[string]int64 // Largest body by route path, e.g. "/api/submit/"; uploads are limited by SetMaxUploadSize unless listed
+	MaxDepth            int              // How deeply JSON bodies may nest; DefaultMaxJSONDepth when zero
+	RejectDuplicateKeys bool             // Reject JSON objects repeating a key, which parsers resolve differently
+}
+
+// defaultRequestLimits are the limits of handlers created without WithRequestLimits, and of
+// bodies decoded outside of a request
+var defaultRequestLimits = &RequestLimits{MaxBodySize: DefaultMaxBodySize, MaxDepth: DefaultMaxJSONDepth}
+
+// WithRequestLimits sets the limits of the request bodies the handler reads. Bodies over their
+// size limit are rejected with a 413 payload_too_large error, and JSON nesting too deep or
+// repeating keys with a 422 unprocessable error.
+func WithRequestLimits(limits RequestLimits) HandlerOption {
+	return func(ah *APIHandler) {
+		if limits.MaxBodySize <= 0 {
+			limits.MaxBodySize = DefaultMaxBodySize
+		}
+		if limits.MaxDepth <= 0 {
+			limits.MaxDepth = DefaultMaxJSONDepth
+		}
+		ah.requestLimits = &limits
+	}
+}
+
+// limits returns the request limits of the handler
+func (ah *APIHandler) limits() *RequestLimits {
+	if ah.requestLimits != nil {
+		return ah.requestLimits
+	}
+	return defaultRequestLimits
+}
+
+// bodySize returns the largest body accepted by a route, or 0 for routes that limit their
+// bodies themselves
+func (rl *RequestLimits) bodySize(route string) int64 {
+	if size, ok := rl.EndpointBodySizes[route]; ok {
+		return size
+	}
+	if route == "/api/upload/" {
+		return 0
+	}
+	return rl.MaxBodySize
+}
+
+// limitedBody is a request body read within the limits of its route
+type limitedBody struct {
+	io.ReadCloser
+	limits *RequestLimits
+}
+
+// limitRoutes limits the request bodies of each route
+func (ah *APIHandler) limitRoutes(routes []Route) []Route {
+	limits := ah.limits()
+	for i, route := range routes {
+		size := limits.bodySize(route.Path)
+		if size <= 0 {
+			continue
+		}
+		next := route.Handler
+		routes[i].Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, size), limits: limits}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return routes
+}
+
+// requestLimits returns the limits a body is read within
+func requestLimits(body io.Reader) *RequestLimits {
+	if limited, ok := body.(*limitedBody); ok {
+		return limited.limits
+	}
+	return defaultRequestLimits
+}
+
+// payloadTooLarge reports a request body over its size limit
+func payloadTooLarge(limit int64) *RequestError {
+	requestErr := newRequestError(&RequestDiagnostic{Message: fmt.Sprintf("request body must be at most %d bytes", limit)})
+	requestErr.Code = ErrorCodePayloadTooLarge
+	requestErr.Message = "Request body too large"
+	requestErr.status = http.StatusRequestEntityTooLarge
+	return requestErr
+}
+
+// readError translates an error reading a request body
+func readError(err error) *RequestError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return payloadTooLarge(tooLarge.Limit)
+	}
+	return newRequestError(&RequestDiagnostic{Message: err.Error()})
+}
+
+// unprocessable reports well-formed JSON the handler refuses to decode
+func unprocessable(diagnostic *RequestDiagnostic) *RequestError {
+	requestErr := newRequestError(diagnostic)
+	requestErr.Code = ErrorCodeUnprocessable
+	requestErr.Message = "Request body exceeds the decoding limits"
+	requestErr.status = http.StatusUnprocessableEntity
+	return requestErr
+}
+
+// checkJSONLimits checks the nesting of well-formed JSON, and its keys when duplicates are
+// rejected, before it is decoded
+func checkJSONLimits(data []byte, limits *RequestLimits) *RequestError {
+	if offset, tooDeep := nestsDeeper(data, limits.MaxDepth); tooDeep {
+		line, column := textPosition(data, offset)
+		return unprocessable(&RequestDiagnostic{
+			Message: fmt.Sprintf("JSON nests deeper than %d levels", limits.MaxDepth),
+			Line:    line,
+			Column:  column,
+		})
+	}
+	if limits.RejectDuplicateKeys {
+		if path, ok := duplicateKey(data); ok {
+			return unprocessable(&RequestDiagnostic{Path: path, Message: "duplicate key " + path})
+		}
+	}
+	return nil
+}
+
+// nestsDeeper scans JSON for arrays and objects nested deeper than limit, returning where it
+// first goes over the limit
+func nestsDeeper(data []byte, limit int) (int64, bool) {
+	depth, inString, escaped := 0, false, false
+	for i, b := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch b {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '[' || b == '{':
+			depth++
+			if depth > limit {
+				return int64(i), true
+			}
+		case b == ']' || b == '}':
+			depth--
+		}
+	}
+	return 0, false
+}
+
+// duplicateKey finds the first key repeated within an object of well-formed JSON
+func duplicateKey(data []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	type frame struct {
+		path   string
+		keys   map[string]bool // Keys seen so far, for objects
+		index  int             // Index of the next item, for arrays
+		object bool
+		key    bool // Whether the next token of the object is a key
+	}
+	var stack []*frame
+
+	// path returns the path of the value a token starts
+	path := func(key string) string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			return joinJSONPath(top.path, key)
+		}
+		return fmt.Sprintf("%s[%d]", top.path, top.index)
+	}
+	lastKey := ""
+	// valueDone moves the enclosing container past a value
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.key = true
+		} else {
+			top.index++
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.object && top.key {
+				if key, ok := token.(string); ok {
+					if top.keys[key] {
+						return joinJSONPath(top.path, key), true
+					}
+					top.keys[key] = true
+					top.key = false
+					lastKey = key
+					continue
+				}
+			}
+		}
+
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, &frame{path: path(lastKey), keys: map[string]bool{}, object: true, key: true})
+		case json.Delim('['):
+			stack = append(stack, &frame{path: path(lastKey)})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
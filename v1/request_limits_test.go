@@ -0,0 +1,75 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLimits(t *testing.T) {
+	handler := NewAPIHandler(WithRequestLimits(RequestLimits{
+		MaxBodySize:         1024,
+		EndpointBodySizes:   map[string]int64{"/api/submit/": 64},
+		MaxDepth:            4,
+		RejectDuplicateKeys: true,
+	}))
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		AddField(NewFieldBuilder("notes", FieldTypeText, "Notes").Build()).
+		Build()))
+	mux := handler.Handler()
+
+	post := func(path, body string) (int, *APIError) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var apiErr APIError
+		_ = json.Unmarshal(rec.Body.Bytes(), &apiErr)
+		return rec.Code, &apiErr
+	}
+
+	code, _ := post("/api/submit/contact", `{"name": "Ada"}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, apiErr := post("/api/submit/contact", `{"name": "Ada", "notes": "`+strings.Repeat("x", 64)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Equal(t, ErrorCodePayloadTooLarge, apiErr.Code)
+
+	// Other endpoints take the default limit
+	code, _ = post("/api/validate/contact", `{"name": "Ada", "notes": "`+strings.Repeat("x", 64)+`"}`)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = post("/api/validate/contact", `{"notes": "`+strings.Repeat("x", 1024)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	code, apiErr = post("/api/validate/contact", `{"name": [[[[["deep"]]]]]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, ErrorCodeUnprocessable, apiErr.Code)
+	code, _ = post("/api/validate/contact", `{"name": "[[[[[not nested"}`)
+	assert.Equal(t, http.StatusOK, code, "brackets in strings do not nest")
+
+	code, apiErr = post("/api/validate/contact", `{"name": "Ada", "name": "Eve"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, "name", apiErr.Field)
+
+	// Malformed bodies are still bad requests
+	code, _ = post("/api/validate/contact", `{"name": `)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestDuplicateKey(t *testing.T) {
+	for data, expected := range map[string]string{
+		`{"a": 1, "b": 2}`:                            "",
+		`{"a": {"b": 1, "b": 2}}`:                     "a.b",
+		`{"a": [{"b": 1}, {"b": 1, "c": 2, "c": 3}]}`: "a[1].c",
+		`[{"a": 1}, {"a": 2}]`:                        "",
+		`{"a": [1, 2], "a": 3}`:                       "a",
+	} {
+		path, found := duplicateKey([]byte(data))
+		assert.Equal(t, expected != "", found, data)
+		assert.Equal(t, expected, path, data)
+	}
+}
@@ -0,0 +1,26 @@
+package smartform
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Route is an endpoint of the API, independent of the router it is mounted on
+type Route struct {
+	Path    string       // Path of the endpoint; a trailing slash also matches every path below it
+	Handler http.Handler // Handler of every method of the endpoint
+}
+
+// IsPrefix reports whether the route matches every path below its own, like a ServeMux pattern
+// ending in a slash. Routers that take path parameters need a catch-all pattern for it.
+func (r Route) IsPrefix() bool {
+	return strings.HasSuffix(r.Path, "/")
+}
+
+// Handler returns a handler serving every route of the API, for routers that mount a single
+// handler under a prefix
+func (ah *APIHandler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	ah.SetupRoutes(mux)
+	return mux
+}
@@ -0,0 +1,302 @@
+package smartform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of issues found by schema analysis
+const (
+	SchemaIssueDuplicateID      = "duplicateId"      // Two fields share a path
+	SchemaIssueCycle            = "cycle"            // Fields depend on each other in a cycle
+	SchemaIssueUnknownField     = "unknownField"     // A reference that must name a field names none
+	SchemaIssueUnknownReference = "unknownReference" // A reference names no field, so it must be a context variable
+)
+
+// Sources of the references between fields
+const (
+	ReferenceSourceVisible       = "visible"
+	ReferenceSourceEnabled       = "enabled"
+	ReferenceSourceRequiredIf    = "requiredIf"
+	ReferenceSourceDefaultWhen   = "defaultWhen"
+	ReferenceSourceRefreshOn     = "refreshOn"
+	ReferenceSourceDependency    = "dependency"
+	ReferenceSourceParameters    = "parameters"
+	ReferenceSourceDynamicValue  = "dynamicValue"
+	ReferenceSourceComputedValue = "computedValue"
+)
+
+// analysisVariables are variables available to expressions besides fields: feature flags and,
+// inside array items, the item, its parent, and its index
+var analysisVariables = map[string]bool{FeatureFlagsVariable: true, "item": true, "parent": true, "index": true}
+
+// SchemaIssue is a problem in how the fields of a schema refer to each other
+type SchemaIssue struct {
+	Kind      string   `json:"kind"`
+	FieldID   string   `json:"fieldId"`             // Path of the field the issue was found on
+	Source    string   `json:"source,omitempty"`    // Where the field refers to another one
+	Reference string   `json:"reference,omitempty"` // Reference that names no field
+	Cycle     []string `json:"cycle,omitempty"`     // Paths of the fields of a cycle, back to the first
+	Message   string   `json:"message"`
+}
+
+// SchemaAnalysis lists the issues of a schema. Errors break the form at runtime; warnings are
+// references that may be meant for context variables but could also be typos.
+type SchemaAnalysis struct {
+	Errors   []*SchemaIssue `json:"errors"`
+	Warnings []*SchemaIssue `json:"warnings"`
+}
+
+// SchemaAnalysisError is returned when analysis finds errors in a schema
+type SchemaAnalysisError struct {
+	SchemaID string
+	Issues   []*SchemaIssue
+}
+
+// Error implements the error interface
+func (e *SchemaAnalysisError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.Message
+	}
+	return fmt.Sprintf("schema %s has %d error(s): %s", e.SchemaID, len(e.Issues), strings.Join(messages, "; "))
+}
+
+// analysisNode is a field located in the schema, with the fields it refers to
+type analysisNode struct {
+	field      *Field
+	path       string
+	container  string
+	references []*fieldReference
+}
+
+// fieldReference is a reference from one field to another
+type fieldReference struct {
+	source string
+	name   string
+	strict bool // The reference must name a field, rather than possibly a context variable
+	target *analysisNode
+}
+
+// Analyze builds the graph of the references between the fields of the schema, through
+// conditions, option dependencies and refresh triggers, dynamic values, and computed values,
+// and reports duplicate field paths, cycles, and references to fields that do not exist
+func (fs *FormSchema) Analyze() *SchemaAnalysis {
+	analysis := &SchemaAnalysis{Errors: []*SchemaIssue{}, Warnings: []*SchemaIssue{}}
+
+	nodes := []*analysisNode{}
+	paths := map[string]*analysisNode{}
+	ids := map[string][]*analysisNode{}
+	var collect func(fields []*Field, container string)
+	collect = func(fields []*Field, container string) {
+		for _, field := range fields {
+			node := &analysisNode{field: field, path: joinPath(container, field.ID), container: container}
+			if _, exists := paths[node.path]; exists {
+				analysis.Errors = append(analysis.Errors, &SchemaIssue{
+					Kind:    SchemaIssueDuplicateID,
+					FieldID: node.path,
+					Message: fmt.Sprintf("field %s is declared more than once", node.path),
+				})
+				continue
+			}
+			nodes = append(nodes, node)
+			paths[node.path] = node
+			ids[field.ID] = append(ids[field.ID], node)
+			collect(field.Nested, node.path)
+		}
+	}
+	collect(fs.Fields, "")
+
+	for _, node := range nodes {
+		node.references = fieldReferences(node.field)
+		for _, ref := range node.references {
+			ref.target = resolveReference(node, ref.name, paths, ids)
+			if ref.target != nil || analysisVariables[strings.SplitN(ref.name, ".", 2)[0]] {
+				continue
+			}
+
+			issue := &SchemaIssue{
+				Kind:      SchemaIssueUnknownReference,
+				FieldID:   node.path,
+				Source:    ref.source,
+				Reference: ref.name,
+				Message:   fmt.Sprintf("%s of field %s refers to %s, which is not a field", ref.source, node.path, ref.name),
+			}
+			if ref.strict {
+				issue.Kind = SchemaIssueUnknownField
+				analysis.Errors = append(analysis.Errors, issue)
+			} else {
+				analysis.Warnings = append(analysis.Warnings, issue)
+			}
+		}
+	}
+
+	analysis.Errors = append(analysis.Errors, findCycles(nodes)...)
+	return analysis
+}
+
+// CheckDependencies analyzes the schema and returns a *SchemaAnalysisError listing its errors
+func (fs *FormSchema) CheckDependencies() error {
+	analysis := fs.Analyze()
+	if len(analysis.Errors) == 0 {
+		return nil
+	}
+	return &SchemaAnalysisError{SchemaID: fs.ID, Issues: analysis.Errors}
+}
+
+// BuildChecked builds the form like Build and analyzes it, failing with a *SchemaAnalysisError
+// when fields are declared twice, depend on each other in a cycle, or refer to missing fields
+func (fb *FormBuilder) BuildChecked() (*FormSchema, error) {
+	schema := fb.Build()
+	if err := schema.CheckDependencies(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// fieldReferences lists the references of a field to other fields
+func fieldReferences(field *Field) []*fieldReference {
+	references := []*fieldReference{}
+	add := func(source, name string, strict bool) {
+		if name != "" {
+			references = append(references, &fieldReference{source: source, name: name, strict: strict})
+		}
+	}
+
+	var addCondition func(source string, condition *Condition)
+	addCondition = func(source string, condition *Condition) {
+		if condition == nil {
+			return
+		}
+		add(source, condition.Field, false)
+		for _, sub := range condition.Conditions {
+			addCondition(source, sub)
+		}
+	}
+	addCondition(ReferenceSourceVisible, field.Visible)
+	addCondition(ReferenceSourceEnabled, field.Enabled)
+	addCondition(ReferenceSourceRequiredIf, field.RequiredIf)
+	for _, defaultWhen := range field.DefaultWhen {
+		addCondition(ReferenceSourceDefaultWhen, defaultWhen.Condition)
+	}
+
+	if field.Options != nil {
+		if field.Options.Dependency != nil {
+			add(ReferenceSourceDependency, field.Options.Dependency.Field, false)
+		}
+		if source := field.Options.DynamicSource; source != nil {
+			for _, name := range source.RefreshOn {
+				add(ReferenceSourceRefreshOn, name, true)
+			}
+			for _, value := range source.Parameters {
+				if s, ok := value.(string); ok {
+					for _, name := range templateReferences(s) {
+						add(ReferenceSourceParameters, name, false)
+					}
+				}
+			}
+		}
+	}
+
+	if config := fieldDynamicFunction(field); config != nil {
+		for _, value := range config.Arguments {
+			if s, ok := value.(string); ok {
+				for _, name := range templateReferences(s) {
+					add(ReferenceSourceDynamicValue, name, false)
+				}
+			}
+		}
+	}
+
+	for _, name := range templateReferences(field.ComputedValue) {
+		add(ReferenceSourceComputedValue, name, false)
+	}
+	return references
+}
+
+// resolveReference finds the field a reference names: a field of the same group or array item
+// by its ID, possibly through "item", a field by its full path, or a field by an ID no other
+// field has
+func resolveReference(node *analysisNode, name string, paths map[string]*analysisNode, ids map[string][]*analysisNode) *analysisNode {
+	if node.container != "" {
+		if target, ok := paths[joinPath(node.container, strings.TrimPrefix(name, "item."))]; ok {
+			return target
+		}
+	}
+	if target, ok := paths[name]; ok {
+		return target
+	}
+	if candidates := ids[name]; len(candidates) == 1 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// findCycles reports every cycle of references between fields once, starting from the field
+// declared first
+func findCycles(nodes []*analysisNode) []*SchemaIssue {
+	issues := []*SchemaIssue{}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[*analysisNode]int{}
+	reported := map[string]bool{}
+	stack := []*analysisNode{}
+
+	var visit func(node *analysisNode)
+	visit = func(node *analysisNode) {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, ref := range node.references {
+			if ref.target == nil {
+				continue
+			}
+			switch state[ref.target] {
+			case unvisited:
+				visit(ref.target)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != ref.target {
+					start--
+				}
+				cycle := []string{}
+				for _, member := range stack[start:] {
+					cycle = append(cycle, member.path)
+				}
+				cycle = append(cycle, ref.target.path)
+
+				key := cycleKey(cycle)
+				if reported[key] {
+					continue
+				}
+				reported[key] = true
+				issues = append(issues, &SchemaIssue{
+					Kind:    SchemaIssueCycle,
+					FieldID: ref.target.path,
+					Source:  ref.source,
+					Cycle:   cycle,
+					Message: fmt.Sprintf("fields depend on each other in a cycle: %s", strings.Join(cycle, " -> ")),
+				})
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+	}
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return issues
+}
+
+// cycleKey identifies a cycle regardless of the field it starts from
+func cycleKey(cycle []string) string {
+	members := append([]string(nil), cycle[:len(cycle)-1]...)
+	sort.Strings(members)
+	return strings.Join(members, "\x00")
+}
@@ -0,0 +1,61 @@
+package smartform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaAnalysis(t *testing.T) {
+	form := NewForm("shipping", "Shipping")
+	form.CheckboxField("gift", "Gift").VisibleWhenEquals("giftWrap", true)
+	form.CheckboxField("giftWrap", "Gift wrap").EnabledWhenEquals("gift", true)
+	form.TextField("email", "Email")
+	form.TextField("email", "Email again")
+	form.TextField("coupon", "Coupon").VisibleWhenEquals("beta", true).EnabledWhenFlag("coupons")
+	form.SelectField("city", "City").
+		WithDynamicOptionsConfig(NewOptionsBuilder().Dynamic().FromFunction("cities").RefreshOn("contry").Build())
+	items := form.ArrayField("items", "Items")
+	items.NumberField("price", "Price")
+	items.NumberField("quantity", "Quantity")
+	items.NumberField("lineTotal", "Line total").ComputedValue("${multiply(price, item.quantity)}")
+
+	analysis := form.Build().Analyze()
+	require.Len(t, analysis.Errors, 3)
+	assert.Equal(t, &SchemaIssue{
+		Kind:    SchemaIssueDuplicateID,
+		FieldID: "email",
+		Message: "field email is declared more than once",
+	}, analysis.Errors[0])
+	assert.Equal(t, &SchemaIssue{
+		Kind:      SchemaIssueUnknownField,
+		FieldID:   "city",
+		Source:    ReferenceSourceRefreshOn,
+		Reference: "contry",
+		Message:   "refreshOn of field city refers to contry, which is not a field",
+	}, analysis.Errors[1])
+	assert.Equal(t, SchemaIssueCycle, analysis.Errors[2].Kind)
+	assert.Equal(t, []string{"gift", "giftWrap", "gift"}, analysis.Errors[2].Cycle)
+
+	// References that may name context variables are only warnings; feature flags and fields
+	// of the same array item resolve
+	require.Len(t, analysis.Warnings, 1)
+	assert.Equal(t, SchemaIssueUnknownReference, analysis.Warnings[0].Kind)
+	assert.Equal(t, "beta", analysis.Warnings[0].Reference)
+
+	// Building checked and registering fail with the errors
+	_, err := form.BuildChecked()
+	var analysisErr *SchemaAnalysisError
+	require.True(t, errors.As(err, &analysisErr))
+	assert.Len(t, analysisErr.Issues, 3)
+	assert.Error(t, NewAPIHandler().RegisterSchema(form.Build()))
+
+	valid := NewForm("valid", "Valid")
+	valid.TextField("country", "Country")
+	valid.TextField("region", "Region").VisibleWhenExists("country")
+	schema, err := valid.BuildChecked()
+	require.NoError(t, err)
+	assert.Equal(t, "valid", schema.ID)
+}
@@ -0,0 +1,304 @@
+package smartform
+
+// Clone returns a deep copy of the schema. Registered functions and the variable
+// registry are shared with the original since they hold behaviour rather than data.
+func (fs *FormSchema) Clone() *FormSchema {
+	if fs == nil {
+		return nil
+	}
+
+	clone := *fs
+	clone.compiled = nil // Clones are usually changed, so they are compiled again if at all
+	clone.Fields = cloneFields(fs.Fields)
+	clone.Properties = cloneMap(fs.Properties)
+	clone.Annotations = fs.Annotations.Clone()
+	clone.Tags = append([]string(nil), fs.Tags...)
+	clone.Metadata = cloneMap(fs.Metadata)
+	if fs.Print != nil {
+		layout := *fs.Print
+		clone.Print = &layout
+	}
+
+	if fs.Scripts != nil {
+		clone.Scripts = make([]*ScriptDefinition, len(fs.Scripts))
+		for i, script := range fs.Scripts {
+			scriptCopy := *script
+			clone.Scripts[i] = &scriptCopy
+		}
+	}
+
+	if fs.RemoteFragments != nil {
+		clone.RemoteFragments = make([]*RemoteFragmentRef, len(fs.RemoteFragments))
+		for i, ref := range fs.RemoteFragments {
+			refCopy := *ref
+			clone.RemoteFragments[i] = &refCopy
+		}
+	}
+
+	if fs.Fragments != nil {
+		clone.Fragments = make([]*FragmentRef, len(fs.Fragments))
+		for i, ref := range fs.Fragments {
+			refCopy := *ref
+			clone.Fragments[i] = &refCopy
+		}
+	}
+
+	if fs.Examples != nil {
+		clone.Examples = make([]*SubmissionExample, len(fs.Examples))
+		for i, example := range fs.Examples {
+			exampleCopy := *example
+			exampleCopy.Data = cloneMap(example.Data)
+			exampleCopy.ErrorFields = append([]string(nil), example.ErrorFields...)
+			clone.Examples[i] = &exampleCopy
+		}
+	}
+
+	if fs.Parent != nil {
+		parent := *fs.Parent
+		if fs.Parent.Fields != nil {
+			parent.Fields = make([]*ParentFieldBinding, len(fs.Parent.Fields))
+			for i, binding := range fs.Parent.Fields {
+				bindingCopy := *binding
+				parent.Fields[i] = &bindingCopy
+			}
+		}
+		clone.Parent = &parent
+	}
+
+	if fs.Output != nil {
+		output := *fs.Output
+		if fs.Output.Rules != nil {
+			output.Rules = make([]*OutputRule, len(fs.Output.Rules))
+			for i, rule := range fs.Output.Rules {
+				ruleCopy := *rule
+				ruleCopy.Value = cloneValue(rule.Value)
+				output.Rules[i] = &ruleCopy
+			}
+		}
+		clone.Output = &output
+	}
+
+	if fs.Duplicates != nil {
+		clone.Duplicates = make([]*DuplicateRule, len(fs.Duplicates))
+		for i, rule := range fs.Duplicates {
+			ruleCopy := *rule
+			ruleCopy.Fields = append([]string(nil), rule.Fields...)
+			clone.Duplicates[i] = &ruleCopy
+		}
+	}
+
+	if fs.Unique != nil {
+		clone.Unique = make([]*UniqueConstraint, len(fs.Unique))
+		for i, constraint := range fs.Unique {
+			constraintCopy := *constraint
+			constraintCopy.Fields = append([]string(nil), constraint.Fields...)
+			clone.Unique[i] = &constraintCopy
+		}
+	}
+
+	if fs.Confirmation != nil {
+		confirmation := *fs.Confirmation
+		clone.Confirmation = &confirmation
+	}
+	if fs.Autosave != nil {
+		autosave := *fs.Autosave
+		clone.Autosave = &autosave
+	}
+
+	if fs.Environments != nil {
+		clone.Environments = make(map[string]*SchemaOverlay, len(fs.Environments))
+		for name, overlay := range fs.Environments {
+			clone.Environments[name] = overlay.Clone()
+		}
+	}
+
+	if fs.Translations != nil {
+		clone.Translations = make(map[string]map[string]string, len(fs.Translations))
+		for locale, bundle := range fs.Translations {
+			clone.Translations[locale] = make(map[string]string, len(bundle))
+			for key, text := range bundle {
+				clone.Translations[locale][key] = text
+			}
+		}
+	}
+
+	if fs.functions != nil {
+		clone.functions = make(map[string]DynamicFunction, len(fs.functions))
+		for name, fn := range fs.functions {
+			clone.functions[name] = fn
+		}
+	}
+
+	clone.validator = NewValidator(&clone)
+	return &clone
+}
+
+// Clone returns a deep copy of the field and its nested fields
+func (f *Field) Clone() *Field {
+	if f == nil {
+		return nil
+	}
+
+	clone := *f
+	clone.RequiredIf = f.RequiredIf.Clone()
+	clone.Visible = f.Visible.Clone()
+	clone.Enabled = f.Enabled.Clone()
+	clone.DefaultValue = cloneValue(f.DefaultValue)
+	clone.Properties = cloneMap(f.Properties)
+	clone.Options = f.Options.Clone()
+	clone.Help = f.Help.Clone()
+	clone.Consent = f.Consent.Clone()
+	clone.Signature = f.Signature.Clone()
+	clone.Phone = f.Phone.Clone()
+	clone.Currency = f.Currency.Clone()
+	clone.DateRange = f.DateRange.Clone()
+	clone.Nested = cloneFields(f.Nested)
+	clone.Annotations = f.Annotations.Clone()
+	if f.Print != nil {
+		hints := *f.Print
+		clone.Print = &hints
+	}
+	clone.ReadRoles = append([]string(nil), f.ReadRoles...)
+	clone.WriteRoles = append([]string(nil), f.WriteRoles...)
+
+	if f.DefaultWhen != nil {
+		clone.DefaultWhen = make([]*DefaultWhen, len(f.DefaultWhen))
+		for i, defaultWhen := range f.DefaultWhen {
+			clone.DefaultWhen[i] = &DefaultWhen{
+				Condition: defaultWhen.Condition.Clone(),
+				Value:     cloneValue(defaultWhen.Value),
+			}
+		}
+	}
+
+	if f.ValidationRules != nil {
+		clone.ValidationRules = make([]*ValidationRule, len(f.ValidationRules))
+		for i, rule := range f.ValidationRules {
+			clone.ValidationRules[i] = &ValidationRule{
+				Type:       rule.Type,
+				Message:    rule.Message,
+				Parameters: cloneValue(rule.Parameters),
+			}
+		}
+	}
+
+	return &clone
+}
+
+// Clone returns a deep copy of the condition tree
+func (c *Condition) Clone() *Condition {
+	if c == nil {
+		return nil
+	}
+
+	clone := *c
+	clone.Value = cloneValue(c.Value)
+	if c.Conditions != nil {
+		clone.Conditions = make([]*Condition, len(c.Conditions))
+		for i, sub := range c.Conditions {
+			clone.Conditions[i] = sub.Clone()
+		}
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the options configuration
+func (oc *OptionsConfig) Clone() *OptionsConfig {
+	if oc == nil {
+		return nil
+	}
+
+	clone := *oc
+	clone.Static = cloneOptions(oc.Static)
+
+	if oc.DynamicSource != nil {
+		source := *oc.DynamicSource
+		source.Parameters = cloneMap(oc.DynamicSource.Parameters)
+		if oc.DynamicSource.Headers != nil {
+			source.Headers = make(map[string]string, len(oc.DynamicSource.Headers))
+			for k, v := range oc.DynamicSource.Headers {
+				source.Headers[k] = v
+			}
+		}
+		if oc.DynamicSource.RefreshOn != nil {
+			source.RefreshOn = append([]string{}, oc.DynamicSource.RefreshOn...)
+		}
+		source.Mock = cloneOptions(oc.DynamicSource.Mock)
+		source.Fallback = cloneOptions(oc.DynamicSource.Fallback)
+		if oc.DynamicSource.CachePolicy != nil {
+			policy := *oc.DynamicSource.CachePolicy
+			source.CachePolicy = &policy
+		}
+		clone.DynamicSource = &source
+	}
+
+	if oc.Dependency != nil {
+		dependency := *oc.Dependency
+		if oc.Dependency.ValueMap != nil {
+			dependency.ValueMap = make(map[string][]*Option, len(oc.Dependency.ValueMap))
+			for k, v := range oc.Dependency.ValueMap {
+				dependency.ValueMap[k] = cloneOptions(v)
+			}
+		}
+		clone.Dependency = &dependency
+	}
+
+	return &clone
+}
+
+// cloneFields deep copies a slice of fields
+func cloneFields(fields []*Field) []*Field {
+	if fields == nil {
+		return nil
+	}
+	clones := make([]*Field, len(fields))
+	for i, field := range fields {
+		clones[i] = field.Clone()
+	}
+	return clones
+}
+
+// cloneOptions deep copies a slice of options
+func cloneOptions(options []*Option) []*Option {
+	if options == nil {
+		return nil
+	}
+	clones := make([]*Option, len(options))
+	for i, option := range options {
+		optionCopy := *option
+		optionCopy.Value = cloneValue(option.Value)
+		optionCopy.Condition = option.Condition.Clone()
+		clones[i] = &optionCopy
+	}
+	return clones
+}
+
+// cloneMap deep copies a generic map
+func cloneMap(original map[string]interface{}) map[string]interface{} {
+	if original == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(original))
+	for k, v := range original {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+// cloneValue deep copies maps and slices produced by JSON decoding; other values are shared
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return cloneMap(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case *Condition:
+		return v.Clone()
+	default:
+		return v
+	}
+}
@@ -0,0 +1,327 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Kinds of changes between two schemas
+const (
+	SchemaChangeAdded   = "added"
+	SchemaChangeRemoved = "removed"
+	SchemaChangeChanged = "changed"
+)
+
+// SchemaDiff is the structured difference between two versions of a schema
+type SchemaDiff struct {
+	FormID      string            `json:"formId"`
+	FromVersion string            `json:"fromVersion,omitempty"`
+	ToVersion   string            `json:"toVersion,omitempty"`
+	Form        []*PropertyChange `json:"form"`   // Changes to the properties of the form itself
+	Fields      []*FieldChange    `json:"fields"` // Fields added, removed, or changed
+}
+
+// FieldChange is a field added, removed, or changed between two schemas
+type FieldChange struct {
+	FieldID string            `json:"fieldId"` // Path of the field
+	Kind    string            `json:"kind"`
+	Type    FieldType         `json:"type"`              // Type of the field, as of the newer schema unless removed
+	Label   string            `json:"label,omitempty"`   // Label of the field, as of the newer schema unless removed
+	Changes []*PropertyChange `json:"changes,omitempty"` // Changes to the properties of a changed field
+}
+
+// PropertyChange is a change to a property of a form or field. Validation rules are named
+// "validation.<type>" and static options "options.<value>".
+type PropertyChange struct {
+	Property string      `json:"property"`
+	Kind     string      `json:"kind"`
+	Old      interface{} `json:"old,omitempty"`
+	New      interface{} `json:"new,omitempty"`
+}
+
+// IsEmpty reports whether the schemas are the same
+func (sd *SchemaDiff) IsEmpty() bool {
+	return len(sd.Form) == 0 && len(sd.Fields) == 0
+}
+
+// DiffSchemas compares two versions of a schema. Fields are matched by their path, so a field
+// moved to another group is reported as removed and added; fields merely reordered are not
+// reported.
+func DiffSchemas(old, new *FormSchema) *SchemaDiff {
+	diff := &SchemaDiff{
+		FormID:      new.ID,
+		FromVersion: old.Version,
+		ToVersion:   new.Version,
+		Form:        diffProperties("", schemaProperties(old), schemaProperties(new)),
+		Fields:      []*FieldChange{},
+	}
+
+	oldFields, oldPaths := diffFieldPaths(old.Fields)
+	newFields, newPaths := diffFieldPaths(new.Fields)
+	for _, path := range newPaths {
+		field := newFields[path]
+		previous, existed := oldFields[path]
+		if !existed {
+			diff.Fields = append(diff.Fields, &FieldChange{FieldID: path, Kind: SchemaChangeAdded, Type: field.Type, Label: field.Label})
+			continue
+		}
+
+		changes := diffProperties("", fieldProperties(previous), fieldProperties(field))
+		changes = append(changes, diffValidationRules(previous.ValidationRules, field.ValidationRules)...)
+		changes = append(changes, diffStaticOptions(previous.Options, field.Options)...)
+		if len(changes) > 0 {
+			diff.Fields = append(diff.Fields, &FieldChange{FieldID: path, Kind: SchemaChangeChanged, Type: field.Type, Label: field.Label, Changes: changes})
+		}
+	}
+	for _, path := range oldPaths {
+		if _, exists := newFields[path]; !exists {
+			field := oldFields[path]
+			diff.Fields = append(diff.Fields, &FieldChange{FieldID: path, Kind: SchemaChangeRemoved, Type: field.Type, Label: field.Label})
+		}
+	}
+	return diff
+}
+
+// Diff compares the schema with a newer version of it, see DiffSchemas
+func (fs *FormSchema) Diff(newer *FormSchema) *SchemaDiff {
+	return DiffSchemas(fs, newer)
+}
+
+// diffFieldPaths indexes fields and their nested fields by path, and lists the paths in order
+func diffFieldPaths(fields []*Field) (map[string]*Field, []string) {
+	byPath := map[string]*Field{}
+	paths := []string{}
+	var collect func(fields []*Field, container string)
+	collect = func(fields []*Field, container string) {
+		for _, field := range fields {
+			path := joinPath(container, field.ID)
+			if _, exists := byPath[path]; !exists {
+				paths = append(paths, path)
+			}
+			byPath[path] = field
+			collect(field.Nested, path)
+		}
+	}
+	collect(fields, "")
+	return byPath, paths
+}
+
+// schemaProperties returns the properties of a schema as they are serialized, without its
+// fields and the ID and version the diff is labeled with
+func schemaProperties(schema *FormSchema) map[string]interface{} {
+	properties := jsonProperties(schema)
+	delete(properties, "id")
+	delete(properties, "version")
+	delete(properties, "fields")
+	return properties
+}
+
+// fieldProperties returns the properties of a field as they are serialized, without those
+// compared separately: its nested fields, validation rules, and static options. The order of
+// fields is left out too, as it changes whenever a field is inserted before them.
+func fieldProperties(field *Field) map[string]interface{} {
+	properties := jsonProperties(field)
+	delete(properties, "id")
+	delete(properties, "nested")
+	delete(properties, "order")
+	delete(properties, "validationRules")
+	if options, ok := properties["options"].(map[string]interface{}); ok {
+		delete(options, "static")
+	}
+	return properties
+}
+
+// jsonProperties serializes a value to a map, so that values are compared as they are stored
+func jsonProperties(value interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	if data, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(data, &properties)
+	}
+	return properties
+}
+
+// diffProperties compares serialized properties, looking into objects such as options so that
+// changes name the property that changed, e.g. "options.dynamicSource"
+func diffProperties(prefix string, old, new map[string]interface{}) []*PropertyChange {
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	changes := []*PropertyChange{}
+	for _, name := range sorted {
+		property := joinPath(prefix, name)
+		previous, existed := old[name]
+		current, exists := new[name]
+		switch {
+		case !existed:
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeAdded, New: current})
+		case !exists:
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeRemoved, Old: previous})
+		case !reflect.DeepEqual(previous, current):
+			oldObject, oldIsObject := previous.(map[string]interface{})
+			newObject, newIsObject := current.(map[string]interface{})
+			if oldIsObject && newIsObject && name == "options" {
+				changes = append(changes, diffProperties(property, oldObject, newObject)...)
+			} else {
+				changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeChanged, Old: previous, New: current})
+			}
+		}
+	}
+	return changes
+}
+
+// diffValidationRules compares validation rules by type
+func diffValidationRules(old, new []*ValidationRule) []*PropertyChange {
+	byType := func(rules []*ValidationRule) (map[string]*ValidationRule, []string) {
+		indexed := map[string]*ValidationRule{}
+		types := []string{}
+		for _, rule := range rules {
+			if _, exists := indexed[string(rule.Type)]; !exists {
+				types = append(types, string(rule.Type))
+			}
+			indexed[string(rule.Type)] = rule
+		}
+		return indexed, types
+	}
+	oldRules, oldTypes := byType(old)
+	newRules, newTypes := byType(new)
+
+	changes := []*PropertyChange{}
+	for _, ruleType := range newTypes {
+		property := "validation." + ruleType
+		rule := jsonProperties(newRules[ruleType])
+		previous, existed := oldRules[ruleType]
+		switch {
+		case !existed:
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeAdded, New: rule})
+		case !reflect.DeepEqual(jsonProperties(previous), rule):
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeChanged, Old: jsonProperties(previous), New: rule})
+		}
+	}
+	for _, ruleType := range oldTypes {
+		if _, exists := newRules[ruleType]; !exists {
+			changes = append(changes, &PropertyChange{Property: "validation." + ruleType, Kind: SchemaChangeRemoved, Old: jsonProperties(oldRules[ruleType])})
+		}
+	}
+	return changes
+}
+
+// diffStaticOptions compares static options by value
+func diffStaticOptions(old, new *OptionsConfig) []*PropertyChange {
+	index := func(config *OptionsConfig) (map[string]*Option, []string) {
+		indexed := map[string]*Option{}
+		values := []string{}
+		if config == nil {
+			return indexed, values
+		}
+		for _, option := range config.Static {
+			value := fmt.Sprintf("%v", option.Value)
+			if _, exists := indexed[value]; !exists {
+				values = append(values, value)
+			}
+			indexed[value] = option
+		}
+		return indexed, values
+	}
+	oldOptions, oldValues := index(old)
+	newOptions, newValues := index(new)
+
+	changes := []*PropertyChange{}
+	for _, value := range newValues {
+		property := "options." + value
+		option := jsonProperties(newOptions[value])
+		previous, existed := oldOptions[value]
+		switch {
+		case !existed:
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeAdded, New: option})
+		case !reflect.DeepEqual(jsonProperties(previous), option):
+			changes = append(changes, &PropertyChange{Property: property, Kind: SchemaChangeChanged, Old: jsonProperties(previous), New: option})
+		}
+	}
+	for _, value := range oldValues {
+		if _, exists := newOptions[value]; !exists {
+			changes = append(changes, &PropertyChange{Property: "options." + value, Kind: SchemaChangeRemoved, Old: jsonProperties(oldOptions[value])})
+		}
+	}
+	return changes
+}
+
+// Changelog describes the changes as a Markdown list, one line per change
+func (sd *SchemaDiff) Changelog() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s", sd.FormID)
+	if sd.FromVersion != "" || sd.ToVersion != "" {
+		fmt.Fprintf(&b, " %s → %s", orDash(sd.FromVersion), orDash(sd.ToVersion))
+	}
+	b.WriteString("\n\n")
+	if sd.IsEmpty() {
+		b.WriteString("No changes.\n")
+		return b.String()
+	}
+
+	for _, change := range sd.Form {
+		fmt.Fprintf(&b, "- Form %s\n", describePropertyChange(change))
+	}
+	for _, field := range sd.Fields {
+		switch field.Kind {
+		case SchemaChangeAdded:
+			fmt.Fprintf(&b, "- Added %s field %s%s\n", field.Type, field.FieldID, quotedLabel(field.Label))
+		case SchemaChangeRemoved:
+			fmt.Fprintf(&b, "- Removed %s field %s%s\n", field.Type, field.FieldID, quotedLabel(field.Label))
+		default:
+			for _, change := range field.Changes {
+				fmt.Fprintf(&b, "- Field %s: %s\n", field.FieldID, describePropertyChange(change))
+			}
+		}
+	}
+	return b.String()
+}
+
+// describePropertyChange describes a change to a property, e.g. `required changed from false to true`
+func describePropertyChange(change *PropertyChange) string {
+	switch change.Kind {
+	case SchemaChangeAdded:
+		return fmt.Sprintf("%s added: %s", change.Property, changelogValue(change.New))
+	case SchemaChangeRemoved:
+		return fmt.Sprintf("%s removed (was %s)", change.Property, changelogValue(change.Old))
+	default:
+		return fmt.Sprintf("%s changed from %s to %s", change.Property, changelogValue(change.Old), changelogValue(change.New))
+	}
+}
+
+// changelogValue formats a value for the changelog as JSON
+func changelogValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// quotedLabel formats a label following a field path in the changelog
+func quotedLabel(label string) string {
+	if label == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%q)", label)
+}
+
+// orDash returns s, or a dash when it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
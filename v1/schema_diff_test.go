@@ -0,0 +1,66 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	build := func(version, title string, changed bool) *FormSchema {
+		form := NewForm("signup", title).Version(version)
+		form.TextField("name", "Name")
+		email := form.EmailField("email", "Email").ValidateEmail("Enter a valid email")
+		plan := form.SelectField("plan", "Plan")
+		address := form.GroupField("address", "Address")
+		address.TextField("street", "Street")
+		if changed {
+			email.Required(true).ValidateMaxLength(120, "Too long")
+			plan.WithStaticOptions([]*Option{{Value: "free", Label: "Free"}, {Value: "pro", Label: "Professional"}})
+			address.TextField("city", "City")
+			form.TextareaField("notes", "Notes")
+		} else {
+			plan.WithStaticOptions([]*Option{{Value: "free", Label: "Free"}, {Value: "pro", Label: "Pro"}, {Value: "team", Label: "Team"}})
+			form.TextField("fax", "Fax")
+		}
+		return form.Build()
+	}
+
+	old := build("1.0.0", "Sign up", false)
+	diff := DiffSchemas(old, build("1.1.0", "Create an account", true))
+	assert.Equal(t, "1.0.0", diff.FromVersion)
+	assert.Equal(t, "1.1.0", diff.ToVersion)
+	require.Len(t, diff.Form, 1)
+	assert.Equal(t, &PropertyChange{Property: "title", Kind: SchemaChangeChanged, Old: "Sign up", New: "Create an account"}, diff.Form[0])
+
+	fields := map[string]*FieldChange{}
+	for _, field := range diff.Fields {
+		fields[field.FieldID] = field
+	}
+	require.Len(t, fields, 5)
+	assert.Equal(t, SchemaChangeAdded, fields["address.city"].Kind)
+	assert.Equal(t, SchemaChangeAdded, fields["notes"].Kind)
+	assert.Equal(t, FieldTypeTextarea, fields["notes"].Type)
+	assert.Equal(t, SchemaChangeRemoved, fields["fax"].Kind)
+
+	properties := func(field *FieldChange) map[string]string {
+		kinds := map[string]string{}
+		for _, change := range field.Changes {
+			kinds[change.Property] = change.Kind
+		}
+		return kinds
+	}
+	assert.Equal(t, map[string]string{"required": SchemaChangeChanged, "validation.maxLength": SchemaChangeAdded}, properties(fields["email"]))
+	assert.Equal(t, map[string]string{"options.pro": SchemaChangeChanged, "options.team": SchemaChangeRemoved}, properties(fields["plan"]))
+
+	changelog := diff.Changelog()
+	assert.Contains(t, changelog, "## signup 1.0.0 → 1.1.0")
+	assert.Contains(t, changelog, `- Form title changed from "Sign up" to "Create an account"`)
+	assert.Contains(t, changelog, `- Added textarea field notes ("Notes")`)
+	assert.Contains(t, changelog, `- Removed text field fax ("Fax")`)
+	assert.Contains(t, changelog, "- Field email: required changed from false to true")
+
+	assert.True(t, old.Diff(build("1.0.0", "Sign up", false)).IsEmpty())
+	assert.Contains(t, old.Diff(old).Changelog(), "No changes.")
+}
@@ -0,0 +1,138 @@
+package smartform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SubmissionExample is an example submission stored with a schema together with the
+// validation outcome it is expected to produce. Examples act as executable regression
+// tests for the form's logic and are checked when the schema is registered.
+type SubmissionExample struct {
+	Name        string                 `json:"name"`
+	Data        map[string]interface{} `json:"data"`
+	Valid       bool                   `json:"valid"`
+	ErrorFields []string               `json:"errorFields,omitempty"` // Fields expected to fail when Valid is false
+}
+
+// ExampleFailure describes an example whose expectation no longer holds
+type ExampleFailure struct {
+	Example *SubmissionExample `json:"example"`
+	Result  *ValidationResult  `json:"result"`
+	Reason  string             `json:"reason"`
+}
+
+// ExampleError is returned when one or more schema examples fail
+type ExampleError struct {
+	SchemaID string
+	Failures []*ExampleFailure
+}
+
+// Error implements the error interface
+func (e *ExampleError) Error() string {
+	reasons := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		reasons[i] = fmt.Sprintf("%s: %s", failure.Example.Name, failure.Reason)
+	}
+	return fmt.Sprintf("schema %s failed %d example(s): %s", e.SchemaID, len(e.Failures), strings.Join(reasons, "; "))
+}
+
+// AddExample attaches an example submission to the schema
+func (fs *FormSchema) AddExample(example *SubmissionExample) *FormSchema {
+	fs.Examples = append(fs.Examples, example)
+	return fs
+}
+
+// CheckExamples validates every example submission and returns an *ExampleError
+// describing the examples whose expectations do not hold
+func (fs *FormSchema) CheckExamples() error {
+	failures := []*ExampleFailure{}
+
+	for _, example := range fs.Examples {
+		data := example.Data
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+
+		result := fs.Validate(data)
+		if reason := checkExampleResult(example, result); reason != "" {
+			failures = append(failures, &ExampleFailure{
+				Example: example,
+				Result:  result,
+				Reason:  reason,
+			})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &ExampleError{SchemaID: fs.ID, Failures: failures}
+	}
+	return nil
+}
+
+// checkExampleResult compares a validation result with an example's expectation
+func checkExampleResult(example *SubmissionExample, result *ValidationResult) string {
+	if example.Valid {
+		if !result.Valid {
+			return fmt.Sprintf("expected valid submission, got errors on %s", strings.Join(errorFieldIDs(result), ", "))
+		}
+		return ""
+	}
+
+	if result.Valid {
+		return "expected invalid submission, but it passed validation"
+	}
+
+	failed := make(map[string]bool)
+	for _, id := range errorFieldIDs(result) {
+		failed[id] = true
+	}
+
+	missing := []string{}
+	for _, id := range example.ErrorFields {
+		if !failed[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("expected errors on %s", strings.Join(missing, ", "))
+	}
+
+	return ""
+}
+
+// errorFieldIDs returns the sorted, distinct field IDs of a validation result's errors
+func errorFieldIDs(result *ValidationResult) []string {
+	seen := make(map[string]bool)
+	ids := []string{}
+	for _, err := range result.Errors {
+		if !seen[err.FieldID] {
+			seen[err.FieldID] = true
+			ids = append(ids, err.FieldID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ValidExample adds an example submission that must pass validation
+func (fb *FormBuilder) ValidExample(name string, data map[string]interface{}) *FormBuilder {
+	fb.schema.AddExample(&SubmissionExample{
+		Name:  name,
+		Data:  data,
+		Valid: true,
+	})
+	return fb
+}
+
+// InvalidExample adds an example submission that must fail validation on the given fields
+func (fb *FormBuilder) InvalidExample(name string, data map[string]interface{}, errorFields ...string) *FormBuilder {
+	fb.schema.AddExample(&SubmissionExample{
+		Name:        name,
+		Data:        data,
+		Valid:       false,
+		ErrorFields: errorFields,
+	})
+	return fb
+}
@@ -0,0 +1,28 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormSchema_CheckExamples(t *testing.T) {
+	builder := NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		ValidExample("complete", map[string]interface{}{"email": "jane@example.com"}).
+		InvalidExample("missing email", map[string]interface{}{}, "email")
+
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(builder.Build()))
+
+	// An expectation that no longer holds fails registration
+	builder.ValidExample("empty", map[string]interface{}{})
+	err := handler.RegisterSchema(builder.Build())
+	require.Error(t, err)
+
+	exampleErr, ok := err.(*ExampleError)
+	require.True(t, ok)
+	require.Len(t, exampleErr.Failures, 1)
+	assert.Equal(t, "empty", exampleErr.Failures[0].Example.Name)
+}
@@ -0,0 +1,29 @@
+package smartform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// FingerprintHeader carries the fingerprint of the schema a response was rendered from
+const FingerprintHeader = "X-Smartform-Fingerprint"
+
+// Fingerprint returns a stable content hash of the schema. Two schemas with the same
+// serialized content always have the same fingerprint, so clients can cache a schema
+// and only refetch it when the fingerprint changes.
+func (fs *FormSchema) Fingerprint() (string, error) {
+	// encoding/json emits struct fields in declaration order and sorts map keys,
+	// which makes the serialized form canonical
+	data, err := json.Marshal(fs)
+	if err != nil {
+		return "", err
+	}
+	return fingerprintBytes(data), nil
+}
+
+// fingerprintBytes hashes arbitrary content into a fingerprint
+func fingerprintBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256-" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,146 @@
+package smartform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaOverlay patches a schema for one deployment environment (e.g. dev, staging, prod)
+// so the same schema file can be promoted without editing embedded URLs
+type SchemaOverlay struct {
+	Environment string                    `json:"environment"`
+	Endpoints   map[string]string         `json:"endpoints,omitempty"`   // URL prefix → replacement, applied to every endpoint
+	Connections map[string]string         `json:"connections,omitempty"` // Connection name → replacement
+	Flags       map[string]bool           `json:"flags,omitempty"`       // Feature flags merged into the "flags" property
+	Options     map[string]*OptionsConfig `json:"options,omitempty"`     // Field ID → replacement option source
+}
+
+// ApplyEnvironment returns the schema patched with the overlay of the given environment,
+// or the schema itself when it has no overlay for that environment
+func (fs *FormSchema) ApplyEnvironment(environment string) (*FormSchema, error) {
+	overlay, ok := fs.Environments[environment]
+	if environment == "" || !ok {
+		return fs, nil
+	}
+	return fs.ApplyOverlay(overlay)
+}
+
+// ApplyOverlay returns a copy of the schema patched with an environment overlay
+func (fs *FormSchema) ApplyOverlay(overlay *SchemaOverlay) (*FormSchema, error) {
+	applied := fs.Clone()
+	if overlay == nil {
+		return applied, nil
+	}
+
+	for fieldID, options := range overlay.Options {
+		field := applied.FindFieldByID(fieldID)
+		if field == nil {
+			return nil, fmt.Errorf("overlay %s: field %s not found", overlay.Environment, fieldID)
+		}
+		field.Options = options.Clone()
+	}
+
+	prefixes := make([]string, 0, len(overlay.Endpoints))
+	for prefix := range overlay.Endpoints {
+		prefixes = append(prefixes, prefix)
+	}
+	// Longest prefix first, so that the most specific rewrite wins
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	rewriteEndpoint := func(endpoint string) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(endpoint, prefix) {
+				return overlay.Endpoints[prefix] + endpoint[len(prefix):]
+			}
+		}
+		return endpoint
+	}
+
+	rewriteProperties := func(properties map[string]interface{}) {
+		if endpoint, ok := properties["endpoint"].(string); ok {
+			properties["endpoint"] = rewriteEndpoint(endpoint)
+		}
+		if connection, ok := properties["connection"].(string); ok {
+			if replacement, ok := overlay.Connections[connection]; ok {
+				properties["connection"] = replacement
+			}
+		}
+	}
+
+	var patch func(fields []*Field)
+	patch = func(fields []*Field) {
+		for _, field := range fields {
+			rewriteProperties(field.Properties)
+			if field.Options != nil && field.Options.DynamicSource != nil {
+				field.Options.DynamicSource.Endpoint = rewriteEndpoint(field.Options.DynamicSource.Endpoint)
+			}
+			patch(field.Nested)
+		}
+	}
+	patch(applied.Fields)
+	rewriteProperties(applied.Properties)
+
+	for _, ref := range applied.RemoteFragments {
+		ref.URL = rewriteEndpoint(ref.URL)
+	}
+
+	if len(overlay.Flags) > 0 {
+		if applied.Properties == nil {
+			applied.Properties = make(map[string]interface{})
+		}
+		flags, _ := applied.Properties["flags"].(map[string]interface{})
+		if flags == nil {
+			flags = make(map[string]interface{})
+		}
+		for name, enabled := range overlay.Flags {
+			flags[name] = enabled
+		}
+		applied.Properties["flags"] = flags
+	}
+
+	return applied, nil
+}
+
+// Clone returns a deep copy of the overlay
+func (so *SchemaOverlay) Clone() *SchemaOverlay {
+	if so == nil {
+		return nil
+	}
+
+	clone := *so
+	if so.Endpoints != nil {
+		clone.Endpoints = make(map[string]string, len(so.Endpoints))
+		for prefix, replacement := range so.Endpoints {
+			clone.Endpoints[prefix] = replacement
+		}
+	}
+	if so.Connections != nil {
+		clone.Connections = make(map[string]string, len(so.Connections))
+		for name, replacement := range so.Connections {
+			clone.Connections[name] = replacement
+		}
+	}
+	if so.Flags != nil {
+		clone.Flags = make(map[string]bool, len(so.Flags))
+		for name, enabled := range so.Flags {
+			clone.Flags[name] = enabled
+		}
+	}
+	if so.Options != nil {
+		clone.Options = make(map[string]*OptionsConfig, len(so.Options))
+		for fieldID, options := range so.Options {
+			clone.Options[fieldID] = options.Clone()
+		}
+	}
+	return &clone
+}
+
+// EnvironmentOverlay adds an overlay applied when the schema is loaded in the overlay's environment
+func (fb *FormBuilder) EnvironmentOverlay(overlay *SchemaOverlay) *FormBuilder {
+	if fb.schema.Environments == nil {
+		fb.schema.Environments = make(map[string]*SchemaOverlay)
+	}
+	fb.schema.Environments[overlay.Environment] = overlay
+	return fb
+}
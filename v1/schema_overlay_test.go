@@ -0,0 +1,62 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvironmentOverlay(t *testing.T) {
+	schema, err := FormSchemaFromJSONForEnvironment(`{
+		"id": "order",
+		"title": "Order",
+		"fields": [
+			{
+				"id": "product",
+				"type": "select",
+				"label": "Product",
+				"options": {
+					"type": "dynamic",
+					"dynamicSource": {"type": "api", "endpoint": "https://api.example.com/v1/products"}
+				}
+			},
+			{
+				"id": "warehouse",
+				"type": "select",
+				"label": "Warehouse",
+				"properties": {"connection": "inventory-prod"}
+			}
+		],
+		"environments": {
+			"staging": {
+				"endpoints": {"https://api.example.com": "https://staging.example.com"},
+				"connections": {"inventory-prod": "inventory-staging"},
+				"flags": {"betaCheckout": true},
+				"options": {
+					"warehouse": {"type": "static", "static": [{"value": "test", "label": "Test"}]}
+				}
+			}
+		}
+	}`, "staging")
+	require.NoError(t, err)
+
+	product := schema.FindFieldByID("product")
+	assert.Equal(t, "https://staging.example.com/v1/products", product.Options.DynamicSource.Endpoint)
+
+	warehouse := schema.FindFieldByID("warehouse")
+	assert.Equal(t, "inventory-staging", warehouse.Properties["connection"])
+	require.NotNil(t, warehouse.Options)
+	assert.Equal(t, "test", warehouse.Options.Static[0].Value)
+
+	assert.Equal(t, map[string]interface{}{"betaCheckout": true}, schema.Properties["flags"])
+	assert.Equal(t, "staging", schema.Environments["staging"].Environment)
+}
+
+func TestApplyEnvironmentWithoutOverlay(t *testing.T) {
+	schema := NewForm("plain", "Plain").Build()
+
+	applied, err := schema.ApplyEnvironment("prod")
+	require.NoError(t, err)
+	assert.Same(t, schema, applied)
+}
@@ -0,0 +1,180 @@
+package smartform
+
+import (
+	"strings"
+)
+
+// Query parameters used to request a sparse fieldset
+const (
+	FieldsQueryParam  = "fields"
+	ExcludeQueryParam = "exclude"
+)
+
+// SelectFields returns a copy of the schema containing only part of its fields.
+//
+// When include is non-empty only the listed fields are kept, together with every
+// field they depend on through conditions, option dependencies, or refresh triggers.
+// Selecting a section or group keeps all of its nested fields; selecting a nested
+// field keeps its parents with only the selected children. Fields listed in exclude
+// are always removed, even if another selected field depends on them.
+func (fs *FormSchema) SelectFields(include, exclude []string) *FormSchema {
+	projection := fs.Clone()
+
+	if len(include) > 0 {
+		selected := fs.resolveFieldDependencies(include)
+		projection.Fields = selectFields(projection.Fields, selected)
+	}
+
+	if len(exclude) > 0 {
+		excluded := make(map[string]bool, len(exclude))
+		for _, id := range exclude {
+			excluded[id] = true
+		}
+		projection.Fields = excludeFields(projection.Fields, excluded)
+	}
+
+	return projection
+}
+
+// ParseFieldList splits a comma-separated list of field IDs as used by ?fields= and ?exclude=
+func ParseFieldList(value string) []string {
+	ids := []string{}
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// resolveFieldDependencies expands a list of field IDs with the fields they depend on
+func (fs *FormSchema) resolveFieldDependencies(ids []string) map[string]bool {
+	index := make(map[string]*Field)
+	indexFields(fs.Fields, index)
+
+	selected := make(map[string]bool)
+	queue := append([]string{}, ids...)
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if selected[id] {
+			continue
+		}
+		selected[id] = true
+
+		field, ok := index[id]
+		if !ok {
+			continue
+		}
+
+		// A selected container brings along its children and their dependencies
+		for _, nestedID := range collectFieldIDs(field.Nested) {
+			queue = append(queue, nestedID)
+		}
+
+		for _, dependency := range fieldDependencies(field) {
+			if _, exists := index[dependency]; exists {
+				queue = append(queue, dependency)
+			}
+		}
+	}
+
+	return selected
+}
+
+// indexFields maps every field ID, including nested ones, to its field
+func indexFields(fields []*Field, index map[string]*Field) {
+	for _, field := range fields {
+		index[field.ID] = field
+		indexFields(field.Nested, index)
+	}
+}
+
+// fieldDependencies returns the IDs of the fields a field refers to
+func fieldDependencies(field *Field) []string {
+	dependencies := []string{}
+
+	addReference := func(ref string) {
+		if ref == "" {
+			return
+		}
+		dependencies = append(dependencies, ref)
+		// Dotted paths refer to a field nested inside its top-level parent
+		if root := strings.SplitN(ref, ".", 2)[0]; root != ref {
+			dependencies = append(dependencies, root)
+		}
+	}
+
+	var addCondition func(condition *Condition)
+	addCondition = func(condition *Condition) {
+		if condition == nil {
+			return
+		}
+		addReference(condition.Field)
+		if condition.Expression != "" {
+			dependencies = append(dependencies, identifierPattern.FindAllString(condition.Expression, -1)...)
+		}
+		for _, sub := range condition.Conditions {
+			addCondition(sub)
+		}
+	}
+
+	addCondition(field.RequiredIf)
+	addCondition(field.Visible)
+	addCondition(field.Enabled)
+	for _, defaultWhen := range field.DefaultWhen {
+		addCondition(defaultWhen.Condition)
+	}
+	for _, ref := range templateReferences(field.ComputedValue) {
+		addReference(ref)
+	}
+
+	if field.Options != nil {
+		if field.Options.Dependency != nil {
+			addReference(field.Options.Dependency.Field)
+		}
+		if field.Options.DynamicSource != nil {
+			for _, ref := range field.Options.DynamicSource.RefreshOn {
+				addReference(ref)
+			}
+		}
+	}
+
+	return dependencies
+}
+
+// selectFields keeps selected fields and the parents of selected nested fields
+func selectFields(fields []*Field, selected map[string]bool) []*Field {
+	result := []*Field{}
+	for _, field := range fields {
+		if selected[field.ID] {
+			result = append(result, field)
+			continue
+		}
+
+		if len(field.Nested) > 0 {
+			if nested := selectFields(field.Nested, selected); len(nested) > 0 {
+				field.Nested = nested
+				result = append(result, field)
+			}
+		}
+	}
+	return result
+}
+
+// excludeFields removes excluded fields at any depth
+func excludeFields(fields []*Field, excluded map[string]bool) []*Field {
+	result := []*Field{}
+	for _, field := range fields {
+		if excluded[field.ID] {
+			continue
+		}
+		if field.Nested != nil {
+			field.Nested = excludeFields(field.Nested, excluded)
+		}
+		result = append(result, field)
+	}
+	return result
+}
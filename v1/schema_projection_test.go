@@ -0,0 +1,37 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormSchema_SelectFields(t *testing.T) {
+	schema := NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("country", FieldTypeSelect, "Country").Build()).
+		AddField(NewFieldBuilder("state", FieldTypeText, "State").
+			VisibleWhenEquals("country", "US").Build()).
+		AddField(NewFieldBuilder("bio", FieldTypeTextarea, "Bio").Build()).
+		AddField(&Field{
+			ID:   "account",
+			Type: FieldTypeGroup,
+			Nested: []*Field{
+				{ID: "username", Type: FieldTypeText},
+				{ID: "password", Type: FieldTypePassword},
+			},
+		}).
+		Build()
+
+	projection := schema.SelectFields([]string{"state", "password"}, nil)
+	ids := collectFieldIDs(projection.Fields)
+	assert.ElementsMatch(t, []string{"country", "state", "account", "password"}, ids)
+	assert.Len(t, schema.Fields, 4, "original schema must not be modified")
+
+	projection = schema.SelectFields(ParseFieldList("account"), ParseFieldList("password, bio"))
+	require.Len(t, projection.Fields, 1)
+	assert.Equal(t, []string{"account", "username"}, collectFieldIDs(projection.Fields))
+
+	projection = schema.SelectFields(nil, []string{"bio"})
+	assert.Equal(t, []string{"country", "state", "account", "username", "password"}, collectFieldIDs(projection.Fields))
+}
@@ -0,0 +1,352 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSchemaPollInterval is how often WatchSchemas reloads schemas by default
+const DefaultSchemaPollInterval = 30 * time.Second
+
+var (
+	// ErrNoSchemaSource is returned when reloading schemas without a schema source
+	ErrNoSchemaSource = errors.New("no schema source is configured")
+	// ErrSchemaSourceFailed is returned when the schemas of a source cannot be loaded
+	ErrSchemaSourceFailed = errors.New("schema source failed")
+	// ErrInvalidSchema is returned when a schema of a source fails the checks of registration
+	ErrInvalidSchema = errors.New("invalid schema")
+)
+
+// SchemaSource supplies form schemas at runtime, so that forms change without restarting the
+// service: a directory of JSON files, a database table, or a remote URL
+type SchemaSource interface {
+	// LoadSchemas returns every schema of the source, in any number of versions per form
+	LoadSchemas(ctx context.Context) ([]*FormSchema, error)
+}
+
+// SchemaSourceFunc adapts a function to the SchemaSource interface
+type SchemaSourceFunc func(ctx context.Context) ([]*FormSchema, error)
+
+// LoadSchemas calls the function
+func (f SchemaSourceFunc) LoadSchemas(ctx context.Context) ([]*FormSchema, error) {
+	return f(ctx)
+}
+
+// SchemaNotifier is implemented by schema sources that know when their schemas change, e.g.
+// from database notifications, so that WatchSchemas reloads them without waiting for a poll
+type SchemaNotifier interface {
+	SchemaChanges() <-chan struct{}
+}
+
+// SchemaReload is the outcome of reloading the schemas of a source. Form versions are
+// named "<form ID>@<version>".
+type SchemaReload struct {
+	Loaded    []string `json:"loaded"`    // Form versions added or changed
+	Unloaded  []string `json:"unloaded"`  // Form versions removed from the source
+	Unchanged int      `json:"unchanged"` // Number of form versions left as they were
+}
+
+// SetSchemaSource sets the source ReloadSchemas and WatchSchemas load schemas from
+func (ah *APIHandler) SetSchemaSource(source SchemaSource) {
+	ah.reloadLock.Lock()
+	defer ah.reloadLock.Unlock()
+	ah.schemaSource = source
+}
+
+// ReloadSchemas loads the schemas of the schema source and swaps them in at once. Every
+// schema is checked as RegisterSchema checks it first, so a source with an invalid schema
+// leaves every form as it was. Form versions the source no longer has are unloaded; schemas
+// registered otherwise are left alone, unless the source replaces them.
+func (ah *APIHandler) ReloadSchemas(ctx context.Context) (*SchemaReload, error) {
+	ah.reloadLock.Lock()
+	defer ah.reloadLock.Unlock()
+
+	start := time.Now()
+	reload, err := ah.reloadSchemas(ctx)
+	event := &AuditEvent{Type: AuditSchemasReloaded, Error: auditError(err)}
+	if reload != nil {
+		event.Details = map[string]interface{}{"loaded": reload.Loaded, "unloaded": reload.Unloaded}
+	}
+	ah.audit(nil, start, event)
+	return reload, err
+}
+
+// reloadSchemas reloads the schemas of the schema source. The caller holds the reload lock.
+func (ah *APIHandler) reloadSchemas(ctx context.Context) (*SchemaReload, error) {
+	if ah.schemaSource == nil {
+		return nil, ErrNoSchemaSource
+	}
+	schemas, err := ah.schemaSource.LoadSchemas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSchemaSourceFailed, err)
+	}
+
+	prepared := make([]*preparedSchema, 0, len(schemas))
+	loaded := make(map[string]map[string]bool)
+	var errs []error
+	for _, schema := range schemas {
+		if schema == nil {
+			continue
+		}
+		p, err := ah.prepareSchema(schema)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w %s: %w", ErrInvalidSchema, schema.ID, err))
+			continue
+		}
+		id, version := p.base.ID, p.base.Version
+		if loaded[id][version] {
+			errs = append(errs, fmt.Errorf("%w %s: version %s is loaded twice", ErrInvalidSchema, id, version))
+			continue
+		}
+		if loaded[id] == nil {
+			loaded[id] = make(map[string]bool)
+		}
+		loaded[id][version] = true
+		prepared = append(prepared, p)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	reload := &SchemaReload{Loaded: []string{}, Unloaded: []string{}}
+	for id, versions := range ah.sourcedSchemas {
+		for version := range versions {
+			if !loaded[id][version] {
+				ah.unloadSchema(id, version)
+				reload.Unloaded = append(reload.Unloaded, id+"@"+version)
+			}
+		}
+	}
+	for _, p := range prepared {
+		id, version := p.base.ID, p.base.Version
+		if existing := ah.versions[id][version]; existing != nil && ah.sourcedSchemas[id][version] {
+			if fingerprint, _ := existing.Fingerprint(); fingerprint == p.fingerprint {
+				reload.Unchanged++
+				continue
+			}
+		}
+		ah.storeSchema(p)
+		reload.Loaded = append(reload.Loaded, id+"@"+version)
+	}
+	ah.sourcedSchemas = loaded
+
+	sort.Strings(reload.Loaded)
+	sort.Strings(reload.Unloaded)
+	return reload, nil
+}
+
+// unloadSchema removes a version of a form, making the newest remaining version the current
+// one. The caller holds the schemas lock.
+func (ah *APIHandler) unloadSchema(id, version string) {
+	delete(ah.versions[id], version)
+	if current := ah.schemas[id]; current == nil || current.Version != version {
+		return
+	}
+
+	var newest *FormSchema
+	for v, schema := range ah.versions[id] {
+		if newest == nil || compareSchemaVersions(v, newest.Version) > 0 {
+			newest = schema
+		}
+	}
+	if newest == nil {
+		delete(ah.versions, id)
+		delete(ah.schemas, id)
+		delete(ah.baseSchemas, id)
+		delete(ah.fingerprints, id)
+		return
+	}
+	// Older versions keep the fragments merged when they were registered
+	ah.schemas[id] = newest
+	delete(ah.baseSchemas, id)
+	ah.fingerprints[id], _ = newest.Fingerprint()
+}
+
+// WatchSchemas reloads the schemas of the schema source every interval, and whenever a source
+// implementing SchemaNotifier reports a change, until ctx is done. A zero interval polls every
+// DefaultSchemaPollInterval, a negative one only reloads on notifications. Failed reloads keep
+// the schemas served and are recorded in the audit log.
+func (ah *APIHandler) WatchSchemas(ctx context.Context, interval time.Duration) error {
+	if _, err := ah.ReloadSchemas(ctx); errors.Is(err, ErrNoSchemaSource) {
+		return err
+	}
+
+	var tick <-chan time.Time
+	if interval == 0 {
+		interval = DefaultSchemaPollInterval
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var changes <-chan struct{}
+	ah.reloadLock.Lock()
+	if notifier, ok := ah.schemaSource.(SchemaNotifier); ok {
+		changes = notifier.SchemaChanges()
+	}
+	ah.reloadLock.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick:
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+		}
+		_, _ = ah.ReloadSchemas(ctx)
+	}
+}
+
+// handleSchemaReload handles requests to reload the schemas of the schema source, for
+// deployment pipelines that publish forms
+func (ah *APIHandler) handleSchemaReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	reload, err := ah.ReloadSchemas(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reload)
+}
+
+// DirectorySchemaSource loads a schema from every JSON file of a directory
+type DirectorySchemaSource struct {
+	dir string
+}
+
+// NewDirectorySchemaSource creates a source loading the *.json files of a directory
+func NewDirectorySchemaSource(dir string) *DirectorySchemaSource {
+	return &DirectorySchemaSource{dir: dir}
+}
+
+// LoadSchemas reads every schema of the directory
+func (ds *DirectorySchemaSource) LoadSchemas(ctx context.Context) ([]*FormSchema, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema directory %s: %w", ds.dir, err)
+	}
+
+	var schemas []*FormSchema
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(ds.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema file %s: %w", path, err)
+		}
+		schema, err := FormSchemaFromJSON(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("error importing schema file %s: %w", path, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// URLSchemaSource loads schemas from a URL serving a JSON array of schemas. The response is
+// only imported again when its ETag changes.
+type URLSchemaSource struct {
+	url     string
+	client  *http.Client
+	etag    string
+	schemas []*FormSchema
+	lock    sync.Mutex
+}
+
+// NewURLSchemaSource creates a source loading the schemas served at a URL
+func NewURLSchemaSource(url string) *URLSchemaSource {
+	return &URLSchemaSource{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// SetHTTPClient replaces the client used to fetch schemas, e.g. with one that authenticates
+func (us *URLSchemaSource) SetHTTPClient(client *http.Client) *URLSchemaSource {
+	us.client = client
+	return us
+}
+
+// LoadSchemas fetches the schemas served at the URL
+func (us *URLSchemaSource) LoadSchemas(ctx context.Context) ([]*FormSchema, error) {
+	us.lock.Lock()
+	defer us.lock.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, us.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if us.etag != "" {
+		req.Header.Set("If-None-Match", us.etag)
+	}
+	resp, err := us.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching schemas %s: %w", us.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && us.etag != "" {
+		return us.schemas, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("schemas %s returned status %d", us.url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schemas %s: %w", us.url, err)
+	}
+
+	var documents []json.RawMessage
+	if err := json.Unmarshal(body, &documents); err != nil {
+		return nil, fmt.Errorf("schemas %s are not a JSON array: %w", us.url, err)
+	}
+	schemas := make([]*FormSchema, 0, len(documents))
+	for i, document := range documents {
+		schema, err := FormSchemaFromJSON(string(document))
+		if err != nil {
+			return nil, fmt.Errorf("error importing schema %d of %s: %w", i, us.url, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	us.etag = resp.Header.Get("ETag")
+	us.schemas = schemas
+	return schemas, nil
+}
@@ -0,0 +1,251 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaJSON returns the JSON of a form with a text field per field ID
+func schemaJSON(id, version string, fieldIDs ...string) string {
+	fields := make([]map[string]interface{}, len(fieldIDs))
+	for i, fieldID := range fieldIDs {
+		fields[i] = map[string]interface{}{"id": fieldID, "type": "text", "label": fieldID}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"id": id, "title": id, "version": version, "fields": fields})
+	return string(data)
+}
+
+func TestReloadSchemasFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").Build()))
+	handler.SetSchemaSource(NewDirectorySchemaSource(dir))
+
+	write("signup.json", schemaJSON("signup", "1.0.0", "email"))
+	write("README.md", "not a schema")
+	reload, err := handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &SchemaReload{Loaded: []string{"signup@1.0.0"}, Unloaded: []string{}}, reload)
+
+	reload, err = handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, reload.Loaded)
+	assert.Equal(t, 1, reload.Unchanged)
+
+	write("signup.json", schemaJSON("signup", "1.0.0", "email", "name"))
+	write("signup-2.json", schemaJSON("signup", "2.0.0", "email", "name", "phone"))
+	reload, err = handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"signup@1.0.0", "signup@2.0.0"}, reload.Loaded)
+	current, ok := handler.GetSchema("signup")
+	require.True(t, ok)
+	assert.Equal(t, "2.0.0", current.Version)
+
+	// An invalid schema leaves every form as it was
+	write("broken.json", schemaJSON("broken", "one"))
+	write("signup-2.json", schemaJSON("signup", "2.0.0", "email"))
+	_, err = handler.ReloadSchemas(context.Background())
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidSchema))
+	assert.Contains(t, err.Error(), "broken")
+	current, _ = handler.GetSchema("signup")
+	assert.Len(t, current.Fields, 3)
+
+	// Removed versions are unloaded, and the newest remaining one becomes current
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.json")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "signup-2.json")))
+	reload, err = handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"signup@2.0.0"}, reload.Unloaded)
+	current, _ = handler.GetSchema("signup")
+	assert.Equal(t, "1.0.0", current.Version)
+	assert.Equal(t, []string{"1.0.0"}, handler.SchemaVersions("signup"))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "signup.json")))
+	reload, err = handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"signup@1.0.0"}, reload.Unloaded)
+	_, ok = handler.GetSchema("signup")
+	assert.False(t, ok)
+	_, ok = handler.GetSchema("contact")
+	assert.True(t, ok, "schemas registered in code are left alone")
+}
+
+func TestReloadSchemasConcurrently(t *testing.T) {
+	var version atomic.Int32
+	handler := NewAPIHandler()
+	handler.SetSchemaSource(SchemaSourceFunc(func(ctx context.Context) ([]*FormSchema, error) {
+		n := version.Add(1)
+		return []*FormSchema{
+			NewForm("a", "A").Version(fmt.Sprintf("1.0.%d", n)).Build(),
+			NewForm("b", "B").Version(fmt.Sprintf("1.0.%d", n)).Build(),
+		}, nil
+	}))
+	_, err := handler.ReloadSchemas(context.Background())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_, okA := handler.GetSchema("a")
+				_, okB := handler.GetSchema("b")
+				if !okA || !okB {
+					t.Error("a form was missing during a reload")
+					return
+				}
+			}
+		}()
+	}
+	for range 20 {
+		_, err := handler.ReloadSchemas(context.Background())
+		require.NoError(t, err)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSchemaReloadEndpoint(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "ops", r.Header.Get("X-Staff") == "yes"
+	})
+	mux := handler.Handler()
+	reload := func(method string, staff bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/admin/reload", nil)
+		if staff {
+			req.Header.Set("X-Staff", "yes")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, reload(http.MethodPost, false).Code)
+	assert.Equal(t, http.StatusNotImplemented, reload(http.MethodPost, true).Code)
+
+	var failing atomic.Bool
+	handler.SetSchemaSource(SchemaSourceFunc(func(ctx context.Context) ([]*FormSchema, error) {
+		if failing.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return []*FormSchema{NewForm("survey", "Survey").Build()}, nil
+	}))
+	rec := reload(http.MethodPost, true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var result SchemaReload
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, []string{"survey@" + DefaultSchemaVersion}, result.Loaded)
+	assert.Equal(t, http.StatusMethodNotAllowed, reload(http.MethodGet, true).Code)
+
+	failing.Store(true)
+	rec = reload(http.MethodPost, true)
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	_, ok := handler.GetSchema("survey")
+	assert.True(t, ok, "forms are still served when the source fails")
+}
+
+func TestURLSchemaSource(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetches.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("[" + schemaJSON("signup", "1.0.0", "email") + "," + schemaJSON("survey", "1.2.0") + "]"))
+	}))
+	defer server.Close()
+
+	source := NewURLSchemaSource(server.URL)
+	schemas, err := source.LoadSchemas(context.Background())
+	require.NoError(t, err)
+	require.Len(t, schemas, 2)
+	assert.Equal(t, "signup", schemas[0].ID)
+	assert.Equal(t, "1.2.0", schemas[1].Version)
+
+	again, err := source.LoadSchemas(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, schemas, again)
+	assert.Equal(t, int32(1), fetches.Load(), "unchanged schemas are not fetched again")
+
+	_, err = NewURLSchemaSource(server.URL + "/missing").LoadSchemas(context.Background())
+	assert.Error(t, err)
+}
+
+// notifyingSource is a schema source reporting its changes
+type notifyingSource struct {
+	lock    sync.Mutex
+	schemas []*FormSchema
+	changes chan struct{}
+}
+
+func (ns *notifyingSource) LoadSchemas(ctx context.Context) ([]*FormSchema, error) {
+	ns.lock.Lock()
+	defer ns.lock.Unlock()
+	return ns.schemas, nil
+}
+
+func (ns *notifyingSource) SchemaChanges() <-chan struct{} {
+	return ns.changes
+}
+
+func TestWatchSchemas(t *testing.T) {
+	source := &notifyingSource{
+		schemas: []*FormSchema{NewForm("signup", "Signup").Build()},
+		changes: make(chan struct{}),
+	}
+	handler := NewAPIHandler()
+	assert.True(t, errors.Is(handler.WatchSchemas(context.Background(), time.Hour), ErrNoSchemaSource))
+	handler.SetSchemaSource(source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watched := make(chan error)
+	go func() { watched <- handler.WatchSchemas(ctx, -1) }()
+	require.Eventually(t, func() bool {
+		_, ok := handler.GetSchema("signup")
+		return ok
+	}, time.Second, time.Millisecond)
+
+	source.lock.Lock()
+	source.schemas = []*FormSchema{NewForm("survey", "Survey").Build()}
+	source.lock.Unlock()
+	source.changes <- struct{}{}
+	require.Eventually(t, func() bool {
+		_, signup := handler.GetSchema("signup")
+		_, survey := handler.GetSchema("survey")
+		return !signup && survey
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.True(t, errors.Is(<-watched, context.Canceled))
+}
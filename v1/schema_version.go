@@ -0,0 +1,221 @@
+package smartform
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSchemaVersion is given to schemas registered without a version
+const DefaultSchemaVersion = "1.0.0"
+
+// Request parameters naming the schema version a form was rendered from
+const (
+	VersionQueryParam = "version"
+	VersionDataKey    = "_version"
+)
+
+// SchemaMigration upgrades submitted data from one schema version to the next
+type SchemaMigration func(data map[string]interface{}) (map[string]interface{}, error)
+
+// schemaMigration is a migration registered between two versions of a form
+type schemaMigration struct {
+	from    string
+	to      string
+	migrate SchemaMigration
+}
+
+// parseSchemaVersion parses a version such as "2", "2.1", or "2.1.0" and returns its
+// components and how many of them were given
+func parseSchemaVersion(version string) ([3]int, int, error) {
+	var parts [3]int
+	trimmed := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	segments := strings.Split(trimmed, ".")
+	if trimmed == "" || len(segments) > 3 {
+		return parts, 0, fmt.Errorf("invalid schema version: %q", version)
+	}
+
+	for i, segment := range segments {
+		n, err := strconv.Atoi(segment)
+		if err != nil || n < 0 {
+			return parts, 0, fmt.Errorf("invalid schema version: %q", version)
+		}
+		parts[i] = n
+	}
+	return parts, len(segments), nil
+}
+
+// compareSchemaVersions returns -1, 0, or 1 when a is older than, equal to, or newer than b.
+// Unparsable versions sort first.
+func compareSchemaVersions(a, b string) int {
+	pa, _, errA := parseSchemaVersion(a)
+	pb, _, errB := parseSchemaVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// schemaVersionMatches reports whether a registered version satisfies a requested one.
+// A partial request such as "2" or "2.1" matches every version with that prefix.
+func schemaVersionMatches(requested, version string) bool {
+	want, n, err := parseSchemaVersion(requested)
+	if err != nil {
+		return false
+	}
+	have, _, err := parseSchemaVersion(version)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SchemaVersionFromRequest reads the schema version submitted data was entered against from
+// the version query parameter or from the reserved VersionDataKey entry of the form data,
+// which is removed from the data
+func SchemaVersionFromRequest(query url.Values, data map[string]interface{}) string {
+	version := query.Get(VersionQueryParam)
+	if raw, ok := data[VersionDataKey]; ok {
+		delete(data, VersionDataKey)
+		if s, ok := raw.(string); ok && version == "" {
+			version = s
+		}
+	}
+	return version
+}
+
+// GetSchemaVersion gets the newest registered version of a schema matching version, which may
+// be partial ("2" matches "2.3.1"). An empty version returns the current schema.
+func (ah *APIHandler) GetSchemaVersion(id, version string) (*FormSchema, bool) {
+	if version == "" {
+		return ah.GetSchema(id)
+	}
+
+	ah.schemasLock.RLock()
+	var match *FormSchema
+	for v, schema := range ah.versions[id] {
+		if schemaVersionMatches(version, v) && (match == nil || compareSchemaVersions(v, match.Version) > 0) {
+			match = schema
+		}
+	}
+	current := ah.schemas[id]
+	ah.schemasLock.RUnlock()
+
+	if match == nil {
+		return nil, false
+	}
+	// The current version is resolved through GetSchema so that its fragments stay fresh
+	if current != nil && match.Version == current.Version {
+		return ah.GetSchema(id)
+	}
+	return match, true
+}
+
+// SchemaVersions lists the registered versions of a schema, oldest first
+func (ah *APIHandler) SchemaVersions(id string) []string {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+
+	versions := make([]string, 0, len(ah.versions[id]))
+	for version := range ah.versions[id] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareSchemaVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// RegisterMigration registers a function that upgrades data submitted against version from
+// of a form to version to. Data from older versions is migrated step by step to the
+// current version before it is validated.
+func (ah *APIHandler) RegisterMigration(formID, from, to string, migration SchemaMigration) error {
+	if _, n, err := parseSchemaVersion(from); err != nil || n != 3 {
+		return fmt.Errorf("invalid migration source version: %q", from)
+	}
+	if _, n, err := parseSchemaVersion(to); err != nil || n != 3 {
+		return fmt.Errorf("invalid migration target version: %q", to)
+	}
+	if compareSchemaVersions(from, to) >= 0 {
+		return fmt.Errorf("migration must upgrade: %s is not older than %s", from, to)
+	}
+
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.migrations[formID] = append(ah.migrations[formID], &schemaMigration{from: from, to: to, migrate: migration})
+	return nil
+}
+
+// MigrateData upgrades data submitted against a version of a form to the target version by
+// chaining registered migrations, taking the largest step available each time
+func (ah *APIHandler) MigrateData(formID, from, to string, data map[string]interface{}) (map[string]interface{}, error) {
+	ah.schemasLock.RLock()
+	migrations := ah.migrations[formID]
+	ah.schemasLock.RUnlock()
+
+	version := from
+	for compareSchemaVersions(version, to) < 0 {
+		var step *schemaMigration
+		for _, migration := range migrations {
+			if compareSchemaVersions(migration.from, version) != 0 || compareSchemaVersions(migration.to, to) > 0 {
+				continue
+			}
+			if step == nil || compareSchemaVersions(migration.to, step.to) > 0 {
+				step = migration
+			}
+		}
+		if step == nil {
+			return nil, fmt.Errorf("no migration from version %s of form %s", version, formID)
+		}
+
+		migrated, err := step.migrate(data)
+		if err != nil {
+			return nil, fmt.Errorf("error migrating form %s from %s to %s: %w", formID, step.from, step.to, err)
+		}
+		data = migrated
+		version = step.to
+	}
+	return data, nil
+}
+
+// migrateSubmission upgrades submitted data to the version of schema when it was entered
+// against an older version
+func (ah *APIHandler) migrateSubmission(schema *FormSchema, query url.Values, data map[string]interface{}) (map[string]interface{}, error) {
+	version := SchemaVersionFromRequest(query, data)
+	if version == "" || schemaVersionMatches(version, schema.Version) {
+		return data, nil
+	}
+
+	// Resolve partial versions to a registered one
+	if registered, ok := ah.GetSchemaVersion(schema.ID, version); ok {
+		version = registered.Version
+	} else if _, n, err := parseSchemaVersion(version); err != nil || n != 3 {
+		return nil, fmt.Errorf("unknown version %s of form %s", version, schema.ID)
+	}
+
+	if compareSchemaVersions(version, schema.Version) > 0 {
+		return nil, fmt.Errorf("version %s of form %s is newer than %s", version, schema.ID, schema.Version)
+	}
+	return ah.MigrateData(schema.ID, version, schema.Version, data)
+}
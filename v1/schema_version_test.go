@@ -0,0 +1,102 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVersionedHandler(t *testing.T) (*APIHandler, *http.ServeMux) {
+	handler := NewAPIHandler()
+
+	// v1 had a single name field, v2 splits it
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		Version("1.0.0").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Required(true).Build()).
+		Build()))
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		Version("2.0.0").
+		AddField(NewFieldBuilder("firstName", FieldTypeText, "First name").Required(true).Build()).
+		AddField(NewFieldBuilder("lastName", FieldTypeText, "Last name").Required(true).Build()).
+		Build()))
+	require.NoError(t, handler.RegisterMigration("contact", "1.0.0", "2.0.0", func(data map[string]interface{}) (map[string]interface{}, error) {
+		first, last, _ := strings.Cut(data["name"].(string), " ")
+		return map[string]interface{}{"firstName": first, "lastName": last}, nil
+	}))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	return handler, mux
+}
+
+func TestSchemaVersionsServedConcurrently(t *testing.T) {
+	handler, mux := newVersionedHandler(t)
+
+	assert.Equal(t, []string{"1.0.0", "2.0.0"}, handler.SchemaVersions("contact"))
+
+	current, ok := handler.GetSchema("contact")
+	require.True(t, ok)
+	assert.Equal(t, "2.0.0", current.Version)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/contact?version=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var rendered map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	assert.Equal(t, "1.0.0", rendered["version"])
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/contact?version=3", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Registering an older version later does not replace the current one
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").Version("1.5.0").Build()))
+	current, _ = handler.GetSchema("contact")
+	assert.Equal(t, "2.0.0", current.Version)
+
+	assert.Error(t, handler.RegisterSchema(NewForm("bad", "Bad").Version("two").Build()))
+}
+
+func TestSubmissionMigratedFromOlderVersion(t *testing.T) {
+	_, mux := newVersionedHandler(t)
+
+	validate := func(path, body string) *ValidationResult {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var result ValidationResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return &result
+	}
+
+	assert.True(t, validate("/api/validate/contact?version=1.0.0", `{"name": "Ada Lovelace"}`).Valid)
+	assert.True(t, validate("/api/validate/contact", `{"_version": "1", "name": "Ada Lovelace"}`).Valid)
+	assert.False(t, validate("/api/validate/contact", `{"name": "Ada Lovelace"}`).Valid)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/contact?version=0.9.0", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMigrateDataChainsSteps(t *testing.T) {
+	handler := NewAPIHandler()
+	step := func(key string) SchemaMigration {
+		return func(data map[string]interface{}) (map[string]interface{}, error) {
+			data[key] = true
+			return data, nil
+		}
+	}
+	require.NoError(t, handler.RegisterMigration("f", "1.0.0", "1.1.0", step("a")))
+	require.NoError(t, handler.RegisterMigration("f", "1.1.0", "2.0.0", step("b")))
+	require.NoError(t, handler.RegisterMigration("f", "1.1.0", "3.0.0", step("skipped")))
+	assert.Error(t, handler.RegisterMigration("f", "2.0.0", "1.0.0", step("c")))
+
+	data, err := handler.MigrateData("f", "1.0.0", "2.0.0", map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": true, "b": true}, data)
+}
@@ -0,0 +1,339 @@
+// Package script executes smartform dynamic functions and transformers authored in JavaScript.
+//
+// A script's source must evaluate to a function. Function scripts are called as
+// fn(args, formState) and transformer scripts as fn(data, params):
+//
+//	(args, formState) => formState.items.map(i => ({ value: i.id, label: i.name }))
+//
+// Every call runs in a fresh goja runtime with eval and the Function constructor
+// removed, also where it is reachable as the constructor of any function, a bounded call
+// stack, and a wall-clock time limit.
+package script
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+// Language is the ScriptDefinition language handled by this package
+const Language = "javascript"
+
+// Options configures the script sandbox
+type Options struct {
+	Timeout      time.Duration          // Maximum wall-clock time per call (default: 500ms)
+	MaxCallStack int                    // Maximum call stack depth (default: 256)
+	Globals      map[string]interface{} // Additional values or Go functions exposed to scripts
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		Timeout:      500 * time.Millisecond,
+		MaxCallStack: 256,
+	}
+}
+
+// forbiddenGlobals are removed from every runtime to keep scripts within the sandbox
+var forbiddenGlobals = []string{"eval", "Function", "WebAssembly"}
+
+// functionKinds evaluate to a function of every kind. The constructor of each kind's prototype
+// compiles source like eval, and is reachable from any function literal, e.g.
+// (() => {}).constructor("...").
+var functionKinds = func() []*goja.Program {
+	var programs []*goja.Program
+	for _, source := range []string{"(function () {})", "(function* () {})", "(async function () {})", "(async function* () {})"} {
+		// Kinds the runtime does not support cannot be reached by scripts either
+		if program, err := goja.Compile("", source, true); err == nil {
+			programs = append(programs, program)
+		}
+	}
+	return programs
+}()
+
+// Engine compiles and runs JavaScript scripts
+type Engine struct {
+	options  Options
+	programs map[string]*goja.Program
+	owners   map[serviceScript]string // Schemas whose scripts are registered with a service
+	mutex    sync.RWMutex
+}
+
+// serviceScript identifies a script registered with a dynamic function service, which knows
+// functions and transformers by name alone
+type serviceScript struct {
+	service *smartform.DynamicFunctionService
+	kind    smartform.ScriptKind
+	name    string
+}
+
+// NewEngine creates a new script engine
+func NewEngine(options Options) *Engine {
+	defaults := DefaultOptions()
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.MaxCallStack <= 0 {
+		options.MaxCallStack = defaults.MaxCallStack
+	}
+
+	return &Engine{
+		options:  options,
+		programs: make(map[string]*goja.Program),
+		owners:   make(map[serviceScript]string),
+	}
+}
+
+// Compile compiles a script source and caches it under name
+func (e *Engine) Compile(name, source string) error {
+	program, err := compile(name, source)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.programs[name] = program
+	e.mutex.Unlock()
+	return nil
+}
+
+// compile compiles a script source
+func compile(name, source string) (*goja.Program, error) {
+	// Wrap in parentheses so both function declarations and arrow functions evaluate to a value
+	program, err := goja.Compile(name, "("+strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(source), ";"))+")", true)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling script %s: %w", name, err)
+	}
+	return program, nil
+}
+
+// Scripts returns the names of all compiled scripts
+func (e *Engine) Scripts() []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	names := make([]string, 0, len(e.programs))
+	for name := range e.programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call runs a compiled script with the given arguments
+func (e *Engine) Call(name string, args ...interface{}) (interface{}, error) {
+	e.mutex.RLock()
+	program, ok := e.programs[name]
+	e.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("script '%s' not found", name)
+	}
+	return e.run(name, program, args...)
+}
+
+// run runs a compiled program in a fresh runtime with the given arguments
+func (e *Engine) run(name string, program *goja.Program, args ...interface{}) (interface{}, error) {
+	vm, err := e.newRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(e.options.Timeout, func() {
+		vm.Interrupt(fmt.Sprintf("script %s exceeded the %s time limit", name, e.options.Timeout))
+	})
+	defer timer.Stop()
+
+	value, err := vm.RunProgram(program)
+	if err != nil {
+		return nil, translateError(name, err)
+	}
+
+	fn, ok := goja.AssertFunction(value)
+	if !ok {
+		return nil, fmt.Errorf("script %s does not evaluate to a function", name)
+	}
+
+	jsArgs := make([]goja.Value, len(args))
+	for i, arg := range args {
+		jsArgs[i] = vm.ToValue(arg)
+	}
+
+	result, err := fn(goja.Undefined(), jsArgs...)
+	if err != nil {
+		return nil, translateError(name, err)
+	}
+
+	return exportValue(result), nil
+}
+
+// Function returns a dynamic function that runs the named script
+func (e *Engine) Function(name string) smartform.DynamicFunction {
+	return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return e.Call(name, args, formState)
+	}
+}
+
+// Transformer returns a data transformer that runs the named script
+func (e *Engine) Transformer(name string) smartform.DataTransformer {
+	return func(data interface{}, params map[string]interface{}) (interface{}, error) {
+		return e.Call(name, data, params)
+	}
+}
+
+// RegisterSchemaScripts compiles all JavaScript scripts stored on a schema and registers them
+// with the schema and, when non-nil, with the dynamic function service. The functions
+// registered with the schema run its own scripts even when other schemas have scripts of the
+// same name; the scripts are cached as "<schema ID>/<name>". The service knows scripts by name
+// alone, so a script of the same kind and name registered with it by another schema is an error.
+func (e *Engine) RegisterSchemaScripts(schema *smartform.FormSchema, service *smartform.DynamicFunctionService) error {
+	if service != nil {
+		if err := e.claimServiceScripts(schema, service); err != nil {
+			return err
+		}
+	}
+
+	for _, definition := range schema.Scripts {
+		if definition.Language != "" && definition.Language != Language {
+			continue
+		}
+
+		name := schema.ID + "/" + definition.Name
+		program, err := compile(name, definition.Source)
+		if err != nil {
+			return err
+		}
+		e.mutex.Lock()
+		e.programs[name] = program
+		e.mutex.Unlock()
+
+		switch definition.Kind {
+		case smartform.ScriptKindTransformer:
+			if service != nil {
+				service.RegisterTransformer(definition.Name, func(data interface{}, params map[string]interface{}) (interface{}, error) {
+					return e.run(name, program, data, params)
+				})
+			}
+		case smartform.ScriptKindFunction, "":
+			fn := func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+				return e.run(name, program, args, formState)
+			}
+			schema.RegisterFunction(definition.Name, fn)
+			if service != nil {
+				service.RegisterFunction(definition.Name, fn)
+			}
+		default:
+			return fmt.Errorf("script %s has unsupported kind '%s'", definition.Name, definition.Kind)
+		}
+	}
+	return nil
+}
+
+// claimServiceScripts records that the scripts of a schema are registered with a service,
+// failing without claiming any when another schema registered one of the same kind and name
+func (e *Engine) claimServiceScripts(schema *smartform.FormSchema, service *smartform.DynamicFunctionService) error {
+	claims := []serviceScript{}
+	for _, definition := range schema.Scripts {
+		if definition.Language != "" && definition.Language != Language {
+			continue
+		}
+		kind := definition.Kind
+		if kind == "" {
+			kind = smartform.ScriptKindFunction
+		}
+		claims = append(claims, serviceScript{service: service, kind: kind, name: definition.Name})
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, claim := range claims {
+		if owner, ok := e.owners[claim]; ok && owner != schema.ID {
+			return fmt.Errorf("%s script %s of schema %s is already registered by schema %s", claim.kind, claim.name, schema.ID, owner)
+		}
+	}
+	for _, claim := range claims {
+		e.owners[claim] = schema.ID
+	}
+	return nil
+}
+
+// newRuntime creates a sandboxed runtime with the restricted standard library
+func (e *Engine) newRuntime() (*goja.Runtime, error) {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(e.options.MaxCallStack)
+	vm.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+
+	blocked := vm.ToValue(func(goja.FunctionCall) goja.Value {
+		panic(vm.NewTypeError("code generation from strings is not allowed"))
+	})
+	for _, program := range functionKinds {
+		fn, err := vm.RunProgram(program)
+		if err != nil {
+			return nil, fmt.Errorf("error preparing sandbox: %w", err)
+		}
+		prototype := fn.ToObject(vm).Prototype()
+		if err := prototype.DefineDataProperty("constructor", blocked, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+			return nil, fmt.Errorf("error preparing sandbox: %w", err)
+		}
+	}
+
+	for _, name := range forbiddenGlobals {
+		if err := vm.GlobalObject().Delete(name); err != nil {
+			return nil, fmt.Errorf("error preparing sandbox: %w", err)
+		}
+	}
+
+	for name, value := range e.options.Globals {
+		if err := vm.Set(name, value); err != nil {
+			return nil, fmt.Errorf("error exposing global %s: %w", name, err)
+		}
+	}
+
+	return vm, nil
+}
+
+// translateError converts goja errors into plain Go errors
+func translateError(name string, err error) error {
+	switch e := err.(type) {
+	case *goja.InterruptedError:
+		return fmt.Errorf("%v", e.Value())
+	case *goja.Exception:
+		return fmt.Errorf("script %s failed: %s", name, e.Value().String())
+	default:
+		return fmt.Errorf("script %s failed: %w", name, err)
+	}
+}
+
+// exportValue converts a JS value into plain Go values (maps, slices, float64, string, bool)
+func exportValue(value goja.Value) interface{} {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	return normalize(value.Export())
+}
+
+// normalize makes exported values match what encoding/json would produce
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalize(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
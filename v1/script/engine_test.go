@@ -0,0 +1,115 @@
+package script
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+func TestEngine_SchemaScripts(t *testing.T) {
+	schema := smartform.NewForm("order", "Order").
+		FunctionScript("cities", `(args, formState) => formState.cities
+			.filter(c => c.state === args.state)
+			.map(c => ({ value: c.id, label: c.name }))`).
+		TransformerScript("double", `function (data, params) { return data * (params.factor || 2); }`).
+		Build()
+
+	service := smartform.NewDynamicFunctionService()
+	engine := NewEngine(Options{})
+	require.NoError(t, engine.RegisterSchemaScripts(schema, service))
+
+	formState := map[string]interface{}{
+		"cities": []interface{}{
+			map[string]interface{}{"id": "sf", "name": "San Francisco", "state": "CA"},
+			map[string]interface{}{"id": "ny", "name": "New York", "state": "NY"},
+		},
+	}
+
+	options, err := service.ExecuteFunctionForOptions("cities", map[string]interface{}{"state": "CA"}, formState)
+	require.NoError(t, err)
+	require.Len(t, options, 1)
+	assert.Equal(t, "sf", options[0].Value)
+	assert.Equal(t, "San Francisco", options[0].Label)
+
+	doubled, err := service.TransformData("double", 21, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), doubled)
+}
+
+func TestEngine_SameScriptNameInSchemas(t *testing.T) {
+	first := smartform.NewForm("first", "First").FunctionScript("answer", `() => 1`).Build()
+	second := smartform.NewForm("second", "Second").FunctionScript("answer", `() => 2`).Build()
+
+	engine := NewEngine(Options{})
+	require.NoError(t, engine.RegisterSchemaScripts(first, nil))
+	require.NoError(t, engine.RegisterSchemaScripts(second, nil))
+	assert.Equal(t, []string{"first/answer", "second/answer"}, engine.Scripts())
+
+	for schema, expected := range map[*smartform.FormSchema]float64{first: 1, second: 2} {
+		result, err := schema.ExecuteDynamicFunction("answer", nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, result, schema.ID)
+	}
+
+	// A service knows scripts by name alone, so a second schema may not replace one
+	service := smartform.NewDynamicFunctionService()
+	engine = NewEngine(Options{})
+	require.NoError(t, engine.RegisterSchemaScripts(first, service))
+	err := engine.RegisterSchemaScripts(second, service)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "function script answer of schema second is already registered by schema first")
+	result, err := service.ExecuteFunction("answer", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), result)
+
+	shout := smartform.NewForm("shout", "Shout").TransformerScript("format", `(data) => data + "!"`).Build()
+	whisper := smartform.NewForm("whisper", "Whisper").TransformerScript("format", `(data) => data + "..."`).Build()
+	require.NoError(t, engine.RegisterSchemaScripts(shout, service))
+	require.Error(t, engine.RegisterSchemaScripts(whisper, service))
+	transformed, err := service.TransformData("format", "hi", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hi!", transformed)
+
+	// Scripts may be registered again by their own schema, and with other services
+	require.NoError(t, engine.RegisterSchemaScripts(first, service))
+	require.NoError(t, engine.RegisterSchemaScripts(second, smartform.NewDynamicFunctionService()))
+}
+
+func TestEngine_Sandbox(t *testing.T) {
+	engine := NewEngine(Options{Timeout: 50 * time.Millisecond})
+
+	require.NoError(t, engine.Compile("loop", `() => { while (true) {} }`))
+	_, err := engine.Call("loop")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "time limit")
+
+	require.NoError(t, engine.Compile("evil", `() => eval("1 + 1")`))
+	_, err = engine.Call("evil")
+	assert.Error(t, err)
+
+	escapes := map[string]string{
+		"arrow":           `() => (() => {}).constructor("return 40 + 2")()`,
+		"function":        `() => (function () {}).constructor("return 40 + 2")()`,
+		"generator":       `() => (function* () {}).constructor("yield 40 + 2")().next().value`,
+		"async":           `() => (async function () {}).constructor("return 40 + 2")`,
+		"prototype chain": `() => Object.getPrototypeOf(() => {}).constructor("return 40 + 2")()`,
+	}
+	for name, source := range escapes {
+		require.NoError(t, engine.Compile(name, source))
+		result, err := engine.Call(name)
+		assert.Error(t, err, "%s escape returned %v", name, result)
+	}
+
+	require.NoError(t, engine.Compile("recurse", `function f(n) { return f(n + 1); }`))
+	_, err = engine.Call("recurse", 0)
+	assert.Error(t, err)
+
+	require.NoError(t, engine.Compile("throws", `() => { throw new Error("boom"); }`))
+	_, err = engine.Call("throws")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
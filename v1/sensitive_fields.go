@@ -0,0 +1,326 @@
+package smartform
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SensitiveMask replaces the values of sensitive fields in summaries, rendered forms, and
+// responses
+const SensitiveMask = "********"
+
+// encryptedValueKey marks an object holding an encrypted value, {"$encrypted": "..."}
+const encryptedValueKey = "$encrypted"
+
+// ErrDecryptionFailed is returned when an encrypted value cannot be decrypted, because it was
+// encrypted with another key or has been altered
+var ErrDecryptionFailed = errors.New("sensitive value could not be decrypted")
+
+// FieldEncryptor encrypts the values of sensitive fields before they are stored. Values are
+// passed as their JSON encoding.
+type FieldEncryptor interface {
+	Encrypt(ctx context.Context, plaintext []byte) (string, error)
+	Decrypt(ctx context.Context, ciphertext string) ([]byte, error)
+}
+
+// AESGCMEncryptor encrypts values with AES-GCM under a key held by the application
+type AESGCMEncryptor struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMEncryptor creates an encryptor with a 16, 24, or 32 byte key, for AES-128, AES-192,
+// or AES-256
+func NewAESGCMEncryptor(key []byte) (*AESGCMEncryptor, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMEncryptor{aead: aead}, nil
+}
+
+// Encrypt seals a value with a random nonce, returning them base64-encoded together
+func (e *AESGCMEncryptor) Encrypt(ctx context.Context, plaintext []byte) (string, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(e.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Decrypt opens a value sealed by Encrypt
+func (e *AESGCMEncryptor) Decrypt(ctx context.Context, ciphertext string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < e.aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	nonce := sealed[:e.aead.NonceSize()]
+	plaintext, err := e.aead.Open(nil, nonce, sealed[len(nonce):], nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+// KeyWrapper encrypts and decrypts data keys with a master key, usually one held by a key
+// management service that never releases it
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// EnvelopeEncryptor encrypts each value with AES-GCM under a new data key, which is stored
+// with the value wrapped by a KeyWrapper, such as one backed by a KMS
+type EnvelopeEncryptor struct {
+	wrapper KeyWrapper
+}
+
+// envelope is an encrypted value with its wrapped data key
+type envelope struct {
+	Key   []byte `json:"k"`
+	Value string `json:"v"`
+}
+
+// NewEnvelopeEncryptor creates an encryptor wrapping its data keys with wrapper
+func NewEnvelopeEncryptor(wrapper KeyWrapper) *EnvelopeEncryptor {
+	return &EnvelopeEncryptor{wrapper: wrapper}
+}
+
+// Encrypt encrypts a value under a new data key and wraps the key
+func (e *EnvelopeEncryptor) Encrypt(ctx context.Context, plaintext []byte) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	encryptor, err := NewAESGCMEncryptor(key)
+	if err != nil {
+		return "", err
+	}
+	value, err := encryptor.Encrypt(ctx, plaintext)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := e.wrapper.WrapKey(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("error wrapping data key: %w", err)
+	}
+	data, err := json.Marshal(&envelope{Key: wrapped, Value: value})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt unwraps the data key of a value and decrypts it
+func (e *EnvelopeEncryptor) Decrypt(ctx context.Context, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	var sealed envelope
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	key, err := e.wrapper.UnwrapKey(ctx, sealed.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %w", err)
+	}
+	encryptor, err := NewAESGCMEncryptor(key)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return encryptor.Decrypt(ctx, sealed.Value)
+}
+
+// Sensitive marks the field as holding a secret such as a national ID number or an API key.
+// Its values are encrypted with the handler's FieldEncryptor before they are stored, and masked
+// in summaries, rendered forms, and responses.
+func (fb *FieldBuilder) Sensitive(sensitive bool) *FieldBuilder {
+	fb.field.Sensitive = sensitive
+	return fb
+}
+
+// encryptedValue returns the ciphertext of an encrypted value
+func encryptedValue(value interface{}) (string, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) != 1 {
+		return "", false
+	}
+	ciphertext, ok := object[encryptedValueKey].(string)
+	return ciphertext, ok
+}
+
+// IsEncryptedValue reports whether a value is a sensitive value encrypted by EncryptSensitive
+func IsEncryptedValue(value interface{}) bool {
+	_, ok := encryptedValue(value)
+	return ok
+}
+
+// EncryptSensitive returns a copy of data with the values of sensitive fields encrypted, each
+// replaced by an object {"$encrypted": "<ciphertext>"}. Values encrypted already are kept.
+func (fs *FormSchema) EncryptSensitive(ctx context.Context, data map[string]interface{}, encryptor FieldEncryptor) (map[string]interface{}, error) {
+	return mapSensitive(fs.Fields, data, func(value interface{}) (interface{}, error) {
+		if IsEncryptedValue(value) {
+			return value, nil
+		}
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := encryptor.Encrypt(ctx, plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{encryptedValueKey: ciphertext}, nil
+	})
+}
+
+// DecryptSensitive returns a copy of data with the encrypted values of sensitive fields
+// decrypted
+func (fs *FormSchema) DecryptSensitive(ctx context.Context, data map[string]interface{}, encryptor FieldEncryptor) (map[string]interface{}, error) {
+	return mapSensitive(fs.Fields, data, func(value interface{}) (interface{}, error) {
+		ciphertext, ok := encryptedValue(value)
+		if !ok {
+			return value, nil
+		}
+		plaintext, err := encryptor.Decrypt(ctx, ciphertext)
+		if err != nil {
+			return nil, err
+		}
+		var decrypted interface{}
+		if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+			return nil, ErrDecryptionFailed
+		}
+		return decrypted, nil
+	})
+}
+
+// MaskSensitive returns a copy of data with the values of sensitive fields, encrypted or not,
+// replaced by SensitiveMask
+func (fs *FormSchema) MaskSensitive(data map[string]interface{}) map[string]interface{} {
+	masked, _ := mapSensitive(fs.Fields, data, func(value interface{}) (interface{}, error) {
+		return SensitiveMask, nil
+	})
+	return masked
+}
+
+// hasSensitiveFields reports whether any field of the schema is sensitive
+func (fs *FormSchema) hasSensitiveFields() bool {
+	var walk func(fields []*Field) bool
+	walk = func(fields []*Field) bool {
+		for _, field := range fields {
+			if field.Sensitive || walk(field.Nested) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(fs.Fields)
+}
+
+// mapSensitive returns a copy of one level of data with the non-empty values of sensitive
+// fields replaced by the result of fn, looking into groups and array items. Data without
+// sensitive values is returned as it is.
+func mapSensitive(fields []*Field, data map[string]interface{}, fn func(value interface{}) (interface{}, error)) (map[string]interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	mapped := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		mapped[key] = value
+	}
+
+	for _, field := range fields {
+		value, present := mapped[field.ID]
+		if !present || value == nil || value == "" {
+			continue
+		}
+		if field.Sensitive {
+			replaced, err := fn(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.ID, err)
+			}
+			mapped[field.ID] = replaced
+			continue
+		}
+
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			replaced, err := mapSensitive(field.Nested, nested, fn)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%w", field.ID, err)
+			}
+			mapped[field.ID] = replaced
+		case []interface{}:
+			items := make([]interface{}, len(nested))
+			for i, item := range nested {
+				items[i] = item
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					replaced, err := mapSensitive(field.Nested, itemMap, fn)
+					if err != nil {
+						return nil, fmt.Errorf("%s[%d].%w", field.ID, i, err)
+					}
+					items[i] = replaced
+				}
+			}
+			mapped[field.ID] = items
+		}
+	}
+	return mapped, nil
+}
+
+// SetFieldEncryptor sets the encryptor of the values of sensitive fields. Submissions are
+// encrypted once validated, before the persist and notify handlers of the pipeline run and the
+// submission is stored, and form sessions whenever they are saved. Without one, sensitive
+// values are stored as submitted, and only masked in responses.
+func (ah *APIHandler) SetFieldEncryptor(encryptor FieldEncryptor) {
+	ah.fieldEncryptor = encryptor
+}
+
+// encryptSensitive encrypts the sensitive values of data, if an encryptor is set
+func (ah *APIHandler) encryptSensitive(ctx context.Context, schema *FormSchema, data map[string]interface{}) (map[string]interface{}, error) {
+	if ah.fieldEncryptor == nil || !schema.hasSensitiveFields() {
+		return data, nil
+	}
+	encrypted, err := schema.EncryptSensitive(ctx, data, ah.fieldEncryptor)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting sensitive values: %w", err)
+	}
+	return encrypted, nil
+}
+
+// decryptSensitive decrypts the sensitive values of data, if an encryptor is set
+func (ah *APIHandler) decryptSensitive(ctx context.Context, schema *FormSchema, data map[string]interface{}) (map[string]interface{}, error) {
+	if ah.fieldEncryptor == nil || !schema.hasSensitiveFields() {
+		return data, nil
+	}
+	return schema.DecryptSensitive(ctx, data, ah.fieldEncryptor)
+}
+
+// decryptSubmissions returns copies of stored submissions with the sensitive values of their
+// data decrypted, if an encryptor is set, so they compare with submitted data
+func (ah *APIHandler) decryptSubmissions(ctx context.Context, schema *FormSchema, submissions []*Submission) ([]*Submission, error) {
+	if ah.fieldEncryptor == nil || !schema.hasSensitiveFields() {
+		return submissions, nil
+	}
+	decrypted := make([]*Submission, len(submissions))
+	for i, submission := range submissions {
+		data, err := ah.decryptSensitive(ctx, schema, submission.Data)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting submission %s: %w", submission.ID, err)
+		}
+		clone := *submission
+		clone.Data = data
+		decrypted[i] = &clone
+	}
+	return decrypted, nil
+}
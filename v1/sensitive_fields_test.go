@@ -0,0 +1,186 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeyWrapper wraps data keys with AES-GCM, standing in for a KMS
+type testKeyWrapper struct {
+	master *AESGCMEncryptor
+}
+
+func (w *testKeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	wrapped, err := w.master.Encrypt(ctx, key)
+	return []byte(wrapped), err
+}
+
+func (w *testKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return w.master.Decrypt(ctx, string(wrapped))
+}
+
+func newSensitiveForm() *FormSchema {
+	form := NewForm("applicant", "Applicant")
+	form.TextField("name", "Name").Required(true)
+	form.TextField("ssn", "SSN").Required(true).Sensitive(true)
+	form.GroupField("bank", "Bank").
+		AddField(NewFieldBuilder("iban", FieldTypeText, "IBAN").Sensitive(true).Build())
+	return form.Build()
+}
+
+func TestFieldEncryptors(t *testing.T) {
+	ctx := context.Background()
+	aesEncryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	master, err := NewAESGCMEncryptor(bytes.Repeat([]byte{2}, 32))
+	require.NoError(t, err)
+
+	for name, encryptor := range map[string]FieldEncryptor{
+		"aes-gcm":  aesEncryptor,
+		"envelope": NewEnvelopeEncryptor(&testKeyWrapper{master: master}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ciphertext, err := encryptor.Encrypt(ctx, []byte(`"123-45-6789"`))
+			require.NoError(t, err)
+			assert.NotContains(t, ciphertext, "123-45-6789")
+			plaintext, err := encryptor.Decrypt(ctx, ciphertext)
+			require.NoError(t, err)
+			assert.Equal(t, `"123-45-6789"`, string(plaintext))
+
+			_, err = encryptor.Decrypt(ctx, ciphertext[:len(ciphertext)-4]+"AAAA")
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = NewAESGCMEncryptor([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestEncryptSensitive(t *testing.T) {
+	ctx := context.Background()
+	encryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	schema := newSensitiveForm()
+	data := map[string]interface{}{
+		"name": "Ada",
+		"ssn":  "123-45-6789",
+		"bank": map[string]interface{}{"iban": "DE89370400440532013000"},
+	}
+
+	encrypted, err := schema.EncryptSensitive(ctx, data, encryptor)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", encrypted["name"])
+	assert.True(t, IsEncryptedValue(encrypted["ssn"]))
+	assert.True(t, IsEncryptedValue(encrypted["bank"].(map[string]interface{})["iban"]))
+	assert.Equal(t, "123-45-6789", data["ssn"], "the data itself is left alone")
+
+	again, err := schema.EncryptSensitive(ctx, encrypted, encryptor)
+	require.NoError(t, err)
+	assert.Equal(t, encrypted, again, "encrypted values are not encrypted twice")
+
+	decrypted, err := schema.DecryptSensitive(ctx, encrypted, encryptor)
+	require.NoError(t, err)
+	assert.Equal(t, data, decrypted)
+
+	other, err := NewAESGCMEncryptor(bytes.Repeat([]byte{3}, 32))
+	require.NoError(t, err)
+	_, err = schema.DecryptSensitive(ctx, encrypted, other)
+	assert.True(t, errors.Is(err, ErrDecryptionFailed))
+
+	masked := schema.MaskSensitive(encrypted)
+	assert.Equal(t, SensitiveMask, masked["ssn"])
+	assert.Equal(t, map[string]interface{}{"iban": SensitiveMask}, masked["bank"])
+
+	summary, err := schema.Summary(data, nil)
+	require.NoError(t, err)
+	entries := map[string]*SummaryEntry{}
+	for _, entry := range summary.Sections[0].Entries {
+		entries[entry.FieldID] = entry
+	}
+	assert.Equal(t, "Ada", entries["name"].Display)
+	assert.Equal(t, SensitiveMask, entries["ssn"].Display)
+	assert.Equal(t, SensitiveMask, entries["bank.iban"].Value)
+}
+
+func TestSensitiveFieldsEndpoints(t *testing.T) {
+	encryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	store := NewMemorySubmissionStore()
+	handler := NewAPIHandler()
+	handler.SetFieldEncryptor(encryptor)
+	handler.SetSubmissionStore(store)
+	require.NoError(t, handler.RegisterSchema(newSensitiveForm()))
+	mux := handler.Handler()
+
+	request := func(method, path string, body interface{}) map[string]interface{} {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(data)))
+		require.Less(t, rec.Code, 300, rec.Body.String())
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	// Submissions are stored encrypted and echoed masked
+	response := request(http.MethodPost, "/api/submit/applicant", map[string]interface{}{"name": "Ada", "ssn": "123-45-6789"})
+	assert.Equal(t, SensitiveMask, response["data"].(map[string]interface{})["ssn"])
+	stored, err := store.Get(response["submissionId"].(string))
+	require.NoError(t, err)
+	assert.True(t, IsEncryptedValue(stored.Data["ssn"]))
+
+	// Sessions are saved encrypted, shown masked, and validated decrypted
+	session := request(http.MethodPost, "/api/sessions/applicant", map[string]interface{}{"name": "Ada", "ssn": "123-45-6789"})
+	sessionID := session["id"].(string)
+	assert.Equal(t, SensitiveMask, session["data"].(map[string]interface{})["ssn"])
+	saved, err := handler.formSessions.Get(sessionID)
+	require.NoError(t, err)
+	assert.True(t, IsEncryptedValue(saved.Data["ssn"]))
+
+	session = request(http.MethodPatch, "/api/sessions/applicant/"+sessionID, map[string]interface{}{"name": "Ada L.", "ssn": SensitiveMask})
+	assert.Equal(t, "Ada L.", session["data"].(map[string]interface{})["name"])
+	result := request(http.MethodPost, "/api/sessions/applicant/"+sessionID+"/validate", nil)
+	assert.Equal(t, true, result["valid"], "the masked value keeps the saved one")
+}
+
+func TestSensitiveFieldsWithDuplicatesAndSignatures(t *testing.T) {
+	encryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	store := NewMemorySubmissionStore()
+	handler := NewAPIHandler()
+	handler.SetFieldEncryptor(encryptor)
+	handler.SetSubmissionStore(store)
+	handler.SetSignatureKey([]byte("seal-key"))
+	form := NewForm("applicant", "Applicant").DetectDuplicates(DuplicateActionBlock, "ssn")
+	form.TextField("name", "Name")
+	form.TextField("ssn", "SSN").Sensitive(true)
+	form.SignatureField("signature", "Signature").Seal(true)
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := handler.Handler()
+
+	submit := func(name string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]interface{}{"name": name, "ssn": "123-45-6789", "signature": signaturePNG(t, true)})
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/applicant", bytes.NewReader(data)))
+		return rec
+	}
+
+	rec := submit("Ada")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	stored, err := store.Get(response["submissionId"].(string))
+	require.NoError(t, err)
+	assert.True(t, IsEncryptedValue(stored.Data["ssn"]))
+	assert.NoError(t, handler.VerifySubmissionSignatures(stored), "seals cover the decrypted data")
+
+	assert.Equal(t, http.StatusConflict, submit("Ada L.").Code, "encrypted values still match as duplicates")
+}
@@ -0,0 +1,193 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionHeader carries the session ID of dynamic function requests
+const SessionHeader = "X-Smartform-Session"
+
+// SessionMemoryKey is the reserved form state key under which dynamic functions
+// receive the scratch memory of the calling session
+const SessionMemoryKey = "_session"
+
+// Defaults for session memory
+const (
+	DefaultSessionMemoryTTL        = 30 * time.Minute
+	DefaultSessionMemoryMaxEntries = 100
+	DefaultSessionMemoryMaxBytes   = 64 * 1024
+)
+
+// ErrSessionMemoryFull is returned when a write would exceed the session's size limits
+var ErrSessionMemoryFull = errors.New("session memory is full")
+
+// sessionScratch holds the values of one session
+type sessionScratch struct {
+	values    map[string]interface{}
+	sizes     map[string]int
+	size      int
+	expiresAt time.Time
+}
+
+// SessionMemoryStore keeps a small scratch store per session so that dynamic functions
+// can carry state across calls, e.g. to refine a search or accumulate a cart. Sessions
+// expire after a period without access.
+type SessionMemoryStore struct {
+	sessions   map[string]*sessionScratch
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+	lock       sync.Mutex
+}
+
+// NewSessionMemoryStore creates a session memory store with the default limits
+func NewSessionMemoryStore() *SessionMemoryStore {
+	return &SessionMemoryStore{
+		sessions:   make(map[string]*sessionScratch),
+		ttl:        DefaultSessionMemoryTTL,
+		maxEntries: DefaultSessionMemoryMaxEntries,
+		maxBytes:   DefaultSessionMemoryMaxBytes,
+	}
+}
+
+// SetTTL sets how long a session is kept after its last access
+func (sms *SessionMemoryStore) SetTTL(ttl time.Duration) {
+	sms.lock.Lock()
+	defer sms.lock.Unlock()
+	sms.ttl = ttl
+}
+
+// SetLimits sets the maximum number of entries and the maximum JSON-encoded size of a session
+func (sms *SessionMemoryStore) SetLimits(maxEntries, maxBytes int) {
+	sms.lock.Lock()
+	defer sms.lock.Unlock()
+	sms.maxEntries = maxEntries
+	sms.maxBytes = maxBytes
+}
+
+// Session returns the memory of a session
+func (sms *SessionMemoryStore) Session(sessionID string) *SessionMemory {
+	return &SessionMemory{store: sms, sessionID: sessionID}
+}
+
+// Clear drops all values of a session
+func (sms *SessionMemoryStore) Clear(sessionID string) {
+	sms.lock.Lock()
+	defer sms.lock.Unlock()
+	delete(sms.sessions, sessionID)
+}
+
+// scratch returns a live session, creating it when create is set, and extends its lifetime.
+// The caller must hold the lock.
+func (sms *SessionMemoryStore) scratch(sessionID string, create bool) *sessionScratch {
+	now := time.Now()
+	for id, scratch := range sms.sessions {
+		if now.After(scratch.expiresAt) {
+			delete(sms.sessions, id)
+		}
+	}
+
+	scratch, ok := sms.sessions[sessionID]
+	if !ok {
+		if !create {
+			return nil
+		}
+		scratch = &sessionScratch{
+			values: make(map[string]interface{}),
+			sizes:  make(map[string]int),
+		}
+		sms.sessions[sessionID] = scratch
+	}
+	scratch.expiresAt = now.Add(sms.ttl)
+	return scratch
+}
+
+// SessionMemory is the scratch memory of one session, handed to dynamic functions
+type SessionMemory struct {
+	store     *SessionMemoryStore
+	sessionID string
+}
+
+// SessionMemoryFrom returns the session memory injected into a dynamic function's form
+// state, or nil when the call was made outside a session
+func SessionMemoryFrom(formState map[string]interface{}) *SessionMemory {
+	memory, _ := formState[SessionMemoryKey].(*SessionMemory)
+	return memory
+}
+
+// SessionID returns the ID of the session
+func (sm *SessionMemory) SessionID() string {
+	return sm.sessionID
+}
+
+// Get returns a copy of a stored value
+func (sm *SessionMemory) Get(key string) (interface{}, bool) {
+	sm.store.lock.Lock()
+	defer sm.store.lock.Unlock()
+
+	scratch := sm.store.scratch(sm.sessionID, false)
+	if scratch == nil {
+		return nil, false
+	}
+	value, ok := scratch.values[key]
+	return cloneValue(value), ok
+}
+
+// Set stores a JSON-encodable value. It fails with ErrSessionMemoryFull when the session
+// would exceed its entry or size limit.
+func (sm *SessionMemory) Set(key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error encoding session value %s: %w", key, err)
+	}
+	size := len(key) + len(encoded)
+
+	sm.store.lock.Lock()
+	defer sm.store.lock.Unlock()
+
+	scratch := sm.store.scratch(sm.sessionID, true)
+	previous, exists := scratch.sizes[key]
+	if !exists && sm.store.maxEntries > 0 && len(scratch.values) >= sm.store.maxEntries {
+		return ErrSessionMemoryFull
+	}
+	if sm.store.maxBytes > 0 && scratch.size-previous+size > sm.store.maxBytes {
+		return ErrSessionMemoryFull
+	}
+
+	scratch.values[key] = cloneValue(value)
+	scratch.sizes[key] = size
+	scratch.size += size - previous
+	return nil
+}
+
+// Delete removes a stored value
+func (sm *SessionMemory) Delete(key string) {
+	sm.store.lock.Lock()
+	defer sm.store.lock.Unlock()
+
+	scratch := sm.store.scratch(sm.sessionID, false)
+	if scratch == nil {
+		return
+	}
+	scratch.size -= scratch.sizes[key]
+	delete(scratch.values, key)
+	delete(scratch.sizes, key)
+}
+
+// Keys returns the keys stored in the session
+func (sm *SessionMemory) Keys() []string {
+	sm.store.lock.Lock()
+	defer sm.store.lock.Unlock()
+
+	keys := []string{}
+	if scratch := sm.store.scratch(sm.sessionID, false); scratch != nil {
+		for key := range scratch.values {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,69 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionMemoryAccumulatesAcrossCalls(t *testing.T) {
+	service := NewDynamicFunctionService()
+	service.RegisterFunction("addToCart", func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		memory := SessionMemoryFrom(formState)
+		if memory == nil {
+			return []interface{}{args["item"]}, nil
+		}
+		cart, _ := memory.Get("cart")
+		items, _ := cart.([]interface{})
+		items = append(items, args["item"])
+		return items, memory.Set("cart", items)
+	})
+
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(service)
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	call := func(item, session string) []interface{} {
+		req := httptest.NewRequest(http.MethodPost, "/api/function/addToCart",
+			strings.NewReader(`{"arguments": {"item": "`+item+`"}}`))
+		req.Header.Set(SessionHeader, session)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var items []interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &items))
+		return items
+	}
+
+	call("apple", "s1")
+	assert.Equal(t, []interface{}{"apple", "pear"}, call("pear", "s1"))
+	assert.Equal(t, []interface{}{"plum"}, call("plum", "s2"))
+	assert.Equal(t, []interface{}{"fig"}, call("fig", ""))
+}
+
+func TestSessionMemoryLimitsAndExpiry(t *testing.T) {
+	store := NewSessionMemoryStore()
+	store.SetLimits(2, 32)
+	memory := store.Session("s1")
+
+	require.NoError(t, memory.Set("a", 1))
+	require.NoError(t, memory.Set("b", 2))
+	assert.Equal(t, ErrSessionMemoryFull, memory.Set("c", 3))
+	require.NoError(t, memory.Set("b", 3)) // Overwriting does not add an entry
+	assert.Equal(t, ErrSessionMemoryFull, memory.Set("a", strings.Repeat("x", 40)))
+
+	memory.Delete("a")
+	assert.ElementsMatch(t, []string{"b"}, memory.Keys())
+
+	store.SetTTL(-time.Second)
+	_ = memory.Set("c", 1) // Refreshes the session with an already elapsed TTL
+	_, ok := memory.Get("c")
+	assert.False(t, ok)
+}
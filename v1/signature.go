@@ -0,0 +1,493 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+)
+
+// SignatureFormat is the form a drawn signature is submitted in
+type SignatureFormat string
+
+const (
+	// SignatureFormatPNG signatures are PNG images, as a data URL or base64
+	SignatureFormatPNG SignatureFormat = "png"
+	// SignatureFormatPoints signatures are the strokes drawn on the pad, as lists of points
+	SignatureFormatPoints SignatureFormat = "points"
+)
+
+// Limits of signature values
+const (
+	// DefaultSignatureMaxBytes is the largest decoded image accepted unless configured otherwise
+	DefaultSignatureMaxBytes = 256 << 10
+	// DefaultSignatureMinPoints is the fewest points of a signature drawn as strokes unless
+	// configured otherwise; a single dot is no signature
+	DefaultSignatureMinPoints = 2
+	// maxSignaturePoints is the most points a signature drawn as strokes may have
+	maxSignaturePoints = 10000
+	// maxSignaturePixels is the largest image decoded, whatever its size in bytes
+	maxSignaturePixels = 4096 * 4096
+)
+
+// pngDataURLPrefix prefixes signatures submitted as data URLs
+const pngDataURLPrefix = "data:image/png;base64,"
+
+// ErrSignatureMismatch is returned when a sealed signature does not match the stored data
+var ErrSignatureMismatch = errors.New("signature does not match the signed data")
+
+// SignatureConfig configures the signature pad of a signature field and the checks of its value
+type SignatureConfig struct {
+	Formats         []SignatureFormat `json:"formats,omitempty"`         // Formats accepted, all when empty
+	MaxBytes        int64             `json:"maxBytes,omitempty"`        // Largest decoded image, DefaultSignatureMaxBytes when zero
+	MinPoints       int               `json:"minPoints,omitempty"`       // Fewest points of strokes, DefaultSignatureMinPoints when zero
+	Width           int               `json:"width,omitempty"`           // Width of the pad in pixels
+	Height          int               `json:"height,omitempty"`          // Height of the pad in pixels
+	PenColor        string            `json:"penColor,omitempty"`        // CSS color of the pen
+	BackgroundColor string            `json:"backgroundColor,omitempty"` // CSS color of the pad
+	Seal            bool              `json:"seal,omitempty"`            // Store a hash over the signature and the submitted data
+}
+
+// Clone returns a deep copy of the signature configuration
+func (sc *SignatureConfig) Clone() *SignatureConfig {
+	if sc == nil {
+		return nil
+	}
+
+	clone := *sc
+	if sc.Formats != nil {
+		clone.Formats = append([]SignatureFormat(nil), sc.Formats...)
+	}
+	return &clone
+}
+
+// accepts reports whether signatures may be submitted in a format
+func (sc *SignatureConfig) accepts(format SignatureFormat) bool {
+	if sc == nil || len(sc.Formats) == 0 {
+		return true
+	}
+	for _, accepted := range sc.Formats {
+		if accepted == format {
+			return true
+		}
+	}
+	return false
+}
+
+// maxBytes returns the largest decoded image accepted
+func (sc *SignatureConfig) maxBytes() int64 {
+	if sc == nil || sc.MaxBytes <= 0 {
+		return DefaultSignatureMaxBytes
+	}
+	return sc.MaxBytes
+}
+
+// minPoints returns the fewest points a signature drawn as strokes must have
+func (sc *SignatureConfig) minPoints() int {
+	if sc == nil || sc.MinPoints <= 0 {
+		return DefaultSignatureMinPoints
+	}
+	return sc.MinPoints
+}
+
+// SignaturePoint is a point of a stroke drawn on the signature pad
+type SignaturePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	T int64   `json:"t,omitempty"` // Milliseconds since the stroke began
+}
+
+// Signature is a drawn signature, either as a PNG image or as strokes
+type Signature struct {
+	Format  SignatureFormat    `json:"format"`
+	Data    string             `json:"data,omitempty"`    // Base64 PNG image
+	Strokes [][]SignaturePoint `json:"strokes,omitempty"` // Points of each stroke, in drawing order
+}
+
+// SignatureRecord is stored in the submission in place of the value of a sealed signature
+// field. Its hash covers the signature, the rest of the submitted data, and the signing time.
+type SignatureRecord struct {
+	Signature
+	Hash     string    `json:"hash"`
+	SignedAt time.Time `json:"signedAt"`
+}
+
+// ParseSignature reads a submitted signature: a PNG data URL or base64 string, or an object
+// with the "format" and either the base64 "data" or the "strokes". It returns nil for no value.
+func ParseSignature(value interface{}) (*Signature, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		data, err := signatureData(v)
+		if err != nil {
+			return nil, err
+		}
+		return &Signature{Format: SignatureFormatPNG, Data: data}, nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		var signature Signature
+		if err := decodeRaw(v, &signature); err != nil {
+			return nil, fmt.Errorf("invalid signature: %w", err)
+		}
+		if signature.Format == "" {
+			signature.Format = SignatureFormatPNG
+			if signature.Strokes != nil {
+				signature.Format = SignatureFormatPoints
+			}
+		}
+		if signature.Format == SignatureFormatPNG {
+			data, err := signatureData(signature.Data)
+			if err != nil {
+				return nil, err
+			}
+			signature.Data = data
+		}
+		return &signature, nil
+	case *Signature:
+		return v, nil
+	case *SignatureRecord:
+		return &v.Signature, nil
+	default:
+		return nil, fmt.Errorf("invalid signature of type %T", value)
+	}
+}
+
+// signatureData strips the data URL prefix from a base64 PNG image
+func signatureData(value string) (string, error) {
+	if strings.HasPrefix(value, "data:") {
+		if !strings.HasPrefix(value, pngDataURLPrefix) {
+			return "", errors.New("signature image is not a PNG")
+		}
+		value = strings.TrimPrefix(value, pngDataURLPrefix)
+	}
+	return value, nil
+}
+
+// Image returns the decoded PNG image of a signature
+func (s *Signature) Image() ([]byte, error) {
+	if s.Format != SignatureFormatPNG {
+		return nil, fmt.Errorf("signature is drawn as %s, not an image", s.Format)
+	}
+	data, err := base64.StdEncoding.DecodeString(s.Data)
+	if err != nil {
+		// Encoders for data URLs commonly drop the padding
+		if data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s.Data, "=")); err != nil {
+			return nil, errors.New("signature image is not valid base64")
+		}
+	}
+	return data, nil
+}
+
+// points returns the number of points of a signature drawn as strokes
+func (s *Signature) points() int {
+	count := 0
+	for _, stroke := range s.Strokes {
+		count += len(stroke)
+	}
+	return count
+}
+
+// inspect checks a signature against the configuration and reports whether it is blank
+func (sc *SignatureConfig) inspect(signature *Signature) (bool, error) {
+	if !sc.accepts(signature.Format) {
+		return false, fmt.Errorf("signatures drawn as %s are not accepted", signature.Format)
+	}
+
+	switch signature.Format {
+	case SignatureFormatPNG:
+		data, err := signature.Image()
+		if err != nil {
+			return false, err
+		}
+		if int64(len(data)) > sc.maxBytes() {
+			return false, fmt.Errorf("signature image exceeds %d bytes", sc.maxBytes())
+		}
+		return blankPNG(data)
+	case SignatureFormatPoints:
+		count := signature.points()
+		if count > maxSignaturePoints {
+			return false, fmt.Errorf("signature exceeds %d points", maxSignaturePoints)
+		}
+		return count < sc.minPoints(), nil
+	default:
+		return false, fmt.Errorf("unknown signature format %q", signature.Format)
+	}
+}
+
+// blankPNG decodes a PNG image and reports whether all of its pixels are the same, as on an
+// untouched signature pad
+func blankPNG(data []byte) (bool, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return false, errors.New("signature image is not a valid PNG")
+	}
+	if config.Width*config.Height > maxSignaturePixels {
+		return false, fmt.Errorf("signature image exceeds %d pixels", maxSignaturePixels)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return false, errors.New("signature image is not a valid PNG")
+	}
+
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return true, nil
+	}
+	// Colors are compared premultiplied, so all fully transparent pixels are alike
+	r0, g0, b0, a0 := img.At(bounds.Min.X, bounds.Min.Y).RGBA()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, g, b, a := img.At(x, y).RGBA(); r != r0 || g != g0 || b != b0 || a != a0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// validateSignature checks the value of a signature field: a blank signature counts as no
+// value, and a signature that is malformed, too large, or in a format the field does not
+// accept is rejected
+func (v *Validator) validateSignature(field *Field, fieldPath string, value interface{}, required bool, result *ValidationResult) {
+	signature, err := ParseSignature(value)
+	blank := signature == nil
+	if err == nil && signature != nil {
+		blank, err = field.Signature.inspect(signature)
+	}
+	if err != nil {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s is not a valid signature: %v", field.Label, err),
+			RuleType: string(ValidationTypeSignature),
+		})
+		return
+	}
+
+	if blank && required {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s is required", field.Label),
+			RuleType: string(ValidationTypeRequired),
+		})
+	}
+}
+
+// signatureSummary describes the value of a signature field in place of the signature itself,
+// e.g. on printed copies
+func signatureSummary(value interface{}) string {
+	if record, ok := value.(*SignatureRecord); ok {
+		return "Signed " + record.SignedAt.Format("2006-01-02 15:04 MST")
+	}
+	if signature, err := ParseSignature(value); err != nil || signature == nil {
+		return ""
+	}
+	return "Signed"
+}
+
+// SealSignatures replaces the value of every signature field configured to be sealed in
+// validated data with a SignatureRecord. The hash of the record covers the signature, the
+// rest of the data, and the signing time; it is an HMAC when a key is given, so that it
+// cannot be recomputed without the key.
+func (fs *FormSchema) SealSignatures(data map[string]interface{}, signedAt time.Time, key []byte) error {
+	payload, err := fs.signedPayload(data)
+	if err != nil {
+		return err
+	}
+
+	validator := NewValidator(fs)
+	var seal func(fields []*Field, values map[string]interface{}, path string) error
+	seal = func(fields []*Field, values map[string]interface{}, path string) error {
+		for _, field := range fields {
+			if field.Visible != nil && !validator.evaluateCondition(field.Visible, data) {
+				continue
+			}
+			fieldPath := joinPath(path, field.ID)
+
+			if field.Type == FieldTypeSignature && field.Signature != nil && field.Signature.Seal {
+				signature, err := ParseSignature(values[field.ID])
+				if err != nil {
+					return fmt.Errorf("error sealing signature %s: %w", fieldPath, err)
+				}
+				if signature == nil {
+					continue
+				}
+				record := &SignatureRecord{Signature: *signature, SignedAt: signedAt.UTC()}
+				if record.Hash, err = signatureHash(fieldPath, record, payload, key); err != nil {
+					return fmt.Errorf("error sealing signature %s: %w", fieldPath, err)
+				}
+				values[field.ID] = record
+				continue
+			}
+
+			if field.Type == FieldTypeGroup || field.Type == FieldTypeObject {
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					if err := seal(field.Nested, nested, fieldPath); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return seal(fs.Fields, data, "")
+}
+
+// VerifySignatures checks the sealed signatures in stored data against the data, returning
+// an error wrapping ErrSignatureMismatch for the first that does not match. The key must be
+// the one the signatures were sealed with.
+func (fs *FormSchema) VerifySignatures(data map[string]interface{}, key []byte) error {
+	payload, err := fs.signedPayload(data)
+	if err != nil {
+		return err
+	}
+
+	var verify func(fields []*Field, values map[string]interface{}, path string) error
+	verify = func(fields []*Field, values map[string]interface{}, path string) error {
+		for _, field := range fields {
+			fieldPath := joinPath(path, field.ID)
+
+			if field.Type == FieldTypeSignature && field.Signature != nil && field.Signature.Seal {
+				value, ok := values[field.ID]
+				if !ok || value == nil {
+					continue
+				}
+				record, ok := value.(*SignatureRecord)
+				if !ok {
+					record = &SignatureRecord{}
+					if err := decodeRaw(value, record); err != nil || record.Hash == "" {
+						return fmt.Errorf("%s is not sealed: %w", fieldPath, ErrSignatureMismatch)
+					}
+				}
+				hash, err := signatureHash(fieldPath, record, payload, key)
+				if err != nil {
+					return err
+				}
+				if !hmac.Equal([]byte(hash), []byte(record.Hash)) {
+					return fmt.Errorf("%s: %w", fieldPath, ErrSignatureMismatch)
+				}
+				continue
+			}
+
+			if field.Type == FieldTypeGroup || field.Type == FieldTypeObject {
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					if err := verify(field.Nested, nested, fieldPath); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	return verify(fs.Fields, data, "")
+}
+
+// signedPayload returns the canonical JSON of data without the values of signature fields,
+// which is what sealed signatures vouch for. Decoding and encoding again sorts the keys of
+// records stored as structs, so that data read back from a store encodes the same.
+func (fs *FormSchema) signedPayload(data map[string]interface{}) ([]byte, error) {
+	payload := cloneMap(data)
+
+	var strip func(fields []*Field, values map[string]interface{})
+	strip = func(fields []*Field, values map[string]interface{}) {
+		for _, field := range fields {
+			if field.Type == FieldTypeSignature {
+				delete(values, field.ID)
+				continue
+			}
+			if field.Type == FieldTypeGroup || field.Type == FieldTypeObject {
+				if nested, ok := values[field.ID].(map[string]interface{}); ok {
+					strip(field.Nested, nested)
+				}
+			}
+		}
+	}
+	strip(fs.Fields, payload)
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding signed data: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var canonical interface{}
+	if err := decoder.Decode(&canonical); err != nil {
+		return nil, fmt.Errorf("error encoding signed data: %w", err)
+	}
+	return json.Marshal(canonical)
+}
+
+// signatureHash hashes a signature with the path of its field, the signed data, and the
+// signing time, as an HMAC when a key is given
+func signatureHash(fieldPath string, record *SignatureRecord, payload []byte, key []byte) (string, error) {
+	content := []byte(record.Data)
+	if record.Format == SignatureFormatPoints {
+		var err error
+		if content, err = json.Marshal(record.Strokes); err != nil {
+			return "", fmt.Errorf("error encoding signature: %w", err)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(fieldPath)
+	b.WriteByte(0)
+	b.WriteString(string(record.Format))
+	b.WriteByte(0)
+	b.Write(content)
+	b.WriteByte(0)
+	b.Write(payload)
+	b.WriteByte(0)
+	b.WriteString(record.SignedAt.UTC().Format(time.RFC3339Nano))
+
+	if len(key) == 0 {
+		sum := sha256.Sum256(b.Bytes())
+		return "sha256-" + hex.EncodeToString(sum[:]), nil
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b.Bytes())
+	return "hmac-sha256-" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SetSignatureKey sets the key sealed signatures are hashed with. Without one, seals are
+// plain hashes, which reveal accidental changes but can be recomputed by anyone who changes
+// the data on purpose.
+func (ah *APIHandler) SetSignatureKey(key []byte) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.signatureKey = append([]byte(nil), key...)
+}
+
+// signatureSealKey returns the key sealed signatures are hashed with, if any
+func (ah *APIHandler) signatureSealKey() []byte {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+	return ah.signatureKey
+}
+
+// VerifySubmissionSignatures checks the sealed signatures of a stored submission against its
+// data, see FormSchema.VerifySignatures. Signatures are sealed before sensitive values are
+// encrypted, so they are checked against the decrypted data.
+func (ah *APIHandler) VerifySubmissionSignatures(submission *Submission) error {
+	schema, ok := ah.GetSchema(submission.FormID)
+	if !ok {
+		return ErrFormNotFound
+	}
+	data, err := ah.decryptSensitive(context.Background(), schema, submission.Data)
+	if err != nil {
+		return err
+	}
+	return schema.VerifySignatures(data, ah.signatureSealKey())
+}
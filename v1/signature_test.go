@@ -0,0 +1,115 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signaturePNG returns a white pad as a PNG data URL, with a stroke drawn across it if drawn
+func signaturePNG(t *testing.T, drawn bool) string {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, color.White)
+		}
+		if drawn {
+			img.Set(x, 5, color.Black)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestSignatureValidation(t *testing.T) {
+	builder := NewForm("lease", "Lease")
+	builder.SignatureField("tenant", "Tenant signature").MaxBytes(4096).Required(true)
+	builder.SignatureField("witness", "Witness signature").Formats(SignatureFormatPoints).MinPoints(3)
+	schema := builder.Build()
+
+	stroke := []interface{}{
+		map[string]interface{}{"x": 1.0, "y": 2.0, "t": 0.0},
+		map[string]interface{}{"x": 3.0, "y": 4.0, "t": 16.0},
+		map[string]interface{}{"x": 5.0, "y": 4.0, "t": 32.0},
+	}
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		ruleType ValidationType
+	}{
+		{"drawn image", map[string]interface{}{"tenant": signaturePNG(t, true)}, ""},
+		{"missing", map[string]interface{}{}, ValidationTypeRequired},
+		{"blank pad", map[string]interface{}{"tenant": signaturePNG(t, false)}, ValidationTypeRequired},
+		{"not a PNG", map[string]interface{}{"tenant": "data:image/jpeg;base64,/9j/"}, ValidationTypeSignature},
+		{"corrupt image", map[string]interface{}{"tenant": base64.StdEncoding.EncodeToString([]byte("scribble"))}, ValidationTypeSignature},
+		{"too large", map[string]interface{}{"tenant": base64.StdEncoding.EncodeToString(make([]byte, 5000))}, ValidationTypeSignature},
+		{"strokes", map[string]interface{}{
+			"tenant":  signaturePNG(t, true),
+			"witness": map[string]interface{}{"strokes": []interface{}{stroke}},
+		}, ""},
+		{"too few points is unsigned", map[string]interface{}{
+			"tenant":  signaturePNG(t, true),
+			"witness": map[string]interface{}{"format": "points", "strokes": []interface{}{stroke[:1]}},
+		}, ""},
+		{"format not accepted", map[string]interface{}{
+			"tenant":  signaturePNG(t, true),
+			"witness": signaturePNG(t, true),
+		}, ValidationTypeSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := schema.Validate(tt.data)
+			if tt.ruleType == "" {
+				assert.True(t, result.Valid, "%v", result.Errors)
+				return
+			}
+			require.False(t, result.Valid)
+			assert.Equal(t, string(tt.ruleType), result.Errors[0].RuleType)
+		})
+	}
+}
+
+func TestSealSignatures(t *testing.T) {
+	builder := NewForm("lease", "Lease")
+	builder.TextField("tenantName", "Tenant name")
+	builder.NumberField("rent", "Monthly rent")
+	builder.SignatureField("tenant", "Tenant signature").Seal(true)
+	schema := builder.Build()
+	key := []byte("seal-key")
+	signedAt := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	data := map[string]interface{}{"tenantName": "Ada", "rent": 1200.0, "tenant": signaturePNG(t, true)}
+	require.NoError(t, schema.SealSignatures(data, signedAt, key))
+	record, ok := data["tenant"].(*SignatureRecord)
+	require.True(t, ok)
+	assert.Equal(t, SignatureFormatPNG, record.Format)
+	assert.Equal(t, signedAt, record.SignedAt)
+	assert.Contains(t, record.Hash, "hmac-sha256-")
+	assert.NoError(t, schema.VerifySignatures(data, key))
+
+	// Data read back from a store verifies the same
+	encoded, err := json.Marshal(data)
+	require.NoError(t, err)
+	var stored map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &stored))
+	assert.NoError(t, schema.VerifySignatures(stored, key))
+
+	// Changing the signed data, or verifying with another key, breaks the seal
+	stored["rent"] = 900.0
+	assert.True(t, errors.Is(schema.VerifySignatures(stored, key), ErrSignatureMismatch))
+	assert.True(t, errors.Is(schema.VerifySignatures(data, []byte("other")), ErrSignatureMismatch))
+
+	// Replacing the record by an unsealed signature breaks it too
+	data["tenant"] = signaturePNG(t, true)
+	assert.True(t, errors.Is(schema.VerifySignatures(data, key), ErrSignatureMismatch))
+}
@@ -148,6 +148,41 @@ func (gb *GroupFieldBuilder) BranchField(id, label string) *BranchFieldBuilder {
 	return field
 }
 
+// ConsentField adds a consent field to the group and returns a ConsentFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) ConsentField(id, label string) *ConsentFieldBuilder {
+	field := NewConsentFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
+// SignatureField adds a signature field to the group and returns a SignatureFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) SignatureField(id, label string) *SignatureFieldBuilder {
+	field := NewSignatureFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
+// PhoneField adds a phone field to the group and returns a PhoneFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) PhoneField(id, label string) *PhoneFieldBuilder {
+	field := NewPhoneFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
+// CurrencyField adds a currency field to the group and returns a CurrencyFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) CurrencyField(id, label string) *CurrencyFieldBuilder {
+	field := NewCurrencyFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
+// DisplayField adds a display field to the group and returns a DisplayFieldBuilder for further configuration.
+func (gb *GroupFieldBuilder) DisplayField(id, label string) *DisplayFieldBuilder {
+	field := NewDisplayFieldBuilder(id, label)
+	gb.AddField(field.Build())
+	return field
+}
+
 // CustomField adds a customizable field with a specified id and label, returning a CustomFieldBuilder for further configuration.
 func (gb *GroupFieldBuilder) CustomField(id, label string) *CustomFieldBuilder {
 	field := NewCustomFieldBuilder(id, label)
@@ -557,6 +592,240 @@ func (bb *BranchFieldBuilder) Build() *Field {
 	return bb.field
 }
 
+// -------------------------------
+
+// ConsentFieldBuilder provides a fluent API for creating consent fields
+type ConsentFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewConsentFieldBuilder creates a new consent field builder. Consent fields are
+// required by default.
+func NewConsentFieldBuilder(id, label string) *ConsentFieldBuilder {
+	builder := &ConsentFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypeConsent, label),
+	}
+	builder.field.Required = true
+	builder.field.Consent = &ConsentConfig{}
+	return builder
+}
+
+// Version sets the version of the legal text
+func (cb *ConsentFieldBuilder) Version(version string) *ConsentFieldBuilder {
+	cb.field.Consent.Version = version
+	return cb
+}
+
+// Section adds a block of legal text that is always shown
+func (cb *ConsentFieldBuilder) Section(id, title, text string) *ConsentFieldBuilder {
+	cb.field.Consent.Sections = append(cb.field.Consent.Sections, &ConsentSection{
+		ID:    id,
+		Title: title,
+		Text:  text,
+	})
+	return cb
+}
+
+// SectionWhen adds a block of legal text that is shown when the condition is true
+func (cb *ConsentFieldBuilder) SectionWhen(id, title, text string, condition *Condition) *ConsentFieldBuilder {
+	cb.field.Consent.Sections = append(cb.field.Consent.Sections, &ConsentSection{
+		ID:      id,
+		Title:   title,
+		Text:    text,
+		Visible: condition,
+	})
+	return cb
+}
+
+// Build finalizes and returns the consent field
+func (cb *ConsentFieldBuilder) Build() *Field {
+	return cb.field
+}
+
+// SignatureFieldBuilder provides a fluent API for creating signature fields
+type SignatureFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewSignatureFieldBuilder creates a new signature field builder. Signature fields accept
+// both formats by default.
+func NewSignatureFieldBuilder(id, label string) *SignatureFieldBuilder {
+	builder := &SignatureFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypeSignature, label),
+	}
+	builder.field.Signature = &SignatureConfig{}
+	return builder
+}
+
+// Formats restricts the formats the signature may be submitted in
+func (sb *SignatureFieldBuilder) Formats(formats ...SignatureFormat) *SignatureFieldBuilder {
+	sb.field.Signature.Formats = formats
+	return sb
+}
+
+// MaxBytes sets the largest decoded image accepted
+func (sb *SignatureFieldBuilder) MaxBytes(maxBytes int64) *SignatureFieldBuilder {
+	sb.field.Signature.MaxBytes = maxBytes
+	return sb
+}
+
+// MinPoints sets the fewest points a signature drawn as strokes must have
+func (sb *SignatureFieldBuilder) MinPoints(minPoints int) *SignatureFieldBuilder {
+	sb.field.Signature.MinPoints = minPoints
+	return sb
+}
+
+// PadSize sets the size of the signature pad in pixels
+func (sb *SignatureFieldBuilder) PadSize(width, height int) *SignatureFieldBuilder {
+	sb.field.Signature.Width = width
+	sb.field.Signature.Height = height
+	return sb
+}
+
+// PenColor sets the color the signature is drawn in
+func (sb *SignatureFieldBuilder) PenColor(color string) *SignatureFieldBuilder {
+	sb.field.Signature.PenColor = color
+	return sb
+}
+
+// BackgroundColor sets the color of the signature pad
+func (sb *SignatureFieldBuilder) BackgroundColor(color string) *SignatureFieldBuilder {
+	sb.field.Signature.BackgroundColor = color
+	return sb
+}
+
+// Seal stores the signature with a hash over it and the rest of the submitted data, so that
+// later changes to either can be detected
+func (sb *SignatureFieldBuilder) Seal(seal bool) *SignatureFieldBuilder {
+	sb.field.Signature.Seal = seal
+	return sb
+}
+
+// Build finalizes and returns the signature field
+func (sb *SignatureFieldBuilder) Build() *Field {
+	return sb.field
+}
+
+// PhoneFieldBuilder provides a fluent API for creating phone fields
+type PhoneFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewPhoneFieldBuilder creates a new phone field builder. Without a default country, numbers
+// must be entered with their country code.
+func NewPhoneFieldBuilder(id, label string) *PhoneFieldBuilder {
+	builder := &PhoneFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypePhone, label),
+	}
+	builder.field.Phone = &PhoneConfig{}
+	return builder
+}
+
+// DefaultCountry sets the region, as an ISO 3166-1 alpha-2 code, of numbers entered without
+// a country code
+func (pb *PhoneFieldBuilder) DefaultCountry(country string) *PhoneFieldBuilder {
+	pb.field.Phone.DefaultCountry = country
+	return pb
+}
+
+// CountryFrom takes the region of numbers entered without a country code from another field,
+// such as the country of an address, falling back to the default country when it is empty
+func (pb *PhoneFieldBuilder) CountryFrom(fieldPath string) *PhoneFieldBuilder {
+	pb.field.Phone.CountryField = fieldPath
+	return pb
+}
+
+// Countries restricts the numbers accepted to those of the given regions
+func (pb *PhoneFieldBuilder) Countries(countries ...string) *PhoneFieldBuilder {
+	pb.field.Phone.Countries = countries
+	return pb
+}
+
+// DisplayFormat sets how clients display numbers as they are entered
+func (pb *PhoneFieldBuilder) DisplayFormat(format PhoneFormat) *PhoneFieldBuilder {
+	pb.field.Phone.Format = format
+	return pb
+}
+
+// Build finalizes and returns the phone field
+func (pb *PhoneFieldBuilder) Build() *Field {
+	return pb.field
+}
+
+// CurrencyFieldBuilder provides a fluent API for creating currency fields
+type CurrencyFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewCurrencyFieldBuilder creates a new currency field builder. Without a default currency,
+// amounts must be entered with their currency.
+func NewCurrencyFieldBuilder(id, label string) *CurrencyFieldBuilder {
+	builder := &CurrencyFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypeCurrency, label),
+	}
+	builder.field.Currency = &CurrencyConfig{}
+	return builder
+}
+
+// Currencies restricts the amounts accepted to those of the given currencies, as ISO 4217 codes
+func (cb *CurrencyFieldBuilder) Currencies(currencies ...string) *CurrencyFieldBuilder {
+	cb.field.Currency.Currencies = currencies
+	return cb
+}
+
+// DefaultCurrency sets the currency of amounts entered without one
+func (cb *CurrencyFieldBuilder) DefaultCurrency(currency string) *CurrencyFieldBuilder {
+	cb.field.Currency.DefaultCurrency = currency
+	return cb
+}
+
+// Precision sets the most decimal places amounts may be entered with, e.g. 0 for whole
+// amounts. Amounts are stored in minor units of their currency whatever the precision.
+func (cb *CurrencyFieldBuilder) Precision(places int) *CurrencyFieldBuilder {
+	cb.field.Currency.Precision = &places
+	return cb
+}
+
+// Locale sets the locale amounts are formatted in, e.g. "de" for "1.234,56 €"
+func (cb *CurrencyFieldBuilder) Locale(locale string) *CurrencyFieldBuilder {
+	cb.field.Currency.Locale = locale
+	return cb
+}
+
+// Build finalizes and returns the currency field
+func (cb *CurrencyFieldBuilder) Build() *Field {
+	return cb.field
+}
+
+// DisplayFieldBuilder provides a fluent API for creating display fields
+type DisplayFieldBuilder struct {
+	FieldBuilder
+}
+
+// NewDisplayFieldBuilder creates a new display field builder. Display fields show a value
+// computed from the rest of the form, which is never accepted from the client or stored.
+func NewDisplayFieldBuilder(id, label string) *DisplayFieldBuilder {
+	return &DisplayFieldBuilder{
+		FieldBuilder: *NewFieldBuilder(id, FieldTypeDisplay, label),
+	}
+}
+
+// Expression sets the template expression computing the value, e.g. "${multiply(price, quantity)}"
+func (db *DisplayFieldBuilder) Expression(expression string) *DisplayFieldBuilder {
+	db.field.ComputedValue = expression
+	return db
+}
+
+// Function sets the dynamic function computing the value
+func (db *DisplayFieldBuilder) Function(functionName string) *DynamicFunctionBuilder {
+	return db.DynamicValue(functionName)
+}
+
+// Build finalizes and returns the display field
+func (db *DisplayFieldBuilder) Build() *Field {
+	return db.field
+}
+
 // Extend API field builder for dynamic function support
 func (ab *APIFieldBuilder) WithDynamicRequest(functionName string) *DynamicFunctionBuilder {
 	ab.field.Properties["dynamicRequest"] = true
@@ -0,0 +1,435 @@
+package smartform
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sqlSubmissionSchema creates the tables of SQLSubmissionStore. Submissions are stored whole
+// as JSON, next to the columns they are looked up by.
+var sqlSubmissionSchema = []string{
+	`CREATE TABLE IF NOT EXISTS smartform_submissions (
+		id TEXT PRIMARY KEY,
+		form_id TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		body TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS smartform_submissions_form ON smartform_submissions (form_id, created_at)`,
+	`CREATE TABLE IF NOT EXISTS smartform_unique_keys (
+		form_id TEXT NOT NULL,
+		constraint_name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		submission_id TEXT NOT NULL,
+		PRIMARY KEY (form_id, constraint_name, value)
+	)`,
+}
+
+// sqlFormSessionSchema creates the table of SQLFormSessionStore
+var sqlFormSessionSchema = []string{
+	`CREATE TABLE IF NOT EXISTS smartform_form_sessions (
+		id TEXT PRIMARY KEY,
+		expires_at INTEGER NOT NULL,
+		body TEXT NOT NULL
+	)`,
+}
+
+// sqlSchemaSourceSchema creates the table of SQLSchemaSource
+var sqlSchemaSourceSchema = []string{
+	`CREATE TABLE IF NOT EXISTS smartform_schemas (
+		form_id TEXT NOT NULL,
+		version TEXT NOT NULL,
+		body TEXT NOT NULL,
+		PRIMARY KEY (form_id, version)
+	)`,
+}
+
+// createTables runs the statements creating the tables of a store
+func createTables(db *sql.DB, statements []string) error {
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return fmt.Errorf("error creating tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// SQLSubmissionStore keeps submissions in a SQL database, such as the SQLite database of the
+// standalone server. It creates its tables when missing and enforces unique constraints.
+type SQLSubmissionStore struct {
+	db   *sql.DB
+	lock sync.Mutex // Serializes read-modify-write transactions, which SQLite cannot run concurrently
+}
+
+// NewSQLSubmissionStore creates a submission store in a database, creating its tables if needed
+func NewSQLSubmissionStore(db *sql.DB) (*SQLSubmissionStore, error) {
+	if err := createTables(db, sqlSubmissionSchema); err != nil {
+		return nil, err
+	}
+	return &SQLSubmissionStore{db: db}, nil
+}
+
+// Ping checks that the database is reachable
+func (ss *SQLSubmissionStore) Ping(ctx context.Context) error {
+	return ss.db.PingContext(ctx)
+}
+
+// Save stores a new submission
+func (ss *SQLSubmissionStore) Save(submission *Submission) error {
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("error encoding submission: %w", err)
+	}
+	_, err = ss.db.Exec(`INSERT INTO smartform_submissions (id, form_id, created_at, body) VALUES (?, ?, ?, ?)`,
+		submission.ID, submission.FormID, submission.CreatedAt.UnixNano(), string(body))
+	if err != nil {
+		return fmt.Errorf("error saving submission: %w", err)
+	}
+	return nil
+}
+
+// Get loads a submission by ID
+func (ss *SQLSubmissionStore) Get(id string) (*Submission, error) {
+	return ss.get(ss.db.QueryRow(`SELECT body FROM smartform_submissions WHERE id = ?`, id))
+}
+
+// get decodes the submission of a row
+func (ss *SQLSubmissionStore) get(row *sql.Row) (*Submission, error) {
+	var body string
+	if err := row.Scan(&body); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, fmt.Errorf("error loading submission: %w", err)
+	}
+	submission := &Submission{}
+	if err := json.Unmarshal([]byte(body), submission); err != nil {
+		return nil, fmt.Errorf("error decoding submission: %w", err)
+	}
+	return submission, nil
+}
+
+// List returns the submissions of a form, oldest first
+func (ss *SQLSubmissionStore) List(formID string) ([]*Submission, error) {
+	query := `SELECT body FROM smartform_submissions ORDER BY created_at`
+	args := []interface{}{}
+	if formID != "" {
+		query = `SELECT body FROM smartform_submissions WHERE form_id = ? ORDER BY created_at`
+		args = append(args, formID)
+	}
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing submissions: %w", err)
+	}
+	defer rows.Close()
+
+	submissions := []*Submission{}
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return nil, fmt.Errorf("error listing submissions: %w", err)
+		}
+		submission := &Submission{}
+		if err := json.Unmarshal([]byte(body), submission); err != nil {
+			return nil, fmt.Errorf("error decoding submission: %w", err)
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing submissions: %w", err)
+	}
+	return submissions, nil
+}
+
+// Update applies a change to a stored submission atomically
+func (ss *SQLSubmissionStore) Update(id string, change func(submission *Submission) error) (*Submission, error) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating submission: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	submission, err := ss.get(tx.QueryRow(`SELECT body FROM smartform_submissions WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := change(submission); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding submission: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE smartform_submissions SET body = ? WHERE id = ?`, string(body), id); err != nil {
+		return nil, fmt.Errorf("error updating submission: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating submission: %w", err)
+	}
+	return submission, nil
+}
+
+// Reserve claims unique keys of a form for a submission, all of them or none
+func (ss *SQLSubmissionStore) Reserve(formID, submissionID string, keys []UniqueKey) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error reserving unique keys: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	for _, key := range keys {
+		holder, err := ss.lookup(tx.QueryRow(`SELECT submission_id FROM smartform_unique_keys WHERE form_id = ? AND constraint_name = ? AND value = ?`,
+			formID, key.Constraint, key.Value))
+		if err != nil {
+			return err
+		}
+		if holder != "" && holder != submissionID {
+			return &UniqueConflictError{Constraint: key.Constraint, SubmissionID: holder}
+		}
+	}
+	for _, key := range keys {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO smartform_unique_keys (form_id, constraint_name, value, submission_id) VALUES (?, ?, ?, ?)`,
+			formID, key.Constraint, key.Value, submissionID)
+		if err != nil {
+			return fmt.Errorf("error reserving unique keys: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error reserving unique keys: %w", err)
+	}
+	return nil
+}
+
+// Release frees the unique keys held by a submission
+func (ss *SQLSubmissionStore) Release(formID, submissionID string) error {
+	_, err := ss.db.Exec(`DELETE FROM smartform_unique_keys WHERE form_id = ? AND submission_id = ?`, formID, submissionID)
+	if err != nil {
+		return fmt.Errorf("error releasing unique keys: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the ID of the submission holding a unique key, or "" when the key is free
+func (ss *SQLSubmissionStore) Lookup(formID string, key UniqueKey) (string, error) {
+	return ss.lookup(ss.db.QueryRow(`SELECT submission_id FROM smartform_unique_keys WHERE form_id = ? AND constraint_name = ? AND value = ?`,
+		formID, key.Constraint, key.Value))
+}
+
+// lookup scans the holder of a unique key, which is "" when the key is free
+func (ss *SQLSubmissionStore) lookup(row *sql.Row) (string, error) {
+	var holder string
+	if err := row.Scan(&holder); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("error looking up unique key: %w", err)
+	}
+	return holder, nil
+}
+
+// SQLFormSessionStore keeps form sessions in a SQL database, such as the SQLite database of the
+// standalone server. It creates its table when missing.
+type SQLFormSessionStore struct {
+	db   *sql.DB
+	lock sync.Mutex // Serializes read-modify-write transactions, which SQLite cannot run concurrently
+}
+
+// NewSQLFormSessionStore creates a form session store in a database, creating its table if
+// needed
+func NewSQLFormSessionStore(db *sql.DB) (*SQLFormSessionStore, error) {
+	if err := createTables(db, sqlFormSessionSchema); err != nil {
+		return nil, err
+	}
+	return &SQLFormSessionStore{db: db}, nil
+}
+
+// Ping checks that the database is reachable
+func (ss *SQLFormSessionStore) Ping(ctx context.Context) error {
+	return ss.db.PingContext(ctx)
+}
+
+// Create stores a new session
+func (ss *SQLFormSessionStore) Create(session *FormSession) error {
+	// Expired sessions are dropped as new ones come in, as in the memory store
+	if _, err := ss.db.Exec(`DELETE FROM smartform_form_sessions WHERE expires_at > 0 AND expires_at < ?`, time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("error removing expired sessions: %w", err)
+	}
+
+	body, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("error encoding session: %w", err)
+	}
+	_, err = ss.db.Exec(`INSERT INTO smartform_form_sessions (id, expires_at, body) VALUES (?, ?, ?)`,
+		session.ID, expiryNanos(session.ExpiresAt), string(body))
+	if err != nil {
+		return fmt.Errorf("error saving session: %w", err)
+	}
+	return nil
+}
+
+// Get loads a session by ID
+func (ss *SQLFormSessionStore) Get(id string) (*FormSession, error) {
+	return ss.get(ss.db.QueryRow(`SELECT body FROM smartform_form_sessions WHERE id = ?`, id))
+}
+
+// get decodes the session of a row, treating expired sessions as missing
+func (ss *SQLFormSessionStore) get(row *sql.Row) (*FormSession, error) {
+	var body string
+	if err := row.Scan(&body); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrFormSessionNotFound
+		}
+		return nil, fmt.Errorf("error loading session: %w", err)
+	}
+	session := &FormSession{}
+	if err := json.Unmarshal([]byte(body), session); err != nil {
+		return nil, fmt.Errorf("error decoding session: %w", err)
+	}
+	if session.expired(time.Now()) {
+		return nil, ErrFormSessionNotFound
+	}
+	return session, nil
+}
+
+// Update applies a change to a stored session atomically
+func (ss *SQLFormSessionStore) Update(id string, change func(session *FormSession) error) (*FormSession, error) {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating session: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	session, err := ss.get(tx.QueryRow(`SELECT body FROM smartform_form_sessions WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := change(session); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding session: %w", err)
+	}
+	_, err = tx.Exec(`UPDATE smartform_form_sessions SET expires_at = ?, body = ? WHERE id = ?`,
+		expiryNanos(session.ExpiresAt), string(body), id)
+	if err != nil {
+		return nil, fmt.Errorf("error updating session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating session: %w", err)
+	}
+	return session, nil
+}
+
+// Delete removes a session
+func (ss *SQLFormSessionStore) Delete(id string) error {
+	if _, err := ss.Get(id); err != nil {
+		return err
+	}
+	if _, err := ss.db.Exec(`DELETE FROM smartform_form_sessions WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	return nil
+}
+
+// expiryNanos returns an expiry time as stored, with 0 for sessions that never expire
+func expiryNanos(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return 0
+	}
+	return expiresAt.UnixNano()
+}
+
+// SQLSchemaSource keeps form schemas in a SQL database, one row per version of a form, for
+// APIHandler.ReloadSchemas to load. It creates its table when missing.
+type SQLSchemaSource struct {
+	db   *sql.DB
+	lock sync.Mutex // Serializes read-modify-write transactions, which SQLite cannot run concurrently
+}
+
+// NewSQLSchemaSource creates a schema source in a database, creating its table if needed
+func NewSQLSchemaSource(db *sql.DB) (*SQLSchemaSource, error) {
+	if err := createTables(db, sqlSchemaSourceSchema); err != nil {
+		return nil, err
+	}
+	return &SQLSchemaSource{db: db}, nil
+}
+
+// Save stores a version of a form, replacing the stored one
+func (ss *SQLSchemaSource) Save(schema *FormSchema) error {
+	body, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("error encoding schema: %w", err)
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error saving schema: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }() // No-op after commit
+
+	if _, err := tx.Exec(`DELETE FROM smartform_schemas WHERE form_id = ? AND version = ?`, schema.ID, schema.Version); err != nil {
+		return fmt.Errorf("error saving schema: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO smartform_schemas (form_id, version, body) VALUES (?, ?, ?)`,
+		schema.ID, schema.Version, string(body))
+	if err != nil {
+		return fmt.Errorf("error saving schema: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error saving schema: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a version of a form, or every version when version is empty
+func (ss *SQLSchemaSource) Delete(formID, version string) error {
+	var err error
+	if version == "" {
+		_, err = ss.db.Exec(`DELETE FROM smartform_schemas WHERE form_id = ?`, formID)
+	} else {
+		_, err = ss.db.Exec(`DELETE FROM smartform_schemas WHERE form_id = ? AND version = ?`, formID, version)
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting schema: %w", err)
+	}
+	return nil
+}
+
+// LoadSchemas loads every stored schema
+func (ss *SQLSchemaSource) LoadSchemas(ctx context.Context) ([]*FormSchema, error) {
+	rows, err := ss.db.QueryContext(ctx, `SELECT form_id, body FROM smartform_schemas ORDER BY form_id, version`)
+	if err != nil {
+		return nil, fmt.Errorf("error loading schemas: %w", err)
+	}
+	defer rows.Close()
+
+	var schemas []*FormSchema
+	for rows.Next() {
+		var formID, body string
+		if err := rows.Scan(&formID, &body); err != nil {
+			return nil, fmt.Errorf("error loading schemas: %w", err)
+		}
+		schema, err := FormSchemaFromJSON(body)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding schema %s: %w", formID, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading schemas: %w", err)
+	}
+	return schemas, nil
+}
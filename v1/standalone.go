@@ -0,0 +1,166 @@
+package smartform
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults of the standalone server
+const (
+	DefaultStandaloneAddr      = ":8080"
+	DefaultStandaloneDriver    = "sqlite"
+	DefaultStandaloneDatabase  = "smartform.db"
+	DefaultStandaloneUploadDir = "uploads"
+)
+
+// StandaloneConfig configures the standalone server. Zero values take the defaults.
+type StandaloneConfig struct {
+	Addr      string        // Address to listen on
+	Driver    string        // Name of the registered database/sql driver
+	Database  string        // Data source name, for SQLite the path of the database file
+	UploadDir string        // Directory uploaded files are stored in
+	Forms     []*FormSchema // Forms to serve
+	// Configure sets up the handler further once the stores are wired, e.g. with dynamic
+	// functions or a staff authorizer for the submission review endpoints
+	Configure func(handler *APIHandler) error
+}
+
+// withDefaults returns the configuration with defaults for the values left unset
+func (sc StandaloneConfig) withDefaults() StandaloneConfig {
+	if sc.Addr == "" {
+		sc.Addr = DefaultStandaloneAddr
+	}
+	if sc.Driver == "" {
+		sc.Driver = DefaultStandaloneDriver
+	}
+	if sc.Database == "" {
+		sc.Database = DefaultStandaloneDatabase
+	}
+	if sc.UploadDir == "" {
+		sc.UploadDir = DefaultStandaloneUploadDir
+	}
+	return sc
+}
+
+// StandaloneServer is a complete form backend in one process: the API handler with
+// submissions and form sessions stored in a SQL database, uploads stored on local disk, and
+// the HTML print renderer. Larger deployments compose these pieces themselves instead.
+type StandaloneServer struct {
+	Handler *APIHandler
+	DB      *sql.DB
+	server  *http.Server
+}
+
+// NewStandaloneServer opens the database and wires the standalone server. The database driver
+// is not linked into the library; programs register one with a blank import, such as
+// modernc.org/sqlite (driver "sqlite") or github.com/mattn/go-sqlite3 (driver "sqlite3").
+func NewStandaloneServer(config StandaloneConfig) (*StandaloneServer, error) {
+	config = config.withDefaults()
+	if !driverRegistered(config.Driver) {
+		return nil, fmt.Errorf("database driver %q is not registered; import one, e.g. _ \"modernc.org/sqlite\"", config.Driver)
+	}
+
+	db, err := sql.Open(config.Driver, config.Database)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+	if strings.HasPrefix(config.Driver, "sqlite") {
+		// SQLite allows one writer at a time
+		db.SetMaxOpenConns(1)
+	}
+
+	server, err := newStandaloneServer(config, db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return server, nil
+}
+
+// newStandaloneServer wires the standalone server on an open database
+func newStandaloneServer(config StandaloneConfig, db *sql.DB) (*StandaloneServer, error) {
+	submissions, err := NewSQLSubmissionStore(db)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := NewSQLFormSessionStore(db)
+	if err != nil {
+		return nil, err
+	}
+	files, err := NewLocalFileStorage(config.UploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(submissions)
+	handler.SetFormSessionStore(sessions)
+	handler.SetFileStorage(files)
+	for _, schema := range config.Forms {
+		if err := handler.RegisterSchema(schema); err != nil {
+			return nil, fmt.Errorf("error registering form %s: %w", schema.ID, err)
+		}
+	}
+	if config.Configure != nil {
+		if err := config.Configure(handler); err != nil {
+			return nil, err
+		}
+	}
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	return &StandaloneServer{
+		Handler: handler,
+		DB:      db,
+		server:  &http.Server{Addr: config.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}, nil
+}
+
+// driverRegistered reports whether a database/sql driver is registered under a name
+func driverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the routes of the API handler
+func (ss *StandaloneServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ss.server.Handler.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves requests until the server is shut down
+func (ss *StandaloneServer) ListenAndServe() error {
+	err := ss.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the server gracefully and closes the database
+func (ss *StandaloneServer) Shutdown(ctx context.Context) error {
+	err := ss.server.Shutdown(ctx)
+	if closeErr := ss.DB.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ServeStandalone runs a complete form backend from one binary, see NewStandaloneServer. It
+// blocks until the server fails.
+func ServeStandalone(config StandaloneConfig) error {
+	server, err := NewStandaloneServer(config)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = server.DB.Close() }()
+	return server.ListenAndServe()
+}
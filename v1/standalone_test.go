@@ -0,0 +1,22 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandaloneConfigDefaults(t *testing.T) {
+	config := StandaloneConfig{Database: "forms.db"}.withDefaults()
+	assert.Equal(t, DefaultStandaloneAddr, config.Addr)
+	assert.Equal(t, DefaultStandaloneDriver, config.Driver)
+	assert.Equal(t, "forms.db", config.Database)
+	assert.Equal(t, DefaultStandaloneUploadDir, config.UploadDir)
+}
+
+func TestStandaloneServerRequiresDriver(t *testing.T) {
+	// The library links no database driver; programs import the one they want
+	_, err := NewStandaloneServer(StandaloneConfig{Driver: "no-such-driver", UploadDir: t.TempDir()})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"no-such-driver" is not registered`)
+}
@@ -0,0 +1,146 @@
+package smartform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StateEngine computes server-side changes to form state
+type StateEngine struct {
+	schema    *FormSchema
+	resolver  *TemplateResolver
+	validator *Validator
+}
+
+// NewStateEngine creates a state engine for the given schema
+func NewStateEngine(schema *FormSchema) *StateEngine {
+	return &StateEngine{
+		schema:    schema,
+		resolver:  schema.GetTemplateResolver(),
+		validator: NewValidator(schema),
+	}
+}
+
+// NewArrayItem returns a fully defaulted item for the array field at arrayPath
+// (e.g. "contacts" or "orders[0].lines") given the current form state.
+//
+// Defaults and conditional defaults inside the item template are evaluated against
+// the form state, with the item's own fields (its siblings) taking precedence and
+// three extra variables available: "item" (the item being built), "parent" (the
+// object that holds the array), and "index" (the position the item will take).
+func (se *StateEngine) NewArrayItem(arrayPath string, state map[string]interface{}) (map[string]interface{}, error) {
+	if state == nil {
+		state = map[string]interface{}{}
+	}
+	field, parent, items, err := se.findArray(arrayPath, state)
+	if err != nil {
+		return nil, err
+	}
+
+	item := map[string]interface{}{}
+	se.applyItemDefaults(field.Nested, item, item, parent, len(items), state)
+	return item, nil
+}
+
+// findArray finds the array field at arrayPath, with the object holding the array and the
+// items it has in the form state
+func (se *StateEngine) findArray(arrayPath string, state map[string]interface{}) (*Field, map[string]interface{}, []interface{}, error) {
+	field, err := se.findFieldByPath(arrayPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if field.Type != FieldTypeArray {
+		return nil, nil, nil, fmt.Errorf("field '%s' is not an array", arrayPath)
+	}
+
+	parent := state
+	if i := strings.LastIndex(arrayPath, "."); i >= 0 {
+		if parentMap, ok := se.validator.getValueByPath(state, arrayPath[:i]).(map[string]interface{}); ok {
+			parent = parentMap
+		}
+	}
+
+	items, _ := se.validator.getValueByPath(state, arrayPath).([]interface{})
+	return field, parent, items, nil
+}
+
+// applyItemDefaults fills target with the defaults of fields, evaluated in the item's scope
+func (se *StateEngine) applyItemDefaults(fields []*Field, target, item, parent map[string]interface{}, index int, state map[string]interface{}) {
+	for _, field := range fields {
+		if field.Type == FieldTypeGroup || field.Type == FieldTypeObject {
+			nested := map[string]interface{}{}
+			target[field.ID] = nested
+			se.applyItemDefaults(field.Nested, nested, item, parent, index, state)
+			continue
+		}
+
+		if field.Type == FieldTypeArray {
+			target[field.ID] = []interface{}{}
+			continue
+		}
+
+		scope := itemScope(state, item, parent, index)
+		value := field.DefaultValue
+
+		for _, defaultWhen := range field.DefaultWhen {
+			if defaultWhen.Condition != nil && se.validator.evaluateCondition(defaultWhen.Condition, scope) {
+				value = defaultWhen.Value
+			}
+		}
+
+		if value == nil {
+			continue
+		}
+
+		resolved := se.resolver.ResolveFieldValue(field.ID, cloneValue(value), scope)
+		if resolved.Resolved {
+			value = resolved.Value
+		}
+		target[field.ID] = value
+	}
+}
+
+// itemScope builds the data an array item's defaults are evaluated against
+func itemScope(state, item, parent map[string]interface{}, index int) map[string]interface{} {
+	scope := make(map[string]interface{}, len(state)+len(item)+3)
+	for k, v := range state {
+		scope[k] = v
+	}
+	for k, v := range item {
+		scope[k] = v
+	}
+	scope["item"] = item
+	scope["parent"] = parent
+	scope["index"] = float64(index)
+	return scope
+}
+
+// pathIndexPattern matches array indexes in a data path
+var pathIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// findFieldByPath finds a field definition by its data path, ignoring array indexes
+func (se *StateEngine) findFieldByPath(path string) (*Field, error) {
+	if path == "" {
+		return nil, fmt.Errorf("field path is required")
+	}
+
+	fields := se.schema.Fields
+	var field *Field
+
+	for _, part := range strings.Split(pathIndexPattern.ReplaceAllString(path, ""), ".") {
+		field = nil
+		for _, candidate := range fields {
+			if candidate.ID == part {
+				field = candidate
+				break
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("field '%s' not found", path)
+		}
+		fields = field.Nested
+	}
+
+	return field, nil
+}
@@ -0,0 +1,44 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateEngine_NewArrayItem(t *testing.T) {
+	lines := NewArrayFieldBuilder("lines", "Lines")
+	lines.ItemTemplate(NewFieldBuilder("currency", FieldTypeText, "Currency").
+		DefaultValue("${parent.currency}").Build())
+	lines.ItemTemplate(&Field{
+		ID:           "quantity",
+		Type:         FieldTypeNumber,
+		DefaultValue: float64(1),
+		DefaultWhen: []*DefaultWhen{{
+			Condition: &Condition{Type: ConditionTypeSimple, Field: "wholesale", Operator: "eq", Value: true},
+			Value:     float64(100),
+		}},
+	})
+
+	schema := NewForm("invoice", "Invoice").
+		AddField(NewFieldBuilder("wholesale", FieldTypeCheckbox, "Wholesale").Build()).
+		AddField(NewFieldBuilder("currency", FieldTypeText, "Currency").Build()).
+		AddField(lines.Build()).
+		Build()
+
+	engine := NewStateEngine(schema)
+	state := map[string]interface{}{
+		"wholesale": true,
+		"currency":  "EUR",
+		"lines":     []interface{}{map[string]interface{}{"currency": "EUR", "quantity": float64(3)}},
+	}
+
+	item, err := engine.NewArrayItem("lines", state)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", item["currency"])
+	assert.Equal(t, float64(100), item["quantity"])
+
+	_, err = engine.NewArrayItem("currency", state)
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Layouts date, time, and datetime fields are parsed with, tried in order
+var (
+	dateLayouts     = []string{"2006-01-02", time.RFC3339}
+	timeLayouts     = []string{"15:04", "15:04:05", time.RFC3339}
+	datetimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+// Decode copies submitted form data into the struct target points to, so handlers can work
+// with typed values instead of unpacking maps. Struct fields are matched to form fields like
+// FromStruct names them: by the id option of their smartform tag, their json tag, or their
+// lowerCamel name. Groups are decoded into nested structs, arrays into slices, date, time,
+// and datetime fields into time.Time, and numbers and booleans are converted from strings and
+// between numeric types. Values without a struct field are ignored. Decode does not validate
+// the data; validate it first.
+func (fs *FormSchema) Decode(data map[string]interface{}, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Decode needs a pointer to a struct, got %T", target)
+	}
+	return decodeStruct(fs.Fields, data, v.Elem(), "")
+}
+
+// decodeStruct decodes an object into a struct, looking up the form fields of its values
+func decodeStruct(fields []*Field, data map[string]interface{}, v reflect.Value, path string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup(StructTag)
+		if tag == "-" {
+			continue
+		}
+
+		// Embedded structs are flattened, as FromStruct does
+		fieldType := sf.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if sf.Anonymous && !hasTag && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			if sf.Type.Kind() == reflect.Ptr && !sf.IsExported() {
+				continue
+			}
+			if err := decodeStruct(fields, data, allocate(v.Field(i)), path); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		options, err := parseStructTag(tag)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		id := structFieldID(sf, options)
+		value, ok := data[id]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(findField(fields, id), value, v.Field(i), joinPath(path, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeValue decodes a submitted value into a Go value. field may be nil when the schema has
+// no field for the value.
+func decodeValue(field *Field, value interface{}, v reflect.Value, path string) error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		return decodeValue(field, value, allocate(v), path)
+	}
+	if v.Type() == timeType {
+		t, err := decodeTime(field, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	mismatch := fmt.Errorf("%s: cannot decode %T into %s", path, value, v.Type())
+	switch v.Kind() {
+	case reflect.Interface:
+		if !reflect.TypeOf(value).AssignableTo(v.Type()) {
+			return mismatch
+		}
+		v.Set(reflect.ValueOf(value))
+
+	case reflect.String:
+		coerced, _ := coerceValue(string(OptionValueTypeString), value)
+		str, ok := coerced.(string)
+		if !ok {
+			return mismatch
+		}
+		v.SetString(str)
+
+	case reflect.Bool:
+		b, _ := coerceValue(string(OptionValueTypeBoolean), value)
+		boolean, ok := b.(bool)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(boolean)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, ok := decodeNumber(value)
+		if !ok || num != math.Trunc(num) || v.OverflowInt(int64(num)) {
+			return mismatch
+		}
+		v.SetInt(int64(num))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, ok := decodeNumber(value)
+		if !ok || num < 0 || num != math.Trunc(num) || v.OverflowUint(uint64(num)) {
+			return mismatch
+		}
+		v.SetUint(uint64(num))
+
+	case reflect.Float32, reflect.Float64:
+		num, ok := decodeNumber(value)
+		if !ok || v.OverflowFloat(num) {
+			return mismatch
+		}
+		v.SetFloat(num)
+
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		return decodeStruct(nestedFields(field), object, v, path)
+
+	case reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		itemField := arrayItemField(field, v.Type().Elem())
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(itemField, item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return mismatch
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(object))
+		for key, item := range object {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeValue(findField(nestedFields(field), key), item, elem, joinPath(path, key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+// decodeNumber converts a submitted number, or a string holding one, into a float64
+func decodeNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		num, err := n.Float64()
+		return num, err == nil
+	}
+	num, ok := coerceValue(string(OptionValueTypeNumber), value)
+	if !ok {
+		return 0, false
+	}
+	return num.(float64), true
+}
+
+// decodeTime parses the value of a date, time, or datetime field. Numbers are read as Unix
+// timestamps in seconds.
+func decodeTime(field *Field, value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		seconds, fraction := math.Modf(v)
+		return time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC(), nil
+	case string:
+		layouts := datetimeLayouts
+		if field != nil {
+			switch field.Type {
+			case FieldTypeDate:
+				layouts = dateLayouts
+			case FieldTypeTime:
+				layouts = timeLayouts
+			}
+		}
+		str := strings.TrimSpace(v)
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, str); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse time %q", v)
+	}
+	return time.Time{}, fmt.Errorf("cannot decode %T into time.Time", value)
+}
+
+// arrayItemField returns the field describing the items of an array: a group of the item
+// fields for objects, or the single item template for other values
+func arrayItemField(field *Field, elem reflect.Type) *Field {
+	if field == nil {
+		return nil
+	}
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Struct && elem != timeType {
+		return &Field{ID: field.ID, Type: FieldTypeGroup, Nested: field.Nested}
+	}
+	if len(field.Nested) == 1 {
+		return field.Nested[0]
+	}
+	return nil
+}
+
+// allocate returns the value a pointer points to, allocating it if it is nil
+func allocate(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Ptr {
+		return v
+	}
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	return allocate(v.Elem())
+}
+
+// findField finds a field among fields by ID
+func findField(fields []*Field, id string) *Field {
+	for _, field := range fields {
+		if field.ID == id {
+			return field
+		}
+	}
+	return nil
+}
+
+// nestedFields returns the nested fields of a field that may be nil
+func nestedFields(field *Field) []*Field {
+	if field == nil {
+		return nil
+	}
+	return field.Nested
+}
@@ -0,0 +1,123 @@
+package smartform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodedClaim struct {
+	ID       string            `smartform:"id=claimId"`
+	Amount   float64           `json:"amount"`
+	Nights   int               `json:"nights"`
+	Urgent   bool              `json:"urgent"`
+	Incident time.Time         `json:"incident"`
+	Reported *time.Time        `json:"reported"`
+	Claimant *decodedClaimant  `json:"claimant"`
+	Items    []decodedItem     `json:"items"`
+	Tags     []string          `json:"tags"`
+	Extra    map[string]string `json:"extra"`
+	Notes    interface{}       `json:"notes"`
+	Ignored  string            `smartform:"-"`
+	structFormAudit
+}
+
+type decodedClaimant struct {
+	Name string `json:"name"`
+	Age  uint8  `json:"age"`
+}
+
+type decodedItem struct {
+	Label string  `json:"label"`
+	Price float64 `json:"price"`
+}
+
+func TestDecode(t *testing.T) {
+	schema := NewForm("claim", "Claim").
+		AddField(NewFieldBuilder("claimId", FieldTypeText, "Claim").Build()).
+		AddField(NewFieldBuilder("amount", FieldTypeNumber, "Amount").Build()).
+		AddField(NewFieldBuilder("incident", FieldTypeDate, "Incident").Build()).
+		AddField(NewFieldBuilder("reported", FieldTypeDateTime, "Reported").Build()).
+		AddField(NewGroupFieldBuilder("claimant", "Claimant").
+			AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+			AddField(NewFieldBuilder("age", FieldTypeNumber, "Age").Build()).
+			Build()).
+		AddField(NewArrayFieldBuilder("items", "Items").
+			ItemTemplate(NewFieldBuilder("label", FieldTypeText, "Label").Build()).
+			ItemTemplate(NewFieldBuilder("price", FieldTypeNumber, "Price").Build()).
+			Build()).
+		Build()
+
+	var claim decodedClaim
+	require.NoError(t, schema.Decode(map[string]interface{}{
+		"claimId":   float64(42),
+		"amount":    "199.5",
+		"nights":    float64(3),
+		"urgent":    "true",
+		"incident":  "2024-03-01",
+		"reported":  "2024-03-02T09:30",
+		"claimant":  map[string]interface{}{"name": "Ada", "age": float64(36)},
+		"items":     []interface{}{map[string]interface{}{"label": "Taxi", "price": float64(20)}},
+		"tags":      []interface{}{"travel", "medical"},
+		"extra":     map[string]interface{}{"ref": "A-1"},
+		"notes":     []interface{}{"x"},
+		"Ignored":   "x",
+		"createdAt": "2024-01-01T00:00:00Z",
+		"unknown":   "x",
+	}, &claim))
+
+	assert.Equal(t, "42", claim.ID)
+	assert.Equal(t, 199.5, claim.Amount)
+	assert.Equal(t, 3, claim.Nights)
+	assert.True(t, claim.Urgent)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), claim.Incident)
+	require.NotNil(t, claim.Reported)
+	assert.Equal(t, time.Date(2024, 3, 2, 9, 30, 0, 0, time.UTC), *claim.Reported)
+	require.NotNil(t, claim.Claimant)
+	assert.Equal(t, decodedClaimant{Name: "Ada", Age: 36}, *claim.Claimant)
+	assert.Equal(t, []decodedItem{{Label: "Taxi", Price: 20}}, claim.Items)
+	assert.Equal(t, []string{"travel", "medical"}, claim.Tags)
+	assert.Equal(t, map[string]string{"ref": "A-1"}, claim.Extra)
+	assert.Equal(t, []interface{}{"x"}, claim.Notes)
+	assert.Empty(t, claim.Ignored)
+	assert.Equal(t, 2024, claim.CreatedAt.Year())
+}
+
+func TestDecodeErrors(t *testing.T) {
+	schema := NewForm("claim", "Claim").Build()
+	var claim decodedClaim
+
+	assert.EqualError(t, schema.Decode(map[string]interface{}{"nights": 2.5}, &claim),
+		"nights: cannot decode float64 into int")
+	assert.EqualError(t, schema.Decode(map[string]interface{}{"claimant": map[string]interface{}{"age": float64(300)}}, &claim),
+		"claimant.age: cannot decode float64 into uint8")
+	assert.EqualError(t, schema.Decode(map[string]interface{}{"items": []interface{}{"taxi"}}, &claim),
+		"items[0]: cannot decode string into smartform.decodedItem")
+	assert.EqualError(t, schema.Decode(map[string]interface{}{"incident": "yesterday"}, &claim),
+		`incident: cannot parse time "yesterday"`)
+	assert.Error(t, schema.Decode(map[string]interface{}{}, claim))
+}
+
+func TestDecodeFromStructForm(t *testing.T) {
+	builder, err := FromStruct("signup", "Sign up", structFormSignup{})
+	require.NoError(t, err)
+	schema := builder.Build()
+
+	data := map[string]interface{}{
+		"email":    "ada@example.com",
+		"age":      "42",
+		"terms":    true,
+		"address":  map[string]interface{}{"street": "1 Main St", "town": "London"},
+		"contacts": []interface{}{map[string]interface{}{"street": "2 High St"}},
+		"tags":     []interface{}{"a"},
+	}
+	var signup structFormSignup
+	require.NoError(t, schema.Decode(data, &signup))
+	assert.Equal(t, "ada@example.com", signup.Email)
+	assert.Equal(t, 42, signup.Age)
+	assert.Equal(t, "London", signup.Address.City)
+	assert.Equal(t, "2 High St", signup.Contacts[0].Street)
+	assert.Equal(t, []string{"a"}, signup.Tags)
+}
@@ -0,0 +1,319 @@
+package smartform
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StructTag is the struct tag FromStruct reads field settings from
+const StructTag = "smartform"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FromStruct builds a form from the exported fields of a struct, or a pointer to one.
+// Each field is configured by a tag such as `smartform:"label=Email,required,type=email"`,
+// and a tag of "-" skips the field. The options are:
+//
+//	id=<id>                field ID, defaulting to the json tag name or the lowerCamel field name
+//	label=<text>           label, defaulting to the field name split into words
+//	type=<field type>      field type, inferred from the Go type when omitted
+//	required               mark the field as required
+//	placeholder=<text>     placeholder
+//	help=<text>            help text
+//	default=<value>        default value, parsed according to the Go type
+//	options=<a|b|c>        static options
+//	min=, max=             minimum and maximum value
+//	minLength=, maxLength= minimum and maximum length
+//	pattern=<regexp>       pattern the value must match
+//	order=<n>              display order
+//
+// Commas inside values are escaped as "\,". Strings become text fields, numbers number
+// fields, bools checkboxes, and time.Time datetime fields. Nested structs become group
+// fields, slices array fields, and the fields of embedded structs are added to the parent.
+// The returned builder can be used to configure the form further.
+func FromStruct(id, title string, value interface{}) (*FormBuilder, error) {
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("FromStruct needs a struct, got %T", value)
+	}
+
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, err
+	}
+	return NewForm(id, title).AddFields(fields...), nil
+}
+
+// structFields builds the fields of a struct type
+func structFields(t reflect.Type) ([]*Field, error) {
+	fields := []*Field{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup(StructTag)
+		if tag == "-" {
+			continue
+		}
+
+		fieldType := sf.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// Embedded structs are flattened unless they are tagged like an ordinary field
+		if sf.Anonymous && !hasTag && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			embedded, err := structFields(fieldType)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		field, err := structField(sf, fieldType, tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// structField builds the field for a struct field from its Go type and tag
+func structField(sf reflect.StructField, t reflect.Type, tag string) (*Field, error) {
+	options, err := parseStructTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	id := structFieldID(sf, options)
+	label := humanizeFieldName(sf.Name)
+	for _, option := range options {
+		if option.key == "label" {
+			label = option.value
+		}
+	}
+
+	var builder *FieldBuilder
+	switch {
+	case t.Kind() == reflect.Struct && t != timeType:
+		nested, err := structFields(t)
+		if err != nil {
+			return nil, err
+		}
+		group := NewGroupFieldBuilder(id, label)
+		for _, field := range nested {
+			group.AddField(field)
+		}
+		builder = &group.FieldBuilder
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8:
+		array := NewArrayFieldBuilder(id, label)
+		item := t.Elem()
+		for item.Kind() == reflect.Ptr {
+			item = item.Elem()
+		}
+		if item.Kind() == reflect.Struct && item != timeType {
+			nested, err := structFields(item)
+			if err != nil {
+				return nil, err
+			}
+			for _, field := range nested {
+				array.ItemTemplate(field)
+			}
+		} else {
+			array.ItemTemplate(NewFieldBuilder("item", inferFieldType(item), "").Build())
+		}
+		builder = &array.FieldBuilder
+	default:
+		builder = NewFieldBuilder(id, inferFieldType(t), label)
+	}
+
+	for _, option := range options {
+		if err := applyStructTagOption(builder, t, option.key, option.value); err != nil {
+			return nil, err
+		}
+	}
+	return builder.Build(), nil
+}
+
+// applyStructTagOption applies a single tag option to a field
+func applyStructTagOption(builder *FieldBuilder, t reflect.Type, key, value string) error {
+	switch key {
+	case "id", "label":
+		// Applied when the field was created
+	case "type":
+		builder.field.Type = FieldType(value)
+	case "required":
+		builder.Required(true)
+	case "placeholder":
+		builder.Placeholder(value)
+	case "help":
+		builder.HelpText(value)
+	case "default":
+		defaultValue, err := parseStructTagValue(t, value)
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", value, err)
+		}
+		builder.DefaultValue(defaultValue)
+	case "options":
+		options := []*Option{}
+		for _, option := range strings.Split(value, "|") {
+			options = append(options, NewOption(option, option))
+		}
+		builder.WithStaticOptions(options)
+	case "min", "max", "minLength", "maxLength":
+		limit, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+		switch key {
+		case "min":
+			builder.ValidateMin(limit, fmt.Sprintf("Must be at least %v", limit))
+		case "max":
+			builder.ValidateMax(limit, fmt.Sprintf("Must be at most %v", limit))
+		case "minLength":
+			builder.ValidateMinLength(limit, fmt.Sprintf("Must be at least %v characters", limit))
+		case "maxLength":
+			builder.ValidateMaxLength(limit, fmt.Sprintf("Must be at most %v characters", limit))
+		}
+	case "pattern":
+		builder.ValidatePattern(value, "Invalid format")
+	case "order":
+		order, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid order %q", value)
+		}
+		builder.Order(order)
+	default:
+		return fmt.Errorf("unknown tag option %q", key)
+	}
+	return nil
+}
+
+// structTagOption is an option of a smartform tag. Flags such as "required" have an empty value.
+type structTagOption struct {
+	key   string
+	value string
+}
+
+// parseStructTag splits a tag into its options in order, unescaping "\," in values
+func parseStructTag(tag string) ([]structTagOption, error) {
+	options := []structTagOption{}
+	seen := make(map[string]bool)
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			part.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(tag[i])
+		}
+	}
+	parts = append(parts, part.String())
+
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate tag option %q", key)
+		}
+		seen[key] = true
+		options = append(options, structTagOption{key: key, value: value})
+	}
+	return options, nil
+}
+
+// parseStructTagValue parses a tag value according to a Go type
+func parseStructTagValue(t reflect.Type, value string) (interface{}, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
+
+// inferFieldType picks the field type for a Go type
+func inferFieldType(t reflect.Type) FieldType {
+	if t == timeType {
+		return FieldTypeDateTime
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return FieldTypeCheckbox
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return FieldTypeNumber
+	case reflect.Map, reflect.Interface:
+		return FieldTypeObject
+	default:
+		return FieldTypeText
+	}
+}
+
+// structFieldID returns the ID of the form field of a struct field, from its id tag option,
+// its json tag, or its name
+func structFieldID(sf reflect.StructField, options []structTagOption) string {
+	for _, option := range options {
+		if option.key == "id" {
+			return option.value
+		}
+	}
+	return jsonFieldName(sf)
+}
+
+// jsonFieldName returns the name a struct field is encoded with by encoding/json, or its name
+// in lowerCamel case
+func jsonFieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	runes := []rune(sf.Name)
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		// Keep the last capital of a leading acronym, as in "URLPath" -> "urlPath"
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// humanizeFieldName splits a Go field name into words, e.g. "FirstName" -> "First Name"
+func humanizeFieldName(name string) string {
+	runes := []rune(name)
+	var words strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			words.WriteByte(' ')
+		}
+		if r == '_' {
+			r = ' '
+		}
+		words.WriteRune(r)
+	}
+	return words.String()
+}
@@ -0,0 +1,130 @@
+package smartform
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type structFormAudit struct {
+	CreatedAt time.Time `smartform:"label=Created"`
+}
+
+type structFormAddress struct {
+	Street string `smartform:"required"`
+	City   string `json:"town"`
+}
+
+type structFormSignup struct {
+	structFormAudit
+	Email    string              `json:"email" smartform:"label=Email,required,type=email,placeholder=you@example.com"`
+	Age      int                 `smartform:"min=18,max=120,default=30"`
+	Plan     string              `smartform:"options=free|pro,default=free"`
+	Note     string              `smartform:"help=Shown to admins\\, not users,maxLength=200"`
+	Terms    bool                `smartform:"required"`
+	Address  *structFormAddress  `smartform:"label=Home address"`
+	Contacts []structFormAddress ``
+	Tags     []string            ``
+	Secret   string              `smartform:"-"`
+	internal string
+}
+
+func TestFromStruct(t *testing.T) {
+	builder, err := FromStruct("signup", "Sign up", &structFormSignup{})
+	require.NoError(t, err)
+	schema := builder.Build()
+
+	ids := []string{}
+	for _, field := range schema.Fields {
+		ids = append(ids, field.ID)
+	}
+	assert.Equal(t, []string{"createdAt", "email", "age", "plan", "note", "terms", "address", "contacts", "tags"}, ids)
+
+	created := schema.FindFieldByID("createdAt")
+	assert.Equal(t, FieldTypeDateTime, created.Type)
+	assert.Equal(t, "Created", created.Label)
+
+	email := schema.FindFieldByID("email")
+	assert.Equal(t, FieldTypeEmail, email.Type)
+	assert.Equal(t, "Email", email.Label)
+	assert.True(t, email.Required)
+	assert.Equal(t, "you@example.com", email.Placeholder)
+
+	age := schema.FindFieldByID("age")
+	assert.Equal(t, FieldTypeNumber, age.Type)
+	assert.Equal(t, float64(30), age.DefaultValue)
+	require.Len(t, age.ValidationRules, 2)
+	assert.Equal(t, ValidationTypeMin, age.ValidationRules[0].Type)
+	assert.Equal(t, ValidationTypeMax, age.ValidationRules[1].Type)
+
+	plan := schema.FindFieldByID("plan")
+	require.Len(t, plan.Options.Static, 2)
+	assert.Equal(t, "pro", plan.Options.Static[1].Value)
+
+	assert.Equal(t, "Shown to admins, not users", schema.FindFieldByID("note").HelpText)
+	assert.Equal(t, FieldTypeCheckbox, schema.FindFieldByID("terms").Type)
+
+	address := schema.FindFieldByID("address")
+	assert.Equal(t, FieldTypeGroup, address.Type)
+	assert.Equal(t, "Home address", address.Label)
+	require.Len(t, address.Nested, 2)
+	assert.Equal(t, "street", address.Nested[0].ID)
+	assert.True(t, address.Nested[0].Required)
+	assert.Equal(t, "town", address.Nested[1].ID)
+	assert.Equal(t, "City", address.Nested[1].Label)
+
+	contacts := schema.FindFieldByID("contacts")
+	assert.Equal(t, FieldTypeArray, contacts.Type)
+	require.Len(t, contacts.Nested, 2)
+	assert.Equal(t, "street", contacts.Nested[0].ID)
+
+	tags := schema.FindFieldByID("tags")
+	assert.Equal(t, FieldTypeArray, tags.Type)
+	require.Len(t, tags.Nested, 1)
+	assert.Equal(t, FieldTypeText, tags.Nested[0].Type)
+
+	// Generated forms validate like built ones
+	result := NewValidator(schema).ValidateForm(map[string]interface{}{"email": "ada@example.com", "age": float64(12)})
+	assert.False(t, result.Valid)
+	failed := map[string]bool{}
+	for _, validationErr := range result.Errors {
+		failed[validationErr.FieldID] = true
+	}
+	assert.True(t, failed["age"])
+	assert.True(t, failed["terms"])
+	assert.False(t, failed["email"])
+}
+
+func TestFromStructErrors(t *testing.T) {
+	_, err := FromStruct("form", "Form", "not a struct")
+	assert.Error(t, err)
+
+	_, err = FromStruct("form", "Form", struct {
+		Age int `smartform:"min=young"`
+	}{})
+	assert.EqualError(t, err, `field Age: invalid min "young"`)
+
+	_, err = FromStruct("form", "Form", struct {
+		Name string `smartform:"colour=red"`
+	}{})
+	assert.EqualError(t, err, `field Name: unknown tag option "colour"`)
+
+	_, err = FromStruct("form", "Form", struct {
+		Address structFormAddress
+		Owner   struct {
+			Name string `smartform:"required,required"`
+		}
+	}{})
+	assert.EqualError(t, err, `field Owner: field Name: duplicate tag option "required"`)
+}
+
+func TestStructFieldNames(t *testing.T) {
+	assert.Equal(t, "First Name", humanizeFieldName("FirstName"))
+	assert.Equal(t, "Home URL", humanizeFieldName("HomeURL"))
+	assert.Equal(t, "URL Path", humanizeFieldName("URLPath"))
+	assert.Equal(t, "urlPath", jsonFieldName(reflect.StructField{Name: "URLPath"}))
+	assert.Equal(t, "id", jsonFieldName(reflect.StructField{Name: "ID"}))
+}
@@ -0,0 +1,318 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// SetConfirmationStore sets the store submissions awaiting confirmation are parked in
+func (ah *APIHandler) SetConfirmationStore(store *ConfirmationStore) {
+	ah.confirmations = store
+}
+
+// StaffAuthorizer identifies the staff member making a back-office request. It returns
+// false when the request is not from authorized staff.
+type StaffAuthorizer func(r *http.Request) (actor string, ok bool)
+
+// SetSubmissionStore sets the store submissions are saved to
+func (ah *APIHandler) SetSubmissionStore(store SubmissionStore) {
+	ah.submissionStore = store
+}
+
+// SetStaffAuthorizer sets the function that authorizes back-office access to stored submissions.
+// Without one, the submission review endpoints reject every request.
+func (ah *APIHandler) SetStaffAuthorizer(authorizer StaffAuthorizer) {
+	ah.staffAuthorizer = authorizer
+}
+
+// authorizeStaff checks that a request comes from authorized staff and returns the actor
+func (ah *APIHandler) authorizeStaff(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if ah.submissionStore == nil {
+		writeError(w, notConfigured(http.StatusNotImplemented, "Submission storage is not configured"))
+		return "", false
+	}
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return "", false
+	}
+	actor, ok := ah.staffAuthorizer(r)
+	if !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return "", false
+	}
+	return actor, true
+}
+
+// handleSubmissions handles requests to list the stored submissions of a form
+func (ah *APIHandler) handleSubmissions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+	if _, ok := ah.authorizeStaff(w, r); !ok {
+		return
+	}
+
+	submissions, err := ah.submissionStore.List(r.URL.Query().Get("formId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []*Submission{}
+		for _, submission := range submissions {
+			if string(submission.Status) == status {
+				filtered = append(filtered, submission)
+			}
+		}
+		submissions = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(submissions)
+}
+
+// handleSubmission handles requests for a stored submission and its triage state
+func (ah *APIHandler) handleSubmission(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(getPathParam(r.URL.Path, "/api/submissions/"))
+	if len(segments) == 0 {
+		writeError(w, badRequest("Submission ID is required"))
+		return
+	}
+	actor, ok := ah.authorizeStaff(w, r)
+	if !ok {
+		return
+	}
+	id := segments[0]
+
+	resource := ""
+	if len(segments) > 1 {
+		resource = segments[1]
+	}
+
+	switch {
+	case resource == "" && r.Method == http.MethodGet:
+		submission, err := ah.submissionStore.Get(id)
+		ah.writeSubmission(w, submission, err)
+
+	case resource == "history" && r.Method == http.MethodGet:
+		submission, err := ah.submissionStore.Get(id)
+		if err != nil {
+			ah.writeSubmission(w, nil, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(submission.History)
+
+	case resource == "status" && r.Method == http.MethodPut:
+		var request struct {
+			Status SubmissionStatus `json:"status"`
+		}
+		if err := decodeRequest(r.Body, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if request.Status == "" {
+			writeRequestError(w, missingFieldError("status"))
+			return
+		}
+		submission, err := ah.submissionStore.Update(id, func(submission *Submission) error {
+			submission.SetStatus(actor, request.Status)
+			return nil
+		})
+		ah.writeSubmission(w, submission, err)
+
+	case resource == "notes" && r.Method == http.MethodPost:
+		var request struct {
+			Text string `json:"text"`
+		}
+		if err := decodeRequest(r.Body, &request); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if request.Text == "" {
+			writeRequestError(w, missingFieldError("text"))
+			return
+		}
+		submission, err := ah.submissionStore.Update(id, func(submission *Submission) error {
+			submission.AddNote(actor, request.Text)
+			return nil
+		})
+		ah.writeSubmission(w, submission, err)
+
+	case resource == "annotations" && r.Method == http.MethodPatch:
+		// A null value removes the annotation
+		var annotations map[string]interface{}
+		if err := decodeRequest(r.Body, &annotations); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		submission, err := ah.submissionStore.Update(id, func(submission *Submission) error {
+			for key, value := range annotations {
+				submission.Annotate(actor, key, value)
+			}
+			return nil
+		})
+		ah.writeSubmission(w, submission, err)
+
+	case resource == "" || resource == "history" || resource == "status" || resource == "notes" || resource == "annotations":
+		writeError(w, ErrMethodNotAllowed)
+
+	default:
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+	}
+}
+
+// writeSubmission writes a submission or the error that occurred loading it
+func (ah *APIHandler) writeSubmission(w http.ResponseWriter, submission *Submission, err error) {
+	if errors.Is(err, ErrSubmissionNotFound) {
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Submission not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(submission)
+}
+
+// parkSubmission holds a validated submission and sends the code that confirms it
+func (ah *APIHandler) parkSubmission(w http.ResponseWriter, r *http.Request, schema *FormSchema, formData map[string]interface{}, parent *ParentContext) {
+	if ah.notifier == nil {
+		writeError(w, notConfigured(http.StatusInternalServerError, "Submission confirmation is not configured"))
+		return
+	}
+
+	to, err := confirmationRecipient(schema, formData)
+	if err != nil {
+		writeError(w, badRequest(err.Error()))
+		return
+	}
+	if retryAfter, ok := ah.confirmations.allowRecipient(to); !ok {
+		writeRateLimited(w, retryAfter, "Too many confirmations were sent to this address")
+		return
+	}
+
+	pending, code, err := ah.confirmations.Park(schema.Confirmation, schema.ID, formData, parent)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	notification, err := confirmationNotification(schema, pending, code)
+	if err == nil {
+		err = ah.notifier.Notify(r.Context(), notification)
+	}
+	if err != nil {
+		writeError(w, NewAPIError(http.StatusBadGateway, ErrorCodeUpstreamFailed, fmt.Sprintf("Error sending confirmation: %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"status":       "pendingConfirmation",
+		"message":      "Check your " + schema.Confirmation.Channel + " to confirm the submission",
+		"formId":       schema.ID,
+		"submissionId": pending.ID,
+	})
+}
+
+// handleConfirmSubmission handles requests to confirm a parked submission. The code is
+// read from the JSON body ({"code": "..."}), the "code" field of a form post, or the "code"
+// query parameter. Opening a magic link only shows a page that posts its code, so that mail
+// scanners and link previews fetching the link do not confirm the submission.
+func (ah *APIHandler) handleConfirmSubmission(w http.ResponseWriter, r *http.Request, id string) {
+	code := r.URL.Query().Get("code")
+
+	switch r.Method {
+	case http.MethodGet:
+		ah.writeConfirmationPage(w, r, code)
+		return
+	case http.MethodPost:
+		if code == "" && isFormPost(r) {
+			code = r.PostFormValue("code")
+		} else if code == "" {
+			var request struct {
+				Code string `json:"code"`
+			}
+			if err := decodeRequest(r.Body, &request); err != nil {
+				writeRequestError(w, err)
+				return
+			}
+			code = request.Code
+		}
+	default:
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	if code == "" {
+		writeError(w, badRequest("Confirmation code is required"))
+		return
+	}
+
+	formID, ok := ah.confirmations.FormID(id)
+	if !ok {
+		writeError(w, ErrConfirmationNotFound)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok || schema.Confirmation == nil {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+
+	pending, err := ah.confirmations.Confirm(id, code, schema.Confirmation.maxAttempts())
+	switch {
+	case errors.Is(err, ErrConfirmationNotFound):
+		writeError(w, statusError(http.StatusNotFound, err))
+		return
+	case errors.Is(err, ErrConfirmationInvalid):
+		writeError(w, statusError(http.StatusForbidden, err))
+		return
+	case err != nil:
+		writeError(w, err)
+		return
+	}
+
+	ah.finalizeSubmission(w, &SubmissionContext{
+		Context:  r.Context(),
+		Request:  r,
+		FormID:   schema.ID,
+		Schema:   schema,
+		Data:     pending.Data,
+		Parent:   pending.Parent,
+		Response: map[string]interface{}{},
+	})
+}
+
+// writeConfirmationPage writes the page a magic link opens, which confirms the submission when
+// its button is pressed. With CSRF protection, the page carries the caller's CSRF token.
+func (ah *APIHandler) writeConfirmationPage(w http.ResponseWriter, r *http.Request, code string) {
+	if code == "" {
+		writeError(w, badRequest("Confirmation code is required"))
+		return
+	}
+	page := struct{ Code, CSRFField, CSRFToken string }{Code: code}
+	if ah.csrf != nil {
+		secret, err := ah.csrf.issue(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		page.CSRFField, page.CSRFToken = CSRFFormField, ah.csrf.token(secret)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = confirmationPage.Execute(w, page)
+}
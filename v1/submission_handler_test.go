@@ -0,0 +1,67 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionTriageAPI(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("message", FieldTypeText, "Message").Build()).
+		Build()))
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		user := r.Header.Get("X-Staff")
+		return user, user != ""
+	})
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	do := func(method, path, body, staff string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if staff != "" {
+			req.Header.Set("X-Staff", staff)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/submit/contact", `{"message": "hello"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var submitted map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &submitted))
+	id := submitted["submissionId"].(string)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/submissions/"+id, "", "").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPut, "/api/submissions/"+id+"/status", `{"status": "flagged"}`, "ana").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/submissions/"+id+"/status", `{"status": "lost"}`, "ana").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/submissions/"+id+"/notes", `{"text": "looks like spam"}`, "ana").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPatch, "/api/submissions/"+id+"/annotations", `{"priority": "high"}`, "ben").Code)
+
+	rec = do(http.MethodGet, "/api/submissions?formId=contact&status=flagged", "", "ben")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var submissions []*Submission
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &submissions))
+	require.Len(t, submissions, 1)
+
+	submission := submissions[0]
+	assert.Equal(t, SubmissionStatusFlagged, submission.Status)
+	assert.Equal(t, "looks like spam", submission.Notes[0].Text)
+	assert.Equal(t, "ana", submission.Notes[0].Author)
+	assert.Equal(t, "high", submission.Annotations["priority"])
+	require.Len(t, submission.History, 3)
+	assert.Equal(t, "status", submission.History[0].Action)
+	assert.Equal(t, "ben", submission.History[2].Actor)
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/submissions/missing", "", "ben").Code)
+}
@@ -0,0 +1,145 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// SubmissionStage names a point in the submission pipeline
+type SubmissionStage string
+
+// Stages of the submission pipeline, in the order they run
+const (
+	SubmissionStagePreValidate  SubmissionStage = "preValidate"  // Before validation; handlers may normalize Data
+	SubmissionStagePostValidate SubmissionStage = "postValidate" // After validation; handlers may add errors to Result
+	SubmissionStagePersist      SubmissionStage = "persist"      // Around saving to the submission store
+	SubmissionStageNotify       SubmissionStage = "notify"       // After the submission is persisted
+)
+
+// AllForms registers a submission handler for every form
+const AllForms = "*"
+
+// SubmissionContext carries a submission through the pipeline
+type SubmissionContext struct {
+	Context      context.Context
+	Request      *http.Request
+	Stage        SubmissionStage
+	FormID       string
+	Schema       *FormSchema
+	Data         map[string]interface{}
+	Parent       *ParentContext
+	Result       *ValidationResult      // Set from the postValidate stage
+	Output       interface{}            // Output mapping result, set from the persist stage
+	SubmissionID string                 // Set by the submission store or a persist handler
+	Response     map[string]interface{} // Extra fields added to the submit response
+	Job          *SubmissionJob         // Set when the submission is processed asynchronously
+}
+
+// SubmissionHandler handles a stage of the submission pipeline. Handlers are middleware:
+// they call next to continue the pipeline, may do work before and after it, and return an
+// error to stop the submission. In the persist stage, the last next saves the submission
+// to the handler's submission store, if any.
+type SubmissionHandler interface {
+	HandleSubmission(submission *SubmissionContext, next func() error) error
+}
+
+// SubmissionHandlerFunc adapts a function to the SubmissionHandler interface
+type SubmissionHandlerFunc func(submission *SubmissionContext, next func() error) error
+
+// HandleSubmission calls f
+func (f SubmissionHandlerFunc) HandleSubmission(submission *SubmissionContext, next func() error) error {
+	return f(submission, next)
+}
+
+// SubmissionRejection is returned by a submission handler to stop a submission with a status
+// code and a message for the client
+type SubmissionRejection struct {
+	Status  int
+	Message string
+}
+
+// Error implements the error interface
+func (sr *SubmissionRejection) Error() string {
+	return sr.Message
+}
+
+// UseSubmissionHandler adds a handler to a stage of the submission pipeline of a form, or of
+// every form with AllForms. Handlers for every form run before those of a specific form, and
+// handlers of the same form run in the order they were added.
+func (ah *APIHandler) UseSubmissionHandler(formID string, stage SubmissionStage, handler SubmissionHandler) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+
+	if ah.submissionHandlers == nil {
+		ah.submissionHandlers = make(map[string]map[SubmissionStage][]SubmissionHandler)
+	}
+	if ah.submissionHandlers[formID] == nil {
+		ah.submissionHandlers[formID] = make(map[SubmissionStage][]SubmissionHandler)
+	}
+	ah.submissionHandlers[formID][stage] = append(ah.submissionHandlers[formID][stage], handler)
+}
+
+// runSubmissionStage runs the handlers of a stage around final, which may be nil
+func (ah *APIHandler) runSubmissionStage(submission *SubmissionContext, stage SubmissionStage, final func() error) error {
+	ah.schemasLock.RLock()
+	handlers := append([]SubmissionHandler{}, ah.submissionHandlers[AllForms][stage]...)
+	if submission.FormID != AllForms {
+		handlers = append(handlers, ah.submissionHandlers[submission.FormID][stage]...)
+	}
+	ah.schemasLock.RUnlock()
+
+	submission.Stage = stage
+	var run func(i int) error
+	run = func(i int) error {
+		if i == len(handlers) {
+			if final == nil {
+				return nil
+			}
+			return final()
+		}
+		return handlers[i].HandleSubmission(submission, func() error {
+			return run(i + 1)
+		})
+	}
+	return run(0)
+}
+
+// writeSubmissionError writes the error that stopped a submission. Rejections and API errors
+// carry their own status; other errors get defaultStatus.
+func (ah *APIHandler) writeSubmissionError(w http.ResponseWriter, formID string, err error, defaultStatus int) {
+	status, response := submissionErrorResponse(formID, err, defaultStatus)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// submissionErrorResponse returns the status and body describing the error that stopped a
+// submission
+func submissionErrorResponse(formID string, err error, defaultStatus int) (int, map[string]interface{}) {
+	status := defaultStatus
+	code := errorCodeForStatus(status)
+	field := ""
+	var apiErr *APIError
+	var rejection *SubmissionRejection
+	switch {
+	case errors.As(err, &apiErr):
+		described := AsAPIError(apiErr)
+		status, code, field = described.Status, described.Code, described.Field
+	case errors.As(err, &rejection) && rejection.Status != 0:
+		status = rejection.Status
+		code = errorCodeForStatus(status)
+	}
+
+	response := map[string]interface{}{
+		"success": false,
+		"code":    code,
+		"message": err.Error(),
+		"formId":  formID,
+	}
+	if field != "" {
+		response["field"] = field
+	}
+	return status, response
+}
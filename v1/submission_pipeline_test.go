@@ -0,0 +1,96 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmissionPipeline(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		Build()))
+
+	var stages []string
+	var saved []map[string]interface{}
+	notifyFails := false
+
+	handler.UseSubmissionHandler(AllForms, SubmissionStagePreValidate, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		stages = append(stages, "all:"+string(s.Stage))
+		if s.Data["email"] == "blocked@example.com" {
+			return &SubmissionRejection{Status: http.StatusTooManyRequests, Message: "Try again later"}
+		}
+		return next()
+	}))
+	handler.UseSubmissionHandler("signup", SubmissionStagePreValidate, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		stages = append(stages, "signup:"+string(s.Stage))
+		if email, ok := s.Data["email"].(string); ok {
+			s.Data["email"] = strings.ToLower(email)
+		}
+		return next()
+	}))
+	handler.UseSubmissionHandler("signup", SubmissionStagePostValidate, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		if s.Data["email"] == "taken@example.com" {
+			s.Result.Errors = append(s.Result.Errors, &ValidationError{FieldID: "email", Message: "Email is taken", RuleType: "unique"})
+		}
+		return next()
+	}))
+	handler.UseSubmissionHandler("signup", SubmissionStagePersist, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		if err := next(); err != nil {
+			return err
+		}
+		// The store has assigned an ID by the time next returns
+		saved = append(saved, map[string]interface{}{"id": s.SubmissionID, "email": s.Data["email"]})
+		s.Response["accountId"] = "acct-1"
+		s.Response["success"] = "overridden"
+		return nil
+	}))
+	handler.UseSubmissionHandler("signup", SubmissionStageNotify, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		if notifyFails {
+			return errors.New("webhook unavailable")
+		}
+		return next()
+	}))
+
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+	submit := func(body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/signup", strings.NewReader(body)))
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		return rec.Code, response
+	}
+
+	code, response := submit(`{"email": "Ada@Example.com"}`)
+	require.Equal(t, http.StatusOK, code, response)
+	assert.Equal(t, []string{"all:preValidate", "signup:preValidate"}, stages)
+	assert.Equal(t, "ada@example.com", response["data"].(map[string]interface{})["email"])
+	assert.Equal(t, "acct-1", response["accountId"])
+	assert.Equal(t, true, response["success"])
+	require.Len(t, saved, 1)
+	assert.Equal(t, response["submissionId"], saved[0]["id"])
+
+	code, response = submit(`{"email": "taken@example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, false, response["valid"])
+	assert.Len(t, saved, 1)
+
+	code, response = submit(`{"email": "blocked@example.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+	assert.Equal(t, "Try again later", response["message"])
+
+	notifyFails = true
+	code, response = submit(`{"email": "grace@example.com"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "webhook unavailable", response["notifyError"])
+	assert.Len(t, saved, 2)
+}
@@ -0,0 +1,476 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AsyncQueryParam asks for a submission to be processed asynchronously, like the
+// "Prefer: respond-async" header
+const AsyncQueryParam = "async"
+
+// Defaults of the submission queue
+const (
+	DefaultSubmissionWorkers      = 4
+	DefaultSubmissionQueueSize    = 100
+	DefaultSubmissionMaxAttempts  = 3
+	DefaultSubmissionRetryDelay   = time.Second
+	DefaultSubmissionJobRetention = 24 * time.Hour
+
+	submissionJobSweepInterval = time.Minute
+)
+
+// SubmissionJobStatus is the state of an asynchronous submission
+type SubmissionJobStatus string
+
+// Submission job states
+const (
+	SubmissionJobQueued    SubmissionJobStatus = "queued"    // Waiting for a worker or for a retry
+	SubmissionJobRunning   SubmissionJobStatus = "running"   // Being processed
+	SubmissionJobSucceeded SubmissionJobStatus = "succeeded" // Processed; Result holds the submit response
+	SubmissionJobFailed    SubmissionJobStatus = "failed"    // Rejected, or out of attempts
+)
+
+var (
+	// ErrSubmissionJobNotFound is returned when a submission job does not exist
+	ErrSubmissionJobNotFound = errors.New("submission job not found")
+	// ErrSubmissionQueueFull is returned when a submission cannot be queued
+	ErrSubmissionQueueFull = errors.New("submission queue is full, try again later")
+)
+
+// SubmissionJob tracks a submission processed in the background
+type SubmissionJob struct {
+	ID            string                 `json:"id"`
+	FormID        string                 `json:"formId"`
+	Status        SubmissionJobStatus    `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	StatusCode    int                    `json:"statusCode,omitempty"` // Status the submit response would have had
+	Result        map[string]interface{} `json:"result,omitempty"`     // Body the submit response would have had
+	Error         string                 `json:"error,omitempty"`      // Error of the last failed attempt
+	CreatedAt     time.Time              `json:"createdAt"`
+	UpdatedAt     time.Time              `json:"updatedAt"`
+	NextAttemptAt *time.Time             `json:"nextAttemptAt,omitempty"`
+}
+
+// Done reports whether the job has finished
+func (j *SubmissionJob) Done() bool {
+	return j.Status == SubmissionJobSucceeded || j.Status == SubmissionJobFailed
+}
+
+// Clone returns a deep copy of the job
+func (j *SubmissionJob) Clone() *SubmissionJob {
+	if j == nil {
+		return nil
+	}
+	clone := *j
+	clone.Result = cloneMap(j.Result)
+	if j.NextAttemptAt != nil {
+		next := *j.NextAttemptAt
+		clone.NextAttemptAt = &next
+	}
+	return &clone
+}
+
+// SubmissionJobStore persists the status of submission jobs
+type SubmissionJobStore interface {
+	// Save stores a new job
+	Save(job *SubmissionJob) error
+	// Get loads a job by ID
+	Get(id string) (*SubmissionJob, error)
+	// Update applies a change to a stored job atomically
+	Update(id string, change func(job *SubmissionJob) error) (*SubmissionJob, error)
+}
+
+// MemorySubmissionJobStore keeps submission jobs in memory. Finished jobs are kept for a
+// retention period, then dropped as new jobs are saved, so that clients can poll them for a
+// while without the store growing with every submission ever queued.
+type MemorySubmissionJobStore struct {
+	jobs      map[string]*SubmissionJob
+	retention time.Duration
+	swept     time.Time
+	lock      sync.RWMutex
+}
+
+// NewMemorySubmissionJobStore creates a new in-memory submission job store, keeping finished
+// jobs for DefaultSubmissionJobRetention
+func NewMemorySubmissionJobStore() *MemorySubmissionJobStore {
+	return &MemorySubmissionJobStore{
+		jobs:      make(map[string]*SubmissionJob),
+		retention: DefaultSubmissionJobRetention,
+	}
+}
+
+// SetRetention sets how long finished jobs are kept after their last update
+func (ms *MemorySubmissionJobStore) SetRetention(retention time.Duration) *MemorySubmissionJobStore {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.retention = retention
+	return ms
+}
+
+// Save stores a new job
+func (ms *MemorySubmissionJobStore) Save(job *SubmissionJob) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.removeExpired()
+	ms.jobs[job.ID] = job.Clone()
+	return nil
+}
+
+// removeExpired drops jobs that finished longer than the retention period ago. The store is
+// swept at most once per submissionJobSweepInterval.
+func (ms *MemorySubmissionJobStore) removeExpired() {
+	now := time.Now()
+	if now.Sub(ms.swept) < submissionJobSweepInterval {
+		return
+	}
+	ms.swept = now
+	for id, job := range ms.jobs {
+		if job.Done() && now.Sub(job.UpdatedAt) >= ms.retention {
+			delete(ms.jobs, id)
+		}
+	}
+}
+
+// Get loads a job by ID
+func (ms *MemorySubmissionJobStore) Get(id string) (*SubmissionJob, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	job, ok := ms.jobs[id]
+	if !ok {
+		return nil, ErrSubmissionJobNotFound
+	}
+	return job.Clone(), nil
+}
+
+// Update applies a change to a stored job atomically
+func (ms *MemorySubmissionJobStore) Update(id string, change func(job *SubmissionJob) error) (*SubmissionJob, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	stored, ok := ms.jobs[id]
+	if !ok {
+		return nil, ErrSubmissionJobNotFound
+	}
+
+	updated := stored.Clone()
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+	ms.jobs[id] = updated
+	return updated.Clone(), nil
+}
+
+// SubmissionQueueOptions configures asynchronous submission processing
+type SubmissionQueueOptions struct {
+	Workers     int                // Submissions processed at once (default 4)
+	QueueSize   int                // Submissions that may wait; more are rejected with 503 (default 100)
+	MaxAttempts int                // Attempts before a job fails (default 3)
+	RetryDelay  time.Duration      // Delay before the first retry, doubled after each attempt (default 1s)
+	Store       SubmissionJobStore // Where job status is kept (default in memory, keeping finished jobs for DefaultSubmissionJobRetention)
+}
+
+// SubmissionJobListener is told about every change of a job's status, for instance to call a
+// webhook when a job finishes
+type SubmissionJobListener func(job *SubmissionJob)
+
+// submissionQueue is a pool of workers processing queued submissions
+type submissionQueue struct {
+	options SubmissionQueueOptions
+	pending chan *queuedSubmission
+	workers sync.WaitGroup
+	closed  bool
+	lock    sync.RWMutex
+}
+
+// queuedSubmission is a validated submission waiting to be processed
+type queuedSubmission struct {
+	jobID      string
+	submission *SubmissionContext
+}
+
+// StartSubmissionQueue starts a pool of workers that process submissions in the background.
+// While it runs, forms marked with AsyncSubmit, and submit requests carrying "?async=true" or a
+// "Prefer: respond-async" header, are answered with 202 Accepted and a job to poll at
+// /api/jobs/{id}. Attempts that fail with a server error are retried with backoff. A queue
+// that is already running is stopped first.
+func (ah *APIHandler) StartSubmissionQueue(options SubmissionQueueOptions) {
+	ah.StopSubmissionQueue()
+
+	if options.Workers <= 0 {
+		options.Workers = DefaultSubmissionWorkers
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultSubmissionQueueSize
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultSubmissionMaxAttempts
+	}
+	if options.RetryDelay <= 0 {
+		options.RetryDelay = DefaultSubmissionRetryDelay
+	}
+	if options.Store == nil {
+		options.Store = NewMemorySubmissionJobStore()
+	}
+
+	queue := &submissionQueue{
+		options: options,
+		pending: make(chan *queuedSubmission, options.QueueSize),
+	}
+	for i := 0; i < options.Workers; i++ {
+		queue.workers.Add(1)
+		go func() {
+			defer queue.workers.Done()
+			for queued := range queue.pending {
+				ah.processSubmissionJob(queue, queued)
+			}
+		}()
+	}
+
+	ah.schemasLock.Lock()
+	ah.submissionQueue = queue
+	ah.schemasLock.Unlock()
+}
+
+// StopSubmissionQueue stops accepting asynchronous submissions and waits until the queued ones
+// have been processed
+func (ah *APIHandler) StopSubmissionQueue() {
+	ah.schemasLock.Lock()
+	queue := ah.submissionQueue
+	ah.submissionQueue = nil
+	ah.schemasLock.Unlock()
+
+	if queue == nil {
+		return
+	}
+	queue.lock.Lock()
+	queue.closed = true
+	close(queue.pending)
+	queue.lock.Unlock()
+	queue.workers.Wait()
+}
+
+// UseSubmissionJobListener adds a listener told about every change of a job's status
+func (ah *APIHandler) UseSubmissionJobListener(listener SubmissionJobListener) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.submissionJobListeners = append(ah.submissionJobListeners, listener)
+}
+
+// GetSubmissionJob loads a submission job by ID
+func (ah *APIHandler) GetSubmissionJob(id string) (*SubmissionJob, error) {
+	queue := ah.currentSubmissionQueue()
+	if queue == nil {
+		return nil, ErrSubmissionJobNotFound
+	}
+	return queue.options.Store.Get(id)
+}
+
+// currentSubmissionQueue returns the running submission queue, if any
+func (ah *APIHandler) currentSubmissionQueue() *submissionQueue {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+	return ah.submissionQueue
+}
+
+// submitsAsync reports whether a submission should be queued rather than processed while the
+// client waits. Without a running queue, every submission is processed synchronously.
+func (ah *APIHandler) submitsAsync(submission *SubmissionContext) bool {
+	if ah.currentSubmissionQueue() == nil {
+		return false
+	}
+	if submission.Schema.AsyncSubmit {
+		return true
+	}
+	return submission.Request != nil && requestsAsync(submission.Request)
+}
+
+// requestsAsync reports whether a request asks to be answered before it is processed
+func requestsAsync(r *http.Request) bool {
+	if r.URL.Query().Get(AsyncQueryParam) == "true" {
+		return true
+	}
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enqueueSubmission queues a validated submission and answers with the job to poll
+func (ah *APIHandler) enqueueSubmission(w http.ResponseWriter, submission *SubmissionContext) {
+	queue := ah.currentSubmissionQueue()
+	formID := submission.Schema.ID
+
+	// The job outlives the request, so it keeps the request's values but not its cancellation
+	ctx := context.WithoutCancel(submission.Context)
+	submission.Context = ctx
+	if submission.Request != nil {
+		submission.Request = submission.Request.WithContext(ctx)
+	}
+
+	now := time.Now()
+	job := &SubmissionJob{
+		ID:        newID(),
+		FormID:    formID,
+		Status:    SubmissionJobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := queue.options.Store.Save(job); err != nil {
+		ah.writeSubmissionError(w, formID, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := queue.push(&queuedSubmission{jobID: job.ID, submission: submission}); err != nil {
+		ah.updateSubmissionJob(queue, job.ID, func(job *SubmissionJob) {
+			job.Status = SubmissionJobFailed
+			job.Error = err.Error()
+		})
+		w.Header().Set("Retry-After", "1")
+		ah.writeSubmissionError(w, formID, err, http.StatusServiceUnavailable)
+		return
+	}
+	ah.notifySubmissionJob(job)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Form submission accepted for processing",
+		"formId":  formID,
+		"jobId":   job.ID,
+		"status":  job.Status,
+	})
+}
+
+// push queues a submission without waiting for room
+func (sq *submissionQueue) push(queued *queuedSubmission) error {
+	sq.lock.RLock()
+	defer sq.lock.RUnlock()
+	if sq.closed {
+		return ErrSubmissionQueueFull
+	}
+	select {
+	case sq.pending <- queued:
+		return nil
+	default:
+		return ErrSubmissionQueueFull
+	}
+}
+
+// processSubmissionJob completes a queued submission, retrying attempts that fail with a
+// server error. Submission handlers see the job, so they can tell retries apart.
+func (ah *APIHandler) processSubmissionJob(queue *submissionQueue, queued *queuedSubmission) {
+	submission := queued.submission
+	delay := queue.options.RetryDelay
+	// Storing replaces the data with normalized, sealed, and encrypted values, so every attempt
+	// starts from a copy of the validated data
+	validated := submission.Data
+
+	for attempt := 1; ; attempt++ {
+		job := ah.updateSubmissionJob(queue, queued.jobID, func(job *SubmissionJob) {
+			job.Status = SubmissionJobRunning
+			job.Attempts = attempt
+			job.NextAttemptAt = nil
+		})
+
+		submission.Job = job
+		submission.Data = cloneMap(validated)
+		submission.Output = nil
+		submission.SubmissionID = ""
+		submission.Response = map[string]interface{}{}
+		status, response := ah.completeSubmission(submission)
+
+		if status < http.StatusInternalServerError || attempt >= queue.options.MaxAttempts {
+			ah.updateSubmissionJob(queue, queued.jobID, func(job *SubmissionJob) {
+				job.Status = SubmissionJobSucceeded
+				if status >= http.StatusBadRequest {
+					job.Status = SubmissionJobFailed
+					job.Error, _ = response["message"].(string)
+				}
+				job.StatusCode = status
+				job.Result = response
+			})
+			return
+		}
+
+		next := time.Now().Add(delay)
+		ah.updateSubmissionJob(queue, queued.jobID, func(job *SubmissionJob) {
+			job.Status = SubmissionJobQueued
+			job.Error, _ = response["message"].(string)
+			job.NextAttemptAt = &next
+		})
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// updateSubmissionJob changes a job and tells the listeners. It returns the updated job, or
+// nil if the store failed.
+func (ah *APIHandler) updateSubmissionJob(queue *submissionQueue, id string, change func(job *SubmissionJob)) *SubmissionJob {
+	job, err := queue.options.Store.Update(id, func(job *SubmissionJob) error {
+		change(job)
+		job.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	ah.notifySubmissionJob(job)
+	return job
+}
+
+// notifySubmissionJob tells the listeners about a change of a job's status
+func (ah *APIHandler) notifySubmissionJob(job *SubmissionJob) {
+	ah.schemasLock.RLock()
+	listeners := append([]SubmissionJobListener{}, ah.submissionJobListeners...)
+	ah.schemasLock.RUnlock()
+
+	for _, listener := range listeners {
+		listener(job.Clone())
+	}
+}
+
+// handleSubmissionJob handles requests for the status of an asynchronous submission
+func (ah *APIHandler) handleSubmissionJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	id := getPathParam(r.URL.Path, "/api/jobs/")
+	if id == "" {
+		writeError(w, badRequest("Job ID is required"))
+		return
+	}
+
+	job, err := ah.GetSubmissionJob(id)
+	if errors.Is(err, ErrSubmissionJobNotFound) {
+		writeError(w, statusError(http.StatusNotFound, err))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// AsyncSubmit makes submissions of the form be processed in the background when a submission
+// queue is running
+func (fb *FormBuilder) AsyncSubmit() *FormBuilder {
+	fb.schema.AsyncSubmit = true
+	return fb
+}
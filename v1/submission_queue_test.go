@@ -0,0 +1,197 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncSubmissionWithRetry(t *testing.T) {
+	encryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	submissions := NewMemorySubmissionStore()
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(submissions)
+	handler.SetFieldEncryptor(encryptor)
+	require.NoError(t, handler.RegisterSchema(NewForm("provision", "Provision").
+		AsyncSubmit().
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Required(true).Build()).
+		AddField(NewFieldBuilder("token", FieldTypeText, "Token").Sensitive(true).Build()).
+		AddField(NewSignatureFieldBuilder("approval", "Approval").Seal(true).Build()).
+		Build()))
+
+	var lock sync.Mutex
+	var attempts []int
+	var statuses []SubmissionJobStatus
+	handler.UseSubmissionHandler("provision", SubmissionStagePersist, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		lock.Lock()
+		attempts = append(attempts, s.Job.Attempts)
+		lock.Unlock()
+		if s.Job.Attempts == 1 {
+			return errors.New("provisioning backend unavailable")
+		}
+		return next()
+	}))
+	handler.UseSubmissionJobListener(func(job *SubmissionJob) {
+		lock.Lock()
+		statuses = append(statuses, job.Status)
+		lock.Unlock()
+	})
+
+	store := NewMemorySubmissionJobStore()
+	handler.StartSubmissionQueue(SubmissionQueueOptions{Workers: 1, RetryDelay: time.Millisecond, Store: store})
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	// Invalid data is still rejected while the client waits
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/provision", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/provision", strings.NewReader(`{"name": "db-1", "token": "s3cret", "approval": "`+signaturePNG(t, true)+`"}`)))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var accepted map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accepted))
+	jobID, _ := accepted["jobId"].(string)
+	require.NotEmpty(t, jobID)
+	assert.Equal(t, "/api/jobs/"+jobID, rec.Header().Get("Location"))
+
+	// Stopping the queue waits for the job to finish
+	handler.StopSubmissionQueue()
+	job, err := store.Get(jobID)
+	require.NoError(t, err)
+	assert.Equal(t, SubmissionJobSucceeded, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+	assert.NotEmpty(t, job.Result["submissionId"])
+
+	// Retries start from the validated data, so signatures are sealed over plain values
+	stored, err := submissions.Get(job.Result["submissionId"].(string))
+	require.NoError(t, err)
+	assert.NoError(t, handler.VerifySubmissionSignatures(stored))
+
+	_, err = handler.GetSubmissionJob(jobID)
+	assert.True(t, errors.Is(err, ErrSubmissionJobNotFound), "jobs are not served once the queue is stopped")
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Equal(t, []SubmissionJobStatus{
+		SubmissionJobQueued, SubmissionJobRunning, SubmissionJobQueued, SubmissionJobRunning, SubmissionJobSucceeded,
+	}, statuses)
+}
+
+func TestSubmissionJobPolling(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Signup").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Build()).
+		Build()))
+	handler.UseSubmissionHandler("signup", SubmissionStagePersist, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		if s.Data["email"] == "taken@example.com" {
+			return &SubmissionRejection{Status: http.StatusConflict, Message: "Email is taken"}
+		}
+		return next()
+	}))
+
+	store := NewMemorySubmissionJobStore()
+	handler.StartSubmissionQueue(SubmissionQueueOptions{Store: store, RetryDelay: time.Millisecond})
+	defer handler.StopSubmissionQueue()
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	submit := func(email string, async bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/submit/signup", strings.NewReader(`{"email": "`+email+`"}`))
+		if async {
+			req.Header.Set("Prefer", "respond-async")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	poll := func(id string) *SubmissionJob {
+		var job *SubmissionJob
+		require.Eventually(t, func() bool {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			job = &SubmissionJob{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), job))
+			return job.Done()
+		}, time.Second, time.Millisecond)
+		return job
+	}
+	jobID := func(rec *httptest.ResponseRecorder) string {
+		var accepted map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &accepted))
+		return accepted["jobId"].(string)
+	}
+
+	// Forms not marked async are only queued when the client asks
+	assert.Equal(t, http.StatusOK, submit("ada@example.com", false).Code)
+
+	rec := submit("ada@example.com", true)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	job := poll(jobID(rec))
+	assert.Equal(t, SubmissionJobSucceeded, job.Status)
+	assert.Equal(t, http.StatusOK, job.StatusCode)
+	assert.Equal(t, "Form submitted successfully", job.Result["message"])
+
+	// Rejections are not retried
+	rec = submit("taken@example.com", true)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	job = poll(jobID(rec))
+	assert.Equal(t, SubmissionJobFailed, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, http.StatusConflict, job.StatusCode)
+	assert.Equal(t, "Email is taken", job.Error)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/jobs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestMemorySubmissionJobStoreRetention(t *testing.T) {
+	store := NewMemorySubmissionJobStore().SetRetention(time.Hour)
+	now := time.Now()
+	jobs := []*SubmissionJob{
+		{ID: "finished", Status: SubmissionJobSucceeded, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "failed", Status: SubmissionJobFailed, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "waiting", Status: SubmissionJobQueued, UpdatedAt: now.Add(-2 * time.Hour)},
+		{ID: "recent", Status: SubmissionJobSucceeded, UpdatedAt: now.Add(-10 * time.Minute)},
+	}
+	for _, job := range jobs {
+		require.NoError(t, store.Save(job))
+	}
+
+	// Finished jobs past the retention are dropped when the store is next swept
+	store.swept = time.Time{}
+	require.NoError(t, store.Save(&SubmissionJob{ID: "new", Status: SubmissionJobQueued, UpdatedAt: now}))
+	for id, kept := range map[string]bool{"finished": false, "failed": false, "waiting": true, "recent": true, "new": true} {
+		_, err := store.Get(id)
+		if kept {
+			assert.NoError(t, err, id)
+		} else {
+			assert.True(t, errors.Is(err, ErrSubmissionJobNotFound), id)
+		}
+	}
+}
+
+func TestAsyncSubmissionWithoutQueue(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("provision", "Provision").AsyncSubmit().Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/provision?async=true", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,55 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// SubmissionStatus is the triage status of a stored submission
+type SubmissionStatus string
+
+// Define submission statuses
+const (
+	SubmissionStatusNew      SubmissionStatus = "new"      // Not yet looked at
+	SubmissionStatusReviewed SubmissionStatus = "reviewed" // Checked by staff
+	SubmissionStatusFlagged  SubmissionStatus = "flagged"  // Needs attention
+)
+
+// Values provides the possible values for SubmissionStatus, compatible with entgo.
+func (SubmissionStatus) Values() (types []string) {
+	return []string{
+		string(SubmissionStatusNew),
+		string(SubmissionStatusReviewed),
+		string(SubmissionStatusFlagged),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (s SubmissionStatus) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (s *SubmissionStatus) UnmarshalText(text []byte) error {
+	switch SubmissionStatus(text) {
+	case SubmissionStatusNew, SubmissionStatusReviewed, SubmissionStatusFlagged:
+		*s = SubmissionStatus(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid SubmissionStatus: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (s *SubmissionStatus) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("SubmissionStatus should be a string, got %T", value)
+	}
+	return s.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (s SubmissionStatus) Value() (driver.Value, error) {
+	return string(s), nil
+}
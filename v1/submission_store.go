@@ -0,0 +1,267 @@
+package smartform
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSubmissionNotFound is returned when a submission does not exist
+var ErrSubmissionNotFound = errors.New("submission not found")
+
+// Submission is a stored form submission together with its back-office triage state
+type Submission struct {
+	ID          string                 `json:"id"`
+	FormID      string                 `json:"formId"`
+	Data        map[string]interface{} `json:"data"`
+	Status      SubmissionStatus       `json:"status"`
+	Notes       []*SubmissionNote      `json:"notes,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+	History     []*SubmissionChange    `json:"history,omitempty"`
+	DuplicateOf string                 `json:"duplicateOf,omitempty"` // Earlier submission this one was linked to
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+}
+
+// SubmissionNote is an internal note attached to a submission by staff
+type SubmissionNote struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SubmissionChange is an entry in the change history of a submission's triage state
+type SubmissionChange struct {
+	Actor  string      `json:"actor"`
+	Action string      `json:"action"` // "status", "note", or "annotation"
+	Key    string      `json:"key,omitempty"`
+	From   interface{} `json:"from,omitempty"`
+	To     interface{} `json:"to,omitempty"`
+	At     time.Time   `json:"at"`
+}
+
+// SubmissionStore persists submissions
+type SubmissionStore interface {
+	// Save stores a new submission
+	Save(submission *Submission) error
+	// Get loads a submission by ID
+	Get(id string) (*Submission, error)
+	// List returns the submissions of a form, oldest first
+	List(formID string) ([]*Submission, error)
+	// Update applies a change to a stored submission atomically
+	Update(id string, change func(submission *Submission) error) (*Submission, error)
+}
+
+// MemorySubmissionStore keeps submissions in memory
+type MemorySubmissionStore struct {
+	submissions map[string]*Submission
+	unique      map[string]string // Holders of unique keys, by form, constraint, and value
+	lock        sync.RWMutex
+}
+
+// NewMemorySubmissionStore creates a new in-memory submission store
+func NewMemorySubmissionStore() *MemorySubmissionStore {
+	return &MemorySubmissionStore{
+		submissions: make(map[string]*Submission),
+		unique:      make(map[string]string),
+	}
+}
+
+// Save stores a new submission
+func (ms *MemorySubmissionStore) Save(submission *Submission) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.submissions[submission.ID] = submission.Clone()
+	return nil
+}
+
+// Get loads a submission by ID
+func (ms *MemorySubmissionStore) Get(id string) (*Submission, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	submission, ok := ms.submissions[id]
+	if !ok {
+		return nil, ErrSubmissionNotFound
+	}
+	return submission.Clone(), nil
+}
+
+// List returns the submissions of a form, oldest first
+func (ms *MemorySubmissionStore) List(formID string) ([]*Submission, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+
+	submissions := []*Submission{}
+	for _, submission := range ms.submissions {
+		if formID == "" || submission.FormID == formID {
+			submissions = append(submissions, submission.Clone())
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissions[i].CreatedAt.Before(submissions[j].CreatedAt)
+	})
+	return submissions, nil
+}
+
+// Update applies a change to a stored submission atomically
+func (ms *MemorySubmissionStore) Update(id string, change func(submission *Submission) error) (*Submission, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	stored, ok := ms.submissions[id]
+	if !ok {
+		return nil, ErrSubmissionNotFound
+	}
+
+	updated := stored.Clone()
+	if err := change(updated); err != nil {
+		return nil, err
+	}
+	ms.submissions[id] = updated
+	return updated.Clone(), nil
+}
+
+// Reserve claims unique keys of a form for a submission, all of them or none
+func (ms *MemorySubmissionStore) Reserve(formID, submissionID string, keys []UniqueKey) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	for _, key := range keys {
+		if holder, ok := ms.unique[uniqueIndexKey(formID, key)]; ok && holder != submissionID {
+			return &UniqueConflictError{Constraint: key.Constraint, SubmissionID: holder}
+		}
+	}
+	for _, key := range keys {
+		ms.unique[uniqueIndexKey(formID, key)] = submissionID
+	}
+	return nil
+}
+
+// Release frees the unique keys held by a submission
+func (ms *MemorySubmissionStore) Release(formID, submissionID string) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	prefix := formID + "\x00"
+	for key, holder := range ms.unique {
+		if holder == submissionID && strings.HasPrefix(key, prefix) {
+			delete(ms.unique, key)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the ID of the submission holding a unique key, or "" when the key is free
+func (ms *MemorySubmissionStore) Lookup(formID string, key UniqueKey) (string, error) {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	return ms.unique[uniqueIndexKey(formID, key)], nil
+}
+
+// uniqueIndexKey returns the key a unique key of a form is indexed by
+func uniqueIndexKey(formID string, key UniqueKey) string {
+	return formID + "\x00" + key.Constraint + "\x00" + key.Value
+}
+
+// NewSubmission creates a submission with a new ID and the "new" status
+func NewSubmission(formID string, data map[string]interface{}) *Submission {
+	now := time.Now()
+	return &Submission{
+		ID:        newID(),
+		FormID:    formID,
+		Data:      data,
+		Status:    SubmissionStatusNew,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Clone returns a deep copy of the submission
+func (s *Submission) Clone() *Submission {
+	if s == nil {
+		return nil
+	}
+
+	clone := *s
+	clone.Data = cloneMap(s.Data)
+	clone.Annotations = cloneMap(s.Annotations)
+	if s.Notes != nil {
+		clone.Notes = make([]*SubmissionNote, len(s.Notes))
+		for i, note := range s.Notes {
+			noteCopy := *note
+			clone.Notes[i] = &noteCopy
+		}
+	}
+	if s.History != nil {
+		clone.History = make([]*SubmissionChange, len(s.History))
+		for i, change := range s.History {
+			changeCopy := *change
+			changeCopy.From = cloneValue(change.From)
+			changeCopy.To = cloneValue(change.To)
+			clone.History[i] = &changeCopy
+		}
+	}
+	return &clone
+}
+
+// SetStatus changes the triage status and records the change
+func (s *Submission) SetStatus(actor string, status SubmissionStatus) {
+	if s.Status == status {
+		return
+	}
+	s.record(actor, "status", "", s.Status, status)
+	s.Status = status
+}
+
+// AddNote attaches an internal note and records the change
+func (s *Submission) AddNote(author, text string) *SubmissionNote {
+	note := &SubmissionNote{
+		ID:        newID(),
+		Author:    author,
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+	s.Notes = append(s.Notes, note)
+	s.record(author, "note", note.ID, nil, text)
+	return note
+}
+
+// Annotate sets an annotation, or removes it when value is nil, and records the change
+func (s *Submission) Annotate(actor, key string, value interface{}) {
+	previous := s.Annotations[key]
+	if value == nil {
+		delete(s.Annotations, key)
+	} else {
+		if s.Annotations == nil {
+			s.Annotations = make(map[string]interface{})
+		}
+		s.Annotations[key] = value
+	}
+	s.record(actor, "annotation", key, previous, value)
+}
+
+// record appends an entry to the change history
+func (s *Submission) record(actor, action, key string, from, to interface{}) {
+	now := time.Now()
+	s.History = append(s.History, &SubmissionChange{
+		Actor:  actor,
+		Action: action,
+		Key:    key,
+		From:   from,
+		To:     to,
+		At:     now,
+	})
+	s.UpdatedAt = now
+}
+
+// newID returns a random 128-bit identifier
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,89 @@
+package smartform
+
+import (
+	"fmt"
+)
+
+// Reasons a submitted value is considered tampered
+const (
+	TamperReasonHidden    = "hidden"
+	TamperReasonDisabled  = "disabled"
+	TamperReasonComputed  = "computed"  // Value submitted for a display field
+	TamperReasonProtected = "protected" // Value submitted by a caller without the field's write roles
+)
+
+// TamperFinding reports a value submitted for a field the client could not have edited
+type TamperFinding struct {
+	FieldID string       `json:"fieldId"`
+	Reason  string       `json:"reason"` // hidden, disabled, computed, or protected
+	Action  TamperPolicy `json:"action"` // What was done about it
+}
+
+// detectTampering finds values submitted for fields that are hidden or disabled given
+// the rest of the data, and handles them according to the configured tamper policy
+func (v *Validator) detectTampering(data map[string]interface{}, result *ValidationResult) {
+	if v.options == nil || v.options.TamperPolicy == "" || v.options.TamperPolicy == TamperPolicyIgnore {
+		return
+	}
+	v.detectTamperingIn(v.schema.Fields, data, data, "", result)
+}
+
+// detectTamperingIn checks the fields of one level of the submitted data
+func (v *Validator) detectTamperingIn(fields []*Field, values, root map[string]interface{}, prefix string, result *ValidationResult) {
+	for _, field := range fields {
+		fieldPath := field.ID
+		if prefix != "" {
+			fieldPath = prefix + "." + field.ID
+		}
+
+		value, present := values[field.ID]
+		if !present || value == nil {
+			continue
+		}
+
+		reason := ""
+		if field.Visible != nil && !v.evaluateCondition(field.Visible, root) {
+			reason = TamperReasonHidden
+		} else if field.Enabled != nil && !v.evaluateCondition(field.Enabled, root) {
+			reason = TamperReasonDisabled
+		}
+
+		if reason != "" {
+			v.handleTamperedValue(field, fieldPath, reason, values, result)
+			continue
+		}
+
+		switch nested := value.(type) {
+		case map[string]interface{}:
+			v.detectTamperingIn(field.Nested, nested, root, fieldPath, result)
+		case []interface{}:
+			for i, item := range nested {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					v.detectTamperingIn(field.Nested, itemMap, root, fmt.Sprintf("%s[%d]", fieldPath, i), result)
+				}
+			}
+		}
+	}
+}
+
+// handleTamperedValue applies the tamper policy to a single value
+func (v *Validator) handleTamperedValue(field *Field, fieldPath, reason string, values map[string]interface{}, result *ValidationResult) {
+	policy := v.options.TamperPolicy
+
+	switch policy {
+	case TamperPolicyStrip:
+		delete(values, field.ID)
+	case TamperPolicyReject:
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s cannot be submitted while it is %s", field.Label, reason),
+			RuleType: string(ValidationTypeTamper),
+		})
+	}
+
+	result.Tampered = append(result.Tampered, &TamperFinding{
+		FieldID: fieldPath,
+		Reason:  reason,
+		Action:  policy,
+	})
+}
@@ -0,0 +1,57 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// TamperPolicy represents how values submitted for hidden or disabled fields are handled
+type TamperPolicy string
+
+// Define tamper policies
+const (
+	TamperPolicyIgnore TamperPolicy = "ignore" // Leave the values untouched (default)
+	TamperPolicyFlag   TamperPolicy = "flag"   // Keep the values but report them
+	TamperPolicyStrip  TamperPolicy = "strip"  // Remove the values from the submitted data
+	TamperPolicyReject TamperPolicy = "reject" // Fail validation
+)
+
+// Values provides the possible values for TamperPolicy, compatible with entgo.
+func (TamperPolicy) Values() (types []string) {
+	return []string{
+		string(TamperPolicyIgnore),
+		string(TamperPolicyFlag),
+		string(TamperPolicyStrip),
+		string(TamperPolicyReject),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (p TamperPolicy) MarshalText() ([]byte, error) {
+	return []byte(p), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (p *TamperPolicy) UnmarshalText(text []byte) error {
+	switch TamperPolicy(text) {
+	case TamperPolicyIgnore, TamperPolicyFlag, TamperPolicyStrip, TamperPolicyReject:
+		*p = TamperPolicy(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid TamperPolicy: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (p *TamperPolicy) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("TamperPolicy should be a string, got %T", value)
+	}
+	return p.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (p TamperPolicy) Value() (driver.Value, error) {
+	return string(p), nil
+}
@@ -0,0 +1,232 @@
+package smartform
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Metrics recorded through Telemetry
+const (
+	MetricHTTPDuration        = "smartform.http.server.duration"   // Latency of API requests, by route, method, and status
+	MetricRenderDuration      = "smartform.render.duration"        // Time spent rendering forms, by form
+	MetricTemplateDuration    = "smartform.template.duration"      // Time spent evaluating templates, by form
+	MetricOptionFetchDuration = "smartform.options.fetch.duration" // Latency of option requests to upstream APIs, by upstream
+	MetricOptionCacheLookups  = "smartform.options.cache.lookups"  // Option cache lookups, by result: hit, stale, or miss
+	MetricFunctionDuration    = "smartform.function.duration"      // Latency of dynamic function executions, by function
+	MetricValidationFailures  = "smartform.validation.failures"    // Failed validations, by form and operation
+)
+
+// Telemetry receives the traces and metrics of a handler. Its methods mirror the tracer and
+// meter APIs of OpenTelemetry, so that an adapter over a TracerProvider and MeterProvider takes
+// a few lines, without the library depending on an OpenTelemetry SDK.
+type Telemetry interface {
+	// StartSpan starts a span as a child of the span in ctx, if any
+	StartSpan(ctx context.Context, name string, attributes ...TelemetryAttribute) (context.Context, TelemetrySpan)
+	// RecordDuration records a duration in a histogram
+	RecordDuration(ctx context.Context, metric string, duration time.Duration, attributes ...TelemetryAttribute)
+	// AddCount adds to a counter
+	AddCount(ctx context.Context, metric string, n int64, attributes ...TelemetryAttribute)
+}
+
+// TelemetrySpan is a span started by Telemetry
+type TelemetrySpan interface {
+	SetAttributes(attributes ...TelemetryAttribute)
+	RecordError(err error)
+	End()
+}
+
+// TelemetryAttribute is a key-value pair describing a span or a measurement. Values are
+// strings, booleans, or integers.
+type TelemetryAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+// attribute creates a telemetry attribute
+func attribute(key string, value interface{}) TelemetryAttribute {
+	return TelemetryAttribute{Key: key, Value: value}
+}
+
+// HandlerOption configures an APIHandler when it is created
+type HandlerOption func(ah *APIHandler)
+
+// WithTelemetry instruments the handler's endpoints, its option fetches and dynamic function
+// executions, and the template evaluation of the schemas it registers. Without it nothing is
+// recorded.
+func WithTelemetry(telemetry Telemetry) HandlerOption {
+	return func(ah *APIHandler) {
+		ah.telemetry = telemetry
+		ah.optionService.SetTelemetry(telemetry)
+		if ah.dynamicFunctionService != nil {
+			ah.dynamicFunctionService.SetTelemetry(telemetry)
+		}
+	}
+}
+
+// noopSpan is the span of uninstrumented operations
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...TelemetryAttribute) {}
+func (noopSpan) RecordError(err error)                          {}
+func (noopSpan) End()                                           {}
+
+// startSpan starts a span, or a span that records nothing when telemetry is nil
+func startSpan(telemetry Telemetry, ctx context.Context, name string, attributes ...TelemetryAttribute) (context.Context, TelemetrySpan) {
+	if telemetry == nil {
+		return ctx, noopSpan{}
+	}
+	return telemetry.StartSpan(ctx, name, attributes...)
+}
+
+// endSpan ends a span, recording the error it ended with if any
+func endSpan(span TelemetrySpan, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// recordDuration records the time since start, if telemetry is set
+func recordDuration(telemetry Telemetry, ctx context.Context, metric string, start time.Time, attributes ...TelemetryAttribute) {
+	if telemetry != nil {
+		telemetry.RecordDuration(ctx, metric, time.Since(start), attributes...)
+	}
+}
+
+// statusRecorder remembers the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the implicit 200 status of responses written without a header
+func (sr *statusRecorder) Write(data []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(data)
+}
+
+// Flush sends buffered data to the client
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection, for live updates
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	sr.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// telemetryMiddleware traces requests to a route and records their latency
+func (ah *APIHandler) telemetryMiddleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		attributes := []TelemetryAttribute{
+			attribute("http.route", route),
+			attribute("http.request.method", r.Method),
+		}
+		if ah.tenantID != "" {
+			attributes = append(attributes, attribute("smartform.tenant", ah.tenantID))
+		}
+		ctx, span := ah.telemetry.StartSpan(r.Context(), r.Method+" "+route, attributes...)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute("http.response.status_code", status))
+		attributes = append(attributes, attribute("http.response.status_code", status))
+		ah.telemetry.RecordDuration(ctx, MetricHTTPDuration, time.Since(start), attributes...)
+	})
+}
+
+// instrumentRoutes wraps each route with the telemetry middleware, if telemetry is set
+func (ah *APIHandler) instrumentRoutes(routes []Route) []Route {
+	if ah.telemetry == nil {
+		return routes
+	}
+	for i, route := range routes {
+		routes[i].Handler = ah.telemetryMiddleware(route.Path, route.Handler)
+	}
+	return routes
+}
+
+// countValidationFailure counts a failed validation, if telemetry is set
+func (ah *APIHandler) countValidationFailure(ctx context.Context, schema *FormSchema, result *ValidationResult, operation string) {
+	if ah.telemetry != nil && !result.Valid {
+		ah.telemetry.AddCount(ctx, MetricValidationFailures, 1,
+			attribute("smartform.form", schema.ID),
+			attribute("smartform.operation", operation))
+	}
+}
+
+// SetTelemetry sets the telemetry option fetches are recorded with
+func (os *OptionService) SetTelemetry(telemetry Telemetry) {
+	os.telemetry = telemetry
+}
+
+// SetTelemetry sets the telemetry function executions are recorded with
+func (dfs *DynamicFunctionService) SetTelemetry(telemetry Telemetry) {
+	dfs.telemetry = telemetry
+}
+
+// telemetryEvaluator records the time spent evaluating the templates of a form
+type telemetryEvaluator struct {
+	TemplateEvaluator
+	telemetry Telemetry
+	formID    string
+}
+
+// Evaluate evaluates a template, recording its duration
+func (te *telemetryEvaluator) Evaluate(template string, data map[string]interface{}) (interface{}, error) {
+	defer recordDuration(te.telemetry, context.Background(), MetricTemplateDuration, time.Now(), attribute("smartform.form", te.formID))
+	return te.TemplateEvaluator.Evaluate(template, data)
+}
+
+// EvaluateExpression evaluates an expression, recording its duration
+func (te *telemetryEvaluator) EvaluateExpression(expression string, data map[string]interface{}) (interface{}, error) {
+	defer recordDuration(te.telemetry, context.Background(), MetricTemplateDuration, time.Now(), attribute("smartform.form", te.formID))
+	return te.TemplateEvaluator.EvaluateExpression(expression, data)
+}
+
+// countCacheLookup counts a lookup of the option cache, if telemetry is set
+func (os *OptionService) countCacheLookup(found, fresh bool) {
+	if os.telemetry == nil {
+		return
+	}
+	result := "miss"
+	switch {
+	case found && fresh:
+		result = "hit"
+	case found:
+		result = "stale"
+	}
+	os.telemetry.AddCount(context.Background(), MetricOptionCacheLookups, 1, attribute("result", result))
+}
@@ -0,0 +1,149 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSpan is a span kept by recordingTelemetry
+type recordedSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...TelemetryAttribute) {
+	for _, attribute := range attributes {
+		s.attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) { s.err = err }
+func (s *recordedSpan) End()                  { s.ended = true }
+
+// recordingTelemetry keeps the spans and measurements it is given
+type recordingTelemetry struct {
+	lock    sync.Mutex
+	spans   []*recordedSpan
+	metrics map[string][]map[string]interface{}
+	counts  map[string]int64
+}
+
+func newRecordingTelemetry() *recordingTelemetry {
+	return &recordingTelemetry{metrics: map[string][]map[string]interface{}{}, counts: map[string]int64{}}
+}
+
+func attributeMap(attributes []TelemetryAttribute) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, attribute := range attributes {
+		values[attribute.Key] = attribute.Value
+	}
+	return values
+}
+
+func (rt *recordingTelemetry) StartSpan(ctx context.Context, name string, attributes ...TelemetryAttribute) (context.Context, TelemetrySpan) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	span := &recordedSpan{name: name, attributes: attributeMap(attributes)}
+	rt.spans = append(rt.spans, span)
+	return ctx, span
+}
+
+func (rt *recordingTelemetry) RecordDuration(ctx context.Context, metric string, duration time.Duration, attributes ...TelemetryAttribute) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.metrics[metric] = append(rt.metrics[metric], attributeMap(attributes))
+}
+
+func (rt *recordingTelemetry) AddCount(ctx context.Context, metric string, n int64, attributes ...TelemetryAttribute) {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	key := metric
+	if result, ok := attributeMap(attributes)["result"]; ok {
+		key += ":" + result.(string)
+	}
+	rt.counts[key] += n
+}
+
+func (rt *recordingTelemetry) span(name string) *recordedSpan {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	for _, span := range rt.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestTelemetryInstrumentation(t *testing.T) {
+	telemetry := newRecordingTelemetry()
+	handler := NewAPIHandler(WithTelemetry(telemetry))
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("greet", func(args, formState map[string]interface{}) (interface{}, error) {
+		return "hello", nil
+	})
+	handler.SetDynamicFunctionService(functions)
+
+	form := NewForm("contact", "Contact")
+	form.TextField("email", "Email").Required(true).Placeholder("${'you@example.com'}")
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := handler.Handler()
+
+	request := func(method, path string, body interface{}) int {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(data)))
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/api/forms/contact", nil))
+	span := telemetry.span("GET /api/forms/")
+	require.NotNil(t, span)
+	assert.True(t, span.ended)
+	assert.Equal(t, http.StatusOK, span.attributes["http.response.status_code"])
+	assert.NotNil(t, telemetry.span("smartform.render"))
+	assert.NotEmpty(t, telemetry.metrics[MetricRenderDuration])
+	assert.NotEmpty(t, telemetry.metrics[MetricTemplateDuration])
+
+	require.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/api/submit/contact", map[string]interface{}{}))
+	assert.Equal(t, int64(1), telemetry.counts[MetricValidationFailures])
+	durations := telemetry.metrics[MetricHTTPDuration]
+	assert.Equal(t, http.StatusBadRequest, durations[len(durations)-1]["http.response.status_code"])
+
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/api/function/greet", map[string]interface{}{}))
+	assert.NotNil(t, telemetry.span("smartform.function"))
+	assert.Equal(t, "greet", telemetry.metrics[MetricFunctionDuration][0]["smartform.function"])
+}
+
+func TestOptionServiceTelemetry(t *testing.T) {
+	var calls atomic.Int32
+	upstream := flakyUpstream(&calls)
+	defer upstream.Close()
+
+	telemetry := newRecordingTelemetry()
+	service := NewOptionService(time.Minute)
+	service.SetTelemetry(telemetry)
+	source := NewOptionsBuilder().Dynamic().FromAPIWithPath(upstream.URL, "GET", "id", "name").Build().DynamicSource
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetDynamicOptions(source, nil)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), telemetry.counts[MetricOptionCacheLookups+":miss"])
+	assert.Equal(t, int64(1), telemetry.counts[MetricOptionCacheLookups+":hit"])
+	require.Len(t, telemetry.metrics[MetricOptionFetchDuration], 1)
+	assert.Equal(t, false, telemetry.metrics[MetricOptionFetchDuration][0]["error"])
+	assert.NotNil(t, telemetry.span("smartform.options.fetch"))
+}
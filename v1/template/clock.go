@@ -0,0 +1,55 @@
+package template
+
+import "time"
+
+// NowVariable is the context variable holding the instant an evaluation takes place at. now()
+// returns it when it is set, so that every expression evaluated for one request sees the same
+// time.
+const NowVariable = "_now"
+
+// Clock tells the time to date functions
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock tells the wall-clock time
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock always tells the same time
+type FixedClock time.Time
+
+// Now returns the fixed time
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// SetClock sets the clock now() falls back to when the context carries no NowVariable
+func (vr *VariableRegistry) SetClock(clock Clock) {
+	vr.mutex.Lock()
+	defer vr.mutex.Unlock()
+	vr.clock = clock
+}
+
+// Clock returns the clock of the registry, the system clock unless one was set
+func (vr *VariableRegistry) Clock() Clock {
+	vr.mutex.RLock()
+	defer vr.mutex.RUnlock()
+	if vr.clock == nil {
+		return SystemClock{}
+	}
+	return vr.clock
+}
+
+// funcNow returns the time of the evaluation: the NowVariable of the context, or else the time
+// of the registry's clock
+func (vr *VariableRegistry) funcNow(args []interface{}, context map[string]interface{}) (interface{}, error) {
+	if now, ok := context[NowVariable].(time.Time); ok {
+		return now, nil
+	}
+	return vr.Clock().Now(), nil
+}
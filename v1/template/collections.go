@@ -0,0 +1,392 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Collection operations that evaluate an expression for each item, like forEach
+const (
+	CollectionMap    = "map"    // map(item, items, expression): the values of expression
+	CollectionFilter = "filter" // filter(item, items, condition): the items for which condition holds
+	CollectionReduce = "reduce" // reduce(item, total, items, initial, expression): the last value of expression
+)
+
+// CollectionPart represents a collection operation evaluating an expression for each item of
+// a collection. Unlike forEach, it returns structured data rather than text.
+type CollectionPart struct {
+	Operation  string
+	ItemVar    string
+	AccVar     string // Variable holding the running value of reduce
+	Collection TemplatePart
+	Initial    TemplatePart // Initial value of reduce
+	Body       TemplatePart
+}
+
+// Evaluate applies the operation to the items of the collection
+func (cp *CollectionPart) Evaluate(registry *VariableRegistry, context map[string]interface{}) (interface{}, error) {
+	b := contextBudget(context)
+	if !b.allows(cp.Operation) {
+		return nil, fmt.Errorf("%w: %s", ErrFunctionNotAllowed, cp.Operation)
+	}
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.leave()
+
+	collection, err := cp.Collection.Evaluate(registry, context)
+	if err != nil {
+		return nil, err
+	}
+	items := collectionItems(collection)
+
+	var acc interface{}
+	if cp.Operation == CollectionReduce {
+		if acc, err = cp.Initial.Evaluate(registry, context); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if err := b.iterate(); err != nil {
+			return nil, err
+		}
+
+		itemContext := make(map[string]interface{}, len(context)+2)
+		for k, v := range context {
+			itemContext[k] = v
+		}
+		itemContext[cp.ItemVar] = item
+		if cp.AccVar != "" {
+			itemContext[cp.AccVar] = acc
+		}
+
+		value, err := cp.Body.Evaluate(registry, itemContext)
+		if err != nil {
+			return nil, err
+		}
+		switch cp.Operation {
+		case CollectionMap:
+			results = append(results, value)
+		case CollectionFilter:
+			if truthy(value) {
+				results = append(results, item)
+			}
+		case CollectionReduce:
+			acc = value
+		}
+	}
+
+	if cp.Operation == CollectionReduce {
+		return acc, nil
+	}
+	return results, nil
+}
+
+// identifierPattern matches the names of the variables collection operations bind
+var identifierPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// collectionArity is the number of arguments of each collection operation
+var collectionArity = map[string]int{
+	CollectionMap:    3,
+	CollectionFilter: 3,
+	CollectionReduce: 5,
+}
+
+// parseCollectionPart parses a collection operation taking the whole expression, or returns
+// nil if the expression is something else
+func (te *TemplateEngine) parseCollectionPart(expression string) (TemplatePart, error) {
+	if !isSingleCall(expression) {
+		return nil, nil
+	}
+	open := strings.Index(expression, "(")
+	operation := strings.TrimSpace(expression[:open])
+	arity, ok := collectionArity[operation]
+	if !ok {
+		return nil, nil
+	}
+
+	args, err := te.splitFunctionArgs(expression[open+1 : len(expression)-1])
+	if err != nil || len(args) != arity || !identifierPattern.MatchString(strings.TrimSpace(args[0])) {
+		return nil, nil
+	}
+
+	part := &CollectionPart{Operation: operation, ItemVar: strings.TrimSpace(args[0])}
+	rest := args[1:]
+	if operation == CollectionReduce {
+		part.AccVar = strings.TrimSpace(args[1])
+		if !identifierPattern.MatchString(part.AccVar) {
+			return nil, fmt.Errorf("reduce requires a variable name for the running value: %s", args[1])
+		}
+		rest = args[2:]
+	}
+
+	if part.Collection, err = te.parseExpressionPart(rest[0]); err != nil {
+		return nil, err
+	}
+	if operation == CollectionReduce {
+		if part.Initial, err = te.parseExpressionPart(rest[1]); err != nil {
+			return nil, err
+		}
+		rest = rest[1:]
+	}
+	if part.Body, err = te.parseExpressionPart(rest[1]); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// isSingleCall reports whether an expression is a single function call, e.g. count(items)
+// but not count(items) > 1
+func isSingleCall(expression string) bool {
+	open := strings.Index(expression, "(")
+	return open > 0 && identifierPattern.MatchString(strings.TrimSpace(expression[:open])) &&
+		closingParen(expression, open) == len(expression)-1
+}
+
+// closingParen returns the index of the parenthesis closing the one at open, ignoring those
+// in quotes, or -1 if it is not closed
+func closingParen(expression string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(expression); i++ {
+		char := expression[i]
+		switch {
+		case quote != 0:
+			if char == '\\' {
+				i++
+			} else if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// collectionItems returns the items of an array, or the key-value pairs of an object like
+// forEach does
+func collectionItems(collection interface{}) []interface{} {
+	switch v := collection.(type) {
+	case []interface{}:
+		return v
+	case []map[string]interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]interface{}, len(keys))
+		for i, key := range keys {
+			items[i] = map[string]interface{}{"key": key, "value": v[key]}
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+// itemValue returns the value at a path in an item of a collection, or the item itself for an
+// empty path
+func itemValue(item interface{}, path string) interface{} {
+	if path == "" {
+		return item
+	}
+	if m, ok := item.(map[string]interface{}); ok {
+		return getValueByPath(m, path)
+	}
+	return nil
+}
+
+// truthy reports whether a value counts as true in a condition
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	default:
+		if number, err := toNumber(v); err == nil {
+			return number != 0
+		}
+		return true
+	}
+}
+
+// collectionArgs reads the collection and the optional item path the aggregate functions take
+func collectionArgs(name string, args []interface{}) ([]interface{}, string, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, "", fmt.Errorf("%s requires 1 or 2 arguments: collection, [path]", name)
+	}
+	path := ""
+	if len(args) == 2 {
+		var ok bool
+		if path, ok = args[1].(string); !ok {
+			return nil, "", fmt.Errorf("%s requires the path to be a string", name)
+		}
+	}
+	return collectionItems(args[0]), path, nil
+}
+
+// collectionNumbers returns the numbers at a path in the items of a collection, skipping
+// empty values
+func collectionNumbers(name string, args []interface{}) ([]float64, error) {
+	items, path, err := collectionArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]float64, 0, len(items))
+	for _, item := range items {
+		value := itemValue(item, path)
+		if value == nil || value == "" {
+			continue
+		}
+		number, err := toNumber(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func funcSum(args []interface{}) (interface{}, error) {
+	numbers, err := collectionNumbers("sum", args)
+	if err != nil {
+		return nil, err
+	}
+	sum := 0.0
+	for _, number := range numbers {
+		sum += number
+	}
+	return sum, nil
+}
+
+func funcAvg(args []interface{}) (interface{}, error) {
+	numbers, err := collectionNumbers("avg", args)
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	sum := 0.0
+	for _, number := range numbers {
+		sum += number
+	}
+	return sum / float64(len(numbers)), nil
+}
+
+func funcMin(args []interface{}) (interface{}, error) {
+	numbers, err := collectionNumbers("min", args)
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	least := numbers[0]
+	for _, number := range numbers[1:] {
+		if number < least {
+			least = number
+		}
+	}
+	return least, nil
+}
+
+func funcMax(args []interface{}) (interface{}, error) {
+	numbers, err := collectionNumbers("max", args)
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	greatest := numbers[0]
+	for _, number := range numbers[1:] {
+		if number > greatest {
+			greatest = number
+		}
+	}
+	return greatest, nil
+}
+
+func funcSortBy(args []interface{}) (interface{}, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errors.New("sortBy requires 2 or 3 arguments: collection, path, ['desc']")
+	}
+	path, ok := args[1].(string)
+	if !ok {
+		return nil, errors.New("sortBy requires the path to be a string")
+	}
+	descending := false
+	if len(args) == 3 {
+		order, _ := args[2].(string)
+		switch strings.ToLower(order) {
+		case "asc":
+		case "desc":
+			descending = true
+		default:
+			return nil, fmt.Errorf("sortBy order must be 'asc' or 'desc', not %v", args[2])
+		}
+	}
+
+	sorted := append([]interface{}{}, collectionItems(args[0])...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := itemValue(sorted[i], path), itemValue(sorted[j], path)
+		if descending {
+			a, b = b, a
+		}
+		return lessValue(a, b)
+	})
+	return sorted, nil
+}
+
+// lessValue orders values for sortBy: empty values first, then numbers, then text
+func lessValue(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	x, errA := toNumber(a)
+	y, errB := toNumber(b)
+	switch {
+	case errA == nil && errB == nil:
+		return x < y
+	case errA == nil || errB == nil:
+		return errA == nil
+	default:
+		return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+	}
+}
+
+func funcGroupBy(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, errors.New("groupBy requires 2 arguments: collection, path")
+	}
+	path, ok := args[1].(string)
+	if !ok {
+		return nil, errors.New("groupBy requires the path to be a string")
+	}
+
+	groups := make(map[string]interface{})
+	for _, item := range collectionItems(args[0]) {
+		key := ""
+		if value := itemValue(item, path); value != nil {
+			key = fmt.Sprintf("%v", value)
+		}
+		group, _ := groups[key].([]interface{})
+		groups[key] = append(group, item)
+	}
+	return groups, nil
+}
@@ -0,0 +1,55 @@
+package template
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectionFunctions(t *testing.T) {
+	engine := NewTemplateEngine()
+	context := map[string]interface{}{
+		"lines": []interface{}{
+			map[string]interface{}{"sku": "pen", "category": "office", "price": 2.5, "quantity": 4},
+			map[string]interface{}{"sku": "desk", "category": "furniture", "price": 120.0, "quantity": 1},
+			map[string]interface{}{"sku": "paper", "category": "office", "price": 8.0, "quantity": 2},
+		},
+		"minimum": 5,
+	}
+
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"${sum(lines, 'price')}", 130.5},
+		{"${sum(map(line, lines, multiply(line.price, line.quantity)))}", 146.0},
+		{"${count(filter(line, lines, line.price > minimum))}", 2},
+		{"${map(line, filter(line, lines, line.category == 'office'), line.sku)}", []interface{}{"pen", "paper"}},
+		{"${reduce(line, total, lines, 0, add(total, line.quantity))}", 7.0},
+		{"${avg(lines, 'quantity')}", 7.0 / 3},
+		{"${min(lines, 'price')}", 2.5},
+		{"${max(map(line, lines, line.quantity))}", 4.0},
+		{"${avg(filter(line, lines, line.price > 1000), 'price')}", nil},
+		{"${map(line, sortBy(lines, 'price', 'desc'), line.sku)}", []interface{}{"desk", "paper", "pen"}},
+		{"${map(line, sortBy(lines, 'sku'), line.sku)}", []interface{}{"desk", "paper", "pen"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			result, err := engine.EvaluateExpression(tt.expression, context)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	groups, err := engine.EvaluateExpression("${groupBy(lines, 'category')}", context)
+	require.NoError(t, err)
+	assert.Len(t, groups.(map[string]interface{})["office"], 2)
+	assert.Len(t, groups.(map[string]interface{})["furniture"], 1)
+
+	// The iterations of collection operations count against the limits of the engine
+	engine.SetLimits(&Limits{MaxIterations: 5})
+	_, err = engine.EvaluateExpression("${sum(map(a, lines, sum(map(b, lines, b.price))))}", context)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), err)
+}
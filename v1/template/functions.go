@@ -42,6 +42,14 @@ func (vr *VariableRegistry) RegisterStandardFunctions() {
 	vr.RegisterFunction("last", funcLast)
 	vr.RegisterFunction("count", funcCount)
 
+	// Collection functions
+	vr.RegisterFunction("sum", funcSum)
+	vr.RegisterFunction("avg", funcAvg)
+	vr.RegisterFunction("min", funcMin)
+	vr.RegisterFunction("max", funcMax)
+	vr.RegisterFunction("sortBy", funcSortBy)
+	vr.RegisterFunction("groupBy", funcGroupBy)
+
 	// Type conversion
 	vr.RegisterFunction("toString", funcToString)
 	vr.RegisterFunction("toNumber", funcToNumber) // Ensure this uses the updated toNumber
@@ -52,7 +60,7 @@ func (vr *VariableRegistry) RegisterStandardFunctions() {
 	vr.RegisterFunction("coalesce", funcCoalesce)
 
 	// Date functions
-	vr.RegisterFunction("now", funcNow)
+	vr.RegisterContextFunction("now", vr.funcNow)
 	vr.RegisterFunction("formatDate", funcFormatDate)
 	vr.RegisterFunction("addDays", funcAddDays)
 
@@ -445,10 +453,6 @@ func funcCoalesce(args []interface{}) (interface{}, error) {
 	return args[len(args)-1], nil
 }
 
-func funcNow(args []interface{}) (interface{}, error) {
-	return time.Now(), nil
-}
-
 func funcFormatDate(args []interface{}) (interface{}, error) {
 	if len(args) < 1 || len(args) > 2 {
 		return nil, errors.New("formatDate requires 1 or 2 arguments")
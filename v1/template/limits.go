@@ -0,0 +1,121 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors of templates stopped by the limits of their engine
+var (
+	ErrLimitExceeded      = errors.New("template limit exceeded")
+	ErrFunctionNotAllowed = errors.New("template function not allowed")
+)
+
+// budgetKey is the context key the budget of an evaluation is carried under
+const budgetKey = "__budget"
+
+// Limits bounds the work a template may do, so that templates of untrusted schemas cannot
+// exhaust the server. Zero values mean no limit.
+type Limits struct {
+	MaxIterations int           // forEach, map, filter, and reduce iterations, across all the loops of an evaluation
+	MaxCallDepth  int           // Nesting of function calls and loops
+	Timeout       time.Duration // Time an evaluation may take
+	Functions     []string      // When set, the only functions templates may call, forEach, map, filter, and reduce included; operators always work
+}
+
+// SetLimits bounds the evaluation of templates by the engine, or removes the bounds when
+// limits is nil
+func (te *TemplateEngine) SetLimits(limits *Limits) {
+	if limits != nil {
+		copied := *limits
+		limits = &copied
+	}
+	te.limits = limits
+}
+
+// Limits returns the bounds set with SetLimits, if any
+func (te *TemplateEngine) Limits() *Limits {
+	return te.limits
+}
+
+// budget tracks the work done by one evaluation against the limits of its engine
+type budget struct {
+	limits     *Limits
+	allowed    map[string]bool
+	deadline   time.Time
+	iterations int
+	depth      int
+}
+
+// withBudget returns a copy of the context carrying a new budget for the limits
+func withBudget(context map[string]interface{}, limits *Limits) map[string]interface{} {
+	b := &budget{limits: limits}
+	if limits.Timeout > 0 {
+		b.deadline = time.Now().Add(limits.Timeout)
+	}
+	if limits.Functions != nil {
+		b.allowed = make(map[string]bool, len(limits.Functions))
+		for _, name := range limits.Functions {
+			b.allowed[name] = true
+		}
+	}
+
+	budgeted := make(map[string]interface{}, len(context)+1)
+	for k, v := range context {
+		budgeted[k] = v
+	}
+	budgeted[budgetKey] = b
+	return budgeted
+}
+
+// contextBudget returns the budget carried by a context, or nil for evaluations without limits
+func contextBudget(context map[string]interface{}) *budget {
+	b, _ := context[budgetKey].(*budget)
+	return b
+}
+
+// enter records entering a function call or loop, failing once the call depth or time is
+// exceeded. Calls to enter are paired with calls to leave.
+func (b *budget) enter() error {
+	if b == nil {
+		return nil
+	}
+	b.depth++
+	if b.limits.MaxCallDepth > 0 && b.depth > b.limits.MaxCallDepth {
+		return fmt.Errorf("%w: calls nested deeper than %d", ErrLimitExceeded, b.limits.MaxCallDepth)
+	}
+	return b.checkTime()
+}
+
+// leave records leaving a function call or loop
+func (b *budget) leave() {
+	if b != nil {
+		b.depth--
+	}
+}
+
+// iterate records an iteration of a loop, failing once the iterations or time are exceeded
+func (b *budget) iterate() error {
+	if b == nil {
+		return nil
+	}
+	b.iterations++
+	if b.limits.MaxIterations > 0 && b.iterations > b.limits.MaxIterations {
+		return fmt.Errorf("%w: more than %d loop iterations", ErrLimitExceeded, b.limits.MaxIterations)
+	}
+	return b.checkTime()
+}
+
+// checkTime fails once the evaluation has taken longer than its timeout
+func (b *budget) checkTime() error {
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return fmt.Errorf("%w: evaluation took longer than %s", ErrLimitExceeded, b.limits.Timeout)
+	}
+	return nil
+}
+
+// allows reports whether templates may call a function
+func (b *budget) allows(name string) bool {
+	return b == nil || b.allowed == nil || b.allowed[name]
+}
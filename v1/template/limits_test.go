@@ -0,0 +1,91 @@
+package template
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateLimits(t *testing.T) {
+	items := make([]interface{}, 20)
+	for i := range items {
+		items[i] = map[string]interface{}{"name": "x"}
+	}
+	context := map[string]interface{}{"items": items}
+
+	engine := NewTemplateEngine()
+	engine.SetLimits(&Limits{MaxIterations: 100, MaxCallDepth: 4})
+
+	result, err := engine.EvaluateExpression("${forEach(item, items, item.name)}", context)
+	require.NoError(t, err)
+	assert.Equal(t, 20, len(result.(string)))
+
+	// Nested loops count the iterations of all of them
+	_, err = engine.EvaluateExpression("${forEach(a, items, forEach(b, items, b.name))}", context)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), err)
+
+	_, err = engine.EvaluateExpression("${toUpper(toLower(toUpper(toLower(toUpper('deep')))))}", context)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), err)
+
+	// Each evaluation gets its own budget
+	_, err = engine.EvaluateExpression("${forEach(item, items, item.name)}", context)
+	assert.NoError(t, err)
+
+	// Coalescing does not hide limits
+	_, err = engine.EvaluateExpression("${toUpper(toLower(toUpper(toLower(toUpper(missing))))) ?? 'fallback'}", context)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), err)
+
+	slow := NewTemplateEngine()
+	slow.GetVariableRegistry().RegisterFunction("sleep", func(args []interface{}) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return "", nil
+	})
+	slow.SetLimits(&Limits{Timeout: 5 * time.Millisecond})
+	_, err = slow.EvaluateExpression("${forEach(item, items, sleep())}", context)
+	assert.True(t, errors.Is(err, ErrLimitExceeded), err)
+}
+
+func TestTemplateFunctionAllowlist(t *testing.T) {
+	engine := NewTemplateEngine()
+	engine.SetLimits(&Limits{Functions: []string{"toUpper"}})
+	context := map[string]interface{}{"name": "ada", "age": 30}
+
+	result, err := engine.EvaluateExpression("${toUpper(name)}", context)
+	require.NoError(t, err)
+	assert.Equal(t, "ADA", result)
+
+	_, err = engine.EvaluateExpression("${toLower(name)}", context)
+	assert.True(t, errors.Is(err, ErrFunctionNotAllowed), err)
+
+	// Operators are not functions templates call
+	result, err = engine.EvaluateExpression("${age > 18 ? 'adult' : 'minor'}", context)
+	require.NoError(t, err)
+	assert.Equal(t, "adult", result)
+
+	engine.SetLimits(nil)
+	_, err = engine.EvaluateExpression("${toLower(name)}", context)
+	assert.NoError(t, err)
+}
+
+func TestTemplateFunctionAllowlistCollections(t *testing.T) {
+	engine := NewTemplateEngine()
+	engine.SetLimits(&Limits{Functions: []string{"toUpper", "map"}})
+	context := map[string]interface{}{"names": []interface{}{"ada", "alan"}}
+
+	result, err := engine.EvaluateExpression("${map(name, names, toUpper(name))}", context)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"ADA", "ALAN"}, result)
+
+	for _, expression := range []string{
+		"${filter(name, names, name == 'ada')}",
+		"${reduce(name, total, names, '', name)}",
+		"${forEach(name, names, name)}",
+		"${map(name, names, toLower(name))}",
+	} {
+		_, err := engine.EvaluateExpression(expression, context)
+		assert.True(t, errors.Is(err, ErrFunctionNotAllowed), "%s: %v", expression, err)
+	}
+}
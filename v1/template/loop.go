@@ -15,6 +15,15 @@ type ForEachPart struct {
 
 // Evaluate executes the loop and concatenates the results
 func (fp *ForEachPart) Evaluate(registry *VariableRegistry, context map[string]interface{}) (interface{}, error) {
+	b := contextBudget(context)
+	if !b.allows("forEach") {
+		return nil, fmt.Errorf("%w: forEach", ErrFunctionNotAllowed)
+	}
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.leave()
+
 	// Evaluate the collection
 	collection, err := fp.Collection.Evaluate(registry, context)
 	if err != nil {
@@ -41,6 +50,10 @@ func (fp *ForEachPart) Evaluate(registry *VariableRegistry, context map[string]i
 	// Iterate over the collection
 	var result strings.Builder
 	for i, item := range items {
+		if err := b.iterate(); err != nil {
+			return nil, err
+		}
+
 		// Create a new context with the item variable
 		loopContext := make(map[string]interface{})
 		for k, v := range context {
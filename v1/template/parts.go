@@ -1,6 +1,7 @@
 package template
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -112,12 +113,23 @@ func (vp *VariablePart) Evaluate(registry *VariableRegistry, context map[string]
 
 // FunctionPart represents a function call in a template
 type FunctionPart struct {
-	Name string
-	Args []TemplatePart
+	Name     string
+	Args     []TemplatePart
+	operator bool // Set for calls the parser makes for operators, such as "?:" and ">"
 }
 
 // Evaluate calls the function with evaluated arguments
 func (fp *FunctionPart) Evaluate(registry *VariableRegistry, context map[string]interface{}) (interface{}, error) {
+	b := contextBudget(context)
+	if !fp.operator && !b.allows(fp.Name) {
+		return nil, fmt.Errorf("%w: %s", ErrFunctionNotAllowed, fp.Name)
+	}
+	if err := b.enter(); err != nil {
+		return nil, err
+	}
+	defer b.leave()
+
+	contextFn, withContext := registry.GetContextFunction(fp.Name)
 	fn, ok := registry.GetFunction(fp.Name)
 	if !ok {
 		return nil, fmt.Errorf("function not found: %s", fp.Name)
@@ -132,6 +144,9 @@ func (fp *FunctionPart) Evaluate(registry *VariableRegistry, context map[string]
 		args[i] = value
 	}
 
+	if withContext {
+		return contextFn(args, context)
+	}
 	return fn(args)
 }
 
@@ -143,8 +158,11 @@ type NullCoalescePart struct {
 
 // Evaluate returns the left value if not nil/empty, otherwise the right value
 func (ncp *NullCoalescePart) Evaluate(registry *VariableRegistry, context map[string]interface{}) (interface{}, error) {
-	// Try to evaluate the left part, but don't propagate errors
+	// Try to evaluate the left part, but don't propagate errors other than those of limits
 	leftVal, leftErr := ncp.Left.Evaluate(registry, context)
+	if errors.Is(leftErr, ErrLimitExceeded) || errors.Is(leftErr, ErrFunctionNotAllowed) {
+		return nil, leftErr
+	}
 
 	// If left part evaluated successfully and is not nil/empty, use it
 	if leftErr == nil && leftVal != nil {
@@ -13,6 +13,7 @@ type TemplateEngine struct {
 	variableRegistry *VariableRegistry
 	expressionCache  map[string]*TemplateExpression
 	cacheMutex       sync.RWMutex
+	limits           *Limits // Bounds of evaluations, set with SetLimits
 }
 
 // NewTemplateEngine creates a new template engine
@@ -25,16 +26,21 @@ func NewTemplateEngine() *TemplateEngine {
 
 // VariableRegistry manages variables and functions for templating
 type VariableRegistry struct {
-	variables map[string]interface{}
-	functions map[string]TemplateFunction
-	mutex     sync.RWMutex
+	variables        map[string]interface{}
+	functions        map[string]TemplateFunction
+	contextFunctions map[string]ContextFunction
+	signatures       map[string]*FunctionSignature
+	clock            Clock
+	mutex            sync.RWMutex
 }
 
 // NewVariableRegistry creates a new variable registry with standard functions
 func NewVariableRegistry() *VariableRegistry {
 	registry := &VariableRegistry{
-		variables: make(map[string]interface{}),
-		functions: make(map[string]TemplateFunction),
+		variables:        make(map[string]interface{}),
+		functions:        make(map[string]TemplateFunction),
+		contextFunctions: make(map[string]ContextFunction),
+		signatures:       make(map[string]*FunctionSignature),
 	}
 
 	// Register standard functions
@@ -104,6 +110,31 @@ func (vr *VariableRegistry) RegisterFunction(name string, fn TemplateFunction) {
 	vr.mutex.Lock()
 	defer vr.mutex.Unlock()
 	vr.functions[name] = fn
+	delete(vr.contextFunctions, name)
+	delete(vr.signatures, name)
+}
+
+// ContextFunction represents a template function that also reads the evaluation context
+type ContextFunction func(args []interface{}, context map[string]interface{}) (interface{}, error)
+
+// RegisterContextFunction registers a function that is passed the evaluation context. Looked up
+// with GetFunction, it is called without a context.
+func (vr *VariableRegistry) RegisterContextFunction(name string, fn ContextFunction) {
+	vr.mutex.Lock()
+	defer vr.mutex.Unlock()
+	vr.contextFunctions[name] = fn
+	delete(vr.signatures, name)
+	vr.functions[name] = func(args []interface{}) (interface{}, error) {
+		return fn(args, nil)
+	}
+}
+
+// GetContextFunction retrieves a function registered with RegisterContextFunction
+func (vr *VariableRegistry) GetContextFunction(name string) (ContextFunction, bool) {
+	vr.mutex.RLock()
+	defer vr.mutex.RUnlock()
+	fn, ok := vr.contextFunctions[name]
+	return fn, ok
 }
 
 // GetFunction retrieves a function from the registry
@@ -191,6 +222,9 @@ func (te *TemplateEngine) EvaluateExpression(expression string, context map[stri
 	if err != nil {
 		return nil, err
 	}
+	if te.limits != nil {
+		context = withBudget(context, te.limits)
+	}
 
 	if len(expr.Parts) == 1 {
 		// Simple expression
@@ -221,9 +255,12 @@ func (te *TemplateEngine) EvaluateExpressionAsString(expression string, context
 
 // GetExpressionSuggestions returns variable and function suggestions relevant to a partial expression
 func (te *TemplateEngine) GetExpressionSuggestions(partialExpr string) []*VariableSuggestion {
-	// Get all suggestions
-	allSuggestions := te.variableRegistry.GenerateVariableSuggestions()
+	return SuggestExpressions(te.variableRegistry.GenerateVariableSuggestions(), partialExpr)
+}
 
+// SuggestExpressions returns the suggestions relevant to a partial expression, such as the
+// properties of "customer." or the variables matching a function argument being typed
+func SuggestExpressions(allSuggestions []*VariableSuggestion, partialExpr string) []*VariableSuggestion {
 	// If the expression is empty, return everything
 	if partialExpr == "" {
 		return allSuggestions
@@ -507,7 +544,7 @@ func (te *TemplateEngine) parseTernaryExpressionCore(expression string, question
 		return nil, fmt.Errorf("parsing ternary falseValue '%s': %w", falseValue, err)
 	}
 
-	return &FunctionPart{Name: "if", Args: []TemplatePart{condPart, truePart, falsePart}}, nil
+	return &FunctionPart{Name: "if", Args: []TemplatePart{condPart, truePart, falsePart}, operator: true}, nil
 }
 
 // This function is no longer directly called in the main chain if parseTernaryExpressionCore is used.
@@ -543,6 +580,12 @@ func (te *TemplateEngine) parseExpressionPart(expression string) (TemplatePart,
 	}
 	// If not a top-level ternary structure, continue to other parsing rules.
 
+	// Collection operations: map(...), filter(...), reduce(...). Their bodies may contain
+	// any operator, so they are recognized before the operators are split.
+	if part, err := te.parseCollectionPart(expression); part != nil || err != nil {
+		return part, err
+	}
+
 	// 3. Null-coalescing operator (??)
 	// This also needs careful precedence, ensure it splits on top-level '??'
 	if strings.Contains(expression, "??") {
@@ -569,11 +612,15 @@ func (te *TemplateEngine) parseExpressionPart(expression string) (TemplatePart,
 		}
 	}
 
+	// Operators in the arguments of a call, e.g. filter(item, items, item.price > 10), belong
+	// to the arguments, so a single call is never split on them.
+	call := isSingleCall(expression)
+
 	// 4. Preprocess expressions with comparison operators that have no surrounding spaces.
 	// This loop aims to add spaces, e.g., "a>b" becomes "a > b".
 	// The recursive call means the modified expression is re-parsed from the top.
 	for _, op := range []string{">=", "<=", "==", "!=", ">", "<"} { // Order matters: >= before >
-		if strings.Contains(expression, op) && !strings.Contains(expression, " "+op+" ") && !strings.Contains(expression, op+" ") && !strings.Contains(expression, " "+op) {
+		if !call && strings.Contains(expression, op) && !strings.Contains(expression, " "+op+" ") && !strings.Contains(expression, op+" ") && !strings.Contains(expression, " "+op) {
 			// Check more carefully to avoid adding spaces if one side already has it.
 			// This simple replace might still be problematic if `op` is part of a literal or variable name.
 			// A proper lexer would avoid this.
@@ -606,7 +653,7 @@ func (te *TemplateEngine) parseExpressionPart(expression string) (TemplatePart,
 	// 5. Comparison Operators (e.g., >, <, ==) - Use REGEX for robustness
 	// REMOVE THE OLD `operators` LOOP that used symbols like `>` without spaces.
 	comparisonRegex := regexp.MustCompile(`^(.*?)(\s*(?:>=|<=|==|!=|>|<)\s*)(.*)$`) // Ensure operator is captured
-	if matches := comparisonRegex.FindStringSubmatch(expression); len(matches) == 4 {
+	if matches := comparisonRegex.FindStringSubmatch(expression); !call && len(matches) == 4 {
 		leftExpr := strings.TrimSpace(matches[1])
 		operator := strings.TrimSpace(matches[2]) // This is the operator like ">", "=="
 		rightExpr := strings.TrimSpace(matches[3])
@@ -639,7 +686,7 @@ func (te *TemplateEngine) parseExpressionPart(expression string) (TemplatePart,
 			default:
 				return nil, fmt.Errorf("unsupported comparison operator: %s", operator)
 			}
-			return &FunctionPart{Name: funcName, Args: []TemplatePart{leftPart, rightPart}}, nil
+			return &FunctionPart{Name: funcName, Args: []TemplatePart{leftPart, rightPart}, operator: true}, nil
 		}
 	}
 
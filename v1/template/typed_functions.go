@@ -0,0 +1,262 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FunctionDoc documents a function registered with RegisterTypedFunction
+type FunctionDoc struct {
+	Description string
+	Params      []string // Names of the parameters, in order; "arg1", "arg2", ... when empty
+}
+
+// FunctionParam is a parameter of a typed function
+type FunctionParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // Template type, e.g. "string", "number", "array<string>"
+}
+
+// FunctionSignature describes a typed function for editors
+type FunctionSignature struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Params      []FunctionParam `json:"params"`
+	Returns     string          `json:"returns"`
+	Variadic    bool            `json:"variadic,omitempty"` // The last parameter takes any number of arguments
+}
+
+// String formats the signature, e.g. "discount(price number, percent number) number"
+func (s *FunctionSignature) String() string {
+	params := make([]string, len(s.Params))
+	for i, param := range s.Params {
+		if s.Variadic && i == len(s.Params)-1 {
+			params[i] = "..." + param.Name + " " + param.Type
+		} else {
+			params[i] = param.Name + " " + param.Type
+		}
+	}
+	return fmt.Sprintf("%s(%s) %s", s.Name, strings.Join(params, ", "), s.Returns)
+}
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// RegisterTypedFunction registers a Go function taking and returning typed values, such as
+// func(price float64, percent int) float64. Arguments are converted to the types of the
+// parameters, and calls with arguments that cannot be are failed with an error. The function
+// may return an error as its second result. Its signature, with the names of the parameters
+// from doc, is shown in suggestions.
+func (vr *VariableRegistry) RegisterTypedFunction(name string, fn interface{}, doc FunctionDoc) error {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func {
+		return fmt.Errorf("function %s must be a func, not %s", name, fnType)
+	}
+	switch {
+	case fnType.NumOut() == 1 && fnType.Out(0) != errorType:
+	case fnType.NumOut() == 2 && fnType.Out(1) == errorType:
+	default:
+		return fmt.Errorf("function %s must return a value, or a value and an error", name)
+	}
+	if len(doc.Params) != 0 && len(doc.Params) != fnType.NumIn() {
+		return fmt.Errorf("function %s takes %d parameters, but %d are named", name, fnType.NumIn(), len(doc.Params))
+	}
+
+	signature := &FunctionSignature{
+		Name:        name,
+		Description: doc.Description,
+		Params:      make([]FunctionParam, fnType.NumIn()),
+		Returns:     typeName(fnType.Out(0)),
+		Variadic:    fnType.IsVariadic(),
+	}
+	for i := range signature.Params {
+		paramType := fnType.In(i)
+		if signature.Variadic && i == fnType.NumIn()-1 {
+			paramType = paramType.Elem()
+		}
+		signature.Params[i] = FunctionParam{Name: fmt.Sprintf("arg%d", i+1), Type: typeName(paramType)}
+		if len(doc.Params) != 0 {
+			signature.Params[i].Name = doc.Params[i]
+		}
+	}
+
+	adapted := func(args []interface{}) (interface{}, error) {
+		in, err := typedArgs(signature, fnType, args)
+		if err != nil {
+			return nil, err
+		}
+		out := fnValue.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return out[0].Interface(), nil
+	}
+
+	vr.mutex.Lock()
+	defer vr.mutex.Unlock()
+	vr.functions[name] = adapted
+	delete(vr.contextFunctions, name)
+	vr.signatures[name] = signature
+	return nil
+}
+
+// FunctionSignature returns the signature of a function registered with RegisterTypedFunction
+func (vr *VariableRegistry) FunctionSignature(name string) (*FunctionSignature, bool) {
+	vr.mutex.RLock()
+	defer vr.mutex.RUnlock()
+	signature, ok := vr.signatures[name]
+	return signature, ok
+}
+
+// typedArgs converts the arguments of a call to the parameter types of a typed function
+func typedArgs(signature *FunctionSignature, fnType reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	fixed := fnType.NumIn()
+	if signature.Variadic {
+		fixed--
+		if len(args) < fixed {
+			return nil, fmt.Errorf("%s requires at least %d arguments: %s", signature.Name, fixed, signature)
+		}
+	} else if len(args) != fixed {
+		return nil, fmt.Errorf("%s requires %d arguments: %s", signature.Name, fixed, signature)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		param := i
+		if i >= fixed {
+			param = fixed
+		}
+		paramType := fnType.In(param)
+		if signature.Variadic && param == fixed {
+			paramType = paramType.Elem()
+		}
+		value, err := convertArg(arg, paramType)
+		if err != nil {
+			p := signature.Params[param]
+			return nil, fmt.Errorf("%s: argument %s must be %s: %w", signature.Name, p.Name, p.Type, err)
+		}
+		in[i] = value
+	}
+	return in, nil
+}
+
+// convertArg converts a template value to a Go type
+func convertArg(arg interface{}, target reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(target), nil
+	}
+	value := reflect.ValueOf(arg)
+	if value.Type().AssignableTo(target) {
+		result := reflect.New(target).Elem()
+		result.Set(value)
+		return result, nil
+	}
+
+	switch {
+	case target == timeType:
+		s, ok := arg.(string)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("cannot convert %T to a date", arg)
+		}
+		for _, layout := range []string{time.RFC3339, time.RFC1123, "2006-01-02", "2006-01-02 15:04:05"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return reflect.ValueOf(t), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("invalid date %q", s)
+
+	case target.Kind() == reflect.String:
+		return reflect.ValueOf(fmt.Sprintf("%v", arg)).Convert(target), nil
+
+	case target.Kind() == reflect.Bool:
+		if s, ok := arg.(string); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("invalid boolean %q", s)
+			}
+			return reflect.ValueOf(b).Convert(target), nil
+		}
+
+	case isNumberKind(target.Kind()):
+		number, err := toNumber(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		switch target.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return reflect.ValueOf(number).Convert(target), nil
+		}
+		if number != math.Trunc(number) {
+			return reflect.Value{}, fmt.Errorf("%v is not a whole number", arg)
+		}
+		if (target.Kind() >= reflect.Uint && target.Kind() <= reflect.Uintptr) && number < 0 {
+			return reflect.Value{}, fmt.Errorf("%v is negative", arg)
+		}
+		return reflect.ValueOf(number).Convert(target), nil
+
+	case target.Kind() == reflect.Slice && (value.Kind() == reflect.Slice || value.Kind() == reflect.Array):
+		result := reflect.MakeSlice(target, value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			item, err := convertArg(value.Index(i).Interface(), target.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("item %d: %w", i, err)
+			}
+			result.Index(i).Set(item)
+		}
+		return result, nil
+
+	case target.Kind() == reflect.Map && target.Key().Kind() == reflect.String && value.Kind() == reflect.Map:
+		result := reflect.MakeMapWithSize(target, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			item, err := convertArg(iter.Value().Interface(), target.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("%v: %w", iter.Key(), err)
+			}
+			result.SetMapIndex(reflect.ValueOf(fmt.Sprintf("%v", iter.Key())).Convert(target.Key()), item)
+		}
+		return result, nil
+	}
+
+	if value.Type().ConvertibleTo(target) && value.Kind() == target.Kind() {
+		return value.Convert(target), nil
+	}
+	return reflect.Value{}, errors.New("cannot convert " + value.Type().String())
+}
+
+// isNumberKind reports whether a kind is an integer or floating-point number
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64 && kind != reflect.Uintptr
+}
+
+// typeName returns the template type of a Go type, as suggestions name them
+func typeName(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "date"
+	case t.Kind() == reflect.Interface:
+		return "any"
+	case t.Kind() == reflect.String:
+		return "string"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	case isNumberKind(t.Kind()):
+		return "number"
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return fmt.Sprintf("array<%s>", typeName(t.Elem()))
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Struct:
+		return "object"
+	case t.Kind() == reflect.Ptr:
+		return typeName(t.Elem())
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,72 @@
+package template
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterTypedFunction(t *testing.T) {
+	engine := NewTemplateEngine()
+	registry := engine.GetVariableRegistry()
+
+	require.NoError(t, registry.RegisterTypedFunction("discount", func(price float64, percent int) float64 {
+		return price * float64(100-percent) / 100
+	}, FunctionDoc{Description: "Applies a percentage discount", Params: []string{"price", "percent"}}))
+	require.NoError(t, registry.RegisterTypedFunction("initials", func(names ...string) string {
+		initials := ""
+		for _, name := range names {
+			initials += name[:1]
+		}
+		return initials
+	}, FunctionDoc{Params: []string{"names"}}))
+	require.NoError(t, registry.RegisterTypedFunction("weekday", func(date time.Time) (string, error) {
+		return date.Weekday().String(), nil
+	}, FunctionDoc{}))
+
+	context := map[string]interface{}{"price": "80", "percent": 25.0, "first": "Ada", "last": "Lovelace"}
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"${discount(price, percent)}", 60.0},
+		{"${initials(first, last)}", "AL"},
+		{"${initials()}", ""},
+		{"${weekday('2024-03-01')}", "Friday"},
+	}
+	for _, tt := range tests {
+		result, err := engine.EvaluateExpression(tt.expression, context)
+		require.NoError(t, err, tt.expression)
+		assert.Equal(t, tt.expected, result, tt.expression)
+	}
+
+	_, err := engine.EvaluateExpression("${discount(price, 12.5)}", context)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "argument percent must be number")
+	_, err = engine.EvaluateExpression("${discount(price)}", context)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "discount(price number, percent number) number")
+
+	assert.Error(t, registry.RegisterTypedFunction("bad", "not a function", FunctionDoc{}))
+	assert.Error(t, registry.RegisterTypedFunction("bad", func() {}, FunctionDoc{}))
+	assert.Error(t, registry.RegisterTypedFunction("bad", func(a, b int) int { return a }, FunctionDoc{Params: []string{"a"}}))
+
+	suggestions := map[string]*VariableSuggestion{}
+	for _, suggestion := range registry.GenerateVariableSuggestions() {
+		suggestions[suggestion.Expr] = suggestion
+	}
+	assert.Equal(t, "discount(price number, percent number) number", suggestions["discount"].Signature)
+	assert.Equal(t, "Applies a percentage discount", suggestions["discount"].Description)
+	assert.Equal(t, []FunctionParam{{Name: "price", Type: "number"}, {Name: "percent", Type: "number"}}, suggestions["discount"].Params)
+	assert.Equal(t, "initials(...names string) string", suggestions["initials"].Signature)
+	assert.Equal(t, "weekday(arg1 date) string", suggestions["weekday"].Signature)
+	assert.True(t, strings.HasPrefix(suggestions["concat"].Signature, "concat("))
+
+	// Registering an untyped function replaces the signature
+	registry.RegisterFunction("discount", funcAdd)
+	_, ok := registry.FunctionSignature("discount")
+	assert.False(t, ok)
+}
@@ -19,6 +19,9 @@ type VariableSuggestion struct {
 	ArrayInfo   *ArrayInfo  `json:"arrayInfo"`   // Info about array, if this is an array
 	IsFunction  bool        `json:"isFunction"`  // Whether this is a function
 	Signature   string      `json:"signature"`   // Function signature, if a function
+
+	Params  []FunctionParam `json:"params,omitempty"`  // Parameters, if a typed function
+	Returns string          `json:"returns,omitempty"` // Type returned, if a typed function
 }
 
 // ArrayInfo contains information about an array type
@@ -65,6 +68,18 @@ func (vr *VariableRegistry) GenerateVariableSuggestions() []*VariableSuggestion
 
 	// Add all functions with appropriate signatures
 	for name := range vr.functions {
+		if typed, ok := vr.signatures[name]; ok {
+			suggestions = append(suggestions, &VariableSuggestion{
+				Expr:        name,
+				Type:        "function",
+				Description: typed.Description,
+				IsFunction:  true,
+				Signature:   typed.String(),
+				Params:      typed.Params,
+				Returns:     typed.Returns,
+			})
+			continue
+		}
 		signature, description := getFunctionInfo(name)
 		suggestions = append(suggestions, &VariableSuggestion{
 			Expr:        name,
@@ -322,6 +337,30 @@ func getFunctionInfo(name string) (string, string) {
 			Signature:   "count(array)",
 			Description: "Returns the number of elements in an array",
 		},
+		"sum": {
+			Signature:   "sum(array, [path])",
+			Description: "Returns the sum of the numbers in an array, or at a path in its items",
+		},
+		"avg": {
+			Signature:   "avg(array, [path])",
+			Description: "Returns the average of the numbers in an array, or at a path in its items",
+		},
+		"min": {
+			Signature:   "min(array, [path])",
+			Description: "Returns the smallest number in an array, or at a path in its items",
+		},
+		"max": {
+			Signature:   "max(array, [path])",
+			Description: "Returns the largest number in an array, or at a path in its items",
+		},
+		"sortBy": {
+			Signature:   "sortBy(array, path, ['asc'|'desc'])",
+			Description: "Returns the items of an array sorted by the value at a path",
+		},
+		"groupBy": {
+			Signature:   "groupBy(array, path)",
+			Description: "Returns the items of an array grouped by the value at a path",
+		},
 		"toString": {
 			Signature:   "toString(value)",
 			Description: "Converts a value to a string",
@@ -0,0 +1,161 @@
+package smartform
+
+import (
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/juicycleff/smartform/v1/template"
+)
+
+// TemplateEvaluator evaluates the templates found in labels, default values, and conditions.
+// The built-in engine, with its ${...} syntax, is used by default; adapters let teams that
+// standardize on Go text/template, expr-lang, or another engine use it instead.
+type TemplateEvaluator interface {
+	// IsTemplate reports whether a string contains template syntax
+	IsTemplate(s string) bool
+	// Evaluate evaluates a string containing template syntax against a context
+	Evaluate(template string, context map[string]interface{}) (interface{}, error)
+	// EvaluateExpression evaluates a bare expression, such as "age >= 18", against a context
+	EvaluateExpression(expression string, context map[string]interface{}) (interface{}, error)
+}
+
+// DefaultTemplateEvaluator evaluates templates with the built-in ${...} template engine
+type DefaultTemplateEvaluator struct {
+	Engine *template.TemplateEngine
+}
+
+// NewDefaultTemplateEvaluator creates an evaluator for the built-in template engine, creating
+// an engine when engine is nil
+func NewDefaultTemplateEvaluator(engine *template.TemplateEngine) *DefaultTemplateEvaluator {
+	if engine == nil {
+		engine = template.NewTemplateEngine()
+	}
+	return &DefaultTemplateEvaluator{Engine: engine}
+}
+
+// IsTemplate reports whether a string contains a ${...} expression
+func (de *DefaultTemplateEvaluator) IsTemplate(s string) bool {
+	return strings.Contains(s, "${") && strings.Contains(s, "}")
+}
+
+// Evaluate evaluates a string containing ${...} expressions
+func (de *DefaultTemplateEvaluator) Evaluate(template string, context map[string]interface{}) (interface{}, error) {
+	return de.Engine.EvaluateExpression(template, context)
+}
+
+// EvaluateExpression evaluates an expression, wrapping it in ${...} when it is bare
+func (de *DefaultTemplateEvaluator) EvaluateExpression(expression string, context map[string]interface{}) (interface{}, error) {
+	if !de.IsTemplate(expression) {
+		expression = "${" + expression + "}"
+	}
+	return de.Engine.EvaluateExpression(expression, context)
+}
+
+// GoTemplateEvaluator evaluates templates with Go's text/template, e.g. "Hello {{.user.name}}".
+// Results that read as booleans or numbers are returned as such, so that expressions like
+// "{{gt .age 17}}" can drive conditions.
+type GoTemplateEvaluator struct {
+	Funcs texttemplate.FuncMap // Functions available to templates, in addition to the built-in ones
+}
+
+// IsTemplate reports whether a string contains a {{...}} action
+func (ge *GoTemplateEvaluator) IsTemplate(s string) bool {
+	return strings.Contains(s, "{{") && strings.Contains(s, "}}")
+}
+
+// Evaluate executes a Go template against the context
+func (ge *GoTemplateEvaluator) Evaluate(source string, context map[string]interface{}) (interface{}, error) {
+	tmpl, err := texttemplate.New("smartform").Funcs(ge.Funcs).Option("missingkey=zero").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var output strings.Builder
+	if err := tmpl.Execute(&output, context); err != nil {
+		return nil, err
+	}
+	return parseTemplateOutput(output.String()), nil
+}
+
+// EvaluateExpression evaluates an expression as a Go template action, wrapping it in {{...}}
+// when it is bare
+func (ge *GoTemplateEvaluator) EvaluateExpression(expression string, context map[string]interface{}) (interface{}, error) {
+	if !ge.IsTemplate(expression) {
+		expression = "{{" + expression + "}}"
+	}
+	return ge.Evaluate(expression, context)
+}
+
+// parseTemplateOutput reads text produced by a template as a boolean or number when it is one
+func parseTemplateOutput(output string) interface{} {
+	if value, err := strconv.ParseBool(output); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseFloat(output, 64); err == nil {
+		return value
+	}
+	return output
+}
+
+// SetTemplateEvaluator sets the engine evaluating the schema's templates and conditions
+func (fs *FormSchema) SetTemplateEvaluator(evaluator TemplateEvaluator) {
+	fs.templateEvaluator = evaluator
+}
+
+// TemplateEvaluator returns the engine evaluating the schema's templates: the one set on the
+// schema, or the built-in engine with the schema's variables and template limits. Schemas
+// registered with a handler with telemetry record the time spent evaluating.
+func (fs *FormSchema) TemplateEvaluator() TemplateEvaluator {
+	evaluator := fs.templateEvaluator
+	if evaluator == nil {
+		evaluator = fs.defaultTemplateEvaluator()
+	}
+	if fs.telemetry != nil {
+		return &telemetryEvaluator{TemplateEvaluator: evaluator, telemetry: fs.telemetry, formID: fs.ID}
+	}
+	return evaluator
+}
+
+// defaultTemplateEvaluator returns the built-in engine with the schema's variables and
+// template limits
+func (fs *FormSchema) defaultTemplateEvaluator() TemplateEvaluator {
+
+	engine := template.NewTemplateEngine()
+	if fs.variableRegistry != nil {
+		engine.SetVariableRegistry(fs.variableRegistry)
+	}
+	engine.SetLimits(fs.templateLimits)
+	return NewDefaultTemplateEvaluator(engine)
+}
+
+// SetTemplateLimits bounds the work the schema's templates may do when evaluated by the
+// built-in engine: loop iterations, call depth, time, and the functions they may call
+func (fs *FormSchema) SetTemplateLimits(limits *template.Limits) {
+	fs.templateLimits = limits
+}
+
+// WithTemplateLimits bounds the work the form's templates may do when evaluated by the
+// built-in engine
+func (fb *FormBuilder) WithTemplateLimits(limits *template.Limits) *FormBuilder {
+	fb.schema.templateLimits = limits
+	return fb
+}
+
+// WithTemplateEvaluator sets the engine evaluating the form's templates and conditions
+func (fb *FormBuilder) WithTemplateEvaluator(evaluator TemplateEvaluator) *FormBuilder {
+	fb.schema.templateEvaluator = evaluator
+	return fb
+}
+
+// SetTemplateEvaluator sets the engine evaluating the templates of forms registered from now on
+// that do not set their own
+func (ah *APIHandler) SetTemplateEvaluator(evaluator TemplateEvaluator) {
+	ah.templateEvaluator = evaluator
+}
+
+// SetTemplateLimits bounds the templates of forms registered from now on that are evaluated by
+// the built-in engine and do not set their own limits, such as forms uploaded by tenants
+func (ah *APIHandler) SetTemplateLimits(limits *template.Limits) {
+	ah.templateLimits = limits
+}
@@ -0,0 +1,98 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juicycleff/smartform/v1/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoTemplateEvaluator(t *testing.T) {
+	evaluator := &GoTemplateEvaluator{Funcs: map[string]interface{}{"upper": strings.ToUpper}}
+	context := map[string]interface{}{"age": 21, "user": map[string]interface{}{"name": "Ada"}}
+
+	assert.True(t, evaluator.IsTemplate("Hello {{.user.name}}"))
+	assert.False(t, evaluator.IsTemplate("Hello ${user.name}"))
+
+	result, err := evaluator.Evaluate("Hello {{upper .user.name}}", context)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello ADA", result)
+
+	result, err = evaluator.EvaluateExpression("gt .age 17", context)
+	require.NoError(t, err)
+	assert.Equal(t, true, result)
+
+	// The condition code evaluates expressions with whichever evaluator is set
+	conditions := NewConditionEvaluator()
+	conditions.SetTemplateEvaluator(evaluator)
+	ctx := NewEvaluationContext()
+	ctx.AddField("age", 16)
+	adult, err := conditions.Evaluate(&Condition{Type: ConditionTypeExpression, Expression: "ge .age 18"}, ctx)
+	require.NoError(t, err)
+	assert.False(t, adult)
+}
+
+func TestSchemaTemplateEvaluator(t *testing.T) {
+	schema := NewForm("signup", "Sign up").
+		WithTemplateEvaluator(&GoTemplateEvaluator{}).
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email for {{.company}}").Build()).
+		Build()
+
+	resolver := schema.GetTemplateResolver()
+	resolved := resolver.ResolveFormData(map[string]interface{}{"company": "Acme", "greeting": "Welcome to {{.company}}"})
+	assert.Equal(t, "Welcome to Acme", resolved["greeting"])
+
+	matches, err := resolver.ResolveConditionalExpression(&Condition{Type: ConditionTypeExpression, Expression: `eq .company "Acme"`}, map[string]interface{}{"company": "Acme"})
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	// Forms without their own evaluator use the handler's
+	handler := NewAPIHandler()
+	handler.SetTemplateEvaluator(&GoTemplateEvaluator{})
+	require.NoError(t, handler.RegisterSchema(NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name at {{.company}}").Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/contact?company=Acme", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rendered struct {
+		Fields []struct {
+			Label string `json:"label"`
+		} `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	require.Len(t, rendered.Fields, 1)
+	assert.Equal(t, "Name at Acme", rendered.Fields[0].Label)
+}
+
+func TestSchemaTemplateLimits(t *testing.T) {
+	form := NewForm("tenant", "Tenant").WithTemplateLimits(&template.Limits{Functions: []string{"toUpper"}})
+	form.TextField("name", "Name")
+	form.TextField("shout", "Shout").ComputedValue("${toUpper(name)}")
+	form.TextField("whisper", "Whisper").ComputedValue("${toLower(name)}")
+
+	computed, err := form.Build().ComputeValues(map[string]interface{}{"name": "Ada"})
+	require.NoError(t, err)
+	assert.Equal(t, "ADA", computed.Values["shout"])
+	assert.Contains(t, computed.Errors["whisper"], "template function not allowed: toLower")
+
+	// Forms registered with a handler get its limits unless they set their own
+	handler := NewAPIHandler()
+	handler.SetTemplateLimits(&template.Limits{MaxCallDepth: 1})
+	uploaded := NewForm("uploaded", "Uploaded")
+	uploaded.TextField("name", "Name")
+	uploaded.TextField("nested", "Nested").ComputedValue("${toUpper(toLower(name))}")
+	require.NoError(t, handler.RegisterSchema(uploaded.Build()))
+	schema, _ := handler.GetSchema("uploaded")
+	computed, err = schema.ComputeValues(map[string]interface{}{"name": "Ada"})
+	require.NoError(t, err)
+	assert.Contains(t, computed.Errors["nested"], "template limit exceeded")
+}
@@ -5,16 +5,14 @@ import (
 	"reflect"
 	"strings"
 	"sync"
-
-	"github.com/juicycleff/smartform/v1/template"
 )
 
 // TemplateResolver handles resolving template expressions in form data and configurations
 type TemplateResolver struct {
-	schema         *FormSchema
-	templateEngine *template.TemplateEngine
-	resolving      map[string]bool // Track circular dependencies
-	mutex          sync.RWMutex
+	schema    *FormSchema
+	evaluator TemplateEvaluator
+	resolving map[string]bool // Track circular dependencies
+	mutex     sync.RWMutex
 }
 
 // ResolutionContext provides context for template resolution
@@ -45,30 +43,16 @@ type ResolutionResult struct {
 // NewTemplateResolver creates a new template resolver for the given form schema
 func NewTemplateResolver(schema *FormSchema) *TemplateResolver {
 	return &TemplateResolver{
-		schema:         schema,
-		templateEngine: template.NewTemplateEngine(),
-		resolving:      make(map[string]bool),
+		schema:    schema,
+		evaluator: schema.TemplateEvaluator(),
+		resolving: make(map[string]bool),
 	}
 }
 
-// GetTemplateResolver returns a template resolver for the form schema
+// GetTemplateResolver returns a template resolver for the form schema, evaluating templates
+// with the schema's template evaluator and variables
 func (fs *FormSchema) GetTemplateResolver() *TemplateResolver {
-	resolver := NewTemplateResolver(fs)
-
-	// Set the variable registry from the form schema
-	resolver.templateEngine.SetVariableRegistry(fs.variableRegistry)
-
-	// Also ensure the template engine's registry has all the variables
-	// by copying them to be absolutely sure
-	if fs.variableRegistry != nil {
-		allVars := fs.variableRegistry.GetVariables()
-		templateEngineRegistry := resolver.templateEngine.GetVariableRegistry()
-		for key, value := range allVars {
-			templateEngineRegistry.RegisterVariable(key, value)
-		}
-	}
-
-	return resolver
+	return NewTemplateResolver(fs)
 }
 
 // ResolveFormData resolves all template expressions in form data
@@ -206,7 +190,7 @@ func (tr *TemplateResolver) ResolveConditionalExpression(condition *Condition, f
 
 	// If it's an expression-based condition
 	if condition.Expression != "" {
-		result, err := tr.templateEngine.EvaluateExpression(condition.Expression, tr.buildTemplateContext(context))
+		result, err := tr.evaluator.EvaluateExpression(condition.Expression, tr.buildTemplateContext(context))
 		if err != nil {
 			return false, err
 		}
@@ -246,7 +230,7 @@ func (tr *TemplateResolver) resolveValue(value interface{}, context *ResolutionC
 // resolveStringValue resolves template expressions in a string value
 func (tr *TemplateResolver) resolveStringValue(value string, context *ResolutionContext) (interface{}, error) {
 	// Check if the string contains template expressions
-	if !strings.Contains(value, "${") {
+	if !tr.evaluator.IsTemplate(value) {
 		return value, nil
 	}
 
@@ -254,7 +238,7 @@ func (tr *TemplateResolver) resolveStringValue(value string, context *Resolution
 	templateContext := tr.buildTemplateContext(context)
 
 	// Evaluate the expression
-	result, err := tr.templateEngine.EvaluateExpression(value, templateContext)
+	result, err := tr.evaluator.Evaluate(value, templateContext)
 	if err != nil {
 		if context.Options.StrictMode {
 			return nil, err
@@ -268,7 +252,7 @@ func (tr *TemplateResolver) resolveStringValue(value string, context *Resolution
 
 	// If recursive resolution is enabled and result is a string with templates
 	if context.Options.EnableRecursion {
-		if resultStr, ok := result.(string); ok && strings.Contains(resultStr, "${") {
+		if resultStr, ok := result.(string); ok && tr.evaluator.IsTemplate(resultStr) {
 			// Prevent infinite recursion
 			resolutionKey := fmt.Sprintf("recursive:%s", resultStr)
 			if tr.isResolving(resolutionKey) {
@@ -365,7 +349,7 @@ func (tr *TemplateResolver) buildTemplateContext(context *ResolutionContext) map
 	if context.FormData != nil {
 		for key, value := range context.FormData {
 			// Only add values that are NOT template expressions to avoid circular references
-			if strValue, ok := value.(string); ok && strings.Contains(strValue, "${") {
+			if strValue, ok := value.(string); ok && tr.evaluator.IsTemplate(strValue) {
 				// Skip unresolved template expressions
 				continue
 			}
@@ -0,0 +1,232 @@
+package smartform
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TenantHeader carries the ID of the tenant a request is for
+const TenantHeader = "X-Tenant-ID"
+
+// ErrTenantNotFound is returned for requests to a tenant that has not been created
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// TenantResolver returns the tenant a request is for, or "" for none, along with the request to
+// pass on, e.g. without the tenant's path prefix
+type TenantResolver func(r *http.Request) (string, *http.Request)
+
+// TenantFromHeader resolves tenants from a request header, TenantHeader when name is empty.
+// The header must be set by a trusted proxy, as clients could otherwise pick any tenant.
+func TenantFromHeader(name string) TenantResolver {
+	if name == "" {
+		name = TenantHeader
+	}
+	return func(r *http.Request) (string, *http.Request) {
+		return strings.TrimSpace(r.Header.Get(name)), r
+	}
+}
+
+// TenantFromPathPrefix resolves tenants from the path segment following a prefix, e.g. "acme"
+// for "/tenants/acme/api/forms" with the prefix "/tenants/", and strips both from the path
+func TenantFromPathPrefix(prefix string) TenantResolver {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return func(r *http.Request) (string, *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			return "", r
+		}
+		rest := r.URL.Path[len(prefix):]
+		tenantID, path := rest, "/"
+		if slash := strings.Index(rest, "/"); slash >= 0 {
+			tenantID, path = rest[:slash], rest[slash:]
+		}
+		if tenantID == "" {
+			return "", r
+		}
+
+		stripped := r.Clone(r.Context())
+		stripped.URL.Path = path
+		stripped.URL.RawPath = ""
+		return tenantID, stripped
+	}
+}
+
+// tenantRegistry holds the handlers of the tenants of an API handler
+type tenantRegistry struct {
+	handlers map[string]*APIHandler
+	setup    func(tenantID string, tenant *APIHandler)
+	lock     sync.Mutex
+}
+
+// Tenant returns the handler of a tenant, creating it on first use. A tenant has its own
+// schemas, option cache, auth tokens, and dynamic functions, starting with a copy of the
+// functions of this handler. It inherits this handler's configuration, such as its environment,
+// template evaluator, and verifiers, but none of its stores: submissions, files, form sessions,
+// and API keys must be set per tenant in the setup hook, as must option service settings such as
+// circuit breakers and source limits.
+func (ah *APIHandler) Tenant(tenantID string) *APIHandler {
+	ah.tenants.lock.Lock()
+	defer ah.tenants.lock.Unlock()
+
+	if tenant, ok := ah.tenants.handlers[tenantID]; ok {
+		return tenant
+	}
+
+	tenant := NewAPIHandler(WithTelemetry(ah.telemetry))
+	tenant.tenantID = tenantID
+	ah.inheritConfig(tenant)
+	tenant.SetAuthService(ah.authService.Clone())
+	functions := NewDynamicFunctionService()
+	if ah.dynamicFunctionService != nil {
+		functions = ah.dynamicFunctionService.Clone()
+	}
+	tenant.SetDynamicFunctionService(functions)
+	tenant.optionService.SetDynamicFunctionService(functions)
+	if ah.tenants.setup != nil {
+		ah.tenants.setup(tenantID, tenant)
+	}
+
+	if ah.tenants.handlers == nil {
+		ah.tenants.handlers = make(map[string]*APIHandler)
+	}
+	ah.tenants.handlers[tenantID] = tenant
+	return tenant
+}
+
+// inheritConfig copies the configuration of the handler to a new tenant
+func (ah *APIHandler) inheritConfig(tenant *APIHandler) {
+	ah.schemasLock.RLock()
+	defer ah.schemasLock.RUnlock()
+
+	tenant.fragmentLoader = ah.fragmentLoader
+	tenant.tamperPolicy = ah.tamperPolicy
+	tenant.parentResolver = ah.parentResolver
+	tenant.environment = ah.environment
+	tenant.mediaResolver = ah.mediaResolver
+	tenant.formSessionTTL = ah.formSessionTTL
+	tenant.resumeLinkKey = ah.resumeLinkKey
+	tenant.signatureKey = ah.signatureKey
+	tenant.uniqueHashKey = ah.uniqueHashKey
+	tenant.staffAuthorizer = ah.staffAuthorizer
+	tenant.verifiers = ah.verifiers
+	tenant.maxUploadSize = ah.maxUploadSize
+	tenant.failureLogger = ah.failureLogger
+	tenant.identityResolver = ah.identityResolver
+	tenant.accessResolver = ah.accessResolver
+	tenant.fieldEncryptor = ah.fieldEncryptor
+	tenant.auditLogger = ah.auditLogger
+	tenant.actorResolver = ah.actorResolver
+	tenant.cors = ah.cors
+	tenant.csrf = ah.csrf
+	tenant.requestLimits = ah.requestLimits
+	tenant.renderContexts = append([]RenderContextFunc{}, ah.renderContexts...)
+	tenant.flagProvider = ah.flagProvider
+	tenant.openAPIInfo = ah.openAPIInfo
+	tenant.clock = ah.clock
+	tenant.sampleGenerator = ah.sampleGenerator
+	tenant.templateEvaluator = ah.templateEvaluator
+	tenant.templateLimits = ah.templateLimits
+	tenant.lintRules = ah.lintRules
+	tenant.notifier = ah.notifier
+	tenant.compressionEnabled = ah.compressionEnabled
+	tenant.compressionMinSize = ah.compressionMinSize
+	tenant.batchWorkers = ah.batchWorkers
+	tenant.liveChangeLimit = ah.liveChangeLimit
+	tenant.previewLimiter = newRateLimiter(ah.previewLimiter.limit)
+	tenant.confirmations = ah.confirmations.emptyCopy()
+	for key, probe := range ah.probes {
+		tenant.probes[key] = probe
+	}
+	tenant.defaultGeocoder = ah.defaultGeocoder
+	if ah.geocoders != nil {
+		tenant.geocoders = make(map[string]*registeredGeocoder, len(ah.geocoders))
+		for name, geocoder := range ah.geocoders {
+			tenant.geocoders[name] = geocoder
+		}
+	}
+	if ah.remoteValidators != nil {
+		tenant.remoteValidators = make(map[string]RemoteValidator, len(ah.remoteValidators))
+		for name, validator := range ah.remoteValidators {
+			tenant.remoteValidators[name] = validator
+		}
+	}
+}
+
+// TenantID returns the ID of the tenant the handler serves, or "" for a handler that is not
+// a tenant's
+func (ah *APIHandler) TenantID() string {
+	return ah.tenantID
+}
+
+// SetTenantSetup sets a function configuring each tenant when it is created, e.g. with its own
+// submission store or API keys
+func (ah *APIHandler) SetTenantSetup(setup func(tenantID string, tenant *APIHandler)) {
+	ah.tenants.lock.Lock()
+	defer ah.tenants.lock.Unlock()
+	ah.tenants.setup = setup
+}
+
+// RegisterSchemaForTenant registers a form schema that only a tenant serves
+func (ah *APIHandler) RegisterSchemaForTenant(tenantID string, schema *FormSchema) error {
+	return ah.Tenant(tenantID).RegisterSchema(schema)
+}
+
+// Tenants returns the IDs of the tenants created, in order
+func (ah *APIHandler) Tenants() []string {
+	ah.tenants.lock.Lock()
+	defer ah.tenants.lock.Unlock()
+
+	ids := make([]string, 0, len(ah.tenants.handlers))
+	for id := range ah.tenants.handlers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RemoveTenant removes a tenant with its schemas, option cache, tokens, and functions
+func (ah *APIHandler) RemoveTenant(tenantID string) {
+	ah.tenants.lock.Lock()
+	defer ah.tenants.lock.Unlock()
+	delete(ah.tenants.handlers, tenantID)
+}
+
+// tenant returns the handler of a tenant that has been created
+func (ah *APIHandler) tenant(tenantID string) (*APIHandler, bool) {
+	ah.tenants.lock.Lock()
+	defer ah.tenants.lock.Unlock()
+	tenant, ok := ah.tenants.handlers[tenantID]
+	return tenant, ok
+}
+
+// TenantMiddleware routes requests for a tenant to the tenant's handler, and other requests to
+// next. Requests for tenants that have not been created are rejected rather than creating them.
+func (ah *APIHandler) TenantMiddleware(next http.Handler, resolver TenantResolver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, r := resolver(r)
+		if tenantID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tenant, ok := ah.tenant(tenantID)
+		if !ok {
+			writeError(w, ErrTenantNotFound)
+			return
+		}
+		tenant.tenantHandler().ServeHTTP(w, r)
+	})
+}
+
+// tenantHandler returns the handler serving the routes of a tenant, built on its first request
+// so that the tenant's configuration is complete
+func (ah *APIHandler) tenantHandler() http.Handler {
+	ah.tenantRoutesOnce.Do(func() {
+		ah.tenantRoutes = ah.Handler()
+	})
+	return ah.tenantRoutes
+}
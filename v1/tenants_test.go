@@ -0,0 +1,103 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenants(t *testing.T) {
+	handler := NewAPIHandler(WithBatchWorkers(2))
+	handler.SetLiveChangeLimit(RateLimit{Requests: 5, Per: time.Second})
+	handler.SetValidationPreviewLimit(ValidationPreviewLimit{Requests: 3, Per: time.Minute})
+	handler.SetConfirmationStore(NewConfirmationStore().SetMaxPending(7))
+	handler.AddProbe(ProbeKindConnection, "crm", func(ctx context.Context) error { return nil })
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("greet", func(args, state map[string]interface{}) (interface{}, error) {
+		return "hello", nil
+	})
+	handler.SetDynamicFunctionService(functions)
+	handler.SetEnvironment("staging")
+	require.NoError(t, handler.RegisterSchema(NewForm("shared", "Shared").Build()))
+
+	configured := []string{}
+	handler.SetTenantSetup(func(tenantID string, tenant *APIHandler) {
+		configured = append(configured, tenantID)
+	})
+	require.NoError(t, handler.RegisterSchemaForTenant("acme", NewForm("orders", "Acme orders").Build()))
+	require.NoError(t, handler.RegisterSchemaForTenant("globex", NewForm("orders", "Globex orders").Build()))
+	assert.Equal(t, []string{"acme", "globex"}, handler.Tenants())
+	assert.Equal(t, []string{"acme", "globex"}, configured)
+
+	acme := handler.Tenant("acme")
+	assert.Same(t, acme, handler.Tenant("acme"))
+	assert.Equal(t, "acme", acme.TenantID())
+	assert.Equal(t, "staging", acme.environment)
+	assert.Equal(t, 2, acme.batchWorkers)
+	assert.Equal(t, RateLimit{Requests: 5, Per: time.Second}, acme.liveChangeLimit)
+	assert.Equal(t, RateLimit{Requests: 3, Per: time.Minute}, acme.previewLimiter.limit)
+	assert.Equal(t, 7, acme.confirmations.maxPending)
+	assert.NotSame(t, handler.confirmations, acme.confirmations)
+	assert.Contains(t, acme.probes, probeKey(ProbeKindConnection, "crm"))
+	_, ok := handler.GetSchema("orders")
+	assert.False(t, ok)
+	_, ok = acme.GetSchema("shared")
+	assert.False(t, ok)
+
+	// Each tenant has its own functions, option cache, and tokens
+	assert.True(t, acme.dynamicFunctionService.HasFunction("greet"))
+	acme.dynamicFunctionService.RegisterFunction("acmeOnly", func(args, state map[string]interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.False(t, functions.HasFunction("acmeOnly"))
+	assert.False(t, handler.Tenant("globex").dynamicFunctionService.HasFunction("acmeOnly"))
+	assert.NotSame(t, handler.optionService, acme.optionService)
+	assert.NotSame(t, handler.authService, acme.authService)
+	assert.Same(t, acme.authService, acme.optionService.authService)
+
+	handler.RemoveTenant("globex")
+	assert.Equal(t, []string{"acme"}, handler.Tenants())
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("orders", "Default orders").Build()))
+	require.NoError(t, handler.RegisterSchemaForTenant("acme", NewForm("orders", "Acme orders").Build()))
+
+	title := func(server http.Handler, path string, header string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if header != "" {
+			req.Header.Set(TenantHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		var schema FormSchema
+		_ = json.Unmarshal(rec.Body.Bytes(), &schema)
+		return rec.Code, schema.Title
+	}
+
+	byHeader := handler.TenantMiddleware(handler.Handler(), TenantFromHeader(""))
+	status, got := title(byHeader, "/api/forms/orders", "acme")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Acme orders", got)
+	_, got = title(byHeader, "/api/forms/orders", "")
+	assert.Equal(t, "Default orders", got)
+	status, _ = title(byHeader, "/api/forms/orders", "initech")
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, []string{"acme"}, handler.Tenants())
+
+	byPath := handler.TenantMiddleware(handler.Handler(), TenantFromPathPrefix("/tenants"))
+	status, got = title(byPath, "/tenants/acme/api/forms/orders", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Acme orders", got)
+	_, got = title(byPath, "/api/forms/orders", "")
+	assert.Equal(t, "Default orders", got)
+	status, _ = title(byPath, "/tenants/initech/api/forms/orders", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
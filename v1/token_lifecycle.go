@@ -0,0 +1,388 @@
+package smartform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultTokenRefreshLeeway is how long before they expire OAuth tokens are refreshed
+const DefaultTokenRefreshLeeway = time.Minute
+
+// ErrTokenNotFound is returned when a service has no token to refresh or revoke
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenInfo is a token saved for a service, with its metadata
+type TokenInfo struct {
+	Token        string    `json:"token,omitempty"`
+	Type         string    `json:"type,omitempty"`      // Auth type the token was issued for
+	Provider     string    `json:"provider,omitempty"`  // Provider that issued the token
+	ServiceID    string    `json:"serviceId,omitempty"` // Service the token is saved for
+	ExpiresAt    time.Time `json:"expiresAt,omitzero"`  // Zero if the token never expires
+	Scopes       []string  `json:"scopes,omitempty"`
+	RefreshToken string    `json:"-"` // Used to refresh OAuth tokens, never sent to clients
+}
+
+// Expired reports whether the token has expired
+func (ti *TokenInfo) Expired() bool {
+	return !ti.ExpiresAt.IsZero() && !time.Now().Before(ti.ExpiresAt)
+}
+
+// Clone returns a copy of the token
+func (ti *TokenInfo) Clone() *TokenInfo {
+	clone := *ti
+	clone.Scopes = append([]string(nil), ti.Scopes...)
+	return &clone
+}
+
+// metadata returns a copy of the token without its secrets
+func (ti *TokenInfo) metadata() *TokenInfo {
+	metadata := ti.Clone()
+	metadata.Token = ""
+	metadata.RefreshToken = ""
+	return metadata
+}
+
+// OAuthTokenProvider is implemented by OAuth providers that report the metadata of the tokens
+// they issue, such as their expiry, scopes, and refresh token. The AuthService prefers it to
+// AuthenticateOAuth.
+type OAuthTokenProvider interface {
+	AuthenticateOAuthToken(ctx context.Context, config map[string]string) (*TokenInfo, error)
+}
+
+// OAuthRefresher is implemented by OAuth providers that can refresh their tokens. Saved tokens
+// with an expiry and a refresh token are refreshed shortly before they expire.
+type OAuthRefresher interface {
+	RefreshOAuth(ctx context.Context, token *TokenInfo) (*TokenInfo, error)
+}
+
+// TokenRevoker is implemented by providers that can revoke their tokens upstream
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, token *TokenInfo) error
+}
+
+// ConnectionEventType names what happened to a service connection
+type ConnectionEventType string
+
+// Connection event types
+const (
+	ConnectionRefreshed     ConnectionEventType = "refreshed"      // The token was replaced by a refreshed one
+	ConnectionRefreshFailed ConnectionEventType = "refresh_failed" // The token could not be refreshed and will expire
+	ConnectionExpired       ConnectionEventType = "expired"        // The token expired and was removed
+	ConnectionRevoked       ConnectionEventType = "revoked"        // The token was revoked and removed
+)
+
+// ConnectionEvent tells about a change of a service connection
+type ConnectionEvent struct {
+	Type      ConnectionEventType
+	ServiceID string
+	Token     *TokenInfo // The token after the change, without its secrets
+	Err       error      // Why a refresh failed
+}
+
+// ConnectionListener is told when a service connection is refreshed or becomes invalid, for
+// instance to disable the fields whose options come from the service
+type ConnectionListener func(event *ConnectionEvent)
+
+// UseConnectionListener adds a listener told about changes of service connections
+func (as *AuthService) UseConnectionListener(listener ConnectionListener) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+	as.listeners = append(as.listeners, listener)
+}
+
+// SetRefreshLeeway sets how long before they expire OAuth tokens are refreshed
+func (as *AuthService) SetRefreshLeeway(leeway time.Duration) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+	as.refreshLeeway = leeway
+}
+
+// StoreToken saves a token for a service, replacing the token of the same kind saved before.
+// OAuth, basic, and API key tokens share a kind, JWT and SAML tokens have their own. A token
+// with an expiry is removed when it expires, or refreshed before that if it is an OAuth token
+// with a refresh token from a provider implementing OAuthRefresher.
+func (as *AuthService) StoreToken(serviceID string, token *TokenInfo) {
+	token = token.Clone()
+	token.ServiceID = serviceID
+	key := tokenKey(token.Type, serviceID)
+
+	as.lock.Lock()
+	defer as.lock.Unlock()
+	as.tokens[key] = token
+	as.schedule(key, token)
+}
+
+// GetTokenInfo retrieves the token of an auth type saved for a service, unless it has expired
+func (as *AuthService) GetTokenInfo(authType, serviceID string) (*TokenInfo, bool) {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+	token, ok := as.tokens[tokenKey(authType, serviceID)]
+	if !ok || token.Expired() {
+		return nil, false
+	}
+	return token.Clone(), true
+}
+
+// ServiceTokens returns the metadata of the tokens saved for a service, without their secrets
+func (as *AuthService) ServiceTokens(serviceID string) []*TokenInfo {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+	tokens := []*TokenInfo{}
+	for _, authType := range []string{"", AuthTypeJWT, AuthTypeSAML} {
+		if token, ok := as.tokens[tokenKey(authType, serviceID)]; ok && !token.Expired() {
+			tokens = append(tokens, token.metadata())
+		}
+	}
+	return tokens
+}
+
+// RefreshToken refreshes the OAuth token saved for a service now
+func (as *AuthService) RefreshToken(ctx context.Context, serviceID string) (*TokenInfo, error) {
+	key := tokenKey(AuthTypeOAuth, serviceID)
+	as.lock.RLock()
+	token, ok := as.tokens[key]
+	as.lock.RUnlock()
+	if !ok || token.Type != AuthTypeOAuth {
+		return nil, fmt.Errorf("%w: no OAuth token for service %q", ErrTokenNotFound, serviceID)
+	}
+
+	refreshed, err := as.refresh(ctx, key, token)
+	if err != nil {
+		return nil, err
+	}
+	return refreshed.metadata(), nil
+}
+
+// RevokeToken removes the tokens saved for a service, revoking them upstream with providers
+// implementing TokenRevoker. The tokens are removed even if a provider fails to revoke them.
+func (as *AuthService) RevokeToken(ctx context.Context, serviceID string) error {
+	revoked := []*TokenInfo{}
+	as.lock.Lock()
+	for _, authType := range []string{"", AuthTypeJWT, AuthTypeSAML} {
+		key := tokenKey(authType, serviceID)
+		if token, ok := as.tokens[key]; ok {
+			revoked = append(revoked, token)
+			as.remove(key)
+		}
+	}
+	as.lock.Unlock()
+	if len(revoked) == 0 {
+		return fmt.Errorf("%w: no token for service %q", ErrTokenNotFound, serviceID)
+	}
+
+	var errs []error
+	for _, token := range revoked {
+		if provider, err := as.provider(token.Type, token.Provider); err == nil {
+			if revoker, ok := provider.(TokenRevoker); ok {
+				if err := revoker.RevokeToken(ctx, token.Clone()); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+		as.notify(&ConnectionEvent{Type: ConnectionRevoked, ServiceID: serviceID, Token: token.metadata()})
+	}
+	return errors.Join(errs...)
+}
+
+// token returns a token of an auth type saved for a service, unless it has expired
+func (as *AuthService) token(authType, serviceID string) (string, bool) {
+	token, ok := as.GetTokenInfo(authType, serviceID)
+	if !ok {
+		return "", false
+	}
+	return token.Token, true
+}
+
+// schedule sets the timer refreshing or expiring a token. The caller must hold the lock.
+func (as *AuthService) schedule(key string, token *TokenInfo) {
+	if timer, ok := as.timers[key]; ok {
+		timer.Stop()
+		delete(as.timers, key)
+	}
+	if token.ExpiresAt.IsZero() {
+		return
+	}
+
+	if as.refreshable(token) {
+		as.timers[key] = time.AfterFunc(time.Until(token.ExpiresAt.Add(-as.refreshLeeway)), func() {
+			_, _ = as.refresh(context.Background(), key, token)
+		})
+		return
+	}
+	as.timers[key] = time.AfterFunc(time.Until(token.ExpiresAt), func() {
+		as.expire(key, token)
+	})
+}
+
+// refreshable reports whether a token can be refreshed. The caller must hold the lock.
+func (as *AuthService) refreshable(token *TokenInfo) bool {
+	if token.Type != AuthTypeOAuth || token.RefreshToken == "" {
+		return false
+	}
+	_, ok := as.providers[AuthTypeOAuth][providerName(token.Provider)].(OAuthRefresher)
+	return ok
+}
+
+// refresh replaces a token with a refreshed one. If the refresh fails, the token is kept until
+// it expires.
+func (as *AuthService) refresh(ctx context.Context, key string, token *TokenInfo) (*TokenInfo, error) {
+	provider, err := as.provider(AuthTypeOAuth, token.Provider)
+	if err != nil {
+		return nil, err
+	}
+	refresher, ok := provider.(OAuthRefresher)
+	if !ok {
+		return nil, fmt.Errorf("OAuth provider %q cannot refresh tokens", providerName(token.Provider))
+	}
+
+	refreshed, err := refresher.RefreshOAuth(ctx, token.Clone())
+	if err != nil {
+		as.lock.Lock()
+		current := as.tokens[key] == token
+		if current && !token.ExpiresAt.IsZero() {
+			if timer, ok := as.timers[key]; ok {
+				timer.Stop()
+			}
+			as.timers[key] = time.AfterFunc(time.Until(token.ExpiresAt), func() {
+				as.expire(key, token)
+			})
+		}
+		as.lock.Unlock()
+		if current {
+			as.notify(&ConnectionEvent{Type: ConnectionRefreshFailed, ServiceID: token.ServiceID, Token: token.metadata(), Err: err})
+		}
+		return nil, err
+	}
+
+	refreshed = refreshed.Clone()
+	refreshed.Type = AuthTypeOAuth
+	refreshed.Provider = token.Provider
+	refreshed.ServiceID = token.ServiceID
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+
+	as.lock.Lock()
+	if as.tokens[key] != token {
+		// The token was replaced or revoked while it was refreshed
+		as.lock.Unlock()
+		return refreshed, nil
+	}
+	as.tokens[key] = refreshed
+	as.schedule(key, refreshed)
+	as.lock.Unlock()
+
+	as.notify(&ConnectionEvent{Type: ConnectionRefreshed, ServiceID: token.ServiceID, Token: refreshed.metadata()})
+	return refreshed, nil
+}
+
+// expire removes a token that has expired
+func (as *AuthService) expire(key string, token *TokenInfo) {
+	as.lock.Lock()
+	if as.tokens[key] != token {
+		as.lock.Unlock()
+		return
+	}
+	as.remove(key)
+	as.lock.Unlock()
+
+	as.notify(&ConnectionEvent{Type: ConnectionExpired, ServiceID: token.ServiceID, Token: token.metadata()})
+}
+
+// remove deletes a token and stops its timer. The caller must hold the lock.
+func (as *AuthService) remove(key string) {
+	if timer, ok := as.timers[key]; ok {
+		timer.Stop()
+		delete(as.timers, key)
+	}
+	delete(as.tokens, key)
+}
+
+// notify tells the listeners about a change of a service connection
+func (as *AuthService) notify(event *ConnectionEvent) {
+	as.lock.RLock()
+	listeners := append([]ConnectionListener{}, as.listeners...)
+	as.lock.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// tokenKey keys a token by its kind and service. OAuth, basic, and API key tokens share a kind.
+func tokenKey(authType, serviceID string) string {
+	switch authType {
+	case AuthTypeJWT, AuthTypeSAML:
+		return authType + "/" + serviceID
+	default:
+		return "/" + serviceID
+	}
+}
+
+// UseConnectionListener adds a listener told about changes of service connections
+func (ah *APIHandler) UseConnectionListener(listener ConnectionListener) {
+	ah.authService.UseConnectionListener(listener)
+}
+
+// handleAuthTokens handles staff requests for the tokens saved for a service
+func (ah *APIHandler) handleAuthTokens(w http.ResponseWriter, r *http.Request) {
+	if ah.staffAuthorizer == nil {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+	if _, ok := ah.staffAuthorizer(r); !ok {
+		writeError(w, NewAPIError(http.StatusForbidden, ErrorCodeForbidden, "Forbidden"))
+		return
+	}
+
+	segments := splitPath(getPathParam(r.URL.Path, "/api/auth/tokens/"))
+	if len(segments) == 0 || len(segments) > 2 || (len(segments) == 2 && segments[1] != "refresh") {
+		writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "Not found"))
+		return
+	}
+	serviceID := segments[0]
+
+	var response interface{}
+	switch {
+	case len(segments) == 2 && r.Method == http.MethodPost:
+		token, err := ah.authService.RefreshToken(r.Context(), serviceID)
+		switch {
+		case errors.Is(err, ErrTokenNotFound):
+			writeError(w, statusError(http.StatusNotFound, err))
+			return
+		case err != nil:
+			writeError(w, NewAPIError(http.StatusBadGateway, ErrorCodeUpstreamFailed, fmt.Sprintf("Refresh failed: %v", err)))
+			return
+		}
+		response = token
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		tokens := ah.authService.ServiceTokens(serviceID)
+		if len(tokens) == 0 {
+			writeError(w, NewAPIError(http.StatusNotFound, ErrorCodeNotFound, "No token for service"))
+			return
+		}
+		response = tokens
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		err := ah.authService.RevokeToken(r.Context(), serviceID)
+		switch {
+		case errors.Is(err, ErrTokenNotFound):
+			writeError(w, statusError(http.StatusNotFound, err))
+			return
+		case err != nil:
+			writeError(w, NewAPIError(http.StatusBadGateway, ErrorCodeUpstreamFailed, fmt.Sprintf("Revocation failed upstream: %v", err)))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
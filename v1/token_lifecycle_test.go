@@ -0,0 +1,196 @@
+package smartform
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectionEvents collects the connection events of a service
+func connectionEvents(service *AuthService) chan *ConnectionEvent {
+	events := make(chan *ConnectionEvent, 100)
+	service.UseConnectionListener(func(event *ConnectionEvent) {
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	return events
+}
+
+// waitForConnectionEvent waits for the next event of a type, skipping others
+func waitForConnectionEvent(t *testing.T, events chan *ConnectionEvent, eventType ConnectionEventType) *ConnectionEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", eventType)
+			return nil
+		}
+	}
+}
+
+func TestTokenLifecycleEndpoints(t *testing.T) {
+	var authorization string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`[{"value": "acme", "label": "Acme"}]`))
+	}))
+	defer upstream.Close()
+
+	fake := NewFakeAuthProvider()
+	fake.OAuthTTL = time.Hour
+	service := NewAuthService()
+	service.RegisterOAuthProvider("", fake)
+	events := connectionEvents(service)
+
+	handler := NewAPIHandler()
+	handler.SetAuthService(service)
+	handler.SetStaffAuthorizer(func(r *http.Request) (string, bool) {
+		return "admin", r.Header.Get("X-Staff") == "yes"
+	})
+	require.NoError(t, handler.RegisterSchema(NewForm("deal", "Deal").
+		AddField(NewFieldBuilder("account", FieldTypeSelect, "Account").
+			WithDynamicOptionsConfig(NewOptionsBuilder().Dynamic().FromAPI(upstream.URL, "GET").UsingService("crm").Build()).
+			Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	anonymous := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Staff", "yes")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/api/auth/oauth", `{"code": "abc", "scope": "read write", "serviceId": "crm"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var token TokenInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &token))
+	assert.Equal(t, "fake-oauth-1", token.Token)
+	assert.Equal(t, []string{"read", "write"}, token.Scopes)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, time.Minute)
+	assert.NotContains(t, rec.Body.String(), "refresh")
+
+	// Tokens are managed by staff only
+	assert.Equal(t, http.StatusForbidden, anonymous(http.MethodGet, "/api/auth/tokens/crm", "").Code)
+	assert.Equal(t, http.StatusForbidden, anonymous(http.MethodPost, "/api/auth/tokens/crm/refresh", "").Code)
+	assert.Equal(t, http.StatusForbidden, anonymous(http.MethodDelete, "/api/auth/tokens/crm", "").Code)
+	assert.Empty(t, fake.Calls()[1:], "anonymous requests reach no provider")
+
+	rec = request(http.MethodGet, "/api/auth/tokens/crm", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tokens []*TokenInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	require.Len(t, tokens, 1)
+	assert.Equal(t, AuthTypeOAuth, tokens[0].Type)
+	assert.Equal(t, DefaultAuthProvider, tokens[0].Provider)
+	assert.Empty(t, tokens[0].Token)
+
+	rec = request(http.MethodPost, "/api/auth/tokens/crm/refresh", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, ConnectionRefreshed, waitForConnectionEvent(t, events, ConnectionRefreshed).Type)
+	current, ok := service.GetTokenInfo(AuthTypeOAuth, "crm")
+	require.True(t, ok)
+	assert.Equal(t, "fake-refresh-2", current.Token)
+	assert.Equal(t, []string{"read", "write"}, current.Scopes)
+
+	// Sources of a connected service are authorized with its token
+	rec = request(http.MethodGet, "/api/options/deal/account", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "Bearer fake-refresh-2", authorization)
+
+	rec = request(http.MethodDelete, "/api/auth/tokens/crm", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	event := waitForConnectionEvent(t, events, ConnectionRevoked)
+	assert.Equal(t, "crm", event.ServiceID)
+	calls := fake.Calls()
+	assert.Equal(t, "revoke", calls[len(calls)-1].Type)
+	assert.Equal(t, "fake-refresh-2", calls[len(calls)-1].Data["token"])
+
+	// Once disconnected, its sources are unavailable
+	rec = request(http.MethodGet, "/api/options/deal/account", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), ServiceDisconnectedCode)
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, "/api/auth/tokens/crm", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/api/auth/tokens/crm", "").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/api/auth/tokens/crm/refresh", "").Code)
+}
+
+func TestTokenRefreshScheduling(t *testing.T) {
+	fake := NewFakeAuthProvider()
+	fake.OAuthTTL = time.Hour
+	service := NewAuthService()
+	service.RegisterOAuthProvider("", fake)
+	service.SetRefreshLeeway(time.Hour - 20*time.Millisecond)
+	events := connectionEvents(service)
+
+	token, err := service.AuthenticateToken(t.Context(), AuthTypeOAuth, "", map[string]string{"code": "abc"})
+	require.NoError(t, err)
+	service.StoreToken("crm", token)
+
+	event := waitForConnectionEvent(t, events, ConnectionRefreshed)
+	assert.Equal(t, "crm", event.ServiceID)
+	assert.Empty(t, event.Token.Token)
+	refreshed, ok := service.GetToken("crm")
+	assert.True(t, ok)
+	assert.NotEqual(t, token.Token, refreshed)
+
+	require.NoError(t, service.RevokeToken(t.Context(), "crm"))
+	waitForConnectionEvent(t, events, ConnectionRevoked)
+	_, ok = service.GetToken("crm")
+	assert.False(t, ok)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	broken := NewFakeAuthProvider()
+	broken.Err = errors.New("provider unavailable")
+	service := NewAuthService()
+	service.RegisterOAuthProvider("broken", broken)
+	events := connectionEvents(service)
+
+	service.StoreToken("erp", &TokenInfo{Token: "jwt", Type: AuthTypeJWT, ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+	_, ok := service.GetJWTToken("erp")
+	assert.True(t, ok)
+	event := waitForConnectionEvent(t, events, ConnectionExpired)
+	assert.Equal(t, AuthTypeJWT, event.Token.Type)
+	_, ok = service.GetJWTToken("erp")
+	assert.False(t, ok)
+
+	// Tokens that cannot be refreshed are kept until they expire
+	service.StoreToken("crm", &TokenInfo{
+		Token:        "oauth",
+		Type:         AuthTypeOAuth,
+		Provider:     "broken",
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(300 * time.Millisecond),
+	})
+	event = waitForConnectionEvent(t, events, ConnectionRefreshFailed)
+	assert.EqualError(t, event.Err, "provider unavailable")
+	token, ok := service.GetToken("crm")
+	assert.True(t, ok)
+	assert.Equal(t, "oauth", token)
+	waitForConnectionEvent(t, events, ConnectionExpired)
+	_, ok = service.GetToken("crm")
+	assert.False(t, ok)
+}
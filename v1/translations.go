@@ -0,0 +1,132 @@
+package smartform
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LocaleQueryParam overrides the locale negotiated from the Accept-Language header
+const LocaleQueryParam = "locale"
+
+// TranslationBundle adds translations for a locale such as "fr" or "pt-BR". Keys name the text
+// they replace: "title" and "description" for the form, and "<field path>.label",
+// ".placeholder", ".helpText", or ".validation.<rule type>" for fields, e.g.
+// "address.city.label" or "email.validation.email". Translations may use templates.
+// Bundles added for the same locale are merged.
+func (fb *FormBuilder) TranslationBundle(locale string, translations map[string]string) *FormBuilder {
+	if fb.schema.Translations == nil {
+		fb.schema.Translations = make(map[string]map[string]string)
+	}
+	bundle := fb.schema.Translations[locale]
+	if bundle == nil {
+		bundle = make(map[string]string, len(translations))
+		fb.schema.Translations[locale] = bundle
+	}
+	for key, text := range translations {
+		bundle[key] = text
+	}
+	return fb
+}
+
+// Locales returns the locales the schema has translations for, sorted
+func (fs *FormSchema) Locales() []string {
+	locales := make([]string, 0, len(fs.Translations))
+	for locale := range fs.Translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// NegotiateLocale picks the best of the available locales for an Accept-Language header value,
+// e.g. "fr-CA,fr;q=0.9,en;q=0.5". A requested "fr-CA" matches "fr-CA" or, failing that, "fr".
+// It returns "" if no available locale is acceptable.
+func NegotiateLocale(acceptLanguage string, available []string) string {
+	type preference struct {
+		tag     string
+		quality float64
+	}
+
+	preferences := []preference{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" && quality > 0 {
+			preferences = append(preferences, preference{tag: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+
+	for _, preference := range preferences {
+		if locale := matchLocale(preference.tag, available); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// matchLocale finds a locale for a language tag, falling back to the tag's base language
+func matchLocale(tag string, available []string) string {
+	for {
+		for _, locale := range available {
+			if strings.EqualFold(locale, tag) {
+				return locale
+			}
+		}
+		index := strings.LastIndex(tag, "-")
+		if index < 0 {
+			return ""
+		}
+		tag = tag[:index]
+	}
+}
+
+// SetLocale makes the renderer substitute the schema's translations for the locale
+func (fr *FormRenderer) SetLocale(locale string) {
+	fr.locale = locale
+	fr.translations = fr.schema.Translations[locale]
+}
+
+// translate returns the translation of a text by its key, in which array indexes are ignored,
+// or the text itself if there is none
+func (fr *FormRenderer) translate(key, text string) string {
+	if translation, ok := fr.translations[pathIndexPattern.ReplaceAllString(key, "")]; ok {
+		return translation
+	}
+	return text
+}
+
+// TranslateErrors replaces the messages of validation errors with their translations for a
+// locale, looked up by "<field path>.validation.<rule type>" with array indexes removed
+func (fs *FormSchema) TranslateErrors(result *ValidationResult, locale string) {
+	bundle := fs.Translations[locale]
+	if bundle == nil {
+		return
+	}
+	for _, err := range result.Errors {
+		key := pathIndexPattern.ReplaceAllString(err.FieldID, "") + ".validation." + err.RuleType
+		if translation, ok := bundle[key]; ok {
+			err.Message = translation
+		}
+	}
+}
+
+// requestLocale negotiates the locale of a rendered form from the locale query parameter or the
+// Accept-Language header
+func requestLocale(query url.Values, acceptLanguage string, schema *FormSchema) string {
+	if locale := query.Get(LocaleQueryParam); locale != "" {
+		return matchLocale(locale, schema.Locales())
+	}
+	return NegotiateLocale(acceptLanguage, schema.Locales())
+}
@@ -0,0 +1,79 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	available := []string{"en", "fr", "pt-BR"}
+	assert.Equal(t, "fr", NegotiateLocale("fr-CA,fr;q=0.9,en;q=0.5", available))
+	assert.Equal(t, "en", NegotiateLocale("de,en;q=0.8", available))
+	assert.Equal(t, "pt-BR", NegotiateLocale("pt-br", available))
+	assert.Equal(t, "en", NegotiateLocale("fr;q=0.2,en;q=0.7", available))
+	assert.Equal(t, "", NegotiateLocale("de, *;q=0.1", available))
+	assert.Equal(t, "", NegotiateLocale("", available))
+}
+
+func TestTranslatedForm(t *testing.T) {
+	handler := NewAPIHandler()
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Sign up").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").
+			Placeholder("you@example.com").
+			Required(true).
+			ValidateEmail("Enter a valid email").
+			Build()).
+		AddField(NewFieldBuilder("name", FieldTypeText, "Name").Build()).
+		TranslationBundle("fr", map[string]string{
+			"title":                  "Inscription",
+			"email.label":            "Courriel",
+			"email.placeholder":      "vous@exemple.fr",
+			"email.validation.email": "Saisissez un courriel valide",
+		}).
+		TranslationBundle("fr", map[string]string{"email.validation.required": "Le courriel est obligatoire"}).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	render := func(path, acceptLanguage string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var rendered map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+		return rec, rendered
+	}
+
+	rec, rendered := render("/api/forms/signup", "fr-CA,fr;q=0.9,en;q=0.5")
+	assert.Equal(t, "fr", rec.Header().Get("Content-Language"))
+	assert.Equal(t, "Accept-Language", rec.Header().Get("Vary"))
+	assert.Equal(t, "Inscription", rendered["title"])
+	email := rendered["fields"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Courriel", email["label"])
+	assert.Equal(t, "vous@exemple.fr", email["placeholder"])
+	assert.Equal(t, "Saisissez un courriel valide", email["validationRules"].([]interface{})[0].(map[string]interface{})["message"])
+	assert.Equal(t, "Name", rendered["fields"].([]interface{})[1].(map[string]interface{})["label"])
+	assert.NotContains(t, rendered, "translations")
+
+	// Untranslated locales get the texts the form was built with
+	rec, rendered = render("/api/forms/signup?locale=de", "fr")
+	assert.Empty(t, rec.Header().Get("Content-Language"))
+	assert.Equal(t, "Sign up", rendered["title"])
+
+	req := httptest.NewRequest(http.MethodPost, "/api/validate/signup", strings.NewReader(`{"name": "Ada"}`))
+	req.Header.Set("Accept-Language", "fr")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	var result ValidationResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "Le courriel est obligatoire", result.Errors[0].Message)
+}
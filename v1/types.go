@@ -9,15 +9,41 @@ import (
 
 // FormSchema represents the entire form structure
 type FormSchema struct {
-	ID               string                 `json:"id"`
-	Title            string                 `json:"title"`
-	Description      string                 `json:"description,omitempty"`
-	Type             FormType               `json:"type"`               // Type of form (regular or auth)
-	AuthType         AuthStrategy           `json:"authType,omitempty"` // Auth type if this is an auth form
-	Fields           []*Field               `json:"fields"`
-	Properties       map[string]interface{} `json:"properties,omitempty"`
-	validator        *Validator
-	variableRegistry *template.VariableRegistry `json:"-"`
+	ID                string                       `json:"id"`
+	Title             string                       `json:"title"`
+	Version           string                       `json:"version,omitempty"` // Semantic version, e.g. "2.1.0"
+	Description       string                       `json:"description,omitempty"`
+	Type              FormType                     `json:"type"`               // Type of form (regular or auth)
+	AuthType          AuthStrategy                 `json:"authType,omitempty"` // Auth type if this is an auth form
+	Fields            []*Field                     `json:"fields"`
+	Properties        map[string]interface{}       `json:"properties,omitempty"`
+	Scripts           []*ScriptDefinition          `json:"scripts,omitempty"`         // Server-side scripted functions and transformers
+	RemoteFragments   []*RemoteFragmentRef         `json:"remoteFragments,omitempty"` // Fragments merged in from other services
+	Fragments         []*FragmentRef               `json:"fragments,omitempty"`       // Registered fragments the form includes
+	Examples          []*SubmissionExample         `json:"examples,omitempty"`        // Example submissions checked at registration
+	Parent            *ParentBinding               `json:"parent,omitempty"`          // Record the form is launched from
+	Output            *OutputMapping               `json:"output,omitempty"`          // Reshapes submitted data for downstream systems
+	Duplicates        []*DuplicateRule             `json:"duplicates,omitempty"`      // Rules for detecting repeated submissions
+	Unique            []*UniqueConstraint          `json:"unique,omitempty"`          // Field combinations no two submissions may share
+	Confirmation      *ConfirmationConfig          `json:"confirmation,omitempty"`    // Submissions wait for a one-time code
+	AsyncSubmit       bool                         `json:"asyncSubmit,omitempty"`     // Submissions are processed in the background
+	Autosave          *AutosaveConfig              `json:"autosave,omitempty"`        // Retention of drafts and their resume links
+	Environments      map[string]*SchemaOverlay    `json:"environments,omitempty"`    // Overlays applied per deployment environment
+	Translations      map[string]map[string]string `json:"translations,omitempty"`    // Localized texts by locale and key
+	Annotations       Annotations                  `json:"annotations,omitempty"`     // Metadata of downstream tools, keyed by vendor-prefixed names
+	Tags              []string                     `json:"tags,omitempty"`            // Labels the form list can be filtered by
+	Category          string                       `json:"category,omitempty"`        // Catalog category the form is grouped under
+	Owner             string                       `json:"owner,omitempty"`           // Team or person responsible for the form
+	Metadata          map[string]interface{}       `json:"metadata,omitempty"`        // Catalog metadata, such as an icon
+	Print             *PrintLayout                 `json:"print,omitempty"`           // Layout of printed copies
+	validator         *Validator
+	templateEvaluator TemplateEvaluator
+	templateLimits    *template.Limits
+	clock             Clock
+	telemetry         Telemetry
+	prefills          map[string]string          // Parent record paths of prefilled fields, by field ID
+	compiled          *CompiledSchema            // Pre-serialized parts renderers use, set by Compile
+	variableRegistry  *template.VariableRegistry `json:"-"`
 
 	// Map of registered functions - not serialized
 	functions map[string]DynamicFunction `json:"-"`
@@ -34,14 +60,28 @@ type Field struct {
 	Enabled         *Condition             `json:"enabled,omitempty"`
 	DefaultValue    interface{}            `json:"defaultValue,omitempty"`
 	DefaultWhen     []*DefaultWhen         `json:"defaultWhen,omitempty"`
+	ComputedValue   string                 `json:"computedValue,omitempty"` // Template expression recomputed when the fields it refers to change
 	Placeholder     string                 `json:"placeholder,omitempty"`
 	HelpText        string                 `json:"helpText,omitempty"`
+	Help            *HelpContent           `json:"help,omitempty"`      // Structured help, alongside the plain HelpText
+	Consent         *ConsentConfig         `json:"consent,omitempty"`   // Legal text of consent fields
+	Signature       *SignatureConfig       `json:"signature,omitempty"` // Pad and checks of signature fields
+	Phone           *PhoneConfig           `json:"phone,omitempty"`     // Parsing of phone fields
+	Currency        *CurrencyConfig        `json:"currency,omitempty"`  // Amounts accepted by currency fields
+	TimeZone        string                 `json:"timeZone,omitempty"`  // IANA time zone of time and datetime fields
+	DateRange       *DateRangeConfig       `json:"dateRange,omitempty"` // Ranges accepted by date range fields
 	ValidationRules []*ValidationRule      `json:"validationRules,omitempty"`
 	Properties      map[string]interface{} `json:"properties,omitempty"`
 	Order           int                    `json:"order"`
 	Options         *OptionsConfig         `json:"options,omitempty"`
 	Nested          []*Field               `json:"nested,omitempty"` // For group, oneOf, anyOf fields
 	Multiline       bool                   `json:"multiline,omitempty"`
+	Verify          bool                   `json:"verify,omitempty"`      // Check the value with an email, phone, or address verifier
+	Annotations     Annotations            `json:"annotations,omitempty"` // Metadata of downstream tools, keyed by vendor-prefixed names
+	Print           *PrintHints            `json:"print,omitempty"`       // Layout of the field on printed copies
+	ReadRoles       []string               `json:"readRoles,omitempty"`   // Roles that may see the field; everyone when empty
+	WriteRoles      []string               `json:"writeRoles,omitempty"`  // Roles that may submit the field; everyone who sees it when empty
+	Sensitive       bool                   `json:"sensitive,omitempty"`   // Values are encrypted at rest and masked when shown
 }
 
 // Condition represents a conditional expression for field visibility or enablement
@@ -68,6 +108,7 @@ type OptionsConfig struct {
 	Static        []*Option          `json:"static,omitempty"`
 	DynamicSource *DynamicSource     `json:"dynamicSource,omitempty"`
 	Dependency    *OptionsDependency `json:"dependency,omitempty"`
+	ValueType     OptionValueType    `json:"valueType,omitempty"` // Declared type of the option values
 }
 
 // OptionsType defines how options are sourced
@@ -75,16 +116,18 @@ type OptionsType string
 
 // Define options types
 const (
-	OptionsTypeStatic    OptionsType = "static"    // Hardcoded options
-	OptionsTypeDynamic   OptionsType = "dynamic"   // Dynamically loaded options
-	OptionsTypeDependent OptionsType = "dependent" // Options depend on another field
+	OptionsTypeStatic      OptionsType = "static"      // Hardcoded options
+	OptionsTypeDynamic     OptionsType = "dynamic"     // Dynamically loaded options
+	OptionsTypeDependent   OptionsType = "dependent"   // Options depend on another field
+	OptionsTypeConditional OptionsType = "conditional" // Static options offered while their conditions hold
 )
 
 // Option represents a single option for select-type fields
 type Option struct {
-	Value interface{} `json:"value"`
-	Label string      `json:"label"`
-	Icon  string      `json:"icon,omitempty"`
+	Value     interface{} `json:"value"`
+	Label     string      `json:"label"`
+	Icon      string      `json:"icon,omitempty"`
+	Condition *Condition  `json:"condition,omitempty"` // Form state the option is offered in, for conditional options
 }
 
 // DynamicSource defines where to get dynamic options from
@@ -99,6 +142,12 @@ type DynamicSource struct {
 	RefreshOn      []string               `json:"refreshOn,omitempty"` // Fields that trigger refresh
 	FunctionName   string                 `json:"functionName,omitempty"`
 	FunctionConfig *DynamicFieldConfig    `json:"functionConfig,omitempty"`
+	WindowSource   string                 `json:"windowSource,omitempty"` // Registered OptionWindowSource serving huge lists
+	PageSize       int                    `json:"pageSize,omitempty"`     // Default window size for windowed sources
+	Mock           []*Option              `json:"mock,omitempty"`         // Representative options shown in previews
+	ServiceID      string                 `json:"serviceId,omitempty"`    // Connected service whose token authorizes API requests
+	CachePolicy    *OptionCachePolicy     `json:"cache,omitempty"`        // How long fetched options are cached (default: the service TTL)
+	Fallback       []*Option              `json:"fallback,omitempty"`     // Options served while the API cannot be fetched
 
 	// This won't be serialized to JSON but allows passing a direct function reference
 	// when creating the options - won't survive serialization
@@ -121,14 +170,23 @@ type ValidationError struct {
 
 // ValidationResult holds the result of validating the entire form
 type ValidationResult struct {
-	Valid  bool               `json:"valid"`
-	Errors []*ValidationError `json:"errors,omitempty"`
+	Valid         bool                          `json:"valid"`
+	Errors        []*ValidationError            `json:"errors,omitempty"`
+	Sections      map[string][]*ValidationError `json:"sections,omitempty"`      // Errors grouped by section or group ID
+	Truncated     bool                          `json:"truncated,omitempty"`     // The error limit was reached, so further errors may exist
+	Coercions     []*ValueCoercion              `json:"coercions,omitempty"`     // Values converted to their field's data type
+	Tampered      []*TamperFinding              `json:"tampered,omitempty"`      // Values submitted for hidden or disabled fields
+	Verifications []*VerificationResult         `json:"verifications,omitempty"` // Outcomes of email, phone, and address verification
 }
 
 // CacheEntry represents a cached API response
 type CacheEntry struct {
 	Data      []byte
 	Timestamp time.Time
+	Identity  CacheIdentity // Who the entry was fetched for, if the cache is partitioned
+	Source    string        // The source the entry was fetched from, see sourceCacheTag
+
+	lifetime time.Duration // How long after Timestamp the entry may be served, stale or not
 }
 
 // NewFormSchema creates a new form schema instance
@@ -197,6 +255,12 @@ func (fs *FormSchema) RegisterVariableFunction(name string, fn template.Template
 	return fs
 }
 
+// RegisterTypedVariableFunction registers a typed Go function in the form's registry, see
+// template.VariableRegistry.RegisterTypedFunction
+func (fs *FormSchema) RegisterTypedVariableFunction(name string, fn interface{}, doc template.FunctionDoc) error {
+	return fs.variableRegistry.RegisterTypedFunction(name, fn, doc)
+}
+
 // GetVariableRegistry returns the form's variable registry
 func (fs *FormSchema) GetVariableRegistry() *template.VariableRegistry {
 	return fs.variableRegistry
@@ -0,0 +1,311 @@
+package smartform
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrUniqueIndexUnsupported is returned when a form has unique constraints but the submission
+// store does not implement UniqueIndex
+var ErrUniqueIndexUnsupported = errors.New("submission store does not provide a unique index")
+
+// ErrUniqueHashKeyMissing is returned when sensitive values are encrypted and a unique
+// constraint covers a sensitive field, but no key to hash its values with is set
+var ErrUniqueHashKeyMissing = errors.New("unique constraints on sensitive fields need a hash key, see SetUniqueHashKey")
+
+// UniqueConstraint declares that no two stored submissions of a form may share the values of
+// all of the given fields, such as one registration per email per event. Submissions missing a
+// value of the constraint are not held to it.
+type UniqueConstraint struct {
+	Name       string   `json:"name"`
+	Fields     []string `json:"fields"`
+	IgnoreCase bool     `json:"ignoreCase,omitempty"` // Compare text regardless of case
+	Message    string   `json:"message,omitempty"`    // Error reported on the fields of a conflict
+}
+
+// UniqueKey identifies the values a submission claims under a unique constraint
+type UniqueKey struct {
+	Constraint string `json:"constraint"`
+	Value      string `json:"value"` // Canonical encoding of the values of the constraint's fields
+}
+
+// UniqueIndex is implemented by submission stores that enforce unique constraints
+type UniqueIndex interface {
+	// Reserve claims keys of a form for a submission atomically, all of them or none. When
+	// another submission holds one of them, it fails with a *UniqueConflictError.
+	Reserve(formID, submissionID string, keys []UniqueKey) error
+	// Release frees the keys held by a submission
+	Release(formID, submissionID string) error
+	// Lookup returns the ID of the submission holding a key, or "" when the key is free
+	Lookup(formID string, key UniqueKey) (string, error)
+}
+
+// UniqueConflictError reports that the values of a unique constraint are held by an earlier
+// submission
+type UniqueConflictError struct {
+	Constraint   string   `json:"constraint"`
+	Fields       []string `json:"fields"`
+	SubmissionID string   `json:"-"` // Submission holding the values, never disclosed to clients
+}
+
+// Error implements the error interface
+func (e *UniqueConflictError) Error() string {
+	return fmt.Sprintf("a submission with the same %s already exists", strings.Join(e.Fields, ", "))
+}
+
+// UniqueKeys returns the keys the data claims under the schema's unique constraints
+func (fs *FormSchema) UniqueKeys(data map[string]interface{}) []UniqueKey {
+	validator := NewValidator(fs)
+	keys := []UniqueKey{}
+	for _, constraint := range fs.Unique {
+		if key, ok := uniqueKey(validator, constraint, data); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// uniqueKey encodes the values of a constraint's fields, failing when one of them is empty
+func uniqueKey(validator *Validator, constraint *UniqueConstraint, data map[string]interface{}) (UniqueKey, bool) {
+	values := make([]interface{}, len(constraint.Fields))
+	for i, fieldID := range constraint.Fields {
+		value := validator.getValueByPath(data, fieldID)
+		if validator.isEmpty(value) {
+			return UniqueKey{}, false
+		}
+		if s, ok := value.(string); ok {
+			s = strings.TrimSpace(s)
+			if constraint.IgnoreCase {
+				s = strings.ToLower(s)
+			}
+			value = s
+		}
+		values[i] = value
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return UniqueKey{}, false
+	}
+	return UniqueKey{Constraint: constraint.Name, Value: string(encoded)}, true
+}
+
+// coversSensitive reports whether a constraint covers a sensitive field, or a field of a
+// sensitive group
+func (fs *FormSchema) coversSensitive(constraint *UniqueConstraint) bool {
+	engine := NewStateEngine(fs)
+	for _, path := range constraint.Fields {
+		segments := strings.Split(path, ".")
+		for i := range segments {
+			field, err := engine.findFieldByPath(strings.Join(segments[:i+1], "."))
+			if err == nil && field.Sensitive {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetUniqueHashKey sets the key the values of unique constraints are hashed with, so that the
+// unique index of the submission store holds an HMAC of them rather than the values
+// themselves. It is required when sensitive values are encrypted and a constraint covers a
+// sensitive field. Changing it frees the values claimed by earlier submissions.
+func (ah *APIHandler) SetUniqueHashKey(key []byte) {
+	ah.schemasLock.Lock()
+	defer ah.schemasLock.Unlock()
+	ah.uniqueHashKey = append([]byte(nil), key...)
+}
+
+// indexKey returns the key the values of a constraint are stored under in the unique index
+func (ah *APIHandler) indexKey(schema *FormSchema, key UniqueKey) (UniqueKey, error) {
+	ah.schemasLock.RLock()
+	hashKey := ah.uniqueHashKey
+	ah.schemasLock.RUnlock()
+
+	if len(hashKey) == 0 {
+		constraint := schema.uniqueConstraint(key.Constraint)
+		if ah.fieldEncryptor != nil && constraint != nil && schema.coversSensitive(constraint) {
+			return UniqueKey{}, ErrUniqueHashKeyMissing
+		}
+		return key, nil
+	}
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(key.Value))
+	return UniqueKey{Constraint: key.Constraint, Value: "hmac-sha256-" + hex.EncodeToString(mac.Sum(nil))}, nil
+}
+
+// uniqueConstraint returns the unique constraint with the given name
+func (fs *FormSchema) uniqueConstraint(name string) *UniqueConstraint {
+	for _, constraint := range fs.Unique {
+		if constraint.Name == name {
+			return constraint
+		}
+	}
+	return nil
+}
+
+// CheckUniqueConstraints checks that unique constraints are named uniquely and refer to fields
+// of the form
+func (fs *FormSchema) CheckUniqueConstraints() error {
+	engine := NewStateEngine(fs)
+	names := map[string]bool{}
+	for _, constraint := range fs.Unique {
+		if constraint.Name == "" {
+			return fmt.Errorf("unique constraint on %s has no name", strings.Join(constraint.Fields, ", "))
+		}
+		if names[constraint.Name] {
+			return fmt.Errorf("unique constraint %s is declared twice", constraint.Name)
+		}
+		names[constraint.Name] = true
+
+		if len(constraint.Fields) == 0 {
+			return fmt.Errorf("unique constraint %s has no fields", constraint.Name)
+		}
+		for _, fieldID := range constraint.Fields {
+			if _, err := engine.findFieldByPath(fieldID); err != nil {
+				return fmt.Errorf("unique constraint %s refers to unknown field %s", constraint.Name, fieldID)
+			}
+		}
+	}
+	return nil
+}
+
+// uniqueConflictErrors returns validation errors on the fields of a conflicting constraint,
+// restricted to fieldID unless it is empty
+func (fs *FormSchema) uniqueConflictErrors(constraint *UniqueConstraint, fieldID string) []*ValidationError {
+	errs := []*ValidationError{}
+	engine := NewStateEngine(fs)
+	for _, path := range constraint.Fields {
+		if fieldID != "" && path != fieldID {
+			continue
+		}
+		message := constraint.Message
+		if message == "" {
+			label := path
+			if field, err := engine.findFieldByPath(path); err == nil && field.Label != "" {
+				label = field.Label
+			}
+			message = fmt.Sprintf("%s is already taken", label)
+		}
+		errs = append(errs, &ValidationError{
+			FieldID:  path,
+			Message:  message,
+			RuleType: string(ValidationTypeUnique),
+		})
+	}
+	return errs
+}
+
+// checkUnique adds errors for the unique constraints whose values an earlier submission
+// holds, so conflicts are reported before the form is submitted. Only constraints on fieldID
+// are checked unless it is empty.
+func (ah *APIHandler) checkUnique(schema *FormSchema, data map[string]interface{}, fieldID string, result *ValidationResult) error {
+	index, ok := ah.submissionStore.(UniqueIndex)
+	if !ok || len(schema.Unique) == 0 {
+		return nil
+	}
+
+	validator := NewValidator(schema)
+	for _, constraint := range schema.Unique {
+		if fieldID != "" && !slices.Contains(constraint.Fields, fieldID) {
+			continue
+		}
+		key, ok := uniqueKey(validator, constraint, data)
+		if !ok {
+			continue
+		}
+		key, err := ah.indexKey(schema, key)
+		if err != nil {
+			return err
+		}
+		holder, err := index.Lookup(schema.ID, key)
+		if err != nil {
+			return err
+		}
+		if holder != "" {
+			result.Errors = append(result.Errors, schema.uniqueConflictErrors(constraint, fieldID)...)
+			result.Valid = false
+		}
+	}
+	return nil
+}
+
+// reserveUnique claims the keys of a submission in the submission store, before it is saved so
+// that concurrent submissions of the same values cannot both succeed
+func (ah *APIHandler) reserveUnique(schema *FormSchema, submissionID string, data map[string]interface{}) error {
+	keys := schema.UniqueKeys(data)
+	if ah.submissionStore == nil || len(keys) == 0 {
+		return nil
+	}
+	index, ok := ah.submissionStore.(UniqueIndex)
+	if !ok {
+		return ErrUniqueIndexUnsupported
+	}
+	for i, key := range keys {
+		indexed, err := ah.indexKey(schema, key)
+		if err != nil {
+			return err
+		}
+		keys[i] = indexed
+	}
+
+	err := index.Reserve(schema.ID, submissionID, keys)
+	var conflict *UniqueConflictError
+	if errors.As(err, &conflict) {
+		if constraint := schema.uniqueConstraint(conflict.Constraint); constraint != nil {
+			conflict.Fields = constraint.Fields
+		}
+	}
+	return err
+}
+
+// uniqueConflictResponse returns the status and body of the submit response when the values of
+// unique constraints could not be claimed
+func uniqueConflictResponse(schema *FormSchema, err error) (int, map[string]interface{}) {
+	var conflict *UniqueConflictError
+	if !errors.As(err, &conflict) {
+		return submissionErrorResponse(schema.ID, fmt.Errorf("error reserving unique values: %w", err), http.StatusInternalServerError)
+	}
+
+	response := map[string]interface{}{
+		"success":  false,
+		"code":     ErrorCodeConflict,
+		"message":  "A submission with the same values already exists",
+		"formId":   schema.ID,
+		"conflict": conflict,
+	}
+	if constraint := schema.uniqueConstraint(conflict.Constraint); constraint != nil {
+		errs := schema.uniqueConflictErrors(constraint, "")
+		response["errors"] = errs
+		if len(errs) > 0 {
+			response["field"] = errs[0].FieldID
+		}
+	}
+	return http.StatusConflict, response
+}
+
+// releaseUnique frees the keys claimed for a submission that was not saved
+func (ah *APIHandler) releaseUnique(schema *FormSchema, submissionID string) {
+	if index, ok := ah.submissionStore.(UniqueIndex); ok && len(schema.Unique) > 0 {
+		_ = index.Release(schema.ID, submissionID)
+	}
+}
+
+// Unique adds a constraint that no two submissions share the values of the given fields
+func (fb *FormBuilder) Unique(name string, fields ...string) *FormBuilder {
+	fb.schema.Unique = append(fb.schema.Unique, &UniqueConstraint{Name: name, Fields: fields})
+	return fb
+}
+
+// UniqueIgnoringCase adds a unique constraint that compares text regardless of case
+func (fb *FormBuilder) UniqueIgnoringCase(name string, fields ...string) *FormBuilder {
+	fb.schema.Unique = append(fb.schema.Unique, &UniqueConstraint{Name: name, Fields: fields, IgnoreCase: true})
+	return fb
+}
@@ -0,0 +1,174 @@
+package smartform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRegistrationForm() *FormSchema {
+	form := NewForm("registration", "Registration")
+	form.EmailField("email", "Email").Required(true)
+	form.TextField("event", "Event").Required(true)
+	form.TextField("name", "Name")
+	form.UniqueIgnoringCase("email-per-event", "email", "event")
+	return form.Build()
+}
+
+func TestUniqueConstraints(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	require.NoError(t, handler.RegisterSchema(newRegistrationForm()))
+
+	persistFails := false
+	handler.UseSubmissionHandler("registration", SubmissionStagePersist, SubmissionHandlerFunc(func(s *SubmissionContext, next func() error) error {
+		if persistFails {
+			return errors.New("database unavailable")
+		}
+		return next()
+	}))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	post := func(path, body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+		return rec.Code, response
+	}
+
+	status, _ := post("/api/submit/registration", `{"email": "alice@example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// The same email registers for another event, but not twice for the same one
+	status, _ = post("/api/submit/registration", `{"email": "alice@example.com", "event": "kubecon"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, response := post("/api/submit/registration", `{"email": " Alice@Example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, map[string]interface{}{
+		"constraint": "email-per-event",
+		"fields":     []interface{}{"email", "event"},
+	}, response["conflict"])
+	require.Len(t, response["errors"], 2)
+	assert.Equal(t, map[string]interface{}{"fieldId": "email", "message": "Email is already taken", "ruleType": "unique"}, response["errors"].([]interface{})[0])
+
+	// Validation reports the conflict before the form is submitted, for one field too
+	status, response = post("/api/validate/registration", `{"email": "alice@example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, response["valid"])
+	status, response = post("/api/validate/registration/field/email", `{"email": "alice@example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusOK, status)
+	require.Len(t, response["errors"], 1)
+	status, response = post("/api/validate/registration", `{"email": "bob@example.com", "event": "gophercon"}`)
+	assert.Equal(t, true, response["valid"])
+
+	// Values of a submission that fails to save are released
+	persistFails = true
+	status, _ = post("/api/submit/registration", `{"email": "bob@example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	persistFails = false
+	status, _ = post("/api/submit/registration", `{"email": "bob@example.com", "event": "gophercon"}`)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestUniqueConstraintsConcurrentSubmissions(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(NewMemorySubmissionStore())
+	require.NoError(t, handler.RegisterSchema(newRegistrationForm()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 20)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/registration",
+				strings.NewReader(`{"email": "carol@example.com", "event": "gophercon"}`)))
+			statuses[i] = rec.Code
+		}(i)
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, status := range statuses {
+		if status == http.StatusOK {
+			accepted++
+		} else {
+			assert.Equal(t, http.StatusConflict, status)
+		}
+	}
+	assert.Equal(t, 1, accepted)
+}
+
+func TestUniqueConstraintsRequireIndex(t *testing.T) {
+	// A store that does not implement UniqueIndex cannot enforce constraints
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(struct{ SubmissionStore }{NewMemorySubmissionStore()})
+	require.NoError(t, handler.RegisterSchema(newRegistrationForm()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/registration",
+		strings.NewReader(`{"email": "dave@example.com", "event": "gophercon"}`)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrUniqueIndexUnsupported.Error())
+
+	// Constraints must refer to fields of the form
+	form := NewForm("broken", "Broken")
+	form.EmailField("email", "Email")
+	form.Unique("email-per-event", "email", "event")
+	err := handler.RegisterSchema(form.Build())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unique constraint email-per-event refers to unknown field event")
+}
+
+func TestUniqueConstraintsOnSensitiveFields(t *testing.T) {
+	encryptor, err := NewAESGCMEncryptor(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	store := NewMemorySubmissionStore()
+	handler := NewAPIHandler()
+	handler.SetSubmissionStore(store)
+	handler.SetFieldEncryptor(encryptor)
+	require.NoError(t, handler.RegisterSchema(NewForm("claim", "Claim").
+		AddField(NewFieldBuilder("ssn", FieldTypeText, "SSN").Sensitive(true).Build()).
+		Unique("one-claim-per-person", "ssn").
+		Build()))
+	mux := handler.Handler()
+	submit := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/submit/claim", strings.NewReader(`{"ssn": "123-45-6789"}`)))
+		return rec
+	}
+
+	// Encrypted values are not indexed in the clear, so the index needs a key to hash them with
+	rec := submit()
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrUniqueHashKeyMissing.Error())
+
+	handler.SetUniqueHashKey([]byte("unique-key"))
+	assert.Equal(t, http.StatusOK, submit().Code)
+	assert.Equal(t, http.StatusConflict, submit().Code)
+	require.Len(t, store.unique, 1)
+	for key := range store.unique {
+		assert.NotContains(t, key, "123-45-6789")
+	}
+
+	// Conflicts are still reported when validating
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/claim", strings.NewReader(`{"ssn": "123-45-6789"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"valid":false`)
+}
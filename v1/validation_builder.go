@@ -77,6 +77,17 @@ func (vb *ValidationBuilder) Email(message string) *ValidationRule {
 	}
 }
 
+// Phone creates a rule rejecting values that are not possible phone numbers. Numbers entered
+// without a country code are parsed as numbers of the default country, an ISO 3166-1 alpha-2
+// code; with no default country they must have one.
+func (vb *ValidationBuilder) Phone(defaultCountry, message string) *ValidationRule {
+	return &ValidationRule{
+		Type:       ValidationTypePhone,
+		Message:    message,
+		Parameters: defaultCountry,
+	}
+}
+
 // URL creates a URL validation rule
 func (vb *ValidationBuilder) URL(message string) *ValidationRule {
 	return &ValidationRule{
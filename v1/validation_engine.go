@@ -6,11 +6,15 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // Validator handles form validation
 type Validator struct {
-	schema *FormSchema
+	schema  *FormSchema
+	options *ValidationOptions
+	files   map[string]storedFileResult // Stored files looked up during a run, by ID
+	now     time.Time                   // Time a run is evaluated at, frozen when it starts
 }
 
 // NewValidator creates a new validator for the given schema
@@ -27,33 +31,151 @@ func (v *Validator) ValidateForm(data map[string]interface{}) *ValidationResult
 
 	// Validate each field
 	for _, field := range v.schema.Fields {
-		v.validateField(field, data, "", result)
+		if v.limitReached(result) {
+			break
+		}
+		v.validateField(field, data, data, "", result)
 	}
 
+	// Handle values submitted for fields the client could not edit
+	v.detectTampering(data, result)
+
 	result.Valid = len(result.Errors) == 0
+	result.Truncated = v.limitReached(result)
+	v.groupErrorsBySection(result)
+	v.logFailures(data, result)
 	return result
 }
 
-// validateField validates a single field and its nested fields if applicable
-func (v *Validator) validateField(field *Field, data map[string]interface{}, prefix string, result *ValidationResult) {
+// ValidateField validates a single field, given by its path such as "address.street" or
+// "items[2].quantity", and the fields nested in it; a path ending in an array item, such as
+// "items[2]", validates the fields of the item. The rest of the form data is only used by
+// conditions and remote validators. It returns false if the schema has no such field.
+func (v *Validator) ValidateField(path string, data map[string]interface{}) (*ValidationResult, bool) {
+	result := &ValidationResult{
+		Valid:  true,
+		Errors: []*ValidationError{},
+	}
+
+	// Walk down to the field, passing it the same data and scope a full validation run would
+	root := data
+	scope := data
+	fields := v.schema.Fields
+	prefix := ""
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		id, index, indexed := splitIndexedSegment(segment)
+		var field *Field
+		for _, candidate := range fields {
+			if candidate.ID == id {
+				field = candidate
+				break
+			}
+		}
+		if field == nil || (indexed && field.Type != FieldTypeArray) {
+			return nil, false
+		}
+
+		last := i == len(segments)-1
+		if last && !indexed {
+			v.validateField(field, data, scope, prefix, result)
+			break
+		}
+
+		var nested map[string]interface{}
+		if indexed {
+			if items, _ := data[id].([]interface{}); index < len(items) {
+				nested, _ = items[index].(map[string]interface{})
+			}
+			if nested == nil {
+				nested = map[string]interface{}{}
+			}
+			scope = itemScope(scope, nested, data, index)
+			prefix = fmt.Sprintf("%s[%d]", joinPath(prefix, id), index)
+		} else {
+			nested, _ = data[id].(map[string]interface{})
+			if nested == nil {
+				nested = map[string]interface{}{}
+			}
+			scope = overlayVariables(scope, nested)
+			prefix = joinPath(prefix, id)
+		}
+		data = nested
+		fields = field.Nested
+
+		if last {
+			for _, nestedField := range fields {
+				v.validateField(nestedField, data, scope, prefix, result)
+			}
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	result.Truncated = v.limitReached(result)
+	v.groupErrorsBySection(result)
+	v.logFailures(root, result)
+	return result, true
+}
+
+// validateField validates a single field and its nested fields if applicable. Values are read
+// from data, the object holding the field, while conditions and rules are evaluated in scope:
+// the values of the field's group or array item over those of the enclosing objects, so that
+// fields refer to their siblings by ID and to other fields by their path, with the "item",
+// "parent", and "index" of array items.
+func (v *Validator) validateField(field *Field, data, scope map[string]interface{}, prefix string, result *ValidationResult) {
 	fieldPath := field.ID
 	if prefix != "" {
 		fieldPath = prefix + "." + field.ID
 	}
 
+	if v.limitReached(result) {
+		return
+	}
+
+	// Display fields are computed by the server, whether or not they are visible
+	if field.Type == FieldTypeDisplay {
+		v.rejectDisplayValue(field, fieldPath, data, result)
+		return
+	}
+
+	// Fields the caller may not write are not validated, only checked to be left out
+	if v.options != nil && v.options.Access != nil && !v.options.Access.CanWrite(field) {
+		v.rejectProtectedValue(field, fieldPath, data, result)
+		return
+	}
+
 	// Skip validation if field is not visible
-	if field.Visible != nil && !v.evaluateCondition(field.Visible, data) {
+	if field.Visible != nil && !v.evaluateCondition(field.Visible, scope) {
 		return
 	}
 
 	// Get field value (support nested path like "address.street")
-	value := v.getValueByPath(data, fieldPath)
+	value := v.getValueByPath(data, field.ID)
+
+	// Interpret the value as the field's data type, coercing string input unless strict
+	value, ok := v.interpretValue(field, fieldPath, value, result)
+	if !ok {
+		return
+	}
+
+	// Consent fields require explicit acceptance of the current legal text
+	if field.Type == FieldTypeConsent {
+		v.validateConsent(field, fieldPath, value, scope, result)
+		return
+	}
+
+	// Signature fields are checked for a drawn, well-formed signature of acceptable size
+	if field.Type == FieldTypeSignature {
+		required := field.Required || (field.RequiredIf != nil && v.evaluateCondition(field.RequiredIf, scope))
+		v.validateSignature(field, fieldPath, value, required, result)
+		return
+	}
 
 	// Check required fields
 	if field.Required {
 		isEmpty := v.isEmpty(value)
 		if isEmpty {
-			result.Errors = append(result.Errors, &ValidationError{
+			v.addError(result, &ValidationError{
 				FieldID:  fieldPath,
 				Message:  fmt.Sprintf("%s is required", field.Label),
 				RuleType: string(ValidationTypeRequired),
@@ -62,10 +184,10 @@ func (v *Validator) validateField(field *Field, data map[string]interface{}, pre
 	}
 
 	// Check conditional required (requiredIf)
-	if field.RequiredIf != nil && v.evaluateCondition(field.RequiredIf, data) {
+	if field.RequiredIf != nil && v.evaluateCondition(field.RequiredIf, scope) {
 		isEmpty := v.isEmpty(value)
 		if isEmpty {
-			result.Errors = append(result.Errors, &ValidationError{
+			v.addError(result, &ValidationError{
 				FieldID:  fieldPath,
 				Message:  fmt.Sprintf("%s is required based on other field values", field.Label),
 				RuleType: string(ValidationTypeRequiredIf),
@@ -77,11 +199,29 @@ func (v *Validator) validateField(field *Field, data map[string]interface{}, pre
 		return
 	}
 
+	// Check the value against the field's options
+	v.validateOptionValue(field, fieldPath, value, scope, result)
+
+	// Check that phone numbers are possible numbers of an accepted region
+	v.validatePhoneNumber(field, fieldPath, value, scope, result)
+
+	// Check that amounts are exact amounts of an accepted currency
+	v.validateCurrency(field, fieldPath, value, result)
+
+	// Check date ranges, and times entered in a time zone
+	v.validateDateTime(field, fieldPath, value, result)
+
+	// Check the value with an external verifier
+	v.verifyValue(field, fieldPath, value, result)
+
+	// Check that submitted files were uploaded for the field
+	v.validateFileReferences(field, fieldPath, value, result)
+
 	// Apply field-specific validations
 	for _, rule := range field.ValidationRules {
-		valid, message := v.applyValidationRule(rule, value, field, data)
+		valid, message := v.applyValidationRule(rule, value, field, scope)
 		if !valid {
-			result.Errors = append(result.Errors, &ValidationError{
+			v.addError(result, &ValidationError{
 				FieldID:  fieldPath,
 				Message:  message,
 				RuleType: string(rule.Type),
@@ -95,8 +235,9 @@ func (v *Validator) validateField(field *Field, data map[string]interface{}, pre
 		if mapValue, ok := value.(map[string]interface{}); ok {
 			nestedData = mapValue
 		}
+		nestedScope := overlayVariables(scope, nestedData)
 		for _, nestedField := range field.Nested {
-			v.validateField(nestedField, nestedData, fieldPath, result)
+			v.validateField(nestedField, nestedData, nestedScope, fieldPath, result)
 		}
 	}
 
@@ -105,8 +246,9 @@ func (v *Validator) validateField(field *Field, data map[string]interface{}, pre
 		if arrayValue, ok := value.([]interface{}); ok {
 			for i, item := range arrayValue {
 				if itemMap, ok := item.(map[string]interface{}); ok {
+					nestedScope := itemScope(scope, itemMap, data, i)
 					for _, nestedField := range field.Nested {
-						v.validateField(nestedField, itemMap, fmt.Sprintf("%s[%d]", fieldPath, i), result)
+						v.validateField(nestedField, itemMap, nestedScope, fmt.Sprintf("%s[%d]", fieldPath, i), result)
 					}
 				}
 			}
@@ -164,27 +306,8 @@ func (v *Validator) applyValidationRule(rule *ValidationRule, value interface{},
 		}
 		return false, rule.Message
 
-	case ValidationTypeMin:
-		if num, ok := value.(float64); ok {
-			min, _ := rule.Parameters.(float64)
-			return num >= min, rule.Message
-		}
-		if num, ok := value.(int); ok {
-			min, _ := rule.Parameters.(float64)
-			return float64(num) >= min, rule.Message
-		}
-		return false, rule.Message
-
-	case ValidationTypeMax:
-		if num, ok := value.(float64); ok {
-			max, _ := rule.Parameters.(float64)
-			return num <= max, rule.Message
-		}
-		if num, ok := value.(int); ok {
-			max, _ := rule.Parameters.(float64)
-			return float64(num) <= max, rule.Message
-		}
-		return false, rule.Message
+	case ValidationTypeMin, ValidationTypeMax, ValidationTypeStep:
+		return v.validateNumberRule(rule, value, field, data)
 
 	case ValidationTypeEmail:
 		if str, ok := value.(string); ok {
@@ -194,6 +317,13 @@ func (v *Validator) applyValidationRule(rule *ValidationRule, value interface{},
 		}
 		return false, rule.Message
 
+	case ValidationTypePhone:
+		if str, ok := value.(string); ok {
+			_, err := ParsePhoneNumber(str, phoneRuleCountry(rule))
+			return err == nil, rule.Message
+		}
+		return false, rule.Message
+
 	case ValidationTypeURL:
 		if str, ok := value.(string); ok {
 			// Simple URL regex - a production system would use a more comprehensive one
@@ -202,17 +332,8 @@ func (v *Validator) applyValidationRule(rule *ValidationRule, value interface{},
 		}
 		return false, rule.Message
 
-	case ValidationTypeFileType:
-		// Implementation would check file extension or MIME type
-		return true, ""
-
-	case ValidationTypeFileSize:
-		// Implementation would check file size
-		return true, ""
-
-	case ValidationTypeImageDimensions:
-		// Implementation would check image dimensions
-		return true, ""
+	case ValidationTypeFileType, ValidationTypeFileSize, ValidationTypeImageDimensions:
+		return v.validateFileRule(rule, value)
 
 	case ValidationTypeDependency:
 		// Implementation would check dependencies between fields
@@ -226,6 +347,9 @@ func (v *Validator) applyValidationRule(rule *ValidationRule, value interface{},
 		// Custom validation would be implemented by the application
 		return true, ""
 
+	case ValidationTypeRemote:
+		return v.validateRemote(rule, value, data)
+
 	default:
 		return true, ""
 	}
@@ -260,15 +384,28 @@ func (v *Validator) validateDependency(rule *ValidationRule, field *Field, data
 
 // evaluateCondition evaluates a condition against form data
 func (v *Validator) evaluateCondition(condition *Condition, data map[string]interface{}) bool {
+	if v.options != nil {
+		data = overlayVariables(data, v.options.Variables)
+	}
+
 	switch condition.Type {
 	case ConditionTypeSimple:
 		fieldValue := v.getValueByPath(data, condition.Field)
 		switch condition.Operator {
 		case "eq":
+			if equal, ok := temporalEqual(fieldValue, condition.Value); ok {
+				return equal
+			}
 			return reflect.DeepEqual(fieldValue, condition.Value)
 		case "neq":
+			if equal, ok := temporalEqual(fieldValue, condition.Value); ok {
+				return !equal
+			}
 			return !reflect.DeepEqual(fieldValue, condition.Value)
 		case "contains":
+			if within, ok := dateRangeContains(fieldValue, condition.Value); ok {
+				return within
+			}
 			if str, ok := fieldValue.(string); ok {
 				if valueStr, ok := condition.Value.(string); ok {
 					return strings.Contains(str, valueStr)
@@ -295,28 +432,32 @@ func (v *Validator) evaluateCondition(condition *Condition, data map[string]inte
 					return num > valueNum
 				}
 			}
-			return false
+			comparison, ok := compareTemporal(fieldValue, condition.Value)
+			return ok && comparison > 0
 		case "gte":
 			if num, ok := fieldValue.(float64); ok {
 				if valueNum, ok := condition.Value.(float64); ok {
 					return num >= valueNum
 				}
 			}
-			return false
+			comparison, ok := compareTemporal(fieldValue, condition.Value)
+			return ok && comparison >= 0
 		case "lt":
 			if num, ok := fieldValue.(float64); ok {
 				if valueNum, ok := condition.Value.(float64); ok {
 					return num < valueNum
 				}
 			}
-			return false
+			comparison, ok := compareTemporal(fieldValue, condition.Value)
+			return ok && comparison < 0
 		case "lte":
 			if num, ok := fieldValue.(float64); ok {
 				if valueNum, ok := condition.Value.(float64); ok {
 					return num <= valueNum
 				}
 			}
-			return false
+			comparison, ok := compareTemporal(fieldValue, condition.Value)
+			return ok && comparison <= 0
 		default:
 			return false
 		}
@@ -350,19 +491,21 @@ func (v *Validator) evaluateCondition(condition *Condition, data map[string]inte
 	case ConditionTypeExpression:
 		// For expression evaluation, we would use a lightweight expression engine
 		// This is simplified for demonstration
-		return evaluateExpression(condition.Expression, data)
+		return evaluateExpression(condition.Expression, data, v.evaluationTime())
 
 	default:
 		return false
 	}
 }
 
-// evaluateExpression evaluates a custom expression against form data
+// evaluateExpression evaluates a custom expression against form data, with now as the time
+// the expression is evaluated at
 // This would typically use a specialized expression evaluation library
-func evaluateExpression(expression string, data map[string]interface{}) bool {
+func evaluateExpression(expression string, data map[string]interface{}, now time.Time) bool {
 	// Create environment
 	env, _ := cel.NewEnv(
 		cel.Variable("data", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
 	)
 
 	// Parse and check expression
@@ -385,6 +528,7 @@ func evaluateExpression(expression string, data map[string]interface{}) bool {
 	// Evaluate with data
 	result, _, err := program.Eval(map[string]interface{}{
 		"data": data,
+		"now":  now,
 	})
 
 	if err != nil {
@@ -400,6 +544,15 @@ func evaluateExpression(expression string, data map[string]interface{}) bool {
 	return boolResult
 }
 
+// evaluationTime returns the time the run is evaluated at, or the current time for runs
+// without options
+func (v *Validator) evaluationTime() time.Time {
+	if v.now.IsZero() {
+		return time.Now()
+	}
+	return v.now
+}
+
 // isEmpty checks if a value is empty
 func (v *Validator) isEmpty(value interface{}) bool {
 	if value == nil {
@@ -0,0 +1,196 @@
+package smartform
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Logger writes structured log records, given as alternating keys and values. *slog.Logger
+// satisfies it.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// ValidationFailureMessage is the message of logged validation failures
+const ValidationFailureMessage = "validation failed"
+
+// ValidationLogConfig configures the logging of validation failures
+type ValidationLogConfig struct {
+	SampleRate   float64    // Fraction of failing validation runs logged, above 0 and up to 1 (default 1)
+	MaxPerMinute int        // Most failures logged per minute for one field and rule (0 means unlimited)
+	HashKey      []byte     // Key values are hashed with, so equal values can be correlated; without one no hash is logged
+	Level        slog.Level // Level records are logged at (default info)
+}
+
+// ValidationFailureLogger logs validation failures, one record per error, so that fields that
+// fail systematically can be found in production. Values are never logged: records carry their
+// kind, their length, and, with a hash key, a keyed hash.
+type ValidationFailureLogger struct {
+	logger  Logger
+	config  ValidationLogConfig
+	lock    sync.Mutex
+	windows map[string]*failureWindow
+	random  func() float64
+	now     func() time.Time
+}
+
+// failureWindow counts the failures of one field and rule within a minute
+type failureWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+// NewValidationFailureLogger creates a logger of validation failures writing to logger
+func NewValidationFailureLogger(logger Logger, config ValidationLogConfig) *ValidationFailureLogger {
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = 1
+	}
+	return &ValidationFailureLogger{
+		logger:  logger,
+		config:  config,
+		windows: make(map[string]*failureWindow),
+		random:  rand.Float64,
+		now:     time.Now,
+	}
+}
+
+// SetValidationFailureLogger sets the logger that validation failures of the validate and
+// submit endpoints are logged to
+func (ah *APIHandler) SetValidationFailureLogger(logger *ValidationFailureLogger) {
+	ah.failureLogger = logger
+}
+
+// LogFailures logs the errors of a failed validation run of a form, if the run is sampled
+func (fl *ValidationFailureLogger) LogFailures(ctx context.Context, schema *FormSchema, data map[string]interface{}, result *ValidationResult) {
+	if len(result.Errors) == 0 || fl.random() >= fl.config.SampleRate {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	validator := NewValidator(schema)
+	for _, err := range result.Errors {
+		suppressed, ok := fl.allow(schema.ID, err)
+		if !ok {
+			continue
+		}
+
+		value := validator.getValueByPath(data, err.FieldID)
+		args := []any{
+			"form", schema.ID,
+			"version", schema.Version,
+			"field", err.FieldID,
+			"rule", err.RuleType,
+			"valueKind", valueKind(value),
+		}
+		if length, ok := valueLength(value); ok {
+			args = append(args, "valueLength", length)
+		}
+		if hash := fl.hashValue(value); hash != "" {
+			args = append(args, "valueHash", hash)
+		}
+		if suppressed > 0 {
+			args = append(args, "suppressed", suppressed)
+		}
+		fl.logger.Log(ctx, fl.config.Level, ValidationFailureMessage, args...)
+	}
+}
+
+// logFailures logs the errors of a validation run to the failure logger of its options
+func (v *Validator) logFailures(data map[string]interface{}, result *ValidationResult) {
+	if v.options == nil || v.options.FailureLogger == nil {
+		return
+	}
+	v.options.FailureLogger.LogFailures(v.options.Context, v.schema, data, result)
+}
+
+// allow reports whether a failure may be logged under the per-minute limit of its field and
+// rule, and how many failures were suppressed since the last one logged
+func (fl *ValidationFailureLogger) allow(formID string, err *ValidationError) (int, bool) {
+	if fl.config.MaxPerMinute <= 0 {
+		return 0, true
+	}
+
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+
+	key := formID + "\x00" + pathIndexPattern.ReplaceAllString(err.FieldID, "") + "\x00" + err.RuleType
+	now := fl.now()
+	window, ok := fl.windows[key]
+	if !ok {
+		window = &failureWindow{start: now}
+		fl.windows[key] = window
+	}
+	if now.Sub(window.start) >= time.Minute {
+		window.start = now
+		window.logged = 0
+	}
+	if window.logged >= fl.config.MaxPerMinute {
+		window.suppressed++
+		return 0, false
+	}
+	window.logged++
+	suppressed := window.suppressed
+	window.suppressed = 0
+	return suppressed, true
+}
+
+// hashValue returns a keyed hash of a value, or "" without a hash key
+func (fl *ValidationFailureLogger) hashValue(value interface{}) string {
+	if len(fl.config.HashKey) == 0 || value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, fl.config.HashKey)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// valueKind returns the JSON kind of a value
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		if _, ok := decodeNumber(value); ok {
+			return "number"
+		}
+		return "other"
+	}
+}
+
+// valueLength returns the number of characters of a string, items of an array, or keys of an
+// object
+func valueLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		return utf8.RuneCountInString(v), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	default:
+		return 0, false
+	}
+}
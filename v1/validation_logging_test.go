@@ -0,0 +1,123 @@
+package smartform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationFailureLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewValidationFailureLogger(slog.New(slog.NewJSONHandler(&buf, nil)), ValidationLogConfig{
+		HashKey:      []byte("secret"),
+		MaxPerMinute: 2,
+	})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logger.now = func() time.Time { return now }
+
+	handler := NewAPIHandler()
+	handler.SetValidationFailureLogger(logger)
+	require.NoError(t, handler.RegisterSchema(NewForm("signup", "Sign up").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).ValidateEmail("Invalid email").Build()).
+		AddField(NewFieldBuilder("age", FieldTypeNumber, "Age").ValidateMin(18, "Too young").Build()).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	validate := func(body string) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/validate/signup", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	records := func() []map[string]interface{} {
+		var list []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &record))
+			list = append(list, record)
+		}
+		buf.Reset()
+		return list
+	}
+
+	validate(`{"email": "ada.lovelace", "age": 12}`)
+	logged := records()
+	require.Len(t, logged, 2)
+	assert.Equal(t, ValidationFailureMessage, logged[0]["msg"])
+	assert.Equal(t, "signup", logged[0]["form"])
+	assert.Equal(t, "email", logged[0]["field"])
+	assert.Equal(t, "email", logged[0]["rule"])
+	assert.Equal(t, "string", logged[0]["valueKind"])
+	assert.EqualValues(t, 12, logged[0]["valueLength"])
+	assert.Equal(t, "age", logged[1]["field"])
+	assert.Equal(t, "number", logged[1]["valueKind"])
+	hash := logged[0]["valueHash"]
+	assert.Len(t, hash, 16)
+
+	// Raw values never reach the log, but equal values hash alike
+	validate(`{"email": "ada.lovelace", "age": 30}`)
+	logged = records()
+	require.Len(t, logged, 1)
+	assert.Equal(t, hash, logged[0]["valueHash"])
+	assert.NotContains(t, logged[0], "ada.lovelace")
+
+	// Each field and rule is logged at most twice a minute; suppressed failures are counted
+	validate(`{"email": "grace", "age": 30}`)
+	assert.Empty(t, records())
+	validate(`{"email": "grace", "age": 30}`)
+	assert.Empty(t, records())
+	now = now.Add(time.Minute)
+	validate(`{"email": "grace", "age": 30}`)
+	logged = records()
+	require.Len(t, logged, 1)
+	assert.EqualValues(t, 2, logged[0]["suppressed"])
+
+	// Valid data logs nothing
+	validate(`{"email": "grace@example.com", "age": 30}`)
+	assert.Empty(t, records())
+}
+
+// recordingLogger keeps the fields of logged records
+type recordingLogger struct {
+	records [][]any
+}
+
+func (rl *recordingLogger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	rl.records = append(rl.records, args)
+}
+
+func TestValidationFailureSampling(t *testing.T) {
+	recorder := &recordingLogger{}
+	logger := NewValidationFailureLogger(recorder, ValidationLogConfig{SampleRate: 0.25})
+	rolls := []float64{0.1, 0.5, 0.24, 0.9}
+	logger.random = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	schema := NewForm("signup", "Sign up").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Required(true).Build()).
+		Build()
+	options := DefaultValidationOptions()
+	options.FailureLogger = logger
+	for i := 0; i < 4; i++ {
+		NewValidator(schema).ValidateFormWithOptions(map[string]interface{}{}, options)
+	}
+
+	// Runs are sampled as a whole; without a hash key no hash is logged
+	require.Len(t, recorder.records, 2)
+	assert.Equal(t, []any{"form", "signup", "version", "", "field", "email", "rule", "required", "valueKind", "null"}, recorder.records[0])
+}
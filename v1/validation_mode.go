@@ -0,0 +1,53 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// ValidationMode controls when the validator stops collecting errors
+type ValidationMode string
+
+// Define validation modes
+const (
+	ValidationModeCollectAll ValidationMode = "collectAll" // Report every error (default)
+	ValidationModeFailFast   ValidationMode = "failFast"   // Stop at the first error
+)
+
+// Values provides the possible values for ValidationMode, compatible with entgo.
+func (ValidationMode) Values() (types []string) {
+	return []string{
+		string(ValidationModeCollectAll),
+		string(ValidationModeFailFast),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (m ValidationMode) MarshalText() ([]byte, error) {
+	return []byte(m), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (m *ValidationMode) UnmarshalText(text []byte) error {
+	switch ValidationMode(text) {
+	case ValidationModeCollectAll, ValidationModeFailFast:
+		*m = ValidationMode(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid ValidationMode: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (m *ValidationMode) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("ValidationMode should be a string, got %T", value)
+	}
+	return m.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (m ValidationMode) Value() (driver.Value, error) {
+	return string(m), nil
+}
@@ -0,0 +1,180 @@
+package smartform
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValidationOptionsKey is the reserved form data key that may carry per-request validation options
+const ValidationOptionsKey = "_validation"
+
+// ValidationOptions configures how a validation run behaves
+type ValidationOptions struct {
+	Mode      ValidationMode `json:"mode,omitempty"`      // collectAll (default) or failFast
+	MaxErrors int            `json:"maxErrors,omitempty"` // Stop after this many errors (0 means unlimited)
+	Strict    bool           `json:"strict,omitempty"`    // Disable value coercion and reject mistyped values
+
+	// TamperPolicy decides what happens to values submitted for hidden or disabled fields.
+	// It is a server-side setting and is never read from the request.
+	TamperPolicy TamperPolicy `json:"-"`
+
+	// OptionResolver re-fetches dynamic options so submitted values can be checked against them
+	OptionResolver OptionResolver `json:"-"`
+
+	// Verifiers check fields marked for email, phone, or address verification
+	Verifiers *Verifiers `json:"-"`
+
+	// RemoteValidators check values of remote validation rules, by the name the rules refer to
+	RemoteValidators map[string]RemoteValidator `json:"-"`
+
+	// FileStorage looks up submitted file references, so that file rules check the stored files
+	FileStorage FileStorage `json:"-"`
+
+	// FailureLogger logs the errors of failed runs with anonymized values
+	FailureLogger *ValidationFailureLogger `json:"-"`
+
+	// Context is passed to verifiers and remote validators; it is usually the request context
+	Context context.Context `json:"-"`
+
+	// Clock tells the time rules and conditions are evaluated at, unless Variables carry
+	// NowVariable. It is read once per run.
+	Clock Clock `json:"-"`
+
+	// Access is who the data is submitted by. When set, values of fields whose write roles the
+	// caller lacks are rejected.
+	Access *AccessContext `json:"-"`
+
+	// Variables are server-provided values, such as feature flags, that conditions can refer to
+	// like fields. They take precedence over submitted data.
+	Variables map[string]interface{} `json:"-"`
+}
+
+// DefaultValidationOptions returns options that collect every error
+func DefaultValidationOptions() *ValidationOptions {
+	return &ValidationOptions{
+		Mode: ValidationModeCollectAll,
+	}
+}
+
+// errorLimit returns the maximum number of errors to collect, or 0 for no limit
+func (o *ValidationOptions) errorLimit() int {
+	if o == nil {
+		return 0
+	}
+	if o.Mode == ValidationModeFailFast {
+		return 1
+	}
+	return o.MaxErrors
+}
+
+// ParseValidationOptions reads validation options from the "mode", "maxErrors", and "strict" query
+// parameters and from the reserved ValidationOptionsKey entry of the form data, which is
+// removed from the data. Query parameters take precedence over the body.
+func ParseValidationOptions(query url.Values, data map[string]interface{}) (*ValidationOptions, error) {
+	options := DefaultValidationOptions()
+
+	if raw, ok := data[ValidationOptionsKey]; ok {
+		delete(data, ValidationOptionsKey)
+		if err := decodeRaw(raw, options); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ValidationOptionsKey, err)
+		}
+	}
+
+	if mode := query.Get("mode"); mode != "" {
+		if err := options.Mode.UnmarshalText([]byte(mode)); err != nil {
+			return nil, err
+		}
+	}
+
+	if maxErrors := query.Get("maxErrors"); maxErrors != "" {
+		limit, err := strconv.Atoi(maxErrors)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid maxErrors: %s", maxErrors)
+		}
+		options.MaxErrors = limit
+	}
+
+	if strict := query.Get("strict"); strict != "" {
+		value, err := strconv.ParseBool(strict)
+		if err != nil {
+			return nil, fmt.Errorf("invalid strict: %s", strict)
+		}
+		options.Strict = value
+	}
+
+	if options.Mode == "" {
+		options.Mode = ValidationModeCollectAll
+	}
+
+	return options, nil
+}
+
+// ValidateWithOptions validates form data using the given validation options
+func (fs *FormSchema) ValidateWithOptions(data map[string]interface{}, options *ValidationOptions) *ValidationResult {
+	return fs.validator.ValidateFormWithOptions(data, options)
+}
+
+// ValidateFormWithOptions validates a form data map using the given validation options
+func (v *Validator) ValidateFormWithOptions(data map[string]interface{}, options *ValidationOptions) *ValidationResult {
+	run := &Validator{schema: v.schema, options: options, now: options.evaluationTime()}
+	return run.ValidateForm(data)
+}
+
+// ValidateFieldWithOptions validates a single field of a form data map using the given
+// validation options
+func (v *Validator) ValidateFieldWithOptions(path string, data map[string]interface{}, options *ValidationOptions) (*ValidationResult, bool) {
+	run := &Validator{schema: v.schema, options: options, now: options.evaluationTime()}
+	return run.ValidateField(path, data)
+}
+
+// addError records a validation error unless the error limit has been reached
+func (v *Validator) addError(result *ValidationResult, err *ValidationError) {
+	if v.limitReached(result) {
+		return
+	}
+	result.Errors = append(result.Errors, err)
+}
+
+// limitReached reports whether no more errors should be collected
+func (v *Validator) limitReached(result *ValidationResult) bool {
+	limit := v.options.errorLimit()
+	return limit > 0 && len(result.Errors) >= limit
+}
+
+// pathRootPattern extracts the top-level field ID from an error path such as "items[0].name"
+var pathRootPattern = regexp.MustCompile(`^[^.\[]+`)
+
+// groupErrorsBySection groups errors by the section or group that contains their field.
+// A field belongs to the container it is nested in or, for top-level fields, to the
+// closest section field before it.
+func (v *Validator) groupErrorsBySection(result *ValidationResult) {
+	sections := make(map[string]string)
+	current := ""
+	for _, field := range v.schema.Fields {
+		switch {
+		case field.Type == FieldTypeSection && len(field.Nested) == 0:
+			current = field.ID
+			sections[field.ID] = field.ID
+		case (field.Type == FieldTypeSection || field.Type == FieldTypeGroup) && len(field.Nested) > 0:
+			sections[field.ID] = field.ID
+		default:
+			sections[field.ID] = current
+		}
+	}
+
+	for _, err := range result.Errors {
+		root := pathRootPattern.FindString(strings.TrimSpace(err.FieldID))
+		section := sections[root]
+		if section == "" {
+			continue
+		}
+		if result.Sections == nil {
+			result.Sections = make(map[string][]*ValidationError)
+		}
+		result.Sections[section] = append(result.Sections[section], err)
+	}
+}
@@ -0,0 +1,134 @@
+package smartform
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_ValidationModes(t *testing.T) {
+	schema := NewForm("profile", "Profile").
+		AddField(NewFieldBuilder("personal", FieldTypeSection, "Personal").Build()).
+		AddField(NewFieldBuilder("first", FieldTypeText, "First").Required(true).Build()).
+		AddField(NewFieldBuilder("last", FieldTypeText, "Last").Required(true).Build()).
+		AddField(NewFieldBuilder("work", FieldTypeSection, "Work").Build()).
+		AddField(NewFieldBuilder("company", FieldTypeText, "Company").Required(true).Build()).
+		Build()
+
+	result := schema.ValidateWithOptions(map[string]interface{}{}, DefaultValidationOptions())
+	assert.Len(t, result.Errors, 3)
+	assert.False(t, result.Truncated)
+	assert.Len(t, result.Sections["personal"], 2)
+	assert.Len(t, result.Sections["work"], 1)
+
+	result = schema.ValidateWithOptions(map[string]interface{}{}, &ValidationOptions{Mode: ValidationModeFailFast})
+	assert.Len(t, result.Errors, 1)
+	assert.True(t, result.Truncated)
+	assert.False(t, result.Valid)
+
+	data := map[string]interface{}{
+		ValidationOptionsKey: map[string]interface{}{"maxErrors": 1},
+	}
+	options, err := ParseValidationOptions(url.Values{"maxErrors": {"2"}}, data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, options.MaxErrors)
+	assert.NotContains(t, data, ValidationOptionsKey)
+
+	result = schema.ValidateWithOptions(data, options)
+	assert.Len(t, result.Errors, 2)
+
+	_, err = ParseValidationOptions(url.Values{"mode": {"sometimes"}}, map[string]interface{}{})
+	assert.Error(t, err)
+}
+
+func TestValidator_Coercion(t *testing.T) {
+	schema := NewForm("order", "Order").
+		AddField(NewFieldBuilder("quantity", FieldTypeNumber, "Quantity").ValidateMin(1, "At least one").Build()).
+		AddField(NewFieldBuilder("gift", FieldTypeCheckbox, "Gift").Build()).
+		Build()
+
+	data := map[string]interface{}{"quantity": "5", "gift": "true"}
+
+	result := schema.ValidateWithOptions(data, DefaultValidationOptions())
+	assert.True(t, result.Valid)
+	require.Len(t, result.Coercions, 2)
+	assert.Equal(t, "quantity", result.Coercions[0].FieldID)
+	assert.Equal(t, float64(5), result.Coercions[0].To)
+	assert.Equal(t, true, result.Coercions[1].To)
+
+	result = schema.ValidateWithOptions(data, &ValidationOptions{Strict: true})
+	assert.False(t, result.Valid)
+	assert.Empty(t, result.Coercions)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, string(ValidationTypeDataType), result.Errors[0].RuleType)
+}
+
+func TestValidator_TamperDetection(t *testing.T) {
+	schema := NewForm("shipping", "Shipping").
+		AddField(NewFieldBuilder("express", FieldTypeCheckbox, "Express").Build()).
+		AddField(NewFieldBuilder("deliveryDate", FieldTypeDate, "Delivery date").
+			VisibleWhenEquals("express", true).Build()).
+		Build()
+
+	newData := func() map[string]interface{} {
+		return map[string]interface{}{"express": false, "deliveryDate": "2025-01-01"}
+	}
+
+	result := schema.ValidateWithOptions(newData(), &ValidationOptions{TamperPolicy: TamperPolicyFlag})
+	assert.True(t, result.Valid)
+	require.Len(t, result.Tampered, 1)
+	assert.Equal(t, "deliveryDate", result.Tampered[0].FieldID)
+	assert.Equal(t, TamperReasonHidden, result.Tampered[0].Reason)
+
+	data := newData()
+	result = schema.ValidateWithOptions(data, &ValidationOptions{TamperPolicy: TamperPolicyStrip})
+	assert.True(t, result.Valid)
+	assert.NotContains(t, data, "deliveryDate")
+
+	result = schema.ValidateWithOptions(newData(), &ValidationOptions{TamperPolicy: TamperPolicyReject})
+	assert.False(t, result.Valid)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, string(ValidationTypeTamper), result.Errors[0].RuleType)
+
+	result = schema.ValidateWithOptions(newData(), DefaultValidationOptions())
+	assert.Empty(t, result.Tampered)
+}
+
+func TestValidator_OptionValues(t *testing.T) {
+	schema := NewForm("payment", "Payment").
+		AddField(NewFieldBuilder("method", FieldTypeSelect, "Method").
+			WithStaticOptions([]*Option{NewOption("card", "Card"), NewOption("invoice", "Invoice")}).Build()).
+		AddField(NewFieldBuilder("tip", FieldTypeSelect, "Tip").
+			WithStaticOptions([]*Option{NewOption(float64(5), "5"), NewOption(float64(10), "10")}).
+			OptionValueType(OptionValueTypeNumber).Build()).
+		AddField(NewFieldBuilder("extras", FieldTypeMultiSelect, "Extras").
+			WithStaticOptions([]*Option{NewOption("wrap", "Gift wrap"), NewOption("note", "Note")}).Build()).
+		Build()
+	require.NoError(t, schema.CheckOptionTypes())
+
+	result := schema.ValidateWithOptions(map[string]interface{}{
+		"method": "card",
+		"tip":    "10",
+		"extras": []interface{}{"wrap"},
+	}, DefaultValidationOptions())
+	assert.True(t, result.Valid)
+	require.Len(t, result.Coercions, 1)
+
+	result = schema.ValidateWithOptions(map[string]interface{}{
+		"method": "cash",
+		"tip":    float64(7),
+		"extras": []interface{}{"wrap", "balloons"},
+	}, DefaultValidationOptions())
+	require.Len(t, result.Errors, 3)
+	assert.Equal(t, "extras[1]", result.Errors[2].FieldID)
+	assert.Equal(t, string(ValidationTypeOption), result.Errors[0].RuleType)
+
+	result = schema.ValidateWithOptions(map[string]interface{}{"tip": "10"}, &ValidationOptions{Strict: true})
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, string(ValidationTypeDataType), result.Errors[0].RuleType)
+
+	schema.FindFieldByID("tip").Options.Static[0].Value = "5"
+	assert.Error(t, schema.CheckOptionTypes())
+}
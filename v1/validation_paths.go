@@ -0,0 +1,75 @@
+package smartform
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// indexedSegmentPattern matches a segment of a field path naming an array item, e.g. "items[2]"
+var indexedSegmentPattern = regexp.MustCompile(`^(.+)\[(\d+)\]$`)
+
+// splitIndexedSegment splits a segment of a field path into the ID of the field and, when it
+// names an array item, the index of the item
+func splitIndexedSegment(segment string) (string, int, bool) {
+	matches := indexedSegmentPattern.FindStringSubmatch(segment)
+	if matches == nil {
+		return segment, 0, false
+	}
+	index, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return segment, 0, false
+	}
+	return matches[1], index, true
+}
+
+// SplitFieldPath splits the path of a field, such as "items[2].quantity", into the keys and
+// indexes leading to its value: "items", 2, and "quantity"
+func SplitFieldPath(path string) []interface{} {
+	if path == "" {
+		return []interface{}{}
+	}
+	keys := []interface{}{}
+	for _, segment := range strings.Split(path, ".") {
+		indexes := []interface{}{}
+		for {
+			id, index, indexed := splitIndexedSegment(segment)
+			if !indexed {
+				break
+			}
+			indexes = append([]interface{}{index}, indexes...)
+			segment = id
+		}
+		keys = append(keys, segment)
+		keys = append(keys, indexes...)
+	}
+	return keys
+}
+
+// Pointer returns the path of the field as a JSON pointer into the submitted data, e.g.
+// "/items/2/quantity"
+func (ve *ValidationError) Pointer() string {
+	var b strings.Builder
+	for _, key := range SplitFieldPath(ve.FieldID) {
+		b.WriteByte('/')
+		switch key := key.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(key))
+		case string:
+			b.WriteString(strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1"))
+		}
+	}
+	return b.String()
+}
+
+// ErrorsAt returns the errors of the field at path and of the fields nested in it, so that
+// errors can be shown next to a group or a single array item, e.g. ErrorsAt("items[2]")
+func (vr *ValidationResult) ErrorsAt(path string) []*ValidationError {
+	errs := []*ValidationError{}
+	for _, err := range vr.Errors {
+		if err.FieldID == path || strings.HasPrefix(err.FieldID, path+".") || strings.HasPrefix(err.FieldID, path+"[") {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package smartform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newShipmentForm() *FormSchema {
+	form := NewForm("shipment", "Shipment")
+	form.CheckboxField("express", "Express")
+	items := form.ArrayField("items", "Items")
+	items.NumberField("quantity", "Quantity").RequiredWhenEquals("fragile", true)
+	items.CheckboxField("fragile", "Fragile")
+	items.TextField("insurer", "Insurer").RequiredWhenEquals("express", true)
+	address := form.GroupField("address", "Address")
+	address.TextField("country", "Country")
+	address.TextField("state", "State").RequiredWhenEquals("country", "US")
+	form.TextField("zip", "ZIP code").RequiredWhenEquals("address.country", "US")
+	return form.Build()
+}
+
+func TestValidationPaths(t *testing.T) {
+	data := map[string]interface{}{
+		"express": true,
+		"items": []interface{}{
+			map[string]interface{}{"quantity": 1, "insurer": "Acme"},
+			map[string]interface{}{"fragile": true, "insurer": "Acme"},
+			map[string]interface{}{"quantity": 3},
+		},
+		"address": map[string]interface{}{"country": "US"},
+	}
+	validator := NewValidator(newShipmentForm())
+
+	// Conditions resolve siblings inside array items and groups, and other fields by path
+	result := validator.ValidateForm(data)
+	paths := []string{}
+	for _, err := range result.Errors {
+		paths = append(paths, err.FieldID)
+	}
+	assert.Equal(t, []string{"items[1].quantity", "items[2].insurer", "address.state", "zip"}, paths)
+	assert.Equal(t, "/items/1/quantity", result.Errors[0].Pointer())
+	assert.Len(t, result.ErrorsAt("items"), 2)
+	require.Len(t, result.ErrorsAt("items[2]"), 1)
+	assert.Equal(t, "items[2].insurer", result.ErrorsAt("items[2]")[0].FieldID)
+
+	// Single fields and array items are validated by their indexed path
+	fieldResult, ok := validator.ValidateField("items[1].quantity", data)
+	require.True(t, ok)
+	require.Len(t, fieldResult.Errors, 1)
+	assert.Equal(t, "items[1].quantity", fieldResult.Errors[0].FieldID)
+
+	fieldResult, ok = validator.ValidateField("items[0]", data)
+	require.True(t, ok)
+	assert.True(t, fieldResult.Valid)
+
+	_, ok = validator.ValidateField("address[0].state", data)
+	assert.False(t, ok)
+
+	assert.Equal(t, []interface{}{"items", 2, "quantity"}, SplitFieldPath("items[2].quantity"))
+	assert.Equal(t, []interface{}{"grid", 1, 0}, SplitFieldPath("grid[1][0]"))
+	assert.Equal(t, "/a~1b/c~0d", (&ValidationError{FieldID: "a/b.c~d"}).Pointer())
+}
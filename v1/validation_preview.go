@@ -0,0 +1,187 @@
+package smartform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultValidationPreviewLimit is how many validation previews a client may request by default
+var DefaultValidationPreviewLimit = ValidationPreviewLimit{Requests: 10, Per: time.Second}
+
+// ValidationPreviewLimit caps how often one client may preview the dynamic validation of fields,
+// so that checks run while the user types cannot overload the functions behind them
+type ValidationPreviewLimit = RateLimit
+
+// ValidationPreview is the outcome of the dynamic validation rules of one field
+type ValidationPreview struct {
+	FieldID string `json:"fieldId"`
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+}
+
+// SetValidationPreviewLimit caps how often one client may preview the dynamic validation of
+// fields. Clients are told apart by their API key, or else their address.
+func (ah *APIHandler) SetValidationPreviewLimit(limit ValidationPreviewLimit) {
+	ah.previewLimiter = newRateLimiter(limit)
+}
+
+// dynamicValidations returns the dynamic validation rules of a field, whether
+// they were added with the builder or imported from JSON
+func dynamicValidations(field *Field) []*ValidationRule {
+	rules := []*ValidationRule{}
+	for _, rule := range field.ValidationRules {
+		if rule.Type != ValidationTypeCustom || ruleDynamicFunction(rule) == nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ruleDynamicFunction returns the dynamic function a validation rule checks values with
+func ruleDynamicFunction(rule *ValidationRule) *DynamicFieldConfig {
+	params, ok := rule.Parameters.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch config := params["dynamicFunction"].(type) {
+	case *DynamicFieldConfig:
+		return config
+	case map[string]interface{}:
+		var decoded DynamicFieldConfig
+		if err := decodeRaw(config, &decoded); err == nil && decoded.FunctionName != "" {
+			return &decoded
+		}
+	}
+	return nil
+}
+
+// PreviewValidation runs the dynamic validation rules of the field at path against the form
+// state, stopping at the first one that fails. Functions report a value as valid by returning
+// true, nil, or an empty string, and as invalid by returning false, a message, or an object
+// such as {"valid": false, "message": "..."}; messages they return take precedence over the
+// message of the rule.
+func (fs *FormSchema) PreviewValidation(service *DynamicFunctionService, path string, state map[string]interface{}) (*ValidationPreview, error) {
+	field, err := NewStateEngine(fs).findFieldByPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ValidationPreview{FieldID: path, Valid: true}
+	for _, rule := range dynamicValidations(field) {
+		result, err := ruleDynamicFunction(rule).ExecuteWithFormState(service, state)
+		if err != nil {
+			return nil, err
+		}
+
+		valid, message := validationOutcome(result)
+		if valid {
+			continue
+		}
+		if message == "" {
+			message = rule.Message
+		}
+		preview.Valid = false
+		preview.Message = message
+		break
+	}
+	return preview, nil
+}
+
+// validationOutcome interprets the result of a validation function
+func validationOutcome(result interface{}) (bool, string) {
+	switch v := result.(type) {
+	case nil:
+		return true, ""
+	case bool:
+		return v, ""
+	case string:
+		return v == "", v
+	case map[string]interface{}:
+		valid, _ := v["valid"].(bool)
+		message, _ := v["message"].(string)
+		return valid, message
+	default:
+		return true, ""
+	}
+}
+
+// previewClient identifies the client of a preview request for rate limiting
+func previewClient(r *http.Request) string {
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return "key:" + key.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+// handleValidationPreview handles requests to run only the dynamic validation rules of a field,
+// such as while the user types, without validating the whole form
+func (ah *APIHandler) handleValidationPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	if ah.dynamicFunctionService == nil {
+		writeError(w, notConfigured(http.StatusInternalServerError, "Dynamic function service not configured"))
+		return
+	}
+
+	pathParts := splitPath(getPathParam(r.URL.Path, "/api/field/validate/"))
+	if len(pathParts) < 2 {
+		writeError(w, badRequest("Form ID and Field ID are required"))
+		return
+	}
+	formID, fieldPath := pathParts[0], pathParts[1]
+
+	if retryAfter, ok := ah.previewLimiter.allow(previewClient(r)); !ok {
+		writeRateLimited(w, retryAfter, "Too many validation previews")
+		return
+	}
+
+	var request struct {
+		Value     interface{}            `json:"value"`
+		FormState map[string]interface{} `json:"formState"`
+		SessionID string                 `json:"sessionId"`
+	}
+	if err := decodeRequest(r.Body, &request); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	schema, ok := ah.GetSchema(formID)
+	if !ok {
+		writeError(w, ErrFormNotFound)
+		return
+	}
+	if _, err := NewStateEngine(schema).findFieldByPath(fieldPath); err != nil {
+		writeError(w, fieldNotFound(fieldPath))
+		return
+	}
+
+	// The value being typed takes precedence over the one in the form state
+	state := cloneMap(request.FormState)
+	if state == nil {
+		state = map[string]interface{}{}
+	}
+	if err := setValueByPath(state, fieldPath, request.Value); err != nil {
+		writeError(w, statusError(http.StatusBadRequest, err))
+		return
+	}
+
+	preview, err := schema.PreviewValidation(ah.dynamicFunctionService, fieldPath, ah.functionState(r, request.SessionID, state))
+	if err != nil {
+		writeError(w, NewAPIError(http.StatusInternalServerError, ErrorCodeInternal, fmt.Sprintf("Error executing validation function: %v", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preview)
+}
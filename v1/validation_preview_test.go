@@ -0,0 +1,83 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationPreview(t *testing.T) {
+	functions := NewDynamicFunctionService()
+	functions.RegisterFunction("usernameFree", func(args map[string]interface{}, state map[string]interface{}) (interface{}, error) {
+		return args["username"] != "admin", nil
+	})
+	functions.RegisterFunction("notReserved", func(args map[string]interface{}, state map[string]interface{}) (interface{}, error) {
+		if strings.HasPrefix(args["username"].(string), "sys") {
+			return map[string]interface{}{"valid": false, "message": "Names starting with sys are reserved"}, nil
+		}
+		return nil, nil
+	})
+
+	form := NewForm("signup", "Signup")
+	username := form.TextField("username", "Username")
+	username.DynamicValidation("usernameFree", "Username is taken").WithFieldReference("username", "username")
+	username.DynamicValidation("notReserved", "").WithFieldReference("username", "username")
+
+	handler := NewAPIHandler()
+	handler.SetDynamicFunctionService(functions)
+	handler.SetValidationPreviewLimit(ValidationPreviewLimit{Requests: 3, Per: time.Minute})
+	require.NoError(t, handler.RegisterSchema(form.Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	preview := func(body string) (*httptest.ResponseRecorder, *ValidationPreview) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/field/validate/signup/username", strings.NewReader(body)))
+		var result ValidationPreview
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		}
+		return rec, &result
+	}
+
+	// The value being typed takes precedence over the form state
+	_, result := preview(`{"value": "admin", "formState": {"username": "alice"}}`)
+	assert.Equal(t, &ValidationPreview{FieldID: "username", Valid: false, Message: "Username is taken"}, result)
+	_, result = preview(`{"value": "sysop"}`)
+	assert.Equal(t, &ValidationPreview{FieldID: "username", Valid: false, Message: "Names starting with sys are reserved"}, result)
+	_, result = preview(`{"value": "alice"}`)
+	assert.Equal(t, &ValidationPreview{FieldID: "username", Valid: true}, result)
+
+	// Clients typing faster than the limit are asked to slow down
+	rec, _ := preview(`{"value": "bob"}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{Requests: 2})
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, ok := limiter.allow("a")
+		assert.True(t, ok)
+	}
+	wait, ok := limiter.allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+	_, ok = limiter.allow("b")
+	assert.True(t, ok)
+
+	// A new period starts afresh and forgets idle clients
+	now = now.Add(time.Second)
+	_, ok = limiter.allow("a")
+	assert.True(t, ok)
+	assert.Len(t, limiter.windows, 1)
+}
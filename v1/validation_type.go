@@ -24,6 +24,18 @@ const (
 	ValidationTypeFileSize        ValidationType = "fileSize"
 	ValidationTypeImageDimensions ValidationType = "imageDimensions"
 	ValidationTypeDependency      ValidationType = "dependency"
+	ValidationTypeDataType        ValidationType = "type"         // Value does not have the field's data type
+	ValidationTypeTamper          ValidationType = "tamper"       // Value submitted for a hidden or disabled field
+	ValidationTypeOption          ValidationType = "option"       // Value is not one of the field's options
+	ValidationTypeConsent         ValidationType = "consent"      // Accepted legal text is not the current text
+	ValidationTypeVerification    ValidationType = "verification" // Value failed external verification
+	ValidationTypeRemote          ValidationType = "remote"       // Value rejected by a registered remote validator
+	ValidationTypeStep            ValidationType = "step"         // Number is not a multiple of the step above the minimum
+	ValidationTypeFile            ValidationType = "file"         // File reference was not uploaded for the field
+	ValidationTypeSignature       ValidationType = "signature"    // Signature is malformed, too large, or not accepted
+	ValidationTypePhone           ValidationType = "phone"        // Value is not a possible phone number
+	ValidationTypeCurrency        ValidationType = "currency"     // Value is not an exact amount of an accepted currency
+	ValidationTypeDateTime        ValidationType = "datetime"     // Value is not a valid date range, or time in a known time zone
 )
 
 // Values returns all possible values of ValidationType
@@ -44,6 +56,18 @@ func (vt ValidationType) Values() []string {
 		string(ValidationTypeFileSize),
 		string(ValidationTypeImageDimensions),
 		string(ValidationTypeDependency),
+		string(ValidationTypeDataType),
+		string(ValidationTypeTamper),
+		string(ValidationTypeOption),
+		string(ValidationTypeConsent),
+		string(ValidationTypeVerification),
+		string(ValidationTypeRemote),
+		string(ValidationTypeStep),
+		string(ValidationTypeFile),
+		string(ValidationTypeSignature),
+		string(ValidationTypePhone),
+		string(ValidationTypeCurrency),
+		string(ValidationTypeDateTime),
 	}
 }
 
@@ -69,7 +93,19 @@ func (vt ValidationType) IsValid() bool {
 		ValidationTypeFileType,
 		ValidationTypeFileSize,
 		ValidationTypeImageDimensions,
-		ValidationTypeDependency:
+		ValidationTypeDependency,
+		ValidationTypeDataType,
+		ValidationTypeTamper,
+		ValidationTypeOption,
+		ValidationTypeConsent,
+		ValidationTypeVerification,
+		ValidationTypeRemote,
+		ValidationTypeStep,
+		ValidationTypeFile,
+		ValidationTypeSignature,
+		ValidationTypePhone,
+		ValidationTypeCurrency,
+		ValidationTypeDateTime:
 		return true
 	default:
 		return false
@@ -0,0 +1,129 @@
+package smartform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ValueCoercion records how the validator interpreted a submitted value
+type ValueCoercion struct {
+	FieldID  string      `json:"fieldId"`
+	From     interface{} `json:"from"`
+	To       interface{} `json:"to"`
+	FromType string      `json:"fromType"`
+	ToType   string      `json:"toType"`
+}
+
+// expectedDataType returns the data type a field's value is validated as, or "" when any type is accepted
+func expectedDataType(field *Field) string {
+	switch field.Type {
+	case FieldTypeNumber, FieldTypeSlider, FieldTypeRating:
+		return string(OptionValueTypeNumber)
+	case FieldTypeCheckbox, FieldTypeSwitch:
+		if field.Options == nil {
+			return string(OptionValueTypeBoolean)
+		}
+	case FieldTypeSelect, FieldTypeRadio:
+		return declaredOptionValueType(field)
+	case FieldTypePhone:
+		return string(OptionValueTypeString)
+	}
+	return ""
+}
+
+// declaredOptionValueType returns the declared type of a field's option values, or "" when undeclared
+func declaredOptionValueType(field *Field) string {
+	if field.Options == nil || field.Options.ValueType == OptionValueTypeAny {
+		return ""
+	}
+	return string(field.Options.ValueType)
+}
+
+// coerceValue converts a value into the given data type. It returns the converted
+// value and whether a conversion happened.
+func coerceValue(dataType string, value interface{}) (interface{}, bool) {
+	if dataType == string(OptionValueTypeString) {
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case int:
+			return strconv.Itoa(v), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+		return value, false
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return value, false
+	}
+	str = strings.TrimSpace(str)
+
+	switch dataType {
+	case string(OptionValueTypeNumber):
+		if num, err := strconv.ParseFloat(str, 64); err == nil {
+			return num, true
+		}
+	case string(OptionValueTypeBoolean):
+		if b, err := strconv.ParseBool(str); err == nil {
+			return b, true
+		}
+	}
+
+	return value, false
+}
+
+// hasDataType reports whether a value already has the given data type
+func hasDataType(dataType string, value interface{}) bool {
+	switch dataType {
+	case string(OptionValueTypeNumber):
+		switch value.(type) {
+		case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		}
+		return false
+	case string(OptionValueTypeBoolean):
+		_, ok := value.(bool)
+		return ok
+	case string(OptionValueTypeString):
+		_, ok := value.(string)
+		return ok
+	default:
+		return true
+	}
+}
+
+// interpretValue applies coercion, or in strict mode reports mistyped values, returning the value to validate
+func (v *Validator) interpretValue(field *Field, fieldPath string, value interface{}, result *ValidationResult) (interface{}, bool) {
+	return v.interpretAs(expectedDataType(field), field, fieldPath, value, result)
+}
+
+// interpretAs interprets a value as the given data type
+func (v *Validator) interpretAs(dataType string, field *Field, fieldPath string, value interface{}, result *ValidationResult) (interface{}, bool) {
+	if value == nil || value == "" || hasDataType(dataType, value) {
+		return value, true
+	}
+
+	if v.options != nil && v.options.Strict {
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  fmt.Sprintf("%s must be a %s", field.Label, dataType),
+			RuleType: string(ValidationTypeDataType),
+		})
+		return value, false
+	}
+
+	coerced, ok := coerceValue(dataType, value)
+	if ok {
+		result.Coercions = append(result.Coercions, &ValueCoercion{
+			FieldID:  fieldPath,
+			From:     value,
+			To:       coerced,
+			FromType: fmt.Sprintf("%T", value),
+			ToType:   fmt.Sprintf("%T", coerced),
+		})
+	}
+	return coerced, true
+}
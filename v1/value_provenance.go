@@ -0,0 +1,70 @@
+package smartform
+
+import "encoding/json"
+
+// DiagnosticsQueryParam asks for a diagnostics section in a rendered form
+const DiagnosticsQueryParam = "diagnostics"
+
+// Sources of the values resolved when a form is rendered
+const (
+	ProvenanceStatic      = "static"      // The field's default value
+	ProvenanceDefaultWhen = "defaultWhen" // The first matching conditional default
+	ProvenanceFunction    = "function"    // A dynamic function computing the value
+	ProvenancePrefill     = "prefill"     // A value of the parent record the form was launched from
+	ProvenanceExpression  = "expression"  // The computed value expression of a field
+)
+
+// ValueProvenance describes where the value of a field in a rendered form came from
+type ValueProvenance struct {
+	Source   string      `json:"source"`
+	Value    interface{} `json:"value,omitempty"`
+	Rule     *int        `json:"rule,omitempty"`     // Index of the matching DefaultWhen rule
+	Template string      `json:"template,omitempty"` // Template the value was evaluated from
+	Function string      `json:"function,omitempty"` // Dynamic function computing the value
+	Prefill  string      `json:"prefill,omitempty"`  // Path of the value in the parent record
+	Error    string      `json:"error,omitempty"`    // Why the template could not be evaluated
+}
+
+// RenderDiagnostics explains how the values of a rendered form were resolved
+type RenderDiagnostics struct {
+	Provenance map[string]*ValueProvenance `json:"provenance"` // By field path
+}
+
+// RenderJSONWithDiagnostics renders the form like RenderJSONWithContext, adding a diagnostics
+// section that records where each resolved default, computed value, and prefill came from
+func (fr *FormRenderer) RenderJSONWithDiagnostics(context map[string]interface{}) (string, error) {
+	fr.diagnostics = &RenderDiagnostics{Provenance: make(map[string]*ValueProvenance)}
+	defer func() { fr.diagnostics = nil }()
+
+	schemaCopy := fr.copySchemaWithContext(context)
+	data, err := json.MarshalIndent(struct {
+		*FormSchema
+		Diagnostics *RenderDiagnostics `json:"diagnostics"`
+	}{schemaCopy, fr.diagnostics}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// recordProvenance records where the value of a field came from when diagnostics are enabled
+func (fr *FormRenderer) recordProvenance(path string, provenance *ValueProvenance) {
+	if fr.diagnostics == nil {
+		return
+	}
+	fr.diagnostics.Provenance[path] = provenance
+}
+
+// computedProvenance records the dynamic function computing a field's value, if any
+func (fr *FormRenderer) computedProvenance(field *Field, path string, value interface{}) {
+	computed, _ := field.Properties["dynamicValue"].(bool)
+	config := fieldDynamicFunction(field)
+	if !computed || config == nil {
+		return
+	}
+	fr.recordProvenance(path, &ValueProvenance{
+		Source:   ProvenanceFunction,
+		Value:    value,
+		Function: config.FunctionName,
+	})
+}
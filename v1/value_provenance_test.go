@@ -0,0 +1,58 @@
+package smartform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderDiagnostics(t *testing.T) {
+	handler := NewAPIHandler()
+	handler.SetParentRecordResolver(func(recordType, id string) (map[string]interface{}, error) {
+		return map[string]interface{}{"name": "Acme"}, nil
+	})
+	total := NewFieldBuilder("total", FieldTypeNumber, "Total")
+	total.DynamicValue("orderTotal")
+	require.NoError(t, handler.RegisterSchema(NewForm("order", "Order").
+		AddField(NewFieldBuilder("account", FieldTypeText, "Account").Build()).
+		AddField(NewFieldBuilder("currency", FieldTypeText, "Currency").DefaultValue("EUR").Build()).
+		AddField(NewFieldBuilder("greeting", FieldTypeText, "Greeting").DefaultValue("Hello ${user}").Build()).
+		AddField(NewFieldBuilder("shipping", FieldTypeText, "Shipping").
+			DefaultWhenEquals("country", "US", "ground").
+			DefaultWhenEquals("country", "FR", "colissimo").
+			Build()).
+		AddField(total.Build()).
+		ParentRecord("account", false).
+		BindParentField("account", "name", true).
+		Build()))
+	mux := http.NewServeMux()
+	handler.SetupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/order?diagnostics=true&country=FR&user=Ada&parentType=account&parentId=42", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var rendered struct {
+		Diagnostics *RenderDiagnostics `json:"diagnostics"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rendered))
+	require.NotNil(t, rendered.Diagnostics)
+	provenance := rendered.Diagnostics.Provenance
+
+	assert.Equal(t, &ValueProvenance{Source: ProvenancePrefill, Value: "Acme", Prefill: "name"}, provenance["account"])
+	assert.Equal(t, &ValueProvenance{Source: ProvenanceStatic, Value: "EUR"}, provenance["currency"])
+	assert.Equal(t, &ValueProvenance{Source: ProvenanceStatic, Value: "Hello Ada", Template: "Hello ${user}"}, provenance["greeting"])
+	rule := 1
+	assert.Equal(t, &ValueProvenance{Source: ProvenanceDefaultWhen, Value: "colissimo", Rule: &rule}, provenance["shipping"])
+	assert.Equal(t, &ValueProvenance{Source: ProvenanceFunction, Function: "orderTotal"}, provenance["total"])
+
+	// Diagnostics are only included when asked for
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/forms/order?country=FR", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"diagnostics"`)
+}
@@ -0,0 +1,139 @@
+package smartform
+
+import (
+	"context"
+	"fmt"
+)
+
+// Kinds of values that can be verified
+const (
+	VerificationKindEmail   = "email"
+	VerificationKindPhone   = "phone"
+	VerificationKindAddress = "address"
+)
+
+// VerificationResult is the outcome of verifying a field value with an external service
+type VerificationResult struct {
+	FieldID    string             `json:"fieldId"`
+	Kind       string             `json:"kind"`
+	Status     VerificationStatus `json:"status"`
+	Normalized interface{}        `json:"normalized,omitempty"` // Canonical form of the value, e.g. E.164 phone number
+	Reason     string             `json:"reason,omitempty"`
+}
+
+// EmailVerifier checks that an email address exists and can receive mail
+type EmailVerifier interface {
+	VerifyEmail(ctx context.Context, email string) (*VerificationResult, error)
+}
+
+// PhoneVerifier checks that a phone number exists and can be reached
+type PhoneVerifier interface {
+	VerifyPhone(ctx context.Context, phone string) (*VerificationResult, error)
+}
+
+// AddressVerifier checks that a postal address exists
+type AddressVerifier interface {
+	VerifyAddress(ctx context.Context, address map[string]interface{}) (*VerificationResult, error)
+}
+
+// Verifiers holds the verification services available to the validator. Any of them may be nil.
+type Verifiers struct {
+	Email   EmailVerifier
+	Phone   PhoneVerifier
+	Address AddressVerifier
+}
+
+// verificationKind returns which verifier checks a field marked for verification: the
+// "verifyAs" property when set, otherwise a kind inferred from the field type
+func verificationKind(field *Field) string {
+	if kind, ok := field.Properties["verifyAs"].(string); ok && kind != "" {
+		return kind
+	}
+	switch field.Type {
+	case FieldTypeEmail:
+		return VerificationKindEmail
+	case FieldTypePhone:
+		return VerificationKindPhone
+	case FieldTypeGroup, FieldTypeObject:
+		return VerificationKindAddress
+	default:
+		return ""
+	}
+}
+
+// verifyValue calls the verifier for a field marked for verification and records the outcome.
+// Invalid values fail validation; risky and unknown outcomes are only reported.
+func (v *Validator) verifyValue(field *Field, fieldPath string, value interface{}, result *ValidationResult) {
+	if !field.Verify || v.options == nil || v.options.Verifiers == nil {
+		return
+	}
+
+	ctx := v.options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	verifiers := v.options.Verifiers
+	kind := verificationKind(field)
+
+	var verification *VerificationResult
+	var err error
+
+	switch kind {
+	case VerificationKindEmail:
+		if verifiers.Email == nil {
+			return
+		}
+		verification, err = verifiers.Email.VerifyEmail(ctx, fmt.Sprintf("%v", value))
+	case VerificationKindPhone:
+		if verifiers.Phone == nil {
+			return
+		}
+		verification, err = verifiers.Phone.VerifyPhone(ctx, fmt.Sprintf("%v", value))
+	case VerificationKindAddress:
+		address, ok := value.(map[string]interface{})
+		if verifiers.Address == nil || !ok {
+			return
+		}
+		verification, err = verifiers.Address.VerifyAddress(ctx, address)
+	default:
+		return
+	}
+
+	// An unavailable verifier must not block submissions
+	if err != nil {
+		verification = &VerificationResult{Status: VerificationStatusUnknown, Reason: err.Error()}
+	}
+	if verification == nil {
+		return
+	}
+	verification.FieldID = fieldPath
+	verification.Kind = kind
+	result.Verifications = append(result.Verifications, verification)
+
+	if verification.Status == VerificationStatusInvalid {
+		message := fmt.Sprintf("%s could not be verified", field.Label)
+		if verification.Reason != "" {
+			message = fmt.Sprintf("%s could not be verified: %s", field.Label, verification.Reason)
+		}
+		v.addError(result, &ValidationError{
+			FieldID:  fieldPath,
+			Message:  message,
+			RuleType: string(ValidationTypeVerification),
+		})
+	}
+}
+
+// Verify marks the field for verification by the email, phone, or address verifier
+// matching its type
+func (fb *FieldBuilder) Verify() *FieldBuilder {
+	fb.field.Verify = true
+	return fb
+}
+
+// VerifyAs marks the field for verification by the verifier of the given kind
+func (fb *FieldBuilder) VerifyAs(kind string) *FieldBuilder {
+	fb.field.Verify = true
+	fb.Property("verifyAs", kind)
+	return fb
+}
@@ -0,0 +1,57 @@
+package smartform
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// VerificationStatus is the outcome of verifying an email address, phone number, or postal address
+type VerificationStatus string
+
+// Define verification statuses
+const (
+	VerificationStatusDeliverable VerificationStatus = "deliverable" // Value exists and can be reached
+	VerificationStatusRisky       VerificationStatus = "risky"       // Value may work but looks suspicious (e.g. disposable email)
+	VerificationStatusInvalid     VerificationStatus = "invalid"     // Value does not exist; fails validation
+	VerificationStatusUnknown     VerificationStatus = "unknown"     // Verifier could not decide or was unavailable
+)
+
+// Values provides the possible values for VerificationStatus, compatible with entgo.
+func (VerificationStatus) Values() (types []string) {
+	return []string{
+		string(VerificationStatusDeliverable),
+		string(VerificationStatusRisky),
+		string(VerificationStatusInvalid),
+		string(VerificationStatusUnknown),
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (s VerificationStatus) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (s *VerificationStatus) UnmarshalText(text []byte) error {
+	switch VerificationStatus(text) {
+	case VerificationStatusDeliverable, VerificationStatusRisky, VerificationStatusInvalid, VerificationStatusUnknown:
+		*s = VerificationStatus(text)
+		return nil
+	default:
+		return fmt.Errorf("invalid VerificationStatus: %s", string(text))
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (s *VerificationStatus) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("VerificationStatus should be a string, got %T", value)
+	}
+	return s.UnmarshalText([]byte(str))
+}
+
+// Value implements the driver.Valuer interface
+func (s VerificationStatus) Value() (driver.Value, error) {
+	return string(s), nil
+}
@@ -0,0 +1,57 @@
+package smartform
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeEmailVerifier struct{}
+
+func (fakeEmailVerifier) VerifyEmail(ctx context.Context, email string) (*VerificationResult, error) {
+	switch {
+	case strings.HasSuffix(email, "@down.test"):
+		return nil, errors.New("service unavailable")
+	case strings.HasSuffix(email, "@mailinator.com"):
+		return &VerificationResult{Status: VerificationStatusRisky, Reason: "disposable"}, nil
+	case strings.HasPrefix(email, "nobody"):
+		return &VerificationResult{Status: VerificationStatusInvalid, Reason: "mailbox does not exist"}, nil
+	default:
+		return &VerificationResult{Status: VerificationStatusDeliverable, Normalized: strings.ToLower(email)}, nil
+	}
+}
+
+func TestVerifyFields(t *testing.T) {
+	schema := NewForm("contact", "Contact").
+		AddField(NewFieldBuilder("email", FieldTypeEmail, "Email").Verify().Build()).
+		Build()
+
+	options := DefaultValidationOptions()
+	options.Verifiers = &Verifiers{Email: fakeEmailVerifier{}}
+	validate := func(email string) *ValidationResult {
+		return NewValidator(schema).ValidateFormWithOptions(map[string]interface{}{"email": email}, options)
+	}
+
+	result := validate("Ada@Example.com")
+	require.True(t, result.Valid)
+	require.Len(t, result.Verifications, 1)
+	assert.Equal(t, "ada@example.com", result.Verifications[0].Normalized)
+	assert.Equal(t, VerificationKindEmail, result.Verifications[0].Kind)
+
+	result = validate("temp@mailinator.com")
+	assert.True(t, result.Valid)
+	assert.Equal(t, VerificationStatusRisky, result.Verifications[0].Status)
+
+	result = validate("a@down.test")
+	assert.True(t, result.Valid)
+	assert.Equal(t, VerificationStatusUnknown, result.Verifications[0].Status)
+
+	result = validate("nobody@example.com")
+	require.False(t, result.Valid)
+	assert.Equal(t, string(ValidationTypeVerification), result.Errors[0].RuleType)
+	assert.Contains(t, result.Errors[0].Message, "mailbox does not exist")
+}
@@ -0,0 +1,493 @@
+// Package wasm runs smartform dynamic functions supplied as WebAssembly modules.
+//
+// A plugin module must follow this ABI:
+//
+//   - export its linear memory as "memory"
+//   - export "smartform_alloc(size i32) -> i32" returning a buffer the host can write into
+//   - export one or more functions with the signature "(ptr i32, len i32) -> i64"
+//
+// Each function receives a JSON document {"args": {...}, "formState": {...}} at ptr/len
+// and returns a packed i64 (ptr<<32 | len) pointing at a JSON document
+// {"result": <any>, "error": "<message>"}. A non-empty error fails the call.
+package wasm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+// Export names reserved by the plugin ABI
+const (
+	MemoryExport = "memory"
+	AllocExport  = "smartform_alloc"
+	FreeExport   = "smartform_free"
+)
+
+// Limits bounds the resources a single plugin call may consume
+type Limits struct {
+	MemoryPages uint32        // Maximum linear memory in 64KiB pages (default: 256, i.e. 16MiB)
+	Timeout     time.Duration // Maximum wall-clock time per call (default: 2s)
+	MaxOutput   uint32        // Maximum size of the result document in bytes (default: 1MiB)
+}
+
+// DefaultLimits returns the limits used when none are configured
+func DefaultLimits() Limits {
+	return Limits{
+		MemoryPages: 256,
+		Timeout:     2 * time.Second,
+		MaxOutput:   1 << 20,
+	}
+}
+
+// Plugin describes a compiled plugin module
+type Plugin struct {
+	Name      string    `json:"name"`
+	Functions []string  `json:"functions"`
+	Checksum  string    `json:"checksum"`
+	LoadedAt  time.Time `json:"loadedAt"`
+
+	compiled *compilation
+	source   string
+	modTime  time.Time
+}
+
+// compilation is a compiled module, shared by the plugins loaded from the same binary since the
+// runtime compiles each binary once. It is closed when the last plugin and call holding it
+// let go, so reloads and unloads never close a module that calls in flight still instantiate.
+type compilation struct {
+	module   wazero.CompiledModule
+	checksum string
+	refs     int // Plugins loaded from it and calls in flight, guarded by the host's mutex
+}
+
+// Host compiles WASM plugins and exposes their exports as dynamic functions
+type Host struct {
+	runtime      wazero.Runtime
+	limits       Limits
+	plugins      map[string]*Plugin
+	compilations map[string]*compilation // Keyed by checksum
+	mutex        sync.RWMutex
+	loading      sync.Mutex // Serializes loads, so that a binary is compiled once
+}
+
+// NewHost creates a new plugin host with the given limits.
+// WASI is made available so modules produced by TinyGo or Rust's wasm32-wasi target load unchanged.
+func NewHost(ctx context.Context, limits Limits) *Host {
+	defaults := DefaultLimits()
+	if limits.MemoryPages == 0 {
+		limits.MemoryPages = defaults.MemoryPages
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = defaults.Timeout
+	}
+	if limits.MaxOutput == 0 {
+		limits.MaxOutput = defaults.MaxOutput
+	}
+
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true)
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	return &Host{
+		runtime:      runtime,
+		limits:       limits,
+		plugins:      make(map[string]*Plugin),
+		compilations: make(map[string]*compilation),
+	}
+}
+
+// Load compiles a plugin and atomically replaces any plugin previously loaded under the same name
+func (h *Host) Load(ctx context.Context, name string, binary []byte) (*Plugin, error) {
+	if name == "" {
+		return nil, fmt.Errorf("plugin name is required")
+	}
+
+	h.loading.Lock()
+	defer h.loading.Unlock()
+
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	h.mutex.Lock()
+	compiled, ok := h.compilations[checksum]
+	if ok {
+		compiled.refs++
+	}
+	h.mutex.Unlock()
+
+	if !ok {
+		module, err := h.runtime.CompileModule(ctx, binary)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling plugin %s: %w", name, err)
+		}
+		compiled = &compilation{module: module, checksum: checksum, refs: 1}
+		h.mutex.Lock()
+		h.compilations[checksum] = compiled
+		h.mutex.Unlock()
+	}
+
+	functions, err := validateABI(compiled.module)
+	if err != nil {
+		h.drop(compiled)
+		return nil, fmt.Errorf("plugin %s: %w", name, err)
+	}
+
+	plugin := &Plugin{
+		Name:      name,
+		Functions: functions,
+		Checksum:  checksum,
+		LoadedAt:  time.Now(),
+		compiled:  compiled,
+	}
+
+	h.mutex.Lock()
+	previous := h.plugins[name]
+	h.plugins[name] = plugin
+	h.mutex.Unlock()
+
+	// Calls in flight hold the old compilation, so it is closed once the last of them finishes
+	if previous != nil {
+		h.drop(previous.compiled)
+	}
+
+	return plugin, nil
+}
+
+// LoadFile loads a plugin from disk, naming it after the file unless name is given
+func (h *Host) LoadFile(ctx context.Context, name, path string) (*Plugin, error) {
+	binary, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plugin file: %w", err)
+	}
+
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	plugin, err := h.Load(ctx, name, binary)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		plugin.source = path
+		plugin.modTime = info.ModTime()
+	}
+
+	return plugin, nil
+}
+
+// Unload removes a plugin; functions registered from it fail until it is loaded again
+func (h *Host) Unload(ctx context.Context, name string) {
+	h.mutex.Lock()
+	plugin, ok := h.plugins[name]
+	delete(h.plugins, name)
+	h.mutex.Unlock()
+
+	if ok {
+		h.drop(plugin.compiled)
+	}
+}
+
+// acquire returns a loaded plugin for a call, holding its compilation until the call drops it
+func (h *Host) acquire(name string) (*Plugin, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	plugin, ok := h.plugins[name]
+	if ok {
+		plugin.compiled.refs++
+	}
+	return plugin, ok
+}
+
+// drop lets go of a compilation, closing it when nothing else holds it
+func (h *Host) drop(compiled *compilation) {
+	h.mutex.Lock()
+	compiled.refs--
+	last := compiled.refs == 0
+	if last && h.compilations[compiled.checksum] == compiled {
+		delete(h.compilations, compiled.checksum)
+	}
+	h.mutex.Unlock()
+
+	if last {
+		_ = compiled.module.Close(context.Background())
+	}
+}
+
+// GetPlugin returns a loaded plugin by name
+func (h *Host) GetPlugin(name string) (*Plugin, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	plugin, ok := h.plugins[name]
+	return plugin, ok
+}
+
+// Plugins returns all loaded plugins sorted by name
+func (h *Host) Plugins() []*Plugin {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	plugins := make([]*Plugin, 0, len(h.plugins))
+	for _, plugin := range h.plugins {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// Function returns a dynamic function bound to a plugin export.
+// The plugin is looked up on every call, so hot reloads take effect immediately.
+func (h *Host) Function(pluginName, export string) smartform.DynamicFunction {
+	return func(args map[string]interface{}, formState map[string]interface{}) (interface{}, error) {
+		return h.Call(pluginName, export, args, formState)
+	}
+}
+
+// Register registers every export of a plugin with the service as "<plugin>.<export>"
+func (h *Host) Register(service *smartform.DynamicFunctionService, pluginName string) error {
+	plugin, ok := h.GetPlugin(pluginName)
+	if !ok {
+		return fmt.Errorf("plugin '%s' not loaded", pluginName)
+	}
+
+	for _, export := range plugin.Functions {
+		service.RegisterFunction(pluginName+"."+export, h.Function(pluginName, export))
+	}
+	return nil
+}
+
+// callInput is the document passed to plugin functions
+type callInput struct {
+	Args      map[string]interface{} `json:"args"`
+	FormState map[string]interface{} `json:"formState"`
+}
+
+// callOutput is the document returned by plugin functions
+type callOutput struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Call executes a plugin export in a fresh, isolated module instance
+func (h *Host) Call(pluginName, export string, args, formState map[string]interface{}) (interface{}, error) {
+	plugin, ok := h.acquire(pluginName)
+	if !ok {
+		return nil, fmt.Errorf("plugin '%s' not loaded", pluginName)
+	}
+	defer h.drop(plugin.compiled)
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.limits.Timeout)
+	defer cancel()
+
+	// Anonymous instances allow concurrent calls and guarantee no state leaks between them
+	module, err := h.runtime.InstantiateModule(ctx, plugin.compiled.module,
+		wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, fmt.Errorf("error instantiating plugin %s: %w", pluginName, err)
+	}
+	defer module.Close(context.Background())
+
+	fn := module.ExportedFunction(export)
+	if fn == nil {
+		return nil, fmt.Errorf("plugin %s does not export '%s'", pluginName, export)
+	}
+
+	payload, err := json.Marshal(callInput{Args: args, FormState: formState})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling plugin input: %w", err)
+	}
+
+	allocated, err := module.ExportedFunction(AllocExport).Call(ctx, uint64(len(payload)))
+	if err != nil {
+		return nil, h.wrapCallError(ctx, pluginName, export, err)
+	}
+	inputPtr := uint32(allocated[0])
+
+	if !module.Memory().Write(inputPtr, payload) {
+		return nil, fmt.Errorf("plugin %s: input buffer out of range", pluginName)
+	}
+
+	results, err := fn.Call(ctx, uint64(inputPtr), uint64(len(payload)))
+	if err != nil {
+		return nil, h.wrapCallError(ctx, pluginName, export, err)
+	}
+
+	outputPtr := uint32(results[0] >> 32)
+	outputLen := uint32(results[0])
+	if outputLen > h.limits.MaxOutput {
+		return nil, fmt.Errorf("plugin %s.%s returned %d bytes, exceeding the %d byte limit",
+			pluginName, export, outputLen, h.limits.MaxOutput)
+	}
+
+	data, ok := module.Memory().Read(outputPtr, outputLen)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: output buffer out of range", pluginName)
+	}
+
+	var output callOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, fmt.Errorf("plugin %s.%s returned invalid JSON: %w", pluginName, export, err)
+	}
+
+	if output.Error != "" {
+		return nil, errors.New(output.Error)
+	}
+
+	return output.Result, nil
+}
+
+// wrapCallError turns context expiry into a readable limit error
+func (h *Host) wrapCallError(ctx context.Context, pluginName, export string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("plugin %s.%s exceeded the %s time limit", pluginName, export, h.limits.Timeout)
+	}
+	return fmt.Errorf("error executing plugin %s.%s: %w", pluginName, export, err)
+}
+
+// Watch polls dir for *.wasm files, loading new or modified plugins and unloading deleted ones
+// until ctx is cancelled. When service is non-nil, loaded plugins are (re)registered with it.
+func (h *Host) Watch(ctx context.Context, dir string, interval time.Duration, service *smartform.DynamicFunctionService) error {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	if err := h.scanDir(ctx, dir, service); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_ = h.scanDir(ctx, dir, service)
+		}
+	}
+}
+
+// scanDir synchronises loaded plugins with the contents of dir
+func (h *Host) scanDir(ctx context.Context, dir string, service *smartform.DynamicFunctionService) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wasm"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var errs []error
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".wasm")
+		seen[name] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if existing, ok := h.GetPlugin(name); ok && existing.source == path && !info.ModTime().After(existing.modTime) {
+			continue
+		}
+
+		if _, err := h.LoadFile(ctx, name, path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if service != nil {
+			_ = h.Register(service, name)
+		}
+	}
+
+	// Unload plugins whose file has been removed
+	for _, plugin := range h.Plugins() {
+		if plugin.source != "" && filepath.Dir(plugin.source) == filepath.Clean(dir) && !seen[plugin.Name] {
+			h.Unload(ctx, plugin.Name)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close releases the runtime and all compiled plugins
+func (h *Host) Close(ctx context.Context) error {
+	h.mutex.Lock()
+	h.plugins = make(map[string]*Plugin)
+	h.compilations = make(map[string]*compilation)
+	h.mutex.Unlock()
+	return h.runtime.Close(ctx)
+}
+
+// validateABI checks the required exports and returns the callable function names
+func validateABI(compiled wazero.CompiledModule) ([]string, error) {
+	if _, ok := compiled.ExportedMemories()[MemoryExport]; !ok {
+		return nil, fmt.Errorf("module must export '%s'", MemoryExport)
+	}
+
+	exports := compiled.ExportedFunctions()
+
+	alloc, ok := exports[AllocExport]
+	if !ok || !hasSignature(alloc, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		return nil, fmt.Errorf("module must export '%s(i32) -> i32'", AllocExport)
+	}
+
+	functions := []string{}
+	for name, definition := range exports {
+		if name == AllocExport || name == FreeExport || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if hasSignature(definition,
+			[]api.ValueType{api.ValueTypeI32, api.ValueTypeI32},
+			[]api.ValueType{api.ValueTypeI64}) {
+			functions = append(functions, name)
+		}
+	}
+
+	if len(functions) == 0 {
+		return nil, fmt.Errorf("module exports no functions with the signature (i32, i32) -> i64")
+	}
+
+	sort.Strings(functions)
+	return functions, nil
+}
+
+// hasSignature reports whether a function definition has the given parameter and result types
+func hasSignature(definition api.FunctionDefinition, params, results []api.ValueType) bool {
+	return equalTypes(definition.ParamTypes(), params) && equalTypes(definition.ResultTypes(), results)
+}
+
+func equalTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
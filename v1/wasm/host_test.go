@@ -0,0 +1,129 @@
+package wasm
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tetratelabs/wazero"
+
+	smartform "github.com/juicycleff/smartform/v1"
+)
+
+// answerModule is a hand-assembled plugin exporting memory, smartform_alloc and
+// "answer", which ignores its input and returns {"result":42} from a data segment.
+var answerModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic + version
+	// type section: (i32)->i32, (i32,i32)->i64
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e,
+	// function section
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	// memory section: one page
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// export section
+	0x07, 0x25, 0x03,
+	0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+	0x0f, 's', 'm', 'a', 'r', 't', 'f', 'o', 'r', 'm', '_', 'a', 'l', 'l', 'o', 'c', 0x00, 0x00,
+	0x06, 'a', 'n', 's', 'w', 'e', 'r', 0x00, 0x01,
+	// code section: alloc returns 1024, answer returns (0<<32 | 13)
+	0x0a, 0x0c, 0x02,
+	0x05, 0x00, 0x41, 0x80, 0x08, 0x0b,
+	0x04, 0x00, 0x42, 0x0d, 0x0b,
+	// data section: {"result":42} at offset 0
+	0x0b, 0x13, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x0d,
+	'{', '"', 'r', 'e', 's', 'u', 'l', 't', '"', ':', '4', '2', '}',
+}
+
+func TestHost_LoadAndCall(t *testing.T) {
+	ctx := context.Background()
+	host := NewHost(ctx, Limits{})
+	defer host.Close(ctx)
+
+	plugin, err := host.Load(ctx, "demo", answerModule)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"answer"}, plugin.Functions)
+	assert.NotEmpty(t, plugin.Checksum)
+
+	result, err := host.Call("demo", "answer", map[string]interface{}{"x": 1}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), result)
+}
+
+func TestHost_ReloadWhileCalling(t *testing.T) {
+	ctx := context.Background()
+	host := NewHost(ctx, Limits{})
+	defer host.Close(ctx)
+
+	_, err := host.Load(ctx, "demo", answerModule)
+	require.NoError(t, err)
+
+	// Calls started before a reload finish with the module they started with
+	var wg sync.WaitGroup
+	errs := make(chan error, 8*50)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := host.Call("demo", "answer", nil, nil); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		_, err := host.Load(ctx, "demo", answerModule)
+		require.NoError(t, err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	// The module of an unloaded plugin is closed once its last call finishes
+	plugin, ok := host.acquire("demo")
+	require.True(t, ok)
+	host.Unload(ctx, "demo")
+	module, err := host.runtime.InstantiateModule(ctx, plugin.compiled.module, wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+	require.NoError(t, module.Close(ctx))
+	host.drop(plugin.compiled)
+	_, err = host.runtime.InstantiateModule(ctx, plugin.compiled.module, wazero.NewModuleConfig().WithName(""))
+	assert.Error(t, err)
+	assert.Empty(t, host.compilations)
+}
+
+func TestHost_RegisterWithService(t *testing.T) {
+	ctx := context.Background()
+	host := NewHost(ctx, Limits{})
+	defer host.Close(ctx)
+
+	_, err := host.Load(ctx, "demo", answerModule)
+	require.NoError(t, err)
+
+	service := smartform.NewDynamicFunctionService()
+	require.NoError(t, host.Register(service, "demo"))
+
+	result, err := service.ExecuteFunction("demo.answer", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), result)
+
+	host.Unload(ctx, "demo")
+	_, err = service.ExecuteFunction("demo.answer", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestHost_RejectsInvalidModules(t *testing.T) {
+	ctx := context.Background()
+	host := NewHost(ctx, Limits{})
+	defer host.Close(ctx)
+
+	_, err := host.Load(ctx, "broken", []byte("not wasm"))
+	assert.Error(t, err)
+
+	_, err = host.Load(ctx, "", answerModule)
+	assert.Error(t, err)
+}